@@ -0,0 +1,178 @@
+// Command certs-n-status runs the endpoint checker and the dashboard in one
+// process, sharing one store. With STORAGE=memory, or --standalone, the
+// store is kept in memory, for a demo without Redis or PostgreSQL:
+//
+//	certs-n-status --standalone --endpoints endpoints.lst
+//
+// SIGTERM and Ctrl-C stop the checks and drain the dashboard before the
+// store is closed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+	"certs-n-status/store/sdnotify"
+	"certs-n-status/store/version"
+	"dashboard-go/dashboard"
+	"endpoint-checker/checker"
+)
+
+// configKeys are the keys of the --config file: both binaries' sections,
+// neither of them skipped. The one log of the process is set up in the
+// dashboard section.
+var configKeys = slices.Concat(
+	slices.DeleteFunc(slices.Clone(checker.ConfigKeys), func(key store.ConfigKey) bool {
+		return slices.Contains(logging.ConfigKeys("checker"), key)
+	}),
+	slices.DeleteFunc(slices.Clone(dashboard.ConfigKeys), func(key store.ConfigKey) bool {
+		return slices.Contains(store.SharedConfigKeys, key)
+	}),
+)
+
+func main() {
+	configFile := flag.String("config", "", "YAML config file; environment variables override its settings")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	standalone := flag.Bool("standalone", false, "keep the results in memory instead of Redis or PostgreSQL, for a demo (STORAGE=memory)")
+	endpoints := flag.String("endpoints", "", "file of the endpoints to check, overriding ENDPOINTS_FILE")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	if *showVersion {
+		fmt.Println("certs-n-status " + version.Get().String())
+		return
+	}
+
+	if *standalone {
+		os.Setenv("STORAGE", "memory")
+	}
+	if *endpoints != "" {
+		os.Setenv("ENDPOINTS_FILE", *endpoints)
+	}
+	if *configFile != "" {
+		if err := store.ApplyConfigFile(*configFile, configKeys); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	var env store.Env
+	checkerConfig, dashboardConfig, err := loadConfig(&env)
+	if *printConfig {
+		if err := env.WriteConfig(os.Stdout, configKeys); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	if err != nil {
+		logging.Setup(logging.Config{Format: checkerConfig.Log.Format})
+		log.Fatalf("[FATAL] Invalid configuration:\n%v", err)
+	}
+	if *printConfig {
+		return
+	}
+	closeLog, err := logging.Setup(checkerConfig.Log)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to set up logging: %v", err)
+	}
+	defer closeLog()
+	log.Printf("[INFO] Starting certs-n-status %s", version.Get())
+
+	// SIGTERM (docker stop, Kubernetes) and Ctrl-C stop both halves
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, checkerConfig, dashboardConfig); err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+	log.Printf("[INFO] Shutdown complete")
+}
+
+// loadConfig reads the settings of both halves through env. Both read the
+// storage and log settings, so their problems are reported once, and both
+// log as certs-n-status.
+func loadConfig(env *store.Env) (checker.Config, dashboard.Config, error) {
+	// env collects the problems of both
+	checkerConfig, _ := checker.LoadConfig(env)
+	dashboardConfig, _ := dashboard.LoadConfig(env)
+	checkerConfig.Log = logging.ConfigFromEnv(env, "certs-n-status")
+	dashboardConfig.Log = checkerConfig.Log
+	var problems []error
+	err := env.Err()
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, problem := range joined.Unwrap() {
+			if !slices.ContainsFunc(problems, func(seen error) bool { return seen.Error() == problem.Error() }) {
+				problems = append(problems, problem)
+			}
+		}
+	} else if err != nil {
+		problems = append(problems, err)
+	}
+	return checkerConfig, dashboardConfig, errors.Join(problems...)
+}
+
+// run runs the checker and the dashboard on one store until ctx is done or
+// either of them fails, then stops the other
+func run(ctx context.Context, checkerConfig checker.Config, dashboardConfig dashboard.Config) error {
+	var st store.Store
+	if checkerConfig.Storage == "memory" {
+		st = checker.NewMemoryStore(checkerConfig)
+		log.Printf("[WARN] STORAGE=memory: results are kept in this process only and lost on restart")
+	} else {
+		var err error
+		if st, err = checker.NewStore(ctx, checkerConfig); err != nil {
+			return fmt.Errorf("failed to open storage: %w", err)
+		}
+		if rs, ok := st.(*store.RedisStore); ok {
+			rs.SetScanDiscovery(dashboardConfig.ScanDiscovery)
+		}
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Printf("[WARN] Failed to close storage: %v", err)
+		}
+	}()
+
+	ec := checker.NewEndpointChecker(checkerConfig, st)
+	server, err := dashboard.NewServer(dashboardConfig, st)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	checked := make(chan error, 1)
+	go func() {
+		err := ec.Start()
+		cancel() // a failed checker stops the dashboard
+		checked <- err
+	}()
+	// Under systemd, Type=notify waits for both halves and WatchdogSec
+	// restarts the binary when the checker's cycles stop completing
+	go func() {
+		for _, ready := range []<-chan struct{}{ec.Ready(), server.Ready()} {
+			select {
+			case <-ready:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := sdnotify.Notify(sdnotify.Ready); err != nil {
+			log.Printf("[WARN] Failed to notify systemd: %v", err)
+		}
+		sdnotify.Watchdog(ctx, ec.Healthy)
+	}()
+	serverErr := server.Start(ctx)
+	ec.Stop()
+	if err := <-checked; err != nil {
+		return fmt.Errorf("checker error: %w", err)
+	}
+	if serverErr != nil {
+		return fmt.Errorf("server error: %w", serverErr)
+	}
+	return nil
+}
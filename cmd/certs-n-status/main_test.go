@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"certs-n-status/store"
+)
+
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range configKeys {
+		t.Setenv(key.Env, "")
+	}
+}
+
+// TestLoadConfig tests that problems of the settings both halves read are
+// reported once
+func TestLoadConfig(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("STORAGE", "etcd")
+	t.Setenv("REDIS_DB", "first")
+	_, _, err := loadConfig(&store.Env{})
+	if err == nil {
+		t.Fatal("loadConfig() succeeded")
+	}
+	for _, want := range []string{`unknown STORAGE "etcd"`, "invalid REDIS_DB value"} {
+		if n := strings.Count(err.Error(), want); n != 1 {
+			t.Errorf("loadConfig() reports %q %d times, want once:\n%v", want, n, err)
+		}
+	}
+
+	clearConfigEnv(t)
+	t.Setenv("STORAGE", "memory")
+	t.Setenv("ENDPOINTS_SOURCE", "redis")
+	if _, _, err := loadConfig(&store.Env{}); err == nil || !strings.Contains(err.Error(), "ENDPOINTS_SOURCE=redis requires Redis storage, not memory") {
+		t.Errorf("loadConfig() of an in-memory registry = %v, want an error", err)
+	}
+}
+
+// TestConfigKeys tests that every variable has one key, so that
+// --print-config writes it once
+func TestConfigKeys(t *testing.T) {
+	paths := make(map[string]string)
+	for _, key := range configKeys {
+		if path, ok := paths[key.Env]; ok {
+			t.Errorf("%s has the keys %s and %s", key.Env, path, key.Path)
+		}
+		paths[key.Env] = key.Path
+	}
+	if paths["LOG_OUTPUT"] != "dashboard.log.output" {
+		t.Errorf("LOG_OUTPUT key = %q, want the dashboard section's", paths["LOG_OUTPUT"])
+	}
+}
+
+// TestRunStandalone tests that the dashboard serves the checker's results
+// from the shared in-memory store, and that both stop with ctx
+func TestRunStandalone(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	file := filepath.Join(t.TempDir(), "endpoints.lst")
+	if err := os.WriteFile(file, []byte(target.URL+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	clearConfigEnv(t)
+	t.Setenv("STORAGE", "memory")
+	t.Setenv("ENDPOINTS_FILE", file)
+	t.Setenv("SERVER_PORT", port)
+	t.Setenv("CACHE_TTL", "0s")
+	checkerConfig, dashboardConfig, err := loadConfig(&store.Env{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, checkerConfig, dashboardConfig) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if resp, err := http.Get("http://127.0.0.1:" + port + "/api/endpoints"); err == nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if strings.Contains(string(body), target.URL) {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the dashboard did not serve the checked endpoint")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run() = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run() did not return after ctx was done")
+	}
+}
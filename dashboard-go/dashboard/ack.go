@@ -0,0 +1,214 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// Bounds of POST /api/endpoints/ack
+const (
+	maxAckRequestSize  = 4 << 10
+	maxAckReasonLength = 500
+	maxAckDuration     = 90 * 24 * time.Hour
+)
+
+// ackRequest is the body of POST /api/endpoints/ack
+type ackRequest struct {
+	URL      string `json:"url"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"` // e.g. 2h or 3d
+}
+
+// APIAck is an acknowledgement in API responses. Endpoint is left out where
+// it is nested in an endpoint.
+type APIAck struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Reason   string `json:"reason"`
+	User     string `json:"user,omitempty"`
+	At       string `json:"at"`
+	Until    string `json:"until"`
+}
+
+func newAPIAck(ack store.Ack) APIAck {
+	return APIAck{Endpoint: ack.Endpoint, Reason: ack.Reason, User: ack.User, At: apiTime(ack.At), Until: apiTime(ack.Until)}
+}
+
+// AckTitle describes the endpoint's acknowledgement for the row tooltip,
+// with its expiry in location
+func (e EndpointData) AckTitle(location *time.Location) string {
+	if e.Ack == nil {
+		return ""
+	}
+	by := ""
+	if e.Ack.User != "" {
+		by = " by " + e.Ack.User
+	}
+	return fmt.Sprintf("Acknowledged%s until %s: %s", by, e.Ack.Until.In(location).Format("2006-01-02 15:04 MST"), e.Ack.Reason)
+}
+
+// readAcks returns the acknowledgements in effect by endpoint, or nil
+// without Redis storage or when they cannot be read, in which case no
+// endpoint shows as acknowledged
+func (s *Server) readAcks(ctx context.Context) map[string]store.Ack {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	acks, err := rs.Acks(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read acknowledgements: %v", err)
+		return nil
+	}
+	byEndpoint := make(map[string]store.Ack, len(acks))
+	for _, ack := range acks {
+		byEndpoint[ack.Endpoint] = ack
+	}
+	return byEndpoint
+}
+
+// requestUser returns the dashboard login the request was made with, or ""
+// when no login is required. API tokens name no user.
+func (s *Server) requestUser(r *http.Request) string {
+	if s.auth == nil {
+		return ""
+	}
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// ackStore returns the Redis store, or answers that acknowledgements need it
+func (s *Server) ackStore(w http.ResponseWriter) (*store.RedisStore, bool) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Acknowledgements require Redis storage", http.StatusNotImplemented)
+	}
+	return rs, ok
+}
+
+// handleAPIAck serves POST /api/endpoints/ack, acknowledging a monitored
+// endpoint given as {"url", "reason", "duration"} until the duration has
+// passed, replacing any earlier acknowledgement
+func (s *Server) handleAPIAck(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	rs, ok := s.ackStore(w)
+	if !ok {
+		return
+	}
+	// Like adding endpoints, a JSON body keeps other sites from posting
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var request ackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAckRequestSize)).Decode(&request); err != nil {
+		http.Error(w, `Expected a body of {"url": "https://example.com", "reason": "...", "duration": "24h"}`, http.StatusBadRequest)
+		return
+	}
+	endpoint, err := parseEndpointURL(request.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" || len(reason) > maxAckReasonLength {
+		http.Error(w, fmt.Sprintf("A reason of 1 to %d characters is required", maxAckReasonLength), http.StatusBadRequest)
+		return
+	}
+	duration, err := parseDuration(strings.TrimSpace(request.Duration))
+	if err != nil || duration <= 0 || duration > maxAckDuration {
+		http.Error(w, fmt.Sprintf("invalid duration %q (use e.g. 2h or 3d, at most %dd)", request.Duration, int(maxAckDuration.Hours()/24)), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := rs.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to get endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read endpoint %s: %v", endpoint, err)
+		return
+	}
+	if !stored.HasStatus && stored.SSLUpdated.IsZero() {
+		s.notFound(w, r)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	ack := store.Ack{Endpoint: endpoint, Reason: reason, User: s.requestUser(r), At: now, Until: now.Add(duration)}
+	if err := rs.Acknowledge(ctx, ack); err != nil {
+		http.Error(w, "Failed to acknowledge endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to acknowledge %s: %v", endpoint, err)
+		return
+	}
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Endpoint %s acknowledged for %s by %q from %s: %s", endpoint, duration, ack.User, clientIP(r, s.config.TrustProxy), reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAPIAck(ack))
+}
+
+// handleAPIUnack serves DELETE /api/endpoints/ack?url=..., ending an
+// acknowledgement before it expires
+func (s *Server) handleAPIUnack(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	rs, ok := s.ackStore(w)
+	if !ok {
+		return
+	}
+	endpoint, err := parseEndpointURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	removed, err := rs.Unacknowledge(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to remove acknowledgement", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to remove the acknowledgement of %s: %v", endpoint, err)
+		return
+	}
+	if !removed {
+		s.notFound(w, r)
+		return
+	}
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Acknowledgement of %s removed by %q from %s", endpoint, s.requestUser(r), clientIP(r, s.config.TrustProxy))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIAcks serves GET /api/endpoints/acks, the acknowledgements in
+// effect, soonest expiring first
+func (s *Server) handleAPIAcks(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.ackStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	acks, err := rs.Acks(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get acknowledgements", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read acknowledgements: %v", err)
+		return
+	}
+	response := make([]APIAck, 0, len(acks))
+	for _, ack := range acks {
+		response = append(response, newAPIAck(ack))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,111 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// recentAlertsShown is how many notifications the Recent alerts section of
+// the dashboard lists
+const recentAlertsShown = 10
+
+// APIAlert is a notification of the checker's alert history in API
+// responses
+type APIAlert struct {
+	ID        string `json:"id"`
+	Endpoint  string `json:"endpoint"`
+	Kind      string `json:"kind"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Notifier  string `json:"notifier"`
+	Route     string `json:"route,omitempty"`
+	Delivered bool   `json:"delivered"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts"`
+	At        string `json:"at"`
+}
+
+func newAPIAlert(record store.AlertRecord) APIAlert {
+	return APIAlert{
+		ID:        record.ID,
+		Endpoint:  record.Endpoint,
+		Kind:      record.Kind,
+		Old:       record.Old,
+		New:       record.New,
+		Notifier:  record.Notifier,
+		Route:     record.Route,
+		Delivered: record.Delivered,
+		Error:     record.Error,
+		Attempts:  record.Attempts,
+		At:        apiTime(record.At),
+	}
+}
+
+// readRecentAlerts returns the newest notifications for the dashboard, or
+// nil without Redis storage or when they cannot be read, in which case the
+// section is left out
+func (s *Server) readRecentAlerts(ctx context.Context) []store.AlertRecord {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	records, err := rs.AlertHistory(ctx, "", time.Time{}, recentAlertsShown)
+	if err != nil {
+		log.Printf("[WARN] Failed to read the alert history: %v", err)
+		return nil
+	}
+	return records
+}
+
+// handleAPIAlerts returns the notifications of the checker's alert history,
+// newest first, optionally only those of one endpoint or since a time
+// (all that are kept when omitted). Undelivered ones have delivered false
+// and the error of their last attempt.
+func (s *Server) handleAPIAlerts(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Alert history requires Redis storage", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = parseSince(value, time.Now(), 0); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit value %q (use 1 to %d)", value, maxEventsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	records, err := rs.AlertHistory(ctx, query.Get("endpoint"), since, limit)
+	if err != nil {
+		http.Error(w, "Failed to get alert history", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read the alert history: %v", err)
+		return
+	}
+	response := make([]APIAlert, 0, len(records))
+	for _, record := range records {
+		response = append(response, newAPIAlert(record))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
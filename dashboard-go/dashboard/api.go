@@ -0,0 +1,222 @@
+package dashboard
+
+import (
+	"net/http"
+	"time"
+)
+
+// APIEndpoint is one endpoint as returned by /api/v1/endpoints. Unlike
+// EndpointData, which feeds the HTML template, it carries no presentation
+// fields; timestamps are RFC 3339 in UTC and absent values are omitted.
+type APIEndpoint struct {
+	Endpoint        string          `json:"endpoint"`
+	Name            string          `json:"name,omitempty"`        // display name of the endpoints file
+	Description     string          `json:"description,omitempty"` // description of the endpoints file
+	HTTPS           bool            `json:"https"`
+	StatusCode      *int            `json:"status_code,omitempty"`
+	StatusUpdatedAt string          `json:"status_updated_at,omitempty"`
+	RemoteAddr      string          `json:"remote_addr,omitempty"` // IP and port that served the last status check
+	CheckedBy       string          `json:"checked_by,omitempty"`  // CHECKER_ID of the checker instance behind the last status check
+	AlertState      string          `json:"alert_state,omitempty"` // ok, warning, critical, expired or down
+	SSLExpiration   string          `json:"ssl_expiration,omitempty"`
+	DaysLeft        *int            `json:"days_left,omitempty"`
+	SSLUpdatedAt    string          `json:"ssl_updated_at,omitempty"`
+	SSLCheckedBy    string          `json:"ssl_checked_by,omitempty"` // CHECKER_ID of the checker instance behind the last SSL check
+	Certificate     *APICertificate `json:"certificate,omitempty"`
+	HeaderAudit     *APIHeaderAudit `json:"header_audit,omitempty"`
+	CapturedHeaders *APICaptured    `json:"captured_headers,omitempty"`
+	Tags            []string        `json:"tags,omitempty"`
+	Acknowledgement *APIAck         `json:"acknowledgement,omitempty"`
+	InMaintenance   bool            `json:"in_maintenance,omitempty"`
+	Stale           bool            `json:"stale,omitempty"` // the checker stopped updating it
+	// PausedBySchedule is set while the endpoint's schedule keeps the
+	// checker from checking it; such an endpoint is never stale
+	PausedBySchedule bool `json:"paused_by_schedule,omitempty"`
+	// Pause is set while the endpoint is paused through the API; such an
+	// endpoint is not checked and never stale
+	Pause *APIPause `json:"pause,omitempty"`
+	// ErrorClass, ErrorMessage and ErrorAt describe why the last status
+	// check was not up, and are absent once a check is up again
+	ErrorClass   string `json:"error_class,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	ErrorAt      string `json:"error_at,omitempty"`
+	// Uptime maps each window ("24h", "7d", "30d") to the percentage of up
+	// checks, null when the window has no checks
+	Uptime map[string]*float64 `json:"uptime,omitempty"`
+}
+
+type APICertificate struct {
+	NotBefore    string `json:"not_before,omitempty"`
+	NotAfter     string `json:"not_after,omitempty"`
+	Subject      string `json:"subject,omitempty"`
+	Issuer       string `json:"issuer,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	Fingerprint  string `json:"fingerprint,omitempty"`
+	State        string `json:"state,omitempty"`
+	RemoteAddr   string `json:"remote_addr,omitempty"` // IP and port the certificate was read from
+}
+
+type APIHeaderAudit struct {
+	Passed    bool              `json:"passed"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Failures  []string          `json:"failures,omitempty"`
+	UpdatedAt string            `json:"updated_at,omitempty"`
+}
+
+// APICaptured are the response headers the checker captured for
+// diagnostics, by CAPTURE_HEADERS
+type APICaptured struct {
+	Headers   map[string]string `json:"headers"`
+	ChangedAt string            `json:"changed_at,omitempty"` // when the headers last changed
+}
+
+// APIEndpointList is the /api/v1/endpoints response
+type APIEndpointList struct {
+	Endpoints  []APIEndpoint `json:"endpoints"`
+	Total      int           `json:"total"`
+	StaleSince string        `json:"stale_since,omitempty"` // set when cached data is served
+}
+
+// APISelectedEndpointList is the /api/v1/endpoints response when the fields
+// parameter picks the fields of each endpoint
+type APISelectedEndpointList struct {
+	Endpoints  []map[string]any `json:"endpoints"`
+	Total      int              `json:"total"`
+	StaleSince string           `json:"stale_since,omitempty"`
+}
+
+// newAPIEndpoint converts the dashboard view of an endpoint to its API form
+func newAPIEndpoint(data EndpointData) APIEndpoint {
+	endpoint := APIEndpoint{
+		Endpoint:      data.Endpoint,
+		Name:          data.Name,
+		Description:   data.Description,
+		HTTPS:         data.IsHTTPS,
+		AlertState:    data.AlertState,
+		SSLExpiration: apiTimePtr(data.SSLExpiration),
+		DaysLeft:      data.DaysLeft,
+		SSLUpdatedAt:  apiTimePtr(data.LastSSLUpdate),
+		SSLCheckedBy:  data.SSLCheckedBy,
+		Uptime:        apiUptime(data.Uptime),
+		Tags:          data.Tags,
+		InMaintenance: data.InMaintenance,
+		Stale:         data.Stale,
+
+		PausedBySchedule: data.PausedBySchedule,
+	}
+	// StatusText is only set once a status check was recorded, and
+	// StatusCode is 0 for failed connections
+	if data.StatusText != "" {
+		code := data.StatusCode
+		endpoint.StatusCode = &code
+		endpoint.StatusUpdatedAt = apiTimePtr(data.LastStatusUpdate)
+		endpoint.RemoteAddr = data.RemoteAddr
+		endpoint.CheckedBy = data.CheckedBy
+	}
+	if checkErr := data.Error; checkErr != nil {
+		endpoint.ErrorClass = checkErr.Class
+		endpoint.ErrorMessage = checkErr.Message
+		endpoint.ErrorAt = apiTime(checkErr.At)
+	}
+	if data.Ack != nil {
+		ack := newAPIAck(*data.Ack)
+		ack.Endpoint = ""
+		endpoint.Acknowledgement = &ack
+	}
+	if data.Pause != nil {
+		pause := newAPIPause(*data.Pause)
+		pause.Endpoint = ""
+		endpoint.Pause = &pause
+	}
+	if cert := data.CertInfo; cert != nil {
+		endpoint.Certificate = &APICertificate{
+			NotBefore:    apiTime(cert.NotBefore),
+			NotAfter:     apiTime(cert.NotAfter),
+			Subject:      cert.Subject,
+			Issuer:       cert.Issuer,
+			SerialNumber: cert.SerialNumber,
+			Fingerprint:  cert.Fingerprint,
+			State:        cert.State,
+			RemoteAddr:   cert.RemoteAddr,
+		}
+	}
+	if audit := data.HeaderAudit; audit != nil {
+		endpoint.HeaderAudit = &APIHeaderAudit{
+			Passed:    audit.Passed(),
+			Headers:   audit.Headers,
+			Failures:  audit.Failures,
+			UpdatedAt: apiTime(audit.Updated),
+		}
+	}
+	if captured := data.Captured; captured != nil {
+		endpoint.CapturedHeaders = &APICaptured{Headers: captured.Headers, ChangedAt: apiTime(captured.Changed)}
+	}
+	return endpoint
+}
+
+// apiTime formats t as RFC 3339 in UTC, or "" for the zero time the store
+// uses for missing values
+func apiTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func apiTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return apiTime(*t)
+}
+
+// handleAPIv1Endpoints serves GET /api/v1/endpoints, the endpoints matching
+// the filterEndpoints query parameters ordered by sortEndpoints, in the
+// versioned API format, reduced to the selectFields fields when requested
+func (s *Server) handleAPIv1Endpoints(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.viewQuery(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+
+	endpointData, staleSince, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get endpoints", storeErrorStatus(ctx))
+		return
+	}
+	if endpointData, err = filterEndpoints(query, endpointData, time.Now().UTC()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := sortEndpoints(query, endpointData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	endpoints := make([]APIEndpoint, 0, len(endpointData))
+	for _, data := range endpointData {
+		endpoints = append(endpoints, newAPIEndpoint(data))
+	}
+	selected, err := selectFields(query, apiEndpointFields, endpoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := s.staleStatus(w, ctx, staleSince)
+	if selected != nil {
+		writeJSONWithETag(w, r, status, APISelectedEndpointList{
+			Endpoints:  selected,
+			Total:      len(selected),
+			StaleSince: apiTime(staleSince),
+		})
+		return
+	}
+	writeJSONWithETag(w, r, status, APIEndpointList{
+		Endpoints:  endpoints,
+		Total:      len(endpoints),
+		StaleSince: apiTime(staleSince),
+	})
+}
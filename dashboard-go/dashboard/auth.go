@@ -0,0 +1,206 @@
+package dashboard
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authRealm names the protection space in WWW-Authenticate
+const authRealm = "Certs-n-Status"
+
+// basicAuthExempt are the paths served without credentials: liveness
+// probes cannot send them, and the public status page is for customers.
+// apiTokenHandler does not require a token on them either.
+var basicAuthExempt = map[string]bool{
+	"/healthz":    true,
+	"/status":     true,
+	"/api/public": true,
+}
+
+// dummyBcryptHash is compared against for unknown users, so that a
+// wrong username takes as long to reject as a wrong password
+var dummyBcryptHash = []byte("$2a$10$fERbZuP9TCG7bPdeehl4C.WJ1WHOXto3CBhOWYlQG6d.TwFnS8QrO")
+
+// basicAuth holds the dashboard users: the DASHBOARD_USERNAME and
+// DASHBOARD_PASSWORD pair, and the bcrypt hashes of a htpasswd file
+type basicAuth struct {
+	username     [sha256.Size]byte // hashed so comparisons take constant time whatever the length
+	password     [sha256.Size]byte
+	hasPassword  bool
+	bcryptHashes map[string][]byte
+}
+
+// newBasicAuth returns the configured users, or nil when none are
+func newBasicAuth(config Config) (*basicAuth, error) {
+	auth := &basicAuth{}
+	if config.DashboardUsername != "" || config.DashboardPassword != "" {
+		if config.DashboardUsername == "" || config.DashboardPassword == "" {
+			return nil, fmt.Errorf("DASHBOARD_USERNAME and DASHBOARD_PASSWORD must be set together")
+		}
+		auth.username = sha256.Sum256([]byte(config.DashboardUsername))
+		auth.password = sha256.Sum256([]byte(config.DashboardPassword))
+		auth.hasPassword = true
+	}
+	if config.DashboardHtpasswd != "" {
+		hashes, err := loadHtpasswd(config.DashboardHtpasswd)
+		if err != nil {
+			return nil, err
+		}
+		auth.bcryptHashes = hashes
+	}
+	if !auth.hasPassword && auth.bcryptHashes == nil {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// loadHtpasswd reads "user:hash" lines as written by `htpasswd -B`; blank
+// lines and # comments are skipped, and hashes other than bcrypt refused
+func loadHtpasswd(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("DASHBOARD_HTPASSWD_FILE: %w", err)
+	}
+	defer file.Close()
+
+	hashes := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("DASHBOARD_HTPASSWD_FILE %s line %d: expected user:hash", path, n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("DASHBOARD_HTPASSWD_FILE %s line %d: user %q does not have a bcrypt hash (create it with htpasswd -B)", path, n, user)
+		}
+		hashes[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("DASHBOARD_HTPASSWD_FILE %s: %w", path, err)
+	}
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("DASHBOARD_HTPASSWD_FILE %s has no users", path)
+	}
+	return hashes, nil
+}
+
+// valid reports whether the credentials belong to a user
+func (a *basicAuth) valid(username, password string) bool {
+	if a.hasPassword {
+		user := sha256.Sum256([]byte(username))
+		pass := sha256.Sum256([]byte(password))
+		userOK := subtle.ConstantTimeCompare(user[:], a.username[:])
+		passOK := subtle.ConstantTimeCompare(pass[:], a.password[:])
+		if userOK&passOK == 1 {
+			return true
+		}
+	}
+	if a.bcryptHashes == nil {
+		return false
+	}
+	hash, ok := a.bcryptHashes[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyBcryptHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// basicAuthHandler requires the credentials of a dashboard user on every
+// path but the basicAuthExempt ones
+func basicAuthHandler(h http.Handler, auth *basicAuth, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if basicAuthExempt[r.URL.Path] {
+			h.ServeHTTP(w, r)
+			return
+		}
+		username, password, ok := r.BasicAuth()
+		if !ok || !auth.valid(username, password) {
+			if ok {
+				log.Printf("[WARN] Failed dashboard login for user %q from %s", username, clientIP(r, trustProxy))
+			}
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, authRealm))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// apiTokens are the bearer tokens accepted on /api/, kept as hashes so
+// every comparison takes the same time
+type apiTokens [][sha256.Size]byte
+
+// newAPITokens returns the tokens of API_TOKENS and API_TOKENS_FILE (one
+// per line, # comments allowed), or nil when neither is set
+func newAPITokens(config Config) (apiTokens, error) {
+	tokens := slices.Clone(config.APITokens)
+	if config.APITokensFile != "" {
+		data, err := os.ReadFile(config.APITokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("API_TOKENS_FILE: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				tokens = append(tokens, line)
+			}
+		}
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("API_TOKENS_FILE %s has no tokens", config.APITokensFile)
+		}
+	}
+
+	var hashed apiTokens
+	for _, token := range tokens {
+		hashed = append(hashed, sha256.Sum256([]byte(token)))
+	}
+	return hashed, nil
+}
+
+// valid reports whether token is one of the tokens, comparing all of them
+func (t apiTokens) valid(token string) bool {
+	given := sha256.Sum256([]byte(token))
+	match := 0
+	for _, hash := range t {
+		match |= subtle.ConstantTimeCompare(given[:], hash[:])
+	}
+	return match == 1
+}
+
+// apiTokenHandler requires one of the tokens as `Authorization: Bearer`
+// on /api/ paths, which are then served by api without any login the UI
+// requires; other paths, and the public /api/ paths of basicAuthExempt,
+// go to ui
+func apiTokenHandler(api, ui http.Handler, tokens apiTokens, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || basicAuthExempt[r.URL.Path] {
+			ui.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !tokens.valid(token) {
+			challenge := fmt.Sprintf(`Bearer realm="%s API"`, authRealm)
+			if ok {
+				challenge += `, error="invalid_token"`
+				log.Printf("[WARN] Rejected an invalid API token for %s from %s", r.URL.Path, clientIP(r, trustProxy))
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
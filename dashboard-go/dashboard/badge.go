@@ -0,0 +1,148 @@
+package dashboard
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// badgeMaxAge is how long image caches may keep a badge
+const badgeMaxAge = time.Minute
+
+// Badge colors, matching the dashboard's status and SSL classes
+var badgeColors = map[string]string{
+	"status-success":      "#28a745",
+	"status-redirect":     "#ffc107",
+	"status-client-error": "#dc3545",
+	"status-server-error": "#dc3545",
+	"status-error":        "#6c757d",
+	"ssl-ok":              "#28a745",
+	"ssl-warning":         "#ffc107",
+	"ssl-critical":        "#dc3545",
+	"ssl-expired":         "#721c24",
+}
+
+// badgeUnknownColor is used for endpoints without data
+const badgeUnknownColor = "#9f9f9f"
+
+// badgeTemplate draws a flat shields-style badge, a grey label next to a
+// colored message
+var badgeTemplate = template.Must(template.New("badge").Funcs(template.FuncMap{
+	"xml": template.HTMLEscapeString,
+}).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{xml .Label}}: {{xml .Message}}">
+<title>{{xml .Label}}: {{xml .Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="14">{{xml .Label}}</text>
+<text x="{{.MessageX}}" y="14">{{xml .Message}}</text>
+</g>
+</svg>
+`))
+
+// badge is the data of badgeTemplate
+type badge struct {
+	Label, Message, Color    string
+	LabelWidth, MessageWidth int
+	Width, LabelX, MessageX  int
+}
+
+// newBadge lays out a badge, estimating text widths from the character count
+func newBadge(label, message, color string) badge {
+	b := badge{Label: label, Message: message, Color: color}
+	b.LabelWidth = badgeTextWidth(label)
+	b.MessageWidth = badgeTextWidth(message)
+	b.Width = b.LabelWidth + b.MessageWidth
+	b.LabelX = b.LabelWidth / 2
+	b.MessageX = b.LabelWidth + b.MessageWidth/2
+	return b
+}
+
+func badgeTextWidth(text string) int {
+	return 7*len([]rune(text)) + 10
+}
+
+// statusBadge labels an endpoint "up", "up 301" or "down 502"
+func statusBadge(data EndpointData) badge {
+	code := statusCode(data)
+	if code == nil {
+		return newBadge("status", "unknown", badgeUnknownColor)
+	}
+	var message string
+	switch {
+	case *code == -1:
+		// A DNS failure is colored like a failed connection
+		return newBadge("status", "down dns", badgeColors["status-error"])
+	case *code == 0:
+		message = "down"
+	case *code < 300:
+		message = "up"
+	case *code < 400:
+		message = "up " + strconv.Itoa(*code)
+	default:
+		message = "down " + strconv.Itoa(*code)
+	}
+	return newBadge("status", message, badgeColors[data.StatusClass])
+}
+
+// sslBadge labels an endpoint's certificate with its days left
+func sslBadge(data EndpointData) badge {
+	switch {
+	case data.CertInfo != nil && data.CertInfo.State == store.CertStateNotYetValid:
+		return newBadge("cert", "not yet valid", badgeColors["ssl-critical"])
+	case data.DaysLeft == nil:
+		return newBadge("cert", "unknown", badgeUnknownColor)
+	case data.SSLClass == "ssl-expired":
+		return newBadge("cert", "expired", badgeColors[data.SSLClass])
+	}
+	return newBadge("cert", fmt.Sprintf("%dd", *data.DaysLeft), badgeColors[data.SSLClass])
+}
+
+// handleBadge serves GET /badge?url=<endpoint>&kind=status|ssl, an SVG badge
+// for embedding in runbooks. Endpoints without data, and storage failures,
+// get a grey "unknown" badge so embedded images never break.
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	endpoint := strings.TrimSpace(query.Get("url"))
+	if endpoint == "" {
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+	kind := query.Get("kind")
+	if kind == "" {
+		kind = "status"
+	}
+	draw := map[string]func(EndpointData) badge{"status": statusBadge, "ssl": sslBadge}[kind]
+	if draw == nil {
+		http.Error(w, fmt.Sprintf("Invalid kind value %q (use status or ssl)", kind), http.StatusBadRequest)
+		return
+	}
+	endpoint = store.NormalizeEndpoint(endpoint)
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	maxAge := badgeMaxAge
+	stored, err := s.store.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		log.Printf("[ERROR] Failed to read endpoint %s for a badge: %v", endpoint, err)
+		stored = store.EndpointData{Endpoint: endpoint}
+		maxAge = 0
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds())))
+	if err := badgeTemplate.Execute(w, draw(newEndpointData(stored, time.Now().UTC()))); err != nil {
+		log.Printf("[ERROR] Failed to render badge: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"certs-n-status/store"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Reads are tried readAttempts times, waiting readBackoff after the first
+// failure and doubling it after each further one, before the dashboard falls
+// back to cached data
+const (
+	readAttempts = 3
+	readBackoff  = 100 * time.Millisecond
+)
+
+// endpointCache keeps the last endpoint data read successfully, so the
+// dashboard can keep serving it while storage is unavailable
+type endpointCache struct {
+	mu           sync.Mutex
+	data         []store.EndpointData
+	readAt       time.Time // zero until the first successful read
+	failingSince time.Time // zero while reads succeed
+}
+
+// assembledCacheKey is the singleflight key of assembling the endpoint data
+const assembledCacheKey = "endpoints"
+
+// assembledCache keeps the endpoint data getAllEndpointData assembled for
+// CACHE_TTL, so page views and API calls arriving together share one read
+type assembledCache struct {
+	mu      sync.Mutex
+	data    []EndpointData
+	expires time.Time
+	// generation is bumped by invalidate; an assembly that started before
+	// is not cached, as it may predate the change
+	generation uint64
+	flight     singleflight.Group
+}
+
+// assembled is a shared getAllEndpointData result
+type assembled struct {
+	data       []EndpointData
+	staleSince time.Time
+}
+
+// invalidateEndpointData drops the cached endpoint data after the dashboard
+// changed what it holds, so the next request reads storage again
+func (s *Server) invalidateEndpointData() {
+	s.assembled.mu.Lock()
+	defer s.assembled.mu.Unlock()
+	s.assembled.data = nil
+	s.assembled.generation++
+	s.assembled.flight.Forget(assembledCacheKey)
+}
+
+// getAllEndpointData returns assembleEndpointData, cached for CACHE_TTL.
+// Concurrent requests that miss the cache wait for one assembly instead of
+// each reading storage; it runs until the deadline of the request that
+// started it, even when that client goes away. Only fresh data is cached.
+// The slice is the caller's to reorder, not its elements to change.
+func (s *Server) getAllEndpointData(ctx context.Context) (endpointData []EndpointData, staleSince time.Time, err error) {
+	ttl := s.config.CacheTTL
+	if ttl <= 0 {
+		return s.assembleEndpointData(ctx)
+	}
+	cache := &s.assembled
+	cache.mu.Lock()
+	if cache.data != nil && time.Now().Before(cache.expires) {
+		data := slices.Clone(cache.data)
+		cache.mu.Unlock()
+		return data, time.Time{}, nil
+	}
+	generation := cache.generation
+	cache.mu.Unlock()
+
+	result, err, _ := cache.flight.Do(assembledCacheKey, func() (any, error) {
+		flightCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			flightCtx, cancel = context.WithDeadline(flightCtx, deadline)
+			defer cancel()
+		}
+		data, staleSince, err := s.assembleEndpointData(flightCtx)
+		if err != nil {
+			return nil, err
+		}
+		if staleSince.IsZero() {
+			cache.mu.Lock()
+			if cache.generation == generation {
+				cache.data, cache.expires = data, time.Now().Add(ttl)
+			}
+			cache.mu.Unlock()
+		}
+		return assembled{data, staleSince}, nil
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	shared := result.(assembled)
+	return slices.Clone(shared.data), shared.staleSince, nil
+}
+
+// assembleEndpointData returns every endpoint, in URL order, from the live
+// snapshot when it is in sync, and otherwise reads them in one bulk store
+// call. Neither keeps an order of its own, so the URL order gives every
+// view the same starting point for its sort and ties. When
+// storage stays unavailable, or does not answer before ctx is done, it
+// returns the last data read successfully together with the time storage
+// became unavailable; staleSince is zero for fresh data. Fresh data carries
+// the acknowledgements in effect, and marks endpoints the checker stopped
+// updating as Stale. It only fails when nothing has been read
+// yet.
+func (s *Server) assembleEndpointData(ctx context.Context) (endpointData []EndpointData, staleSince time.Time, err error) {
+	stored, live := s.live.endpointData()
+	if !live {
+		stored, err = s.readEndpointData(ctx)
+	}
+
+	s.cache.mu.Lock()
+	if err == nil {
+		s.cache.data = stored
+		s.cache.readAt = time.Now().UTC()
+		s.cache.failingSince = time.Time{}
+	} else {
+		if s.cache.failingSince.IsZero() {
+			s.cache.failingSince = time.Now().UTC()
+		}
+		if s.cache.readAt.IsZero() {
+			s.cache.mu.Unlock()
+			return nil, time.Time{}, err
+		}
+		log.Printf("[WARN] %s unavailable, serving data read at %s: %v",
+			storageName(s.store), s.cache.readAt.Format(time.RFC3339), err)
+		stored = s.cache.data
+		staleSince = s.cache.failingSince
+	}
+	s.cache.mu.Unlock()
+
+	var acks map[string]store.Ack
+	var pauses map[string]store.Pause
+	var heartbeat store.Heartbeat
+	if staleSince.IsZero() {
+		acks = s.readAcks(ctx)
+		pauses = s.readPauses(ctx)
+		heartbeat = s.readHeartbeat(ctx)
+	}
+	now := time.Now().UTC()
+	endpointData = make([]EndpointData, 0, len(stored))
+	for _, data := range stored {
+		ep := newEndpointData(data, now)
+		if ack, ok := acks[data.Endpoint]; ok {
+			ep.Ack = &ack
+		}
+		if pause, ok := pauses[data.Endpoint]; ok {
+			ep.Pause = &pause
+		}
+		ep.Stale = statusStale(ep, heartbeat, now)
+		endpointData = append(endpointData, ep)
+	}
+	slices.SortFunc(endpointData, func(a, b EndpointData) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return endpointData, staleSince, nil
+}
+
+// readEndpointData reads every endpoint, retrying with backoff so a single
+// transient error does not put the dashboard into stale mode. Retries stop
+// once ctx is done.
+func (s *Server) readEndpointData(ctx context.Context) ([]store.EndpointData, error) {
+	backoff := s.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.prepareRedis(ctx)
+		if err == nil {
+			var stored []store.EndpointData
+			if stored, err = s.store.ListEndpointData(ctx); err == nil {
+				return stored, nil
+			}
+		}
+		storeReadErrors.Add(1)
+		if attempt == readAttempts {
+			return nil, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// prepareRedis runs once Redis is reachable: it refuses data written by a
+// newer release, and seeds the endpoint registry from existing endpoint
+// hashes since data written before the registry existed is only found by
+// SCAN
+func (s *Server) prepareRedis(ctx context.Context) error {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok || s.redisPrepared.Load() {
+		return nil
+	}
+	if err := rs.CheckSchemaVersion(ctx); err != nil {
+		return err
+	}
+	if !s.config.ScanDiscovery {
+		seeded, err := rs.SeedEndpointRegistry(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to seed endpoint registry: %w", err)
+		}
+		if seeded > 0 {
+			log.Printf("[INFO] Seeded endpoint registry with %d endpoints", seeded)
+		}
+	}
+	s.redisPrepared.Store(true)
+	return nil
+}
+
+// storageName names the storage backend in stale data notices
+func storageName(st store.Store) string {
+	switch st.(type) {
+	case *store.RedisStore:
+		return "Redis"
+	case *store.PostgresStore:
+		return "PostgreSQL"
+	}
+	return "Storage"
+}
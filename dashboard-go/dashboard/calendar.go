@@ -0,0 +1,165 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultCalendarAlarmDays is how many days before a certificate expires
+// its calendar event reminds, unless CALENDAR_ALARM_DAYS says otherwise
+const defaultCalendarAlarmDays = 14
+
+// calendarEvent is one certificate expiry in /calendar.ics
+type calendarEvent struct {
+	UID         string
+	Endpoint    string
+	Host        string
+	NotAfter    time.Time
+	Description string
+}
+
+// calendarEvents returns an event for every HTTPS endpoint whose certificate
+// expires before now+within (0 for all, expired ones included), soonest first
+func calendarEvents(endpointData []EndpointData, within time.Duration, now time.Time) []calendarEvent {
+	var events []calendarEvent
+	for _, ep := range endpointData {
+		if !ep.IsHTTPS || ep.SSLExpiration == nil {
+			continue
+		}
+		notAfter := ep.SSLExpiration.UTC()
+		if within > 0 && !notAfter.Before(now.Add(within)) {
+			continue
+		}
+
+		host := ep.Endpoint
+		if u, err := url.Parse(ep.Endpoint); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+		description := fmt.Sprintf("The certificate of %s expires at %s.", ep.Endpoint, notAfter.Format(time.RFC3339))
+		// The serial tells a renewed certificate from the one it replaced;
+		// data written before certificate details were stored has none
+		serial := notAfter.Format(time.RFC3339)
+		if ep.CertInfo != nil {
+			if ep.CertInfo.Issuer != "" {
+				description += "\nIssuer: " + ep.CertInfo.Issuer
+			}
+			if ep.CertInfo.SerialNumber != "" {
+				serial = ep.CertInfo.SerialNumber
+				description += "\nSerial: " + serial
+			}
+		}
+
+		sum := sha256.Sum256([]byte(ep.Endpoint + "\n" + serial))
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("%x@certs-n-status", sum[:16]),
+			Endpoint:    ep.Endpoint,
+			Host:        host,
+			NotAfter:    notAfter,
+			Description: description,
+		})
+	}
+	slices.SortFunc(events, func(a, b calendarEvent) int {
+		if c := a.NotAfter.Compare(b.NotAfter); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Endpoint, b.Endpoint)
+	})
+	return events
+}
+
+// icsEscaper escapes TEXT property values (RFC 5545, section 3.3.11)
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsWriter writes content lines, folded at 75 octets and ended by CRLF
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (iw *icsWriter) line(name, value string) {
+	line := name + ":" + value
+	// Continuation lines start with the space that marks them
+	for limit := 75; len(line) > limit && iw.err == nil; limit = 74 {
+		// Fold between characters, never inside a UTF-8 sequence
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		_, iw.err = io.WriteString(iw.w, line[:cut]+"\r\n ")
+		line = line[cut:]
+	}
+	if iw.err == nil {
+		_, iw.err = io.WriteString(iw.w, line+"\r\n")
+	}
+}
+
+// writeCalendar writes the events as an iCalendar (RFC 5545) document of
+// all-day events on each expiry date, each with an alarm alarmDays before
+// (none when 0). now stamps the events.
+func writeCalendar(w io.Writer, events []calendarEvent, alarmDays int, now time.Time) error {
+	iw := &icsWriter{w: w}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//certs-n-status//dashboard//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	iw.line("METHOD", "PUBLISH")
+	iw.line("X-WR-CALNAME", "Certificate expirations")
+	for _, event := range events {
+		iw.line("BEGIN", "VEVENT")
+		iw.line("UID", event.UID)
+		iw.line("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+		iw.line("DTSTART;VALUE=DATE", event.NotAfter.Format("20060102"))
+		iw.line("DTEND;VALUE=DATE", event.NotAfter.AddDate(0, 0, 1).Format("20060102"))
+		iw.line("SUMMARY", icsEscaper.Replace("Cert expires: "+event.Host))
+		iw.line("DESCRIPTION", icsEscaper.Replace(event.Description))
+		iw.line("URL", event.Endpoint)
+		iw.line("TRANSP", "TRANSPARENT")
+		if alarmDays > 0 {
+			iw.line("BEGIN", "VALARM")
+			iw.line("ACTION", "DISPLAY")
+			iw.line("DESCRIPTION", icsEscaper.Replace(fmt.Sprintf("Cert of %s expires in %d days", event.Host, alarmDays)))
+			iw.line("TRIGGER", fmt.Sprintf("-P%dD", alarmDays))
+			iw.line("END", "VALARM")
+		}
+		iw.line("END", "VEVENT")
+	}
+	iw.line("END", "VCALENDAR")
+	return iw.err
+}
+
+// handleCalendar serves the certificate expiry dates of the HTTPS endpoints
+// as an iCalendar feed, optionally only those expiring within a duration
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	var within time.Duration
+	if value := r.URL.Query().Get("within"); value != "" {
+		d, err := parseDuration(value)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("Invalid within value %q (use e.g. 90d or 72h)", value), http.StatusBadRequest)
+			return
+		}
+		within = d
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	endpointData, _, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get endpoints", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to get endpoints for the calendar: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="certificates.ics"`)
+	if err := writeCalendar(w, calendarEvents(endpointData, within, now), s.config.CalendarAlarmDays, now); err != nil {
+		log.Printf("[ERROR] Failed to write calendar: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+)
+
+// ConfigKeys are the keys of the --config file the dashboard reads; the
+// checker section is left to the checker
+var ConfigKeys = slices.Concat(store.SharedConfigKeys, []store.ConfigKey{
+	{Path: "dashboard.server_port", Env: "SERVER_PORT"},
+	{Path: "dashboard.base_path", Env: "BASE_PATH"},
+	{Path: "dashboard.endpoint_discovery", Env: "ENDPOINT_DISCOVERY"},
+	{Path: "dashboard.cache_ttl", Env: "CACHE_TTL"},
+	{Path: "dashboard.keyspace_events", Env: "REDIS_KEYSPACE_EVENTS"},
+	{Path: "dashboard.display_timezone", Env: "DISPLAY_TIMEZONE"},
+	{Path: "dashboard.auto_refresh_seconds", Env: "AUTO_REFRESH_SECONDS"},
+	{Path: "dashboard.views_file", Env: "VIEWS_FILE"},
+	{Path: "dashboard.template_dir", Env: "TEMPLATE_DIR"},
+	{Path: "dashboard.template_reload", Env: "TEMPLATE_RELOAD"},
+	{Path: "dashboard.allow_write", Env: "ALLOW_WRITE"},
+	{Path: "dashboard.debug_endpoints", Env: "DEBUG_ENDPOINTS"},
+	{Path: "dashboard.calendar_alarm_days", Env: "CALENDAR_ALARM_DAYS"},
+	{Path: "dashboard.log.level", Env: "LOG_LEVEL"},
+	{Path: "dashboard.metrics.label", Env: "METRICS_LABEL"},
+	{Path: "dashboard.metrics.stale_after", Env: "METRICS_STALE_AFTER"},
+	{Path: "dashboard.auth.username", Env: "DASHBOARD_USERNAME"},
+	{Path: "dashboard.auth.password", Env: "DASHBOARD_PASSWORD", Secret: true},
+	{Path: "dashboard.auth.htpasswd_file", Env: "DASHBOARD_HTPASSWD_FILE"},
+	{Path: "dashboard.auth.api_tokens", Env: "API_TOKENS", Secret: true},
+	{Path: "dashboard.auth.api_tokens_file", Env: "API_TOKENS_FILE"},
+	{Path: "dashboard.websocket.allowed_origins", Env: "WS_ALLOWED_ORIGINS"},
+	{Path: "dashboard.websocket.token", Env: "WS_TOKEN", Secret: true},
+	{Path: "dashboard.rate_limit.rps", Env: "RATE_LIMIT_RPS"},
+	{Path: "dashboard.rate_limit.burst", Env: "RATE_LIMIT_BURST"},
+	{Path: "dashboard.rate_limit.trust_proxy", Env: "TRUST_PROXY"},
+	{Path: "dashboard.http.read_header_timeout", Env: "HTTP_READ_HEADER_TIMEOUT"},
+	{Path: "dashboard.http.read_timeout", Env: "HTTP_READ_TIMEOUT"},
+	{Path: "dashboard.http.write_timeout", Env: "HTTP_WRITE_TIMEOUT"},
+	{Path: "dashboard.http.idle_timeout", Env: "HTTP_IDLE_TIMEOUT"},
+	{Path: "dashboard.http.shutdown_timeout", Env: "SHUTDOWN_TIMEOUT"},
+	{Path: "dashboard.tls.cert_file", Env: "TLS_CERT_FILE"},
+	{Path: "dashboard.tls.key_file", Env: "TLS_KEY_FILE"},
+	{Path: "dashboard.tls.redirect_http_port", Env: "REDIRECT_HTTP_PORT"},
+}, logging.ConfigKeys("dashboard"))
+
+// validate returns every setting of config that parsed but cannot work.
+// Invalid values of single variables are reported when they are read, and
+// settings that depend on files or each other by NewServer.
+func (config Config) validate() []error {
+	var problems []error
+	switch config.Storage {
+	case "redis", "memory":
+	case "postgres":
+		if config.DatabaseURL == "" {
+			problems = append(problems, errors.New("STORAGE=postgres requires DATABASE_URL"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown STORAGE %q (use redis, postgres or memory)", config.Storage))
+	}
+	if !validPort(config.ServerPort) {
+		problems = append(problems, fmt.Errorf("invalid SERVER_PORT %q (use a port number such as 8080)", config.ServerPort))
+	}
+	if config.RedirectHTTPPort != "" && !validPort(config.RedirectHTTPPort) {
+		problems = append(problems, fmt.Errorf("invalid REDIRECT_HTTP_PORT %q (use a port number such as 80)", config.RedirectHTTPPort))
+	}
+	return problems
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
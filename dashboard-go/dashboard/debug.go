@@ -0,0 +1,36 @@
+package dashboard
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Counters published on /debug/vars with DEBUG_ENDPOINTS, besides the
+// memstats and cmdline expvar publishes itself
+var (
+	requestsServed    = expvar.NewInt("requests_served")       // every request answered, probes included
+	storeReadErrors   = expvar.NewInt("store_read_errors")     // failed attempts of the bulk endpoint read
+	pageRenders       = expvar.NewInt("page_renders")          // HTML pages rendered from the templates
+	pageRenderSeconds = expvar.NewFloat("page_render_seconds") // time spent rendering them
+)
+
+// countRender adds a page render that started at start to the expvar counters
+func countRender(start time.Time) {
+	pageRenders.Add(1)
+	pageRenderSeconds.Add(time.Since(start).Seconds())
+}
+
+// addDebugRoutes mounts the pprof profiles under /debug/pprof/ and the
+// expvar variables at /debug/vars. They are only added with
+// DEBUG_ENDPOINTS, which requires the dashboard login.
+func addDebugRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+}
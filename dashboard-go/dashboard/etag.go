@@ -0,0 +1,52 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// writeJSONWithETag writes v as JSON with a strong ETag hashed from the
+// encoded body, answering a request whose If-None-Match lists it with a
+// bodyless 304. Pollers mostly get unchanged data, so this saves the
+// transfer though not the work of building the response. Only 200
+// responses are tagged.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		log.Printf("[ERROR] Failed to encode response: %v", err)
+		return
+	}
+
+	// Caches must revalidate rather than serve a stored copy
+	w.Header().Set("Cache-Control", "no-cache")
+	if status == http.StatusOK {
+		sum := sha256.Sum256(body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// etagMatches evaluates an If-None-Match header against etag with the weak
+// comparison RFC 9110 prescribes for it
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// Bounds of /feed.atom: at most feedMaxEntries entries, found among the
+// newest feedMaxScan stream events, read feedPageSize at a time
+const (
+	feedMaxEntries = 100
+	feedMaxScan    = store.DefaultEventStreamMaxLen
+	feedPageSize   = 500
+)
+
+// atomFeed is an Atom 1.0 (RFC 4287) feed document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Link     atomLink     `xml:"link"`
+	Category atomCategory `xml:"category"`
+	Summary  string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// feedEventTitle describes the events /feed.atom publishes: an endpoint
+// going down or recovering, a certificate entering the warning window and a
+// certificate expiring. Other events, such as renewals, and events of
+// acknowledged endpoints or during maintenance are not notable.
+func feedEventTitle(event store.Event) (string, bool) {
+	if event.Acknowledged || event.InMaintenance {
+		return "", false
+	}
+	switch event.Kind {
+	case store.EventKindStatus:
+		if event.New == store.StatusDown {
+			return event.Endpoint + " is down", true
+		}
+		return event.Endpoint + " recovered", true
+	case store.EventKindCert:
+		switch {
+		case event.New == store.CertLevelExpired:
+			return "Certificate of " + event.Endpoint + " expired", true
+		case event.Old == store.CertLevelOK && event.New == store.CertLevelWarning:
+			return fmt.Sprintf("Certificate of %s expires within %d days", event.Endpoint, int(store.CertWarningWindow.Hours()/24)), true
+		case event.Old == store.CertLevelOK && event.New == store.CertLevelCritical:
+			return fmt.Sprintf("Certificate of %s expires within %d days", event.Endpoint, int(store.CertCriticalWindow.Hours()/24)), true
+		}
+	}
+	return "", false
+}
+
+// feedID is the IRI of the feed or, with a stream ID, of one of its
+// entries. Stream IDs never change, so readers recognize entries they have
+// already seen; the key prefix keeps environments sharing a Redis apart.
+func feedID(keyPrefix, streamID string) string {
+	if streamID == "" {
+		return fmt.Sprintf("urn:certs-n-status:%sfeed", url.PathEscape(keyPrefix))
+	}
+	return fmt.Sprintf("urn:certs-n-status:%sevent:%s", url.PathEscape(keyPrefix), streamID)
+}
+
+// newFeed builds the feed of notable events, given newest first. The feed
+// is as recent as its newest entry, or now when it has none.
+func newFeed(events []store.StreamEvent, keyPrefix, self string, now time.Time) atomFeed {
+	feed := atomFeed{
+		ID:      feedID(keyPrefix, ""),
+		Title:   "Certs-n-Status events",
+		Updated: now.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "certs-n-status"},
+		Links:   []atomLink{{Rel: "self", Href: self}},
+		Entries: []atomEntry{},
+	}
+	for _, event := range events {
+		title, ok := feedEventTitle(event.Event)
+		if !ok {
+			continue
+		}
+		at := event.At.UTC().Format(time.RFC3339)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       feedID(keyPrefix, event.ID),
+			Title:    title,
+			Updated:  at,
+			Link:     atomLink{Rel: "related", Href: event.Endpoint},
+			Category: atomCategory{Term: event.Kind},
+			Summary:  fmt.Sprintf("%s %s changed from %s to %s at %s", event.Endpoint, event.Kind, event.Old, event.New, at),
+		})
+	}
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+	return feed
+}
+
+// recentNotableEvents walks back through the event stream until it has
+// limit notable events or has read feedMaxScan events
+func recentNotableEvents(ctx context.Context, rs *store.RedisStore, limit int) ([]store.StreamEvent, error) {
+	var notable []store.StreamEvent
+	before := ""
+	for scanned := 0; scanned < feedMaxScan; scanned += feedPageSize {
+		batch, err := rs.RecentEvents(ctx, before, feedPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range batch {
+			if _, ok := feedEventTitle(event.Event); ok && len(notable) < limit {
+				notable = append(notable, event)
+			}
+		}
+		if len(batch) < feedPageSize || len(notable) == limit {
+			break
+		}
+		before = batch[len(batch)-1].ID
+	}
+	return notable, nil
+}
+
+// handleFeed serves the most recent notable events as an Atom feed
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "The feed requires Redis storage", http.StatusNotImplemented)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	events, err := recentNotableEvents(ctx, rs, feedMaxEntries)
+	if err != nil {
+		http.Error(w, "Failed to get events", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read event stream: %v", err)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	self := scheme + "://" + r.Host + s.config.BasePath + "/feed.atom"
+	feed := newFeed(events, s.config.KeyPrefix, self, time.Now())
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if err := writeFeed(w, feed); err != nil {
+		log.Printf("[ERROR] Failed to write feed: %v", err)
+	}
+}
+
+// writeFeed writes the feed as an indented XML document
+func writeFeed(w io.Writer, feed atomFeed) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,119 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// endpointFields are the field names the fields parameter of /api/endpoints
+// accepts, mapped to the EndpointData values they select
+var endpointFields = map[string]func(EndpointData) any{
+	"endpoint":           func(e EndpointData) any { return e.Endpoint },
+	"status_code":        func(e EndpointData) any { return e.StatusCode },
+	"status_text":        func(e EndpointData) any { return e.StatusText },
+	"status_class":       func(e EndpointData) any { return e.StatusClass },
+	"alert_state":        func(e EndpointData) any { return e.AlertState },
+	"ssl_expiration":     func(e EndpointData) any { return e.SSLExpiration },
+	"days_left":          func(e EndpointData) any { return e.DaysLeft },
+	"cert_info":          func(e EndpointData) any { return e.CertInfo },
+	"ssl_text":           func(e EndpointData) any { return e.SSLText },
+	"ssl_class":          func(e EndpointData) any { return e.SSLClass },
+	"last_status_update": func(e EndpointData) any { return e.LastStatusUpdate },
+	"last_ssl_update":    func(e EndpointData) any { return e.LastSSLUpdate },
+	"header_audit":       func(e EndpointData) any { return e.HeaderAudit },
+	"captured":           func(e EndpointData) any { return e.Captured },
+	"error":              func(e EndpointData) any { return e.Error },
+	"error_text":         func(e EndpointData) any { return e.ErrorText },
+	"uptime":             func(e EndpointData) any { return e.Uptime },
+	"tags":               func(e EndpointData) any { return e.Tags },
+	"name":               func(e EndpointData) any { return e.Name },
+	"description":        func(e EndpointData) any { return e.Description },
+	"remote_addr":        func(e EndpointData) any { return e.RemoteAddr },
+	"checked_by":         func(e EndpointData) any { return e.CheckedBy },
+	"ssl_checked_by":     func(e EndpointData) any { return e.SSLCheckedBy },
+	"ack":                func(e EndpointData) any { return e.Ack },
+	"in_maintenance":     func(e EndpointData) any { return e.InMaintenance },
+	"stale":              func(e EndpointData) any { return e.Stale },
+	"paused_by_schedule": func(e EndpointData) any { return e.PausedBySchedule },
+	"pause":              func(e EndpointData) any { return e.Pause },
+	"update_text":        func(e EndpointData) any { return e.UpdateText },
+	"is_https":           func(e EndpointData) any { return e.IsHTTPS },
+}
+
+// apiEndpointFields does the same for /api/v1/endpoints, using its JSON
+// names. Absent values select as null.
+var apiEndpointFields = map[string]func(APIEndpoint) any{
+	"endpoint":           func(e APIEndpoint) any { return e.Endpoint },
+	"name":               func(e APIEndpoint) any { return optional(e.Name) },
+	"description":        func(e APIEndpoint) any { return optional(e.Description) },
+	"https":              func(e APIEndpoint) any { return e.HTTPS },
+	"status_code":        func(e APIEndpoint) any { return e.StatusCode },
+	"status_updated_at":  func(e APIEndpoint) any { return optional(e.StatusUpdatedAt) },
+	"remote_addr":        func(e APIEndpoint) any { return optional(e.RemoteAddr) },
+	"checked_by":         func(e APIEndpoint) any { return optional(e.CheckedBy) },
+	"alert_state":        func(e APIEndpoint) any { return optional(e.AlertState) },
+	"ssl_expiration":     func(e APIEndpoint) any { return optional(e.SSLExpiration) },
+	"days_left":          func(e APIEndpoint) any { return e.DaysLeft },
+	"ssl_updated_at":     func(e APIEndpoint) any { return optional(e.SSLUpdatedAt) },
+	"ssl_checked_by":     func(e APIEndpoint) any { return optional(e.SSLCheckedBy) },
+	"certificate":        func(e APIEndpoint) any { return e.Certificate },
+	"header_audit":       func(e APIEndpoint) any { return e.HeaderAudit },
+	"captured_headers":   func(e APIEndpoint) any { return e.CapturedHeaders },
+	"error_class":        func(e APIEndpoint) any { return optional(e.ErrorClass) },
+	"error_message":      func(e APIEndpoint) any { return optional(e.ErrorMessage) },
+	"error_at":           func(e APIEndpoint) any { return optional(e.ErrorAt) },
+	"uptime":             func(e APIEndpoint) any { return e.Uptime },
+	"tags":               func(e APIEndpoint) any { return e.Tags },
+	"acknowledgement":    func(e APIEndpoint) any { return e.Acknowledgement },
+	"in_maintenance":     func(e APIEndpoint) any { return e.InMaintenance },
+	"stale":              func(e APIEndpoint) any { return e.Stale },
+	"paused_by_schedule": func(e APIEndpoint) any { return e.PausedBySchedule },
+	"pause":              func(e APIEndpoint) any { return e.Pause },
+}
+
+// selectFields reduces each item to the comma-separated fields of the
+// fields query parameter. It returns nil when the
+// parameter is not set, and an error naming the valid fields when it lists
+// one missing from whitelist.
+func selectFields[T any](query url.Values, whitelist map[string]func(T) any, items []T) ([]map[string]any, error) {
+	value := query.Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := whitelist[name]; !ok {
+			valid := make([]string, 0, len(whitelist))
+			for name := range whitelist {
+				valid = append(valid, name)
+			}
+			slices.Sort(valid)
+			return nil, fmt.Errorf("unknown field %q (valid fields: %s)", name, strings.Join(valid, ", "))
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	selected := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		fields := make(map[string]any, len(names))
+		for _, name := range names {
+			fields[name] = whitelist[name](item)
+		}
+		selected = append(selected, fields)
+	}
+	return selected, nil
+}
+
+// optional turns the empty string the API types use for absent values into null
+func optional(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,178 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// Values accepted by the status and ssl filters. Several can be given
+// separated by commas, matching any of them.
+var (
+	statusFilters = []string{"ok", "error", "4xx", "5xx"}
+	sslFilters    = []string{"ok", "warning", "critical", "expired"}
+)
+
+// endpointFilterParams are the query parameters read by filterEndpoints
+var endpointFilterParams = []string{"q", "status", "ssl", "https_only", "updated_before", "tag"}
+
+// endpointFilter is the parsed form of the endpoint list query parameters
+type endpointFilter struct {
+	search        string // lowercased substring of the endpoint URL or display name
+	statuses      []string
+	ssl           []string
+	httpsOnly     bool
+	updatedBefore time.Time // zero for no limit
+	tags          []string  // lowercased
+}
+
+// filterEndpoints returns the endpoints matching every filter in query:
+//
+//	status=ok|error|4xx|5xx              last status check; ok is 2xx or 3xx,
+//	                                     error a DNS or connection failure
+//	ssl=ok|warning|critical|expired      certificate state, as colored on the dashboard
+//	https_only=true                      HTTPS endpoints only
+//	updated_before=<duration>            not checked within the duration, e.g. 1h or 2d
+//	q=<text>                             endpoint URL contains the text, ignoring case
+//	tag=<tag>                            endpoint has the tag of the endpoints file
+//
+// Unknown parameters are ignored; invalid values are reported as an error
+// meant for the client.
+func filterEndpoints(query url.Values, endpoints []EndpointData, now time.Time) ([]EndpointData, error) {
+	filter, err := parseEndpointFilter(query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]EndpointData, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if filter.match(endpoint) {
+			matched = append(matched, endpoint)
+		}
+	}
+	return matched, nil
+}
+
+// hasEndpointFilter reports whether query sets any filterEndpoints parameter
+func hasEndpointFilter(query url.Values) bool {
+	for _, name := range endpointFilterParams {
+		if query.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEndpointFilter(query url.Values, now time.Time) (endpointFilter, error) {
+	filter := endpointFilter{search: strings.ToLower(strings.TrimSpace(query.Get("q")))}
+	var err error
+	if filter.statuses, err = parseChoices(query, "status", statusFilters); err != nil {
+		return filter, err
+	}
+	if filter.ssl, err = parseChoices(query, "ssl", sslFilters); err != nil {
+		return filter, err
+	}
+	if value := query.Get("https_only"); value != "" {
+		if filter.httpsOnly, err = strconv.ParseBool(value); err != nil {
+			return filter, fmt.Errorf("invalid https_only value %q (use true or false)", value)
+		}
+	}
+	if value := query.Get("updated_before"); value != "" {
+		d, err := parseDuration(value)
+		if err != nil || d < 0 {
+			return filter, fmt.Errorf("invalid updated_before value %q (use a duration such as 1h or 2d)", value)
+		}
+		filter.updatedBefore = now.Add(-d)
+	}
+	if value := query.Get("tag"); value != "" {
+		for _, tag := range strings.Split(value, ",") {
+			filter.tags = append(filter.tags, strings.ToLower(strings.TrimSpace(tag)))
+		}
+	}
+	return filter, nil
+}
+
+// parseChoices splits the comma-separated values of a query parameter,
+// rejecting any not in valid
+func parseChoices(query url.Values, name string, valid []string) ([]string, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	var choices []string
+	for _, choice := range strings.Split(value, ",") {
+		choice = strings.ToLower(strings.TrimSpace(choice))
+		if !slices.Contains(valid, choice) {
+			return nil, fmt.Errorf("invalid %s value %q (use %s, or several separated by commas)", name, choice, strings.Join(valid, ", "))
+		}
+		choices = append(choices, choice)
+	}
+	return choices, nil
+}
+
+// matchSearch matches the search term against the URL, in its stored and
+// displayed forms for internationalized host names, and the display name
+func (f endpointFilter) matchSearch(endpoint EndpointData) bool {
+	for _, text := range []string{endpoint.Endpoint, store.DisplayEndpoint(endpoint.Endpoint), endpoint.Name} {
+		if strings.Contains(strings.ToLower(text), f.search) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f endpointFilter) match(endpoint EndpointData) bool {
+	if f.search != "" && !f.matchSearch(endpoint) {
+		return false
+	}
+	if f.httpsOnly && !endpoint.IsHTTPS {
+		return false
+	}
+	if f.tags != nil && !slices.ContainsFunc(f.tags, func(tag string) bool { return slices.Contains(endpoint.Tags, tag) }) {
+		return false
+	}
+	if f.statuses != nil && !slices.Contains(f.statuses, statusCategory(endpoint)) {
+		return false
+	}
+	if f.ssl != nil && !slices.Contains(f.ssl, strings.TrimPrefix(endpoint.SSLClass, "ssl-")) {
+		return false
+	}
+	if !f.updatedBefore.IsZero() {
+		// Endpoints never checked count as not updated
+		if updated := lastUpdate(endpoint); updated != nil && !updated.Before(f.updatedBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// statusCategory returns the status filter value an endpoint matches, or ""
+// before its first status check
+func statusCategory(endpoint EndpointData) string {
+	if endpoint.StatusText == "" {
+		return ""
+	}
+	switch code := endpoint.StatusCode; {
+	case code <= 0:
+		return "error"
+	case code >= 500:
+		return "5xx"
+	case code >= 400:
+		return "4xx"
+	}
+	return "ok"
+}
+
+// lastUpdate returns the time of the latest status or SSL check
+func lastUpdate(endpoint EndpointData) *time.Time {
+	last := endpoint.LastStatusUpdate
+	if endpoint.LastSSLUpdate != nil && (last == nil || endpoint.LastSSLUpdate.After(*last)) {
+		last = endpoint.LastSSLUpdate
+	}
+	return last
+}
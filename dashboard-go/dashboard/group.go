@@ -0,0 +1,115 @@
+package dashboard
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Values of the group_by parameter of the dashboard and /api/summary
+var groupByValues = []string{"none", "tag", "domain"}
+
+// untaggedGroup holds the endpoints without tags when grouping by tag
+const untaggedGroup = "untagged"
+
+// EndpointGroup is a heading of the dashboard table and the endpoints under
+// it, counted like the whole table
+type EndpointGroup struct {
+	Name      string // "" when the table is not grouped
+	Endpoints []EndpointData
+	Summary   Summary
+}
+
+// multiLabelSuffixes are common public suffixes of more than one label, so
+// that shop.example.co.uk groups under example.co.uk. Without the full
+// public suffix list, hosts under other such suffixes group one level up.
+var multiLabelSuffixes = map[string]bool{
+	"co.uk": true, "org.uk": true, "ac.uk": true, "gov.uk": true,
+	"com.au": true, "net.au": true, "org.au": true,
+	"co.nz": true, "co.jp": true, "co.in": true, "co.za": true,
+	"com.br": true, "com.cn": true, "com.mx": true, "com.tr": true,
+}
+
+// parseGroupBy reads the group_by parameter, "none" when it is not set
+func parseGroupBy(query url.Values) (string, error) {
+	groupBy := strings.ToLower(strings.TrimSpace(query.Get("group_by")))
+	if groupBy == "" {
+		return "none", nil
+	}
+	if !slices.Contains(groupByValues, groupBy) {
+		return "", fmt.Errorf("invalid group_by value %q (use %s)", groupBy, strings.Join(groupByValues, ", "))
+	}
+	return groupBy, nil
+}
+
+// groupEndpoints splits endpoints by tag or by registrable domain, keeping
+// their order within each group. Groups are sorted by name, with untagged
+// endpoints last; an endpoint with several tags is listed under each. With
+// groupBy "none" all endpoints form one unnamed group, and no endpoints no
+// group at all.
+func groupEndpoints(endpoints []EndpointData, groupBy string, now time.Time) []EndpointGroup {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	if groupBy == "none" {
+		return []EndpointGroup{{Endpoints: endpoints, Summary: summarizeEndpoints(endpoints, now)}}
+	}
+
+	byName := make(map[string][]EndpointData)
+	for _, endpoint := range endpoints {
+		for _, name := range groupNames(endpoint, groupBy) {
+			byName[name] = append(byName[name], endpoint)
+		}
+	}
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if i := slices.Index(names, untaggedGroup); i >= 0 && groupBy == "tag" {
+		names = append(slices.Delete(names, i, i+1), untaggedGroup)
+	}
+
+	groups := make([]EndpointGroup, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, EndpointGroup{Name: name, Endpoints: byName[name], Summary: summarizeEndpoints(byName[name], now)})
+	}
+	return groups
+}
+
+// groupNames returns the groups endpoint is listed under
+func groupNames(endpoint EndpointData, groupBy string) []string {
+	if groupBy == "domain" {
+		return []string{registrableDomain(endpoint.Endpoint)}
+	}
+	if len(endpoint.Tags) == 0 {
+		return []string{untaggedGroup}
+	}
+	return endpoint.Tags
+}
+
+// registrableDomain returns the domain an endpoint's host was registered
+// under, e.g. example.com for https://api.eu.example.com:8443/health. IP
+// addresses and single-label hosts such as localhost are returned as is.
+func registrableDomain(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Hostname() == "" {
+		return endpoint
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	labels := strings.Split(host, ".")
+	n := 2
+	if len(labels) > 2 && multiLabelSuffixes[strings.Join(labels[len(labels)-2:], ".")] {
+		n = 3
+	}
+	if len(labels) <= n {
+		return host
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
@@ -0,0 +1,48 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"certs-n-status/store/version"
+)
+
+// readyTimeout bounds the storage ping of /readyz, well below the usual
+// probe timeout of one second
+const readyTimeout = 500 * time.Millisecond
+
+// handleHealthz serves GET /healthz, answering as long as the process serves
+// requests. Like /readyz its requests are only logged with LOG_LEVEL=debug,
+// so probes do not flood the access log.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// handleAPIVersion serves GET /api/version, the build information of the
+// running dashboard
+func (s *Server) handleAPIVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
+
+// handleReadyz serves GET /readyz: 200 when storage answers a ping within
+// readyTimeout, and 503 with the failure otherwise
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	response := map[string]string{"status": "ready", "storage": "ok"}
+	if err := s.store.Ping(ctx); err != nil {
+		status = http.StatusServiceUnavailable
+		response["status"] = "unavailable"
+		response["storage"] = storageName(s.store) + ": " + err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,68 @@
+package dashboard
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// readHeartbeat returns the checker's last heartbeat, or the zero
+// Heartbeat when it cannot be read, in which case nothing shows as stale
+func (s *Server) readHeartbeat(ctx context.Context) store.Heartbeat {
+	heartbeat, err := s.store.Heartbeat(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read the checker heartbeat: %v", err)
+		return store.Heartbeat{}
+	}
+	return heartbeat
+}
+
+// readCheckers returns the checker instances whose last heartbeat is
+// within heartbeat's StaleAfter of now, or store.CheckerRetention before
+// the checkers report their interval, the most recent first. When they
+// cannot be read there are none.
+func (s *Server) readCheckers(ctx context.Context, heartbeat store.Heartbeat, now time.Time) []SummaryChecker {
+	activeFor := heartbeat.StaleAfter()
+	if activeFor <= 0 {
+		activeFor = store.CheckerRetention
+	}
+	checkers, err := s.store.Checkers(ctx, now.Add(-activeFor))
+	if err != nil {
+		log.Printf("[WARN] Failed to read the checker instances: %v", err)
+		return nil
+	}
+	var active []SummaryChecker
+	for _, checker := range checkers {
+		active = append(active, SummaryChecker{ID: checker.ID, LastHeartbeat: checker.At.UTC()})
+	}
+	return active
+}
+
+// statusStale reports whether the last status check of ep is older than
+// the heartbeat's StaleAfter, i.e. the checker stopped checking it, unless
+// the endpoint's schedule or a pause stopped its checks
+func statusStale(ep EndpointData, heartbeat store.Heartbeat, now time.Time) bool {
+	staleAfter := heartbeat.StaleAfter()
+	return staleAfter > 0 && !ep.PausedBySchedule && ep.Pause == nil && ep.LastStatusUpdate != nil && now.Sub(*ep.LastStatusUpdate) > staleAfter
+}
+
+// checkerNotice returns the banner shown when the checker has not finished
+// a cycle for StaleAfter, e.g. "Checker last seen 2h ago", or ""
+func checkerNotice(heartbeat store.Heartbeat, now time.Time) string {
+	if !heartbeat.Stale(now) {
+		return ""
+	}
+	return "Checker last seen " + formatTimeAgo(&heartbeat.At)
+}
+
+// checkerLastSeen returns the time of heartbeat for /api/summary, or nil
+// when there is none
+func checkerLastSeen(heartbeat store.Heartbeat) *time.Time {
+	if heartbeat.At.IsZero() {
+		return nil
+	}
+	at := heartbeat.At.UTC()
+	return &at
+}
@@ -0,0 +1,119 @@
+package dashboard
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"certs-n-status/store"
+)
+
+// liveSnapshot is an always-current copy of every endpoint's stored data,
+// kept up to date by Redis keyspace notifications when REDIS_KEYSPACE_EVENTS
+// is set, so pages and API calls are served from memory. While it is not in
+// sync, after a lost subscription or a failed read, reads go to Redis.
+type liveSnapshot struct {
+	server *Server
+	mu     sync.RWMutex
+	synced bool
+	data   map[string]store.EndpointData
+}
+
+// startLiveRefresh starts the live snapshot, unless REDIS_KEYSPACE_EVENTS is
+// off or the server does not publish the notifications it needs
+func (s *Server) startLiveRefresh(ctx context.Context) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !s.config.KeyspaceEvents || !ok {
+		return
+	}
+
+	ctx, cancel := withStoreTimeout(context.Background(), s.config.StoreTimeout)
+	enabled, err := rs.KeyspaceEventsEnabled(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("[WARN] Cannot read notify-keyspace-events, assuming it includes %s: %v", store.KeyspaceEventFlags, err)
+	} else if !enabled {
+		log.Printf("[WARN] Redis keyspace notifications are disabled (set notify-keyspace-events to %s), reading endpoints on every request", store.KeyspaceEventFlags)
+		return
+	}
+
+	s.live = &liveSnapshot{server: s}
+	go rs.WatchEndpoints(ctx, s.live)
+	log.Printf("[INFO] Serving endpoints from a snapshot kept current by Redis keyspace notifications")
+}
+
+// endpointData returns every endpoint when the snapshot is in sync
+func (l *liveSnapshot) endpointData() ([]store.EndpointData, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if !l.synced {
+		return nil, false
+	}
+	data := make([]store.EndpointData, 0, len(l.data))
+	for _, endpoint := range l.data {
+		data = append(data, endpoint)
+	}
+	return data, true
+}
+
+// Resync rebuilds the snapshot with a full read
+func (l *liveSnapshot) Resync() {
+	ctx, cancel := withStoreTimeout(context.Background(), l.server.config.StoreTimeout)
+	defer cancel()
+	stored, err := l.server.readEndpointData(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.synced = false
+		log.Printf("[WARN] Failed to rebuild the endpoint snapshot, reading endpoints on every request: %v", err)
+		return
+	}
+	l.data = make(map[string]store.EndpointData, len(stored))
+	for _, data := range stored {
+		l.data[data.Endpoint] = data
+	}
+	l.synced = true
+}
+
+// Changed rereads one endpoint, dropping it when its hash is gone
+func (l *liveSnapshot) Changed(endpoint string) {
+	l.mu.RLock()
+	synced := l.synced
+	l.mu.RUnlock()
+	if !synced {
+		// An earlier failed read left the snapshot behind
+		l.Resync()
+		return
+	}
+
+	ctx, cancel := withStoreTimeout(context.Background(), l.server.config.StoreTimeout)
+	defer cancel()
+	data, err := l.server.store.GetEndpointData(ctx, endpoint)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.synced = false
+		log.Printf("[WARN] Failed to read changed endpoint %s, reading endpoints on every request: %v", endpoint, err)
+		return
+	}
+	if !data.HasStatus && data.SSLUpdated.IsZero() && data.HeaderAudit == nil {
+		delete(l.data, endpoint)
+		return
+	}
+	l.data[endpoint] = data
+}
+
+// Lost stops serving from the snapshot until the subscription is back
+func (l *liveSnapshot) Lost(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.synced {
+		log.Printf("[WARN] Lost Redis keyspace notifications, reading endpoints on every request until resubscribed: %v", err)
+	}
+	l.synced = false
+}
@@ -0,0 +1,157 @@
+package dashboard
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"certs-n-status/store/logging"
+)
+
+// quietPaths are polled by probes; their requests are only logged with
+// LOG_LEVEL=debug
+var quietPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// accessLogEntry is one access log line
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	Level      string  `json:"level"`
+	Msg        string  `json:"msg"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	Remote     string  `json:"remote"`
+	RequestID  string  `json:"request_id"`
+}
+
+// requestIDHeader carries the ID of a request, from the client or generated
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID accepts client IDs that are safe to log and echo: up to
+// 128 letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessLogHandler logs every request once it is answered, with the
+// request ID it returns in X-Request-ID. Requests to the quietPaths under
+// basePath are only logged when debug is set.
+func accessLogHandler(h http.Handler, format string, debug, trustProxy bool, basePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		requestsServed.Add(1)
+
+		level := "INFO"
+		if path, ok := strings.CutPrefix(r.URL.Path, basePath); ok && quietPaths[path] {
+			if !debug {
+				return
+			}
+			level = "DEBUG"
+		}
+		entry := accessLogEntry{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			Level:      strings.ToLower(level),
+			Msg:        "request",
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rec.status(),
+			Bytes:      rec.bytes,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Remote:     clientIP(r, trustProxy),
+			RequestID:  id,
+		}
+		if format == logging.FormatJSON {
+			line, _ := json.Marshal(entry)
+			log.Print(string(line))
+			return
+		}
+		log.Printf("[%s] %s %s %d %dB %.1fms from %s request_id=%s",
+			level, entry.Method, entry.Path, entry.Status, entry.Bytes, entry.DurationMs, entry.Remote, entry.RequestID)
+	})
+}
+
+// statusRecorder notes the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// status is the code sent, 200 when the handler wrote nothing
+func (r *statusRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands over the connection of a WebSocket upgrade, which is logged
+// as 101 Switching Protocols
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer cannot be hijacked")
+	}
+	r.code = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
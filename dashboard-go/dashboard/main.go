@@ -0,0 +1,1303 @@
+// Package dashboard serves the web dashboard and the API of the results
+// the checker saves to the store. It is the dashboard command, run by Main,
+// and the dashboard half of the combined certs-n-status binary.
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+	"certs-n-status/store/sdnotify"
+	"certs-n-status/store/version"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultAutoRefresh is how often the page reloads itself without
+// AUTO_REFRESH_SECONDS, in seconds
+const defaultAutoRefresh = 60
+
+// defaultCacheTTL is how long assembled endpoint data is reused without
+// CACHE_TTL, short enough to go unnoticed by a wall of open dashboards
+const defaultCacheTTL = 5 * time.Second
+
+type Config struct {
+	RedisAddr         string
+	RedisUsername     string
+	RedisPassword     string
+	RedisDB           int
+	RedisTLS          store.RedisTLS
+	RedisPool         store.RedisPool
+	Storage           string // "redis" or "postgres"
+	DatabaseURL       string
+	ServerPort        string
+	ScanDiscovery     bool           // find endpoints by SCAN instead of the endpoints_registry set
+	KeyPrefix         string         // namespace of every Redis key, e.g. "prod:"
+	StoreTimeout      time.Duration  // bounds the store calls of each request; 0 disables
+	CacheTTL          time.Duration  // how long assembled endpoint data is reused; 0 reads storage on every request
+	KeyspaceEvents    bool           // serve endpoints from a snapshot kept by keyspace notifications
+	MetricsByHost     bool           // label /metrics series by hostname instead of endpoint URL
+	MetricsStaleAfter time.Duration  // /metrics leaves out endpoints not checked for this long; 0 keeps all
+	CalendarAlarmDays int            // /calendar.ics reminds this many days before an expiry; 0 disables
+	WSAllowedOrigins  []string       // browser origins besides the dashboard's own allowed to open /ws
+	WSToken           string         // when set, /ws requires it as a bearer token or ?token=
+	RateLimitRPS      float64        // requests per second allowed per client IP; 0 disables limiting
+	RateLimitBurst    int            // requests a client may make at once before the rate applies
+	TrustProxy        bool           // take the client IP from X-Forwarded-For
+	DashboardUsername string         // with DashboardPassword, a user required on every page and API call
+	DashboardPassword string         // password of DashboardUsername
+	DashboardHtpasswd string         // path of a htpasswd file of further users with bcrypt hashes
+	APITokens         []string       // bearer tokens required on /api/ instead of the dashboard login
+	APITokensFile     string         // path of a file of further tokens, one per line
+	Log               logging.Config // format and sinks of the log and the access log
+	LogDebug          bool           // also log the requests of health probes
+	ReadHeaderTimeout time.Duration  // time a client has to send its request headers
+	ReadTimeout       time.Duration  // time a client has to send its whole request
+	WriteTimeout      time.Duration  // time from the end of the request headers to the end of the response
+	IdleTimeout       time.Duration  // how long a keep-alive connection may wait for its next request
+	ShutdownTimeout   time.Duration  // how long requests in flight may run on after SIGTERM; 0 waits for all
+	TLSCertFile       string         // with TLSKeyFile, serve HTTPS with this certificate, reloaded when it changes
+	TLSKeyFile        string         // private key of TLSCertFile
+	RedirectHTTPPort  string         // with TLS, a plain HTTP port redirecting to HTTPS
+	BasePath          string         // path prefix of every route behind a reverse proxy, e.g. "/certs"
+	AllowWrite        bool           // enable adding and removing endpoints through the API
+	ViewsFile         string         // path of a YAML file of named presets of the query parameters
+	DisplayTimezone   string         // IANA zone the page shows absolute times in, "" for UTC
+	AutoRefresh       int            // seconds until the page reloads itself; 0 disables
+	TemplateDir       string         // directory of customized templates, "" for the embedded ones
+	TemplateReload    bool           // parse TemplateDir again on every page request, for development
+	DebugEndpoints    bool           // serve pprof and expvar under /debug/, behind the dashboard login
+}
+
+type EndpointData struct {
+	Endpoint         string
+	StatusCode       int
+	StatusText       string
+	StatusClass      string
+	AlertState       string // the checker's alert condition: ok, warning, critical, expired or down; "" before any check
+	AlertText        string // AlertState as shown, e.g. WARN
+	SSLExpiration    *time.Time
+	DaysLeft         *int
+	CertInfo         *store.CertInfo
+	SSLText          string
+	SSLClass         string
+	LastStatusUpdate *time.Time
+	LastSSLUpdate    *time.Time
+	HeaderAudit      *store.HeaderAudit
+	Captured         *store.CapturedHeaders // response headers captured for diagnostics; nil until any are
+	Error            *store.CheckError      // why the last status check was not up
+	ErrorText        string                 // class and age of Error, e.g. "timeout, 3m ago"
+	Uptime           []UptimeCell           // one per store.UptimeWindows
+	Tags             []string
+	Name             string       // display name, shown instead of the URL and on the public status page
+	Description      string       // shown under the name, "" without one
+	RemoteAddr       string       // IP and port that served the last status check, "" when it got no connection
+	CheckedBy        string       // CHECKER_ID of the checker instance behind the last status check
+	SSLCheckedBy     string       // CHECKER_ID of the checker instance behind the last SSL check
+	Ack              *store.Ack   // set while the endpoint's alerts are acknowledged
+	InMaintenance    bool         // the last status check fell in a maintenance window
+	PausedBySchedule bool         // the endpoint's schedule pauses its checks, so an old check is not stale
+	Pause            *store.Pause // set while the endpoint is paused through the API, so not checked
+	Stale            bool         // the last status check is older than the checker heartbeat allows
+	UpdateText       string
+	IsHTTPS          bool
+}
+
+// RowClass returns the CSS classes of the endpoint's dashboard row
+func (e EndpointData) RowClass() string {
+	var classes []string
+	if e.Pause != nil {
+		classes = append(classes, "paused")
+	}
+	if e.Ack != nil {
+		classes = append(classes, "acknowledged")
+	}
+	if e.Stale {
+		classes = append(classes, "stale")
+	}
+	return strings.Join(classes, " ")
+}
+
+type DashboardData struct {
+	Endpoints        []EndpointData
+	Groups           []EndpointGroup // the Endpoints under their headings, by GroupBy
+	TotalEndpoints   int
+	HealthyCount     int
+	SSLWarningCount  int
+	AckCount         int // acknowledged endpoints, left out of the counts above
+	MaintenanceCount int // endpoints in a maintenance window
+	PausedCount      int // paused endpoints, left out of every count above
+	CurrentTime      string
+	StaleNotice      string         // set when storage is unavailable and cached data is shown
+	CheckerNotice    string         // set when the checker heartbeat is old, e.g. "Checker last seen 2h ago"
+	Location         *time.Location // zone of the absolute times shown, by DISPLAY_TIMEZONE or tz
+
+	// Query is the search text; when any filter is applied the counts
+	// above cover the matching endpoints and the All counts every endpoint
+	Query          string
+	Sort           string // sort and order parameters, kept by the search form
+	Order          string
+	GroupBy        string   // group_by parameter: none, tag or domain
+	View           string   // view parameter, a preset of the parameters above
+	Views          []string // names of the VIEWS_FILE presets, sorted
+	TZ             string   // tz and refresh parameters, kept by the search form
+	Refresh        string
+	AutoRefresh    int // seconds until the page reloads itself, 0 for never
+	Filtered       bool
+	AllEndpoints   int
+	AllHealthy     int
+	AllSSLWarning  int
+	AllAcked       int
+	AllMaintenance int
+	AllPaused      int
+
+	UptimeWindows []string // headers of the uptime columns
+
+	SLOs         []store.SLOStatus   // SLOs computed by the checker, with Redis storage
+	RecentAlerts []store.AlertRecord // newest notifications of the checker, with Redis storage
+
+	BasePath string // prefix of the dashboard's links, "" at the root
+	Recheck  bool   // rows get recheck buttons: Redis storage and no API_TOKENS, which the page cannot send
+	Manage   bool   // rows also get acknowledge and pause buttons: Recheck with ALLOW_WRITE
+}
+
+type Server struct {
+	config        Config
+	store         store.Store
+	templates     *template.Template
+	cache         endpointCache
+	assembled     assembledCache
+	redisPrepared atomic.Bool
+	retryBackoff  time.Duration
+	live          *liveSnapshot // nil unless keyspace notifications are used
+	hub           *eventHub     // created with the first /ws client
+	hubOnce       sync.Once
+	auth          *basicAuth     // nil unless dashboard users are configured
+	apiTokens     apiTokens      // nil unless API tokens are configured
+	certs         *certReloader  // nil unless serving HTTPS
+	views         views          // nil unless VIEWS_FILE is set
+	location      *time.Location // DISPLAY_TIMEZONE
+	mux           *http.ServeMux // the routes, served by Start
+	ready         chan struct{}  // closed by Start, see Ready
+}
+
+// errMemoryStorage is returned by NewStore for STORAGE=memory, whose
+// results only the checker in the same process writes
+var errMemoryStorage = errors.New("STORAGE=memory only works in the combined certs-n-status binary, which runs the dashboard in the checker's process")
+
+// NewStore opens the storage backend selected by config.Storage
+func NewStore(ctx context.Context, config Config) (store.Store, error) {
+	switch config.Storage {
+	case "redis":
+		return newRedisStore(config)
+	case "postgres":
+		return store.OpenPostgres(ctx, config.DatabaseURL)
+	case "memory":
+		return nil, errMemoryStorage
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q (use redis, postgres or memory)", config.Storage)
+	}
+}
+
+// newRedisStore creates the Redis-backed store described by config
+func newRedisStore(config Config) (*store.RedisStore, error) {
+	opts := &redis.Options{
+		Addr:     config.RedisAddr,
+		Username: config.RedisUsername,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+		// Store calls are bounded by their context, see storeContext
+		ContextTimeoutEnabled: true,
+	}
+	if err := config.RedisTLS.ApplyTo(opts); err != nil {
+		return nil, err
+	}
+	config.RedisPool.ApplyTo(opts)
+	st := store.NewRedisStore(redis.NewClient(opts))
+	st.SetKeyPrefix(config.KeyPrefix)
+	st.SetScanDiscovery(config.ScanDiscovery)
+	return st, nil
+}
+
+func NewServer(config Config, st store.Store) (*Server, error) {
+	basePath, err := cleanBasePath(config.BasePath)
+	if err != nil {
+		return nil, err
+	}
+	config.BasePath = basePath
+	server := &Server{
+		config:       config,
+		store:        st,
+		retryBackoff: readBackoff,
+		ready:        make(chan struct{}),
+	}
+	auth, err := newBasicAuth(config)
+	if err != nil {
+		return nil, err
+	}
+	server.auth = auth
+	if server.apiTokens, err = newAPITokens(config); err != nil {
+		return nil, err
+	}
+	if config.CacheTTL < 0 {
+		return nil, fmt.Errorf("invalid CACHE_TTL %s (use a duration such as 5s, or 0 to disable)", config.CacheTTL)
+	}
+	if config.AutoRefresh < 0 {
+		return nil, fmt.Errorf("invalid AUTO_REFRESH_SECONDS %d (use seconds, or 0 to disable)", config.AutoRefresh)
+	}
+	if server.location, err = time.LoadLocation(config.DisplayTimezone); err != nil {
+		return nil, fmt.Errorf("invalid DISPLAY_TIMEZONE %q (use an IANA zone such as Australia/Sydney)", config.DisplayTimezone)
+	}
+	if config.ViewsFile != "" {
+		if server.views, err = loadViews(config.ViewsFile); err != nil {
+			return nil, err
+		}
+	}
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		if server.certs, err = newCertReloader(config.TLSCertFile, config.TLSKeyFile); err != nil {
+			return nil, err
+		}
+	} else if config.RedirectHTTPPort != "" {
+		return nil, fmt.Errorf("REDIRECT_HTTP_PORT requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	// Changes to the endpoint list are never anonymous
+	if config.AllowWrite && server.auth == nil && server.apiTokens == nil {
+		return nil, fmt.Errorf("ALLOW_WRITE requires a dashboard login (DASHBOARD_USERNAME/DASHBOARD_PASSWORD or DASHBOARD_HTPASSWD_FILE) or API_TOKENS")
+	}
+
+	// Storage that is down at startup is retried on every request, but data
+	// from a newer release is refused right away
+	ctx, cancel := withStoreTimeout(context.Background(), config.StoreTimeout)
+	defer cancel()
+	if err := st.Ping(ctx); err != nil {
+		log.Printf("[WARN] Storage unavailable, starting anyway and retrying on each request: %v", err)
+	} else if err := server.prepareRedis(ctx); err != nil {
+		return nil, err
+	}
+
+	// Profiles expose memory contents, so they are never anonymous
+	if config.DebugEndpoints && server.auth == nil {
+		return nil, fmt.Errorf("DEBUG_ENDPOINTS requires a dashboard login (DASHBOARD_USERNAME/DASHBOARD_PASSWORD or DASHBOARD_HTPASSWD_FILE)")
+	}
+	if config.TemplateReload && config.TemplateDir == "" {
+		return nil, fmt.Errorf("TEMPLATE_RELOAD requires TEMPLATE_DIR")
+	}
+	if server.templates, err = parseTemplates(config.TemplateDir); err != nil {
+		if !config.TemplateReload {
+			return nil, err
+		}
+		// Fixed templates are picked up by the next request
+		log.Printf("[WARN] %v", err)
+	}
+	server.mux = server.routes()
+	return server, nil
+}
+
+// storeContext bounds the store calls serving r by STORAGE_TIMEOUT, so a
+// hung storage server cannot block a request until TCP gives up, and
+// cancels them when the client goes away
+func (s *Server) storeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return withStoreTimeout(r.Context(), s.config.StoreTimeout)
+}
+
+func withStoreTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// storeErrorStatus is the status of a response whose store calls failed:
+// 504 when they ran out of time, 500 otherwise
+func storeErrorStatus(ctx context.Context) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) getEndpointData(ctx context.Context, endpoint string) EndpointData {
+	stored, err := s.store.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get data for %s: %v", endpoint, err)
+	}
+	return newEndpointData(stored, time.Now().UTC())
+}
+
+// newEndpointData derives the display values for one endpoint from its stored results
+func newEndpointData(stored store.EndpointData, now time.Time) EndpointData {
+	data := EndpointData{
+		Endpoint:      stored.Endpoint,
+		IsHTTPS:       strings.HasPrefix(stored.Endpoint, "https://"),
+		CertInfo:      stored.CertInfo,
+		HeaderAudit:   stored.HeaderAudit,
+		Captured:      stored.Captured,
+		Error:         stored.Error,
+		Uptime:        newUptimeCells(stored.Uptime),
+		Tags:          stored.Tags,
+		Name:          stored.Name,
+		Description:   stored.Description,
+		RemoteAddr:    stored.RemoteAddr,
+		CheckedBy:     stored.CheckedBy,
+		SSLCheckedBy:  stored.SSLCheckedBy,
+		InMaintenance: stored.InMaintenance,
+
+		PausedBySchedule: stored.PausedBySchedule,
+	}
+
+	if stored.HasStatus {
+		data.StatusCode = stored.StatusCode
+		data.StatusText = strconv.Itoa(stored.StatusCode)
+	}
+	data.LastStatusUpdate = timePtr(stored.StatusUpdated)
+
+	if data.IsHTTPS {
+		data.SSLExpiration = timePtr(stored.SSLExpiration)
+		if data.SSLExpiration != nil {
+			days := daysLeft(*data.SSLExpiration, now)
+			data.DaysLeft = &days
+		}
+		data.LastSSLUpdate = timePtr(stored.SSLUpdated)
+	}
+
+	// Set display values
+	data.StatusClass = getStatusClass(data.StatusCode)
+	data.AlertState = stored.AlertState()
+	data.AlertText = alertTexts[data.AlertState]
+	data.SSLClass = getSSLClass(data.SSLExpiration, now)
+	data.SSLText = getSSLText(data.IsHTTPS, data.SSLExpiration, now)
+	if data.CertInfo != nil && data.CertInfo.State == store.CertStateNotYetValid {
+		data.SSLClass = "ssl-critical"
+		data.SSLText = getNotYetValidText(data.CertInfo.NotBefore, now)
+	}
+
+	data.UpdateText = formatTimeAgo(lastUpdate(data))
+	if data.Error != nil {
+		data.ErrorText = data.Error.Class + ", " + formatTimeAgo(timePtr(data.Error.At))
+	}
+
+	return data
+}
+
+// timePtr returns nil for the zero time, which the store uses for missing values
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func getNotYetValidText(notBefore time.Time, now time.Time) string {
+	if notBefore.IsZero() {
+		return "Not yet valid"
+	}
+	until := notBefore.Sub(now)
+	if until <= 0 {
+		return "Not yet valid (clock skew)"
+	}
+	if until < 48*time.Hour {
+		return fmt.Sprintf("Not yet valid (starts in %dh)", int(until.Hours()))
+	}
+	return fmt.Sprintf("Not yet valid (starts in %d days)", int(until.Hours()/24))
+}
+
+// alertTexts are the AlertState values as the page shows them
+var alertTexts = map[string]string{
+	store.CertLevelOK:       "OK",
+	store.CertLevelWarning:  "WARN",
+	store.CertLevelCritical: "CRIT",
+	store.CertLevelExpired:  "EXPIRED",
+	store.StatusDown:        "DOWN",
+}
+
+func getStatusClass(statusCode int) string {
+	if statusCode == 0 {
+		return "status-error"
+	} else if statusCode >= 200 && statusCode < 300 {
+		return "status-success"
+	} else if statusCode >= 300 && statusCode < 400 {
+		return "status-redirect"
+	} else if statusCode >= 400 && statusCode < 500 {
+		return "status-client-error"
+	} else if statusCode >= 500 && statusCode < 600 {
+		return "status-server-error"
+	}
+	return "status-unknown"
+}
+
+// getSSLClass colors a certificate expiring at expiration by its
+// store.CertLevel, the level the checker notifies
+func getSSLClass(expiration *time.Time, now time.Time) string {
+	if expiration == nil {
+		return ""
+	}
+	return "ssl-" + store.CertLevel(*expiration, now)
+}
+
+// sslHoursShown is the time left or since expiry below which the SSL
+// column counts hours instead of days
+const sslHoursShown = 48 * time.Hour
+
+// getSSLText says how long a certificate expiring at expiration has left
+// at now, or how long ago it expired, in hours within sslHoursShown
+func getSSLText(isHTTPS bool, expiration *time.Time, now time.Time) string {
+	if !isHTTPS {
+		return "HTTP only"
+	}
+	if expiration == nil {
+		return "Checking..."
+	}
+	days, left := store.DaysLeft(*expiration, now)
+	switch {
+	case left <= 0 && -left < sslHoursShown:
+		return "Expired " + formatHours(-left) + " ago"
+	case left <= 0:
+		return fmt.Sprintf("Expired %d days ago", -days)
+	case left < sslHoursShown:
+		return "Expires in " + formatHours(left)
+	}
+	return fmt.Sprintf("%d days left", days)
+}
+
+// formatHours writes d in whole hours, or minutes under an hour
+func formatHours(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh", int(d.Hours()))
+}
+
+// daysLeft is the days part of store.DaysLeft
+func daysLeft(notAfter, now time.Time) int {
+	days, _ := store.DaysLeft(notAfter, now)
+	return days
+}
+
+func formatTimeAgo(t *time.Time) string {
+	if t == nil {
+		return "Never"
+	}
+
+	now := time.Now().UTC()
+	delta := now.Sub(*t)
+	seconds := delta.Seconds()
+
+	if seconds < 60 {
+		return fmt.Sprintf("%ds ago", int(seconds))
+	} else if seconds < 3600 {
+		return fmt.Sprintf("%dm ago", int(seconds/60))
+	} else if seconds < 86400 {
+		return fmt.Sprintf("%dh ago", int(seconds/3600))
+	}
+	return fmt.Sprintf("%dd ago", int(seconds/86400))
+}
+
+// handleIndex serves the dashboard on GET / only; other paths are not found
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	// The format follows Accept unless format= picks one
+	w.Header().Add("Vary", "Accept")
+	mediaType := negotiate(r, "text/html", "application/json", "text/plain")
+	switch r.URL.Query().Get("format") {
+	case "":
+	case "text":
+		mediaType = "text/plain"
+	default:
+		mediaType = "text/html"
+	}
+
+	query, ok := s.viewQuery(w, r)
+	if !ok {
+		return
+	}
+	if mediaType == "application/json" {
+		s.writeEndpointList(w, r, query)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	dashboardData, status, err := s.dashboardData(ctx, query)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if mediaType == "text/plain" {
+		color, _ := strconv.ParseBool(r.URL.Query().Get("color"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		writeTextDashboard(w, dashboardData, color)
+		return
+	}
+
+	tmpl, ok := s.pageTemplates(w)
+	if !ok {
+		return
+	}
+	// A timed out read still shows the cached data, flagged by a 504
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	defer countRender(time.Now())
+	if err := tmpl.Execute(w, dashboardData); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		log.Printf("[ERROR] Failed to render template: %v", err)
+	}
+}
+
+// dashboardData assembles the dashboard for query, filtered, sorted and
+// counted the same way whatever the output format. It returns the response
+// status, 504 when cached data is shown after a timed out read, or the error
+// status with an error meant for the client.
+func (s *Server) dashboardData(ctx context.Context, query url.Values) (DashboardData, int, error) {
+	// Get data for all endpoints
+	endpointData, staleSince, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Failed to get endpoints: %v", err)
+		return DashboardData{}, storeErrorStatus(ctx), errors.New("Failed to get endpoints")
+	}
+
+	location, err := s.displayLocation(query)
+	if err != nil {
+		return DashboardData{}, http.StatusBadRequest, err
+	}
+	autoRefresh := s.config.AutoRefresh
+	if value := query.Get("refresh"); value != "" {
+		if autoRefresh, err = strconv.Atoi(value); err != nil || autoRefresh < 0 {
+			return DashboardData{}, http.StatusBadRequest, fmt.Errorf("invalid refresh value %q (use seconds, or 0 to disable)", value)
+		}
+	}
+	now := time.Now().UTC()
+	all := summarizeEndpoints(endpointData, now)
+	if endpointData, err = filterEndpoints(query, endpointData, now); err != nil {
+		return DashboardData{}, http.StatusBadRequest, err
+	}
+	if err := sortEndpoints(query, endpointData); err != nil {
+		return DashboardData{}, http.StatusBadRequest, err
+	}
+	groupBy, err := parseGroupBy(query)
+	if err != nil {
+		return DashboardData{}, http.StatusBadRequest, err
+	}
+	summary := summarizeEndpoints(endpointData, now)
+
+	dashboardData := DashboardData{
+		Endpoints:        endpointData,
+		Groups:           groupEndpoints(endpointData, groupBy, now),
+		TotalEndpoints:   summary.Total,
+		HealthyCount:     summary.Healthy,
+		SSLWarningCount:  summary.SSLWarning,
+		AckCount:         summary.Acknowledged,
+		MaintenanceCount: summary.InMaintenance,
+		PausedCount:      summary.Paused,
+		CurrentTime:      now.In(location).Format("15:04:05 MST"),
+		Location:         location,
+		Query:            query.Get("q"),
+		Sort:             query.Get("sort"),
+		Order:            query.Get("order"),
+		GroupBy:          groupBy,
+		View:             query.Get("view"),
+		TZ:               query.Get("tz"),
+		Refresh:          query.Get("refresh"),
+		AutoRefresh:      autoRefresh,
+		Views:            s.views.names(),
+		Filtered:         hasEndpointFilter(query),
+		AllEndpoints:     all.Total,
+		AllHealthy:       all.Healthy,
+		AllSSLWarning:    all.SSLWarning,
+		AllAcked:         all.Acknowledged,
+		AllMaintenance:   all.InMaintenance,
+		AllPaused:        all.Paused,
+		UptimeWindows:    uptimeWindowNames(),
+		BasePath:         s.config.BasePath,
+	}
+	if staleSince.IsZero() {
+		dashboardData.CheckerNotice = checkerNotice(s.readHeartbeat(ctx), now)
+		dashboardData.SLOs = s.readSLOs(ctx)
+		dashboardData.RecentAlerts = s.readRecentAlerts(ctx)
+	}
+	if _, ok := s.store.(*store.RedisStore); ok && s.apiTokens == nil {
+		dashboardData.Recheck = true
+		dashboardData.Manage = s.config.AllowWrite
+	}
+	status := http.StatusOK
+	if !staleSince.IsZero() {
+		dashboardData.StaleNotice = fmt.Sprintf("Data may be stale (%s unavailable since %s)",
+			storageName(s.store), staleSince.In(location).Format("2006-01-02 15:04:05 MST"))
+		if storeErrorStatus(ctx) == http.StatusGatewayTimeout {
+			status = http.StatusGatewayTimeout
+		}
+	}
+	return dashboardData, status, nil
+}
+
+// displayLocation returns the zone of the tz query parameter, or of
+// DISPLAY_TIMEZONE without one
+func (s *Server) displayLocation(query url.Values) (*time.Location, error) {
+	if tz := query.Get("tz"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tz value %q (use an IANA zone such as Australia/Sydney)", tz)
+		}
+		return location, nil
+	}
+	if s.location == nil {
+		return time.UTC, nil
+	}
+	return s.location, nil
+}
+
+func (s *Server) handleAPIEndpoints(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.viewQuery(w, r)
+	if !ok {
+		return
+	}
+	// Superseded by /api/v1/endpoints, kept for existing consumers
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "<"+s.config.BasePath+`/api/v1/endpoints>; rel="successor-version"`)
+	s.writeEndpointList(w, r, query)
+}
+
+// writeEndpointList writes the endpoints matching query in the format of
+// /api/endpoints, which / also serves to clients accepting JSON
+func (s *Server) writeEndpointList(w http.ResponseWriter, r *http.Request, query url.Values) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+
+	endpointData, staleSince, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get endpoints", storeErrorStatus(ctx))
+		return
+	}
+	if endpointData, err = filterEndpoints(query, endpointData, time.Now().UTC()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := sortEndpoints(query, endpointData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"endpoints": endpointData,
+		"total":     len(endpointData),
+	}
+	if selected, err := selectFields(query, endpointFields, endpointData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if selected != nil {
+		response["endpoints"] = selected
+	}
+	if !staleSince.IsZero() {
+		response["stale_since"] = staleSince
+	}
+	writeJSONWithETag(w, r, s.staleStatus(w, ctx, staleSince), response)
+}
+
+// staleStatus flags an API response carrying cached data with a Warning
+// header, and returns its status code: 504 when the read timed out
+func (s *Server) staleStatus(w http.ResponseWriter, ctx context.Context, staleSince time.Time) int {
+	if staleSince.IsZero() {
+		return http.StatusOK
+	}
+	w.Header().Set("Warning", fmt.Sprintf(`110 - "Response is stale: %s unavailable since %s"`,
+		storageName(s.store), staleSince.Format(time.RFC3339)))
+	if storeErrorStatus(ctx) == http.StatusGatewayTimeout {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusOK
+}
+
+type ExpiringEndpoint struct {
+	Endpoint      string    `json:"endpoint"`
+	SSLExpiration time.Time `json:"ssl_expiration"`
+	DaysLeft      int       `json:"days_left"`
+}
+
+func (s *Server) handleAPIExpiring(w http.ResponseWriter, r *http.Request) {
+	within := store.CertWarningWindow
+	if value := r.URL.Query().Get("within"); value != "" {
+		d, err := parseDuration(value)
+		if err != nil || d < 0 {
+			http.Error(w, fmt.Sprintf("Invalid within value %q (use e.g. 30d or 72h)", value), http.StatusBadRequest)
+			return
+		}
+		within = d
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	now := time.Now().UTC()
+	results, err := s.store.ExpiringBefore(ctx, now.Add(within))
+	if err != nil {
+		http.Error(w, "Failed to get expiring endpoints", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read SSL expiry index: %v", err)
+		return
+	}
+
+	expiring := make([]ExpiringEndpoint, 0, len(results))
+	for _, result := range results {
+		expiring = append(expiring, ExpiringEndpoint{
+			Endpoint:      result.Endpoint,
+			SSLExpiration: result.SSLExpiration,
+			DaysLeft:      daysLeft(result.SSLExpiration, now),
+		})
+	}
+
+	response := map[string]interface{}{
+		"endpoints": expiring,
+		"total":     len(expiring),
+		"within":    within.String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+type HistoryEntry struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	StatusCode int       `json:"status_code"`
+	LatencyMs  int64     `json:"latency_ms"`
+}
+
+type SSLObservation struct {
+	ObservedAt  time.Time `json:"observed_at"`
+	NotAfter    time.Time `json:"not_after"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+}
+
+// HistorySummary condenses the status checks of the last day
+type HistorySummary struct {
+	Since         time.Time  `json:"since"`
+	Checks        int        `json:"checks"`
+	Healthy       int        `json:"healthy"` // 2xx responses
+	UptimePercent float64    `json:"uptime_percent"`
+	AvgLatencyMs  int64      `json:"avg_latency_ms"`
+	MaxLatencyMs  int64      `json:"max_latency_ms"`
+	LastFailure   *time.Time `json:"last_failure,omitempty"`
+}
+
+// EndpointDetail is the response of GET /api/endpoints/{url}
+type EndpointDetail struct {
+	Endpoint   EndpointData     `json:"endpoint"`
+	SSLHistory []SSLObservation `json:"ssl_history"`
+	History    HistorySummary   `json:"history"`
+	Timestamps map[string]int64 `json:"timestamps"`      // raw Unix times by endpoint hash field
+	Error      string           `json:"error,omitempty"` // reason of a failed last check
+	// LatencyPercentiles are left out without Redis storage
+	LatencyPercentiles []LatencyPercentiles `json:"latency_percentiles,omitempty"`
+}
+
+// detailHistoryWindow is how far back the detail API summarizes the history
+const detailHistoryWindow = 24 * time.Hour
+
+// handleAPIEndpoint serves the per-endpoint API under /api/endpoints/{url},
+// with the endpoint URL percent-encoded:
+//
+//	GET /api/endpoints/{url}                  endpoint details and certificate renewals
+//	GET /api/endpoints/{url}/history?since=   status history
+//	GET /api/endpoints/{url}/latency?since=   hourly latency rollups
+//	GET /api/endpoints/{url}/percentiles      latency percentiles of the last hour and day
+//
+// The URL is normalized like the checker does with endpoints file entries,
+// so example.com finds https://example.com.
+func (s *Server) handleAPIEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/api/endpoints/")
+	if endpoint, ok := strings.CutSuffix(endpoint, "/history"); ok && endpoint != "" {
+		s.handleAPIHistory(w, r, store.NormalizeEndpoint(endpoint))
+		return
+	}
+	if endpoint, ok := strings.CutSuffix(endpoint, "/latency"); ok && endpoint != "" {
+		s.handleAPILatency(w, r, store.NormalizeEndpoint(endpoint))
+		return
+	}
+	if endpoint, ok := strings.CutSuffix(endpoint, "/percentiles"); ok && endpoint != "" {
+		s.handleAPIPercentiles(w, r, store.NormalizeEndpoint(endpoint))
+		return
+	}
+	if endpoint == "" {
+		s.notFound(w, r)
+		return
+	}
+	s.handleAPIEndpointDetail(w, r, store.NormalizeEndpoint(endpoint))
+}
+
+// handleAPIEndpointByURL serves GET /api/endpoints/detail?url=, the endpoint
+// details for tooling that would rather not path-escape the URL
+func (s *Server) handleAPIEndpointByURL(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimSpace(r.URL.Query().Get("url"))
+	if endpoint == "" {
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+	s.handleAPIEndpointDetail(w, r, store.NormalizeEndpoint(endpoint))
+}
+
+// handleAPIEndpointDetail returns everything known about one endpoint: its
+// stored results, the reason of a failed check, a summary of the last day of
+// history, latency percentiles, certificate renewals and the raw Unix
+// timestamps stored in Redis
+func (s *Server) handleAPIEndpointDetail(w http.ResponseWriter, r *http.Request, endpoint string) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := s.store.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to get endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read endpoint %s: %v", endpoint, err)
+		return
+	}
+	if !stored.HasStatus && stored.SSLUpdated.IsZero() {
+		s.notFound(w, r)
+		return
+	}
+
+	renewals, err := s.store.SSLHistory(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to get SSL history", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read SSL history for %s: %v", endpoint, err)
+		return
+	}
+	sslHistory := make([]SSLObservation, 0, len(renewals))
+	for _, renewal := range renewals {
+		sslHistory = append(sslHistory, SSLObservation(renewal))
+	}
+
+	now := time.Now().UTC()
+	entries, err := s.store.StatusHistory(ctx, endpoint, now.Add(-detailHistoryWindow))
+	if err != nil {
+		http.Error(w, "Failed to get status history", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read status history for %s: %v", endpoint, err)
+		return
+	}
+
+	response := EndpointDetail{
+		Endpoint:   newEndpointData(stored, now),
+		SSLHistory: sslHistory,
+		History:    summarizeHistory(entries, now.Add(-detailHistoryWindow)),
+		Timestamps: rawTimestamps(stored),
+		Error:      errorReason(stored),
+
+		LatencyPercentiles: s.readLatencyPercentiles(ctx, endpoint, now),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// errorReason explains a failed status check, or returns "" when the last
+// check got a successful or redirect response
+func errorReason(stored store.EndpointData) string {
+	if !stored.HasStatus {
+		return ""
+	}
+	switch code := stored.StatusCode; {
+	case code == -1:
+		return "DNS resolution failed"
+	case code == 0:
+		return "Connection failed"
+	case code >= 400:
+		return fmt.Sprintf("HTTP %d %s", code, http.StatusText(code))
+	}
+	return ""
+}
+
+// summarizeHistory condenses status checks recorded since since
+func summarizeHistory(entries []store.HistoryEntry, since time.Time) HistorySummary {
+	summary := HistorySummary{Since: since, Checks: len(entries)}
+	var totalLatency time.Duration
+	for _, entry := range entries {
+		if entry.StatusCode >= 200 && entry.StatusCode < 300 {
+			summary.Healthy++
+		} else {
+			summary.LastFailure = timePtr(entry.CheckedAt)
+		}
+		totalLatency += entry.Latency
+		summary.MaxLatencyMs = max(summary.MaxLatencyMs, entry.Latency.Milliseconds())
+	}
+	if len(entries) > 0 {
+		summary.UptimePercent = float64(summary.Healthy) * 100 / float64(len(entries))
+		summary.AvgLatencyMs = (totalLatency / time.Duration(len(entries))).Milliseconds()
+	}
+	return summary
+}
+
+// rawTimestamps returns the Unix times stored in the endpoint hash, keyed by
+// hash field, leaving out those not recorded yet
+func rawTimestamps(stored store.EndpointData) map[string]int64 {
+	timestamps := make(map[string]int64)
+	for field, t := range map[string]time.Time{
+		"status_updated": stored.StatusUpdated,
+		"ssl_expiry":     stored.SSLExpiration,
+		"ssl_updated":    stored.SSLUpdated,
+	} {
+		if !t.IsZero() {
+			timestamps[field] = t.Unix()
+		}
+	}
+	if stored.HeaderAudit != nil && !stored.HeaderAudit.Updated.IsZero() {
+		timestamps["headers_updated"] = stored.HeaderAudit.Updated.Unix()
+	}
+	return timestamps
+}
+
+// handleAPIHistory returns the status history since an RFC 3339 time or a
+// duration back from now (default 24h)
+func (s *Server) handleAPIHistory(w http.ResponseWriter, r *http.Request, endpoint string) {
+	since, err := parseSince(r.URL.Query().Get("since"), time.Now().UTC(), 24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	entries, err := s.store.StatusHistory(ctx, endpoint, since)
+	if err != nil {
+		http.Error(w, "Failed to get status history", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read status history for %s: %v", endpoint, err)
+		return
+	}
+
+	history := make([]HistoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		history = append(history, HistoryEntry{
+			CheckedAt:  entry.CheckedAt,
+			StatusCode: entry.StatusCode,
+			LatencyMs:  entry.Latency.Milliseconds(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+type LatencyRollup struct {
+	Hour   time.Time `json:"hour"`
+	Count  int       `json:"count"`
+	MinMs  int64     `json:"min_ms"`
+	AvgMs  int64     `json:"avg_ms"`
+	P95Ms  int64     `json:"p95_ms"`
+	MaxMs  int64     `json:"max_ms"`
+	Checks int       `json:"checks"` // every check, including those left out of the latencies
+	Up     int       `json:"up"`
+}
+
+// handleAPILatency returns the hourly latency rollups since an RFC 3339 time
+// or a duration back from now (default 7d)
+func (s *Server) handleAPILatency(w http.ResponseWriter, r *http.Request, endpoint string) {
+	since, err := parseSince(r.URL.Query().Get("since"), time.Now().UTC(), 7*24*time.Hour)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := s.store.LatencyRollups(ctx, endpoint, since)
+	if err != nil {
+		http.Error(w, "Failed to get latency rollups", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read latency rollups for %s: %v", endpoint, err)
+		return
+	}
+
+	rollups := make([]LatencyRollup, 0, len(stored))
+	for _, rollup := range stored {
+		rollups = append(rollups, LatencyRollup{
+			Hour:   rollup.Hour,
+			Count:  rollup.Count,
+			MinMs:  rollup.Min.Milliseconds(),
+			AvgMs:  rollup.Avg.Milliseconds(),
+			P95Ms:  rollup.P95.Milliseconds(),
+			MaxMs:  rollup.Max.Milliseconds(),
+			Checks: rollup.Checks,
+			Up:     rollup.Up,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rollups)
+}
+
+// handleAPIEvents returns state-change events from the Redis event stream
+// recorded after the stream ID since (all when omitted), oldest first,
+// optionally only those of one endpoint. Clients page through the stream by
+// passing the last returned id as since.
+func (s *Server) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Events require Redis storage", http.StatusNotImplemented)
+		return
+	}
+
+	query := r.URL.Query()
+	since := query.Get("since")
+	if since != "" && !validStreamID(since) {
+		http.Error(w, fmt.Sprintf("Invalid since value %q (use an event id such as 1709294400000-0)", since), http.StatusBadRequest)
+		return
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			http.Error(w, fmt.Sprintf("Invalid limit value %q (use 1 to %d)", value, maxEventsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	events, err := rs.Events(ctx, since, query.Get("endpoint"), limit)
+	if err != nil {
+		http.Error(w, "Failed to get events", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read event stream: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// handleAPIPool reports the Redis connection pool, to size REDIS_POOL_SIZE
+func (s *Server) handleAPIPool(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Pool stats require Redis storage", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rs.PoolStats())
+}
+
+// maxEventsLimit bounds how many events one /api/events request returns
+const maxEventsLimit = 1000
+
+// validStreamID reports whether id is a Redis stream ID, "<ms>" or "<ms>-<seq>"
+func validStreamID(id string) bool {
+	ms, seq, hasSeq := strings.Cut(id, "-")
+	if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+		return false
+	}
+	if hasSeq {
+		if _, err := strconv.ParseUint(seq, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSince reads a since parameter given as an RFC 3339 time or as a
+// duration back from now, defaulting to now minus def when empty
+func parseSince(value string, now time.Time, def time.Duration) (time.Time, error) {
+	if value == "" {
+		return now.Add(-def), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := parseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since value %q (use an RFC 3339 time or e.g. 24h or 7d)", value)
+}
+
+// parseDuration extends time.ParseDuration with a "d" (day) unit, e.g. "30d"
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Start serves the dashboard until ctx is done, then shuts down gracefully
+func (s *Server) Start(ctx context.Context) error {
+	if rs, ok := s.store.(*store.RedisStore); ok {
+		go rs.WatchPoolTimeouts(ctx, time.Minute)
+	}
+	s.startLiveRefresh(ctx)
+
+	scheme := "http"
+	if s.certs != nil {
+		scheme = "https"
+	}
+	log.Printf("[INFO] Starting Go dashboard server on port %s", s.config.ServerPort)
+	log.Printf("[INFO] Access the dashboard at: %s://localhost:%s%s/", scheme, s.config.ServerPort, s.config.BasePath)
+
+	srv := s.newHTTPServer(s.handler())
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	if s.certs != nil {
+		srv.TLSConfig = s.certs.tlsConfig()
+		if s.config.RedirectHTTPPort != "" {
+			if err := s.startHTTPSRedirect(ctx); err != nil {
+				ln.Close()
+				return err
+			}
+		}
+	}
+	close(s.ready)
+	return s.serve(ctx, srv, ln)
+}
+
+// Ready is closed once Start is listening
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// LoadConfig returns the defaults overridden by environment variables,
+// read through env. It fails listing every invalid value and setting, see
+// Config.validate.
+func LoadConfig(env *store.Env) (Config, error) {
+	// DATABASE_URL alone selects PostgreSQL
+	defaultStorage := "redis"
+	if os.Getenv("DATABASE_URL") != "" {
+		defaultStorage = "postgres"
+	}
+	config := Config{
+		RedisAddr:         env.String("REDIS_ADDR", "localhost:6379"),
+		RedisDB:           env.Int("REDIS_DB", 0),
+		DatabaseURL:       env.String("DATABASE_URL", ""),
+		Storage:           env.String("STORAGE", defaultStorage),
+		ServerPort:        env.String("SERVER_PORT", "8080"),
+		ScanDiscovery:     env.Choice("ENDPOINT_DISCOVERY", "registry", "scan") == "scan",
+		KeyPrefix:         env.String("KEY_PREFIX", ""),
+		StoreTimeout:      env.Duration("STORAGE_TIMEOUT", store.DefaultOperationTimeout),
+		CacheTTL:          env.Duration("CACHE_TTL", defaultCacheTTL),
+		KeyspaceEvents:    env.Bool("REDIS_KEYSPACE_EVENTS", false),
+		MetricsByHost:     env.Choice("METRICS_LABEL", "endpoint", "hostname") == "hostname",
+		MetricsStaleAfter: env.Duration("METRICS_STALE_AFTER", defaultMetricsStaleAfter),
+		CalendarAlarmDays: env.Int("CALENDAR_ALARM_DAYS", defaultCalendarAlarmDays),
+		WSAllowedOrigins:  env.List("WS_ALLOWED_ORIGINS"),
+		WSToken:           env.String("WS_TOKEN", ""),
+		RateLimitRPS:      env.Float("RATE_LIMIT_RPS", 0),
+		RateLimitBurst:    env.Int("RATE_LIMIT_BURST", defaultRateLimitBurst),
+		TrustProxy:        env.Bool("TRUST_PROXY", false),
+		DashboardUsername: env.String("DASHBOARD_USERNAME", ""),
+		DashboardPassword: env.String("DASHBOARD_PASSWORD", ""),
+		DashboardHtpasswd: env.String("DASHBOARD_HTPASSWD_FILE", ""),
+		APITokens:         env.List("API_TOKENS"),
+		APITokensFile:     env.String("API_TOKENS_FILE", ""),
+		Log:               logging.ConfigFromEnv(env, "dashboard"),
+		LogDebug:          env.Choice("LOG_LEVEL", "info", "debug") == "debug",
+		ReadHeaderTimeout: env.Duration("HTTP_READ_HEADER_TIMEOUT", defaultReadHeaderTimeout),
+		ReadTimeout:       env.Duration("HTTP_READ_TIMEOUT", defaultReadTimeout),
+		WriteTimeout:      env.Duration("HTTP_WRITE_TIMEOUT", defaultWriteTimeout),
+		IdleTimeout:       env.Duration("HTTP_IDLE_TIMEOUT", defaultIdleTimeout),
+		ShutdownTimeout:   env.Duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		TLSCertFile:       env.String("TLS_CERT_FILE", ""),
+		TLSKeyFile:        env.String("TLS_KEY_FILE", ""),
+		RedirectHTTPPort:  env.String("REDIRECT_HTTP_PORT", ""),
+		BasePath:          env.String("BASE_PATH", ""),
+		AllowWrite:        env.Bool("ALLOW_WRITE", false),
+		ViewsFile:         env.String("VIEWS_FILE", ""),
+		DisplayTimezone:   env.String("DISPLAY_TIMEZONE", ""),
+		AutoRefresh:       env.Int("AUTO_REFRESH_SECONDS", defaultAutoRefresh),
+		TemplateDir:       env.String("TEMPLATE_DIR", ""),
+		TemplateReload:    env.Bool("TEMPLATE_RELOAD", false),
+		DebugEndpoints:    env.Bool("DEBUG_ENDPOINTS", false),
+	}
+	var err error
+	config.RedisUsername, config.RedisPassword, err = store.RedisCredentialsFromEnv()
+	env.Add(err)
+	config.RedisTLS, err = store.RedisTLSFromEnv()
+	env.Add(err)
+	config.RedisPool, err = store.RedisPoolFromEnv()
+	env.Add(err)
+	for _, problem := range config.validate() {
+		env.Add(problem)
+	}
+	return config, env.Err()
+}
+
+// Main runs the dashboard command line
+func Main() {
+	configFile := flag.String("config", "", "YAML config file; environment variables override its settings")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	if *showVersion {
+		fmt.Println("dashboard " + version.Get().String())
+		return
+	}
+
+	if *configFile != "" {
+		if err := store.ApplyConfigFile(*configFile, ConfigKeys, "checker"); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	var env store.Env
+	config, err := LoadConfig(&env)
+	if *printConfig {
+		if err := env.WriteConfig(os.Stdout, ConfigKeys); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	if err != nil {
+		logging.Setup(logging.Config{Format: config.Log.Format})
+		log.Fatalf("[FATAL] Invalid configuration:\n%v", err)
+	}
+	if *printConfig {
+		return
+	}
+	closeLog, err := logging.Setup(config.Log)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to set up logging: %v", err)
+	}
+	defer closeLog()
+	log.Printf("[INFO] Certs-n-Status dashboard %s", version.Get())
+
+	st, err := NewStore(context.Background(), config)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to open storage: %v", err)
+	}
+
+	server, err := NewServer(config, st)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to create server: %v", err)
+	}
+
+	// SIGTERM (docker stop, Kubernetes) and Ctrl-C drain the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Under systemd, Type=notify waits for the listener
+	go func() {
+		<-server.Ready()
+		if err := sdnotify.Notify(sdnotify.Ready); err != nil {
+			log.Printf("[WARN] Failed to notify systemd: %v", err)
+		}
+		sdnotify.Watchdog(ctx, nil)
+	}()
+	if err := server.Start(ctx); err != nil {
+		log.Fatalf("[FATAL] Server error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		log.Printf("[WARN] Failed to close storage: %v", err)
+	}
+	log.Printf("[INFO] Shutdown complete")
+}
@@ -0,0 +1,5076 @@
+package dashboard
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+	"certs-n-status/store/version"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestParseDuration tests duration parsing with day units
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"72h", 72 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"1.5d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNotYetValidCertificate tests that not-yet-valid certificates render as critical
+func TestNotYetValidCertificate(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	notBefore := now.Add(5 * time.Hour)
+
+	data := newEndpointData(store.EndpointData{
+		Endpoint:      "https://example.com",
+		SSLExpiration: notBefore.Add(90 * 24 * time.Hour),
+		CertInfo: &store.CertInfo{
+			NotBefore: notBefore,
+			NotAfter:  notBefore.Add(90 * 24 * time.Hour),
+			State:     store.CertStateNotYetValid,
+		},
+	}, now)
+
+	if data.SSLClass != "ssl-critical" {
+		t.Errorf("SSLClass = %q, want ssl-critical", data.SSLClass)
+	}
+	if data.SSLText != "Not yet valid (starts in 5h)" {
+		t.Errorf("SSLText = %q", data.SSLText)
+	}
+
+	if got := getNotYetValidText(now.Add(-time.Minute), now); got != "Not yet valid (clock skew)" {
+		t.Errorf("getNotYetValidText() = %q", got)
+	}
+}
+
+// TestCertificateDisplayLevels tests that certificates are colored by the
+// levels the checker notifies, with days rounded up before expiry and down
+// after it, and hours shown within two days of it
+func TestCertificateDisplayLevels(t *testing.T) {
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	for _, tt := range []struct {
+		left      time.Duration
+		wantClass string
+		wantText  string
+	}{
+		{30 * day, "ssl-ok", "30 days left"},
+		{30*day - time.Minute, "ssl-warning", "30 days left"},
+		{7*day - time.Minute, "ssl-critical", "7 days left"},
+		{48 * time.Hour, "ssl-critical", "2 days left"},
+		{31 * time.Hour, "ssl-critical", "Expires in 31h"},
+		{12 * time.Hour, "ssl-critical", "Expires in 12h"},
+		{45 * time.Minute, "ssl-critical", "Expires in 45m"},
+		{-12 * time.Hour, "ssl-expired", "Expired 12h ago"},
+		{-36 * time.Hour, "ssl-expired", "Expired 36h ago"},
+		{-72 * time.Hour, "ssl-expired", "Expired 3 days ago"},
+	} {
+		expiration := now.Add(tt.left)
+		data := newEndpointData(store.EndpointData{Endpoint: "https://example.com", SSLExpiration: expiration, SSLUpdated: now}, now)
+		if data.SSLClass != tt.wantClass || data.SSLText != tt.wantText {
+			t.Errorf("certificate expiring in %s = %q %q, want %q %q", tt.left, data.SSLClass, data.SSLText, tt.wantClass, tt.wantText)
+		}
+		if level := store.CertLevel(expiration, now); data.SSLClass != "ssl-"+level {
+			t.Errorf("certificate expiring in %s has class %q, the checker's level is %s", tt.left, data.SSLClass, level)
+		}
+	}
+}
+
+// TestGetEndpointData tests assembling display data from the store
+func TestGetEndpointData(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	endpoint := "https://example.com"
+
+	st.SetStatus(ctx, endpoint, 200, now)
+	st.SetSSLExpiry(ctx, endpoint, now.Add(10*24*time.Hour+time.Hour), now)
+	st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: now, HeaderAudit: &store.HeaderAudit{Failures: []string{"X-Content-Type-Options missing"}, Updated: now}}})
+
+	server := &Server{store: st}
+	data := server.getEndpointData(ctx, endpoint)
+
+	if data.StatusText != "200" || data.StatusClass != "status-success" {
+		t.Errorf("status = %q/%q, want 200/status-success", data.StatusText, data.StatusClass)
+	}
+	if data.DaysLeft == nil || *data.DaysLeft != 11 {
+		t.Errorf("DaysLeft = %v, want 11", data.DaysLeft)
+	}
+	if data.SSLClass != "ssl-warning" {
+		t.Errorf("SSLClass = %q, want ssl-warning", data.SSLClass)
+	}
+	if data.HeaderAudit == nil || data.HeaderAudit.Passed() {
+		t.Errorf("HeaderAudit = %+v, want failed audit", data.HeaderAudit)
+	}
+
+	unknown := server.getEndpointData(ctx, "http://unknown.example.com")
+	if unknown.StatusText != "" || unknown.UpdateText != "Never" || unknown.SSLText != "HTTP only" {
+		t.Errorf("unknown endpoint = %+v", unknown)
+	}
+}
+
+// TestHandleAPIHistory tests the per-endpoint status history endpoint
+func TestHandleAPIHistory(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	endpoint := "https://example.com/health"
+
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: endpoint, CheckedAt: now.Add(-2 * time.Hour), HasStatus: true, StatusCode: 200, Latency: 80 * time.Millisecond},
+		{Endpoint: endpoint, CheckedAt: now.Add(-30 * time.Minute), HasStatus: true, StatusCode: 503, Latency: 2 * time.Second},
+	})
+	server := &Server{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/endpoints/", server.handleAPIEndpoint)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantCodes  []int
+	}{
+		{"default last 24h", "/api/endpoints/" + url.PathEscape(endpoint) + "/history", http.StatusOK, []int{200, 503}},
+		{"since duration", "/api/endpoints/" + url.PathEscape(endpoint) + "/history?since=1h", http.StatusOK, []int{503}},
+		{"since time", "/api/endpoints/" + url.PathEscape(endpoint) + "/history?since=" + url.QueryEscape(now.Add(-3*time.Hour).Format(time.RFC3339)), http.StatusOK, []int{200, 503}},
+		{"unknown endpoint", "/api/endpoints/" + url.PathEscape("https://other.example.com") + "/history", http.StatusOK, []int{}},
+		{"invalid since", "/api/endpoints/" + url.PathEscape(endpoint) + "/history?since=yesterday", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCodes == nil {
+				return
+			}
+
+			var history []HistoryEntry
+			if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+				t.Fatal(err)
+			}
+			codes := make([]int, 0, len(history))
+			for _, entry := range history {
+				codes = append(codes, entry.StatusCode)
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("status codes = %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+// TestHandleAPIEndpointDetail tests the endpoint detail API with its renewal history
+func TestHandleAPIEndpointDetail(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	endpoint := "https://example.com"
+
+	for i, notAfter := range []time.Time{now.Add(30 * 24 * time.Hour), now.Add(30 * 24 * time.Hour), now.Add(90 * 24 * time.Hour)} {
+		cert := store.CertInfo{NotAfter: notAfter, Fingerprint: fmt.Sprintf("fp%d", i), State: store.CertStateValid}
+		st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: now.Add(time.Duration(i) * time.Hour), HasStatus: true, StatusCode: 200, Cert: &cert}})
+	}
+	server := &Server{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/endpoints/", server.handleAPIEndpoint)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/"+url.PathEscape(endpoint), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+
+	var response struct {
+		Endpoint   EndpointData     `json:"endpoint"`
+		SSLHistory []SSLObservation `json:"ssl_history"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Endpoint.Endpoint != endpoint || response.Endpoint.StatusCode != 200 {
+		t.Errorf("endpoint = %+v", response.Endpoint)
+	}
+	if len(response.SSLHistory) != 2 || response.SSLHistory[1].Fingerprint != "fp2" {
+		t.Errorf("ssl_history = %+v, want the first observation and the renewal", response.SSLHistory)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/"+url.PathEscape("https://unknown.example.com"), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown endpoint status = %d, want 404", rec.Code)
+	}
+}
+
+// TestHandleAPIEndpointByURL tests looking up an endpoint by its normalized URL
+func TestHandleAPIEndpointByURL(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	endpoint := "https://example.com/health"
+
+	cert := store.CertInfo{NotAfter: now.Add(30 * 24 * time.Hour), State: store.CertStateValid}
+	st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: now.Add(-2 * time.Hour), HasStatus: true, StatusCode: 200, Latency: 100 * time.Millisecond, Cert: &cert}})
+	st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: now.Add(-time.Hour), HasStatus: true, StatusCode: 503, Latency: 300 * time.Millisecond}})
+	server := &Server{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/endpoints/", server.handleAPIEndpoint)
+	mux.HandleFunc("GET /api/endpoints/detail", server.handleAPIEndpointByURL)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"query", "/api/endpoints/detail?url=" + url.QueryEscape(endpoint), http.StatusOK},
+		{"query without scheme", "/api/endpoints/detail?url=" + url.QueryEscape(" example.com/health "), http.StatusOK},
+		{"path without scheme", "/api/endpoints/" + url.PathEscape("example.com/health"), http.StatusOK},
+		{"other scheme", "/api/endpoints/detail?url=" + url.QueryEscape("http://example.com/health"), http.StatusNotFound},
+		{"trailing slash", "/api/endpoints/detail?url=" + url.QueryEscape(endpoint+"/"), http.StatusNotFound},
+		{"missing url", "/api/endpoints/detail", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Endpoint   EndpointData     `json:"endpoint"`
+				Error      string           `json:"error"`
+				History    HistorySummary   `json:"history"`
+				Timestamps map[string]int64 `json:"timestamps"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Endpoint.Endpoint != endpoint || response.Endpoint.CertInfo == nil {
+				t.Errorf("endpoint = %+v", response.Endpoint)
+			}
+			if response.Error != "HTTP 503 Service Unavailable" {
+				t.Errorf("error = %q", response.Error)
+			}
+			history := response.History
+			if history.Checks != 2 || history.Healthy != 1 || history.UptimePercent != 50 ||
+				history.AvgLatencyMs != 200 || history.MaxLatencyMs != 300 ||
+				history.LastFailure == nil || !history.LastFailure.Equal(now.Add(-time.Hour)) {
+				t.Errorf("history = %+v", history)
+			}
+			wantTimestamps := map[string]int64{
+				"status_updated": now.Add(-time.Hour).Unix(),
+				"ssl_expiry":     cert.NotAfter.Unix(),
+				"ssl_updated":    now.Add(-2 * time.Hour).Unix(),
+			}
+			if !maps.Equal(response.Timestamps, wantTimestamps) {
+				t.Errorf("timestamps = %v, want %v", response.Timestamps, wantTimestamps)
+			}
+		})
+	}
+}
+
+// TestHandleAPILatency tests the hourly latency rollup endpoint
+func TestHandleAPILatency(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	hour := time.Now().UTC().Truncate(time.Hour)
+	endpoint := "https://example.com"
+
+	st.SaveLatencyRollups(ctx, endpoint, []store.LatencyRollup{
+		{Hour: hour.Add(-10 * 24 * time.Hour), Count: 60, Min: 10 * time.Millisecond, Avg: 20 * time.Millisecond, P95: 40 * time.Millisecond, Max: 90 * time.Millisecond},
+		{Hour: hour.Add(-time.Hour), Count: 60, Min: 12 * time.Millisecond, Avg: 25 * time.Millisecond, P95: 48 * time.Millisecond, Max: 120 * time.Millisecond},
+	})
+	server := &Server{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/endpoints/", server.handleAPIEndpoint)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/"+url.PathEscape(endpoint)+"/latency", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+
+	var rollups []LatencyRollup
+	if err := json.Unmarshal(rec.Body.Bytes(), &rollups); err != nil {
+		t.Fatal(err)
+	}
+	want := []LatencyRollup{{Hour: hour.Add(-time.Hour), Count: 60, MinMs: 12, AvgMs: 25, P95Ms: 48, MaxMs: 120}}
+	if !slices.Equal(rollups, want) {
+		t.Errorf("rollups = %+v, want %+v (default since is 7d)", rollups, want)
+	}
+}
+
+// TestHandleAPIEvents tests reading the event stream over the API
+func TestHandleAPIEvents(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.PublishEvents(ctx, []store.Event{
+		{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: at},
+		{Endpoint: "https://b.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: at},
+		{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp, At: at.Add(time.Minute)},
+	})
+	server := &Server{store: st}
+
+	get := func(query string) (int, []store.StreamEvent) {
+		rec := httptest.NewRecorder()
+		server.handleAPIEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events"+query, nil))
+		var events []store.StreamEvent
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+		return rec.Code, events
+	}
+
+	code, all := get("")
+	if code != http.StatusOK || len(all) != 3 {
+		t.Fatalf("GET /api/events = %d with %d events, want 200 with 3", code, len(all))
+	}
+	if all[0].Endpoint != "https://a.example.com" || all[0].New != store.StatusDown || !all[0].At.Equal(at) {
+		t.Errorf("first event = %+v", all[0])
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{"?since=" + all[0].ID, http.StatusOK, []string{all[1].ID, all[2].ID}},
+		{"?endpoint=" + url.QueryEscape("https://a.example.com"), http.StatusOK, []string{all[0].ID, all[2].ID}},
+		{"?limit=1", http.StatusOK, []string{all[0].ID}},
+		{"?since=" + all[2].ID, http.StatusOK, []string{}},
+		{"?since=yesterday", http.StatusBadRequest, nil},
+		{"?since=1-x", http.StatusBadRequest, nil},
+		{"?limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		code, events := get(tt.query)
+		if code != tt.wantCode {
+			t.Errorf("GET /api/events%s status = %d, want %d", tt.query, code, tt.wantCode)
+			continue
+		}
+		ids := []string{}
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if tt.wantIDs != nil && !slices.Equal(ids, tt.wantIDs) {
+			t.Errorf("GET /api/events%s ids = %v, want %v", tt.query, ids, tt.wantIDs)
+		}
+	}
+
+	// Other storage backends have no event stream
+	rec := httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleAPIEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// TestHandleAPIAlerts tests the alert history API, its filters and the
+// Recent alerts section of the dashboard
+func TestHandleAPIAlerts(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	mr.SetTime(now.Add(-2 * time.Hour))
+	st.AddAlertRecord(ctx, store.AlertRecord{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown,
+		Notifier: "Slack", Route: "route payments", Delivered: true, Attempts: 1, At: now.Add(-2 * time.Hour)})
+	mr.SetTime(now)
+	st.AddAlertRecord(ctx, store.AlertRecord{Endpoint: "https://b.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning,
+		Notifier: "webhook", Delivered: false, Error: "server answered 503 <b>Service Unavailable</b>", Attempts: 3, At: now})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"", http.StatusOK, []string{"https://b.example.com", "https://a.example.com"}},
+		{"?endpoint=" + url.QueryEscape("https://a.example.com"), http.StatusOK, []string{"https://a.example.com"}},
+		{"?since=1h", http.StatusOK, []string{"https://b.example.com"}},
+		{"?since=" + url.QueryEscape(now.Add(-3*time.Hour).Format(time.RFC3339)), http.StatusOK, []string{"https://b.example.com", "https://a.example.com"}},
+		{"?limit=1", http.StatusOK, []string{"https://b.example.com"}},
+		{"?since=yesterday", http.StatusBadRequest, nil},
+		{"?limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.handleAPIAlerts(rec, httptest.NewRequest(http.MethodGet, "/api/alerts"+tt.query, nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("GET /api/alerts%s status = %d, want %d", tt.query, rec.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantNames == nil {
+			continue
+		}
+		var alerts []APIAlert
+		if err := json.Unmarshal(rec.Body.Bytes(), &alerts); err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		names := []string{}
+		for _, alert := range alerts {
+			names = append(names, alert.Endpoint)
+		}
+		if !slices.Equal(names, tt.wantNames) {
+			t.Errorf("GET /api/alerts%s endpoints = %v, want %v", tt.query, names, tt.wantNames)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleAPIAlerts(rec, httptest.NewRequest(http.MethodGet, "/api/alerts?limit=1", nil))
+	want := fmt.Sprintf(`[{"id":"%d-0","endpoint":"https://b.example.com","kind":"cert","old":"ok","new":"warning","notifier":"webhook",`+
+		`"delivered":false,"error":"server answered 503 \u003cb\u003eService Unavailable\u003c/b\u003e","attempts":3,"at":%q}]`+"\n",
+		now.UnixMilli(), now.Format(time.RFC3339))
+	if rec.Body.String() != want {
+		t.Errorf("response = %s, want %s", rec.Body, want)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<h2>Recent alerts</h2>",
+		"<td>status up → down</td>",
+		"<td>route payments</td>",
+		`<span class="alert-delivered">delivered</span>`,
+		`<span class="alert-failed" title="server answered 503 &lt;b&gt;Service Unavailable&lt;/b&gt;">failed after 3 attempts</span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleAPIAlerts(rec, httptest.NewRequest(http.MethodGet, "/api/alerts", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// TestHandleAPISLOs tests the SLO API and the SLOs section of the dashboard
+func TestHandleAPISLOs(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	now := time.Now().UTC().Truncate(time.Second)
+	st.SaveSLOStatuses(context.Background(), []store.SLOStatus{
+		{Tag: "prod", Objective: 99.5, Window: "30d", Endpoints: 3, Checks: 1000, Good: 999, Compliance: 99.9, BudgetRemaining: 0.8, BurnRate: 0.2, BurnRateThreshold: 14.4, UpdatedAt: now},
+		{Tag: "payments", Objective: 99.9, Window: "30d", ExcludeMaintenance: true, Endpoints: 1, Checks: 1000, Good: 990, Compliance: 99, BudgetRemaining: -9, BurnRate: 20, BurnRateThreshold: 14.4, Burning: true, UpdatedAt: now},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleAPISLOs(rec, httptest.NewRequest(http.MethodGet, "/api/slo", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/slo status = %d", rec.Code)
+	}
+	var slos []APISLO
+	if err := json.Unmarshal(rec.Body.Bytes(), &slos); err != nil {
+		t.Fatal(err)
+	}
+	want := APISLO{Tag: "payments", Objective: 99.9, Window: "30d", ExcludeMaintenance: true, Endpoints: 1, Checks: 1000, Good: 990,
+		Compliance: 99, BudgetRemaining: -9, BurnRate: 20, BurnRateThreshold: 14.4, Burning: true, UpdatedAt: now.Format(time.RFC3339)}
+	if len(slos) != 2 || slos[0] != want || slos[1].Tag != "prod" {
+		t.Errorf("GET /api/slo = %+v, want payments %+v and prod", slos, want)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<h2>SLOs</h2>",
+		`<a href="/?tag=payments">payments</a>`,
+		"<td>99.9% over 30d</td>",
+		`<td title="990 of 1000 checks good">99.000%</td>`,
+		`<td class="slo-burning">-900.0%</td>`,
+		`<span class="slo-burning" title="at or above 14.4">20.0x burning</span>`,
+		"<td>80.0%</td>",
+		"<td>0.2x</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleAPISLOs(rec, httptest.NewRequest(http.MethodGet, "/api/slo", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// TestHandleAPIPercentiles tests the latency percentiles of an endpoint,
+// on their own and in its details
+func TestHandleAPIPercentiles(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	st.SetLatencyBuckets([]time.Duration{100 * time.Millisecond, time.Second})
+	now := time.Now().UTC()
+	endpoint := "https://example.com"
+	var results []store.Result
+	for i := range 10 {
+		latency := 50 * time.Millisecond
+		if i == 9 {
+			latency = 550 * time.Millisecond
+		}
+		results = append(results, store.Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, Latency: latency})
+	}
+	st.SaveResults(context.Background(), results)
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/example.com/percentiles", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET percentiles status = %d %s", rec.Code, rec.Body.String())
+	}
+	var percentiles []LatencyPercentiles
+	if err := json.Unmarshal(rec.Body.Bytes(), &percentiles); err != nil {
+		t.Fatal(err)
+	}
+	// The 95th and 99th percentiles fall halfway into and near the top of the
+	// 100ms-1s bucket
+	if len(percentiles) != 2 || percentiles[0].Window != "1h" || percentiles[1].Window != "24h" {
+		t.Fatalf("GET percentiles = %+v, want 1h and 24h", percentiles)
+	}
+	for _, p := range percentiles {
+		if p.Count != 10 || p.P50Ms != 56 || p.P95Ms != 550 || p.P99Ms != 910 {
+			t.Errorf("%s percentiles = %+v, want 10 checks at p50 56ms, p95 550ms and p99 910ms", p.Window, p)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/detail?url=example.com", nil))
+	var detail EndpointDetail
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(detail.LatencyPercentiles, percentiles) {
+		t.Errorf("detail latency_percentiles = %+v, want %+v", detail.LatencyPercentiles, percentiles)
+	}
+
+	rec = httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleAPIPercentiles(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/example.com/percentiles", nil), endpoint)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// outageStore fails reads and pings while down, or for the next failures reads
+type outageStore struct {
+	*store.MemoryStore
+	down     bool
+	failures int
+	reads    int
+}
+
+func (s *outageStore) ListEndpointData(ctx context.Context) ([]store.EndpointData, error) {
+	s.reads++
+	if s.down || s.failures > 0 {
+		s.failures--
+		return nil, fmt.Errorf("connection refused")
+	}
+	return s.MemoryStore.ListEndpointData(ctx)
+}
+
+func (s *outageStore) Ping(ctx context.Context) error {
+	if s.down {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+// TestStaleData tests serving cached data while storage is unavailable
+func TestStaleData(t *testing.T) {
+	st := &outageStore{MemoryStore: store.NewMemoryStore(), down: true}
+	st.SetStatus(context.Background(), "https://example.com", 200, time.Now())
+
+	// Starting while storage is down works, but there is nothing to show yet
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatalf("NewServer() with storage down = %v", err)
+	}
+	server.retryBackoff = 0
+	rec := httptest.NewRecorder()
+	server.handleAPIEndpoints(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status with no cached data = %d, want 500", rec.Code)
+	}
+	if st.reads != readAttempts {
+		t.Errorf("read %d times, want %d", st.reads, readAttempts)
+	}
+
+	// A transient error is retried instead of serving stale data
+	st.down = false
+	st.failures = readAttempts - 1
+	rec = httptest.NewRecorder()
+	server.handleAPIEndpoints(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Warning") != "" {
+		t.Errorf("after transient error: status = %d, Warning = %q, want 200 without warning", rec.Code, rec.Header().Get("Warning"))
+	}
+
+	// An outage serves the cached data, flagged as stale
+	st.down = true
+	rec = httptest.NewRecorder()
+	server.handleAPIEndpoints(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status during outage = %d, want 200", rec.Code)
+	}
+	if warning := rec.Header().Get("Warning"); !strings.HasPrefix(warning, `110 - "Response is stale: Storage unavailable since `) {
+		t.Errorf("Warning = %q", warning)
+	}
+	var response struct {
+		Total      int       `json:"total"`
+		StaleSince time.Time `json:"stale_since"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Total != 1 || response.StaleSince.IsZero() {
+		t.Errorf("response = %+v, want the cached endpoint and stale_since", response)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Data may be stale (Storage unavailable since ") {
+		t.Errorf("index during outage: status = %d, stale banner missing", rec.Code)
+	}
+
+	// Recovery clears the stale state
+	st.down = false
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), "Data may be stale") {
+		t.Error("stale banner still shown after storage recovered")
+	}
+}
+
+// TestSchemaVersionCheck tests that the dashboard refuses Redis data written
+// by a newer release
+func TestSchemaVersionCheck(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	t.Cleanup(func() { st.Close() })
+
+	mr.Set(store.SchemaVersionKey, strconv.Itoa(store.CurrentSchemaVersion))
+	if _, err := NewServer(Config{}, st); err != nil {
+		t.Errorf("NewServer() with the current schema = %v", err)
+	}
+
+	mr.Set(store.SchemaVersionKey, strconv.Itoa(store.CurrentSchemaVersion+1))
+	if _, err := NewServer(Config{}, st); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("NewServer() with a newer schema = %v, want a schema version error", err)
+	}
+}
+
+// unresponsiveListener returns the address of a server that accepts
+// connections but never answers, like Redis behind a network partition
+func unresponsiveListener(t testing.TB) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return ln.Addr().String()
+}
+
+// TestStoreTimeout tests that requests against a hung Redis give up after
+// StoreTimeout with a 504, still serving cached data when there is some
+func TestStoreTimeout(t *testing.T) {
+	config := Config{RedisAddr: unresponsiveListener(t), StoreTimeout: 100 * time.Millisecond}
+	st, err := newRedisStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	start := time.Now()
+	server, err := NewServer(config, st)
+	if err != nil {
+		t.Fatalf("NewServer() with a hung Redis = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NewServer() took %s with a hung Redis", elapsed)
+	}
+
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("GET %s took %s, want about %s", target, elapsed, config.StoreTimeout)
+		}
+		return rec
+	}
+
+	// Nothing cached yet
+	for _, tt := range []struct {
+		target  string
+		handler http.HandlerFunc
+	}{
+		{"/", server.handleIndex},
+		{"/api/endpoints", server.handleAPIEndpoints},
+		{"/api/expiring", server.handleAPIExpiring},
+		{"/api/endpoints/https%3A%2F%2Fexample.com/history", server.handleAPIEndpoint},
+	} {
+		if rec := get(tt.handler, tt.target); rec.Code != http.StatusGatewayTimeout {
+			t.Errorf("GET %s = %d, want 504", tt.target, rec.Code)
+		}
+	}
+
+	// Cached data is served with the 504
+	server.cache.data = []store.EndpointData{{Endpoint: "https://example.com", HasStatus: true, StatusCode: 200, StatusUpdated: time.Now()}}
+	server.cache.readAt = time.Now()
+	rec := get(server.handleAPIEndpoints, "/api/endpoints")
+	if rec.Code != http.StatusGatewayTimeout || rec.Header().Get("Warning") == "" {
+		t.Errorf("GET /api/endpoints = %d, Warning = %q, want 504 with a warning", rec.Code, rec.Header().Get("Warning"))
+	}
+	var response struct {
+		Endpoints []EndpointData `json:"endpoints"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || len(response.Endpoints) != 1 {
+		t.Errorf("GET /api/endpoints body = %s, want the cached endpoint", rec.Body.String())
+	}
+	rec = get(server.handleIndex, "/")
+	if rec.Code != http.StatusGatewayTimeout || !strings.Contains(rec.Body.String(), "https://example.com") {
+		t.Errorf("GET / = %d, want 504 showing the cached endpoint", rec.Code)
+	}
+}
+
+// TestHandleAPIPool tests reporting the Redis connection pool
+func TestHandleAPIPool(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := Config{RedisAddr: mr.Addr(), RedisPool: store.RedisPool{PoolSize: 7}}
+	st, err := newRedisStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	st.Ping(context.Background())
+
+	rec := httptest.NewRecorder()
+	(&Server{store: st}).handleAPIPool(rec, httptest.NewRequest(http.MethodGet, "/api/pool", nil))
+	var stats store.PoolStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("GET /api/pool = %d %s", rec.Code, rec.Body.String())
+	}
+	if stats.PoolSize != 7 || stats.TotalConns != 1 {
+		t.Errorf("GET /api/pool = %+v, want pool size 7 with one connection", stats)
+	}
+
+	rec = httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleAPIPool(rec, httptest.NewRequest(http.MethodGet, "/api/pool", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("GET /api/pool without Redis = %d, want 501", rec.Code)
+	}
+}
+
+// TestLiveSnapshot tests serving endpoints from the snapshot kept by
+// keyspace notifications, and falling back to Redis while it is out of sync
+func TestLiveSnapshot(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	defer st.Close()
+	ctx := context.Background()
+	now := time.Now()
+	save := func(endpoint string, status int) {
+		t.Helper()
+		if err := st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: status}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("https://a.example.com", 200)
+	server := &Server{store: st}
+	server.live = &liveSnapshot{server: server}
+	read := func() map[string]int {
+		t.Helper()
+		data, _, err := server.getAllEndpointData(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, endpoint := range data {
+			got[endpoint.Endpoint] = endpoint.StatusCode
+		}
+		return got
+	}
+
+	// Not synced yet: reads go to Redis
+	save("https://a.example.com", 301)
+	if got := read(); got["https://a.example.com"] != 301 {
+		t.Errorf("before the first resync = %v, want Redis data", got)
+	}
+
+	// Synced: reads are served from memory and only follow notified changes
+	server.live.Resync()
+	save("https://a.example.com", 500)
+	if got := read(); got["https://a.example.com"] != 301 {
+		t.Errorf("unnotified write was read from Redis: %v", got)
+	}
+	server.live.Changed("https://a.example.com")
+	save("https://b.example.com", 200)
+	server.live.Changed("https://b.example.com")
+	if got := read(); !maps.Equal(got, map[string]int{"https://a.example.com": 500, "https://b.example.com": 200}) {
+		t.Errorf("after changes = %v", got)
+	}
+	mr.Del(store.Keys{}.Endpoint("https://b.example.com"))
+	server.live.Changed("https://b.example.com")
+	if got := read(); !maps.Equal(got, map[string]int{"https://a.example.com": 500}) {
+		t.Errorf("after the hash was deleted = %v", got)
+	}
+
+	// A lost subscription falls back to Redis until the next resync
+	server.live.Lost(errors.New("connection reset"))
+	save("https://a.example.com", 204)
+	if got := read(); got["https://a.example.com"] != 204 {
+		t.Errorf("after the subscription was lost = %v, want Redis data", got)
+	}
+
+	// A failed read puts the snapshot out of sync, and the next change rebuilds it
+	mr.SetError("LOADING")
+	server.live.Resync()
+	if _, synced := server.live.endpointData(); synced {
+		t.Error("snapshot in sync after a failed resync")
+	}
+	mr.SetError("")
+	server.live.Changed("https://a.example.com")
+	if _, synced := server.live.endpointData(); !synced {
+		t.Error("snapshot not rebuilt by the next change")
+	}
+}
+
+// TestAPIEndpointJSON pins the JSON shape of /api/v1/endpoints entries
+func TestAPIEndpointJSON(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	checkedAt := time.Date(2024, 3, 1, 11, 59, 30, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name   string
+		stored store.EndpointData
+		want   string
+	}{
+		{
+			name:   "no checks yet",
+			stored: store.EndpointData{Endpoint: "https://new.example.com"},
+			want:   `{"endpoint":"https://new.example.com","https":true}`,
+		},
+		{
+			name:   "connection failed",
+			stored: store.EndpointData{Endpoint: "http://down.example.com", HasStatus: true, StatusCode: 0, StatusUpdated: checkedAt},
+			want:   `{"endpoint":"http://down.example.com","https":false,"status_code":0,"status_updated_at":"2024-03-01T10:59:30Z","alert_state":"down"}`,
+		},
+		{
+			name: "full",
+			stored: store.EndpointData{
+				Endpoint:      "https://example.com",
+				HasStatus:     true,
+				StatusCode:    200,
+				StatusUpdated: checkedAt,
+				SSLExpiration: now.Add(10*24*time.Hour + time.Hour),
+				SSLUpdated:    checkedAt,
+				CertInfo: &store.CertInfo{
+					NotBefore:    now.Add(-80 * 24 * time.Hour),
+					NotAfter:     now.Add(10*24*time.Hour + time.Hour),
+					Subject:      "CN=example.com",
+					Issuer:       "CN=R3,O=Let's Encrypt",
+					SerialNumber: "3a",
+					Fingerprint:  "ab12",
+					State:        store.CertStateValid,
+					RemoteAddr:   "[2001:db8::10]:443",
+				},
+				HeaderAudit: &store.HeaderAudit{
+					Headers:  map[string]string{"Strict-Transport-Security": "max-age=60"},
+					Failures: []string{"Strict-Transport-Security max-age below 31536000"},
+					Updated:  checkedAt,
+				},
+				Captured:    &store.CapturedHeaders{Headers: map[string]string{"Server": "nginx", "Via": "1.1 varnish"}, Changed: checkedAt},
+				Uptime:      []store.Uptime{{Window: "24h", Checks: 1440, Up: 1439}, {Window: "7d", Checks: 0, Up: 0}, {Window: "30d", Checks: 3, Up: 2}},
+				Tags:        []string{"prod", "payments"},
+				Name:        "Payments API",
+				Description: "Card payments of the EU shops",
+				RemoteAddr:  "192.0.2.10:443",
+				CheckedBy:   "eu-west-1",
+
+				SSLCheckedBy: "us-east-1",
+			},
+			want: `{"endpoint":"https://example.com","name":"Payments API","description":"Card payments of the EU shops","https":true,"status_code":200,"status_updated_at":"2024-03-01T10:59:30Z","remote_addr":"192.0.2.10:443","checked_by":"eu-west-1",` +
+				`"alert_state":"warning","ssl_expiration":"2024-03-11T13:00:00Z","days_left":11,"ssl_updated_at":"2024-03-01T10:59:30Z","ssl_checked_by":"us-east-1",` +
+				`"certificate":{"not_before":"2023-12-12T12:00:00Z","not_after":"2024-03-11T13:00:00Z","subject":"CN=example.com",` +
+				`"issuer":"CN=R3,O=Let's Encrypt","serial_number":"3a","fingerprint":"ab12","state":"valid","remote_addr":"[2001:db8::10]:443"},` +
+				`"header_audit":{"passed":false,"headers":{"Strict-Transport-Security":"max-age=60"},` +
+				`"failures":["Strict-Transport-Security max-age below 31536000"],"updated_at":"2024-03-01T10:59:30Z"},` +
+				`"captured_headers":{"headers":{"Server":"nginx","Via":"1.1 varnish"},"changed_at":"2024-03-01T10:59:30Z"},` +
+				`"tags":["prod","payments"],"uptime":{"24h":99.9,"30d":66.6,"7d":null}}`,
+		},
+		{
+			name:   "expired certificate",
+			stored: store.EndpointData{Endpoint: "https://old.example.com", SSLExpiration: now.Add(-36 * time.Hour), SSLUpdated: now},
+			want:   `{"endpoint":"https://old.example.com","https":true,"alert_state":"expired","ssl_expiration":"2024-02-29T00:00:00Z","days_left":-2,"ssl_updated_at":"2024-03-01T12:00:00Z"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(newAPIEndpoint(newEndpointData(tt.stored, now)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSON =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHandleAPIv1Endpoints pins the /api/v1/endpoints response, checks the
+// unversioned path keeps its output with a deprecation notice and that both
+// apply the query filters
+func TestHandleAPIv1Endpoints(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	checkedAt := time.Now().UTC().Truncate(time.Second)
+	st.SaveResults(ctx, []store.Result{{Endpoint: "http://example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503}})
+	server := &Server{store: st}
+
+	rec := httptest.NewRecorder()
+	server.handleAPIv1Endpoints(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil))
+	want := fmt.Sprintf(`{"endpoints":[{"endpoint":"http://example.com","https":false,"status_code":503,"status_updated_at":%q,"alert_state":"down"}],"total":1}`+"\n",
+		checkedAt.Format(time.RFC3339))
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("response = %d %s, want 200 %s", rec.Code, rec.Body, want)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleAPIEndpoints(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints", nil))
+	if rec.Header().Get("Deprecation") != "true" || !strings.Contains(rec.Header().Get("Link"), "/api/v1/endpoints") {
+		t.Errorf("deprecated path headers = %v", rec.Header())
+	}
+	if !strings.Contains(rec.Body.String(), `"StatusClass":"status-server-error"`) {
+		t.Errorf("deprecated path body changed: %s", rec.Body)
+	}
+
+	for _, handler := range []http.HandlerFunc{server.handleAPIv1Endpoints, server.handleAPIEndpoints} {
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints?status=ok", nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":0`) {
+			t.Errorf("filtered response = %d %s, want no endpoints", rec.Code, rec.Body)
+		}
+		rec = httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints?status=bogus", nil))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "use ok, error, 4xx, 5xx") {
+			t.Errorf("invalid filter response = %d %s, want 400 with the valid values", rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestFilterEndpoints tests the endpoint list query parameters
+func TestFilterEndpoints(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	checked := func(ago time.Duration) time.Time { return now.Add(-ago) }
+	endpoints := []EndpointData{}
+	for _, stored := range []store.EndpointData{
+		{Endpoint: "https://ok.example.com", HasStatus: true, StatusCode: 200, StatusUpdated: checked(time.Minute), SSLExpiration: now.Add(90 * 24 * time.Hour), SSLUpdated: checked(time.Minute), Tags: []string{"prod", "payments"}, Name: "Card Gateway"},
+		{Endpoint: "https://moved.example.com", HasStatus: true, StatusCode: 301, StatusUpdated: checked(time.Minute), SSLExpiration: now.Add(20 * 24 * time.Hour), SSLUpdated: checked(3 * time.Hour), Tags: []string{"staging"}},
+		{Endpoint: "https://missing.example.com", HasStatus: true, StatusCode: 404, StatusUpdated: checked(2 * time.Hour), SSLExpiration: now.Add(3 * 24 * time.Hour), SSLUpdated: checked(2 * time.Hour)},
+		{Endpoint: "https://broken.example.com", HasStatus: true, StatusCode: 502, StatusUpdated: checked(time.Minute), SSLExpiration: now.Add(-2 * 24 * time.Hour), SSLUpdated: checked(time.Minute)},
+		{Endpoint: "http://down.example.com", HasStatus: true, StatusCode: 0, StatusUpdated: checked(3 * 24 * time.Hour)},
+		{Endpoint: "http://nxdomain.example.com", HasStatus: true, StatusCode: -1, StatusUpdated: checked(time.Minute)},
+		{Endpoint: "https://new.example.com"},
+	} {
+		endpoints = append(endpoints, newEndpointData(stored, now))
+	}
+
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr string
+	}{
+		{"", []string{"ok", "moved", "missing", "broken", "down", "nxdomain", "new"}, ""},
+		{"status=ok", []string{"ok", "moved"}, ""},
+		{"status=error", []string{"down", "nxdomain"}, ""},
+		{"status=4xx,5xx", []string{"missing", "broken"}, ""},
+		{"ssl=expired", []string{"broken"}, ""},
+		{"ssl=critical,warning", []string{"moved", "missing"}, ""},
+		{"https_only=true", []string{"ok", "moved", "missing", "broken", "new"}, ""},
+		{"https_only=false", []string{"ok", "moved", "missing", "broken", "down", "nxdomain", "new"}, ""},
+		{"updated_before=1h", []string{"missing", "down", "new"}, ""},
+		{"updated_before=2d", []string{"down", "new"}, ""},
+		{"status=error,4xx,5xx&https_only=true", []string{"missing", "broken"}, ""},
+		{"status=ok&ssl=warning", []string{"moved"}, ""},
+		{"tag=payments", []string{"ok"}, ""},
+		{"tag=PROD,staging", []string{"ok", "moved"}, ""},
+		{"tag=unknown", nil, ""},
+		{"q=MISSING", []string{"missing"}, ""},
+		{"q=card gate", []string{"ok"}, ""},
+		{"status=down", nil, `invalid status value "down"`},
+		{"ssl=soon", nil, `invalid ssl value "soon"`},
+		{"https_only=yes", nil, `invalid https_only value "yes"`},
+		{"updated_before=yesterday", nil, `invalid updated_before value "yesterday"`},
+		{"updated_before=-1h", nil, `invalid updated_before value "-1h"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := filterEndpoints(query, endpoints, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, endpoint := range got {
+				u, _ := url.Parse(endpoint.Endpoint)
+				names = append(names, strings.TrimSuffix(u.Host, ".example.com"))
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("filterEndpoints(%s) = %v, want %v", tt.query, names, tt.want)
+			}
+		})
+	}
+}
+
+// TestHandleIndexSearch tests the dashboard search and sort form and its counts
+func TestHandleIndexSearch(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, result := range []store.Result{
+		{Endpoint: "https://api.eu-west.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://api.EU-WEST.example.com/v2", CheckedAt: now, HasStatus: true, StatusCode: 500},
+		{Endpoint: "https://api.us-east.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Name: "US Gateway", Description: "Serves the Americas"},
+	} {
+		st.SaveResults(ctx, []store.Result{result})
+	}
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       []string
+		notWant    []string
+	}{
+		{"", http.StatusOK, []string{`<span class="endpoint-name" title="https://api.us-east.example.com">US Gateway</span>`, `<div class="endpoint-desc">Serves the Americas</div>`, "Total Endpoints", `value=""`}, []string{"of 3", `class="no-matches"`}},
+		{"?q=us+gateway", http.StatusOK, []string{"US Gateway", "1 <span class=\"stat-total\">of 3</span>"}, []string{"api.eu-west.example.com"}},
+		{"?q=eu-west", http.StatusOK, []string{"api.eu-west", "api.EU-WEST", "Matching Endpoints", "2 <span class=\"stat-total\">of 3</span>", "1 <span class=\"stat-total\">of 2</span>", `value="eu-west"`}, []string{"api.us-east"}},
+		{"?q=ap-south", http.StatusOK, []string{`No endpoints match "ap-south"`, "0 <span class=\"stat-total\">of 3</span>"}, []string{"api.eu-west.example.com"}},
+		{"?q=%3Cscript%3E", http.StatusOK, []string{`value="&lt;script&gt;"`}, []string{"<script>alert"}},
+		{"?status=bogus", http.StatusBadRequest, []string{"invalid status value"}, nil},
+		{"?sort=status&order=desc", http.StatusOK, []string{`value="status" selected`, `value="desc" selected`}, nil},
+		{"?sort=bogus", http.StatusBadRequest, []string{"invalid sort value"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("page contains %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+// TestIDNEndpoints tests that an internationalized endpoint is stored under
+// its punycode URL and shown and found by its Unicode one
+func TestIDNEndpoints(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	endpoint := store.NormalizeEndpoint("münchen.example/status")
+	if err := st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 200}}); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("endpoint:https://xn--mnchen-3ya.example/status") {
+		t.Fatalf("keys = %v, want the punycode endpoint key", mr.Keys())
+	}
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"", "?q=" + url.QueryEscape("MÜNCHEN"), "?q=xn--mnchen"} {
+		rec := httptest.NewRecorder()
+		server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/"+query, nil))
+		if body := rec.Body.String(); !strings.Contains(body, `endpoint-cell">https://münchen.example/status<`) || !strings.Contains(body, `data-url="https://xn--mnchen-3ya.example/status"`) {
+			t.Errorf("GET /%s does not show the Unicode URL with the stored one as data-url", query)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleAPIEndpointByURL(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints/detail?url="+url.QueryEscape("https://münchen.example/status"), nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"Endpoint":"https://xn--mnchen-3ya.example/status"`) {
+		t.Errorf("GET /api/endpoints/detail by Unicode URL = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestSortEndpoints tests the sort and order parameters and that endpoints
+// without the sorted value stay last in both directions
+func TestSortEndpoints(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	endpoints := []EndpointData{}
+	for _, stored := range []store.EndpointData{
+		{Endpoint: "https://b.example.com", HasStatus: true, StatusCode: 200, StatusUpdated: now.Add(-time.Hour), SSLExpiration: now.Add(40 * 24 * time.Hour)},
+		{Endpoint: "http://c.example.com", HasStatus: true, StatusCode: 503, StatusUpdated: now.Add(-time.Minute)},
+		{Endpoint: "https://d.example.com", Name: "Admin portal"},
+		{Endpoint: "https://a.example.com", HasStatus: true, StatusCode: 0, StatusUpdated: now.Add(-2 * time.Hour), SSLExpiration: now.Add(5 * 24 * time.Hour)},
+		{Endpoint: "https://e.example.com", HasStatus: true, StatusCode: 200, StatusUpdated: now.Add(-3 * time.Hour), SSLExpiration: now.Add(40 * 24 * time.Hour)},
+	} {
+		endpoints = append(endpoints, newEndpointData(stored, now))
+	}
+
+	tests := []struct {
+		query   string
+		want    string
+		wantErr string
+	}{
+		{"", "a b e c d", ""},
+		{"sort=ssl&order=desc", "b e a c d", ""},
+		{"sort=status", "a b e c d", ""},
+		{"sort=status&order=desc", "c b e a d", ""},
+		{"sort=endpoint", "a d b c e", ""}, // d by its name, Admin portal
+		{"sort=endpoint&order=desc", "e c b d a", ""},
+		{"sort=updated", "c b a e d", ""},
+		{"sort=updated&order=desc", "e a b c d", ""},
+		{"sort=SSL&order=DESC", "b e a c d", ""},
+		{"sort=expiry", "", `invalid sort value "expiry" (use ssl, status, endpoint, updated)`},
+		{"order=down", "", `invalid order value "down" (use asc or desc)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sorted := slices.Clone(endpoints)
+			err = sortEndpoints(query, sorted)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, endpoint := range sorted {
+				u, _ := url.Parse(endpoint.Endpoint)
+				names = append(names, strings.TrimSuffix(u.Host, ".example.com"))
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("sortEndpoints(%s) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStableOrder tests that endpoints with equal sort values, read from a
+// store without an order of its own, are listed the same way on every
+// request, by URL
+func TestStableOrder(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	var results []store.Result
+	for _, host := range []string{"d", "b", "f", "a", "e", "c"} {
+		results = append(results, store.Result{Endpoint: "https://" + host + ".example.com", CheckedAt: now, HasStatus: true, StatusCode: 200,
+			Cert: &store.CertInfo{NotAfter: now.Add(40 * 24 * time.Hour), State: store.CertStateValid}})
+		results = append(results, store.Result{Endpoint: "http://" + host + ".example.com", CheckedAt: now, HasStatus: true, StatusCode: 200})
+	}
+	st.SaveResults(context.Background(), results)
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cell := regexp.MustCompile(`<td class="endpoint-cell">([^<]+)`)
+	generatedAt := regexp.MustCompile(`"generated_at":"[^"]*"`)
+	order := func(path string) string {
+		rec := httptest.NewRecorder()
+		server.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, rec.Code)
+		}
+		if strings.HasPrefix(path, "/api/") {
+			return generatedAt.ReplaceAllString(rec.Body.String(), "")
+		}
+		var endpoints []string
+		for _, match := range cell.FindAllStringSubmatch(rec.Body.String(), -1) {
+			endpoints = append(endpoints, match[1])
+		}
+		return strings.Join(endpoints, " ")
+	}
+
+	first := order("/")
+	want := "https://a.example.com https://b.example.com https://c.example.com https://d.example.com https://e.example.com https://f.example.com " +
+		"http://a.example.com http://b.example.com http://c.example.com http://d.example.com http://e.example.com http://f.example.com"
+	if first != want {
+		t.Errorf("page order = %s, want %s", first, want)
+	}
+	for _, path := range []string{"/", "/?sort=status", "/api/v1/endpoints", "/api/endpoints?sort=ssl&order=desc", "/api/summary"} {
+		first := order(path)
+		for range 10 {
+			if got := order(path); got != first {
+				t.Fatalf("GET %s changed order:\n%s\nthen\n%s", path, first, got)
+			}
+		}
+	}
+}
+
+// TestSelectFields tests the fields parameter of both endpoint lists
+func TestSelectFields(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	checkedAt := time.Now().UTC().Truncate(time.Second)
+	st.SaveResults(ctx, []store.Result{{Endpoint: "http://example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503}})
+	server := &Server{store: st}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		query      string
+		wantStatus int
+		want       string
+	}{
+		{"v1", server.handleAPIv1Endpoints, "fields=endpoint,status_code,days_left", http.StatusOK,
+			`{"endpoints":[{"days_left":null,"endpoint":"http://example.com","status_code":503}],"total":1}`},
+		{"v1 spaces and duplicates", server.handleAPIv1Endpoints, "fields=endpoint,+endpoint", http.StatusOK,
+			`{"endpoints":[{"endpoint":"http://example.com"}],"total":1}`},
+		{"v1 with filter", server.handleAPIv1Endpoints, "fields=endpoint&status=ok", http.StatusOK,
+			`{"endpoints":[],"total":0}`},
+		{"v1 unknown field", server.handleAPIv1Endpoints, "fields=endpoint,status_class", http.StatusBadRequest,
+			`unknown field "status_class" (valid fields: acknowledgement, alert_state, captured_headers, certificate, checked_by, days_left, description, endpoint, error_at, error_class, error_message, header_audit, https, in_maintenance, name, pause, paused_by_schedule, remote_addr, ssl_checked_by, ssl_expiration, ssl_updated_at, stale, status_code, status_updated_at, tags, uptime)`},
+		{"unversioned", server.handleAPIEndpoints, "fields=endpoint,status_class,days_left", http.StatusOK,
+			`{"endpoints":[{"days_left":null,"endpoint":"http://example.com","status_class":"status-server-error"}],"total":1}`},
+		{"unversioned unknown field", server.handleAPIEndpoints, "fields=StatusClass", http.StatusBadRequest,
+			`unknown field "StatusClass" (valid fields: ack, alert_state, captured, cert_info, checked_by, days_left, description, endpoint, error, error_text, header_audit, in_maintenance, is_https, last_ssl_update, last_status_update, name, pause, paused_by_schedule, remote_addr, ssl_checked_by, ssl_class, ssl_expiration, ssl_text, stale, status_class, status_code, status_text, tags, update_text, uptime)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, "/api/endpoints?"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSummarizeEndpoints tests the aggregation shared by /api/summary and the
+// dashboard header
+func TestSummarizeEndpoints(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	notYetValid := &store.CertInfo{NotBefore: now.Add(24 * time.Hour), NotAfter: now.Add(90 * 24 * time.Hour), State: store.CertStateNotYetValid}
+	var endpoints []EndpointData
+	for _, stored := range []store.EndpointData{
+		{Endpoint: "https://ok.example.com", HasStatus: true, StatusCode: 200, StatusUpdated: now.Add(-time.Minute), SSLExpiration: now.Add(90 * 24 * time.Hour), SSLUpdated: now.Add(-time.Minute)},
+		{Endpoint: "https://soon.example.com", HasStatus: true, StatusCode: 200, StatusUpdated: now.Add(-time.Minute), SSLExpiration: now.Add(3*24*time.Hour + time.Hour), SSLUpdated: now.Add(-2 * time.Hour)},
+		{Endpoint: "https://future.example.com", HasStatus: true, StatusCode: 301, StatusUpdated: now.Add(-time.Minute), SSLExpiration: notYetValid.NotAfter, SSLUpdated: now.Add(-time.Minute), CertInfo: notYetValid},
+		{Endpoint: "https://broken.example.com", HasStatus: true, StatusCode: 502, StatusUpdated: now.Add(-3 * time.Hour)},
+		{Endpoint: "http://down.example.com", HasStatus: true, StatusCode: 0, StatusUpdated: now.Add(-time.Minute)},
+		{Endpoint: "http://missing.example.com", HasStatus: true, StatusCode: 404, StatusUpdated: now.Add(-time.Minute)},
+	} {
+		endpoints = append(endpoints, newEndpointData(stored, now))
+	}
+
+	got := summarizeEndpoints(endpoints, now)
+	want := Summary{
+		GeneratedAt:   now,
+		Total:         6,
+		Healthy:       2,
+		SSLWarning:    2,
+		Errors:        3,
+		StatusClasses: map[string]int{"success": 2, "redirect": 1, "server-error": 1, "error": 1, "client-error": 1},
+		SSLClasses:    map[string]int{"ok": 1, "critical": 2},
+		SoonestExpiry: &SummaryExpiry{Endpoint: "https://soon.example.com", DaysLeft: 4},
+		OldestUpdate:  &SummaryUpdate{Endpoint: "https://broken.example.com", UpdatedAt: now.Add(-3 * time.Hour)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeEndpoints() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	empty := summarizeEndpoints(nil, now)
+	if empty.Total != 0 || empty.SoonestExpiry != nil || empty.OldestUpdate != nil {
+		t.Errorf("summary of no endpoints = %+v", empty)
+	}
+}
+
+// TestHandleAPISummary tests that /api/summary honors the endpoint filters
+func TestHandleAPISummary(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://a.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://b.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+	})
+	server := &Server{store: st}
+
+	rec := httptest.NewRecorder()
+	server.handleAPISummary(rec, httptest.NewRequest(http.MethodGet, "/api/summary?status=5xx", nil))
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || summary.Total != 1 || summary.Errors != 1 || summary.GeneratedAt.IsZero() {
+		t.Errorf("summary = %d %+v", rec.Code, summary)
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleAPISummary(rec, httptest.NewRequest(http.MethodGet, "/api/summary?ssl=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid filter status = %d, want 400", rec.Code)
+	}
+}
+
+// TestHealthEndpoints tests that /healthz always answers and /readyz follows
+// the storage
+func TestHealthEndpoints(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}))
+	defer st.Close()
+	server := &Server{store: st}
+
+	get := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get(server.handleHealthz, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", rec.Code)
+	}
+	if rec := get(server.handleReadyz, "/readyz"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ready"`) {
+		t.Errorf("/readyz = %d %s, want 200 ready", rec.Code, rec.Body)
+	}
+
+	mr.Close()
+	if rec := get(server.handleHealthz, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz with Redis down = %d, want 200", rec.Code)
+	}
+	rec := get(server.handleReadyz, "/readyz")
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || response["status"] != "unavailable" || !strings.HasPrefix(response["storage"], "Redis: ") {
+		t.Errorf("/readyz with Redis down = %d %v, want 503 with the error", rec.Code, response)
+	}
+
+	// A hung Redis fails readiness within readyTimeout
+	hung := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: unresponsiveListener(t), ContextTimeoutEnabled: true}))
+	defer hung.Close()
+	start := time.Now()
+	if rec := get((&Server{store: hung}).handleReadyz, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz with Redis hung = %d, want 503", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*readyTimeout {
+		t.Errorf("/readyz with Redis hung took %s", elapsed)
+	}
+}
+
+// TestHandleMetrics tests the Prometheus exposition of the stored endpoints
+func TestHandleMetrics(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	cert := func(days int) *store.CertInfo {
+		return &store.CertInfo{NotAfter: now.Add(time.Duration(days)*24*time.Hour + time.Hour), State: store.CertStateValid}
+	}
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert(40)},
+		{Endpoint: "https://example.com/api", CheckedAt: now.Add(-time.Minute), HasStatus: true, StatusCode: 503, Cert: cert(40)},
+		{Endpoint: `http://quote".example.com`, CheckedAt: now, HasStatus: true, StatusCode: -1},
+		{Endpoint: "https://gone.example.com", CheckedAt: now.Add(-time.Hour), HasStatus: true, StatusCode: 200, Cert: cert(5)},
+	})
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"by endpoint", Config{MetricsStaleAfter: defaultMetricsStaleAfter}, fmt.Sprintf(`# HELP endpoint_http_status_code HTTP status code of the last check; 0 for a connection failure, -1 for a DNS failure.
+# TYPE endpoint_http_status_code gauge
+endpoint_http_status_code{endpoint="http://quote\".example.com"} -1
+endpoint_http_status_code{endpoint="https://example.com"} 200
+endpoint_http_status_code{endpoint="https://example.com/api"} 503
+# HELP endpoint_up Whether the last check got a 2xx or 3xx response.
+# TYPE endpoint_up gauge
+endpoint_up{endpoint="http://quote\".example.com"} 0
+endpoint_up{endpoint="https://example.com"} 1
+endpoint_up{endpoint="https://example.com/api"} 0
+# HELP endpoint_ssl_days_left Days until the certificate expires, a part day counting as one, negative once expired.
+# TYPE endpoint_ssl_days_left gauge
+endpoint_ssl_days_left{endpoint="https://example.com"} 41
+endpoint_ssl_days_left{endpoint="https://example.com/api"} 41
+# HELP endpoint_acknowledged Whether the endpoint's alerts are acknowledged on the dashboard.
+# TYPE endpoint_acknowledged gauge
+endpoint_acknowledged{endpoint="http://quote\".example.com"} 0
+endpoint_acknowledged{endpoint="https://example.com"} 0
+endpoint_acknowledged{endpoint="https://example.com/api"} 0
+# HELP endpoint_in_maintenance Whether the last check fell in a maintenance window.
+# TYPE endpoint_in_maintenance gauge
+endpoint_in_maintenance{endpoint="http://quote\".example.com"} 0
+endpoint_in_maintenance{endpoint="https://example.com"} 0
+endpoint_in_maintenance{endpoint="https://example.com/api"} 0
+# HELP endpoint_last_check_timestamp Unix time of the last status or SSL check.
+# TYPE endpoint_last_check_timestamp gauge
+endpoint_last_check_timestamp{endpoint="http://quote\".example.com"} %[1]d
+endpoint_last_check_timestamp{endpoint="https://example.com"} %[1]d
+endpoint_last_check_timestamp{endpoint="https://example.com/api"} %[2]d
+`, now.Unix(), now.Add(-time.Minute).Unix())},
+		{"by hostname", Config{MetricsByHost: true}, fmt.Sprintf(`# HELP endpoint_http_status_code HTTP status code of the last check; 0 for a connection failure, -1 for a DNS failure.
+# TYPE endpoint_http_status_code gauge
+endpoint_http_status_code{hostname="example.com"} 503
+endpoint_http_status_code{hostname="gone.example.com"} 200
+endpoint_http_status_code{hostname="quote\".example.com"} -1
+# HELP endpoint_up Whether the last check got a 2xx or 3xx response.
+# TYPE endpoint_up gauge
+endpoint_up{hostname="example.com"} 0
+endpoint_up{hostname="gone.example.com"} 1
+endpoint_up{hostname="quote\".example.com"} 0
+# HELP endpoint_ssl_days_left Days until the certificate expires, a part day counting as one, negative once expired.
+# TYPE endpoint_ssl_days_left gauge
+endpoint_ssl_days_left{hostname="example.com"} 41
+endpoint_ssl_days_left{hostname="gone.example.com"} 6
+# HELP endpoint_acknowledged Whether the endpoint's alerts are acknowledged on the dashboard.
+# TYPE endpoint_acknowledged gauge
+endpoint_acknowledged{hostname="example.com"} 0
+endpoint_acknowledged{hostname="gone.example.com"} 0
+endpoint_acknowledged{hostname="quote\".example.com"} 0
+# HELP endpoint_in_maintenance Whether the last check fell in a maintenance window.
+# TYPE endpoint_in_maintenance gauge
+endpoint_in_maintenance{hostname="example.com"} 0
+endpoint_in_maintenance{hostname="gone.example.com"} 0
+endpoint_in_maintenance{hostname="quote\".example.com"} 0
+# HELP endpoint_last_check_timestamp Unix time of the last status or SSL check.
+# TYPE endpoint_last_check_timestamp gauge
+endpoint_last_check_timestamp{hostname="example.com"} %[2]d
+endpoint_last_check_timestamp{hostname="gone.example.com"} %[3]d
+endpoint_last_check_timestamp{hostname="quote\".example.com"} %[1]d
+`, now.Unix(), now.Add(-time.Minute).Unix(), now.Add(-time.Hour).Unix())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{config: tt.config, store: st}
+			rec := httptest.NewRecorder()
+			server.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("metrics =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestHandleBadge tests the SVG status and certificate badges
+func TestHandleBadge(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	cert := func(days int) *store.CertInfo {
+		return &store.CertInfo{NotAfter: now.Add(time.Duration(days)*24*time.Hour + time.Hour), State: store.CertStateValid}
+	}
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://up.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert(12)},
+		{Endpoint: "https://down.example.com", CheckedAt: now, HasStatus: true, StatusCode: 502, Cert: cert(-3)},
+		{Endpoint: "http://moved.example.com", CheckedAt: now, HasStatus: true, StatusCode: 301},
+		{Endpoint: "https://nxdomain.example.com", CheckedAt: now, HasStatus: true, StatusCode: -1},
+	})
+	server := &Server{store: st}
+
+	tests := []struct {
+		query       string
+		wantStatus  int
+		wantMessage string
+		wantColor   string
+	}{
+		{"url=https://up.example.com", http.StatusOK, "status: up", "#28a745"},
+		{"url=up.example.com&kind=status", http.StatusOK, "status: up", "#28a745"},
+		{"url=https://down.example.com", http.StatusOK, "status: down 502", "#dc3545"},
+		{"url=http://moved.example.com", http.StatusOK, "status: up 301", "#ffc107"},
+		{"url=https://nxdomain.example.com", http.StatusOK, "status: down dns", "#6c757d"},
+		{"url=https://up.example.com&kind=ssl", http.StatusOK, "cert: 13d", "#ffc107"},
+		{"url=https://down.example.com&kind=ssl", http.StatusOK, "cert: expired", "#721c24"},
+		{"url=http://moved.example.com&kind=ssl", http.StatusOK, "cert: unknown", badgeUnknownColor},
+		{"url=https://unknown.example.com", http.StatusOK, "status: unknown", badgeUnknownColor},
+		{"url=https://up.example.com&kind=latency", http.StatusBadRequest, "", ""},
+		{"kind=ssl", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.handleBadge(rec, httptest.NewRequest(http.MethodGet, "/badge?"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "image/svg+xml" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "max-age=60" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+
+			var svg struct {
+				XMLName xml.Name `xml:"svg"`
+				Title   string   `xml:"title"`
+				Rects   []struct {
+					Fill string `xml:"fill,attr"`
+				} `xml:"g>rect"`
+			}
+			if err := xml.Unmarshal(rec.Body.Bytes(), &svg); err != nil {
+				t.Fatalf("badge is not valid XML: %v\n%s", err, rec.Body)
+			}
+			if svg.Title != tt.wantMessage {
+				t.Errorf("title = %q, want %q", svg.Title, tt.wantMessage)
+			}
+			if len(svg.Rects) < 2 || svg.Rects[1].Fill != tt.wantColor {
+				t.Errorf("rects = %+v, want message color %s", svg.Rects, tt.wantColor)
+			}
+		})
+	}
+}
+
+// TestTextDashboard tests the plain-text dashboard for terminals
+func TestTextDashboard(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	cert := &store.CertInfo{NotAfter: now.Add(40*24*time.Hour + time.Hour), State: store.CertStateValid}
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert},
+		{Endpoint: "http://a-much-longer-name.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		server.handleIndex(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		accept    string
+		wantText  bool
+		wantColor bool
+	}{
+		{"browser", "/", "text/html,application/xhtml+xml,*/*;q=0.8", false, false},
+		{"curl default", "/", "*/*", false, false},
+		{"accept text", "/", "text/plain", true, false},
+		{"format text", "/?format=text", "", true, false},
+		{"color", "/?format=text&color=true", "", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.path, tt.accept)
+			body := rec.Body.String()
+			if isText := strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"); isText != tt.wantText {
+				t.Fatalf("Content-Type = %q, want text %v", rec.Header().Get("Content-Type"), tt.wantText)
+			}
+			if !tt.wantText {
+				return
+			}
+			if strings.Contains(body, "\x1b[") != tt.wantColor {
+				t.Errorf("ANSI colors = %v, want %v", !tt.wantColor, tt.wantColor)
+			}
+			if strings.Contains(body, "\xff") {
+				t.Error("tabwriter escapes left in the output")
+			}
+
+			// Columns line up once the color codes are removed
+			plain := regexp.MustCompile("\x1b\\[[0-9;]*m").ReplaceAllString(body, "")
+			lines := strings.Split(plain, "\n")
+			if !strings.HasPrefix(lines[0], "CertsNStatus at ") || !strings.Contains(lines[0], "2 endpoints, 1 healthy, 0 SSL expiring soon") {
+				t.Errorf("header = %q", lines[0])
+			}
+			header, first, second := lines[2], lines[3], lines[4]
+			column := strings.Index(header, "STATUS")
+			if !strings.HasPrefix(first[column:], "200 ") || !strings.HasPrefix(second[column:], "503 ") {
+				t.Errorf("STATUS column misaligned:\n%s", plain)
+			}
+			if !strings.Contains(first, "https://example.com") || !strings.Contains(second, "http://a-much-longer-name.example.com") {
+				t.Errorf("rows not sorted by SSL days left:\n%s", plain)
+			}
+		})
+	}
+
+	if rec := get("/?format=text&q=nothing", ""); !strings.Contains(rec.Body.String(), "0 of 2 endpoints") || !strings.Contains(rec.Body.String(), "No endpoints match") {
+		t.Errorf("filtered text dashboard =\n%s", rec.Body)
+	}
+}
+
+// atomDocument counts the elements RFC 4287 requires of a feed and its entries
+type atomDocument struct {
+	XMLName xml.Name
+	IDs     []string `xml:"http://www.w3.org/2005/Atom id"`
+	Titles  []string `xml:"http://www.w3.org/2005/Atom title"`
+	Updated []string `xml:"http://www.w3.org/2005/Atom updated"`
+	Authors []struct {
+		Name string `xml:"http://www.w3.org/2005/Atom name"`
+	} `xml:"http://www.w3.org/2005/Atom author"`
+	Links   []atomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Entries []struct {
+		IDs     []string `xml:"http://www.w3.org/2005/Atom id"`
+		Titles  []string `xml:"http://www.w3.org/2005/Atom title"`
+		Updated []string `xml:"http://www.w3.org/2005/Atom updated"`
+	} `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// validateAtom checks body against the constraints of RFC 4287: an Atom
+// feed element with exactly one id (an absolute IRI), title and updated (an
+// RFC 3339 date), an author, a self link, and entries with their own unique
+// id, title and updated. It returns the entry ids.
+func validateAtom(t *testing.T, body []byte) []string {
+	t.Helper()
+	var doc atomDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if doc.XMLName != (xml.Name{Space: "http://www.w3.org/2005/Atom", Local: "feed"}) {
+		t.Fatalf("root element = %v, want an Atom feed", doc.XMLName)
+	}
+
+	checkRequired := func(what string, ids, titles, updated []string) {
+		if len(ids) != 1 || len(titles) != 1 || len(updated) != 1 {
+			t.Errorf("%s has %d ids, %d titles and %d updated, want one each", what, len(ids), len(titles), len(updated))
+			return
+		}
+		if u, err := url.Parse(ids[0]); err != nil || !u.IsAbs() {
+			t.Errorf("%s id %q is not an absolute IRI", what, ids[0])
+		}
+		if titles[0] == "" {
+			t.Errorf("%s has an empty title", what)
+		}
+		if _, err := time.Parse(time.RFC3339, updated[0]); err != nil {
+			t.Errorf("%s updated %q is not an RFC 3339 date", what, updated[0])
+		}
+	}
+	checkRequired("feed", doc.IDs, doc.Titles, doc.Updated)
+	if len(doc.Authors) == 0 || doc.Authors[0].Name == "" {
+		t.Error("feed has no author")
+	}
+	if !slices.ContainsFunc(doc.Links, func(link atomLink) bool {
+		return link.Rel == "self" && link.Href != ""
+	}) {
+		t.Error("feed has no self link")
+	}
+
+	var ids []string
+	for i, entry := range doc.Entries {
+		checkRequired(fmt.Sprintf("entry %d", i), entry.IDs, entry.Titles, entry.Updated)
+		if len(entry.IDs) == 1 {
+			if slices.Contains(ids, entry.IDs[0]) {
+				t.Errorf("entry %d repeats id %q", i, entry.IDs[0])
+			}
+			ids = append(ids, entry.IDs[0])
+		}
+	}
+	return ids
+}
+
+// TestFeed tests building the Atom feed against the testdata/feed.atom fixture
+func TestFeed(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []store.StreamEvent{
+		{ID: "1709295000000-0", Event: store.Event{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp, At: at.Add(10 * time.Minute)}},
+		{ID: "1709294700000-1", Event: store.Event{Endpoint: "https://c.example.com", Kind: store.EventKindCert, Old: store.CertLevelCritical, New: store.CertLevelExpired, At: at.Add(5 * time.Minute)}},
+		{ID: "1709294700000-0", Event: store.Event{Endpoint: "https://b.example.com", Kind: store.EventKindCertRenewed, Old: "2024-03-05T00:00:00Z", New: "2024-06-03T00:00:00Z", At: at.Add(5 * time.Minute)}},
+		{ID: "1709294460000-0", Event: store.Event{Endpoint: "https://b.example.com", Kind: store.EventKindCert, Old: store.CertLevelWarning, New: store.CertLevelCritical, At: at.Add(time.Minute)}},
+		{ID: "1709294400000-1", Event: store.Event{Endpoint: "https://b.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: at}},
+		{ID: "1709294400000-0", Event: store.Event{Endpoint: "https://a.example.com?env=<prod>&x=1", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: at}},
+	}
+
+	var got strings.Builder
+	if err := writeFeed(&got, newFeed(events, "prod:", "http://localhost:8080/feed.atom", at.Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/feed.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != string(want) {
+		t.Errorf("feed =\n%s\nwant testdata/feed.atom:\n%s", got.String(), want)
+	}
+
+	ids := validateAtom(t, want)
+	wantIDs := []string{
+		"urn:certs-n-status:prod:event:1709295000000-0",
+		"urn:certs-n-status:prod:event:1709294700000-1",
+		"urn:certs-n-status:prod:event:1709294400000-1",
+		"urn:certs-n-status:prod:event:1709294400000-0",
+	}
+	if !slices.Equal(ids, wantIDs) {
+		t.Errorf("entry ids = %v, want %v (renewals and warning to critical left out)", ids, wantIDs)
+	}
+
+	// Without notable events the feed is as recent as the request
+	var empty strings.Builder
+	if err := writeFeed(&empty, newFeed(events[2:3], "", "http://localhost:8080/feed.atom", at)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := validateAtom(t, []byte(empty.String())); len(ids) != 0 {
+		t.Errorf("feed of a renewal has entries %v, want none", ids)
+	}
+	if !strings.Contains(empty.String(), "<updated>2024-03-01T12:00:00Z</updated>") {
+		t.Errorf("empty feed does not use the current time:\n%s", empty.String())
+	}
+}
+
+// TestHandleFeed tests that /feed.atom serves the newest notable events of
+// the stream, capped, with the same ids on every request
+func TestHandleFeed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Renewals are not notable, so they only push events down the stream
+	var events []store.Event
+	for i := 0; i < feedMaxEntries+50; i++ {
+		events = append(events,
+			store.Event{Endpoint: fmt.Sprintf("https://%d.example.com", i), Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: at.Add(time.Duration(i) * time.Minute)},
+			store.Event{Endpoint: fmt.Sprintf("https://%d.example.com", i), Kind: store.EventKindCertRenewed, Old: "2024-03-05T00:00:00Z", New: "2024-06-03T00:00:00Z", At: at.Add(time.Duration(i) * time.Minute)},
+		)
+	}
+	for i := 0; i < 5; i++ {
+		events = append(events, store.Event{Endpoint: "https://renewed.example.com", Kind: store.EventKindCertRenewed, Old: "2024-03-05T00:00:00Z", New: "2024-06-03T00:00:00Z", At: at.Add(time.Duration(feedMaxEntries+50) * time.Minute)})
+	}
+	if err := st.PublishEvents(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{store: st}
+
+	get := func() []string {
+		rec := httptest.NewRecorder()
+		server.handleFeed(rec, httptest.NewRequest(http.MethodGet, "/feed.atom", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /feed.atom = %d, want 200", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+			t.Errorf("Content-Type = %q, want application/atom+xml", ct)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, `<link rel="self" href="http://example.com/feed.atom"></link>`) {
+			t.Errorf("feed has no self link to /feed.atom:\n%.500s", body)
+		}
+		if !strings.Contains(body, "<title>https://149.example.com is down</title>") {
+			t.Errorf("feed does not start with the newest event:\n%.1000s", body)
+		}
+		return validateAtom(t, rec.Body.Bytes())
+	}
+
+	first := get()
+	if len(first) != feedMaxEntries {
+		t.Fatalf("feed has %d entries, want %d", len(first), feedMaxEntries)
+	}
+	if second := get(); !slices.Equal(first, second) {
+		t.Errorf("entry ids changed between requests")
+	}
+
+	// Other storage backends have no event stream
+	rec := httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleFeed(rec, httptest.NewRequest(http.MethodGet, "/feed.atom", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// TestWriteCalendar tests the iCalendar output: CRLF line endings, escaped
+// text, lines folded at 75 octets and the alarm
+func TestWriteCalendar(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	events := []calendarEvent{{
+		UID:         "0123456789abcdef0123456789abcdef@certs-n-status",
+		Endpoint:    "https://example.com",
+		Host:        "example.com",
+		NotAfter:    time.Date(2024, 5, 30, 23, 59, 59, 0, time.UTC),
+		Description: "The certificate of https://example.com expires at 2024-05-30T23:59:59Z.\nIssuer: CN=R3,O=Let's Encrypt",
+	}}
+
+	tests := []struct {
+		name      string
+		alarmDays int
+		want      []string
+	}{
+		{"alarm", 14, []string{
+			"BEGIN:VEVENT",
+			"UID:0123456789abcdef0123456789abcdef@certs-n-status",
+			"DTSTAMP:20240301T123000Z",
+			"DTSTART;VALUE=DATE:20240530",
+			"DTEND;VALUE=DATE:20240531",
+			"SUMMARY:Cert expires: example.com",
+			"DESCRIPTION:The certificate of https://example.com expires at 2024-05-30T23",
+			` :59:59Z.\nIssuer: CN=R3\,O=Let's Encrypt`,
+			"URL:https://example.com",
+			"TRANSP:TRANSPARENT",
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:Cert of example.com expires in 14 days",
+			"TRIGGER:-P14D",
+			"END:VALARM",
+			"END:VEVENT",
+		}},
+		{"no alarm", 0, []string{
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeCalendar(&out, events, tt.alarmDays, now); err != nil {
+				t.Fatal(err)
+			}
+			body := out.String()
+			if !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:") || !strings.HasSuffix(body, "END:VEVENT\r\nEND:VCALENDAR\r\n") {
+				t.Errorf("calendar is not a CRLF-terminated VCALENDAR:\n%q", body)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+				if len(line) > 75 {
+					t.Errorf("line of %d octets is not folded: %q", len(line), line)
+				}
+			}
+			if want := strings.Join(tt.want, "\r\n") + "\r\n"; !strings.Contains(body, want) {
+				t.Errorf("calendar =\n%s\nwant it to contain\n%s", body, want)
+			}
+		})
+	}
+}
+
+// TestICSFolding tests that long lines fold into lines of at most 75 octets
+// that unfold to the original, without splitting a UTF-8 character
+func TestICSFolding(t *testing.T) {
+	value := strings.Repeat("Zertifikat läuft ab ", 12)
+	var out strings.Builder
+	iw := &icsWriter{w: &out}
+	iw.line("DESCRIPTION", value)
+	if iw.err != nil {
+		t.Fatal(iw.err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\r\n"), "\r\n")
+	if len(lines) < 3 {
+		t.Fatalf("line folded into %d lines, want at least 3", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) > 75 || !utf8.ValidString(line) {
+			t.Errorf("folded line %q has %d octets or splits a character", line, len(line))
+		}
+	}
+	if unfolded := strings.ReplaceAll(out.String(), "\r\n ", ""); unfolded != "DESCRIPTION:"+value+"\r\n" {
+		t.Errorf("unfolded line = %q", unfolded)
+	}
+}
+
+// TestHandleCalendar tests that /calendar.ics has one event per HTTPS
+// certificate, filtered by within, with UIDs that only change on renewal
+func TestHandleCalendar(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	cert := func(days int, serial string) *store.CertInfo {
+		return &store.CertInfo{NotAfter: now.Add(time.Duration(days) * 24 * time.Hour), SerialNumber: serial, State: store.CertStateValid}
+	}
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://soon.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert(20, "01")},
+		{Endpoint: "https://later.example.com/login", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert(200, "02")},
+		{Endpoint: "https://expired.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: cert(-3, "03")},
+		{Endpoint: "http://plain.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	server := &Server{store: st, config: Config{CalendarAlarmDays: 7}}
+
+	get := func(query string) (int, []string, string) {
+		rec := httptest.NewRecorder()
+		server.handleCalendar(rec, httptest.NewRequest(http.MethodGet, "/calendar.ics"+query, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil, ""
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+			t.Errorf("Content-Type = %q, want text/calendar", ct)
+		}
+		var summaries, uids []string
+		for _, line := range strings.Split(rec.Body.String(), "\r\n") {
+			if summary, ok := strings.CutPrefix(line, "SUMMARY:"); ok {
+				summaries = append(summaries, summary)
+			}
+			if uid, ok := strings.CutPrefix(line, "UID:"); ok {
+				uids = append(uids, uid)
+			}
+		}
+		if !strings.Contains(rec.Body.String(), "TRIGGER:-P7D\r\n") {
+			t.Errorf("calendar has no alarm 7 days before:\n%s", rec.Body)
+		}
+		return rec.Code, summaries, strings.Join(uids, ",")
+	}
+
+	tests := []struct {
+		query         string
+		wantCode      int
+		wantSummaries []string
+	}{
+		{"", http.StatusOK, []string{"Cert expires: expired.example.com", "Cert expires: soon.example.com", "Cert expires: later.example.com"}},
+		{"?within=90d", http.StatusOK, []string{"Cert expires: expired.example.com", "Cert expires: soon.example.com"}},
+		{"?within=soon", http.StatusBadRequest, nil},
+		{"?within=0d", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		code, summaries, _ := get(tt.query)
+		if code != tt.wantCode || !slices.Equal(summaries, tt.wantSummaries) {
+			t.Errorf("GET /calendar.ics%s = %d %v, want %d %v", tt.query, code, summaries, tt.wantCode, tt.wantSummaries)
+		}
+	}
+
+	// Rechecks keep the UIDs, a renewal replaces that certificate's
+	_, _, before := get("")
+	later := now.Add(time.Hour)
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://later.example.com/login", CheckedAt: later, Cert: cert(200, "02")}})
+	if _, _, after := get(""); after != before {
+		t.Errorf("UIDs changed on a recheck: %s, then %s", before, after)
+	}
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://soon.example.com", CheckedAt: later, Cert: cert(110, "04")}})
+	_, _, renewed := get("")
+	beforeUIDs, renewedUIDs := strings.Split(before, ","), strings.Split(renewed, ",")
+	if renewedUIDs[0] != beforeUIDs[0] || renewedUIDs[2] != beforeUIDs[2] || slices.Contains(beforeUIDs, renewedUIDs[1]) {
+		t.Errorf("UIDs after renewing soon.example.com = %v, were %v", renewedUIDs, beforeUIDs)
+	}
+}
+
+// wsTestClient is the client side of a /ws connection
+type wsTestClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialWS opens a WebSocket to the /ws of server
+func dialWS(t *testing.T, server *httptest.Server, header http.Header) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header = header.Clone()
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade status = %d, want 101", resp.StatusCode)
+	}
+	accept := sha1.Sum([]byte(key + wsAcceptGUID))
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != base64.StdEncoding.EncodeToString(accept[:]) {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return &wsTestClient{conn: conn, br: br}
+}
+
+// write sends a masked frame, as clients must
+func (c *wsTestClient) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	frame := []byte{opcode, 0x80}
+	if fin {
+		frame[0] |= 0x80
+	}
+	if len(payload) > 125 {
+		t.Fatal("test frames are short")
+	}
+	frame[1] |= byte(len(payload))
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// read returns the next frame from the server, which are never masked
+func (c *wsTestClient) read(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		t.Fatal("unexpectedly long frame")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// TestHandleWS tests subscribing over /ws and receiving the events of the
+// subscribed endpoints only
+func TestHandleWS(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	server := &Server{store: st}
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWS))
+	defer ts.Close()
+
+	client := dialWS(t, ts, http.Header{})
+
+	// A request split over two frames, with a ping in between
+	client.write(t, false, wsOpText, []byte(`{"subscribe": ["a.example.com",`))
+	client.write(t, true, wsOpPing, []byte("hi"))
+	client.write(t, true, wsOpContinuation, []byte(` "https://b.example.com"]}`))
+	if opcode, payload := client.read(t); opcode != wsOpPong || string(payload) != "hi" {
+		t.Errorf("ping answered with %x %q, want a pong", opcode, payload)
+	}
+	if _, payload := client.read(t); string(payload) != `{"subscribed":["https://a.example.com","https://b.example.com"]}` {
+		t.Errorf("subscribe ack = %s", payload)
+	}
+	client.write(t, true, wsOpText, []byte(`{"unsubscribe": ["https://b.example.com"]}`))
+	if _, payload := client.read(t); string(payload) != `{"subscribed":["https://a.example.com"]}` {
+		t.Errorf("unsubscribe ack = %s", payload)
+	}
+	client.write(t, true, wsOpText, []byte(`subscribe a`))
+	if _, payload := client.read(t); !strings.HasPrefix(string(payload), `{"error":`) {
+		t.Errorf("reply to an invalid request = %s", payload)
+	}
+
+	for mr.PubSubNumSub(store.EventsChannel)[store.EventsChannel] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	st.PublishEvents(ctx, []store.Event{
+		{Endpoint: "https://b.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: at},
+		{Endpoint: "https://a.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: at},
+		{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: at},
+	})
+	for _, want := range []string{
+		`{"endpoint":"https://a.example.com","event":"cert_warning","kind":"cert","old":"ok","new":"warning","at":"2024-03-01T12:00:00Z"}`,
+		`{"endpoint":"https://a.example.com","event":"down","kind":"status","old":"up","new":"down","at":"2024-03-01T12:00:00Z"}`,
+	} {
+		if opcode, payload := client.read(t); opcode != wsOpText || string(payload) != want {
+			t.Errorf("pushed %x %s, want %s", opcode, payload, want)
+		}
+	}
+
+	// The server completes the closing handshake
+	client.write(t, true, wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if opcode, payload := client.read(t); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseNormal {
+		t.Errorf("close answered with %x %v", opcode, payload)
+	}
+
+	// Unmasked client frames break the protocol
+	client = dialWS(t, ts, http.Header{})
+	client.conn.Write([]byte{0x81, 0x02, '{', '}'})
+	if opcode, payload := client.read(t); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseProtocolError {
+		t.Errorf("unmasked frame answered with %x %v, want close 1002", opcode, payload)
+	}
+}
+
+// TestHandleWSAccess tests the origin check, the token and the handshake
+// checks made before upgrading
+func TestHandleWSAccess(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := &Server{
+		store:  store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		config: Config{WSToken: "s3cret", WSAllowedOrigins: []string{"https://chatops.example.com"}},
+	}
+	upgrade := http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}}
+
+	tests := []struct {
+		name     string
+		target   string
+		header   http.Header
+		wantCode int
+	}{
+		{"no token", "/ws", upgrade, http.StatusUnauthorized},
+		{"wrong token", "/ws?token=guess", upgrade, http.StatusUnauthorized},
+		{"foreign origin", "/ws?token=s3cret", http.Header{"Origin": {"https://evil.example.com"}}, http.StatusForbidden},
+		{"bearer token, no key", "/ws", http.Header{"Authorization": {"Bearer s3cret"}, "Connection": {"Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"13"}}, http.StatusBadRequest},
+		{"allowed origin, old version", "/ws?token=s3cret", http.Header{"Origin": {"https://chatops.example.com"}, "Connection": {"keep-alive, Upgrade"}, "Upgrade": {"websocket"}, "Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"own origin, no upgrade", "/ws?token=s3cret", http.Header{"Origin": {"http://example.com"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header = tt.header
+			rec := httptest.NewRecorder()
+			server.handleWS(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+
+	// With a valid token the socket opens
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWS))
+	defer ts.Close()
+	dialWS(t, ts, http.Header{"Authorization": {"Bearer s3cret"}})
+
+	rec := httptest.NewRecorder()
+	(&Server{store: store.NewMemoryStore()}).handleWS(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("memory store status = %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+// TestEventHubSlowConsumer tests that a client whose queue is full is
+// dropped without holding up the others
+func TestEventHubSlowConsumer(t *testing.T) {
+	hub := newEventHub(nil)
+	slow, fast := newWSClient(nil, "slow"), newWSClient(nil, "fast")
+	slow.update(wsRequest{Subscribe: []string{"https://example.com"}})
+	fast.update(wsRequest{Subscribe: []string{wsSubscribeAll}})
+	hub.clients[slow] = struct{}{}
+	hub.clients[fast] = struct{}{}
+
+	event := store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown}
+	for i := 0; i < wsSendQueueSize; i++ {
+		hub.broadcast(event)
+		<-fast.queue
+	}
+	select {
+	case <-slow.done:
+		t.Fatal("client dropped before its queue was full")
+	default:
+	}
+
+	hub.broadcast(event)
+	select {
+	case <-slow.done:
+	default:
+		t.Fatal("client with a full queue not dropped")
+	}
+	if slow.closeCode != wsClosePolicyViolation {
+		t.Errorf("close code = %d, want %d", slow.closeCode, wsClosePolicyViolation)
+	}
+	if _, ok := hub.clients[slow]; ok {
+		t.Error("dropped client still receives events")
+	}
+	if len(fast.queue) != 1 {
+		t.Errorf("other client has %d queued events, want 1", len(fast.queue))
+	}
+}
+
+// TestServeShutdown tests that cancelling the context drains the requests
+// in flight, and cuts off those still running after SHUTDOWN_TIMEOUT
+func TestServeShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		finish   bool // the request completes during the drain
+		wantBody string
+	}{
+		{"drained", true, "done"},
+		{"deadline", false, ""},
+	}
+	for _, tt := range tests {
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			select {
+			case <-release:
+				w.Write([]byte("done"))
+			case <-r.Context().Done():
+			}
+		})
+		server := &Server{config: Config{ShutdownTimeout: 200 * time.Millisecond}}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- server.serve(ctx, server.newHTTPServer(handler), ln) }()
+
+		body := make(chan string, 1)
+		go func() {
+			resp, err := http.Get("http://" + ln.Addr().String() + "/")
+			if err != nil {
+				body <- ""
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			body <- string(data)
+		}()
+		<-started
+		cancel()
+		for {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				break
+			}
+			conn.Close()
+			time.Sleep(5 * time.Millisecond)
+		}
+		if tt.finish {
+			close(release)
+		}
+
+		select {
+		case err := <-served:
+			if err != nil {
+				t.Errorf("%s: serve = %v, want nil", tt.name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: serve did not return", tt.name)
+		}
+		if got := <-body; got != tt.wantBody {
+			t.Errorf("%s: response body = %q, want %q", tt.name, got, tt.wantBody)
+		}
+	}
+}
+
+// TestCloseWebSockets tests that /ws clients are sent 1001 Going Away on
+// shutdown and that new ones are turned away
+func TestCloseWebSockets(t *testing.T) {
+	mr := miniredis.RunT(t)
+	server := &Server{store: store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))}
+	ts := httptest.NewServer(http.HandlerFunc(server.handleWS))
+	defer ts.Close()
+
+	client := dialWS(t, ts, http.Header{})
+	client.write(t, true, wsOpText, []byte(`{"subscribe": ["*"]}`))
+	client.read(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.closeWebSockets(ctx); err != nil {
+		t.Fatalf("closeWebSockets = %v", err)
+	}
+	if opcode, payload := client.read(t); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseGoingAway {
+		t.Errorf("on shutdown got %x %q, want close 1001", opcode, payload)
+	}
+
+	late := dialWS(t, ts, http.Header{})
+	if opcode, payload := late.read(t); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseGoingAway {
+		t.Errorf("client during shutdown got %x %q, want close 1001", opcode, payload)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 named
+// commonName and its key to cert.pem and key.pem in dir
+func writeTestCert(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// TestCertReloader tests that a changed certificate is served from the
+// next handshake, and that a half-written renewal keeps the old one
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	servedName := func() string {
+		cert, err := certs.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	// Move the files' times forward, as a write within the file system's
+	// timestamp granularity might not change them
+	touch := func(offset time.Duration) {
+		for _, path := range []string{certFile, keyFile} {
+			if err := os.Chtimes(path, time.Now().Add(offset), time.Now().Add(offset)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if got := servedName(); got != "first" {
+		t.Errorf("served %q, want first", got)
+	}
+
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	// A renewal that has replaced the key but not yet the certificate
+	first, _ := os.ReadFile(certFile)
+	writeTestCert(t, dir, "second")
+	os.WriteFile(certFile, first, 0o600)
+	touch(time.Minute)
+	if got := servedName(); got != "first" {
+		t.Errorf("with a mismatched key served %q, want first", got)
+	}
+	if !strings.Contains(logged.String(), "[WARN] Keeping the current TLS certificate") {
+		t.Errorf("logged %q, want a warning about the mismatched key", logged.String())
+	}
+
+	writeTestCert(t, dir, "second")
+	touch(2 * time.Minute)
+	if got := servedName(); got != "second" {
+		t.Errorf("after the renewal served %q, want second", got)
+	}
+}
+
+// TestTLSConfig tests that incomplete TLS settings refuse to start
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "127.0.0.1")
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"cert only", Config{TLSCertFile: certFile}, "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		{"key only", Config{TLSKeyFile: keyFile}, "TLS_CERT_FILE and TLS_KEY_FILE must be set together"},
+		{"missing file", Config{TLSCertFile: certFile, TLSKeyFile: keyFile + ".missing"}, "no such file"},
+		{"swapped files", Config{TLSCertFile: keyFile, TLSKeyFile: certFile}, "TLS certificate"},
+		{"redirect without TLS", Config{RedirectHTTPPort: "8081"}, "REDIRECT_HTTP_PORT requires TLS_CERT_FILE and TLS_KEY_FILE"},
+	}
+	for _, tt := range tests {
+		_, err := NewServer(tt.config, store.NewMemoryStore())
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: NewServer error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestServeTLS tests serving over HTTPS with the configured certificate
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir(), "127.0.0.1")
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{certs: certs}
+	srv := server.newHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	srv.TLSConfig = certs.tlsConfig()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.serve(ctx, srv, ln)
+
+	pool := x509.NewCertPool()
+	leaf, _ := x509.ParseCertificate(certs.cert.Certificate[0])
+	pool.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.TLS == nil || string(body) != "secure" {
+		t.Errorf("GET over TLS = %q (TLS %v), want secure", body, resp.TLS != nil)
+	}
+}
+
+// TestHTTPSRedirect tests the redirects of REDIRECT_HTTP_PORT
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		httpsPort string
+		host      string
+		target    string
+		want      string
+	}{
+		{"8443", "example.com:8080", "/api/summary?x=1", "https://example.com:8443/api/summary?x=1"},
+		{"8443", "example.com", "/", "https://example.com:8443/"},
+		{"443", "example.com:80", "/feed.atom", "https://example.com/feed.atom"},
+		{"443", "[::1]:80", "/", "https://[::1]/"},
+		{"8443", "[::1]:80", "/", "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		httpsRedirectHandler(tt.httpsPort).ServeHTTP(rec, req)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s%s to port %s = %d %q, want 308 %q", tt.host, tt.target, tt.httpsPort, rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+	}
+}
+
+// TestRoutes tests that only the registered paths are served and unknown
+// ones get a 404, as JSON under /api/
+func TestRoutes(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.SaveResults(context.Background(), []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 200},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method      string
+		path        string
+		wantStatus  int
+		contentType string
+		wantBody    string
+	}{
+		{http.MethodGet, "/", http.StatusOK, "", "Total Endpoints"},
+		{http.MethodHead, "/", http.StatusOK, "", ""},
+		{http.MethodGet, "/favicon.ico", http.StatusNotFound, "text/html; charset=utf-8", "Back to the dashboard"},
+		{http.MethodGet, "/wp-login.php", http.StatusNotFound, "text/html; charset=utf-8", "<code>/wp-login.php</code>"},
+		{http.MethodGet, "/%3Cscript%3E", http.StatusNotFound, "text/html; charset=utf-8", "<code>/&lt;script&gt;</code>"},
+		{http.MethodGet, "/index.html", http.StatusNotFound, "text/html; charset=utf-8", "Not found"},
+		{http.MethodGet, "/debug/pprof/", http.StatusNotFound, "text/html; charset=utf-8", "Not found"},
+		{http.MethodGet, "/debug/vars", http.StatusNotFound, "text/html; charset=utf-8", "Not found"},
+		{http.MethodGet, "/api/nope", http.StatusNotFound, "application/json", `{"error":"not found","path":"/api/nope"}`},
+		{http.MethodGet, "/api/endpoints/", http.StatusNotFound, "application/json", `"error":"not found"`},
+		{http.MethodGet, "/api/endpoints/https%3A%2F%2Funknown.example.com", http.StatusNotFound, "application/json", `"error":"not found"`},
+		{http.MethodGet, "/api/endpoints/https%3A%2F%2Fexample.com", http.StatusOK, "application/json", `"endpoint"`},
+		{http.MethodGet, "/api/summary", http.StatusOK, "application/json", `"total":1`},
+		{http.MethodPost, "/api/summary", http.StatusMethodNotAllowed, "", ""},
+		{http.MethodGet, "/healthz", http.StatusOK, "", "ok"},
+		{http.MethodGet, "/api/version", http.StatusOK, "application/json", `{"version":"dev","commit":"unknown","build_date":"unknown","go_version":"go`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+		}
+		if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s %s Content-Type = %q, want %q", tt.method, tt.path, rec.Header().Get("Content-Type"), tt.contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s body = %q, want it to contain %q", tt.method, tt.path, rec.Body.String(), tt.wantBody)
+		}
+	}
+}
+
+// TestDebugEndpoints tests that DEBUG_ENDPOINTS serves pprof and expvar
+// only behind the dashboard login
+func TestDebugEndpoints(t *testing.T) {
+	st := store.NewMemoryStore()
+	if _, err := NewServer(Config{DebugEndpoints: true}, st); err == nil {
+		t.Error("NewServer() with DEBUG_ENDPOINTS and no login succeeded")
+	}
+	server, err := NewServer(Config{DebugEndpoints: true, DashboardUsername: "admin", DashboardPassword: "correct horse"}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.handler()
+	get := func(path string, login bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if login {
+			req.SetBasicAuth("admin", "correct horse")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	before := pageRenders.Value()
+	get("/", true)
+	if pageRenders.Value() != before+1 {
+		t.Errorf("page_renders = %d after a page, want %d", pageRenders.Value(), before+1)
+	}
+	tests := []struct {
+		path     string
+		login    bool
+		wantCode int
+		wantBody string
+	}{
+		{"/debug/vars", false, http.StatusUnauthorized, ""},
+		{"/debug/pprof/", false, http.StatusUnauthorized, ""},
+		{"/debug/vars", true, http.StatusOK, `"page_renders": `},
+		{"/debug/vars", true, http.StatusOK, `"requests_served": `},
+		{"/debug/vars", true, http.StatusOK, `"memstats": `},
+		{"/debug/pprof/", true, http.StatusOK, "goroutine"},
+		{"/debug/pprof/heap?debug=1", true, http.StatusOK, "heap profile"},
+	}
+	for _, tt := range tests {
+		rec := get(tt.path, tt.login)
+		if rec.Code != tt.wantCode || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("GET %s (login %v) = %d, want %d containing %q", tt.path, tt.login, rec.Code, tt.wantCode, tt.wantBody)
+		}
+	}
+}
+
+// TestBasePath tests serving the dashboard and the API under BASE_PATH
+func TestBasePath(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.SaveResults(context.Background(), []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 200},
+	})
+	server, err := NewServer(Config{BasePath: "certs/"}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.config.BasePath != "/certs" {
+		t.Fatalf("BasePath = %q, want /certs", server.config.BasePath)
+	}
+	handler := server.handler()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   []string
+		wantHeader map[string]string
+	}{
+		{"/certs/?q=example", http.StatusOK, []string{`action="/certs/"`, `href="/certs/">Show all`}, nil},
+		{"/certs", http.StatusMovedPermanently, nil, map[string]string{"Location": "/certs/"}},
+		{"/certs?q=a", http.StatusMovedPermanently, nil, map[string]string{"Location": "/certs/?q=a"}},
+		{"/certs/api/summary", http.StatusOK, []string{`"total":1`}, nil},
+		{"/certs/api/v1/endpoints", http.StatusOK, []string{`"endpoint":"https://example.com"`}, nil},
+		{"/certs/api/endpoints", http.StatusOK, nil, map[string]string{"Link": `</certs/api/v1/endpoints>; rel="successor-version"`}},
+		{"/certs/api/endpoints/https%3A%2F%2Fexample.com", http.StatusOK, []string{`"endpoint"`}, nil},
+		{"/certs/api/nope", http.StatusNotFound, []string{`"path":"/certs/api/nope"`}, nil},
+		{"/certs/healthz", http.StatusOK, []string{"ok"}, nil},
+		{"/certs/favicon.ico", http.StatusNotFound, []string{`<a href="/certs/">`}, nil},
+		{"/", http.StatusNotFound, []string{`<a href="/certs/">`}, nil},
+		{"/api/summary", http.StatusNotFound, nil, nil},
+		{"/healthz", http.StatusNotFound, nil, nil},
+		{"/certsx/", http.StatusNotFound, nil, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+		}
+		for _, want := range tt.wantBody {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("GET %s body does not contain %q", tt.path, want)
+			}
+		}
+		for name, want := range tt.wantHeader {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("GET %s %s = %q, want %q", tt.path, name, got, want)
+			}
+		}
+	}
+
+	for _, basePath := range []string{"/a?b", "/../etc", "/a/./b", "/a%2Fb"} {
+		if _, err := NewServer(Config{BasePath: basePath}, st); err == nil {
+			t.Errorf("NewServer with BASE_PATH %q succeeded, want an error", basePath)
+		}
+	}
+}
+
+// TestManageEndpoints tests adding and removing endpoints through the API
+func TestManageEndpoints(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://old.example.com", CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}})
+	writable := (&Server{config: Config{AllowWrite: true}, store: st}).routes()
+	readOnly := (&Server{store: st}).routes()
+	memory := (&Server{config: Config{AllowWrite: true}, store: store.NewMemoryStore()}).routes()
+
+	tests := []struct {
+		name        string
+		mux         *http.ServeMux
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"add", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": " new.example.com "}`, http.StatusCreated, `{"endpoint":"https://new.example.com","added":true}`},
+		{"add again", writable, http.MethodPost, "/api/endpoints", "application/json; charset=utf-8", `{"url": "https://new.example.com"}`, http.StatusOK, `{"endpoint":"https://new.example.com"}`},
+		{"form post", writable, http.MethodPost, "/api/endpoints", "application/x-www-form-urlencoded", `url=https://x.example.com`, http.StatusUnsupportedMediaType, "Content-Type"},
+		{"not json", writable, http.MethodPost, "/api/endpoints", "application/json", `https://x.example.com`, http.StatusBadRequest, "Expected a body"},
+		{"empty url", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": " "}`, http.StatusBadRequest, "missing url"},
+		{"other scheme", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": "ftp://x.example.com"}`, http.StatusBadRequest, "invalid url"},
+		{"no host", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": "https://"}`, http.StatusBadRequest, "invalid url"},
+		{"spaces", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": "https://a b.example.com"}`, http.StatusBadRequest, "invalid url"},
+		{"too big", writable, http.MethodPost, "/api/endpoints", "application/json", `{"url": "https://` + strings.Repeat("a", 5000) + `.com"}`, http.StatusBadRequest, "Expected a body"},
+		{"remove", writable, http.MethodDelete, "/api/endpoints?url=old.example.com", "", "", http.StatusNoContent, ""},
+		{"remove again", writable, http.MethodDelete, "/api/endpoints?url=https://old.example.com", "", "", http.StatusNotFound, `"error":"not found"`},
+		{"remove without url", writable, http.MethodDelete, "/api/endpoints", "", "", http.StatusBadRequest, "missing url"},
+		{"read-only add", readOnly, http.MethodPost, "/api/endpoints", "application/json", `{"url": "https://x.example.com"}`, http.StatusForbidden, "ALLOW_WRITE"},
+		{"read-only remove", readOnly, http.MethodDelete, "/api/endpoints?url=https://new.example.com", "", "", http.StatusForbidden, "ALLOW_WRITE"},
+		{"memory storage", memory, http.MethodPost, "/api/endpoints", "application/json", `{"url": "https://x.example.com"}`, http.StatusNotImplemented, "Redis"},
+		{"other method", writable, http.MethodPut, "/api/endpoints", "application/json", `{}`, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		tt.mux.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d (%s)", tt.name, tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+	}
+
+	endpoints, _ := st.ListEndpoints(ctx)
+	if want := []string{"https://new.example.com"}; !slices.Equal(endpoints, want) {
+		t.Errorf("registry = %v, want %v", endpoints, want)
+	}
+	if mr.Exists("endpoint:https://old.example.com") || mr.Exists("history:status:https://old.example.com") {
+		t.Error("results of the removed endpoint were kept")
+	}
+}
+
+// TestAllowWriteRequiresAuth tests that ALLOW_WRITE refuses to start
+// without a login or API tokens
+func TestAllowWriteRequiresAuth(t *testing.T) {
+	if _, err := NewServer(Config{AllowWrite: true}, store.NewMemoryStore()); err == nil || !strings.Contains(err.Error(), "ALLOW_WRITE requires") {
+		t.Errorf("NewServer with ALLOW_WRITE alone = %v, want an error", err)
+	}
+	if _, err := NewServer(Config{AllowWrite: true, APITokens: []string{"secret"}}, store.NewMemoryStore()); err != nil {
+		t.Errorf("NewServer with ALLOW_WRITE and API_TOKENS = %v", err)
+	}
+}
+
+// TestRecheck tests on-demand rechecks: published to a listening checker,
+// at most once per interval per endpoint, and only for known endpoints
+func TestRecheck(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	checkedAt := time.Unix(1700000000, 0)
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503}})
+	mux := (&Server{store: st}).routes()
+	memory := (&Server{store: store.NewMemoryStore()}).routes()
+
+	post := func(mux *http.ServeMux, method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+	if rec := post(mux, http.MethodPost, "/api/endpoints/recheck?url=example.com"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a checker: %d, want 503 (%s)", rec.Code, rec.Body.String())
+	}
+
+	received := make(chan string, 10)
+	go st.WatchRechecks(ctx, func(endpoint string) { received <- endpoint })
+	for mr.PubSubNumSub(store.RecheckChannel)[store.RecheckChannel] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name       string
+		mux        *http.ServeMux
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"recheck", mux, http.MethodPost, "/api/endpoints/recheck?url=example.com", http.StatusAccepted, `{"endpoint":"https://example.com","status_updated":1700000000}`},
+		{"too soon", mux, http.MethodPost, "/api/endpoints/recheck?url=https://example.com", http.StatusTooManyRequests, "less than 10s ago"},
+		{"unknown endpoint", mux, http.MethodPost, "/api/endpoints/recheck?url=other.example.com", http.StatusNotFound, `"error":"not found"`},
+		{"missing url", mux, http.MethodPost, "/api/endpoints/recheck", http.StatusBadRequest, "missing url"},
+		{"memory storage", memory, http.MethodPost, "/api/endpoints/recheck?url=example.com", http.StatusNotImplemented, "Redis"},
+		{"other method", mux, http.MethodPut, "/api/endpoints/recheck?url=example.com", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		rec := post(tt.mux, tt.method, tt.target)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d (%s)", tt.name, tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "10" {
+			t.Errorf("%s: Retry-After = %q, want 10", tt.name, rec.Header().Get("Retry-After"))
+		}
+	}
+
+	select {
+	case got := <-received:
+		if got != "https://example.com" {
+			t.Errorf("recheck published for %q, want https://example.com", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no recheck published")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected recheck of %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The page only offers rechecks it can request itself
+	for _, tokens := range [][]string{nil, {"secret"}} {
+		server, err := NewServer(Config{APITokens: tokens}, st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got, want := strings.Contains(rec.Body.String(), `class="recheck-btn"`), tokens == nil; got != want {
+			t.Errorf("API tokens %v: recheck button shown = %v, want %v", tokens, got, want)
+		}
+	}
+}
+
+// TestUptimeCells tests the uptime columns: rounded down to one decimal,
+// unknown without checks, and shown in the table
+func TestUptimeCells(t *testing.T) {
+	cells := newUptimeCells([]store.Uptime{
+		{Window: "24h", Checks: 10000, Up: 9999},
+		{Window: "7d", Checks: 0, Up: 0},
+		{Window: "30d", Checks: 200, Up: 197},
+	})
+	tests := []struct {
+		window    string
+		wantText  string
+		wantClass string
+		wantTitle string
+	}{
+		{"24h", "99.9%", "uptime-good", "9999 of 10000 checks up in the last 24h"},
+		{"7d", "—", "no-data", "No checks recorded in the last 7d"},
+		{"30d", "98.5%", "uptime-bad", "197 of 200 checks up in the last 30d"},
+	}
+	for i, tt := range tests {
+		cell := cells[i]
+		if cell.Window != tt.window || cell.Text() != tt.wantText || cell.Class() != tt.wantClass || cell.Title() != tt.wantTitle {
+			t.Errorf("cell %d = %s %q %q %q, want %s %q %q %q", i, cell.Window, cell.Text(), cell.Class(), cell.Title(),
+				tt.window, tt.wantText, tt.wantClass, tt.wantTitle)
+		}
+	}
+	if cell := newUptimeCells([]store.Uptime{{Window: "24h", Checks: 1000, Up: 999}})[0]; cell.Text() != "99.9%" || cell.Class() != "uptime-good" {
+		t.Errorf("999 of 1000 = %q %q, want 99.9%% uptime-good", cell.Text(), cell.Class())
+	}
+	if cell := newUptimeCells([]store.Uptime{{Window: "24h", Checks: 10000, Up: 9950}})[0]; cell.Text() != "99.5%" || cell.Class() != "uptime-warning" {
+		t.Errorf("9950 of 10000 = %q %q, want 99.5%% uptime-warning", cell.Text(), cell.Class())
+	}
+
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://example.com", CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}})
+	st.SaveUptime(ctx, "https://example.com", []store.Uptime{{Window: "24h", Checks: 4, Up: 3}})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, want := range []string{"<th>Uptime 24h</th>", "<th>Uptime 30d</th>", `title="3 of 4 checks up in the last 24h">75.0%</td>`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+// TestCheckErrorDisplay tests that the error of the last status check is
+// shown escaped in the table with the address it connected to and returned
+// by the API, and that an up check clears it
+func TestCheckErrorDisplay(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	endpoint := "https://example.com"
+	at := time.Now().UTC().Add(-3 * time.Minute).Truncate(time.Second)
+	st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: at, HasStatus: true, StatusCode: 0,
+		Error: &store.CheckError{Class: store.ErrorClassTimeout, Message: "dial: <b>i/o timeout</b>", At: at}, RemoteAddr: "192.0.2.10:443"}})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<th>Last error</th>",
+		`title="timeout: dial: &lt;b&gt;i/o timeout&lt;/b&gt; (via 192.0.2.10:443)"`,
+		`<td class="last-error" title="dial: &lt;b&gt;i/o timeout&lt;/b&gt; (via 192.0.2.10:443)">timeout, 3m ago</td>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<b>i/o") {
+		t.Error("page contains the unescaped error message")
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleAPIv1Endpoints(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil))
+	var list APIEndpointList
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if got := list.Endpoints[0]; got.ErrorClass != "timeout" || got.ErrorMessage != "dial: <b>i/o timeout</b>" || got.ErrorAt != at.Format(time.RFC3339) {
+		t.Errorf("API error = %q %q %q, want timeout with the message at %s", got.ErrorClass, got.ErrorMessage, got.ErrorAt, at.Format(time.RFC3339))
+	}
+	if got := list.Endpoints[0].RemoteAddr; got != "192.0.2.10:443" {
+		t.Errorf("API remote_addr = %q, want 192.0.2.10:443", got)
+	}
+
+	st.SaveResults(ctx, []store.Result{{Endpoint: endpoint, CheckedAt: time.Now(), HasStatus: true, StatusCode: 200, RemoteAddr: "192.0.2.11:443"}})
+	rec = httptest.NewRecorder()
+	server.handleAPIv1Endpoints(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil))
+	if strings.Contains(rec.Body.String(), "error_") {
+		t.Errorf("API still reports an error after an up check: %s", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `title="Served by 192.0.2.11:443"`) {
+		t.Error("page does not show the address of the up check")
+	}
+}
+
+// TestAlertStateColumn tests that the Alert column shows the state kept
+// by the checker, which holds a certificate level past the threshold it
+// has just recovered from
+func TestAlertStateColumn(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://held.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200,
+			Cert: &store.CertInfo{NotAfter: now.Add(31 * 24 * time.Hour), State: store.CertStateValid}, CertLevel: store.CertLevelWarning},
+		{Endpoint: "https://down.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<th>Alert</th>",
+		`<span class="alert-badge alert-warning">WARN</span>`,
+		`<span class="alert-badge alert-down">DOWN</span>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+// TestGroupEndpoints tests grouping by tag and registrable domain, the
+// counts of each group and group_by on the page and /api/summary
+func TestGroupEndpoints(t *testing.T) {
+	domains := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://example.com", "example.com"},
+		{"https://api.eu.example.com:8443/health", "example.com"},
+		{"https://shop.example.co.uk", "example.co.uk"},
+		{"https://example.co.uk", "example.co.uk"},
+		{"http://localhost:8080/", "localhost"},
+		{"http://10.0.0.7/status", "10.0.0.7"},
+		{"http://[::1]:8080/", "::1"},
+	}
+	for _, tt := range domains {
+		if got := registrableDomain(tt.endpoint); got != tt.want {
+			t.Errorf("registrableDomain(%s) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+
+	now := time.Now().UTC()
+	var endpoints []EndpointData
+	for _, stored := range []store.EndpointData{
+		{Endpoint: "https://pay.example.com", HasStatus: true, StatusCode: 200, Tags: []string{"prod", "payments"}},
+		{Endpoint: "https://api.example.org", HasStatus: true, StatusCode: 503, Tags: []string{"prod"}},
+		{Endpoint: "https://www.example.com", HasStatus: true, StatusCode: 200},
+	} {
+		endpoints = append(endpoints, newEndpointData(stored, now))
+	}
+	tests := []struct {
+		groupBy string
+		want    []string // name: endpoints, healthy
+	}{
+		{"none", []string{": 3, 2"}},
+		{"tag", []string{"payments: 1, 1", "prod: 2, 1", "untagged: 1, 1"}},
+		{"domain", []string{"example.com: 2, 2", "example.org: 1, 0"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, group := range groupEndpoints(endpoints, tt.groupBy, now) {
+			if len(group.Endpoints) != group.Summary.Total {
+				t.Errorf("group_by=%s: %s has %d endpoints, counted %d", tt.groupBy, group.Name, len(group.Endpoints), group.Summary.Total)
+			}
+			got = append(got, fmt.Sprintf("%s: %d, %d", group.Name, group.Summary.Total, group.Summary.Healthy))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("group_by=%s: groups = %q, want %q", tt.groupBy, got, tt.want)
+		}
+	}
+	if groups := groupEndpoints(nil, "tag", now); groups != nil {
+		t.Errorf("groups of no endpoints = %v, want none", groups)
+	}
+
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://pay.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: []string{"prod", "payments"}},
+		{Endpoint: "https://api.example.org", CheckedAt: now, HasStatus: true, StatusCode: 503, Tags: []string{"prod"}},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/?group_by=tag", nil))
+	for _, want := range []string{
+		`<tbody class="group" data-group="tag:payments">`,
+		`prod <span class="group-counts">2 endpoints · 1 healthy · 0 SSL expiring soon</span>`,
+		`<option value="tag" selected>`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), `class="group-header"`) {
+		t.Error("ungrouped page has group headings")
+	}
+	rec = httptest.NewRecorder()
+	server.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/?group_by=team", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `invalid group_by value "team"`) {
+		t.Errorf("group_by=team = %d %q, want 400", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.handleAPISummary(rec, httptest.NewRequest(http.MethodGet, "/api/summary?group_by=domain&tag=prod", nil))
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	wantGroups := []GroupSummary{
+		{Name: "example.com", Total: 1, Healthy: 1},
+		{Name: "example.org", Total: 1, Errors: 1},
+	}
+	if summary.Total != 2 || !slices.Equal(summary.Groups, wantGroups) {
+		t.Errorf("summary = %d endpoints in %+v, want 2 in %+v", summary.Total, summary.Groups, wantGroups)
+	}
+}
+
+// TestAcks tests acknowledging endpoints: set, listed and removed through
+// the API, left out of the counts, muted on the page and dropped from the
+// alerts
+func TestAcks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://down.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+		{Endpoint: "https://up.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	server, err := NewServer(Config{DashboardUsername: "alice", DashboardPassword: "secret", AllowWrite: true}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.handler()
+	readOnly := (&Server{store: st}).routes()
+	memory := (&Server{store: store.NewMemoryStore()}).routes()
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"ack", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "down.example.com", "reason": "planned migration", "duration": "2d"}`, http.StatusCreated, `"user":"alice"`},
+		{"form post", handler, http.MethodPost, "/api/endpoints/ack", "application/x-www-form-urlencoded", `url=down.example.com`, http.StatusUnsupportedMediaType, "Content-Type"},
+		{"not json", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `down.example.com`, http.StatusBadRequest, "Expected a body"},
+		{"no reason", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "down.example.com", "reason": " ", "duration": "1h"}`, http.StatusBadRequest, "reason"},
+		{"no duration", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "down.example.com", "reason": "x"}`, http.StatusBadRequest, "invalid duration"},
+		{"negative duration", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "down.example.com", "reason": "x", "duration": "-1h"}`, http.StatusBadRequest, "invalid duration"},
+		{"too long", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "down.example.com", "reason": "x", "duration": "91d"}`, http.StatusBadRequest, "at most 90d"},
+		{"unknown endpoint", handler, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "other.example.com", "reason": "x", "duration": "1h"}`, http.StatusNotFound, `"error":"not found"`},
+		{"list", handler, http.MethodGet, "/api/endpoints/acks", "", "", http.StatusOK, `"endpoint":"https://down.example.com","reason":"planned migration","user":"alice"`},
+		{"unack unknown", handler, http.MethodDelete, "/api/endpoints/ack?url=up.example.com", "", "", http.StatusNotFound, `"error":"not found"`},
+		{"unack without url", handler, http.MethodDelete, "/api/endpoints/ack", "", "", http.StatusBadRequest, "missing url"},
+		{"memory storage", memory, http.MethodGet, "/api/endpoints/acks", "", "", http.StatusNotImplemented, "Redis"},
+		{"read-only ack", readOnly, http.MethodPost, "/api/endpoints/ack", "application/json", `{"url": "up.example.com", "reason": "x", "duration": "1h"}`, http.StatusForbidden, "ALLOW_WRITE"},
+		{"read-only unack", readOnly, http.MethodDelete, "/api/endpoints/ack?url=down.example.com", "", "", http.StatusForbidden, "ALLOW_WRITE"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.SetBasicAuth("alice", "secret")
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d (%s)", tt.name, tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+	}
+	if ttl := mr.TTL("ack:https://down.example.com"); ttl <= 47*time.Hour || ttl > 48*time.Hour {
+		t.Errorf("ack TTL = %s, want 48h", ttl)
+	}
+
+	endpointData, _, err := server.getAllEndpointData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summarizeEndpoints(endpointData, now)
+	if summary.Total != 2 || summary.Healthy != 1 || summary.Errors != 0 || summary.Acknowledged != 1 {
+		t.Errorf("summary = %d total, %d healthy, %d errors, %d acknowledged; want 2, 1, 0, 1",
+			summary.Total, summary.Healthy, summary.Errors, summary.Acknowledged)
+	}
+	series := collectMetrics(endpointData, false, 0, now)
+	if len(series) != 2 || !series[0].acked || series[1].acked {
+		t.Errorf("acknowledged series = %v", series)
+	}
+	for _, ep := range endpointData {
+		if ep.Endpoint == "https://down.example.com" {
+			if api := newAPIEndpoint(ep); api.Acknowledgement == nil || api.Acknowledgement.Reason != "planned migration" || api.Acknowledgement.Endpoint != "" {
+				t.Errorf("API acknowledgement = %+v", api.Acknowledgement)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	for _, want := range []string{`<tr class="acknowledged">`, `title="Acknowledged by alice until `, `: planned migration"`, `<div class="stat-label">Acknowledged</div>`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	// Events of acknowledged endpoints are neither pushed nor in the feed
+	event := store.Event{Kind: store.EventKindStatus, Endpoint: "https://down.example.com", Old: store.StatusUp, New: store.StatusDown, Acknowledged: true}
+	if _, ok := feedEventTitle(event); ok {
+		t.Error("acknowledged event is in the feed")
+	}
+	hub := &eventHub{clients: make(map[*wsClient]struct{})}
+	client := &wsClient{queue: make(chan []byte, 1), endpoints: map[string]bool{wsSubscribeAll: true}}
+	hub.clients[client] = struct{}{}
+	hub.broadcast(event)
+	if len(client.queue) != 0 {
+		t.Error("acknowledged event was pushed")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/endpoints/ack?url=down.example.com", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || mr.Exists("ack:https://down.example.com") {
+		t.Errorf("unack = %d, ack kept: %v", rec.Code, mr.Exists("ack:https://down.example.com"))
+	}
+}
+
+// TestPauses tests pausing endpoints: set, listed and resumed through the
+// API, left out of the counts, greyed on the page, never stale and recorded
+// in the event stream
+func TestPauses(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	old := time.Now().UTC().Add(-time.Hour)
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://down.example.com", CheckedAt: old, HasStatus: true, StatusCode: 503},
+		{Endpoint: "https://up.example.com", CheckedAt: old, HasStatus: true, StatusCode: 200},
+	})
+	st.SaveHeartbeat(ctx, store.Heartbeat{At: time.Now().UTC(), StatusInterval: time.Minute, SSLInterval: time.Hour})
+	server, err := NewServer(Config{DashboardUsername: "alice", DashboardPassword: "secret", AllowWrite: true}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.handler()
+	readOnly := (&Server{store: st}).routes()
+	memory := (&Server{config: Config{AllowWrite: true}, store: store.NewMemoryStore()}).routes()
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		method     string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"pause", handler, http.MethodPost, "/api/endpoints/pause?url=down.example.com&for=4h&reason=replacing+the+load+balancer", http.StatusCreated, `"reason":"replacing the load balancer","user":"alice"`},
+		{"no duration", handler, http.MethodPost, "/api/endpoints/pause?url=up.example.com", http.StatusBadRequest, "invalid duration"},
+		{"too long", handler, http.MethodPost, "/api/endpoints/pause?url=up.example.com&for=91d", http.StatusBadRequest, "at most 90d"},
+		{"reason too long", handler, http.MethodPost, "/api/endpoints/pause?url=up.example.com&for=1h&reason=" + strings.Repeat("x", maxPauseReasonLength+1), http.StatusBadRequest, "reason"},
+		{"unknown endpoint", handler, http.MethodPost, "/api/endpoints/pause?url=other.example.com&for=1h", http.StatusNotFound, `"error":"not found"`},
+		{"list", handler, http.MethodGet, "/api/endpoints/pauses", http.StatusOK, `"endpoint":"https://down.example.com","reason":"replacing the load balancer","user":"alice"`},
+		{"resume unknown", handler, http.MethodDelete, "/api/endpoints/pause?url=up.example.com", http.StatusNotFound, `"error":"not found"`},
+		{"resume without url", handler, http.MethodDelete, "/api/endpoints/pause", http.StatusBadRequest, "missing url"},
+		{"memory storage", memory, http.MethodPost, "/api/endpoints/pause?url=up.example.com&for=1h", http.StatusNotImplemented, "Redis"},
+		{"read-only pause", readOnly, http.MethodPost, "/api/endpoints/pause?url=up.example.com&for=1h", http.StatusForbidden, "ALLOW_WRITE"},
+		{"read-only resume", readOnly, http.MethodDelete, "/api/endpoints/pause?url=down.example.com", http.StatusForbidden, "ALLOW_WRITE"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.SetBasicAuth("alice", "secret")
+		rec := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d (%s)", tt.name, tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+	}
+	if ttl := mr.TTL("pause:https://down.example.com"); ttl <= 3*time.Hour || ttl > 4*time.Hour {
+		t.Errorf("pause TTL = %s, want 4h", ttl)
+	}
+
+	endpointData, _, err := server.getAllEndpointData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summarizeEndpoints(endpointData, time.Now().UTC())
+	if summary.Total != 2 || summary.Healthy != 1 || summary.Errors != 0 || summary.Paused != 1 {
+		t.Errorf("summary = %d total, %d healthy, %d errors, %d paused; want 2, 1, 0, 1",
+			summary.Total, summary.Healthy, summary.Errors, summary.Paused)
+	}
+	for _, ep := range endpointData {
+		if paused := ep.Endpoint == "https://down.example.com"; ep.Stale == paused {
+			t.Errorf("%s: Stale = %v, want %v", ep.Endpoint, ep.Stale, !paused)
+		}
+		if ep.Endpoint == "https://down.example.com" {
+			if api := newAPIEndpoint(ep); api.Pause == nil || api.Pause.User != "alice" || api.Pause.Endpoint != "" {
+				t.Errorf("API pause = %+v", api.Pause)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	for _, want := range []string{`<tr class="paused">`, `title="Paused by alice until `, `: replacing the load balancer"`, `<div class="stat-label">Paused</div>`, `onclick="resume(this)"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	viewer, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	viewer.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, button := range []string{`onclick="resume(this)"`, `onclick="unacknowledge(this)"`, `onclick="acknowledge(this)"`} {
+		if strings.Contains(rec.Body.String(), button) {
+			t.Errorf("page without ALLOW_WRITE has %s", button)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/endpoints/pause?url=down.example.com", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || mr.Exists("pause:https://down.example.com") {
+		t.Errorf("resume = %d, pause kept: %v", rec.Code, mr.Exists("pause:https://down.example.com"))
+	}
+
+	events, err := st.Events(ctx, "", "https://down.example.com", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].New != store.PauseStatePaused || events[1].New != store.PauseStateActive || events[1].User != "alice" {
+		t.Errorf("events = %+v, want a pause and a resumption by alice", events)
+	}
+}
+
+// TestMaintenanceWindows tests managing maintenance windows through the
+// API and how endpoints checked during one are shown and counted
+func TestMaintenanceWindows(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://erp.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503, InMaintenance: true},
+		{Endpoint: "https://web.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+	})
+	server := &Server{config: Config{AllowWrite: true}, store: st}
+	writable := server.routes()
+	readOnly := (&Server{store: st}).routes()
+
+	do := func(mux *http.ServeMux, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do(writable, http.MethodPost, "/api/maintenance", `{"tag": "ERP", "days": "sun", "start": "02:00", "duration": "2h", "timezone": "Europe/Berlin", "reason": "batch jobs"}`)
+	var added store.MaintenanceWindow
+	if err := json.Unmarshal(rec.Body.Bytes(), &added); rec.Code != http.StatusCreated || err != nil || added.ID == "" || added.Tag != "erp" {
+		t.Fatalf("POST /api/maintenance = %d %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		mux        *http.ServeMux
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list", readOnly, http.MethodGet, "/api/maintenance", "", http.StatusOK, `"tag":"erp","days":"sun","start":"02:00","duration":"2h","timezone":"Europe/Berlin","reason":"batch jobs"`},
+		{"endpoint window", writable, http.MethodPost, "/api/maintenance", `{"endpoint": "web.example.com", "days": "mon-fri", "start": "22:30", "duration": "30m", "timezone": "UTC"}`, http.StatusCreated, `"endpoint":"https://web.example.com"`},
+		{"no timezone", writable, http.MethodPost, "/api/maintenance", `{"tag": "erp", "days": "sun", "start": "02:00", "duration": "2h"}`, http.StatusBadRequest, "missing timezone"},
+		{"bad days", writable, http.MethodPost, "/api/maintenance", `{"tag": "erp", "days": "sundays", "start": "02:00", "duration": "2h", "timezone": "UTC"}`, http.StatusBadRequest, "invalid days"},
+		{"bad url", writable, http.MethodPost, "/api/maintenance", `{"endpoint": "ftp://x.example.com", "days": "sun", "start": "02:00", "duration": "2h", "timezone": "UTC"}`, http.StatusBadRequest, "invalid url"},
+		{"not json", writable, http.MethodPost, "/api/maintenance", `sun 02:00`, http.StatusBadRequest, "Expected a body"},
+		{"read-only add", readOnly, http.MethodPost, "/api/maintenance", `{}`, http.StatusForbidden, "ALLOW_WRITE"},
+		{"remove", writable, http.MethodDelete, "/api/maintenance?id=" + added.ID, "", http.StatusNoContent, ""},
+		{"remove again", writable, http.MethodDelete, "/api/maintenance?id=" + added.ID, "", http.StatusNotFound, `"error":"not found"`},
+		{"remove without id", writable, http.MethodDelete, "/api/maintenance", "", http.StatusBadRequest, "missing id"},
+	}
+	for _, tt := range tests {
+		rec := do(tt.mux, tt.method, tt.target, tt.body)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: %s %s = %d, want %d (%s)", tt.name, tt.method, tt.target, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.name, rec.Body.String(), tt.wantBody)
+		}
+	}
+
+	endpointData, _, err := server.getAllEndpointData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := summarizeEndpoints(endpointData, now); summary.Errors != 1 || summary.InMaintenance != 1 {
+		t.Errorf("summary = %d errors, %d in maintenance; want 1, 1", summary.Errors, summary.InMaintenance)
+	}
+	page, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	page.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, want := range []string{`title="Checked during a maintenance window">🔧</span>`, `<div class="stat-label">In Maintenance</div>`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+
+	event := store.Event{Kind: store.EventKindStatus, Endpoint: "https://erp.example.com", Old: store.StatusUp, New: store.StatusDown, InMaintenance: true}
+	if _, ok := feedEventTitle(event); ok {
+		t.Error("event during maintenance is in the feed")
+	}
+}
+
+// TestMaintenanceWindowsMemory tests that maintenance windows can be
+// managed without Redis
+func TestMaintenanceWindowsMemory(t *testing.T) {
+	mux := (&Server{config: Config{AllowWrite: true}, store: store.NewMemoryStore()}).routes()
+	req := httptest.NewRequest(http.MethodPost, "/api/maintenance", strings.NewReader(`{"tag": "erp", "days": "sun", "start": "02:00", "duration": "2h", "timezone": "UTC"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/maintenance = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/maintenance", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"tag":"erp"`) {
+		t.Errorf("GET /api/maintenance = %d %s, want the added window", rec.Code, rec.Body.String())
+	}
+}
+
+// TestPublicStatus tests that the public status page lists only the named
+// endpoints tagged public, by state, and shows nothing else about them
+func TestPublicStatus(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	public := []string{"prod", store.PublicTag}
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://pay.internal.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: public, Name: "Payments API"},
+		{Endpoint: "https://www.internal.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503, Tags: public, Name: "Website"},
+		{Endpoint: "https://erp.internal.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503, Tags: public, Name: "ERP", InMaintenance: true},
+		{Endpoint: "https://unnamed.internal.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: public},
+		{Endpoint: "https://admin.internal.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: []string{"prod"}, Name: "Admin"},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/public", nil))
+	want := `{"status":"partial_outage","endpoints":[{"name":"ERP","state":"degraded"},{"name":"Payments API","state":"up"},{"name":"Website","state":"down"}]}` + "\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("GET /api/public = %d %s, want %s", rec.Code, rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, "Partial outage") || !strings.Contains(page, "Payments API") {
+		t.Errorf("GET /status = %d %s", rec.Code, page)
+	}
+	for _, hidden := range []string{"internal.example.com", "503", "Admin"} {
+		if strings.Contains(page, hidden) {
+			t.Errorf("GET /status shows %q", hidden)
+		}
+	}
+
+	percent := func(p float64) *float64 { return &p }
+	up := EndpointData{StatusCode: 200, StatusText: "200", Tags: public, Name: "A"}
+	degraded := up
+	degraded.Uptime = []UptimeCell{{Window: "24h", Percent: percent(98.5)}}
+	expired := up
+	expired.SSLExpiration = timePtr(now.Add(-time.Hour))
+	down := EndpointData{StatusCode: 0, StatusText: "0", Tags: public, Name: "B"}
+	tests := []struct {
+		name      string
+		endpoints []EndpointData
+		want      string
+	}{
+		{"none", nil, publicStatusOperational},
+		{"all up", []EndpointData{up}, publicStatusOperational},
+		{"low uptime", []EndpointData{up, degraded}, publicStatusDegraded},
+		{"expired certificate", []EndpointData{expired}, publicStatusMajorOutage},
+		{"some down", []EndpointData{degraded, down}, publicStatusPartialOutage},
+		{"all down", []EndpointData{down}, publicStatusMajorOutage},
+	}
+	for _, tt := range tests {
+		if got := newPublicStatus(tt.endpoints).Status; got != tt.want {
+			t.Errorf("%s: status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestViews tests loading VIEWS_FILE and applying its presets with view=
+// to the dashboard and the API
+func TestViews(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown parameter": "payments:\n  fields: endpoint\n",
+		"invalid value":     "payments:\n  sort: cost\n",
+		"invalid name":      "pay ments:\n  tag: payments\n",
+		"no views":          "# none yet\n",
+		"not a mapping":     "- payments\n",
+	} {
+		path := filepath.Join(dir, "invalid.yaml")
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := loadViews(path); err == nil {
+			t.Errorf("loadViews() of a file with %s succeeded", name)
+		}
+	}
+
+	path := filepath.Join(dir, "views.yaml")
+	os.WriteFile(path, []byte(`payments:
+  tag: payments
+  sort: status
+  order: desc
+infra:
+  sort: ssl
+  group_by: domain
+  https_only: true
+`), 0o644)
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	now := time.Now().UTC()
+	st.SaveResults(context.Background(), []store.Result{
+		{Endpoint: "https://pay.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: []string{"payments"}},
+		{Endpoint: "https://checkout.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503, Tags: []string{"payments"}},
+		{Endpoint: "http://intranet.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	server, err := NewServer(Config{ViewsFile: path}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantBody   []string // in this order
+	}{
+		{"/api/v1/endpoints?view=payments", http.StatusOK, []string{"checkout.example.com", "pay.example.com", `"total":2`}},
+		{"/api/v1/endpoints?view=payments&order=asc", http.StatusOK, []string{"pay.example.com", "checkout.example.com"}},
+		{"/api/v1/endpoints?view=infra", http.StatusOK, []string{`"total":2`}},
+		{"/api/endpoints?view=payments&fields=endpoint", http.StatusOK, []string{`"total":2`}},
+		{"/api/summary?view=infra", http.StatusOK, []string{`"groups":[{"name":"example.com","total":2`}},
+		{"/api/v1/endpoints?view=ops", http.StatusNotFound, []string{`Unknown view "ops" (valid: infra, payments)`}},
+		{"/?view=payments", http.StatusOK, []string{`<option value="payments" selected>View: payments</option>`, "checkout.example.com"}},
+		{"/?view=ops", http.StatusNotFound, []string{"valid: infra, payments"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		body := rec.Body.String()
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d (%s)", tt.target, rec.Code, tt.wantStatus, body)
+		}
+		rest := body
+		for _, want := range tt.wantBody {
+			i := strings.Index(rest, want)
+			if i < 0 {
+				t.Errorf("GET %s = %s, want %q in order", tt.target, body, want)
+				break
+			}
+			rest = rest[i+len(want):]
+		}
+	}
+
+	plain, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	plain.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints?view=payments", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "none are configured") {
+		t.Errorf("GET ?view= without VIEWS_FILE = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	plain.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(rec.Body.String(), `name="view"`) {
+		t.Error("dashboard without VIEWS_FILE shows the view selector")
+	}
+}
+
+// TestDisplayTimezone tests that the page shows absolute times in
+// DISPLAY_TIMEZONE or the tz parameter while the API stays in UTC
+func TestDisplayTimezone(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	st.SaveResults(context.Background(), []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 200},
+	})
+	if _, err := NewServer(Config{DisplayTimezone: "Australia/Melbourne Cup"}, st); err == nil || !strings.Contains(err.Error(), `"Australia/Melbourne Cup"`) {
+		t.Errorf("NewServer() with an invalid DISPLAY_TIMEZONE = %v, want an error naming it", err)
+	}
+	server, err := NewServer(Config{DisplayTimezone: "Australia/Sydney"}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	zone := func(location *time.Location) string {
+		name, _ := time.Now().In(location).Zone()
+		return name
+	}
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{"/", http.StatusOK, " " + zone(sydney) + "</div>"},
+		{"/?tz=Europe/Berlin", http.StatusOK, " " + zone(berlin) + "</div>"},
+		{"/?tz=Europe/Berlin&q=example", http.StatusOK, `<input type="hidden" name="tz" value="Europe/Berlin">`},
+		{"/?tz=Mars/Olympus", http.StatusBadRequest, `invalid tz value "Mars/Olympus"`},
+		{"/?format=text", http.StatusOK, " " + zone(sydney) + ": 1 endpoints"},
+		{"/api/v1/endpoints?tz=Europe/Berlin", http.StatusOK, `Z","alert_state":"ok"}]`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("GET %s = %d %s, want %d containing %q", tt.target, rec.Code, rec.Body.String(), tt.wantStatus, tt.want)
+		}
+	}
+
+	until := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	ep := EndpointData{Ack: &store.Ack{User: "alice", Until: until, Reason: "migration"}}
+	if got, want := ep.AckTitle(sydney), "Acknowledged by alice until 2024-07-01 22:00 AEST: migration"; got != want {
+		t.Errorf("AckTitle() = %q, want %q", got, want)
+	}
+}
+
+// TestAutoRefresh tests the page's reload interval from
+// AUTO_REFRESH_SECONDS and the refresh parameter
+func TestAutoRefresh(t *testing.T) {
+	st := store.NewMemoryStore()
+	if _, err := NewServer(Config{AutoRefresh: -1}, st); err == nil {
+		t.Error("NewServer() with a negative AUTO_REFRESH_SECONDS succeeded")
+	}
+	server, err := NewServer(Config{AutoRefresh: 45}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reload := regexp.MustCompile(`setTimeout\(\(\) => location\.reload\(\), *(\d+) *\* 1000\)`)
+
+	tests := []struct {
+		target      string
+		wantStatus  int
+		wantSeconds string // "" for no reload
+		wantHidden  bool
+	}{
+		{"/", http.StatusOK, "45", false},
+		{"/?refresh=30&sort=status", http.StatusOK, "30", true},
+		{"/?refresh=0", http.StatusOK, "", true},
+		{"/?refresh=soon", http.StatusBadRequest, "", false},
+		{"/?refresh=-5", http.StatusBadRequest, "", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		body := rec.Body.String()
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s = %d, want %d (%s)", tt.target, rec.Code, tt.wantStatus, body)
+			continue
+		}
+		seconds := ""
+		if match := reload.FindStringSubmatch(body); match != nil {
+			seconds = match[1]
+		}
+		if rec.Code == http.StatusOK && seconds != tt.wantSeconds {
+			t.Errorf("GET %s reloads after %q seconds, want %q", tt.target, seconds, tt.wantSeconds)
+		}
+		if hidden := strings.Contains(body, `<input type="hidden" name="refresh"`); hidden != tt.wantHidden {
+			t.Errorf("GET %s keeps refresh in the search form: %v, want %v", tt.target, hidden, tt.wantHidden)
+		}
+	}
+}
+
+// TestTemplates tests rendering the embedded templates without any files
+// on disk, and replacing them with those of TEMPLATE_DIR
+func TestTemplates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tmpl, err := parseTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := 12
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	endpoint := EndpointData{
+		Endpoint: "https://example.com", StatusCode: 200, StatusText: "200", StatusClass: "status-success",
+		DaysLeft: &days, SSLText: "12 days left", SSLClass: "ssl-warning", LastStatusUpdate: &updated,
+		UpdateText: "1m ago", IsHTTPS: true, Uptime: newUptimeCells(nil),
+	}
+	data := DashboardData{
+		Endpoints: []EndpointData{endpoint}, Groups: []EndpointGroup{{Endpoints: []EndpointData{endpoint}}},
+		TotalEndpoints: 1, HealthyCount: 1, SSLWarningCount: 1, CurrentTime: "12:00:00 UTC",
+		Location: time.UTC, GroupBy: "none", UptimeWindows: uptimeWindowNames(), AutoRefresh: 60,
+	}
+	var page strings.Builder
+	if err := tmpl.Execute(&page, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"https://example.com", "12 days left", "Last refreshed: 12:00:00 UTC"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("index.html does not show %q", want)
+		}
+	}
+	page.Reset()
+	if err := tmpl.ExecuteTemplate(&page, "status.html", PublicStatus{Status: publicStatusOperational, Endpoints: []PublicEndpoint{{Name: "Shop", State: publicStateUp}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), "All systems operational") {
+		t.Errorf("status.html = %s", page.String())
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte(`<h1>ACME status</h1>{{range .Endpoints}}{{.Endpoint}}{{end}}`), 0o644)
+	if _, err := parseTemplates(dir); err == nil || !strings.Contains(err.Error(), dir) {
+		t.Errorf("parseTemplates() without status.html = %v, want an error naming %s", err, dir)
+	}
+	os.WriteFile(filepath.Join(dir, "status.html"), []byte(`{{.Banner}}`), 0o644)
+	server, err := NewServer(Config{TemplateDir: dir}, store.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "<h1>ACME status</h1>" {
+		t.Errorf("GET / with TEMPLATE_DIR = %q", rec.Body.String())
+	}
+}
+
+// TestTemplateReload tests that TEMPLATE_RELOAD picks up changed templates
+// and shows parse errors as a page
+func TestTemplateReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(index string) {
+		os.WriteFile(filepath.Join(dir, "index.html"), []byte(index), 0o644)
+		os.WriteFile(filepath.Join(dir, "status.html"), []byte(`{{.Banner | upper}}`), 0o644)
+	}
+	if _, err := NewServer(Config{TemplateReload: true}, store.NewMemoryStore()); err == nil {
+		t.Error("NewServer() with TEMPLATE_RELOAD and no TEMPLATE_DIR succeeded")
+	}
+	write(`{{.Broken`)
+	if _, err := NewServer(Config{TemplateDir: dir}, store.NewMemoryStore()); err == nil {
+		t.Error("NewServer() with a broken template succeeded")
+	}
+	server, err := NewServer(Config{TemplateDir: dir, TemplateReload: true}, store.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewServer() with TEMPLATE_RELOAD and a broken template = %v, want it to start", err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	log.SetOutput(io.Discard)
+	rec := get("/")
+	log.SetOutput(os.Stderr)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "<h1>Template error</h1>") || !strings.Contains(rec.Body.String(), "index.html") {
+		t.Errorf("GET / with a broken template = %d %s", rec.Code, rec.Body.String())
+	}
+
+	write(`{{lower "ACME"}} {{.TotalEndpoints}} {{percent 199 200}} {{percent 1 0}}`)
+	if rec := get("/"); rec.Code != http.StatusOK || rec.Body.String() != "acme 0 99.5% —" {
+		t.Errorf("GET / after fixing the template = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := get("/status"); rec.Body.String() != "ALL SYSTEMS OPERATIONAL" {
+		t.Errorf("GET /status = %q", rec.Body.String())
+	}
+}
+
+// TestFormatTime tests the formatTime template function
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	tests := []struct {
+		name     string
+		value    any
+		location []*time.Location
+		want     string
+		wantErr  bool
+	}{
+		{"time", at, nil, "2024-03-01 12:00", false},
+		{"pointer", &at, nil, "2024-03-01 12:00", false},
+		{"in a zone", at, []*time.Location{sydney}, "2024-03-01 23:00", false},
+		{"nil pointer", (*time.Time)(nil), nil, "", false},
+		{"zero", time.Time{}, nil, "", false},
+		{"not a time", "yesterday", nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := formatTime("2006-01-02 15:04", tt.value, tt.location...)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: formatTime() = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestCheckerHeartbeat tests that endpoints the checker stopped updating are
+// marked stale, unless their schedule paused them, and that an old
+// heartbeat shows when it was last seen
+func TestCheckerHeartbeat(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://fresh.example.com", CheckedAt: now.Add(-time.Minute), HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://stale.example.com", CheckedAt: now.Add(-2 * time.Hour), HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://office.example.com", CheckedAt: now.Add(-12 * time.Hour), HasStatus: true, StatusCode: 200},
+	})
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://office.example.com", CheckedAt: now.Add(-time.Minute), PausedBySchedule: true}})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	// Without a heartbeat the interval is unknown, so nothing is stale
+	if body := get("/api/v1/endpoints?fields=endpoint,stale"); strings.Contains(body, "true") {
+		t.Errorf("stale without a heartbeat: %s", body)
+	}
+	if body := get("/api/summary"); strings.Contains(body, "checker_last_seen") {
+		t.Errorf("checker_last_seen without a heartbeat: %s", body)
+	}
+
+	tests := []struct {
+		name        string
+		lastSeen    time.Duration
+		wantBanner  bool
+		wantSummary string
+	}{
+		{"checker running", time.Minute, false, `"checker_last_seen":"` + now.Add(-time.Minute).Format(time.RFC3339) + `"`},
+		{"checker stopped", 2 * time.Hour, true, `"checker_last_seen":"` + now.Add(-2*time.Hour).Format(time.RFC3339) + `"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st.SaveHeartbeat(ctx, store.Heartbeat{At: now.Add(-tt.lastSeen), StatusInterval: time.Minute, SSLInterval: time.Hour})
+
+			want := `{"endpoints":[{"endpoint":"https://fresh.example.com","paused_by_schedule":false,"stale":false},` +
+				`{"endpoint":"https://office.example.com","paused_by_schedule":true,"stale":false},` +
+				`{"endpoint":"https://stale.example.com","paused_by_schedule":false,"stale":true}],"total":3}` + "\n"
+			if body := get("/api/v1/endpoints?fields=endpoint,stale,paused_by_schedule"); body != want {
+				t.Errorf("GET /api/v1/endpoints = %s, want %s", body, want)
+			}
+			if body := get("/api/v1/endpoints"); strings.Count(body, `"stale":true`) != 1 {
+				t.Errorf("GET /api/v1/endpoints = %s, want one stale item", body)
+			}
+			if body := get("/api/summary"); !strings.Contains(body, tt.wantSummary) {
+				t.Errorf("GET /api/summary = %s, want %s", body, tt.wantSummary)
+			}
+			page := get("/")
+			if !strings.Contains(page, `<tr class="stale">`) {
+				t.Error("page has no stale row")
+			}
+			if !strings.Contains(page, `title="Paused by its schedule: not checked at this time"`) {
+				t.Error("page does not mark the paused endpoint")
+			}
+			if got := strings.Contains(page, "Checker last seen 2h ago"); got != tt.wantBanner {
+				t.Errorf("page shows the checker banner = %v, want %v", got, tt.wantBanner)
+			}
+			text := get("/?format=text")
+			if !strings.Contains(text, "2h ago (stale)") || !strings.Contains(text, "12h ago (paused)") {
+				t.Errorf("text output has no stale and paused rows:\n%s", text)
+			}
+			if got := strings.Contains(text, "WARNING: Checker last seen 2h ago"); got != tt.wantBanner {
+				t.Errorf("text output shows the checker warning = %v, want %v", got, tt.wantBanner)
+			}
+		})
+	}
+}
+
+// TestSummaryCheckers tests that /api/summary lists the checker instances
+// heard from within the stale period, the most recent first, and that the
+// page names the instance behind each status check
+func TestSummaryCheckers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, CheckedBy: "eu-west-1"}})
+	for _, heartbeat := range []store.Heartbeat{
+		{At: now.Add(-time.Hour), StatusInterval: time.Minute, CheckerID: "ap-south-1"},
+		{At: now.Add(-2 * time.Minute), StatusInterval: time.Minute, CheckerID: "us-east-1"},
+		{At: now.Add(-time.Minute), StatusInterval: time.Minute, CheckerID: "eu-west-1"},
+	} {
+		if err := st.SaveHeartbeat(ctx, heartbeat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/summary", nil))
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("GET /api/summary = %d %s: %v", rec.Code, rec.Body, err)
+	}
+	want := []SummaryChecker{{ID: "eu-west-1", LastHeartbeat: now.Add(-time.Minute)}, {ID: "us-east-1", LastHeartbeat: now.Add(-2 * time.Minute)}}
+	if !reflect.DeepEqual(summary.Checkers, want) {
+		t.Errorf("summary checkers = %+v, want %+v", summary.Checkers, want)
+	}
+
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `title="Checked by eu-west-1"`) {
+		t.Error("page does not name the checker of the status check")
+	}
+}
+
+// TestOpenAPI tests that the OpenAPI document is served, that its schemas
+// list the fields of the API structs, and that actual responses match them
+func TestOpenAPI(t *testing.T) {
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	schemas := spec["components"].(map[string]any)["schemas"].(map[string]any)
+
+	// Every schema backed by a struct lists exactly its JSON fields, and
+	// requires those marshaled even when empty
+	for name, value := range map[string]any{
+		"EndpointList":       APIEndpointList{},
+		"EndpointSelection":  APISelectedEndpointList{},
+		"Endpoint":           APIEndpoint{},
+		"Certificate":        APICertificate{},
+		"HeaderAudit":        APIHeaderAudit{},
+		"CapturedHeaders":    APICaptured{},
+		"Acknowledgement":    APIAck{},
+		"EndpointDetail":     EndpointDetail{},
+		"EndpointData":       EndpointData{},
+		"UptimeCell":         UptimeCell{},
+		"SSLObservation":     SSLObservation{},
+		"HistorySummary":     HistorySummary{},
+		"HistoryEntry":       HistoryEntry{},
+		"LatencyRollup":      LatencyRollup{},
+		"LatencyPercentiles": LatencyPercentiles{},
+		"Summary":            Summary{},
+		"GroupSummary":       GroupSummary{},
+		"SummaryExpiry":      SummaryExpiry{},
+		"SummaryUpdate":      SummaryUpdate{},
+		"PublicStatus":       PublicStatus{},
+		"PublicEndpoint":     PublicEndpoint{},
+		"SLO":                APISLO{},
+		"Version":            version.Info{},
+	} {
+		schema, ok := schemas[name].(map[string]any)
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		var properties, required []string
+		for property := range schema["properties"].(map[string]any) {
+			properties = append(properties, property)
+		}
+		listed, _ := schema["required"].([]any)
+		for _, property := range listed {
+			required = append(required, property.(string))
+		}
+		var fields, wantRequired []string
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			field, omitempty := typ.Field(i).Name, false
+			if tag, ok := typ.Field(i).Tag.Lookup("json"); ok {
+				field, _, _ = strings.Cut(tag, ",")
+				omitempty = strings.HasSuffix(tag, ",omitempty")
+			}
+			fields = append(fields, field)
+			if !omitempty {
+				wantRequired = append(wantRequired, field)
+			}
+		}
+		slices.Sort(properties)
+		slices.Sort(fields)
+		slices.Sort(required)
+		slices.Sort(wantRequired)
+		if !slices.Equal(properties, fields) {
+			t.Errorf("schema %s properties = %v, want the fields of %s: %v", name, properties, typ.Name(), fields)
+		}
+		if !slices.Equal(required, wantRequired) {
+			t.Errorf("schema %s required = %v, want %v", name, required, wantRequired)
+		}
+	}
+
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	endpoint := "https://example.com"
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: endpoint, CheckedAt: now.Add(-time.Hour), HasStatus: true, StatusCode: 200, Latency: 30 * time.Millisecond, Tags: []string{"prod", store.PublicTag}, Name: "Website"},
+		{Endpoint: "http://down.example.com", CheckedAt: now.Add(-time.Hour), HasStatus: true, StatusCode: 0,
+			Error: &store.CheckError{Class: store.ErrorClassTimeout, Message: "i/o timeout", At: now.Add(-time.Hour)}},
+	})
+	st.SaveResults(ctx, []store.Result{{
+		Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 503, Latency: 40 * time.Millisecond, Tags: []string{"prod", store.PublicTag}, Name: "Website",
+		Cert:        &store.CertInfo{NotBefore: now.Add(-24 * time.Hour), NotAfter: now.Add(20 * 24 * time.Hour), Subject: "CN=example.com", Issuer: "CN=R3", SerialNumber: "3a", Fingerprint: "ab12", State: store.CertStateValid},
+		HeaderAudit: &store.HeaderAudit{Headers: map[string]string{"Strict-Transport-Security": "max-age=60"}, Failures: []string{"Strict-Transport-Security max-age below 31536000"}, Updated: now},
+		Captured:    &store.CapturedHeaders{Headers: map[string]string{"Server": "nginx", "X-Cache": "MISS"}, Changed: now},
+	}})
+	st.SaveLatencyRollups(ctx, endpoint, []store.LatencyRollup{{Hour: now.Truncate(time.Hour), Count: 2, Min: 30 * time.Millisecond, Avg: 35 * time.Millisecond, P95: 40 * time.Millisecond, Max: 40 * time.Millisecond, Checks: 2, Up: 1}})
+	st.Acknowledge(ctx, store.Ack{Endpoint: "http://down.example.com", Reason: "migration", User: "ops", At: now, Until: now.Add(time.Hour)})
+	st.SaveHeartbeat(ctx, store.Heartbeat{At: now, StatusInterval: time.Minute, SSLInterval: time.Hour})
+	st.SaveSLOStatuses(ctx, []store.SLOStatus{{Tag: "prod", Objective: 99.9, Window: "30d", Endpoints: 1, Checks: 2, Good: 1, Compliance: 50, BudgetRemaining: -499, BurnRate: 500, BurnRateThreshold: 14.4, Burning: true, UpdatedAt: now}})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	escaped := url.PathEscape(endpoint)
+	tests := []struct {
+		path   string // path of the spec
+		target string
+	}{
+		{"/api/v1/endpoints", "/api/v1/endpoints"},
+		{"/api/v1/endpoints", "/api/v1/endpoints?status=error&sort=status"},
+		{"/api/v1/endpoints", "/api/v1/endpoints?fields=endpoint,status_code,days_left"},
+		{"/api/endpoints/{url}", "/api/endpoints/" + escaped},
+		{"/api/endpoints/detail", "/api/endpoints/detail?url=" + url.QueryEscape("http://down.example.com")},
+		{"/api/endpoints/{url}/history", "/api/endpoints/" + escaped + "/history?since=7d"},
+		{"/api/endpoints/{url}/latency", "/api/endpoints/" + escaped + "/latency"},
+		{"/api/endpoints/{url}/percentiles", "/api/endpoints/" + escaped + "/percentiles"},
+		{"/api/summary", "/api/summary"},
+		{"/api/summary", "/api/summary?group_by=tag"},
+		{"/api/public", "/api/public"},
+		{"/api/slo", "/api/slo"},
+		{"/api/version", "/api/version"},
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		t.Fatalf("openapi.json is not a valid OpenAPI 3 document: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			pathItem := doc.Paths.Value(tt.path)
+			if pathItem == nil || pathItem.Get == nil {
+				t.Fatalf("spec has no GET %s", tt.path)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d %s", tt.target, rec.Code, rec.Body.String())
+			}
+			// The route is known, so the escaped URLs of {url} need no router
+			err := openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request: req,
+					Route:   &routers.Route{Spec: doc, Path: tt.path, PathItem: pathItem, Method: http.MethodGet, Operation: pathItem.Get},
+				},
+				Status: rec.Code,
+				Header: rec.Header(),
+				Body:   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+			})
+			if err != nil {
+				t.Errorf("GET %s does not match the spec: %v\n%s", tt.target, err, rec.Body.String())
+			}
+		})
+	}
+
+	// Served as is, or with the base path as its server
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || !bytes.Equal(rec.Body.Bytes(), openAPISpec) {
+		t.Errorf("GET /api/openapi.json = %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	server.config.BasePath = "/certs"
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if !strings.Contains(rec.Body.String(), `"servers":[{"url":"/certs"}]`) {
+		t.Errorf("GET /api/openapi.json under /certs has no server: %s", rec.Body.String())
+	}
+}
+
+// TestContentNegotiation tests that / serves HTML, JSON or text by the
+// q-values of the Accept header, and the same JSON as /api/endpoints
+func TestContentNegotiation(t *testing.T) {
+	offers := []string{"text/html", "application/json", "text/plain"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/html"},
+		{"*/*", "text/html"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html"},
+		{"application/json", "application/json"},
+		{"text/plain", "text/plain"},
+		{"text/plain, text/html", "text/html"},
+		{"text/html;q=0.5, application/json", "application/json"},
+		{"application/json;q=0.2, text/plain;q=0.8", "text/plain"},
+		{"text/*;q=0.3, application/json;q=0.2", "text/html"},
+		{"text/*, text/html;q=0", "text/plain"},
+		{"*/*;q=0.1, application/json;q=0.9", "application/json"},
+		{"*/*, text/html;q=0, text/plain;q=0", "application/json"},
+		{"image/png", "text/html"},
+		{"text/html;q=0, application/json;q=0, text/plain;q=0", "text/html"},
+		{"application/json;q=bogus, text/plain", "text/plain"},
+		{"APPLICATION/JSON", "application/json"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		if got := negotiate(req, offers...); got != tt.want {
+			t.Errorf("negotiate(Accept: %q) = %s, want %s", tt.accept, got, tt.want)
+		}
+	}
+
+	st := store.NewMemoryStore()
+	st.SaveResults(context.Background(), []store.Result{
+		{Endpoint: "https://example.com", CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://down.example.com", CheckedAt: time.Now().UTC(), HasStatus: true, StatusCode: 503},
+	})
+	server, err := NewServer(Config{}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, target := range []string{"/", "/?status=5xx&fields=endpoint"} {
+		page := get(target, "application/json")
+		api := get("/api"+strings.Replace(target, "/", "/endpoints", 1), "")
+		if page.Code != http.StatusOK || page.Header().Get("Content-Type") != "application/json" || page.Body.String() != api.Body.String() {
+			t.Errorf("GET %s as JSON = %d %s %s, want the body of /api/endpoints: %s",
+				target, page.Code, page.Header().Get("Content-Type"), page.Body, api.Body)
+		}
+		if page.Header().Get("Deprecation") != "" || page.Header().Get("Vary") != "Accept" {
+			t.Errorf("GET %s as JSON: Deprecation %q, Vary %q; want none and Accept", target, page.Header().Get("Deprecation"), page.Header().Get("Vary"))
+		}
+	}
+	for accept, want := range map[string]string{
+		"text/html":                  "text/html; charset=utf-8",
+		"text/plain":                 "text/plain; charset=utf-8",
+		"application/json;q=0.1,*/*": "text/html; charset=utf-8",
+	} {
+		if rec := get("/", accept); rec.Header().Get("Content-Type") != want {
+			t.Errorf("GET / with Accept %q: Content-Type %q, want %q", accept, rec.Header().Get("Content-Type"), want)
+		}
+	}
+	if rec := get("/?format=text", "application/json"); rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("format=text with Accept application/json: Content-Type %q, want text", rec.Header().Get("Content-Type"))
+	}
+	if rec := get("/api/endpoints", "text/html"); rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Deprecation") != "true" {
+		t.Errorf("GET /api/endpoints with Accept text/html = %q, Deprecation %q", rec.Header().Get("Content-Type"), rec.Header().Get("Deprecation"))
+	}
+}
+
+// blockingStore counts bulk reads, each waiting for release
+type blockingStore struct {
+	*store.MemoryStore
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (s *blockingStore) ListEndpointData(ctx context.Context) ([]store.EndpointData, error) {
+	s.reads.Add(1)
+	<-s.release
+	return s.MemoryStore.ListEndpointData(ctx)
+}
+
+// TestEndpointDataCache tests that assembled endpoint data is reused for
+// CACHE_TTL by one read shared between concurrent requests, that filters
+// and sorting leave it intact, and that the write API invalidates it
+func TestEndpointDataCache(t *testing.T) {
+	if _, err := NewServer(Config{CacheTTL: -time.Second}, store.NewMemoryStore()); err == nil || !strings.Contains(err.Error(), "CACHE_TTL") {
+		t.Errorf("NewServer with a negative CACHE_TTL: err = %v", err)
+	}
+
+	blocking := &blockingStore{MemoryStore: store.NewMemoryStore(), release: make(chan struct{})}
+	blocking.SaveResults(context.Background(), []store.Result{{Endpoint: "https://example.com", CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}})
+	server, err := NewServer(Config{CacheTTL: time.Minute}, blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, _, err := server.getAllEndpointData(context.Background()); err != nil || len(data) != 1 {
+				t.Errorf("getAllEndpointData = %d endpoints, %v", len(data), err)
+			}
+		}()
+	}
+	for blocking.reads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // let the other requests join the read
+	close(blocking.release)
+	wg.Wait()
+	if reads := blocking.reads.Load(); reads != 1 {
+		t.Errorf("10 concurrent requests read storage %d times, want 1", reads)
+	}
+	server.getAllEndpointData(context.Background())
+	if reads := blocking.reads.Load(); reads != 1 {
+		t.Errorf("a request within CACHE_TTL read storage again (%d reads)", reads)
+	}
+	server.assembled.expires = time.Now()
+	server.getAllEndpointData(context.Background())
+	if reads := blocking.reads.Load(); reads != 2 {
+		t.Errorf("a request after CACHE_TTL did not read storage (%d reads)", reads)
+	}
+
+	mr := miniredis.RunT(t)
+	st := store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://a.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+		{Endpoint: "https://b.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	server, err = NewServer(Config{CacheTTL: time.Minute, DashboardUsername: "admin", DashboardPassword: "secret", AllowWrite: true}, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+	endpoints := func(target string) string {
+		t.Helper()
+		var list APIEndpointList
+		if err := json.Unmarshal(do(http.MethodGet, target, "").Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, ep := range list.Endpoints {
+			name := strings.TrimSuffix(strings.TrimPrefix(ep.Endpoint, "https://"), ".example.com")
+			if ep.Acknowledgement != nil {
+				name += "(acked)"
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := endpoints("/api/v1/endpoints"); got != "a,b" {
+		t.Fatalf("endpoints = %s, want a,b", got)
+	}
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://c.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200}})
+	for target, want := range map[string]string{
+		"/api/v1/endpoints":                          "a,b",
+		"/api/v1/endpoints?status=ok":                "b",
+		"/api/v1/endpoints?sort=endpoint&order=desc": "b,a",
+	} {
+		if got := endpoints(target); got != want {
+			t.Errorf("cached GET %s = %s, want %s", target, got, want)
+		}
+	}
+	if got := endpoints("/api/v1/endpoints"); got != "a,b" {
+		t.Errorf("cached endpoints after sorting = %s, want a,b in URL order", got)
+	}
+
+	if rec := do(http.MethodPost, "/api/endpoints/ack", `{"url": "https://a.example.com", "reason": "known", "duration": "1h"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/endpoints/ack = %d %s", rec.Code, rec.Body)
+	}
+	if got := endpoints("/api/v1/endpoints"); got != "a(acked),b,c" {
+		t.Errorf("endpoints after acknowledging = %s, want a(acked),b,c", got)
+	}
+	if rec := do(http.MethodDelete, "/api/endpoints/ack?url=https://a.example.com", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /api/endpoints/ack = %d %s", rec.Code, rec.Body)
+	}
+	if got := endpoints("/api/v1/endpoints"); got != "a,b,c" {
+		t.Errorf("endpoints after removing the acknowledgement = %s, want a,b,c", got)
+	}
+	if rec := do(http.MethodDelete, "/api/endpoints?url=https://c.example.com", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /api/endpoints = %d %s", rec.Code, rec.Body)
+	}
+	if got := endpoints("/api/v1/endpoints"); got != "a,b" {
+		t.Errorf("endpoints after removing c = %s, want a,b", got)
+	}
+}
+
+// TestEndpointListETag tests conditional requests on both endpoint lists
+func TestEndpointListETag(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	st.SaveResults(ctx, []store.Result{
+		{Endpoint: "https://a.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+		{Endpoint: "https://b.example.com", CheckedAt: now, HasStatus: true, StatusCode: 503},
+	})
+	server := &Server{store: st}
+
+	for _, path := range []string{"/api/v1/endpoints", "/api/endpoints"} {
+		handler := server.handleAPIv1Endpoints
+		if path == "/api/endpoints" {
+			handler = server.handleAPIEndpoints
+		}
+		get := func(query, ifNoneMatch string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path+query, nil)
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			return rec
+		}
+
+		t.Run(path, func(t *testing.T) {
+			first := get("", "")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || !regexp.MustCompile(`^"[0-9a-f]{32}"$`).MatchString(etag) {
+				t.Fatalf("GET = %d with ETag %q, want 200 with a strong ETag", first.Code, etag)
+			}
+			if got := first.Header().Get("Cache-Control"); got != "no-cache" {
+				t.Errorf("Cache-Control = %q, want no-cache", got)
+			}
+
+			for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+				rec := get("", ifNoneMatch)
+				if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+					t.Errorf("If-None-Match: %s = %d with %d bytes and ETag %q, want an empty 304 with the same ETag", ifNoneMatch, rec.Code, rec.Body.Len(), rec.Header().Get("ETag"))
+				}
+			}
+			if rec := get("", `"other"`); rec.Code != http.StatusOK || rec.Body.String() != first.Body.String() {
+				t.Errorf("If-None-Match with another tag = %d, want the full 200", rec.Code)
+			}
+
+			variants := map[string]string{"": etag}
+			for _, query := range []string{"?status=5xx", "?sort=status&order=desc", "?fields=endpoint"} {
+				rec := get(query, etag)
+				if rec.Code != http.StatusOK {
+					t.Errorf("%s with the unfiltered ETag = %d, want 200", query, rec.Code)
+				}
+				for other, otherTag := range variants {
+					if rec.Header().Get("ETag") == otherTag {
+						t.Errorf("%s has the ETag of %q", query, other)
+					}
+				}
+				variants[query] = rec.Header().Get("ETag")
+			}
+		})
+	}
+
+	// New data changes the tag
+	before := httptest.NewRecorder()
+	server.handleAPIv1Endpoints(before, httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil))
+	st.SaveResults(ctx, []store.Result{{Endpoint: "https://b.example.com", CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 200}})
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil)
+	req.Header.Set("If-None-Match", before.Header().Get("ETag"))
+	after := httptest.NewRecorder()
+	server.handleAPIv1Endpoints(after, req)
+	if after.Code != http.StatusOK || after.Header().Get("ETag") == before.Header().Get("ETag") {
+		t.Errorf("after a check = %d with ETag %q, want 200 with a new ETag", after.Code, after.Header().Get("ETag"))
+	}
+}
+
+// TestGzipHandler tests response compression: large text responses are
+// gzipped for clients accepting it, small ones and other clients are not
+func TestGzipHandler(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	var results []store.Result
+	for i := 0; i < 300; i++ {
+		results = append(results, store.Result{
+			Endpoint:   fmt.Sprintf("https://service-%d.eu-west-1.example.com/health", i),
+			CheckedAt:  now.Add(-time.Duration(i) * time.Second),
+			HasStatus:  true,
+			StatusCode: []int{200, 200, 200, 301, 503}[i%5],
+			Cert: &store.CertInfo{
+				NotBefore:    now.AddDate(0, -2, 0),
+				NotAfter:     now.AddDate(0, 1, i%60),
+				Subject:      fmt.Sprintf("CN=service-%d.eu-west-1.example.com", i),
+				Issuer:       "CN=R11,O=Let's Encrypt,C=US",
+				SerialNumber: fmt.Sprintf("%x", sha1.Sum([]byte{byte(i), byte(i >> 8)})),
+				Fingerprint:  fmt.Sprintf("%x", sha256.Sum256([]byte{byte(i), byte(i >> 8)})),
+				State:        store.CertStateValid,
+			},
+		})
+	}
+	st.SaveResults(ctx, results)
+	server := &Server{store: st}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/endpoints", server.handleAPIv1Endpoints)
+	mux.HandleFunc("GET /healthz", server.handleHealthz)
+	handler := gzipHandler(mux)
+
+	get := func(target, acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	plain := get("/api/v1/endpoints", "", "")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("without Accept-Encoding: Content-Encoding %q, Vary %q; want none and Accept-Encoding", plain.Header().Get("Content-Encoding"), plain.Header().Get("Vary"))
+	}
+
+	compressed := get("/api/v1/endpoints", "br;q=1.0, gzip;q=0.8", "")
+	if compressed.Code != http.StatusOK || compressed.Header().Get("Content-Encoding") != "gzip" || compressed.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("with gzip accepted = %d, Content-Encoding %q, Vary %q", compressed.Code, compressed.Header().Get("Content-Encoding"), compressed.Header().Get("Vary"))
+	}
+	if got := compressed.Header().Get("Content-Length"); got != "" {
+		t.Errorf("compressed response has Content-Length %s", got)
+	}
+	if got := compressed.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("compressed Content-Type = %q", got)
+	}
+	compressedSize := compressed.Body.Len()
+	zr, err := gzip.NewReader(compressed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != plain.Body.String() {
+		t.Error("decompressed body differs from the uncompressed response")
+	}
+	ratio := float64(compressedSize) / float64(len(body))
+	t.Logf("%d endpoints: %d bytes, %d gzipped (%.1f%%)", len(results), len(body), compressedSize, 100*ratio)
+	if ratio > 0.2 {
+		t.Errorf("gzip saved %.0f%% of %d bytes, want more than 80%%", 100*(1-ratio), len(body))
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptEncoding string
+		ifNoneMatch    string
+		wantCode       int
+	}{
+		{"small response", "/healthz", "gzip", "", http.StatusOK},
+		{"gzip refused", "/api/v1/endpoints", "gzip;q=0, identity", "", http.StatusOK},
+		{"not modified", "/api/v1/endpoints", "gzip", compressed.Header().Get("ETag"), http.StatusNotModified},
+		{"error", "/api/v1/endpoints?status=teapot", "gzip", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.target, tt.acceptEncoding, tt.ifNoneMatch)
+			if rec.Code != tt.wantCode || rec.Header().Get("Content-Encoding") != "" {
+				t.Errorf("status %d with Content-Encoding %q, want %d uncompressed", rec.Code, rec.Header().Get("Content-Encoding"), tt.wantCode)
+			}
+		})
+	}
+
+	// WebSocket upgrades pass through the middleware
+	mr := miniredis.RunT(t)
+	wsServer := &Server{store: store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))}
+	wsMux := http.NewServeMux()
+	wsMux.HandleFunc("GET /ws", wsServer.handleWS)
+	ts := httptest.NewServer(gzipHandler(wsMux))
+	defer ts.Close()
+	dialWS(t, ts, http.Header{"Accept-Encoding": {"gzip"}})
+}
+
+// BenchmarkGzipEndpoints measures compressing the endpoint list
+func BenchmarkGzipEndpoints(b *testing.B) {
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	var results []store.Result
+	for i := 0; i < 300; i++ {
+		results = append(results, store.Result{
+			Endpoint: fmt.Sprintf("https://service-%d.example.com", i), CheckedAt: now, HasStatus: true, StatusCode: 200,
+			Cert: &store.CertInfo{NotAfter: now.AddDate(0, 2, 0), Subject: fmt.Sprintf("CN=service-%d.example.com", i), Issuer: "CN=R11,O=Let's Encrypt,C=US", State: store.CertStateValid},
+		})
+	}
+	st.SaveResults(context.Background(), results)
+	handler := gzipHandler(http.HandlerFunc((&Server{store: st}).handleAPIv1Endpoints))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/endpoints", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// TestRateLimiter tests the token buckets: a burst, then one request per
+// refilled token, per client, and dropping idle buckets
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		client    string
+		after     time.Duration
+		wantAllow bool
+		wantWait  time.Duration
+	}{
+		{"10.0.0.1", 0, true, 0},
+		{"10.0.0.1", 0, true, 0},
+		{"10.0.0.1", 0, true, 0},
+		{"10.0.0.1", 0, false, 500 * time.Millisecond},
+		{"10.0.0.2", 0, true, 0},
+		{"10.0.0.1", 250 * time.Millisecond, false, 250 * time.Millisecond},
+		{"10.0.0.1", 500 * time.Millisecond, true, 0},
+		{"10.0.0.1", 500 * time.Millisecond, false, 500 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		allowed, wait := limiter.allow(tt.client, start.Add(tt.after))
+		if allowed != tt.wantAllow || wait != tt.wantWait {
+			t.Errorf("request %d from %s at +%s = %v, wait %s; want %v, wait %s", i, tt.client, tt.after, allowed, wait, tt.wantAllow, tt.wantWait)
+		}
+	}
+
+	// Once full again, buckets are forgotten at the next sweep
+	limiter.allow("10.0.0.3", start.Add(time.Minute))
+	limiter.allow("10.0.0.4", start.Add(rateLimitSweepInterval+time.Second))
+	if got := slices.Sorted(maps.Keys(limiter.buckets)); !slices.Equal(got, []string{"10.0.0.3", "10.0.0.4"}) {
+		t.Errorf("buckets after the sweep = %v, want the recently active clients", got)
+	}
+}
+
+// TestRateLimitHandler tests the 429 responses, the exempt paths and which
+// address identifies the client
+func TestRateLimitHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		trustProxy bool
+		path       string
+		remoteAddr string
+		forwarded  []string
+		wantCodes  []int
+	}{
+		{"limited", false, "/api/summary", "10.0.0.1:1234", nil, []int{200, 200, 429}},
+		{"same host, other port", false, "/", "10.0.0.1:5678", nil, []int{429}},
+		{"other host", false, "/", "10.0.0.2:1234", nil, []int{200, 200, 429}},
+		{"health exempt", false, "/healthz", "10.0.0.1:1234", nil, []int{200, 200, 200}},
+		{"metrics exempt", false, "/metrics", "10.0.0.1:1234", nil, []int{200, 200, 200}},
+		{"forwarded ignored", false, "/", "10.0.0.1:1234", []string{"192.0.2.7"}, []int{429}},
+		{"forwarded trusted", true, "/", "10.0.0.1:1234", []string{"192.0.2.7"}, []int{200, 200, 429}},
+		{"last forwarded entry", true, "/", "10.0.0.1:1234", []string{"203.0.113.9, 192.0.2.8", "192.0.2.7"}, []int{429}},
+	}
+	limiter := newRateLimiter(0.001, 2)
+	for _, tt := range tests {
+		handler := rateLimitHandler(ok, limiter, tt.trustProxy)
+		var codes []int
+		for range tt.wantCodes {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+			if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1000" {
+				t.Errorf("%s: Retry-After = %q, want 1000", tt.name, rec.Header().Get("Retry-After"))
+			}
+		}
+		if !slices.Equal(codes, tt.wantCodes) {
+			t.Errorf("%s: status codes = %v, want %v", tt.name, codes, tt.wantCodes)
+		}
+	}
+}
+
+// TestBasicAuth tests the login required with DASHBOARD_USERNAME and
+// DASHBOARD_PASSWORD or a htpasswd file
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("battery staple"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswd := filepath.Join(t.TempDir(), "htpasswd")
+	os.WriteFile(htpasswd, []byte("# ops team\nalice:"+string(hash)+"\n\n"), 0o600)
+
+	if auth, err := newBasicAuth(Config{}); auth != nil || err != nil {
+		t.Errorf("newBasicAuth() without users = %v, %v; want nil, nil", auth, err)
+	}
+	for name, config := range map[string]Config{
+		"username without password": {DashboardUsername: "admin"},
+		"missing htpasswd file":     {DashboardHtpasswd: filepath.Join(t.TempDir(), "missing")},
+	} {
+		if _, err := newBasicAuth(config); err == nil {
+			t.Errorf("newBasicAuth() with %s succeeded", name)
+		}
+	}
+	for name, content := range map[string]string{
+		"plain password": "bob:secret\n",
+		"MD5 hash":       "bob:$apr1$x7S1TYxS$Nm3Qzs2U3Gb0H5QsY8rR4/\n",
+		"no colon":       "bob\n",
+		"no users":       "# nobody yet\n",
+	} {
+		path := filepath.Join(t.TempDir(), "htpasswd")
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := loadHtpasswd(path); err == nil {
+			t.Errorf("loadHtpasswd() of a file with %s succeeded", name)
+		}
+	}
+
+	auth, err := newBasicAuth(Config{DashboardUsername: "admin", DashboardPassword: "correct horse", DashboardHtpasswd: htpasswd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := basicAuthHandler(ok, auth, false)
+
+	tests := []struct {
+		name     string
+		path     string
+		username string
+		password string
+		wantCode int
+	}{
+		{"no credentials", "/", "", "", http.StatusUnauthorized},
+		{"env user", "/", "admin", "correct horse", http.StatusOK},
+		{"env user, wrong password", "/api/endpoints", "admin", "correct", http.StatusUnauthorized},
+		{"htpasswd user", "/api/endpoints", "alice", "battery staple", http.StatusOK},
+		{"htpasswd user, env password", "/", "alice", "correct horse", http.StatusUnauthorized},
+		{"unknown user", "/", "mallory", "battery staple", http.StatusUnauthorized},
+		{"metrics", "/metrics", "", "", http.StatusUnauthorized},
+		{"readiness", "/readyz", "", "", http.StatusUnauthorized},
+		{"liveness", "/healthz", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			var logged strings.Builder
+			log.SetOutput(&logged)
+			defer log.SetOutput(os.Stderr)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if rec.Code != http.StatusUnauthorized {
+				return
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != `Basic realm="Certs-n-Status", charset="UTF-8"` {
+				t.Errorf("WWW-Authenticate = %q", got)
+			}
+			if tt.username != "" && !strings.Contains(logged.String(), fmt.Sprintf("Failed dashboard login for user %q from 192.0.2.1", tt.username)) {
+				t.Errorf("failed login not logged: %q", logged.String())
+			}
+			if tt.password != "" && strings.Contains(logged.String(), tt.password) {
+				t.Errorf("log contains the password: %q", logged.String())
+			}
+		})
+	}
+}
+
+// TestAPITokens tests the bearer tokens on /api/ for each combination of
+// configured tokens and Authorization header, with and without a UI login
+func TestAPITokens(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens")
+	os.WriteFile(tokensFile, []byte("# ci\ntok-file\n\n"), 0o600)
+	tokens, err := newAPITokens(Config{APITokens: []string{"tok-env"}, APITokensFile: tokensFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("newAPITokens() = %d tokens, want 2", len(tokens))
+	}
+	if none, err := newAPITokens(Config{}); none != nil || err != nil {
+		t.Errorf("newAPITokens() without tokens = %v, %v; want nil, nil", none, err)
+	}
+	auth, err := newBasicAuth(Config{DashboardUsername: "admin", DashboardPassword: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handlers := map[string]http.Handler{
+		"no tokens":        app,
+		"tokens":           apiTokenHandler(app, app, tokens, false),
+		"tokens and login": apiTokenHandler(app, basicAuthHandler(app, auth, false), tokens, false),
+		"no tokens, login": basicAuthHandler(app, auth, false),
+	}
+	tests := []struct {
+		handler       string
+		path          string
+		authorization string
+		wantCode      int
+	}{
+		{"no tokens", "/api/summary", "", http.StatusOK},
+		{"no tokens", "/api/summary", "Bearer tok-env", http.StatusOK},
+		{"no tokens", "/api/summary", "Bearer wrong", http.StatusOK},
+		{"tokens", "/api/summary", "", http.StatusUnauthorized},
+		{"tokens", "/api/summary", "Bearer tok-env", http.StatusOK},
+		{"tokens", "/api/v1/endpoints", "Bearer tok-file", http.StatusOK},
+		{"tokens", "/api/summary", "Bearer wrong", http.StatusUnauthorized},
+		{"tokens", "/api/summary", "Bearer ", http.StatusUnauthorized},
+		{"tokens", "/api/summary", "tok-env", http.StatusUnauthorized},
+		{"tokens", "/", "", http.StatusOK},
+		{"tokens", "/metrics", "", http.StatusOK},
+		{"tokens and login", "/api/summary", "Bearer tok-env", http.StatusOK},
+		{"tokens and login", "/api/summary", "", http.StatusUnauthorized},
+		{"tokens and login", "/", "Bearer tok-env", http.StatusUnauthorized},
+		{"no tokens, login", "/api/summary", "Bearer tok-env", http.StatusUnauthorized},
+		{"tokens", "/api/public", "", http.StatusOK},
+		{"tokens and login", "/api/public", "", http.StatusOK},
+		{"tokens and login", "/status", "", http.StatusOK},
+		{"no tokens, login", "/status", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		var logged strings.Builder
+		log.SetOutput(&logged)
+		rec := httptest.NewRecorder()
+		handlers[tt.handler].ServeHTTP(rec, req)
+		log.SetOutput(os.Stderr)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: GET %s with %q = %d, want %d", tt.handler, tt.path, tt.authorization, rec.Code, tt.wantCode)
+		}
+		if strings.Contains(logged.String(), "tok-") || strings.Contains(logged.String(), "wrong") {
+			t.Errorf("%s: token logged: %q", tt.handler, logged.String())
+		}
+		if rec.Code == http.StatusUnauthorized && strings.HasPrefix(tt.path, "/api/") && tt.handler != "no tokens, login" {
+			want := `Bearer realm="Certs-n-Status API"`
+			if tt.authorization != "" && strings.HasPrefix(tt.authorization, "Bearer ") {
+				want += `, error="invalid_token"`
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != want {
+				t.Errorf("%s: WWW-Authenticate = %q, want %q", tt.handler, got, want)
+			}
+		}
+	}
+}
+
+// TestAccessLog tests the access log lines, request IDs and the quieting
+// of probe requests
+func TestAccessLog(t *testing.T) {
+	body := strings.Repeat("x", 1234)
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/healthz":
+			w.Write([]byte("ok\n"))
+		default:
+			w.Write([]byte(body))
+		}
+	})
+	generatedID := regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+	tests := []struct {
+		name      string
+		format    string
+		debug     bool
+		path      string
+		requestID string
+		wantID    string // "" for a generated one
+		wantLine  string // regexp of the log line, "" for none
+	}{
+		{"text", logging.FormatText, false, "/api/summary", "", "",
+			`^\[INFO\] GET /api/summary 200 1234B \d+\.\dms from 192\.0\.2\.1 request_id=[0-9a-f]{16}\n$`},
+		{"error status", logging.FormatText, false, "/missing", "", "",
+			`^\[INFO\] GET /missing 404 19B `},
+		{"client ID reused", logging.FormatText, false, "/", "req-42.a:b_c", "req-42.a:b_c",
+			`request_id=req-42\.a:b_c\n$`},
+		{"invalid client ID replaced", logging.FormatText, false, "/", "bad id\n", "",
+			`request_id=[0-9a-f]{16}\n$`},
+		{"oversized client ID replaced", logging.FormatText, false, "/", strings.Repeat("a", 129), "",
+			`request_id=[0-9a-f]{16}\n$`},
+		{"probe quiet", logging.FormatText, false, "/healthz", "", "", ""},
+		{"probe debug", logging.FormatText, true, "/healthz", "", "",
+			`^\[DEBUG\] GET /healthz 200 3B `},
+		{"json", logging.FormatJSON, false, "/api/summary", "abc", "abc",
+			`^\{"time":"[^"]+","level":"info","msg":"request","method":"GET","path":"/api/summary","status":200,"bytes":1234,"duration_ms":[0-9.]+,"remote":"192\.0\.2\.1","request_id":"abc"\}\n$`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.requestID != "" {
+			req.Header.Set("X-Request-ID", tt.requestID)
+		}
+		var logged strings.Builder
+		log.SetOutput(&logged)
+		flags := log.Flags()
+		log.SetFlags(0)
+		rec := httptest.NewRecorder()
+		accessLogHandler(app, tt.format, tt.debug, false, "").ServeHTTP(rec, req)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+
+		id := rec.Header().Get("X-Request-ID")
+		if tt.wantID != "" && id != tt.wantID || tt.wantID == "" && !generatedID.MatchString(id) {
+			t.Errorf("%s: X-Request-ID = %q, want %q", tt.name, id, tt.wantID)
+		}
+		if tt.wantLine == "" {
+			if logged.Len() > 0 {
+				t.Errorf("%s: logged %q, want nothing", tt.name, logged.String())
+			}
+			continue
+		}
+		if !regexp.MustCompile(tt.wantLine).MatchString(logged.String()) {
+			t.Errorf("%s: logged %q, want a match of %s", tt.name, logged.String(), tt.wantLine)
+		}
+		if !strings.Contains(logged.String(), id) {
+			t.Errorf("%s: logged %q without the request ID %q", tt.name, logged.String(), id)
+		}
+	}
+}
+
+// TestAccessLogHijack tests that a WebSocket upgrade through the access log
+// can take over the connection and is logged as 101
+func TestAccessLogHijack(t *testing.T) {
+	var logged strings.Builder
+	var mu sync.Mutex
+	done := make(chan struct{})
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		conn.Close()
+	})
+	logHandler := accessLogHandler(app, logging.FormatText, false, false, "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		logHandler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	log.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return logged.Write(p)
+	}))
+	defer log.SetOutput(os.Stderr)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want 101", resp.StatusCode)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(logged.String(), "[INFO] GET /ws 101 0B ") {
+		t.Errorf("logged %q, want a 101 line for /ws", logged.String())
+	}
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// TestLoadConfig tests that invalid values fail loading, all reported at
+// once, rather than falling back to their defaults
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig(&store.Env{})
+	if err != nil {
+		t.Fatalf("LoadConfig(&store.Env{}) without variables = %v", err)
+	}
+	if config.ServerPort != "8080" || config.Storage != "redis" || config.CacheTTL != defaultCacheTTL || config.Log.Format != logging.FormatText {
+		t.Errorf("LoadConfig(&store.Env{}) defaults = %+v", config)
+	}
+
+	t.Setenv("DATABASE_URL", "postgres://localhost/certs")
+	if config, err := LoadConfig(&store.Env{}); err != nil || config.Storage != "postgres" {
+		t.Errorf("LoadConfig(&store.Env{}) with DATABASE_URL = %q, %v; want postgres storage", config.Storage, err)
+	}
+
+	t.Setenv("REDIS_DB", "one")
+	t.Setenv("CACHE_TTL", "-5s")
+	t.Setenv("RATE_LIMIT_RPS", "fast")
+	t.Setenv("ALLOW_WRITE", "maybe")
+	t.Setenv("LOG_FORMAT", "yaml")
+	t.Setenv("METRICS_LABEL", "host")
+	t.Setenv("STORAGE", "mysql")
+	_, err = LoadConfig(&store.Env{})
+	if err == nil {
+		t.Fatal("LoadConfig(&store.Env{}) with invalid values succeeded")
+	}
+	for _, want := range []string{"REDIS_DB", "CACHE_TTL", "RATE_LIMIT_RPS", "ALLOW_WRITE", "LOG_FORMAT", "METRICS_LABEL", "STORAGE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfig(&store.Env{}) error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+// TestConfigValidate tests each rule of Config.validate
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Storage: "redis", ServerPort: "8080"}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"unknown storage", func(c *Config) { c.Storage = "etcd" }, "unknown STORAGE"},
+		{"memory", func(c *Config) { c.Storage = "memory" }, ""},
+		{"postgres without URL", func(c *Config) { c.Storage = "postgres" }, "DATABASE_URL"},
+		{"postgres", func(c *Config) { c.Storage, c.DatabaseURL = "postgres", "postgres://localhost/certs" }, ""},
+		{"server port name", func(c *Config) { c.ServerPort = "http" }, "SERVER_PORT"},
+		{"server port out of range", func(c *Config) { c.ServerPort = "70000" }, "SERVER_PORT"},
+		{"redirect port", func(c *Config) { c.RedirectHTTPPort = "80" }, ""},
+		{"invalid redirect port", func(c *Config) { c.RedirectHTTPPort = ":80" }, "REDIRECT_HTTP_PORT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.change(&config)
+			problems := config.validate()
+			if tt.wantErr == "" {
+				if len(problems) > 0 {
+					t.Errorf("validate() = %v, want no problems", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("validate() = %v, want one problem about %s", problems, tt.wantErr)
+			}
+		})
+	}
+
+	// Every problem is returned, not only the first
+	invalid := Config{Storage: "sqlite", ServerPort: "0", RedirectHTTPPort: "-1"}
+	if problems := invalid.validate(); len(problems) != 3 {
+		t.Errorf("validate() of three invalid settings = %v", problems)
+	}
+}
+
+// TestConfigFile tests reading the settings of --config, overridden by the
+// environment, from a file shared with the checker
+func TestConfigFile(t *testing.T) {
+	for _, key := range ConfigKeys {
+		t.Setenv(key.Env, "")
+	}
+	t.Setenv("DASHBOARD_PASSWORD", "from-env")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+redis:
+  addr: redis:6379
+  key_prefix: "prod:"
+checker:
+  status_check_interval: 30s
+dashboard:
+  server_port: 8081
+  cache_ttl: 10s
+  log:
+    format: json
+  auth:
+    username: admin
+    password: from-file
+  websocket:
+    allowed_origins: [https://a.example.com, https://b.example.com]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ApplyConfigFile(path, ConfigKeys, "checker"); err != nil {
+		t.Fatal(err)
+	}
+	var env store.Env
+	config, err := LoadConfig(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RedisAddr != "redis:6379" || config.KeyPrefix != "prod:" || config.ServerPort != "8081" || config.CacheTTL != 10*time.Second ||
+		config.Log.Format != logging.FormatJSON || config.DashboardUsername != "admin" ||
+		!slices.Equal(config.WSAllowedOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("LoadConfig() from the file = %+v", config)
+	}
+	if config.DashboardPassword != "from-env" {
+		t.Errorf("DashboardPassword = %q, want the environment's", config.DashboardPassword)
+	}
+
+	var out bytes.Buffer
+	if err := env.WriteConfig(&out, ConfigKeys); err != nil {
+		t.Fatal(err)
+	}
+	printed := out.String()
+	for _, want := range []string{"  server_port: \"8081\"\n", "    password: <redacted>\n", "  key_prefix: 'prod:'\n", "    format: json\n"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("printed configuration lacks %q:\n%s", want, printed)
+		}
+	}
+	if strings.Contains(printed, "from-env") || strings.Contains(printed, "checker") {
+		t.Errorf("printed configuration holds a secret or the checker's settings:\n%s", printed)
+	}
+}
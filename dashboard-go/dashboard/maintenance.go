@@ -0,0 +1,113 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"certs-n-status/store"
+)
+
+// Bounds of POST /api/maintenance
+const (
+	maxMaintenanceRequestSize = 4 << 10
+	maxMaintenanceReason      = 500
+)
+
+// handleAPIMaintenanceWindows serves GET /api/maintenance, every stored
+// maintenance window
+func (s *Server) handleAPIMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	windows, err := s.store.MaintenanceWindows(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get maintenance windows", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read maintenance windows: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(windows)
+}
+
+// handleAPIAddMaintenanceWindow serves POST /api/maintenance, storing the
+// window of a {"endpoint" or "tag", "days", "start", "duration",
+// "timezone", "reason"} body and answering 201 with it and its new id
+func (s *Server) handleAPIAddMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var window store.MaintenanceWindow
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMaintenanceRequestSize)).Decode(&window); err != nil {
+		http.Error(w, `Expected a body of {"tag": "erp", "days": "sun", "start": "02:00", "duration": "2h", "timezone": "Europe/Berlin"}`, http.StatusBadRequest)
+		return
+	}
+	if window.Endpoint != "" {
+		endpoint, err := parseEndpointURL(window.Endpoint)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		window.Endpoint = endpoint
+	}
+	window.Tag = strings.ToLower(strings.TrimSpace(window.Tag))
+	window.Reason = strings.TrimSpace(window.Reason)
+	if len(window.Reason) > maxMaintenanceReason {
+		http.Error(w, "reason too long", http.StatusBadRequest)
+		return
+	}
+	if err := window.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	window, err := s.store.AddMaintenanceWindow(ctx, window)
+	if err != nil {
+		http.Error(w, "Failed to add maintenance window", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to add maintenance window: %v", err)
+		return
+	}
+	target := window.Endpoint
+	if target == "" {
+		target = "tag " + window.Tag
+	}
+	log.Printf("[INFO] Maintenance window %s for %s (%s %s for %s, %s) added from %s",
+		window.ID, target, window.Days, window.Start, window.Duration, window.Timezone, clientIP(r, s.config.TrustProxy))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(window)
+}
+
+// handleAPIRemoveMaintenanceWindow serves DELETE /api/maintenance?id=...
+func (s *Server) handleAPIRemoveMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	removed, err := s.store.DeleteMaintenanceWindow(ctx, id)
+	if err != nil {
+		http.Error(w, "Failed to remove maintenance window", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to remove maintenance window %s: %v", id, err)
+		return
+	}
+	if !removed {
+		s.notFound(w, r)
+		return
+	}
+	log.Printf("[INFO] Maintenance window %s removed from %s", id, clientIP(r, s.config.TrustProxy))
+	w.WriteHeader(http.StatusNoContent)
+}
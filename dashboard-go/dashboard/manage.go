@@ -0,0 +1,211 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"certs-n-status/store"
+)
+
+// maxEndpointRequestSize bounds the body of POST /api/endpoints
+const maxEndpointRequestSize = 4 << 10
+
+// endpointRequest is the body of POST /api/endpoints
+type endpointRequest struct {
+	URL string `json:"url"`
+}
+
+// endpointResponse answers a change to the endpoint list
+type endpointResponse struct {
+	Endpoint string `json:"endpoint"`
+	Added    bool   `json:"added,omitempty"`
+}
+
+// parseEndpointURL normalizes raw like the checker's endpoints file
+// (surrounding spaces trimmed, https:// added when there is no scheme) and
+// checks that the result is an HTTP or HTTPS URL with a host
+func parseEndpointURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("missing url")
+	}
+	if scheme, _, ok := strings.Cut(raw, "://"); ok && scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("invalid url %q (only http and https are checked)", raw)
+	}
+	endpoint := store.NormalizeEndpoint(raw)
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || strings.ContainsAny(endpoint, " \t\r\n") {
+		return "", fmt.Errorf("invalid url %q (use e.g. https://example.com)", raw)
+	}
+	return endpoint, nil
+}
+
+// writeAllowed reports whether writes are enabled, or answers the request
+// with why they are not
+func (s *Server) writeAllowed(w http.ResponseWriter) bool {
+	if !s.config.AllowWrite {
+		http.Error(w, "Endpoint management is disabled (set ALLOW_WRITE=true)", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writableStore returns the Redis store when endpoint management is
+// enabled, or answers the request with why it is not
+func (s *Server) writableStore(w http.ResponseWriter) (*store.RedisStore, bool) {
+	if !s.writeAllowed(w) {
+		return nil, false
+	}
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Endpoint management requires Redis storage", http.StatusNotImplemented)
+		return nil, false
+	}
+	return rs, true
+}
+
+// handleAPIAddEndpoint serves POST /api/endpoints, registering the url of a
+// {"url": ...} body for checking: 201 when it is new, 200 when it was
+// already registered
+func (s *Server) handleAPIAddEndpoint(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.writableStore(w)
+	if !ok {
+		return
+	}
+	// A JSON content type cannot be sent cross-site without a CORS
+	// preflight, so other sites cannot post with a visitor's login
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Expected Content-Type: application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var request endpointRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEndpointRequestSize)).Decode(&request); err != nil {
+		http.Error(w, `Expected a body of {"url": "https://example.com"}`, http.StatusBadRequest)
+		return
+	}
+	endpoint, err := parseEndpointURL(request.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	added, err := rs.AddEndpoint(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to add endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to add endpoint %s: %v", endpoint, err)
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		s.invalidateEndpointData()
+		status = http.StatusCreated
+		log.Printf("[INFO] Endpoint %s added from %s", endpoint, clientIP(r, s.config.TrustProxy))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(endpointResponse{Endpoint: endpoint, Added: added})
+}
+
+// handleAPIRemoveEndpoint serves DELETE /api/endpoints?url=..., removing the
+// endpoint from the registry along with its stored results
+func (s *Server) handleAPIRemoveEndpoint(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.writableStore(w)
+	if !ok {
+		return
+	}
+	endpoint, err := parseEndpointURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	removed, err := rs.RemoveEndpoint(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to remove endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to remove endpoint %s: %v", endpoint, err)
+		return
+	}
+	if !removed {
+		s.notFound(w, r)
+		return
+	}
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Endpoint %s removed from %s", endpoint, clientIP(r, s.config.TrustProxy))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recheckResponse answers an accepted recheck with the time of the status
+// check it supersedes, as a Unix time like the endpoint detail's
+// timestamps, so clients can wait for a newer one
+type recheckResponse struct {
+	Endpoint      string `json:"endpoint"`
+	StatusUpdated int64  `json:"status_updated,omitempty"`
+}
+
+// handleAPIRecheck serves POST /api/endpoints/recheck?url=..., asking the
+// checker to check a monitored endpoint now instead of at its next cycle:
+// 202 once the request is published, 429 within store.RecheckInterval of
+// the endpoint's previous recheck and 503 when no checker listens
+func (s *Server) handleAPIRecheck(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Rechecks require Redis storage", http.StatusNotImplemented)
+		return
+	}
+	endpoint, err := parseEndpointURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := rs.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to get endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read endpoint %s: %v", endpoint, err)
+		return
+	}
+	if !stored.HasStatus && stored.SSLUpdated.IsZero() {
+		s.notFound(w, r)
+		return
+	}
+
+	accepted, wait, err := rs.RequestRecheck(ctx, endpoint)
+	switch {
+	case errors.Is(err, store.ErrNoRecheckSubscriber):
+		http.Error(w, "No checker is listening for rechecks", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, "Failed to request recheck", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to request recheck of %s: %v", endpoint, err)
+		return
+	case !accepted:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, fmt.Sprintf("%s was rechecked less than %s ago", endpoint, store.RecheckInterval), http.StatusTooManyRequests)
+		return
+	}
+
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Recheck of %s requested from %s", endpoint, clientIP(r, s.config.TrustProxy))
+	response := recheckResponse{Endpoint: endpoint}
+	if !stored.StatusUpdated.IsZero() {
+		response.StatusUpdated = stored.StatusUpdated.Unix()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,158 @@
+package dashboard
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMetricsStaleAfter is how long after its last check an endpoint is
+// left out of /metrics, fifteen status check intervals at the default
+const defaultMetricsStaleAfter = 15 * time.Minute
+
+// endpointMetrics are the gauges of one series: an endpoint, or every
+// endpoint of a host when METRICS_LABEL=hostname
+type endpointMetrics struct {
+	label         string
+	statusCode    *int
+	up            *int
+	daysLeft      *int
+	lastCheck     time.Time
+	acked         bool // every endpoint of the series is acknowledged
+	inMaintenance bool // every endpoint of the series is in a maintenance window
+}
+
+// handleMetrics serves GET /metrics in the Prometheus text format, built on
+// every scrape from the same bulk read as the dashboard. Endpoints not
+// checked within METRICS_STALE_AFTER are left out, so alerts do not fire on
+// values from endpoints no longer checked.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+
+	endpointData, _, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get endpoints", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to get endpoints for metrics: %v", err)
+		return
+	}
+
+	series := collectMetrics(endpointData, s.config.MetricsByHost, s.config.MetricsStaleAfter, time.Now().UTC())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, series, s.config.MetricsByHost)
+}
+
+// collectMetrics turns endpoints into series, leaving out those not checked
+// within staleAfter (0 keeps all). By host, each series reports the worst of
+// the host's endpoints: down when any is down, with that endpoint's status
+// code, the fewest days left and the oldest check, and acknowledged or in
+// maintenance only when all of them are.
+func collectMetrics(endpointData []EndpointData, byHost bool, staleAfter time.Duration, now time.Time) []*endpointMetrics {
+	byLabel := make(map[string]*endpointMetrics)
+	for _, ep := range endpointData {
+		lastCheck := lastUpdate(ep)
+		if lastCheck == nil || (staleAfter > 0 && now.Sub(*lastCheck) > staleAfter) {
+			continue
+		}
+
+		label := ep.Endpoint
+		if byHost {
+			if u, err := url.Parse(ep.Endpoint); err == nil && u.Hostname() != "" {
+				label = u.Hostname()
+			}
+		}
+		m, seen := byLabel[label]
+		if !seen {
+			m = &endpointMetrics{label: label, lastCheck: *lastCheck, acked: true, inMaintenance: true}
+			byLabel[label] = m
+		}
+		m.acked = m.acked && ep.Ack != nil
+		m.inMaintenance = m.inMaintenance && ep.InMaintenance
+
+		if code := statusCode(ep); code != nil {
+			up := 0
+			if statusCategory(ep) == "ok" {
+				up = 1
+			}
+			if m.up == nil || up < *m.up {
+				m.statusCode, m.up = code, &up
+			}
+		}
+		if ep.DaysLeft != nil && (m.daysLeft == nil || *ep.DaysLeft < *m.daysLeft) {
+			m.daysLeft = ep.DaysLeft
+		}
+		if lastCheck.Before(m.lastCheck) {
+			m.lastCheck = *lastCheck
+		}
+	}
+
+	series := make([]*endpointMetrics, 0, len(byLabel))
+	for _, m := range byLabel {
+		series = append(series, m)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].label < series[j].label })
+	return series
+}
+
+// writeMetrics writes series in the Prometheus text exposition format
+func writeMetrics(w io.Writer, series []*endpointMetrics, byHost bool) {
+	labelName := "endpoint"
+	if byHost {
+		labelName = "hostname"
+	}
+	gauges := []struct {
+		name  string
+		help  string
+		value func(m *endpointMetrics) *float64
+	}{
+		{"endpoint_http_status_code", "HTTP status code of the last check; 0 for a connection failure, -1 for a DNS failure.",
+			func(m *endpointMetrics) *float64 { return intValue(m.statusCode) }},
+		{"endpoint_up", "Whether the last check got a 2xx or 3xx response.",
+			func(m *endpointMetrics) *float64 { return intValue(m.up) }},
+		{"endpoint_ssl_days_left", "Days until the certificate expires, a part day counting as one, negative once expired.",
+			func(m *endpointMetrics) *float64 { return intValue(m.daysLeft) }},
+		{"endpoint_acknowledged", "Whether the endpoint's alerts are acknowledged on the dashboard.",
+			func(m *endpointMetrics) *float64 { return boolValue(m.acked) }},
+		{"endpoint_in_maintenance", "Whether the last check fell in a maintenance window.",
+			func(m *endpointMetrics) *float64 { return boolValue(m.inMaintenance) }},
+		{"endpoint_last_check_timestamp", "Unix time of the last status or SSL check.",
+			func(m *endpointMetrics) *float64 {
+				v := float64(m.lastCheck.Unix())
+				return &v
+			}},
+	}
+
+	for _, gauge := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", gauge.name, gauge.help, gauge.name)
+		for _, m := range series {
+			if v := gauge.value(m); v != nil {
+				fmt.Fprintf(w, "%s{%s=\"%s\"} %s\n", gauge.name, labelName, labelEscaper.Replace(m.label), strconv.FormatFloat(*v, 'f', -1, 64))
+			}
+		}
+	}
+}
+
+// labelEscaper escapes label values as the Prometheus text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func boolValue(b bool) *float64 {
+	v := 0.0
+	if b {
+		v = 1
+	}
+	return &v
+}
+
+func intValue(n *int) *float64 {
+	if n == nil {
+		return nil
+	}
+	v := float64(*n)
+	return &v
+}
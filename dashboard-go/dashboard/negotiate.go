@@ -0,0 +1,63 @@
+package dashboard
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the media type of offers the Accept header of r
+// prefers. Each offer gets the q-value of the most specific range matching
+// it (text/html over text/* over */*), and the highest q-value wins, ties
+// going to the earlier offer. Without an Accept header, or when it refuses
+// every offer, the first offer is returned, so clients always get a page.
+func negotiate(r *http.Request, offers ...string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, accepted := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		offerType, _, _ := strings.Cut(offer, "/")
+		q, specificity := 0.0, -1
+		for _, accepted := range ranges {
+			rangeSpecificity := -1
+			switch {
+			case accepted.mediaType == offer:
+				rangeSpecificity = 2
+			case accepted.mediaType == offerType+"/*":
+				rangeSpecificity = 1
+			case accepted.mediaType == "*/*":
+				rangeSpecificity = 0
+			}
+			if rangeSpecificity > specificity {
+				q, specificity = accepted.q, rangeSpecificity
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,40 @@
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 description of the JSON API. Its schemas
+// are checked against the API structs and actual responses by the tests,
+// so a field added to one and not the other fails them.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIDocument returns openAPISpec with the servers the API is reached
+// at: basePath when the dashboard is served under one
+func openAPIDocument(basePath string) ([]byte, error) {
+	if basePath == "" {
+		return openAPISpec, nil
+	}
+	var document map[string]any
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		return nil, err
+	}
+	document["servers"] = []map[string]string{{"url": basePath}}
+	return json.Marshal(document)
+}
+
+// handleAPIOpenAPI serves GET /api/openapi.json, the OpenAPI document of
+// the JSON API
+func (s *Server) handleAPIOpenAPI(w http.ResponseWriter, r *http.Request) {
+	document, err := openAPIDocument(s.config.BasePath)
+	if err != nil {
+		http.Error(w, "Failed to build the OpenAPI document", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(document)
+}
@@ -0,0 +1,126 @@
+package dashboard
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// Sort keys accepted by the sort parameter; ssl, soonest expiring first, is
+// the default
+var sortKeys = []string{"ssl", "status", "endpoint", "updated"}
+
+// sortEndpoints orders endpointData by the sort and order query parameters:
+//
+//	sort=ssl|status|endpoint|updated   days left on the certificate, HTTP
+//	                                   status code, display name or else
+//	                                   URL without its scheme, or time
+//	                                   since the last check
+//	order=asc|desc                     default asc
+//
+// Endpoints without the sorted value, such as plain HTTP ones when sorting
+// by ssl, stay last in either order. Ties keep URL order.
+func sortEndpoints(query url.Values, endpointData []EndpointData) error {
+	key := strings.ToLower(query.Get("sort"))
+	if key == "" {
+		key = "ssl"
+	}
+	if !slices.Contains(sortKeys, key) {
+		return fmt.Errorf("invalid sort value %q (use %s)", query.Get("sort"), strings.Join(sortKeys, ", "))
+	}
+	desc := false
+	switch order := strings.ToLower(query.Get("order")); order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return fmt.Errorf("invalid order value %q (use asc or desc)", query.Get("order"))
+	}
+
+	slices.SortStableFunc(endpointData, func(a, b EndpointData) int {
+		return compareEndpoints(a, b, key, desc)
+	})
+	return nil
+}
+
+// compareEndpoints orders a and b by key, descending when desc is set, with
+// endpoints lacking the value last and the URL breaking ties
+func compareEndpoints(a, b EndpointData, key string, desc bool) int {
+	var c int
+	switch key {
+	case "ssl":
+		c = compareMissingLast(a.DaysLeft, b.DaysLeft, desc)
+	case "status":
+		c = compareMissingLast(statusCode(a), statusCode(b), desc)
+	case "updated":
+		// The youngest update, i.e. the latest time, comes first in asc order
+		c = compareMissingLast(unixTime(lastUpdate(a)), unixTime(lastUpdate(b)), !desc)
+	case "endpoint":
+		c = strings.Compare(sortLabel(a), sortLabel(b))
+		if c == 0 {
+			c = strings.Compare(a.Endpoint, b.Endpoint)
+		}
+		if desc {
+			c = -c
+		}
+		return c
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.Endpoint, b.Endpoint)
+}
+
+// sortLabel is what sort=endpoint orders by: the display name ignoring
+// case, or the displayed URL without its scheme for endpoints without one
+func sortLabel(ep EndpointData) string {
+	if ep.Name != "" {
+		return strings.ToLower(ep.Name)
+	}
+	return withoutScheme(store.DisplayEndpoint(ep.Endpoint))
+}
+
+// compareMissingLast compares two optional values, putting nil after any
+// value whatever the direction
+func compareMissingLast[T cmp.Ordered](a, b *T, desc bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case desc:
+		return cmp.Compare(*b, *a)
+	}
+	return cmp.Compare(*a, *b)
+}
+
+// statusCode returns the status code, or nil before the first status check
+func statusCode(endpoint EndpointData) *int {
+	if endpoint.StatusText == "" {
+		return nil
+	}
+	return &endpoint.StatusCode
+}
+
+func unixTime(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	unix := t.UnixNano()
+	return &unix
+}
+
+// withoutScheme lets endpoints sort by host whether they use HTTP or HTTPS
+func withoutScheme(endpoint string) string {
+	if _, rest, ok := strings.Cut(endpoint, "://"); ok {
+		return rest
+	}
+	return endpoint
+}
@@ -0,0 +1,191 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// Bounds of POST /api/endpoints/pause
+const (
+	maxPauseReasonLength = 500
+	maxPauseDuration     = 90 * 24 * time.Hour
+)
+
+// APIPause is a pause in API responses. Endpoint is left out where it is
+// nested in an endpoint.
+type APIPause struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	User     string `json:"user,omitempty"`
+	At       string `json:"at"`
+	Until    string `json:"until"`
+}
+
+func newAPIPause(pause store.Pause) APIPause {
+	return APIPause{Endpoint: pause.Endpoint, Reason: pause.Reason, User: pause.User, At: apiTime(pause.At), Until: apiTime(pause.Until)}
+}
+
+// PauseTitle describes the endpoint's pause for the row tooltip, with its
+// expiry in location
+func (e EndpointData) PauseTitle(location *time.Location) string {
+	if e.Pause == nil {
+		return ""
+	}
+	title := "Paused"
+	if e.Pause.User != "" {
+		title += " by " + e.Pause.User
+	}
+	title += " until " + e.Pause.Until.In(location).Format("2006-01-02 15:04 MST")
+	if e.Pause.Reason != "" {
+		title += ": " + e.Pause.Reason
+	}
+	return title
+}
+
+// readPauses returns the pauses in effect by endpoint, or nil without
+// Redis storage or when they cannot be read, in which case no endpoint
+// shows as paused
+func (s *Server) readPauses(ctx context.Context) map[string]store.Pause {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	pauses, err := rs.Pauses(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read paused endpoints: %v", err)
+		return nil
+	}
+	byEndpoint := make(map[string]store.Pause, len(pauses))
+	for _, pause := range pauses {
+		byEndpoint[pause.Endpoint] = pause
+	}
+	return byEndpoint
+}
+
+// pauseStore returns the Redis store, or answers that pauses need it
+func (s *Server) pauseStore(w http.ResponseWriter) (*store.RedisStore, bool) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Pausing endpoints requires Redis storage", http.StatusNotImplemented)
+	}
+	return rs, ok
+}
+
+// handleAPIPause serves POST /api/endpoints/pause?url=...&for=4h, stopping
+// the checks of a monitored endpoint until the duration has passed,
+// replacing any earlier pause. An optional reason is kept with it.
+func (s *Server) handleAPIPause(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	rs, ok := s.pauseStore(w)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	endpoint, err := parseEndpointURL(query.Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	duration, err := parseDuration(strings.TrimSpace(query.Get("for")))
+	if err != nil || duration <= 0 || duration > maxPauseDuration {
+		http.Error(w, fmt.Sprintf("invalid duration %q (use e.g. for=4h or for=3d, at most %dd)", query.Get("for"), int(maxPauseDuration.Hours()/24)), http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(query.Get("reason"))
+	if len(reason) > maxPauseReasonLength {
+		http.Error(w, fmt.Sprintf("The reason is limited to %d characters", maxPauseReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := rs.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		http.Error(w, "Failed to get endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read endpoint %s: %v", endpoint, err)
+		return
+	}
+	if !stored.HasStatus && stored.SSLUpdated.IsZero() {
+		s.notFound(w, r)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	pause := store.Pause{Endpoint: endpoint, Reason: reason, User: s.requestUser(r), At: now, Until: now.Add(duration)}
+	if err := rs.PauseEndpoint(ctx, pause); err != nil {
+		http.Error(w, "Failed to pause endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to pause %s: %v", endpoint, err)
+		return
+	}
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Endpoint %s paused for %s by %q from %s", endpoint, duration, pause.User, clientIP(r, s.config.TrustProxy))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAPIPause(pause))
+}
+
+// handleAPIResume serves DELETE /api/endpoints/pause?url=..., resuming the
+// checks of a paused endpoint before its pause expires
+func (s *Server) handleAPIResume(w http.ResponseWriter, r *http.Request) {
+	if !s.writeAllowed(w) {
+		return
+	}
+	rs, ok := s.pauseStore(w)
+	if !ok {
+		return
+	}
+	endpoint, err := parseEndpointURL(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	user := s.requestUser(r)
+	removed, err := rs.ResumeEndpoint(ctx, endpoint, user, time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		http.Error(w, "Failed to resume endpoint", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to resume %s: %v", endpoint, err)
+		return
+	}
+	if !removed {
+		s.notFound(w, r)
+		return
+	}
+	s.invalidateEndpointData()
+	log.Printf("[INFO] Endpoint %s resumed by %q from %s", endpoint, user, clientIP(r, s.config.TrustProxy))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIPauses serves GET /api/endpoints/pauses, the pauses in effect,
+// soonest expiring first
+func (s *Server) handleAPIPauses(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.pauseStore(w)
+	if !ok {
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	pauses, err := rs.Pauses(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get paused endpoints", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read paused endpoints: %v", err)
+		return
+	}
+	response := make([]APIPause, 0, len(pauses))
+	for _, pause := range pauses {
+		response = append(response, newAPIPause(pause))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
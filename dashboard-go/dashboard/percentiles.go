@@ -0,0 +1,73 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// LatencyPercentiles are the latency percentiles of one window, estimated
+// from the checker's latency histograms
+type LatencyPercentiles struct {
+	Window string    `json:"window"` // "1h" or "24h"
+	Since  time.Time `json:"since"`
+	Count  int       `json:"count"` // checks with a response
+	P50Ms  int64     `json:"p50_ms"`
+	P95Ms  int64     `json:"p95_ms"`
+	P99Ms  int64     `json:"p99_ms"`
+}
+
+func newLatencyPercentiles(stored []store.LatencyPercentiles) []LatencyPercentiles {
+	percentiles := make([]LatencyPercentiles, 0, len(stored))
+	for _, p := range stored {
+		percentiles = append(percentiles, LatencyPercentiles{
+			Window: p.Window,
+			Since:  p.Since,
+			Count:  p.Count,
+			P50Ms:  p.P50.Round(time.Millisecond).Milliseconds(),
+			P95Ms:  p.P95.Round(time.Millisecond).Milliseconds(),
+			P99Ms:  p.P99.Round(time.Millisecond).Milliseconds(),
+		})
+	}
+	return percentiles
+}
+
+// readLatencyPercentiles returns the endpoint's latency percentiles for
+// the detail API, or nil without Redis storage or when they cannot be
+// read, in which case they are left out
+func (s *Server) readLatencyPercentiles(ctx context.Context, endpoint string, now time.Time) []LatencyPercentiles {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	stored, err := rs.LatencyPercentiles(ctx, endpoint, now)
+	if err != nil {
+		log.Printf("[WARN] Failed to read latency percentiles for %s: %v", endpoint, err)
+		return nil
+	}
+	return newLatencyPercentiles(stored)
+}
+
+// handleAPIPercentiles returns the endpoint's p50, p95 and p99 latencies
+// over the last hour and day
+func (s *Server) handleAPIPercentiles(w http.ResponseWriter, r *http.Request, endpoint string) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Latency percentiles require Redis storage", http.StatusNotImplemented)
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	stored, err := rs.LatencyPercentiles(ctx, endpoint, time.Now().UTC())
+	if err != nil {
+		http.Error(w, "Failed to get latency percentiles", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read latency percentiles for %s: %v", endpoint, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newLatencyPercentiles(stored))
+}
@@ -0,0 +1,151 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// States of an endpoint on the public status page
+const (
+	publicStateUp       = "up"
+	publicStateDegraded = "degraded"
+	publicStateDown     = "down"
+)
+
+// Overall states of the public status page
+const (
+	publicStatusOperational   = "operational"
+	publicStatusDegraded      = "degraded"
+	publicStatusPartialOutage = "partial_outage"
+	publicStatusMajorOutage   = "major_outage"
+)
+
+// publicDegradedUptime is the 24h uptime percentage below which an
+// endpoint that is up shows as degraded
+const publicDegradedUptime = 99.0
+
+// PublicEndpoint is an endpoint as the public status page shows it: its
+// display name and state, nothing about its URL, responses or certificate
+type PublicEndpoint struct {
+	Name  string `json:"name"`
+	State string `json:"state"` // up, degraded or down
+}
+
+// PublicStatus is the public status page and the body of GET /api/public
+type PublicStatus struct {
+	Status    string           `json:"status"` // operational, degraded, partial_outage or major_outage
+	Endpoints []PublicEndpoint `json:"endpoints"`
+}
+
+// Banner describes Status for the page heading
+func (p PublicStatus) Banner() string {
+	switch p.Status {
+	case publicStatusDegraded:
+		return "Degraded performance"
+	case publicStatusPartialOutage:
+		return "Partial outage"
+	case publicStatusMajorOutage:
+		return "Major outage"
+	}
+	return "All systems operational"
+}
+
+// publicState returns the state of an endpoint for the public page. An
+// endpoint is down when its last check failed or its certificate is not
+// valid, and degraded when it is up but missed checks in the last 24h or
+// fails during a maintenance window.
+func publicState(ep EndpointData) string {
+	failing := statusCategory(ep) != "ok"
+	badCert := (ep.SSLExpiration != nil && !ep.SSLExpiration.After(time.Now())) ||
+		(ep.CertInfo != nil && ep.CertInfo.State == store.CertStateNotYetValid)
+	switch {
+	case failing && ep.InMaintenance:
+		return publicStateDegraded
+	case failing, badCert:
+		return publicStateDown
+	case len(ep.Uptime) > 0 && ep.Uptime[0].Percent != nil && *ep.Uptime[0].Percent < publicDegradedUptime:
+		return publicStateDegraded
+	}
+	return publicStateUp
+}
+
+// newPublicStatus returns the public status of the endpoints tagged
+// store.PublicTag, by name. Endpoints without a display name or a status
+// check yet are left out, so the page never shows a host name.
+func newPublicStatus(endpointData []EndpointData) PublicStatus {
+	status := PublicStatus{Endpoints: []PublicEndpoint{}}
+	down := 0
+	for _, ep := range endpointData {
+		if !slices.Contains(ep.Tags, store.PublicTag) || ep.Name == "" || ep.StatusText == "" {
+			continue
+		}
+		state := publicState(ep)
+		switch state {
+		case publicStateDown:
+			down++
+		case publicStateDegraded:
+			status.Status = publicStatusDegraded
+		}
+		status.Endpoints = append(status.Endpoints, PublicEndpoint{Name: ep.Name, State: state})
+	}
+	slices.SortStableFunc(status.Endpoints, func(a, b PublicEndpoint) int { return strings.Compare(a.Name, b.Name) })
+	switch {
+	case down > 0 && down == len(status.Endpoints):
+		status.Status = publicStatusMajorOutage
+	case down > 0:
+		status.Status = publicStatusPartialOutage
+	case status.Status == "":
+		status.Status = publicStatusOperational
+	}
+	return status
+}
+
+// publicStatus reads the public status, answering an error when the
+// endpoints cannot be read. Stale data is served as is, since the page
+// shows no update times.
+func (s *Server) publicStatus(w http.ResponseWriter, r *http.Request) (PublicStatus, bool) {
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	endpointData, _, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Status unavailable", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to get endpoints for the public status: %v", err)
+		return PublicStatus{}, false
+	}
+	return newPublicStatus(endpointData), true
+}
+
+// handlePublicStatus serves GET /status, the status page for customers,
+// which needs no login
+func (s *Server) handlePublicStatus(w http.ResponseWriter, r *http.Request) {
+	status, ok := s.publicStatus(w, r)
+	if !ok {
+		return
+	}
+	tmpl, ok := s.pageTemplates(w)
+	if !ok {
+		return
+	}
+	defer countRender(time.Now())
+	if err := tmpl.ExecuteTemplate(w, "status.html", status); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		log.Printf("[ERROR] Failed to render status template: %v", err)
+	}
+}
+
+// handleAPIPublic serves GET /api/public, the public status page as JSON,
+// which needs neither a login nor an API token
+func (s *Server) handleAPIPublic(w http.ResponseWriter, r *http.Request) {
+	status, ok := s.publicStatus(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
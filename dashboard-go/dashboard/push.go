@@ -0,0 +1,344 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// wsSendQueueSize is how many messages may wait for a /ws client; a client
+// that falls further behind is disconnected rather than slowing the others
+const wsSendQueueSize = 64
+
+// wsSubscribeAll subscribes to the events of every endpoint
+const wsSubscribeAll = "*"
+
+// wsRequest is a message from a /ws client. Subscribe adds endpoints to the
+// subscription, Unsubscribe removes them.
+type wsRequest struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// wsSubscribed acknowledges a request with the whole subscription
+type wsSubscribed struct {
+	Subscribed []string `json:"subscribed"`
+}
+
+// wsErrorMessage reports a request the server could not understand
+type wsErrorMessage struct {
+	Error string `json:"error"`
+}
+
+// wsEvent is pushed to the clients subscribed to its endpoint
+type wsEvent struct {
+	Endpoint string    `json:"endpoint"`
+	Event    string    `json:"event"` // down, up, error_class, cert_warning, cert_critical, cert_expired, cert_ok, cert_renewed or cert_changed
+	Kind     string    `json:"kind"`
+	Old      string    `json:"old"`
+	New      string    `json:"new"`
+	At       time.Time `json:"at"`
+}
+
+func newWSEvent(event store.Event) wsEvent {
+	name := event.Kind
+	switch event.Kind {
+	case store.EventKindStatus:
+		name = event.New
+	case store.EventKindCert:
+		name = "cert_" + event.New
+	}
+	return wsEvent{Endpoint: event.Endpoint, Event: name, Kind: event.Kind, Old: event.Old, New: event.New, At: event.At}
+}
+
+// eventHub fans the events published by the checker out to the /ws
+// clients, over a single Redis subscription started with the first client
+type eventHub struct {
+	store   *store.RedisStore
+	start   sync.Once
+	cancel  context.CancelFunc // ends the subscription
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+	closing bool           // shutting down, accepting no more clients
+	active  sync.WaitGroup // clients added and not yet removed
+}
+
+func newEventHub(rs *store.RedisStore) *eventHub {
+	return &eventHub{store: rs, clients: make(map[*wsClient]struct{})}
+}
+
+// add registers a client, unless the hub is shutting down; every client
+// added must be removed once its connection ends
+func (h *eventHub) add(c *wsClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return false
+	}
+	h.start.Do(func() {
+		var ctx context.Context
+		ctx, h.cancel = context.WithCancel(context.Background())
+		go h.store.WatchEvents(ctx, h.broadcast)
+	})
+	h.clients[c] = struct{}{}
+	h.active.Add(1)
+	return true
+}
+
+func (h *eventHub) remove(c *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+	h.active.Done()
+}
+
+// shutdown ends the subscription and every connection with 1001 Going
+// Away, then waits until the clients are removed or ctx is done
+func (h *eventHub) shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	if h.cancel != nil {
+		h.cancel()
+	}
+	for c := range h.clients {
+		c.stop(wsCloseGoingAway, "server shutting down")
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// broadcast queues event for every client subscribed to its endpoint,
+// dropping those whose queue is full. Events of acknowledged endpoints and
+// of those in a maintenance window are not pushed.
+func (h *eventHub) broadcast(event store.Event) {
+	if event.Acknowledged || event.InMaintenance {
+		return
+	}
+	message, err := json.Marshal(newWSEvent(event))
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !c.subscribed(event.Endpoint) {
+			continue
+		}
+		select {
+		case c.queue <- message:
+		default:
+			delete(h.clients, c)
+			log.Printf("[WARN] Dropping WebSocket client %s: %d messages waiting", c.addr, wsSendQueueSize)
+			c.stop(wsClosePolicyViolation, "send queue full")
+		}
+	}
+}
+
+// wsClient is one /ws connection and its subscription
+type wsClient struct {
+	conn  *wsConn
+	addr  string
+	queue chan []byte
+
+	mu        sync.Mutex
+	endpoints map[string]bool
+
+	stopOnce    sync.Once
+	done        chan struct{}
+	closeCode   uint16
+	closeReason string
+}
+
+func newWSClient(conn *wsConn, addr string) *wsClient {
+	return &wsClient{
+		conn:      conn,
+		addr:      addr,
+		queue:     make(chan []byte, wsSendQueueSize),
+		endpoints: make(map[string]bool),
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *wsClient) subscribed(endpoint string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endpoints[endpoint] || c.endpoints[wsSubscribeAll]
+}
+
+// update applies a request and returns the resulting subscription, sorted
+func (c *wsClient) update(request wsRequest) []string {
+	normalize := func(endpoint string) string {
+		if endpoint == wsSubscribeAll {
+			return endpoint
+		}
+		return store.NormalizeEndpoint(endpoint)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, endpoint := range request.Subscribe {
+		c.endpoints[normalize(endpoint)] = true
+	}
+	for _, endpoint := range request.Unsubscribe {
+		delete(c.endpoints, normalize(endpoint))
+	}
+	return slices.Sorted(maps.Keys(c.endpoints))
+}
+
+// stop ends the connection with a close frame of code and reason; only
+// the first call counts
+func (c *wsClient) stop(code uint16, reason string) {
+	c.stopOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.done)
+	})
+}
+
+func (c *wsClient) send(message any) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.conn.writeFrame(wsOpText, payload)
+}
+
+// readLoop handles the client's requests until it leaves or breaks the
+// protocol
+func (c *wsClient) readLoop() {
+	for {
+		opcode, message, err := c.conn.readMessage()
+		var protocolErr *wsError
+		switch {
+		case errors.As(err, &protocolErr):
+			c.stop(protocolErr.code, protocolErr.reason)
+			return
+		case err != nil:
+			c.stop(wsCloseNormal, "")
+			return
+		}
+
+		var request wsRequest
+		if opcode != wsOpText || json.Unmarshal(message, &request) != nil {
+			err = c.send(wsErrorMessage{Error: `expected {"subscribe": [...]} or {"unsubscribe": [...]}`})
+		} else {
+			err = c.send(wsSubscribed{Subscribed: c.update(request)})
+		}
+		if err != nil {
+			c.stop(wsCloseNormal, "")
+			return
+		}
+	}
+}
+
+// writeLoop sends queued events and pings until the connection stops
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			c.conn.close(c.closeCode, c.closeReason)
+			return
+		case message := <-c.queue:
+			if err := c.conn.writeFrame(wsOpText, message); err != nil {
+				c.stop(wsCloseNormal, "")
+			}
+		case <-ping.C:
+			if err := c.conn.writeFrame(wsOpPing, nil); err != nil {
+				c.stop(wsCloseNormal, "")
+			}
+		}
+	}
+}
+
+// wsOriginAllowed accepts requests without an Origin (bots, CLIs), from
+// the dashboard's own host, and from the origins of WS_ALLOWED_ORIGINS
+// ("*" for any), so other web pages cannot open sockets with a visitor's
+// credentials
+func wsOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsTokenValid checks the WS_TOKEN, sent as a bearer token or, since
+// browsers cannot set headers on WebSocket requests, as ?token=
+func wsTokenValid(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		given = r.URL.Query().Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// handleWS upgrades to a WebSocket that pushes the state-change events of
+// the endpoints the client subscribes to
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "Push requires Redis storage", http.StatusNotImplemented)
+		return
+	}
+	if !wsOriginAllowed(r, s.config.WSAllowedOrigins) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !wsTokenValid(r, s.config.WSToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := wsUpgrade(w, r)
+	if err != nil {
+		log.Printf("[WARN] WebSocket upgrade from %s failed: %v", r.RemoteAddr, err)
+		return
+	}
+
+	s.hubOnce.Do(func() { s.hub = newEventHub(rs) })
+	client := newWSClient(conn, r.RemoteAddr)
+	if s.hub == nil || !s.hub.add(client) {
+		conn.close(wsCloseGoingAway, "server shutting down")
+		return
+	}
+	defer s.hub.remove(client)
+
+	go client.readLoop()
+	client.writeLoop()
+}
@@ -0,0 +1,152 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// routes returns the dashboard's own mux. Handlers that libraries register
+// on http.DefaultServeMux (expvar, pprof) are not served by it; the
+// debug routes mount them explicitly with DEBUG_ENDPOINTS.
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /api/endpoints", s.handleAPIEndpoints)
+	mux.HandleFunc("POST /api/endpoints", s.handleAPIAddEndpoint)
+	mux.HandleFunc("DELETE /api/endpoints", s.handleAPIRemoveEndpoint)
+	mux.HandleFunc("POST /api/endpoints/recheck", s.handleAPIRecheck)
+	mux.HandleFunc("POST /api/endpoints/ack", s.handleAPIAck)
+	mux.HandleFunc("DELETE /api/endpoints/ack", s.handleAPIUnack)
+	mux.HandleFunc("GET /api/endpoints/acks", s.handleAPIAcks)
+	mux.HandleFunc("POST /api/endpoints/pause", s.handleAPIPause)
+	mux.HandleFunc("DELETE /api/endpoints/pause", s.handleAPIResume)
+	mux.HandleFunc("GET /api/endpoints/pauses", s.handleAPIPauses)
+	mux.HandleFunc("GET /api/maintenance", s.handleAPIMaintenanceWindows)
+	mux.HandleFunc("POST /api/maintenance", s.handleAPIAddMaintenanceWindow)
+	mux.HandleFunc("DELETE /api/maintenance", s.handleAPIRemoveMaintenanceWindow)
+	mux.HandleFunc("GET /api/v1/endpoints", s.handleAPIv1Endpoints)
+	mux.HandleFunc("GET /api/expiring", s.handleAPIExpiring)
+	mux.HandleFunc("GET /api/summary", s.handleAPISummary)
+	mux.HandleFunc("GET /api/endpoints/", s.handleAPIEndpoint)
+	mux.HandleFunc("GET /api/endpoints/detail", s.handleAPIEndpointByURL)
+	mux.HandleFunc("GET /api/events", s.handleAPIEvents)
+	mux.HandleFunc("GET /api/alerts", s.handleAPIAlerts)
+	mux.HandleFunc("GET /api/slo", s.handleAPISLOs)
+	mux.HandleFunc("GET /api/pool", s.handleAPIPool)
+	mux.HandleFunc("GET /status", s.handlePublicStatus)
+	mux.HandleFunc("GET /api/public", s.handleAPIPublic)
+	mux.HandleFunc("GET /api/openapi.json", s.handleAPIOpenAPI)
+	mux.HandleFunc("GET /api/version", s.handleAPIVersion)
+	mux.HandleFunc("GET /badge", s.handleBadge)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /feed.atom", s.handleFeed)
+	mux.HandleFunc("GET /calendar.ics", s.handleCalendar)
+	mux.HandleFunc("GET /ws", s.handleWS)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	if s.config.DebugEndpoints {
+		addDebugRoutes(mux)
+	}
+	// Only for GET, so that other methods on the routes above get a 405
+	mux.HandleFunc("GET /", s.notFound)
+	return mux
+}
+
+// notFoundPage is served for unknown paths outside the API
+const notFoundPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Not found - Certs-n-Status</title></head>
+<body>
+<h1>Not found</h1>
+<p>There is nothing at <code>%s</code>. <a href="%s/">Back to the dashboard</a></p>
+</body>
+</html>
+`
+
+// notFound answers 404 as JSON under /api/ and as a short page elsewhere,
+// instead of rendering the dashboard for scanners and /favicon.ico
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	writeNotFound(w, s.config.BasePath+r.URL.Path, s.config.BasePath)
+}
+
+// writeNotFound answers 404 for the request path, with a link to the
+// dashboard under basePath
+func writeNotFound(w http.ResponseWriter, path, basePath string) {
+	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasPrefix(path, basePath+"/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not found", "path": path})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, notFoundPage, html.EscapeString(path), html.EscapeString(basePath))
+}
+
+// handler returns the routes behind the configured middleware, the
+// outermost last: compression, the dashboard login, API tokens, rate
+// limiting, the base path and the access log
+func (s *Server) handler() http.Handler {
+	app := gzipHandler(s.mux)
+	handler := app
+	if s.auth != nil {
+		handler = basicAuthHandler(app, s.auth, s.config.TrustProxy)
+		log.Printf("[INFO] Requiring a dashboard login (except on /healthz and the public status page)")
+	}
+	if s.config.DebugEndpoints {
+		log.Printf("[WARN] Serving pprof profiles and expvar variables under /debug/ (DEBUG_ENDPOINTS)")
+	}
+	if s.apiTokens != nil {
+		handler = apiTokenHandler(app, handler, s.apiTokens, s.config.TrustProxy)
+		log.Printf("[INFO] Requiring one of %d API tokens on /api/", len(s.apiTokens))
+	}
+	if s.config.RateLimitRPS > 0 {
+		handler = rateLimitHandler(handler, newRateLimiter(s.config.RateLimitRPS, s.config.RateLimitBurst), s.config.TrustProxy)
+		log.Printf("[INFO] Limiting each client to %g requests per second (burst %d)", s.config.RateLimitRPS, s.config.RateLimitBurst)
+	}
+	if s.config.BasePath != "" {
+		handler = basePathHandler(handler, s.config.BasePath)
+		log.Printf("[INFO] Serving every route under %s/", s.config.BasePath)
+	}
+	return accessLogHandler(handler, s.config.Log.Format, s.config.LogDebug, s.config.TrustProxy, s.config.BasePath)
+}
+
+// cleanBasePath normalizes BASE_PATH to a leading slash and no trailing
+// one, "" for the root
+func cleanBasePath(basePath string) (string, error) {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(basePath, "?#%") || strings.Contains("/"+basePath+"/", "/../") || strings.Contains("/"+basePath+"/", "/./") {
+		return "", fmt.Errorf("invalid BASE_PATH %q (use a plain path such as /certs)", basePath)
+	}
+	return "/" + basePath, nil
+}
+
+// basePathHandler serves the routes of h under basePath, stripping it from
+// the request path so the routes and the other middleware see the paths
+// they were written for. The bare basePath redirects to basePath/, and
+// paths outside it are not found.
+func basePathHandler(h http.Handler, basePath string) http.Handler {
+	stripped := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == basePath:
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			stripped.ServeHTTP(w, r)
+		default:
+			writeNotFound(w, r.URL.Path, basePath)
+		}
+	})
+}
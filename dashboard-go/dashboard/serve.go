@@ -0,0 +1,118 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Defaults of the HTTP_*_TIMEOUT and SHUTDOWN_TIMEOUT settings; 0 disables
+// a timeout
+const (
+	// defaultReadHeaderTimeout cuts off clients that trickle their request
+	// headers to hold connections open (slowloris)
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	// defaultWriteTimeout leaves room for a page render that waits out
+	// STORAGE_TIMEOUT and the read retries
+	defaultWriteTimeout = time.Minute
+	defaultIdleTimeout  = 2 * time.Minute
+	// defaultShutdownTimeout fits within the 30 second grace period
+	// Kubernetes and Docker give a container between SIGTERM and SIGKILL
+	defaultShutdownTimeout = 25 * time.Second
+)
+
+// newHTTPServer returns the server for handler with the configured
+// timeouts. WebSocket connections are taken over from it, so they manage
+// their own deadlines instead.
+func (s *Server) newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + s.config.ServerPort,
+		Handler:           handler,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+}
+
+// serve answers requests on ln, over TLS when srv has a TLSConfig, until
+// ctx is done, then stops accepting connections and gives the requests in
+// flight and the /ws clients up to SHUTDOWN_TIMEOUT to finish. Connections
+// still open after that are closed. It returns nil after a shutdown and
+// the error of a failed listener otherwise.
+func (s *Server) serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("[INFO] Shutting down, waiting up to %s for requests in flight", s.config.ShutdownTimeout)
+	shutdownCtx := context.Background()
+	if s.config.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, s.config.ShutdownTimeout)
+		defer cancel()
+	}
+
+	// Shutdown does not track hijacked connections, so the /ws clients are
+	// sent a close frame of their own alongside
+	wsClosed := make(chan error, 1)
+	go func() { wsClosed <- s.closeWebSockets(shutdownCtx) }()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[WARN] Requests still running after %s, closing their connections: %v", s.config.ShutdownTimeout, err)
+		srv.Close()
+	}
+	if err := <-wsClosed; err != nil {
+		log.Printf("[WARN] WebSocket clients still connected after %s, dropping them: %v", s.config.ShutdownTimeout, err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// closeWebSockets ends every /ws connection with 1001 Going Away and waits
+// until they are closed or ctx is done. No /ws client is accepted after it.
+func (s *Server) closeWebSockets(ctx context.Context) error {
+	// Once ran, the hub is no longer created by a first /ws client
+	s.hubOnce.Do(func() {})
+	if s.hub == nil {
+		return nil
+	}
+	return s.hub.shutdown(ctx)
+}
+
+// startHTTPSRedirect listens on REDIRECT_HTTP_PORT, redirecting every
+// request to HTTPS until ctx is done. Redirects are answered at once, so
+// the listener is simply closed on shutdown.
+func (s *Server) startHTTPSRedirect(ctx context.Context) error {
+	srv := s.newHTTPServer(httpsRedirectHandler(s.config.ServerPort))
+	srv.Addr = ":" + s.config.RedirectHTTPPort
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	context.AfterFunc(ctx, func() { srv.Close() })
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ERROR] HTTPS redirect on port %s stopped: %v", s.config.RedirectHTTPPort, err)
+		}
+	}()
+	log.Printf("[INFO] Redirecting plain HTTP on port %s to HTTPS", s.config.RedirectHTTPPort)
+	return nil
+}
@@ -0,0 +1,92 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"certs-n-status/store"
+)
+
+// APISLO is an SLO computed by the checker in API responses
+type APISLO struct {
+	Tag                string  `json:"tag"`
+	Objective          float64 `json:"objective"`
+	Window             string  `json:"window"`
+	ExcludeMaintenance bool    `json:"exclude_maintenance"`
+	ExcludeUnknown     bool    `json:"exclude_unknown"`
+	Endpoints          int     `json:"endpoints"`
+	Checks             int     `json:"checks"`
+	Good               int     `json:"good"`
+	MaintenanceChecks  int     `json:"maintenance_checks"`
+	UnknownHours       int     `json:"unknown_hours"`
+	Compliance         float64 `json:"compliance"`
+	BudgetRemaining    float64 `json:"budget_remaining"`
+	BurnRate           float64 `json:"burn_rate"`
+	BurnRateThreshold  float64 `json:"burn_rate_threshold"`
+	Burning            bool    `json:"burning"`
+	UpdatedAt          string  `json:"updated_at"`
+}
+
+func newAPISLO(status store.SLOStatus) APISLO {
+	return APISLO{
+		Tag:                status.Tag,
+		Objective:          status.Objective,
+		Window:             status.Window,
+		ExcludeMaintenance: status.ExcludeMaintenance,
+		ExcludeUnknown:     status.ExcludeUnknown,
+		Endpoints:          status.Endpoints,
+		Checks:             status.Checks,
+		Good:               status.Good,
+		MaintenanceChecks:  status.MaintenanceChecks,
+		UnknownHours:       status.UnknownHours,
+		Compliance:         status.Compliance,
+		BudgetRemaining:    status.BudgetRemaining,
+		BurnRate:           status.BurnRate,
+		BurnRateThreshold:  status.BurnRateThreshold,
+		Burning:            status.Burning,
+		UpdatedAt:          apiTime(status.UpdatedAt),
+	}
+}
+
+// readSLOs returns the SLOs for the dashboard, or nil without Redis
+// storage or when they cannot be read, in which case the section is left
+// out
+func (s *Server) readSLOs(ctx context.Context) []store.SLOStatus {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	statuses, err := rs.SLOStatuses(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read SLOs: %v", err)
+		return nil
+	}
+	return statuses
+}
+
+// handleAPISLOs returns the SLOs of the checker's SLO_TARGETS by tag, as
+// of its last hourly rollup: compliance, remaining error budget and burn
+// rate
+func (s *Server) handleAPISLOs(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.store.(*store.RedisStore)
+	if !ok {
+		http.Error(w, "SLOs require Redis storage", http.StatusNotImplemented)
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+	statuses, err := rs.SLOStatuses(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get SLOs", storeErrorStatus(ctx))
+		log.Printf("[ERROR] Failed to read SLOs: %v", err)
+		return
+	}
+	response := make([]APISLO, 0, len(statuses))
+	for _, status := range statuses {
+		response = append(response, newAPISLO(status))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
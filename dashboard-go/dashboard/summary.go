@@ -0,0 +1,169 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Summary aggregates a set of endpoints the way the dashboard header counts
+// them, served by /api/summary
+type Summary struct {
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Total         int            `json:"total"`
+	Healthy       int            `json:"healthy"`        // 2xx responses
+	SSLWarning    int            `json:"ssl_warning"`    // expiring within 30 days or not valid yet
+	Errors        int            `json:"errors"`         // no response, 4xx or 5xx
+	Acknowledged  int            `json:"acknowledged"`   // left out of the three counts above
+	InMaintenance int            `json:"in_maintenance"` // not counted as errors
+	Paused        int            `json:"paused"`         // left out of every count above
+	StatusClasses map[string]int `json:"status_classes"`
+	SSLClasses    map[string]int `json:"ssl_classes"`
+	SoonestExpiry *SummaryExpiry `json:"soonest_expiry,omitempty"`
+	OldestUpdate  *SummaryUpdate `json:"oldest_update,omitempty"`
+	StaleSince    *time.Time     `json:"stale_since,omitempty"` // set when cached data is summarized
+	// CheckerLastSeen is when the checker last finished a check cycle,
+	// absent without Redis storage or before its first cycle
+	CheckerLastSeen *time.Time `json:"checker_last_seen,omitempty"`
+	// Checkers are the checker instances that finished a check cycle
+	// within the heartbeat's stale period, by CHECKER_ID, the most recent
+	// first
+	Checkers []SummaryChecker `json:"checkers,omitempty"`
+	Groups   []GroupSummary   `json:"groups,omitempty"` // set with group_by=tag or domain
+}
+
+// SummaryChecker is one active checker instance and its last heartbeat
+type SummaryChecker struct {
+	ID            string    `json:"id"`
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// GroupSummary counts the endpoints of one group, like the dashboard's
+// group headings
+type GroupSummary struct {
+	Name          string `json:"name"`
+	Total         int    `json:"total"`
+	Healthy       int    `json:"healthy"`
+	SSLWarning    int    `json:"ssl_warning"`
+	Errors        int    `json:"errors"`
+	Acknowledged  int    `json:"acknowledged"`
+	InMaintenance int    `json:"in_maintenance"`
+	Paused        int    `json:"paused"`
+}
+
+type SummaryExpiry struct {
+	Endpoint string `json:"endpoint"`
+	DaysLeft int    `json:"days_left"`
+}
+
+type SummaryUpdate struct {
+	Endpoint  string    `json:"endpoint"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// summarizeEndpoints aggregates endpointData. Status and SSL classes are
+// counted by the dashboard color without their prefix, e.g. "server-error"
+// or "critical"; endpoints without a certificate have no SSL class.
+// Acknowledged endpoints are only counted as such, not as healthy, SSL
+// warnings or errors, and endpoints in a maintenance window are not counted
+// as errors. Paused endpoints are only counted as paused: their results are
+// on hold, so they are left out of the classes, expiry and update too.
+func summarizeEndpoints(endpointData []EndpointData, now time.Time) Summary {
+	summary := Summary{
+		GeneratedAt:   now,
+		Total:         len(endpointData),
+		StatusClasses: make(map[string]int),
+		SSLClasses:    make(map[string]int),
+	}
+	for _, ep := range endpointData {
+		if ep.Pause != nil {
+			summary.Paused++
+			continue
+		}
+		if ep.Ack != nil {
+			summary.Acknowledged++
+		} else if ep.InMaintenance {
+			summary.InMaintenance++
+		}
+		if ep.Ack == nil && ep.StatusCode >= 200 && ep.StatusCode < 300 {
+			summary.Healthy++
+		}
+		if ep.Ack == nil && ep.SSLClass != "" && ep.SSLClass != "ssl-ok" {
+			summary.SSLWarning++
+		}
+		switch statusCategory(ep) {
+		case "error", "4xx", "5xx":
+			if ep.Ack == nil && !ep.InMaintenance {
+				summary.Errors++
+			}
+		}
+
+		summary.StatusClasses[strings.TrimPrefix(ep.StatusClass, "status-")]++
+		if ep.SSLClass != "" {
+			summary.SSLClasses[strings.TrimPrefix(ep.SSLClass, "ssl-")]++
+		}
+		if ep.DaysLeft != nil && (summary.SoonestExpiry == nil || *ep.DaysLeft < summary.SoonestExpiry.DaysLeft) {
+			summary.SoonestExpiry = &SummaryExpiry{Endpoint: ep.Endpoint, DaysLeft: *ep.DaysLeft}
+		}
+		if updated := lastUpdate(ep); updated != nil && (summary.OldestUpdate == nil || updated.Before(summary.OldestUpdate.UpdatedAt)) {
+			summary.OldestUpdate = &SummaryUpdate{Endpoint: ep.Endpoint, UpdatedAt: *updated}
+		}
+	}
+	return summary
+}
+
+// handleAPISummary serves GET /api/summary, the summary of the endpoints
+// matching the filterEndpoints query parameters, and of each group of them
+// with group_by=tag or domain
+func (s *Server) handleAPISummary(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.viewQuery(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := s.storeContext(r)
+	defer cancel()
+
+	endpointData, staleSince, err := s.getAllEndpointData(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get endpoints", storeErrorStatus(ctx))
+		return
+	}
+	now := time.Now().UTC()
+	if endpointData, err = filterEndpoints(query, endpointData, now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy, err := parseGroupBy(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary := summarizeEndpoints(endpointData, now)
+	if groupBy != "none" {
+		for _, group := range groupEndpoints(endpointData, groupBy, now) {
+			summary.Groups = append(summary.Groups, GroupSummary{
+				Name:          group.Name,
+				Total:         group.Summary.Total,
+				Healthy:       group.Summary.Healthy,
+				SSLWarning:    group.Summary.SSLWarning,
+				Errors:        group.Summary.Errors,
+				Acknowledged:  group.Summary.Acknowledged,
+				InMaintenance: group.Summary.InMaintenance,
+				Paused:        group.Summary.Paused,
+			})
+		}
+	}
+	if !staleSince.IsZero() {
+		summary.StaleSince = &staleSince
+	} else {
+		heartbeat := s.readHeartbeat(ctx)
+		summary.CheckerLastSeen = checkerLastSeen(heartbeat)
+		summary.Checkers = s.readCheckers(ctx, heartbeat, now)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(s.staleStatus(w, ctx, staleSince))
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,127 @@
+package dashboard
+
+import (
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// embeddedTemplates are the page templates built into the binary, so it
+// runs without a templates directory next to it
+//
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// templateFiles are the templates of the pages, the first one executed by
+// default
+var templateFiles = []string{"index.html", "status.html"}
+
+// templateFuncs are the functions available to the templates, including
+// customized ones
+var templateFuncs = template.FuncMap{
+	"add":        func(a, b int) int { return a + b },
+	"mul":        func(a, b float64) float64 { return a * b },
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"formatTime": formatTime,
+	"displayURL": store.DisplayEndpoint,
+	"percent":    percent,
+}
+
+// formatTime formats a time.Time or *time.Time with a Go layout, in
+// location when one is given, e.g.
+// {{formatTime "2006-01-02 15:04" .LastStatusUpdate $.Location}}. A nil or
+// zero time is "".
+func formatTime(layout string, value any, location ...*time.Location) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v != nil {
+			t = *v
+		}
+	default:
+		return "", fmt.Errorf("formatTime: %T is not a time", value)
+	}
+	if t.IsZero() {
+		return "", nil
+	}
+	if len(location) > 0 && location[0] != nil {
+		t = t.In(location[0])
+	}
+	return t.Format(layout), nil
+}
+
+// percent is part of total as a percentage with one decimal, e.g. "99.5%",
+// or "—" of a total of zero
+func percent(part, total int) string {
+	if total == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+// templateErrorPage is served instead of a page whose templates do not
+// parse with TEMPLATE_RELOAD
+const templateErrorPage = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Template error - Certs-n-Status</title></head>
+<body>
+<h1>Template error</h1>
+<p>The templates of TEMPLATE_DIR do not parse; fix them and reload.</p>
+<pre>%s</pre>
+</body>
+</html>
+`
+
+// parseTemplates parses templateFiles from dir (TEMPLATE_DIR), or from the
+// embedded copies when dir is ""
+func parseTemplates(dir string) (*template.Template, error) {
+	var templates fs.FS
+	if dir != "" {
+		templates = os.DirFS(dir)
+	} else {
+		var err error
+		if templates, err = fs.Sub(embeddedTemplates, "templates"); err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := template.New(templateFiles[0]).Funcs(templateFuncs).ParseFS(templates, templateFiles...)
+	if err != nil {
+		if dir != "" {
+			return nil, fmt.Errorf("failed to parse templates of TEMPLATE_DIR %s: %w", dir, err)
+		}
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return tmpl, nil
+}
+
+// pageTemplates returns the templates to render a page with: those parsed
+// at startup, or with TEMPLATE_RELOAD those of TEMPLATE_DIR as they are
+// now. A parse error is answered with a page showing it.
+func (s *Server) pageTemplates(w http.ResponseWriter) (*template.Template, bool) {
+	if !s.config.TemplateReload {
+		return s.templates, true
+	}
+	tmpl, err := parseTemplates(s.config.TemplateDir)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, templateErrorPage, html.EscapeString(err.Error()))
+		return nil, false
+	}
+	return tmpl, true
+}
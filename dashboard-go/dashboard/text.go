@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"certs-n-status/store"
+)
+
+// ANSI colors of the text dashboard, by status and SSL class
+var ansiColors = map[string]string{
+	"status-success":      "\x1b[32m",
+	"status-redirect":     "\x1b[33m",
+	"status-client-error": "\x1b[31m",
+	"status-server-error": "\x1b[31m",
+	"status-error":        "\x1b[90m",
+	"ssl-ok":              "\x1b[32m",
+	"ssl-warning":         "\x1b[33m",
+	"ssl-critical":        "\x1b[1;31m",
+	"ssl-expired":         "\x1b[1;31m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// writeTextDashboard writes the dashboard as an aligned table for terminals,
+// with ANSI colors when color is set
+func writeTextDashboard(w io.Writer, data DashboardData, color bool) {
+	counts := func(n, all int) string {
+		if data.Filtered {
+			return fmt.Sprintf("%d of %d", n, all)
+		}
+		return fmt.Sprint(n)
+	}
+	acked := ""
+	if data.AllMaintenance > 0 {
+		acked += fmt.Sprintf(", %s in maintenance", counts(data.MaintenanceCount, data.AllMaintenance))
+	}
+	if data.AllAcked > 0 {
+		acked += fmt.Sprintf(", %s acknowledged", counts(data.AckCount, data.AllAcked))
+	}
+	if data.AllPaused > 0 {
+		acked += fmt.Sprintf(", %s paused", counts(data.PausedCount, data.AllPaused))
+	}
+	fmt.Fprintf(w, "CertsNStatus at %s: %s endpoints, %s healthy, %s SSL expiring soon%s\n",
+		data.CurrentTime, counts(data.TotalEndpoints, data.AllEndpoints),
+		counts(data.HealthyCount, data.AllHealthy), counts(data.SSLWarningCount, data.AllSSLWarning), acked)
+	if data.StaleNotice != "" {
+		fmt.Fprintf(w, "WARNING: %s\n", data.StaleNotice)
+	}
+	if data.CheckerNotice != "" {
+		fmt.Fprintf(w, "WARNING: %s\n", data.CheckerNotice)
+	}
+	fmt.Fprintln(w)
+
+	// Color codes are escaped so tabwriter counts each as one character;
+	// every colored cell has the same two, keeping the columns aligned
+	colored := func(class, text string) string {
+		if !color {
+			return text
+		}
+		code, ok := ansiColors[class]
+		if !ok {
+			code = ansiReset
+		}
+		return "\xff" + code + "\xff" + text + "\xff" + ansiReset + "\xff"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.StripEscape)
+	fmt.Fprintln(tw, "#\tENDPOINT\tSTATUS\tSSL\tUPDATED")
+	for i, ep := range data.Endpoints {
+		status := ep.StatusText
+		if status == "" {
+			status = "-"
+		}
+		updated := ep.UpdateText
+		if ep.Stale {
+			updated += " (stale)"
+		} else if ep.PausedBySchedule || ep.Pause != nil {
+			updated += " (paused)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, store.DisplayEndpoint(ep.Endpoint),
+			colored(ep.StatusClass, status), colored(ep.SSLClass, ep.SSLText), updated)
+	}
+	tw.Flush()
+	if len(data.Endpoints) == 0 {
+		if data.Filtered {
+			fmt.Fprintln(w, "No endpoints match")
+		} else {
+			fmt.Fprintln(w, "No endpoints checked yet")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package dashboard
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate of TLS_CERT_FILE and TLS_KEY_FILE,
+// loading it again when either file changes, so a renewed certificate is
+// used from the next handshake without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// newCertReloader loads the certificate, failing when the files are
+// missing or do not hold a matching certificate and key
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// modTimes returns the modification times of both files
+func (c *certReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// reload loads both files when their modification times differ from the
+// loaded ones. On an error the previous certificate stays in use and the
+// files are tried again on the next call, as a renewal may have replaced
+// the certificate but not yet the key.
+func (c *certReloader) reload() error {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return fmt.Errorf("TLS certificate: %w", err)
+	}
+	if c.cert != nil && certMod.Equal(c.certMod) && keyMod.Equal(c.keyMod) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("TLS certificate %s: %w", c.certFile, err)
+	}
+	if c.cert != nil {
+		log.Printf("[INFO] Reloaded the TLS certificate from %s", c.certFile)
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// getCertificate is the tls.Config callback, checking the files on every
+// handshake; a stat of two files costs little next to the handshake
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.reload(); err != nil {
+		log.Printf("[WARN] Keeping the current TLS certificate: %v", err)
+	}
+	return c.cert, nil
+}
+
+// tlsConfig returns the server configuration presenting the certificate
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: c.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// httpsRedirectHandler sends plain HTTP requests to the same URL over
+// HTTPS on httpsPort
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			host = "[" + host + "]" // IPv6
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,93 @@
+package dashboard
+
+import (
+	"fmt"
+
+	"certs-n-status/store"
+)
+
+// UptimeCell is an endpoint's uptime in one of store.UptimeWindows
+type UptimeCell struct {
+	Window  string
+	Percent *float64 // rounded down to one decimal; nil when unknown
+	Checks  int
+	Up      int
+}
+
+// newUptimeCells returns a cell for each of store.UptimeWindows, in order.
+// Windows the checker has not computed yet, or without any checks, are
+// unknown.
+func newUptimeCells(stored []store.Uptime) []UptimeCell {
+	cells := make([]UptimeCell, len(store.UptimeWindows))
+	for i, window := range store.UptimeWindows {
+		cells[i].Window = window.Name
+		for _, u := range stored {
+			if u.Window != window.Name || u.Checks == 0 {
+				continue
+			}
+			// Rounded down, so only a window without a failed check shows 100.0
+			percent := float64(u.Up*1000/u.Checks) / 10
+			cells[i].Percent = &percent
+			cells[i].Checks, cells[i].Up = u.Checks, u.Up
+		}
+	}
+	return cells
+}
+
+// Text is the cell's table text, e.g. "99.9%"
+func (c UptimeCell) Text() string {
+	if c.Percent == nil {
+		return "—"
+	}
+	return fmt.Sprintf("%.1f%%", *c.Percent)
+}
+
+// Title explains the percentage in the cell's tooltip
+func (c UptimeCell) Title() string {
+	if c.Percent == nil {
+		return fmt.Sprintf("No checks recorded in the last %s", c.Window)
+	}
+	return fmt.Sprintf("%d of %d checks up in the last %s", c.Up, c.Checks, c.Window)
+}
+
+// Class colors the cell like the status badges: from 99.9% the window is
+// healthy, from 99% it warrants a look
+func (c UptimeCell) Class() string {
+	switch {
+	case c.Percent == nil:
+		return "no-data"
+	case *c.Percent >= 99.9:
+		return "uptime-good"
+	case *c.Percent >= 99:
+		return "uptime-warning"
+	default:
+		return "uptime-bad"
+	}
+}
+
+// uptimeWindowNames are the column headers of the uptime cells
+func uptimeWindowNames() []string {
+	names := make([]string, len(store.UptimeWindows))
+	for i, window := range store.UptimeWindows {
+		names[i] = window.Name
+	}
+	return names
+}
+
+// apiUptime returns the percentage of each window, null when unknown, or
+// nil when no window is known
+func apiUptime(cells []UptimeCell) map[string]*float64 {
+	var uptime map[string]*float64
+	for _, cell := range cells {
+		if cell.Percent != nil {
+			uptime = make(map[string]*float64, len(cells))
+			break
+		}
+	}
+	for _, cell := range cells {
+		if uptime != nil {
+			uptime[cell.Window] = cell.Percent
+		}
+	}
+	return uptime
+}
@@ -0,0 +1,125 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// viewParams are the query parameters a view may preset
+var viewParams = append(slices.Clone(endpointFilterParams), "sort", "order", "group_by")
+
+// views are the named presets of VIEWS_FILE, each a set of query parameters
+type views map[string]url.Values
+
+// loadViews reads VIEWS_FILE, a YAML mapping of view names to viewParams:
+//
+//	payments:
+//	  tag: payments
+//	  sort: status
+//	infra:
+//	  sort: ssl
+//	  group_by: domain
+//
+// Values are given as in the query string, e.g. status: error,5xx. Unknown
+// parameters and invalid values are refused, so a typo stops the dashboard
+// at startup instead of answering 400 on every use of the view.
+func loadViews(path string) (views, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("VIEWS_FILE: %w", err)
+	}
+	var parsed map[string]map[string]string
+	if err := yaml.Unmarshal(content, &parsed); err != nil {
+		return nil, fmt.Errorf("VIEWS_FILE %s: %w", path, err)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("VIEWS_FILE %s defines no views", path)
+	}
+	presets := make(views, len(parsed))
+	for name, params := range parsed {
+		if !validViewName(name) {
+			return nil, fmt.Errorf("VIEWS_FILE %s: invalid view name %q (use letters, digits, '-', '_' and '.')", path, name)
+		}
+		query := make(url.Values, len(params))
+		for param, value := range params {
+			if !slices.Contains(viewParams, param) {
+				return nil, fmt.Errorf("VIEWS_FILE %s: view %s: unknown parameter %q (valid: %s)", path, name, param, strings.Join(viewParams, ", "))
+			}
+			query.Set(param, value)
+		}
+		if err := validateQuery(query); err != nil {
+			return nil, fmt.Errorf("VIEWS_FILE %s: view %s: %w", path, name, err)
+		}
+		presets[name] = query
+	}
+	return presets, nil
+}
+
+// validViewName accepts names that need no escaping in a URL: up to 64
+// letters, digits and -_.
+func validViewName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateQuery reports the first invalid filter, sort or grouping of query
+func validateQuery(query url.Values) error {
+	if _, err := filterEndpoints(query, nil, time.Now()); err != nil {
+		return err
+	}
+	if err := sortEndpoints(query, nil); err != nil {
+		return err
+	}
+	_, err := parseGroupBy(query)
+	return err
+}
+
+// names returns the view names, sorted
+func (v views) names() []string {
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// viewQuery returns the query of r with the parameters of its view= preset
+// filled in; parameters of the request itself take precedence. An unknown
+// view is answered with 404 listing the valid names.
+func (s *Server) viewQuery(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	query := r.URL.Query()
+	name := query.Get("view")
+	if name == "" {
+		return query, true
+	}
+	preset, ok := s.views[name]
+	if !ok {
+		valid := "none are configured"
+		if len(s.views) > 0 {
+			valid = "valid: " + strings.Join(s.views.names(), ", ")
+		}
+		http.Error(w, fmt.Sprintf("Unknown view %q (%s)", name, valid), http.StatusNotFound)
+		return nil, false
+	}
+	for param, values := range preset {
+		if !query.Has(param) {
+			query[param] = values
+		}
+	}
+	return query, true
+}
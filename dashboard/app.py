@@ -15,23 +15,14 @@ redis_client = redis.Redis(
     db=int(os.getenv('REDIS_DB', 0)),
     decode_responses=True
 )
+# Namespace shared with the checker, e.g. "prod:"
+KEY_PREFIX = os.getenv('KEY_PREFIX', '')
 
 
 def get_all_endpoints():
     """Get all unique endpoints from Redis"""
-    endpoints = set()
-
-    # Get all status keys
-    for key in redis_client.scan_iter("status:*"):
-        endpoint = key.replace("status:", "")
-        endpoints.add(endpoint)
-
-    # Get all ssl keys
-    for key in redis_client.scan_iter("ssl:*"):
-        endpoint = key.replace("ssl:", "")
-        endpoints.add(endpoint)
-
-    return list(endpoints)
+    # The checker keeps the monitored endpoints in a registry set
+    return list(redis_client.smembers(f"{KEY_PREFIX}endpoints_registry"))
 
 
 def get_endpoint_data(endpoint):
@@ -47,9 +38,10 @@ def get_endpoint_data(endpoint):
         'is_https': endpoint.startswith('https://')
     }
 
+    fields = redis_client.hgetall(f"{KEY_PREFIX}endpoint:{endpoint}")
+
     # Get HTTP status
-    status_key = f"status:{endpoint}"
-    status = redis_client.get(status_key)
+    status = fields.get('status')
     if status:
         try:
             data['status_code'] = int(status)
@@ -58,8 +50,7 @@ def get_endpoint_data(endpoint):
             data['status'] = status
 
     # Get status update time
-    status_updated_key = f"status_updated:{endpoint}"
-    status_updated = redis_client.get(status_updated_key)
+    status_updated = fields.get('status_updated')
     if status_updated:
         try:
             timestamp = int(status_updated)
@@ -70,8 +61,7 @@ def get_endpoint_data(endpoint):
 
     # Get SSL expiration (only for HTTPS)
     if data['is_https']:
-        ssl_key = f"ssl:{endpoint}"
-        ssl_exp = redis_client.get(ssl_key)
+        ssl_exp = fields.get('ssl_expiry')
         if ssl_exp:
             try:
                 exp_timestamp = int(ssl_exp)
@@ -87,8 +77,7 @@ def get_endpoint_data(endpoint):
                 pass
 
         # Get SSL update time
-        ssl_updated_key = f"ssl_updated:{endpoint}"
-        ssl_updated = redis_client.get(ssl_updated_key)
+        ssl_updated = fields.get('ssl_updated')
         if ssl_updated:
             try:
                 timestamp = int(ssl_updated)
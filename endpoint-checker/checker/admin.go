@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"certs-n-status/store/version"
+)
+
+// readyTimeout bounds the storage ping of /readyz, well below the usual
+// probe timeout of one second
+const readyTimeout = 500 * time.Millisecond
+
+// startAdminServer serves the admin endpoints on ADMIN_ADDR, when set:
+//
+//	GET /healthz            200 while the process runs
+//	GET /readyz             200 once the endpoints are loaded and while storage answers
+//	GET /version            build information, as JSON
+//	POST /api/notify-test   send a test event to the notifiers, see handleNotifyTest
+//
+// It fails when the address cannot be listened on.
+func (ec *EndpointChecker) startAdminServer() error {
+	if ec.config.AdminAddr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", ec.config.AdminAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on ADMIN_ADDR: %w", err)
+	}
+	log.Printf("[INFO] Admin server listening on %s", ln.Addr())
+	srv := &http.Server{Handler: ec.adminHandler()}
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ERROR] Admin server stopped: %v", err)
+		}
+	}()
+	context.AfterFunc(ec.ctx, func() { srv.Close() })
+	return nil
+}
+
+// adminHandler routes the admin endpoints. Probe requests are not logged.
+func (ec *EndpointChecker) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", ec.handleReadyz)
+	mux.HandleFunc("POST /api/notify-test", ec.handleNotifyTest)
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(version.Get())
+	})
+	return mux
+}
+
+// handleReadyz reports 200 when the endpoints file was loaded and storage
+// answers a ping within readyTimeout, and 503 naming the failure otherwise
+func (ec *EndpointChecker) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	response := map[string]string{"status": "ready", "storage": "ok", "endpoints": "ok"}
+	if !ec.endpointsLoaded.Load() {
+		status = http.StatusServiceUnavailable
+		response["endpoints"] = "not loaded from " + ec.config.EndpointsFile
+	}
+	if err := ec.store.Ping(ctx); err != nil {
+		status = http.StatusServiceUnavailable
+		response["storage"] = ec.config.Storage + ": " + err.Error()
+	}
+	if status != http.StatusOK {
+		response["status"] = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
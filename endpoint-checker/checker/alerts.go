@@ -0,0 +1,278 @@
+package checker
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// alertGate sits between state changes and the notifiers. It holds back a
+// notification of a condition that was already notified within the
+// cooldown, such as a flapping endpoint going down again, and, when the
+// condition lasts beyond the cooldown, notifies it then. With a reminder
+// interval it also reminds of endpoints that stay down, and with
+// escalation steps it escalates outages that last.
+type alertGate struct {
+	cooldown   time.Duration
+	reminder   time.Duration // 0 disables reminders
+	escalation []escalationStep
+	now        func() time.Time
+
+	mu     sync.Mutex
+	states map[string]store.AlertState // by endpoint
+}
+
+func newAlertGate(cooldown, reminder time.Duration, escalation []escalationStep) *alertGate {
+	return &alertGate{cooldown: cooldown, reminder: reminder, escalation: escalation, now: time.Now, states: make(map[string]store.AlertState)}
+}
+
+// load replaces the alert states, e.g. with those kept from a previous run
+func (g *alertGate) load(states map[string]store.AlertState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.states = states
+}
+
+// problem reports whether the state event moves to needs someone's
+// attention, as opposed to a recovery
+func problem(event store.Event) bool {
+	if event.Kind == store.EventKindStatus {
+		return event.New == store.StatusDown
+	}
+	return event.New != store.CertLevelOK
+}
+
+// filter returns the events to notify and records them as notified,
+// along with the states that changed. Only notable events are gated; the
+// others pass unchanged for the notifiers to choose from. A problem
+// notified within the cooldown is held back, and a recovery is held back
+// only when the problem it ends was. A recovery carries the escalation
+// step its outage reached.
+func (g *alertGate) filter(events []store.Event) (passed []store.Event, changed map[string]store.AlertState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	changed = make(map[string]store.AlertState)
+	for _, event := range events {
+		if !notable(event) {
+			passed = append(passed, event)
+			continue
+		}
+		state := cloneAlertState(g.states[event.Endpoint])
+		if problem(event) {
+			notifiedAt, notified := state.NotifiedAt[event.Kind+":"+event.New]
+			if notified && now.Sub(notifiedAt) < g.cooldown {
+				log.Printf("[INFO] Holding back %s %s notification of %s, notified %s ago", event.Kind, event.New, event.Endpoint, formatDuration(now.Sub(notifiedAt)))
+				if event.Kind == store.EventKindStatus {
+					state.HeldSince = event.At
+				}
+			} else {
+				recordNotified(&state, event, now)
+				passed = append(passed, event)
+			}
+		} else {
+			// Without a record the problem may have been notified
+			if last, known := state.Last[event.Kind]; known && last == event.New {
+				log.Printf("[INFO] Holding back %s %s notification of %s, its problem was held back", event.Kind, event.New, event.Endpoint)
+				if event.Kind == store.EventKindStatus {
+					state.HeldSince = time.Time{}
+				}
+			} else {
+				if event.Kind == store.EventKindStatus {
+					event.Escalation = state.Escalation
+				}
+				recordNotified(&state, event, now)
+				passed = append(passed, event)
+			}
+		}
+		g.states[event.Endpoint] = state
+		changed[event.Endpoint] = state
+	}
+	return passed, changed
+}
+
+// remind returns the notifications due for endpoints whose status results
+// are down: the down notification of an outage held back within the
+// cooldown once the cooldown has passed, the escalation step an outage
+// reaches, and reminders of outages every reminder interval. Outages are
+// timed from the first failed check stored with the result, so that a
+// restart neither repeats nor delays an escalation. The caller leaves out
+// endpoints in maintenance or acknowledged.
+func (g *alertGate) remind(results []store.Result) (due []store.Event, changed map[string]store.AlertState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	changed = make(map[string]store.AlertState)
+	for _, result := range results {
+		if !result.HasStatus || store.StatusLevel(result.StatusCode) != store.StatusDown {
+			continue
+		}
+		state := cloneAlertState(g.states[result.Endpoint])
+		event := store.Event{Endpoint: result.Endpoint, Kind: store.EventKindStatus, New: store.StatusDown, At: now}
+		downSince := state.DownSince
+		if result.Error != nil {
+			event.ErrorClass = result.Error.Class
+			if !result.Error.Since.IsZero() {
+				downSince = result.Error.Since
+			}
+		}
+		level := 0
+		if !downSince.IsZero() {
+			level = escalationLevel(g.escalation, now.Sub(downSince))
+		}
+		switch {
+		case state.Last[store.EventKindStatus] != store.StatusDown:
+			notifiedAt := state.NotifiedAt[event.Kind+":"+event.New]
+			if state.HeldSince.IsZero() || now.Sub(notifiedAt) < g.cooldown {
+				continue
+			}
+			event.Old = store.StatusUp
+			event.At = state.HeldSince
+			recordNotified(&state, event, now)
+		case level > state.Escalation:
+			event.Old = store.StatusDown
+			event.DownSince = downSince
+			event.Escalation = level
+			state.Escalation = level
+			state.RemindedAt = now
+		case g.reminder > 0:
+			last := state.NotifiedAt[event.Kind+":"+event.New]
+			if state.RemindedAt.After(last) {
+				last = state.RemindedAt
+			}
+			if now.Sub(last) < g.reminder {
+				continue
+			}
+			event.Old = store.StatusDown
+			event.DownSince = state.DownSince
+			state.RemindedAt = now
+		default:
+			continue
+		}
+		due = append(due, event)
+		g.states[result.Endpoint] = state
+		changed[result.Endpoint] = state
+	}
+	return due, changed
+}
+
+// recordNotified records event as notified at now
+func recordNotified(state *store.AlertState, event store.Event, now time.Time) {
+	if state.Last == nil {
+		state.Last = make(map[string]string)
+		state.NotifiedAt = make(map[string]time.Time)
+	}
+	state.Last[event.Kind] = event.New
+	state.NotifiedAt[event.Kind+":"+event.New] = now
+	if event.Kind == store.EventKindStatus {
+		state.HeldSince, state.RemindedAt = time.Time{}, time.Time{}
+		state.DownSince, state.Escalation = time.Time{}, 0
+		if event.New == store.StatusDown {
+			state.DownSince = event.At
+		}
+	}
+}
+
+// cloneAlertState copies state, so states handed out for saving never
+// change underneath
+func cloneAlertState(state store.AlertState) store.AlertState {
+	state.Last = maps.Clone(state.Last)
+	state.NotifiedAt = maps.Clone(state.NotifiedAt)
+	return state
+}
+
+// loadAlertStates restores the alert states of a previous run from Redis
+func (ec *EndpointChecker) loadAlertStates() {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok || ec.alerts == nil {
+		return
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	states, err := rs.AlertStates(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read alert states, starting without them: %v", err)
+		return
+	}
+	ec.alerts.load(states)
+}
+
+// saveAlertStates keeps the changed alert states in Redis, so a restart
+// neither repeats nor forgets notifications
+func (ec *EndpointChecker) saveAlertStates(states map[string]store.AlertState) {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok || len(states) == 0 {
+		return
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	if err := rs.SaveAlertStates(ctx, states); err != nil {
+		log.Printf("[WARN] Failed to save alert states of %d endpoints: %v", len(states), err)
+	}
+}
+
+// remindAlerts notifies what the alert gate has due for the endpoints of
+// a batch of status results that are down, outside maintenance and not
+// acknowledged
+func (ec *EndpointChecker) remindAlerts(results []store.Result) {
+	if ec.alerts == nil || len(ec.notifiers) == 0 {
+		return
+	}
+	down := slices.DeleteFunc(slices.Clone(results), func(result store.Result) bool {
+		return !result.HasStatus || result.InMaintenance || store.StatusLevel(result.StatusCode) != store.StatusDown
+	})
+	if len(down) == 0 {
+		return
+	}
+	if rs, ok := ec.store.(*store.RedisStore); ok {
+		ctx, cancel := ec.storeContext()
+		acks, err := rs.Acks(ctx)
+		cancel()
+		// Without the acknowledgements, remind rather than stay silent
+		if err == nil {
+			down = slices.DeleteFunc(down, func(result store.Result) bool {
+				return slices.ContainsFunc(acks, func(ack store.Ack) bool { return ack.Endpoint == result.Endpoint })
+			})
+		}
+	}
+
+	due, changed := ec.alerts.remind(down)
+	ec.saveAlertStates(changed)
+	for _, event := range due {
+		if event.Escalation > 0 {
+			log.Printf("[INFO] Escalating outage of %s to step %d, down for %s", event.Endpoint, event.Escalation, formatDuration(event.At.Sub(event.DownSince)))
+		} else if event.Old == store.StatusDown {
+			log.Printf("[INFO] Reminding that %s is still down after %s", event.Endpoint, formatDuration(event.At.Sub(event.DownSince)))
+		} else {
+			log.Printf("[INFO] Notifying held back outage of %s, still down after ALERT_COOLDOWN", event.Endpoint)
+		}
+	}
+	ec.enqueue(due)
+}
+
+// escalationLevel is the number of steps reached by an outage lasting down
+func escalationLevel(steps []escalationStep, down time.Duration) int {
+	level := 0
+	for level < len(steps) && steps[level].After <= down {
+		level++
+	}
+	return level
+}
+
+// formatDuration rounds d to minutes, or seconds under a minute, and drops
+// the zero units time.Duration.String would end with: 2h, 1h30m, 45s
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	s := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package checker
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"certs-n-status/store"
+)
+
+// runExport implements `endpoint-checker export [-o backup.json] [-history]`,
+// writing the Redis keys under KEY_PREFIX as a JSON backup document. The file
+// is written under a temporary name and renamed when complete, so a failed
+// export never replaces an earlier backup.
+func runExport(config Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "-", "file to write the backup to, - for stdout")
+	history := fs.Bool("history", false, "include the status, certificate and latency histories and the event stream")
+	fs.Parse(args)
+
+	st, err := openBackupStore(config, "export")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	ctx := context.Background()
+
+	if *output == "-" {
+		exported, err := st.Export(ctx, os.Stdout, *history)
+		if err != nil {
+			return fmt.Errorf("export failed: %w", err)
+		}
+		log.Printf("[INFO] Exported %d keys", exported)
+		return nil
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(*output), filepath.Base(*output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // fails harmlessly once renamed
+	exported, err := st.Export(ctx, file, *history)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	if err := os.Rename(file.Name(), *output); err != nil {
+		return err
+	}
+	log.Printf("[INFO] Exported %d keys to %s", exported, *output)
+	return nil
+}
+
+// runImport implements `endpoint-checker import [backup.json]`, writing the
+// keys of a backup document under KEY_PREFIX and replacing existing keys of
+// the same name. Without a file, or with -, the backup is read from stdin.
+func runImport(config Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.Parse(args)
+
+	var input io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	st, err := openBackupStore(config, "import")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	imported, err := st.Import(context.Background(), input)
+	if err != nil {
+		return fmt.Errorf("import failed after %d keys: %w", imported, err)
+	}
+	log.Printf("[INFO] Imported %d keys", imported)
+	return nil
+}
+
+// openBackupStore connects to Redis for the export and import commands
+func openBackupStore(config Config, command string) (*store.RedisStore, error) {
+	if config.Storage != "redis" {
+		return nil, fmt.Errorf("%s is only supported for Redis storage, not %s (use pg_dump)", command, config.Storage)
+	}
+	st, err := newRedisStore(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := st.Ping(context.Background()); err != nil {
+		st.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return st, nil
+}
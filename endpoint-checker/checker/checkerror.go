@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// checkError describes why a status check was not up: err when it got no
+// response, or the 4xx/5xx statusCode it got. It returns nil for checks
+// that are up.
+func checkError(statusCode int, err error, at time.Time) *store.CheckError {
+	switch {
+	case err != nil:
+		class := classifyError(err)
+		if statusCode == -1 {
+			// Keep the class in line with the stored DNS_ERROR status
+			class = store.ErrorClassDNS
+		}
+		return &store.CheckError{Class: class, Message: err.Error(), At: at}
+	case store.StatusLevel(statusCode) == store.StatusDown:
+		return &store.CheckError{Class: store.ErrorClassHTTP, Message: fmt.Sprintf("HTTP %d %s", statusCode, http.StatusText(statusCode)), At: at}
+	}
+	return nil
+}
+
+// classifyError tells why a request got no response, as one of the
+// store.ErrorClass values
+func classifyError(err error) string {
+	var (
+		dnsErr           *net.DNSError
+		verifyErr        *tls.CertificateVerificationError
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidErr       x509.CertificateInvalidError
+		recordErr        tls.RecordHeaderError
+		alertErr         tls.AlertError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return store.ErrorClassDNS
+	case errors.As(err, &verifyErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr), errors.As(err, &recordErr), errors.As(err, &alertErr):
+		return store.ErrorClassTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return store.ErrorClassTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return store.ErrorClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return store.ErrorClassConnectionReset
+	}
+	return store.ErrorClassNetwork
+}
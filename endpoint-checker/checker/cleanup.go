@@ -0,0 +1,74 @@
+package checker
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"certs-n-status/store"
+)
+
+// cleanup removes stored data for endpoints that are not in endpoints,
+// returning what was (or with dryRun, would be) removed
+func (ec *EndpointChecker) cleanup(endpoints []string, dryRun bool) ([]string, error) {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok {
+		return nil, fmt.Errorf("cleanup is only supported for Redis storage, not %s", ec.config.Storage)
+	}
+	return rs.Cleanup(ec.ctx, endpoints, dryRun)
+}
+
+// runCleanup implements `endpoint-checker cleanup [-dry-run]`, printing each
+// removed key for endpoints no longer listed in the endpoints file
+func runCleanup(config Config, args []string) error {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list orphaned data without deleting it")
+	fs.Parse(args)
+
+	if config.Storage != "redis" {
+		return fmt.Errorf("cleanup is only supported for Redis storage, not %s", config.Storage)
+	}
+	st, err := newRedisStore(config)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	checker := NewEndpointChecker(config, st)
+	if err := st.Ping(checker.ctx); err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	if err := st.CheckSchemaVersion(checker.ctx); err != nil {
+		return err
+	}
+	endpoints, err := checker.loadEndpoints()
+	if err != nil {
+		return err
+	}
+
+	removed, err := checker.cleanup(endpoints, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, item := range removed {
+		fmt.Println(item)
+	}
+	if *dryRun {
+		log.Printf("[INFO] Dry run: %d orphaned entries for endpoints not in %s", len(removed), config.EndpointsFile)
+	} else {
+		log.Printf("[INFO] Removed %d orphaned entries for endpoints not in %s", len(removed), config.EndpointsFile)
+	}
+	return nil
+}
+
+// autoCleanup is the per-cycle sweep enabled by AUTO_CLEANUP
+func (ec *EndpointChecker) autoCleanup(endpoints []string) error {
+	removed, err := ec.cleanup(endpoints, false)
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		log.Printf("[INFO] Auto cleanup removed %d orphaned entries", len(removed))
+	}
+	return nil
+}
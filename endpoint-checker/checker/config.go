@@ -0,0 +1,135 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+)
+
+// minSSLCheckInterval is the shortest SSL_CHECK_INTERVAL; certificates are
+// valid for weeks, so checking them more often only adds TLS handshakes
+const minSSLCheckInterval = time.Minute
+
+// defaultCheckerID is the CHECKER_ID of a checker without one: its host
+// name, so instances on different hosts tell their results apart
+func defaultCheckerID() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return "endpoint-checker"
+}
+
+// ConfigKeys are the keys of the --config file the checker reads; the
+// dashboard section is left to the dashboard
+var ConfigKeys = slices.Concat(store.SharedConfigKeys, []store.ConfigKey{
+	{Path: "checker.status_check_interval", Env: "STATUS_CHECK_INTERVAL"},
+	{Path: "checker.ssl_check_interval", Env: "SSL_CHECK_INTERVAL"},
+	{Path: "checker.clock_skew_window", Env: "CLOCK_SKEW_WINDOW"},
+	{Path: "checker.strict", Env: "CONFIG_STRICT"},
+	{Path: "checker.id", Env: "CHECKER_ID"},
+	{Path: "checker.endpoints.source", Env: "ENDPOINTS_SOURCE"},
+	{Path: "checker.endpoints.file", Env: "ENDPOINTS_FILE"},
+	{Path: "checker.admin_addr", Env: "ADMIN_ADDR"},
+	{Path: "checker.dashboard_url", Env: "DASHBOARD_URL"},
+	{Path: "checker.audit_headers", Env: "AUDIT_HEADERS"},
+	{Path: "checker.capture_headers", Env: "CAPTURE_HEADERS"},
+	{Path: "checker.hsts_min_max_age", Env: "HSTS_MIN_MAX_AGE"},
+	{Path: "checker.result_ttl", Env: "RESULT_TTL"},
+	{Path: "checker.auto_cleanup", Env: "AUTO_CLEANUP"},
+	{Path: "checker.history_retention", Env: "HISTORY_RETENTION"},
+	{Path: "checker.latency_buckets", Env: "LATENCY_BUCKETS"},
+	{Path: "checker.events_maxlen", Env: "EVENTS_MAXLEN"},
+	{Path: "checker.alerts.cooldown", Env: "ALERT_COOLDOWN"},
+	{Path: "checker.alerts.reminder", Env: "ALERT_REMINDER"},
+	{Path: "checker.alerts.escalation", Env: "ALERT_ESCALATION"},
+	{Path: "checker.alerts.routes_file", Env: "ALERT_ROUTES_FILE"},
+	{Path: "checker.alerts.history_maxlen", Env: "ALERT_HISTORY_MAXLEN"},
+	{Path: "checker.alerts.history_max_age", Env: "ALERT_HISTORY_MAX_AGE"},
+	{Path: "checker.digest.notify", Env: "DIGEST_NOTIFY"},
+	{Path: "checker.digest.schedule", Env: "DIGEST_SCHEDULE"},
+	{Path: "checker.digest.timezone", Env: "DIGEST_TIMEZONE"},
+	{Path: "checker.slo.targets", Env: "SLO_TARGETS"},
+	{Path: "checker.slo.window", Env: "SLO_WINDOW"},
+	{Path: "checker.slo.burn_window", Env: "SLO_BURN_WINDOW"},
+	{Path: "checker.slo.burn_rate_threshold", Env: "SLO_BURN_RATE_THRESHOLD"},
+	{Path: "checker.slo.exclude_maintenance", Env: "SLO_EXCLUDE_MAINTENANCE"},
+	{Path: "checker.slo.exclude_unknown", Env: "SLO_EXCLUDE_UNKNOWN"},
+	{Path: "checker.slack.webhook_url", Env: "SLACK_WEBHOOK_URL", Secret: true},
+	{Path: "checker.webhook.url", Env: "WEBHOOK_URL"},
+	{Path: "checker.webhook.template", Env: "WEBHOOK_TEMPLATE"},
+	{Path: "checker.webhook.headers", Env: "WEBHOOK_HEADERS", Secret: true},
+	{Path: "checker.webhook.secret", Env: "WEBHOOK_SECRET", Secret: true},
+	{Path: "checker.email.smtp_host", Env: "SMTP_HOST"},
+	{Path: "checker.email.smtp_port", Env: "SMTP_PORT"},
+	{Path: "checker.email.smtp_tls", Env: "SMTP_TLS"},
+	{Path: "checker.email.smtp_username", Env: "SMTP_USERNAME"},
+	{Path: "checker.email.smtp_password", Env: "SMTP_PASSWORD", Secret: true},
+	{Path: "checker.email.from", Env: "SMTP_FROM"},
+	{Path: "checker.email.to", Env: "EMAIL_TO"},
+	{Path: "checker.email.tag_to", Env: "EMAIL_TAG_TO"},
+	{Path: "checker.email.digest_threshold", Env: "EMAIL_DIGEST_THRESHOLD"},
+	{Path: "checker.telegram.bot_token", Env: "TELEGRAM_BOT_TOKEN", Secret: true},
+	{Path: "checker.telegram.chat_id", Env: "TELEGRAM_CHAT_ID"},
+	{Path: "checker.telegram.tag_chat_ids", Env: "TELEGRAM_TAG_CHAT_IDS"},
+	{Path: "checker.opsgenie.api_key", Env: "OPSGENIE_API_KEY", Secret: true},
+	{Path: "checker.opsgenie.api_url", Env: "OPSGENIE_API_URL"},
+	{Path: "checker.opsgenie.priority", Env: "OPSGENIE_PRIORITY"},
+	{Path: "checker.opsgenie.tag_priorities", Env: "OPSGENIE_TAG_PRIORITIES"},
+	{Path: "checker.opsgenie.teams", Env: "OPSGENIE_TEAMS"},
+	{Path: "checker.opsgenie.tag_teams", Env: "OPSGENIE_TAG_TEAMS"},
+}, logging.ConfigKeys("checker"))
+
+// validate returns every setting of config that parsed but cannot work.
+// Invalid values of single variables are reported by LoadConfig already.
+func (config Config) validate() []error {
+	var problems []error
+	if config.StatusCheckInterval <= 0 {
+		problems = append(problems, fmt.Errorf("invalid STATUS_CHECK_INTERVAL %s (use a positive duration such as 1m)", config.StatusCheckInterval))
+	}
+	if config.SSLCheckInterval < minSSLCheckInterval {
+		problems = append(problems, fmt.Errorf("invalid SSL_CHECK_INTERVAL %s (use at least %s, such as 1h)", config.SSLCheckInterval, minSSLCheckInterval))
+	}
+	if strings.ContainsFunc(config.CheckerID, unicode.IsSpace) {
+		problems = append(problems, fmt.Errorf("invalid CHECKER_ID %q (use a name without spaces, such as eu-west-1)", config.CheckerID))
+	}
+	switch config.Storage {
+	case "redis", "memory":
+	case "postgres":
+		if config.DatabaseURL == "" {
+			problems = append(problems, errors.New("STORAGE=postgres requires DATABASE_URL"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown STORAGE %q (use redis, postgres or memory)", config.Storage))
+	}
+	switch config.EndpointsSource {
+	case endpointsSourceFile:
+	case endpointsSourceRedis:
+		if config.Storage != "redis" {
+			problems = append(problems, fmt.Errorf("ENDPOINTS_SOURCE=redis requires Redis storage, not %s", config.Storage))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown ENDPOINTS_SOURCE %q (use file or redis)", config.EndpointsSource))
+	}
+	if len(config.SLO.Targets) > 0 && config.Storage != "redis" {
+		problems = append(problems, fmt.Errorf("SLO_TARGETS requires Redis storage, not %s", config.Storage))
+	}
+	return problems
+}
+
+// checkEndpointList reports an ENDPOINTS_FILE without endpoints, which
+// the checker refuses to start with under CONFIG_STRICT and only warns of
+// otherwise. An empty registry is not reported, as endpoints are added to
+// it from the dashboard while the checker runs.
+func (config Config) checkEndpointList(endpoints []string) error {
+	if len(endpoints) > 0 || config.EndpointsSource != endpointsSourceFile {
+		return nil
+	}
+	return fmt.Errorf("no endpoints to check in %s (list one URL per line)", config.EndpointsFile)
+}
@@ -0,0 +1,319 @@
+package checker
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+
+	"github.com/robfig/cron/v3"
+)
+
+// digestListLimit caps each list of the daily digest, the rest is counted
+const digestListLimit = 10
+
+// leaderTerm is how long a checker instance leads its replicas without
+// renewing the lead, which it does every third of it
+const leaderTerm = 30 * time.Second
+
+// digestConfig configures the daily digest, which is enabled when Notify
+// names any notifier
+type digestConfig struct {
+	Notify   []string // notifiers sending the digest, as named in ALERT_ROUTES_FILE
+	Schedule cron.Schedule
+	Location *time.Location // of Schedule
+}
+
+// loadDigestConfig reads the DIGEST_ variables; configured are the names
+// of the enabled notifiers
+func loadDigestConfig(configured []string) (digestConfig, error) {
+	value := os.Getenv("DIGEST_NOTIFY")
+	if value == "" {
+		return digestConfig{}, nil
+	}
+	var config digestConfig
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "opsgenie":
+			return digestConfig{}, errors.New("invalid DIGEST_NOTIFY: Opsgenie does not send digests")
+		case !slices.Contains(notifierNames, name):
+			return digestConfig{}, fmt.Errorf("invalid DIGEST_NOTIFY: unknown notifier %q (use slack, webhook, email or telegram)", name)
+		case !slices.Contains(configured, name):
+			return digestConfig{}, fmt.Errorf("invalid DIGEST_NOTIFY: notifier %s is not configured", name)
+		}
+		config.Notify = append(config.Notify, name)
+	}
+	spec := cmp.Or(os.Getenv("DIGEST_SCHEDULE"), "08:00")
+	var err error
+	if config.Schedule, err = parseDigestSchedule(spec); err != nil {
+		return digestConfig{}, fmt.Errorf("invalid DIGEST_SCHEDULE %q: %w", spec, err)
+	}
+	zone := os.Getenv("DIGEST_TIMEZONE")
+	if config.Location, err = time.LoadLocation(zone); err != nil {
+		return digestConfig{}, fmt.Errorf("invalid DIGEST_TIMEZONE %q (use an IANA zone such as Europe/Berlin)", zone)
+	}
+	return config, nil
+}
+
+// parseDigestSchedule parses a time of day such as 08:00, or a cron
+// schedule as parseCronSchedule does
+func parseDigestSchedule(spec string) (cron.Schedule, error) {
+	if at, err := time.Parse("15:04", spec); err == nil {
+		spec = fmt.Sprintf("%d %d * * *", at.Minute(), at.Hour())
+	}
+	if len(strings.Fields(spec)) != 5 {
+		return nil, errors.New("use a time such as 08:00 or five cron fields such as \"0 8 * * 1-5\"")
+	}
+	return parseCronSchedule(spec)
+}
+
+// dailyDigest summarizes the state of every endpoint
+type dailyDigest struct {
+	At            time.Time      `json:"at"`
+	Endpoints     int            `json:"endpoints"`
+	Up            int            `json:"up"`
+	Down          []string       `json:"down"`
+	Checks        int            `json:"checks"` // status checks of the last 24 hours
+	UpChecks      int            `json:"up_checks"`
+	LowestUptime  []digestUptime `json:"lowest_uptime"` // endpoints below 100% over the last 24 hours, lowest first
+	Expiring      []digestCert   `json:"expiring"`      // certificates expiring within store.CertWarningWindow or expired, soonest first
+	Acknowledged  []store.Ack    `json:"acknowledged"`
+	InMaintenance []string       `json:"in_maintenance"`
+}
+
+type digestUptime struct {
+	Endpoint string  `json:"endpoint"`
+	Percent  float64 `json:"percent"`
+}
+
+type digestCert struct {
+	Endpoint string    `json:"endpoint"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// newDailyDigest summarizes the stored data of every endpoint and the
+// acknowledgements at now
+func newDailyDigest(data []store.EndpointData, acks []store.Ack, now time.Time) dailyDigest {
+	digest := dailyDigest{At: now, Down: []string{}, LowestUptime: []digestUptime{}, Expiring: []digestCert{}, Acknowledged: acks, InMaintenance: []string{}}
+	if digest.Acknowledged == nil {
+		digest.Acknowledged = []store.Ack{}
+	}
+	for _, d := range data {
+		if d.HasStatus {
+			digest.Endpoints++
+			if store.StatusLevel(d.StatusCode) == store.StatusUp {
+				digest.Up++
+			} else {
+				digest.Down = append(digest.Down, d.Endpoint)
+			}
+		}
+		if !d.SSLExpiration.IsZero() && d.SSLExpiration.Sub(now) < store.CertWarningWindow {
+			digest.Expiring = append(digest.Expiring, digestCert{Endpoint: d.Endpoint, NotAfter: d.SSLExpiration})
+		}
+		if d.InMaintenance {
+			digest.InMaintenance = append(digest.InMaintenance, d.Endpoint)
+		}
+		for _, u := range d.Uptime {
+			if u.Window != store.UptimeWindows[0].Name {
+				continue
+			}
+			digest.Checks += u.Checks
+			digest.UpChecks += u.Up
+			if percent, ok := u.Percent(); ok && percent < 100 {
+				digest.LowestUptime = append(digest.LowestUptime, digestUptime{Endpoint: d.Endpoint, Percent: percent})
+			}
+		}
+	}
+	slices.Sort(digest.Down)
+	slices.Sort(digest.InMaintenance)
+	slices.SortFunc(digest.LowestUptime, func(a, b digestUptime) int {
+		return cmp.Or(cmp.Compare(a.Percent, b.Percent), strings.Compare(a.Endpoint, b.Endpoint))
+	})
+	slices.SortFunc(digest.Expiring, func(a, b digestCert) int {
+		return cmp.Or(a.NotAfter.Compare(b.NotAfter), strings.Compare(a.Endpoint, b.Endpoint))
+	})
+	slices.SortFunc(digest.Acknowledged, func(a, b store.Ack) int { return strings.Compare(a.Endpoint, b.Endpoint) })
+	return digest
+}
+
+// title is the headline of the digest
+func (d dailyDigest) title() string {
+	return fmt.Sprintf("Daily digest: %d of %d endpoints up", d.Up, d.Endpoints)
+}
+
+// text writes the digest as plain text, one line per item
+func (d dailyDigest) text() string {
+	var lines []string
+	list := func(heading string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		lines = append(lines, "", fmt.Sprintf("%s (%d):", heading, len(items)))
+		for i, item := range items {
+			if i == digestListLimit {
+				lines = append(lines, fmt.Sprintf("- and %d more", len(items)-i))
+				break
+			}
+			lines = append(lines, "- "+item)
+		}
+	}
+
+	lines = append(lines, fmt.Sprintf("%d of %d endpoints up, %d down", d.Up, d.Endpoints, len(d.Down)))
+	if d.Checks > 0 {
+		lines = append(lines, fmt.Sprintf("Uptime over the last 24 hours: %.2f%% of %d checks", float64(d.UpChecks)*100/float64(d.Checks), d.Checks))
+	}
+	list("Down", d.Down)
+	var items []string
+	for _, u := range d.LowestUptime {
+		items = append(items, fmt.Sprintf("%s %.2f%%", u.Endpoint, u.Percent))
+	}
+	list("Below 100% uptime over the last 24 hours", items)
+	items = nil
+	for _, cert := range d.Expiring {
+		days, left := store.DaysLeft(cert.NotAfter, d.At)
+		if left <= 0 {
+			items = append(items, fmt.Sprintf("%s expired on %s", cert.Endpoint, cert.NotAfter.In(d.At.Location()).Format(time.DateOnly)))
+		} else {
+			items = append(items, fmt.Sprintf("%s in %d days, on %s", cert.Endpoint, days, cert.NotAfter.In(d.At.Location()).Format(time.DateOnly)))
+		}
+	}
+	list(fmt.Sprintf("Certificates expiring within %d days", int(store.CertWarningWindow.Hours()/24)), items)
+	items = nil
+	for _, ack := range d.Acknowledged {
+		item := fmt.Sprintf("%s until %s", ack.Endpoint, ack.Until.In(d.At.Location()).Format("2006-01-02 15:04"))
+		if ack.User != "" {
+			item += " by " + ack.User
+		}
+		if ack.Reason != "" {
+			item += ": " + ack.Reason
+		}
+		items = append(items, item)
+	}
+	list("Acknowledged", items)
+	list("In maintenance", d.InMaintenance)
+	return strings.Join(lines, "\n")
+}
+
+// dailyDigestSender is a notifier that can send the daily digest
+type dailyDigestSender interface {
+	notifier
+	sendDailyDigest(ctx context.Context, digest dailyDigest) error
+}
+
+// buildDailyDigest reads what the digest summarizes from the store
+func (ec *EndpointChecker) buildDailyDigest(ctx context.Context) (dailyDigest, error) {
+	data, err := ec.store.ListEndpointData(ctx)
+	if err != nil {
+		return dailyDigest{}, fmt.Errorf("failed to read endpoints: %w", err)
+	}
+	var acks []store.Ack
+	if rs, ok := ec.store.(*store.RedisStore); ok {
+		if acks, err = rs.Acks(ctx); err != nil {
+			return dailyDigest{}, fmt.Errorf("failed to read acknowledgements: %w", err)
+		}
+	}
+	return newDailyDigest(data, acks, time.Now().In(ec.config.Digest.Location)), nil
+}
+
+// sendDailyDigest sends the digest through every DIGEST_NOTIFY notifier,
+// making up to notifyAttempts attempts with each
+func (ec *EndpointChecker) sendDailyDigest() error {
+	if len(ec.config.Digest.Notify) == 0 {
+		return errors.New("no digest notifiers configured (set DIGEST_NOTIFY)")
+	}
+	ctx, cancel := ec.storeContext()
+	digest, err := ec.buildDailyDigest(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, q := range ec.notifiers {
+		sender, ok := q.notifier.(dailyDigestSender)
+		if !ok || !slices.Contains(ec.config.Digest.Notify, strings.ToLower(sender.name())) {
+			continue
+		}
+		delay := notifyRetryDelay
+		for attempt := 1; ; attempt++ {
+			err := sender.sendDailyDigest(ec.ctx, digest)
+			if err == nil {
+				log.Printf("[INFO] Sent the daily digest to %s", sender.name())
+				break
+			}
+			if attempt == notifyAttempts {
+				errs = append(errs, fmt.Errorf("%s: %w", sender.name(), err))
+				break
+			}
+			log.Printf("[WARN] Failed to send the daily digest to %s, retrying in %s: %v", sender.name(), delay, err)
+			if sleepContext(ec.ctx, delay) != nil {
+				return ec.ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runDailyDigest sends the daily digest on its schedule, when this
+// instance leads its replicas
+func (ec *EndpointChecker) runDailyDigest() {
+	for {
+		next := ec.config.Digest.Schedule.Next(time.Now().In(ec.config.Digest.Location))
+		log.Printf("[INFO] Next daily digest at %s", next.Format("2006-01-02 15:04 MST"))
+		if sleepContext(ec.ctx, time.Until(next)) != nil {
+			return
+		}
+		if !ec.leading.Load() {
+			log.Printf("[INFO] Leaving the daily digest to the leading checker instance")
+			continue
+		}
+		if err := ec.sendDailyDigest(); err != nil {
+			log.Printf("[ERROR] Failed to send the daily digest: %v", err)
+		}
+	}
+}
+
+// runLeaderElection keeps this instance the leader of its replicas, or
+// waits to become it, holding the store.LeaderKey lock for leaderTerm.
+// Without Redis storage there is nothing to share a lock through, so
+// every instance leads.
+func (ec *EndpointChecker) runLeaderElection() {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok {
+		ec.leading.Store(true)
+		return
+	}
+	id := fmt.Sprintf("%s-%d", ec.config.CheckerID, os.Getpid())
+	ticker := time.NewTicker(leaderTerm / 3)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := ec.storeContext()
+		leading, err := rs.HoldLeadership(ctx, id, leaderTerm)
+		cancel()
+		switch {
+		case err != nil:
+			// Without the lock a term may have run out, let another lead
+			log.Printf("[WARN] Failed to renew leadership: %v", err)
+			leading = false
+		case leading && !ec.leading.Load():
+			log.Printf("[INFO] Leading the checker instances as %s", id)
+		case !leading && ec.leading.Load():
+			log.Printf("[INFO] No longer leading the checker instances")
+		}
+		ec.leading.Store(leading)
+		select {
+		case <-ec.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// SMTP_TLS modes
+const (
+	smtpTLSStartTLS = "starttls" // plain connection upgraded with STARTTLS, usually port 587
+	smtpTLSImplicit = "tls"      // TLS from the first byte, usually port 465
+	smtpTLSNone     = "none"     // unencrypted, e.g. a relay on localhost
+)
+
+// smtpTimeout bounds each connection to the SMTP server
+const smtpTimeout = 30 * time.Second
+
+// defaultEmailDigestThreshold is how many emails are sent per
+// notifyDigestWindow before further events are combined into a digest
+const defaultEmailDigestThreshold = 5
+
+// emailConfig configures the email notifier, which is enabled when Host is set
+type emailConfig struct {
+	Host            string
+	Port            int
+	Username        string // authenticates with PLAIN when set
+	Password        string
+	TLS             string // one of the SMTP_TLS modes
+	From            string
+	To              []string            // recipients of endpoints without a tag in TagTo
+	TagTo           map[string][]string // recipients by endpoints file tag, replacing To
+	DigestThreshold int
+}
+
+// loadEmailConfig reads the SMTP_ and EMAIL_ variables
+func loadEmailConfig() (emailConfig, error) {
+	config := emailConfig{
+		Host:            os.Getenv("SMTP_HOST"),
+		Username:        os.Getenv("SMTP_USERNAME"),
+		Password:        os.Getenv("SMTP_PASSWORD"),
+		TLS:             smtpTLSStartTLS,
+		DigestThreshold: defaultEmailDigestThreshold,
+	}
+	if config.Host == "" {
+		return emailConfig{}, nil
+	}
+
+	if value := os.Getenv("SMTP_TLS"); value != "" {
+		config.TLS = strings.ToLower(value)
+	}
+	switch config.TLS {
+	case smtpTLSStartTLS:
+		config.Port = 587
+	case smtpTLSImplicit:
+		config.Port = 465
+	case smtpTLSNone:
+		config.Port = 25
+	default:
+		return emailConfig{}, fmt.Errorf("invalid SMTP_TLS %q (use starttls, tls or none)", config.TLS)
+	}
+	if value := os.Getenv("SMTP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return emailConfig{}, fmt.Errorf("invalid SMTP_PORT %q (use a port number)", value)
+		}
+		config.Port = port
+	}
+
+	from, err := mail.ParseAddress(os.Getenv("SMTP_FROM"))
+	if err != nil {
+		return emailConfig{}, fmt.Errorf("invalid SMTP_FROM %q (use an address such as checker@example.com): %w", os.Getenv("SMTP_FROM"), err)
+	}
+	config.From = from.String()
+	if value := os.Getenv("EMAIL_TO"); value != "" {
+		if config.To, err = parseEmailAddresses(value); err != nil {
+			return emailConfig{}, fmt.Errorf("invalid EMAIL_TO: %w", err)
+		}
+	}
+	if value := os.Getenv("EMAIL_TAG_TO"); value != "" {
+		if config.TagTo, err = parseTagRoutes("EMAIL_TAG_TO", value, "tag=address,address", parseEmailAddresses); err != nil {
+			return emailConfig{}, err
+		}
+	}
+	if len(config.To) == 0 && len(config.TagTo) == 0 {
+		return emailConfig{}, errors.New("SMTP_HOST needs recipients in EMAIL_TO or EMAIL_TAG_TO")
+	}
+	if value := os.Getenv("EMAIL_DIGEST_THRESHOLD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return emailConfig{}, fmt.Errorf("invalid EMAIL_DIGEST_THRESHOLD %q (use a number of at least 1)", value)
+		}
+		config.DigestThreshold = n
+	}
+	return config, nil
+}
+
+// parseEmailAddresses parses a comma-separated list of addresses
+func parseEmailAddresses(value string) ([]string, error) {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(list))
+	for _, address := range list {
+		addresses = append(addresses, address.Address)
+	}
+	return addresses, nil
+}
+
+// emailNotifier mails state changes as plain text and HTML
+type emailNotifier struct {
+	config       emailConfig
+	dashboardURL string                         // links each message to the endpoint when set
+	tags         func(endpoint string) []string // tags of the endpoints file, choosing TagTo recipients
+}
+
+func newEmailNotifier(config emailConfig, dashboardURL string, tags func(string) []string) *emailNotifier {
+	return &emailNotifier{config: config, dashboardURL: strings.TrimSuffix(dashboardURL, "/"), tags: tags}
+}
+
+func (n *emailNotifier) name() string {
+	return "email"
+}
+
+func (n *emailNotifier) digestThreshold() int {
+	return n.config.DigestThreshold
+}
+
+// recipients returns the TagTo recipients of the endpoint's tags or, when
+// none of them has any, the To recipients
+func (n *emailNotifier) recipients(endpoint string) []string {
+	return routeByTag(n.tags(endpoint), n.config.TagTo, n.config.To)
+}
+
+// send mails one event to its endpoint's recipients
+func (n *emailNotifier) send(ctx context.Context, event store.Event) error {
+	to := n.recipients(event.Endpoint)
+	if len(to) == 0 {
+		return nil
+	}
+	entry := n.newEmailEntry(event)
+	text, html, err := renderEmail(emailTemplateData{Entries: []emailEntry{entry}})
+	if err != nil {
+		return err
+	}
+	return n.mail(ctx, to, "[certs-n-status] "+entry.Summary, text, html)
+}
+
+// sendDigest mails events as one digest per set of recipients
+func (n *emailNotifier) sendDigest(ctx context.Context, events []store.Event) error {
+	type digest struct {
+		to      []string
+		entries []emailEntry
+	}
+	var digests []*digest
+	for _, event := range events {
+		to := n.recipients(event.Endpoint)
+		if len(to) == 0 {
+			continue
+		}
+		i := slices.IndexFunc(digests, func(d *digest) bool { return slices.Equal(d.to, to) })
+		if i < 0 {
+			digests = append(digests, &digest{to: to})
+			i = len(digests) - 1
+		}
+		digests[i].entries = append(digests[i].entries, n.newEmailEntry(event))
+	}
+
+	var errs []error
+	for _, d := range digests {
+		text, html, err := renderEmail(emailTemplateData{Digest: true, Entries: d.entries})
+		if err == nil {
+			err = n.mail(ctx, d.to, fmt.Sprintf("[certs-n-status] %d state changes", len(d.entries)), text, html)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// sendTest mails every configured recipient, to check the SMTP settings
+func (n *emailNotifier) sendTest(ctx context.Context) error {
+	text, html, err := renderEmail(emailTemplateData{Test: true})
+	if err != nil {
+		return err
+	}
+	return n.mail(ctx, allRecipients(n.config.To, n.config.TagTo), "[certs-n-status] Test email", text, html)
+}
+
+// sendDailyDigest mails the digest to every configured recipient
+func (n *emailNotifier) sendDailyDigest(ctx context.Context, digest dailyDigest) error {
+	text := digest.text()
+	html := "<html><body><h3>" + htmltemplate.HTMLEscapeString(digest.title()) + "</h3><pre>" + htmltemplate.HTMLEscapeString(text) + "</pre></body></html>"
+	return n.mail(ctx, allRecipients(n.config.To, n.config.TagTo), "[certs-n-status] "+digest.title(), text, html)
+}
+
+// emailEntry is one event as shown in an email
+type emailEntry struct {
+	Summary    string
+	Endpoint   string
+	Change     string
+	ErrorClass string
+	CheckedBy  string
+	At         string
+	Expires    string
+	Link       string
+}
+
+func (n *emailNotifier) newEmailEntry(event store.Event) emailEntry {
+	entry := emailEntry{
+		Summary:    describeEvent(event, event.Endpoint),
+		Endpoint:   event.Endpoint,
+		Change:     fmt.Sprintf("%s %s → %s", event.Kind, event.Old, event.New),
+		ErrorClass: event.ErrorClass,
+		CheckedBy:  event.CheckedBy,
+		At:         event.At.UTC().Format("2006-01-02 15:04:05 UTC"),
+	}
+	if event.Kind == store.EventKindCertChanged {
+		entry.Change = strings.Join(certChangeDetails(event.CertChange), ", ")
+	}
+	if !event.NotAfter.IsZero() {
+		entry.Expires = event.NotAfter.UTC().Format("2006-01-02 15:04:05 UTC")
+	}
+	if n.dashboardURL != "" {
+		entry.Link = dashboardLink(n.dashboardURL, event.Endpoint)
+	}
+	return entry
+}
+
+type emailTemplateData struct {
+	Digest  bool // several events, listed in a table
+	Test    bool // a test email without events
+	Entries []emailEntry
+}
+
+// emailText and emailHTML render the two alternatives of every email
+var (
+	emailText = texttemplate.Must(texttemplate.New("text").Parse(`
+{{- if .Test -}}
+This is a test email from certs-n-status. State changes will be sent to this address.
+{{- else if .Digest -}}
+{{len .Entries}} state changes within a minute:
+{{range .Entries}}
+{{.At}}  {{.Summary}}{{if .ErrorClass}} ({{.ErrorClass}}){{end}}
+{{- if .Link}}
+    {{.Link}}
+{{- end}}
+{{- end}}
+{{- else}}{{with index .Entries 0 -}}
+{{.Summary}}
+
+Endpoint: {{.Endpoint}}
+Change:   {{.Change}}
+{{- if .ErrorClass}}
+Error:    {{.ErrorClass}}
+{{- end}}
+{{- if .Expires}}
+Expires:  {{.Expires}}
+{{- end}}
+{{- if .CheckedBy}}
+Checker:  {{.CheckedBy}}
+{{- end}}
+Time:     {{.At}}
+{{- if .Link}}
+
+Dashboard: {{.Link}}
+{{- end}}
+{{- end}}{{end}}
+`))
+	emailHTML = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+{{- if .Test}}
+<p>This is a test email from certs-n-status. State changes will be sent to this address.</p>
+{{- else if .Digest}}
+<p>{{len .Entries}} state changes within a minute:</p>
+<table cellpadding="4" style="border-collapse: collapse">
+<tr><th align="left">Time</th><th align="left">Change</th><th align="left">Error</th></tr>
+{{- range .Entries}}
+<tr><td>{{.At}}</td><td>{{if .Link}}<a href="{{.Link}}">{{.Summary}}</a>{{else}}{{.Summary}}{{end}}</td><td>{{.ErrorClass}}</td></tr>
+{{- end}}
+</table>
+{{- else}}{{with index .Entries 0}}
+<h2>{{.Summary}}</h2>
+<table cellpadding="4">
+<tr><th align="left">Endpoint</th><td>{{.Endpoint}}</td></tr>
+<tr><th align="left">Change</th><td>{{.Change}}</td></tr>
+{{- if .ErrorClass}}
+<tr><th align="left">Error</th><td>{{.ErrorClass}}</td></tr>
+{{- end}}
+{{- if .Expires}}
+<tr><th align="left">Certificate expires</th><td>{{.Expires}}</td></tr>
+{{- end}}
+{{- if .CheckedBy}}
+<tr><th align="left">Checker</th><td>{{.CheckedBy}}</td></tr>
+{{- end}}
+<tr><th align="left">Time</th><td>{{.At}}</td></tr>
+</table>
+{{- if .Link}}
+<p><a href="{{.Link}}">Open in dashboard</a></p>
+{{- end}}
+{{- end}}{{end}}
+</body></html>
+`))
+)
+
+// renderEmail renders the plain text and HTML bodies of an email
+func renderEmail(data emailTemplateData) (text, html string, err error) {
+	var textBody, htmlBody bytes.Buffer
+	if err := emailText.Execute(&textBody, data); err != nil {
+		return "", "", err
+	}
+	if err := emailHTML.Execute(&htmlBody, data); err != nil {
+		return "", "", err
+	}
+	return textBody.String(), htmlBody.String(), nil
+}
+
+// buildEmail assembles a multipart/alternative message with the plain text
+// body first, so clients without HTML show that
+func buildEmail(from string, to []string, subject, text, html string, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, alternative := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(alternative.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// mail sends one email through the configured SMTP server
+func (n *emailNotifier) mail(ctx context.Context, to []string, subject, text, html string) error {
+	message, err := buildEmail(n.config.From, to, subject, text, html, time.Now())
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(n.config.From)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(n.config.Host, strconv.Itoa(n.config.Port))
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if n.config.TLS == smtpTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: n.config.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if n.config.TLS == smtpTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS (set SMTP_TLS=tls or none)", addr)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: n.config.Host}); err != nil {
+			return err
+		}
+	}
+	if n.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := client.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// sendTestEmail mails the test message of the email notifier, for
+// run -test-email
+func (ec *EndpointChecker) sendTestEmail() error {
+	for _, q := range ec.notifiers {
+		if n, ok := q.notifier.(*emailNotifier); ok {
+			return n.sendTest(ec.ctx)
+		}
+	}
+	return errors.New("no email notifier configured (set SMTP_HOST)")
+}
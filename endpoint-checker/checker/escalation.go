@@ -0,0 +1,95 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// escalationStep is notified once an endpoint has been down for After
+type escalationStep struct {
+	After    time.Duration
+	Severity string   // routes the escalation and the recovery that ends it
+	Notify   []string // names from notifierNames that get the escalation instead of the route's; nil leaves it to the route
+}
+
+// parseEscalation parses ALERT_ESCALATION, comma-separated steps of
+// after:severity, each optionally followed by :notifier+notifier, such
+// as "15m:warning,1h:critical:opsgenie+slack". The steps must come later
+// and be no less severe one after the other, and the notifiers they name
+// must be configured.
+func parseEscalation(value string, configured []string) ([]escalationStep, error) {
+	var steps []escalationStep
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q (use after:severity or after:severity:notifier+notifier)", entry)
+		}
+		after, err := time.ParseDuration(fields[0])
+		if err != nil || after <= 0 {
+			return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: %q is not a positive duration", entry, fields[0])
+		}
+		step := escalationStep{After: after, Severity: strings.ToLower(fields[1])}
+		if !slices.Contains(severities, step.Severity) {
+			return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: unknown severity %q (use %s)", entry, fields[1], strings.Join(severities, ", "))
+		}
+		if len(fields) == 3 {
+			for _, name := range strings.Split(fields[2], "+") {
+				name = strings.ToLower(strings.TrimSpace(name))
+				switch {
+				case !slices.Contains(notifierNames, name):
+					return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: unknown notifier %q (use %s)", entry, name, strings.Join(notifierNames, ", "))
+				case !slices.Contains(configured, name):
+					return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: notifier %s is not configured", entry, name)
+				}
+				step.Notify = append(step.Notify, name)
+			}
+		}
+		if len(steps) > 0 {
+			previous := steps[len(steps)-1]
+			if step.After <= previous.After {
+				return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: must come after %s", entry, previous.After)
+			}
+			if slices.Index(severities, step.Severity) < slices.Index(severities, previous.Severity) {
+				return nil, fmt.Errorf("invalid ALERT_ESCALATION step %q: less severe than %s", entry, previous.Severity)
+			}
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("ALERT_ESCALATION defines no steps")
+	}
+	return steps, nil
+}
+
+// escalationTargets returns the severity of event, which is its step's
+// for an escalation or the recovery of an escalated outage, and the
+// notifiers it goes to besides the route's, or instead of them when only
+// is set. An escalation goes only to the notifiers its step names, when it
+// names any, and a recovery to those of every step reached as well, so
+// whoever was paged learns that it is over.
+func escalationTargets(steps []escalationStep, event store.Event) (severity string, notify []string, only bool) {
+	level := min(event.Escalation, len(steps))
+	if event.Kind != store.EventKindStatus || level < 1 {
+		return eventSeverity(event), nil, false
+	}
+	if event.New == store.StatusDown {
+		return steps[level-1].Severity, steps[level-1].Notify, steps[level-1].Notify != nil
+	}
+	for _, step := range steps[:level] {
+		for _, name := range step.Notify {
+			if !slices.Contains(notify, name) {
+				notify = append(notify, name)
+			}
+		}
+	}
+	return steps[level-1].Severity, notify, false
+}
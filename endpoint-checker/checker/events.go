@@ -0,0 +1,129 @@
+package checker
+
+import (
+	"context"
+	"log"
+	"net"
+	"slices"
+
+	"certs-n-status/store"
+)
+
+// detectTransitions compares a batch of results with the stored data they
+// are about to replace, read in one round trip, dates the errors of
+// failed status checks back to their outage, moves certificates to
+// their next alert level and drops captured headers that did not change.
+// Certificate changes carry the expect_issuer of their endpoint. If that
+// read fails the batch reports no transitions.
+func (ec *EndpointChecker) detectTransitions(results []store.Result) []store.Event {
+	endpoints := make([]string, 0, len(results))
+	for _, result := range results {
+		endpoints = append(endpoints, result.Endpoint)
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	previous, err := ec.store.GetEndpointsData(ctx, endpoints)
+	if err != nil {
+		log.Printf("[WARN] Failed to read previous results of %d endpoints, not checking for transitions: %v", len(endpoints), err)
+		return nil
+	}
+
+	var events []store.Event
+	for i := range results {
+		continueOutage(previous[i], &results[i])
+		logAddressChange(previous[i], results[i])
+		dropUnchangedCapture(previous[i], &results[i])
+		if cert := results[i].Cert; cert != nil {
+			results[i].CertLevel = store.NextCertLevel(previous[i].CurrentCertLevel(), cert.NotAfter, results[i].CheckedAt)
+		}
+		for _, event := range store.Transitions(previous[i], results[i]) {
+			if event.Kind == store.EventKindCertChanged {
+				event.CertChange.ExpectedIssuer = ec.endpointExpectedIssuer(event.Endpoint)
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// continueOutage sets the Since and Count of a failed status check's error:
+// the outage starts with the check unless the stored check failed too, in
+// which case it continues the stored outage. An outage stored without a
+// start, by an older version, keeps an unknown one.
+func continueOutage(previous store.EndpointData, result *store.Result) {
+	if !result.HasStatus || result.Error == nil {
+		return
+	}
+	checkError := *result.Error
+	switch {
+	case previous.Error == nil:
+		checkError.Since, checkError.Count = result.CheckedAt, 1
+	case !previous.Error.Since.IsZero():
+		checkError.Since, checkError.Count = previous.Error.Since, previous.Error.Count+1
+	}
+	result.Error = &checkError
+}
+
+// logAddressChange logs when a status check was served by another IP than
+// the stored check, e.g. another server behind round-robin DNS. Checks
+// without a connection are not compared.
+func logAddressChange(previous store.EndpointData, result store.Result) {
+	if !result.HasStatus || previous.RemoteAddr == "" || result.RemoteAddr == "" {
+		return
+	}
+	oldIP, _, _ := net.SplitHostPort(previous.RemoteAddr)
+	newIP, _, _ := net.SplitHostPort(result.RemoteAddr)
+	if oldIP != newIP {
+		log.Printf("[INFO] %s is now served by %s (was %s)", result.Endpoint, newIP, oldIP)
+	}
+}
+
+// publishEvents logs state changes, queues the notable ones with the
+// notifiers and, with Redis storage, publishes them on store.EventsChannel
+// and appends them to the store.EventStreamKey stream, marking those of
+// acknowledged endpoints and stamping them with the CHECKER_ID
+func (ec *EndpointChecker) publishEvents(events []store.Event) {
+	if len(events) == 0 {
+		return
+	}
+	for i := range events {
+		events[i].CheckedBy = ec.config.CheckerID
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	rs, isRedis := ec.store.(*store.RedisStore)
+	if isRedis {
+		markAcknowledged(ctx, rs, events)
+	}
+	for _, event := range events {
+		note := ""
+		switch {
+		case event.InMaintenance:
+			note = " (in maintenance)"
+		case event.Acknowledged:
+			note = " (acknowledged)"
+		}
+		log.Printf("[INFO] State change: %s %s %s -> %s%s", event.Endpoint, event.Kind, event.Old, event.New, note)
+	}
+	ec.notify(events)
+
+	if isRedis {
+		if err := rs.PublishEvents(ctx, events); err != nil {
+			log.Printf("[ERROR] Failed to publish %d state change events: %v", len(events), err)
+		}
+	}
+}
+
+// markAcknowledged marks the events of endpoints with an acknowledgement.
+// When the acknowledgements cannot be read the events stay unmarked, so an
+// alert too many is sent rather than one too few.
+func markAcknowledged(ctx context.Context, rs *store.RedisStore, events []store.Event) {
+	acks, err := rs.Acks(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read acknowledgements, publishing %d events unmarked: %v", len(events), err)
+		return
+	}
+	for i := range events {
+		events[i].Acknowledged = slices.ContainsFunc(acks, func(ack store.Ack) bool { return ack.Endpoint == events[i].Endpoint })
+	}
+}
@@ -0,0 +1,138 @@
+package checker
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"certs-n-status/store"
+)
+
+// auditHeaders captures the configured response headers and evaluates the
+// policies for them: every listed header must be present, and
+// Strict-Transport-Security must carry a max-age of at least the configured
+// minimum on HTTPS endpoints.
+func auditHeaders(url string, header http.Header, names []string, hstsMinMaxAge time.Duration) store.HeaderAudit {
+	audit := store.HeaderAudit{Headers: make(map[string]string, len(names))}
+	isHTTPS := strings.HasPrefix(url, "https://")
+
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		value := header.Get(name)
+		if value != "" {
+			audit.Headers[name] = value
+		}
+
+		if name == "Strict-Transport-Security" {
+			if !isHTTPS {
+				// Browsers ignore HSTS received over plain HTTP
+				continue
+			}
+			if value == "" {
+				audit.Failures = append(audit.Failures, "Strict-Transport-Security missing")
+				continue
+			}
+			maxAge, ok := hstsMaxAge(value)
+			if !ok {
+				audit.Failures = append(audit.Failures, "Strict-Transport-Security has no valid max-age")
+			} else if maxAge < hstsMinMaxAge {
+				audit.Failures = append(audit.Failures, fmt.Sprintf(
+					"Strict-Transport-Security max-age %d below %d",
+					int64(maxAge.Seconds()), int64(hstsMinMaxAge.Seconds())))
+			}
+			continue
+		}
+
+		if value == "" {
+			audit.Failures = append(audit.Failures, name+" missing")
+		}
+	}
+
+	return audit
+}
+
+// hstsMaxAge extracts the max-age directive from a Strict-Transport-Security value
+func hstsMaxAge(value string) (time.Duration, bool) {
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(arg), `"`), 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// capturedHeaderMaxLength caps each captured header value, in bytes, so a
+// long header does not bloat the stored endpoint
+const capturedHeaderMaxLength = 256
+
+// sensitiveHeaderWords are parts of header names that can carry
+// credentials or session state; CAPTURE_HEADERS refuses such headers
+var sensitiveHeaderWords = []string{"cookie", "authorization", "token", "secret", "password", "api-key", "apikey", "session", "credential"}
+
+// parseCaptureHeaders reads the comma-separated header names of
+// CAPTURE_HEADERS, canonicalized and without duplicates
+func parseCaptureHeaders(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		lower := strings.ToLower(name)
+		for _, word := range sensitiveHeaderWords {
+			if strings.Contains(lower, word) {
+				return nil, fmt.Errorf("invalid CAPTURE_HEADERS: %s can carry credentials and is never captured", name)
+			}
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// captureHeaders returns the values of the named response headers, several
+// values of one header joined by commas and each capped at
+// capturedHeaderMaxLength. Changed is set to at.
+func captureHeaders(header http.Header, names []string, at time.Time) store.CapturedHeaders {
+	captured := store.CapturedHeaders{Headers: make(map[string]string, len(names)), Changed: at}
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		captured.Headers[name] = truncateHeaderValue(strings.Join(values, ", "))
+	}
+	return captured
+}
+
+// truncateHeaderValue cuts value to capturedHeaderMaxLength bytes without
+// splitting a character, marking the cut with an ellipsis
+func truncateHeaderValue(value string) string {
+	if len(value) <= capturedHeaderMaxLength {
+		return value
+	}
+	cut := capturedHeaderMaxLength - len("…")
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + "…"
+}
+
+// dropUnchangedCapture leaves the stored captured headers alone when the
+// result captured the same ones, so they are only written, and Changed
+// only moves, when they change
+func dropUnchangedCapture(previous store.EndpointData, result *store.Result) {
+	if result.Captured != nil && previous.Captured != nil && maps.Equal(result.Captured.Headers, previous.Captured.Headers) {
+		result.Captured = nil
+	}
+}
@@ -0,0 +1,194 @@
+package checker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"certs-n-status/store"
+)
+
+// TestAuditHeaders tests header capture and policy evaluation
+func TestAuditHeaders(t *testing.T) {
+	names := []string{"strict-transport-security", "X-Content-Type-Options"}
+	minMaxAge := 180 * 24 * time.Hour
+
+	tests := []struct {
+		name         string
+		url          string
+		headers      map[string]string
+		wantFailures []string
+	}{
+		{
+			name: "all policies pass",
+			url:  "https://example.com",
+			headers: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+			},
+		},
+		{
+			name: "hsts max-age too short",
+			url:  "https://example.com",
+			headers: map[string]string{
+				"Strict-Transport-Security": "max-age=300",
+				"X-Content-Type-Options":    "nosniff",
+			},
+			wantFailures: []string{"Strict-Transport-Security max-age 300 below 15552000"},
+		},
+		{
+			name: "hsts without max-age",
+			url:  "https://example.com",
+			headers: map[string]string{
+				"Strict-Transport-Security": "includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+			},
+			wantFailures: []string{"Strict-Transport-Security has no valid max-age"},
+		},
+		{
+			name:         "headers missing",
+			url:          "https://example.com",
+			headers:      map[string]string{},
+			wantFailures: []string{"Strict-Transport-Security missing", "X-Content-Type-Options missing"},
+		},
+		{
+			name:         "hsts not required over http",
+			url:          "http://example.com",
+			headers:      map[string]string{"X-Content-Type-Options": "nosniff"},
+			wantFailures: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+
+			audit := auditHeaders(tt.url, header, names, minMaxAge)
+
+			if !reflect.DeepEqual(audit.Failures, tt.wantFailures) {
+				t.Errorf("Failures = %q, want %q", audit.Failures, tt.wantFailures)
+			}
+			if audit.Passed() != (len(tt.wantFailures) == 0) {
+				t.Errorf("Passed() = %v", audit.Passed())
+			}
+			for name, value := range tt.headers {
+				if audit.Headers[name] != value {
+					t.Errorf("Headers[%s] = %q, want %q", name, audit.Headers[name], value)
+				}
+			}
+		})
+	}
+}
+
+// TestHSTSMaxAge tests max-age directive parsing
+func TestHSTSMaxAge(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"max-age=31536000", 31536000 * time.Second, true},
+		{"includeSubDomains; Max-Age=\"600\"; preload", 600 * time.Second, true},
+		{"max-age=-1", 0, false},
+		{"max-age=abc", 0, false},
+		{"preload", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := hstsMaxAge(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("hstsMaxAge(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestParseCaptureHeaders tests header name canonicalization and the
+// refusal of headers that can carry credentials
+func TestParseCaptureHeaders(t *testing.T) {
+	got, err := parseCaptureHeaders(" server, Via,x-cache,SERVER,")
+	if want := []string{"Server", "Via", "X-Cache"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCaptureHeaders() = %v, %v; want %v", got, err, want)
+	}
+	for _, value := range []string{"Server,Set-Cookie", "authorization", "X-Auth-Token", "X-Api-Key", "Proxy-Authorization"} {
+		if _, err := parseCaptureHeaders(value); err == nil {
+			t.Errorf("parseCaptureHeaders(%q) succeeded", value)
+		}
+	}
+}
+
+// TestCaptureHeaders tests joined and truncated values and headers the
+// response lacks
+func TestCaptureHeaders(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Add("Via", "1.1 a")
+	header.Add("Via", "1.1 b")
+	header.Set("Server", strings.Repeat("é", capturedHeaderMaxLength))
+	header.Set("Set-Cookie", "id=1")
+
+	got := captureHeaders(header, []string{"Server", "Via", "X-Cache"}, at)
+	if got.Changed != at || len(got.Headers) != 2 || got.Headers["Via"] != "1.1 a, 1.1 b" {
+		t.Errorf("captureHeaders() = %+v", got)
+	}
+	server := got.Headers["Server"]
+	if len(server) > capturedHeaderMaxLength || !utf8.ValidString(server) || !strings.HasSuffix(server, "…") {
+		t.Errorf("Server = %q (%d bytes), want it cut to %d bytes at a character", server, len(server), capturedHeaderMaxLength)
+	}
+}
+
+// TestDropUnchangedCapture tests that captured headers are only saved when
+// they differ from the stored ones
+func TestDropUnchangedCapture(t *testing.T) {
+	stored := store.EndpointData{Captured: &store.CapturedHeaders{Headers: map[string]string{"Server": "nginx"}}}
+	tests := []struct {
+		name     string
+		previous store.EndpointData
+		headers  map[string]string
+		wantKept bool
+	}{
+		{"none stored", store.EndpointData{}, map[string]string{"Server": "nginx"}, true},
+		{"unchanged", stored, map[string]string{"Server": "nginx"}, false},
+		{"changed", stored, map[string]string{"Server": "envoy"}, true},
+		{"header gone", stored, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := store.Result{Captured: &store.CapturedHeaders{Headers: tt.headers}}
+			dropUnchangedCapture(tt.previous, &result)
+			if kept := result.Captured != nil; kept != tt.wantKept {
+				t.Errorf("kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+// TestCheckEndpointStatusCapture tests that status checks capture the
+// configured headers, and only when configured
+func TestCheckEndpointStatusCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx")
+		w.Header().Set("X-Cache", "HIT")
+	}))
+	defer server.Close()
+
+	checker := NewEndpointChecker(Config{CaptureHeaders: []string{"Server", "Via", "X-Cache"}}, store.NewMemoryStore())
+	result := checker.checkEndpointStatus(server.URL)
+	if want := map[string]string{"Server": "nginx", "X-Cache": "HIT"}; result.Captured == nil || !reflect.DeepEqual(result.Captured.Headers, want) {
+		t.Fatalf("Captured = %+v, want %v", result.Captured, want)
+	}
+	if !result.Captured.Changed.Equal(result.CheckedAt) {
+		t.Errorf("Changed = %v, want the check time %v", result.Captured.Changed, result.CheckedAt)
+	}
+
+	checker = NewEndpointChecker(Config{}, store.NewMemoryStore())
+	if result := checker.checkEndpointStatus(server.URL); result.Captured != nil {
+		t.Errorf("Captured = %+v without CAPTURE_HEADERS, want nil", result.Captured)
+	}
+}
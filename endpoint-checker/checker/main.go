@@ -0,0 +1,874 @@
+// Package checker checks the status and the SSL certificates of the
+// endpoints, saves the results to the store and sends the alerts. It is the
+// endpoint-checker command, run by Main, and the checker half of the
+// combined certs-n-status binary.
+package checker
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"certs-n-status/store"
+	"certs-n-status/store/logging"
+	"certs-n-status/store/sdnotify"
+	"certs-n-status/store/version"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Config struct {
+	StatusCheckInterval time.Duration
+	SSLCheckInterval    time.Duration
+	EndpointsFile       string
+	RedisAddr           string
+	RedisUsername       string
+	RedisPassword       string
+	RedisDB             int
+	RedisTLS            store.RedisTLS
+	RedisPool           store.RedisPool
+	KeyPrefix           string // namespace of every Redis key, e.g. "prod:"
+	Storage             string // "redis" or "postgres"
+	DatabaseURL         string
+	ClockSkewWindow     time.Duration
+	AuditHeaders        []string // response headers to capture and audit; empty disables auditing
+	CaptureHeaders      []string // response headers stored for diagnostics; empty captures none
+	HSTSMinMaxAge       time.Duration
+	ResultTTL           int  // results expire after this many check intervals without a write; 0 keeps them forever
+	AutoCleanup         bool // purge data of unlisted endpoints after every SSL check cycle
+	HistoryRetention    store.HistoryRetention
+	LatencyBuckets      []time.Duration    // bucket bounds of the Redis latency histograms
+	EventStreamMaxLen   int64              // events kept in the Redis event stream; 0 keeps all
+	AlertHistoryMaxLen  int64              // notifications kept in the Redis alert history; 0 keeps all
+	AlertHistoryMaxAge  time.Duration      // age after which notifications leave the alert history; 0 keeps all
+	StoreTimeout        time.Duration      // bounds each store call during check cycles; 0 disables
+	AdminAddr           string             // listen address of the admin server, e.g. ":9090"; empty disables it
+	EndpointsSource     string             // "file" reads EndpointsFile; "redis" the endpoint registry, re-read every cycle
+	SlackWebhookURL     string             // Slack incoming webhook state changes are posted to; empty disables it
+	DashboardURL        string             // external URL of the dashboard, linked from notifications
+	WebhookURL          string             // state changes are posted to it as JSON; empty disables it
+	WebhookTemplate     *template.Template // renders the webhook body; nil posts the event fields
+	WebhookHeaders      http.Header        // sent with every webhook request, e.g. Authorization
+	WebhookSecret       string             // signs webhook bodies with HMAC-SHA256; empty sends them unsigned
+	Email               emailConfig        // SMTP server and recipients of email notifications
+	Telegram            telegramConfig     // bot and chats of Telegram notifications
+	Opsgenie            opsgenieConfig     // API key, priorities and teams of Opsgenie alerts
+	AlertCooldown       time.Duration      // repeats of a notified condition within it are held back; 0 sends every one
+	AlertReminder       time.Duration      // reminds of endpoints still down this often; 0 disables reminders
+	AlertEscalation     []escalationStep   // notified as outages last; nil disables escalation
+	AlertRoutes         *alertRoutes       // choose the notifiers of each endpoint's events; nil sends them to all
+	Digest              digestConfig       // schedule and notifiers of the daily digest
+	SLO                 sloConfig          // objectives of tags and how they are computed
+	StrictConfig        bool               // refuse to start without endpoints rather than warn
+	CheckerID           string             // names this instance in its results, events and heartbeats; defaults to the host name
+	Log                 logging.Config     // format and sinks of the log
+}
+
+type EndpointChecker struct {
+	config          Config
+	store           store.Store
+	ctx             context.Context // done once Stop is called
+	stop            context.CancelFunc
+	loops           sync.WaitGroup // the loops started by Start
+	ready           chan struct{}  // closed by Start, see Ready
+	statusCycle     cycleClock
+	sslCycle        cycleClock
+	httpClient      *http.Client
+	rootCAs         *x509.CertPool // nil uses the system roots
+	endpointsLoaded atomic.Bool    // reported by /readyz
+	notifiers       []*notifyQueue
+	alerts          *alertGate  // nil without a cooldown or reminders
+	leading         atomic.Bool // leads the checker instances, see runLeaderElection
+
+	endpointsMu sync.Mutex
+	endpoints   []string                   // checked in the current cycles
+	options     map[string]endpointOptions // by endpoint, from ENDPOINTS_FILE
+}
+
+// errMemoryStorage is returned by NewStore for STORAGE=memory: a separate
+// dashboard could not read the results kept in the checker's process
+var errMemoryStorage = errors.New("STORAGE=memory only works in the combined certs-n-status binary, which runs the dashboard in the checker's process")
+
+// NewStore opens the storage backend selected by config.Storage. The
+// in-memory store is opened by NewMemoryStore instead.
+func NewStore(ctx context.Context, config Config) (store.Store, error) {
+	switch config.Storage {
+	case "redis":
+		return newRedisStore(config)
+	case "postgres":
+		return store.OpenPostgres(ctx, config.DatabaseURL)
+	case "memory":
+		return nil, errMemoryStorage
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q (use redis, postgres or memory)", config.Storage)
+	}
+}
+
+// NewMemoryStore creates the in-memory store of STORAGE=memory, expiring
+// results and trimming history like the Redis store
+func NewMemoryStore(config Config) *store.MemoryStore {
+	st := store.NewMemoryStore()
+	st.SetResultTTL(
+		time.Duration(config.ResultTTL)*config.StatusCheckInterval,
+		time.Duration(config.ResultTTL)*config.SSLCheckInterval,
+	)
+	st.SetHistoryRetention(config.HistoryRetention)
+	return st
+}
+
+// newRedisStore creates the Redis-backed store described by config
+func newRedisStore(config Config) (*store.RedisStore, error) {
+	opts := &redis.Options{
+		Addr:     config.RedisAddr,
+		Username: config.RedisUsername,
+		Password: config.RedisPassword,
+		DB:       config.RedisDB,
+		// Store calls are bounded by their context, see storeContext
+		ContextTimeoutEnabled: true,
+	}
+	if err := config.RedisTLS.ApplyTo(opts); err != nil {
+		return nil, err
+	}
+	config.RedisPool.ApplyTo(opts)
+	st := store.NewRedisStore(redis.NewClient(opts))
+	st.SetKeyPrefix(config.KeyPrefix)
+	st.SetResultTTL(
+		time.Duration(config.ResultTTL)*config.StatusCheckInterval,
+		time.Duration(config.ResultTTL)*config.SSLCheckInterval,
+	)
+	st.SetHistoryRetention(config.HistoryRetention)
+	st.SetLatencyBuckets(config.LatencyBuckets)
+	st.SetEventStreamMaxLen(config.EventStreamMaxLen)
+	st.SetAlertHistoryRetention(config.AlertHistoryMaxLen, config.AlertHistoryMaxAge)
+	return st, nil
+}
+
+func NewEndpointChecker(config Config, st store.Store) *EndpointChecker {
+	// Create HTTP client with timeout
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: false,
+			},
+		},
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	ec := &EndpointChecker{
+		config:     config,
+		store:      st,
+		ctx:        ctx,
+		stop:       stop,
+		ready:      make(chan struct{}),
+		httpClient: httpClient,
+	}
+	if config.AlertCooldown > 0 || config.AlertReminder > 0 || config.AlertEscalation != nil {
+		ec.alerts = newAlertGate(config.AlertCooldown, config.AlertReminder, config.AlertEscalation)
+	}
+	if config.SlackWebhookURL != "" {
+		ec.addNotifier(newSlackNotifier(config.SlackWebhookURL, config.DashboardURL))
+	}
+	if config.WebhookURL != "" {
+		ec.addNotifier(newWebhookNotifier(config))
+	}
+	if config.Email.Host != "" {
+		ec.addNotifier(newEmailNotifier(config.Email, config.DashboardURL, ec.endpointTags))
+	}
+	if config.Telegram.BotToken != "" {
+		ec.addNotifier(newTelegramNotifier(config.Telegram, config.DashboardURL, ec.endpointTags))
+	}
+	if config.Opsgenie.APIKey != "" {
+		ec.addNotifier(newOpsgenieNotifier(config.Opsgenie, config.DashboardURL, ec.endpointTags))
+	}
+	return ec
+}
+
+// loadEndpointsFile reads the endpoints of ENDPOINTS_FILE, one per line,
+// skipping blank lines and # comments, along with the options of the
+// lines that have any
+func (ec *EndpointChecker) loadEndpointsFile() ([]string, map[string]endpointOptions, error) {
+	file, err := os.Open(ec.config.EndpointsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open endpoints file: %w", err)
+	}
+	defer file.Close()
+
+	var endpoints []string
+	options := make(map[string]endpointOptions)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		endpoint, lineOptions := parseEndpointLine(line)
+		endpoints = append(endpoints, endpoint)
+		if lineOptions.set() {
+			options[endpoint] = lineOptions
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading endpoints file: %w", err)
+	}
+
+	return endpoints, options, nil
+}
+
+func (ec *EndpointChecker) checkHTTPStatus(url string) (int, error) {
+	statusCode, _, _, err := ec.checkHTTP(url)
+	return statusCode, err
+}
+
+// checkHTTP performs the status check and also returns the response headers
+// and the IP and port of the connection it used: that of the last request
+// after redirects, or the one dialed when the handshake or request failed.
+// The address is "" when no connection was made, e.g. on DNS errors.
+func (ec *EndpointChecker) checkHTTP(url string) (int, http.Header, string, error) {
+	var (
+		mu         sync.Mutex // dials racing for the connection report concurrently
+		remoteAddr string
+	)
+	setRemoteAddr := func(addr string) {
+		mu.Lock()
+		defer mu.Unlock()
+		remoteAddr = addr
+	}
+	trace := &httptrace.ClientTrace{
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				setRemoteAddr(addr)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) { setRemoteAddr(info.Conn.RemoteAddr().String()) },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, "", err
+	}
+
+	resp, err := ec.httpClient.Do(req)
+	mu.Lock()
+	addr := remoteAddr
+	mu.Unlock()
+	if err != nil {
+		// Check if it's a DNS resolution error
+		if strings.Contains(err.Error(), "no such host") ||
+			strings.Contains(err.Error(), "lookup") {
+			return -1, nil, "", err // DNS resolution error
+		}
+		return 0, nil, addr, err // Other network errors
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, resp.Header, addr, nil
+}
+
+// certState reports whether a certificate is already usable by clients.
+// Certificates whose NotBefore lies in the future, or within the clock skew
+// window, are treated as not yet valid.
+func certState(cert store.CertInfo, now time.Time, skew time.Duration) string {
+	if cert.NotBefore.After(now.Add(-skew)) {
+		return store.CertStateNotYetValid
+	}
+	return store.CertStateValid
+}
+
+func (ec *EndpointChecker) checkSSLCertificate(rawURL string) (store.CertInfo, error) {
+	// Only check HTTPS URLs
+	if !strings.HasPrefix(rawURL, "https://") {
+		return store.CertInfo{}, fmt.Errorf("not an HTTPS URL")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return store.CertInfo{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	hostname := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	// The chain and hostname are verified in VerifyConnection rather than by
+	// the handshake itself, so certificates outside their validity period
+	// can still be inspected and reported instead of failing the dial.
+	conn, err := tls.Dial("tcp", net.JoinHostPort(hostname, port), &tls.Config{
+		ServerName:         hostname,
+		InsecureSkipVerify: true,
+		VerifyConnection:   ec.verifyCertificateChain,
+	})
+	if err != nil {
+		return store.CertInfo{}, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return store.CertInfo{}, fmt.Errorf("no certificates found")
+	}
+
+	// Report the leaf certificate
+	leaf := certs[0]
+	fingerprint := sha256.Sum256(leaf.Raw)
+	return store.CertInfo{
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+		Subject:      leaf.Subject.String(),
+		Issuer:       leaf.Issuer.String(),
+		SerialNumber: leaf.SerialNumber.Text(16),
+		Fingerprint:  hex.EncodeToString(fingerprint[:]),
+		RemoteAddr:   conn.RemoteAddr().String(),
+	}, nil
+}
+
+// verifyCertificateChain performs the standard chain and hostname
+// verification, evaluated at a moment inside the leaf's validity period.
+func (ec *EndpointChecker) verifyCertificateChain(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no certificates found")
+	}
+	leaf := cs.PeerCertificates[0]
+
+	verifyAt := time.Now()
+	if verifyAt.Before(leaf.NotBefore) {
+		verifyAt = leaf.NotBefore
+	} else if verifyAt.After(leaf.NotAfter) {
+		verifyAt = leaf.NotAfter
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         ec.rootCAs,
+		Intermediates: intermediates,
+		CurrentTime:   verifyAt,
+	})
+	return err
+}
+
+// storeContext bounds one store call by STORAGE_TIMEOUT, so a hung storage
+// server fails that call instead of stalling the whole cycle
+func (ec *EndpointChecker) storeContext() (context.Context, context.CancelFunc) {
+	if ec.config.StoreTimeout <= 0 {
+		return context.WithCancel(ec.ctx)
+	}
+	return context.WithTimeout(ec.ctx, ec.config.StoreTimeout)
+}
+
+func (ec *EndpointChecker) storeHTTPStatus(url string, statusCode int) error {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	return ec.store.SetStatus(ctx, url, statusCode, time.Now())
+}
+
+func (ec *EndpointChecker) storeSSLExpiration(url string, expiration time.Time) error {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	return ec.store.SetSSLExpiry(ctx, url, expiration, time.Now())
+}
+
+// pruneSSLExpiryIndex removes index entries for endpoints that are no longer monitored
+func (ec *EndpointChecker) pruneSSLExpiryIndex(endpoints []string) error {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	removed, err := ec.store.PruneSSLExpiryIndex(ctx, endpoints)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Printf("[INFO] Removed %d unmonitored endpoints from the SSL expiry index", removed)
+	}
+	return nil
+}
+
+func (ec *EndpointChecker) checkEndpointStatus(url string) store.Result {
+	start := time.Now()
+	statusCode, header, remoteAddr, err := ec.checkHTTP(url)
+	result := store.Result{Endpoint: url, CheckedAt: start, HasStatus: true, Latency: time.Since(start), Tags: ec.endpointTags(url), Name: ec.endpointName(url), Description: ec.endpointDescription(url), RemoteAddr: remoteAddr, CheckedBy: ec.config.CheckerID}
+
+	if err == nil && len(ec.config.AuditHeaders) > 0 {
+		audit := auditHeaders(url, header, ec.config.AuditHeaders, ec.config.HSTSMinMaxAge)
+		if !audit.Passed() {
+			log.Printf("[WARN] Header audit failed for %s: %s", url, strings.Join(audit.Failures, "; "))
+		}
+		audit.Updated = result.CheckedAt
+		result.HeaderAudit = &audit
+	}
+	if err == nil && len(ec.config.CaptureHeaders) > 0 {
+		captured := captureHeaders(header, ec.config.CaptureHeaders, result.CheckedAt)
+		result.Captured = &captured
+	}
+	if err != nil {
+		if statusCode == -1 {
+			log.Printf("[WARN] DNS resolution failed for %s: %v", url, err)
+		} else {
+			log.Printf("[ERROR] Failed to check status for %s: %v", url, err)
+			// Store error code as 0 for other network errors
+			statusCode = 0
+		}
+	}
+	result.StatusCode = statusCode
+	result.Error = checkError(statusCode, err, result.CheckedAt)
+
+	if statusCode == -1 {
+		log.Printf("[INFO] Status check: %s -> DNS_ERROR (-1)", url)
+	} else if statusCode == 0 {
+		log.Printf("[INFO] Status check: %s -> NETWORK_ERROR (0)", url)
+	} else {
+		log.Printf("[INFO] Status check: %s -> %d", url, statusCode)
+	}
+	return result
+}
+
+// checkEndpointSSL inspects the endpoint's certificate; ok is false when the check failed
+func (ec *EndpointChecker) checkEndpointSSL(url string) (result store.Result, ok bool) {
+	cert, err := ec.checkSSLCertificate(url)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check SSL for %s: %v", url, err)
+		return store.Result{}, false
+	}
+
+	cert.State = certState(cert, time.Now(), ec.config.ClockSkewWindow)
+	if cert.State == store.CertStateNotYetValid {
+		log.Printf("[WARN] SSL certificate for %s is not valid until %s", url, cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+
+	daysLeft, _ := store.DaysLeft(cert.NotAfter, time.Now())
+	log.Printf("[INFO] SSL check: %s -> expires in %d days (%s)", url, daysLeft, cert.NotAfter.Format("2006-01-02"))
+	return store.Result{Endpoint: url, CheckedAt: time.Now(), Cert: &cert, CheckedBy: ec.config.CheckerID}, true
+}
+
+func (ec *EndpointChecker) runStatusChecker() {
+	ticker := time.NewTicker(ec.config.StatusCheckInterval)
+	defer ticker.Stop()
+
+	// Initial check
+	ec.statusCycle.run(func() { ec.checkAllStatuses(ec.currentEndpoints()) })
+
+	for {
+		select {
+		case <-ec.ctx.Done():
+			return
+		case <-ticker.C:
+			ec.statusCycle.run(func() { ec.checkAllStatuses(ec.reloadEndpoints()) })
+		}
+	}
+}
+
+func (ec *EndpointChecker) runSSLChecker() {
+	ticker := time.NewTicker(ec.config.SSLCheckInterval)
+	defer ticker.Stop()
+
+	// Initial check
+	ec.sslCycle.run(func() { ec.checkAllSSL(ec.currentEndpoints()) })
+
+	for {
+		select {
+		case <-ec.ctx.Done():
+			return
+		case <-ticker.C:
+			ec.sslCycle.run(func() { ec.checkAllSSL(ec.reloadEndpoints()) })
+		}
+	}
+}
+
+// checkAllStatuses checks the endpoints due by their schedule and marks
+// the others paused. Endpoints paused through the dashboard are skipped.
+func (ec *EndpointChecker) checkAllStatuses(endpoints []string) {
+	now := time.Now()
+	endpoints, paused := ec.splitBySchedule(ec.skipPaused(endpoints), ec.statusCycle.scheduleSince(now, ec.config.StatusCheckInterval), now)
+	var wg sync.WaitGroup
+	results, written := ec.writeResults("status")
+	for _, url := range paused {
+		results <- store.Result{Endpoint: url, CheckedAt: now, PausedBySchedule: true}
+	}
+	for _, url := range endpoints {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			results <- ec.checkEndpointStatus(u)
+		}(url)
+	}
+	wg.Wait()
+	close(results)
+	<-written
+	ec.saveHeartbeat()
+}
+
+// checkAllSSL checks the certificates of the HTTPS endpoints due by their
+// schedule and not paused through the dashboard, then prunes the expiry index and cleans up unmonitored endpoints
+func (ec *EndpointChecker) checkAllSSL(endpoints []string) {
+	now := time.Now()
+	due, _ := ec.splitBySchedule(ec.skipPaused(endpoints), ec.sslCycle.scheduleSince(now, ec.config.SSLCheckInterval), now)
+	var wg sync.WaitGroup
+	results, written := ec.writeResults("SSL")
+	for _, url := range due {
+		// Only check HTTPS URLs
+		if strings.HasPrefix(url, "https://") {
+			wg.Add(1)
+			go func(u string) {
+				defer wg.Done()
+				if result, ok := ec.checkEndpointSSL(u); ok {
+					results <- result
+				}
+			}(url)
+		}
+	}
+	wg.Wait()
+	close(results)
+	<-written
+	ec.saveHeartbeat()
+
+	if err := ec.pruneSSLExpiryIndex(endpoints); err != nil {
+		log.Printf("[ERROR] Failed to prune SSL expiry index: %v", err)
+	}
+
+	if ec.config.AutoCleanup {
+		if err := ec.autoCleanup(endpoints); err != nil {
+			log.Printf("[ERROR] Auto cleanup failed: %v", err)
+		}
+	}
+}
+
+// saveHeartbeat tells the dashboard the checker just finished a cycle, so
+// it can flag results as stale when the checker stops
+func (ec *EndpointChecker) saveHeartbeat() {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	heartbeat := store.Heartbeat{
+		At:             time.Now(),
+		CheckerID:      ec.config.CheckerID,
+		StatusInterval: ec.config.StatusCheckInterval,
+		SSLInterval:    ec.config.SSLCheckInterval,
+	}
+	if err := ec.store.SaveHeartbeat(ctx, heartbeat); err != nil {
+		log.Printf("[WARN] Failed to save heartbeat: %v", err)
+	}
+}
+
+// Start connects to the store, loads the endpoints and checks them until
+// Stop is called
+func (ec *EndpointChecker) Start() error {
+	// Probes get answers while the checker starts, reporting it not ready
+	if err := ec.startAdminServer(); err != nil {
+		return err
+	}
+
+	// Test storage connection
+	ctx, cancel := ec.storeContext()
+	err := ec.store.Ping(ctx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", ec.config.Storage, err)
+	}
+	log.Printf("[INFO] Connected to %s successfully", ec.config.Storage)
+
+	// Upgrade data written by older versions, refusing data from newer ones
+	if rs, ok := ec.store.(*store.RedisStore); ok {
+		if err := migrateRedis(ec.ctx, rs, false); err != nil {
+			return err
+		}
+		go rs.WatchPoolTimeouts(ec.ctx, time.Minute)
+	}
+	ec.loadAlertStates()
+
+	// Load endpoints
+	if err := ec.seedEndpointRegistry(); err != nil {
+		return err
+	}
+	endpoints, err := ec.loadEndpoints()
+	if err != nil {
+		return err
+	}
+	if err := ec.config.checkEndpointList(endpoints); err != nil {
+		if ec.config.StrictConfig {
+			return err
+		}
+		log.Printf("[WARN] %v", err)
+	}
+	log.Printf("[INFO] Loaded %d endpoints", len(endpoints))
+	ec.setEndpoints(endpoints)
+	ec.endpointsLoaded.Store(true)
+
+	// The registry follows the file, unless it is the source itself
+	if rs, ok := ec.store.(*store.RedisStore); ok && ec.config.EndpointsSource == endpointsSourceFile {
+		removed, err := rs.SyncEndpointRegistry(ec.ctx, endpoints)
+		if err != nil {
+			return fmt.Errorf("failed to update endpoint registry: %w", err)
+		}
+		if removed > 0 {
+			log.Printf("[INFO] Removed %d endpoints no longer in %s from the registry", removed, ec.config.EndpointsFile)
+		}
+	}
+
+	// Start checkers in separate goroutines
+	ec.loops.Go(ec.runStatusChecker)
+	ec.loops.Go(ec.runSSLChecker)
+	ec.loops.Go(ec.runLatencyRollups)
+	if rs, ok := ec.store.(*store.RedisStore); ok {
+		ec.loops.Go(func() { ec.watchRechecks(rs) })
+	}
+	if len(ec.config.Digest.Notify) > 0 {
+		ec.loops.Go(ec.runLeaderElection)
+		ec.loops.Go(ec.runDailyDigest)
+	}
+	close(ec.ready)
+
+	// Run until stopped, letting the cycles in progress finish
+	<-ec.ctx.Done()
+	ec.loops.Wait()
+	log.Printf("[INFO] Endpoint checker stopped")
+	return nil
+}
+
+// Stop makes Start return once the check cycles in progress are done,
+// stopping the admin server and the notifications not yet sent. The
+// endpoint-checker command never stops, the combined binary stops on
+// SIGTERM.
+func (ec *EndpointChecker) Stop() {
+	ec.stop()
+}
+
+// Main runs the endpoint-checker command line
+func Main() {
+	configFile := flag.String("config", "", "YAML config file; environment variables override its settings")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	command := cmp.Or(flag.Arg(0), "run")
+	var args []string
+	if flag.NArg() > 1 {
+		args = flag.Args()[1:]
+	}
+	if *showVersion || command == "version" {
+		fmt.Println("endpoint-checker " + version.Get().String())
+		return
+	}
+
+	if *configFile != "" {
+		if err := store.ApplyConfigFile(*configFile, ConfigKeys, "dashboard"); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	var env store.Env
+	config, err := LoadConfig(&env)
+	if *printConfig {
+		if err := env.WriteConfig(os.Stdout, ConfigKeys); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	}
+	if err != nil {
+		logging.Setup(logging.Config{Format: config.Log.Format})
+		log.Fatalf("[FATAL] Invalid configuration:\n%v", err)
+	}
+	if *printConfig {
+		return
+	}
+	closeLog, err := logging.Setup(config.Log)
+	if err != nil {
+		log.Fatalf("[FATAL] Failed to set up logging: %v", err)
+	}
+	defer closeLog()
+
+	switch command {
+	case "run":
+		runChecker(config, args)
+	case "cleanup":
+		if err := runCleanup(config, args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	case "migrate":
+		if err := runMigrate(config, args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	case "export":
+		if err := runExport(config, args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	case "import":
+		if err := runImport(config, args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	case "notify-test":
+		if err := runNotifyTest(config, args); err != nil {
+			log.Fatalf("[FATAL] %v", err)
+		}
+	default:
+		log.Fatalf("[FATAL] Unknown command %q (use run, cleanup, migrate, export, import, notify-test or version)", command)
+	}
+}
+
+// LoadConfig returns the defaults overridden by environment variables,
+// read through env. It fails listing every invalid value and setting, see
+// Config.validate.
+func LoadConfig(env *store.Env) (Config, error) {
+	// DATABASE_URL alone selects PostgreSQL
+	defaultStorage := "redis"
+	if os.Getenv("DATABASE_URL") != "" {
+		defaultStorage = "postgres"
+	}
+	config := Config{
+		StatusCheckInterval: env.Duration("STATUS_CHECK_INTERVAL", 1*time.Minute),
+		SSLCheckInterval:    env.Duration("SSL_CHECK_INTERVAL", 1*time.Hour),
+		EndpointsFile:       env.String("ENDPOINTS_FILE", "endpoints.lst"),
+		RedisAddr:           env.String("REDIS_ADDR", "localhost:6379"),
+		RedisDB:             env.Int("REDIS_DB", 0),
+		KeyPrefix:           env.String("KEY_PREFIX", ""),
+		DatabaseURL:         env.String("DATABASE_URL", ""),
+		Storage:             env.String("STORAGE", defaultStorage),
+		ClockSkewWindow:     env.Duration("CLOCK_SKEW_WINDOW", 5*time.Minute),
+		AuditHeaders:        env.List("AUDIT_HEADERS"),
+		HSTSMinMaxAge:       env.Duration("HSTS_MIN_MAX_AGE", 180*24*time.Hour),
+		ResultTTL:           env.Int("RESULT_TTL", 10),
+		AutoCleanup:         env.Bool("AUTO_CLEANUP", false),
+		HistoryRetention:    store.DefaultHistoryRetention,
+		LatencyBuckets:      store.DefaultLatencyBuckets,
+		EventStreamMaxLen:   env.Int64("EVENTS_MAXLEN", store.DefaultEventStreamMaxLen),
+		AlertHistoryMaxLen:  env.Int64("ALERT_HISTORY_MAXLEN", store.DefaultAlertHistoryMaxLen),
+		AlertHistoryMaxAge:  env.Duration("ALERT_HISTORY_MAX_AGE", store.DefaultAlertHistoryMaxAge),
+		StoreTimeout:        env.Duration("STORAGE_TIMEOUT", store.DefaultOperationTimeout),
+		AdminAddr:           env.String("ADMIN_ADDR", ""),
+		EndpointsSource:     env.String("ENDPOINTS_SOURCE", endpointsSourceFile),
+		SlackWebhookURL:     env.String("SLACK_WEBHOOK_URL", ""),
+		DashboardURL:        env.String("DASHBOARD_URL", ""),
+		WebhookURL:          env.String("WEBHOOK_URL", ""),
+		WebhookSecret:       env.String("WEBHOOK_SECRET", ""),
+		AlertCooldown:       env.Duration("ALERT_COOLDOWN", 10*time.Minute),
+		AlertReminder:       env.Duration("ALERT_REMINDER", 0),
+		StrictConfig:        env.Bool("CONFIG_STRICT", false),
+		CheckerID:           env.String("CHECKER_ID", defaultCheckerID()),
+		Log:                 logging.ConfigFromEnv(env, "endpoint-checker"),
+	}
+
+	if envRetention := os.Getenv("HISTORY_RETENTION"); envRetention != "" {
+		retention, err := store.ParseHistoryRetention(envRetention)
+		env.Add(err)
+		if err == nil {
+			config.HistoryRetention = retention
+		}
+	}
+	if envCapture := os.Getenv("CAPTURE_HEADERS"); envCapture != "" {
+		names, err := parseCaptureHeaders(envCapture)
+		env.Add(err)
+		config.CaptureHeaders = names
+	}
+	if envBuckets := os.Getenv("LATENCY_BUCKETS"); envBuckets != "" {
+		buckets, err := store.ParseLatencyBuckets(envBuckets)
+		if err != nil {
+			env.Add(fmt.Errorf("invalid LATENCY_BUCKETS %q: %w", envBuckets, err))
+		} else {
+			config.LatencyBuckets = buckets
+		}
+	}
+	if config.SlackWebhookURL != "" {
+		env.Add(checkWebhookURL("SLACK_WEBHOOK_URL", config.SlackWebhookURL))
+	}
+	if config.WebhookURL != "" {
+		env.Add(checkWebhookURL("WEBHOOK_URL", config.WebhookURL))
+	}
+	if path := os.Getenv("WEBHOOK_TEMPLATE"); path != "" {
+		tmpl, err := parseWebhookTemplate(path)
+		env.Add(err)
+		config.WebhookTemplate = tmpl
+	}
+	if envHeaders := os.Getenv("WEBHOOK_HEADERS"); envHeaders != "" {
+		headers, err := parseWebhookHeaders(envHeaders)
+		env.Add(err)
+		config.WebhookHeaders = headers
+	}
+	var err error
+	config.Email, err = loadEmailConfig()
+	env.Add(err)
+	config.Telegram, err = loadTelegramConfig()
+	env.Add(err)
+	config.Opsgenie, err = loadOpsgenieConfig()
+	env.Add(err)
+	if path := os.Getenv("ALERT_ROUTES_FILE"); path != "" {
+		config.AlertRoutes, err = loadAlertRoutes(path, config.configuredNotifiers())
+		env.Add(err)
+	}
+	if envEscalation := os.Getenv("ALERT_ESCALATION"); envEscalation != "" {
+		config.AlertEscalation, err = parseEscalation(envEscalation, config.configuredNotifiers())
+		env.Add(err)
+	}
+	config.Digest, err = loadDigestConfig(config.configuredNotifiers())
+	env.Add(err)
+	config.SLO = loadSLOConfig(env, config.StatusCheckInterval)
+	config.RedisUsername, config.RedisPassword, err = store.RedisCredentialsFromEnv()
+	env.Add(err)
+	config.RedisTLS, err = store.RedisTLSFromEnv()
+	env.Add(err)
+	config.RedisPool, err = store.RedisPoolFromEnv()
+	env.Add(err)
+
+	for _, problem := range config.validate() {
+		env.Add(problem)
+	}
+	return config, env.Err()
+}
+
+func runChecker(config Config, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	testEmail := fs.Bool("test-email", false, "send a test email to every recipient at startup")
+	sendDigestNow := fs.Bool("send-digest-now", false, "send the daily digest at startup")
+	fs.Parse(args)
+
+	log.Printf("[INFO] Starting endpoint checker %s", version.Get())
+	log.Printf("[INFO] Status check interval: %s", config.StatusCheckInterval)
+	log.Printf("[INFO] SSL check interval: %s", config.SSLCheckInterval)
+
+	st, err := NewStore(context.Background(), config)
+	if err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+
+	checker := NewEndpointChecker(config, st)
+	if *testEmail {
+		if err := checker.sendTestEmail(); err != nil {
+			log.Printf("[ERROR] Failed to send test email: %v", err)
+		} else {
+			log.Printf("[INFO] Sent test email")
+		}
+	}
+	if *sendDigestNow {
+		if err := checker.sendDailyDigest(); err != nil {
+			log.Printf("[ERROR] Failed to send the daily digest: %v", err)
+		}
+	}
+	// Under systemd, Type=notify waits for the loops and WatchdogSec
+	// restarts a checker whose cycles stop completing
+	go func() {
+		<-checker.Ready()
+		if err := sdnotify.Notify(sdnotify.Ready); err != nil {
+			log.Printf("[WARN] Failed to notify systemd: %v", err)
+		}
+		sdnotify.Watchdog(context.Background(), checker.Healthy)
+	}()
+	if err := checker.Start(); err != nil {
+		log.Fatalf("[FATAL] %v", err)
+	}
+}
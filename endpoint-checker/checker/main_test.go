@@ -0,0 +1,3723 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"certs-n-status/store"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// TestLoadEndpoints tests the endpoint loading functionality
+func TestLoadEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantCount  int
+		wantErr    bool
+		wantScheme string
+	}{
+		{
+			name: "valid endpoints with https",
+			content: `https://example.com
+https://google.com
+https://github.com`,
+			wantCount:  3,
+			wantErr:    false,
+			wantScheme: "https://",
+		},
+		{
+			name: "endpoints without scheme",
+			content: `example.com
+google.com`,
+			wantCount:  2,
+			wantErr:    false,
+			wantScheme: "https://",
+		},
+		{
+			name: "endpoints with comments and empty lines",
+			content: `# This is a comment
+https://example.com
+
+# Another comment
+https://google.com
+
+`,
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "mixed http and https",
+			content: `http://example.com
+https://google.com`,
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "empty file",
+			content:   "",
+			wantCount: 0,
+			wantErr:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary file
+			tmpfile, err := os.CreateTemp("", "endpoints-*.lst")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(tmpfile.Name())
+
+			// Write test content
+			if _, err := tmpfile.Write([]byte(tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tmpfile.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Create checker with test config
+			config := Config{
+				EndpointsFile: tmpfile.Name(),
+				RedisAddr:     "localhost:6379",
+			}
+			checker := NewEndpointChecker(config, store.NewMemoryStore())
+
+			// Load endpoints
+			endpoints, err := checker.loadEndpoints()
+
+			// Check error expectation
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// Check count
+			if len(endpoints) != tt.wantCount {
+				t.Errorf("loadEndpoints() got %d endpoints, want %d", len(endpoints), tt.wantCount)
+			}
+
+			// Check scheme if specified
+			if tt.wantScheme != "" && len(endpoints) > 0 {
+				for _, endpoint := range endpoints {
+					if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+						t.Errorf("endpoint %s missing http/https scheme", endpoint)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestEndpointTags tests the tags=, name=, desc=, public= and expect_issuer=
+// options of the endpoints file and that status results carry them
+func TestEndpointTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.lst")
+	content := `https://a.example.com tags=prod,Payments,prod name="Payments API" desc="Card payments of the EU shops" public=true
+b.example.com   name=Website expect_issuer="Let's Encrypt"
+https://c.example.com tags=staging,bad/tag owner=ops public=maybe name="" desc= expect_issuer=
+münchen.example/status name=München
+https://d.example.com desc="Internal wiki"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(Config{EndpointsFile: path}, store.NewMemoryStore())
+	endpoints, err := checker.loadEndpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com", "https://xn--mnchen-3ya.example/status", "https://d.example.com"}; !slices.Equal(endpoints, want) {
+		t.Errorf("loadEndpoints() = %v, want %v", endpoints, want)
+	}
+
+	tests := []struct {
+		endpoint   string
+		want       []string
+		wantName   string
+		wantDesc   string
+		wantIssuer string
+	}{
+		{"https://a.example.com", []string{"prod", "payments", store.PublicTag}, "Payments API", "Card payments of the EU shops", ""},
+		{"https://b.example.com", nil, "Website", "", "Let's Encrypt"},
+		{"https://c.example.com", []string{"staging"}, "", "", ""},
+		{"https://xn--mnchen-3ya.example/status", nil, "München", "", ""},
+		{"https://d.example.com", nil, "", "Internal wiki", ""},
+	}
+	for _, tt := range tests {
+		if got := checker.endpointTags(tt.endpoint); !slices.Equal(got, tt.want) {
+			t.Errorf("endpointTags(%s) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+		if got := checker.endpointName(tt.endpoint); got != tt.wantName {
+			t.Errorf("endpointName(%s) = %q, want %q", tt.endpoint, got, tt.wantName)
+		}
+		if got := checker.endpointDescription(tt.endpoint); got != tt.wantDesc {
+			t.Errorf("endpointDescription(%s) = %q, want %q", tt.endpoint, got, tt.wantDesc)
+		}
+		if got := checker.endpointExpectedIssuer(tt.endpoint); got != tt.wantIssuer {
+			t.Errorf("endpointExpectedIssuer(%s) = %q, want %q", tt.endpoint, got, tt.wantIssuer)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	checker.setOptions(map[string]endpointOptions{server.URL: {tags: []string{"prod"}, name: "Shop", description: "Storefront"}})
+	if result := checker.checkEndpointStatus(server.URL); !slices.Equal(result.Tags, []string{"prod"}) || result.Name != "Shop" || result.Description != "Storefront" {
+		t.Errorf("status result Tags, Name, Description = %q, %q, %q, want [prod], Shop, Storefront", result.Tags, result.Name, result.Description)
+	}
+}
+
+// TestEndpointsSource tests the settings of ENDPOINTS_SOURCE that need no Redis
+func TestEndpointsSource(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{endpointsSourceRedis, "ENDPOINTS_SOURCE=redis requires Redis storage"},
+		{"consul", `unknown ENDPOINTS_SOURCE "consul"`},
+	}
+	for _, tt := range tests {
+		checker := NewEndpointChecker(Config{Storage: "memory", EndpointsSource: tt.source}, store.NewMemoryStore())
+		if _, err := checker.loadEndpoints(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("loadEndpoints() with %s = %v, want %q", tt.source, err, tt.wantErr)
+		}
+	}
+}
+
+// TestEndpointsSourceRedis tests seeding the registry from the endpoints
+// file and following its changes
+func TestEndpointsSourceRedis(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15,
+	})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	file := filepath.Join(t.TempDir(), "endpoints.lst")
+	os.WriteFile(file, []byte("b.example.com\n# comment\nhttps://a.example.com\n"), 0o644)
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15, Storage: "redis", EndpointsFile: file, EndpointsSource: endpointsSourceRedis}
+	st := mustRedisStore(t, config)
+	checker := NewEndpointChecker(config, st)
+
+	if err := checker.seedEndpointRegistry(); err != nil {
+		t.Fatalf("seedEndpointRegistry() = %v", err)
+	}
+	endpoints, err := checker.loadEndpoints()
+	if want := []string{"https://a.example.com", "https://b.example.com"}; err != nil || !slices.Equal(endpoints, want) {
+		t.Fatalf("loadEndpoints() after seeding = %v, %v; want %v", endpoints, err, want)
+	}
+	checker.setEndpoints(endpoints)
+
+	// The file only seeds an empty registry
+	st.RemoveEndpoint(ctx, "https://b.example.com")
+	st.AddEndpoint(ctx, "https://c.example.com")
+	if err := checker.seedEndpointRegistry(); err != nil {
+		t.Fatalf("seedEndpointRegistry() again = %v", err)
+	}
+	if got, want := checker.reloadEndpoints(), []string{"https://a.example.com", "https://c.example.com"}; !slices.Equal(got, want) {
+		t.Errorf("reloadEndpoints() = %v, want %v", got, want)
+	}
+	if got := checker.currentEndpoints(); len(got) != 2 || got[1] != "https://c.example.com" {
+		t.Errorf("currentEndpoints() = %v after the reload", got)
+	}
+}
+
+// TestCheckHTTPStatus tests HTTP status checking
+func TestCheckHTTPStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCode     int
+		wantStatusCode int
+		wantErr        bool
+	}{
+		{"200 OK", http.StatusOK, 200, false},
+		{"404 Not Found", http.StatusNotFound, 404, false},
+		{"500 Internal Server Error", http.StatusInternalServerError, 500, false},
+		{"301 Redirect", http.StatusMovedPermanently, 301, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create test server
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+
+			// Create checker
+			config := Config{
+				RedisAddr: "localhost:6379",
+			}
+			checker := NewEndpointChecker(config, store.NewMemoryStore())
+
+			// Check status
+			statusCode, err := checker.checkHTTPStatus(server.URL)
+
+			// Verify results
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkHTTPStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if statusCode != tt.wantStatusCode {
+				t.Errorf("checkHTTPStatus() = %v, want %v", statusCode, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+// TestCheckHTTPStatusTimeout tests timeout handling
+func TestCheckHTTPStatusTimeout(t *testing.T) {
+	// Create server that delays response
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(15 * time.Second) // Longer than client timeout
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{
+		RedisAddr: "localhost:6379",
+	}
+	checker := NewEndpointChecker(config, store.NewMemoryStore())
+
+	// This should timeout
+	_, err := checker.checkHTTPStatus(server.URL)
+	if err == nil {
+		t.Error("checkHTTPStatus() expected timeout error, got nil")
+	}
+}
+
+// TestCheckError tests that failed status checks are stored with the class
+// and message of their error, and up checks without one, along with the
+// address they connected to
+func TestCheckError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + ln.Addr().String()
+	ln.Close()
+
+	checker := NewEndpointChecker(Config{CheckerID: "eu-west-1"}, store.NewMemoryStore())
+	tests := []struct {
+		name        string
+		url         string
+		wantClass   string
+		wantMessage string
+		wantAddr    string
+	}{
+		{"up", server.URL, "", "", server.Listener.Addr().String()},
+		{"http error", server.URL + "/down", store.ErrorClassHTTP, "HTTP 503 Service Unavailable", server.Listener.Addr().String()},
+		{"connection refused", closed, store.ErrorClassConnectionRefused, "connection refused", ""},
+		{"untrusted certificate", untrusted.URL, store.ErrorClassTLS, "certificate", untrusted.Listener.Addr().String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checker.checkEndpointStatus(tt.url)
+			if result.RemoteAddr != tt.wantAddr {
+				t.Errorf("RemoteAddr = %q, want %q", result.RemoteAddr, tt.wantAddr)
+			}
+			if result.CheckedBy != "eu-west-1" {
+				t.Errorf("CheckedBy = %q, want eu-west-1", result.CheckedBy)
+			}
+			if tt.wantClass == "" {
+				if result.Error != nil {
+					t.Errorf("Error = %+v, want nil", result.Error)
+				}
+				return
+			}
+			if result.Error == nil {
+				t.Fatalf("Error = nil, want class %q", tt.wantClass)
+			}
+			if result.Error.Class != tt.wantClass || !strings.Contains(result.Error.Message, tt.wantMessage) {
+				t.Errorf("Error = %q %q, want class %q with %q", result.Error.Class, result.Error.Message, tt.wantClass, tt.wantMessage)
+			}
+			if !result.Error.At.Equal(result.CheckedAt) {
+				t.Errorf("Error.At = %v, want the check time %v", result.Error.At, result.CheckedAt)
+			}
+		})
+	}
+
+	classes := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"dns", &url.Error{Op: "Get", URL: "https://nx.invalid", Err: &net.DNSError{Err: "no such host", Name: "nx.invalid", IsNotFound: true}}, store.ErrorClassDNS},
+		{"deadline", fmt.Errorf("get: %w", context.DeadlineExceeded), store.ErrorClassTimeout},
+		{"client timeout", &url.Error{Op: "Get", URL: "https://slow.example", Err: &net.OpError{Op: "dial", Err: timeoutError{}}}, store.ErrorClassTimeout},
+		{"reset", &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, store.ErrorClassConnectionReset},
+		{"tls alert", fmt.Errorf("handshake: %w", tls.AlertError(40)), store.ErrorClassTLS},
+		{"other", errors.New("EOF"), store.ErrorClassNetwork},
+	}
+	for _, tt := range classes {
+		t.Run("class "+tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// newTLSTestServer starts an HTTPS server presenting a self-signed certificate
+// for 127.0.0.1 with the given validity period, and returns a pool trusting it.
+func newTLSTestServer(t *testing.T, notBefore, notAfter time.Time) (*httptest.Server, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}},
+	}
+	server.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return server, pool
+}
+
+// TestCheckSSLCertificate tests certificate inspection, including certificates outside their validity period
+func TestCheckSSLCertificate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		wantState string
+	}{
+		{"valid", now.Add(-24 * time.Hour), now.Add(90 * 24 * time.Hour), store.CertStateValid},
+		{"not yet valid", now.Add(48 * time.Hour), now.Add(90 * 24 * time.Hour), store.CertStateNotYetValid},
+		{"issued within skew window", now.Add(-time.Minute), now.Add(90 * 24 * time.Hour), store.CertStateNotYetValid},
+		{"expired", now.Add(-90 * 24 * time.Hour), now.Add(-24 * time.Hour), store.CertStateValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pool := newTLSTestServer(t, tt.notBefore, tt.notAfter)
+			defer server.Close()
+
+			checker := NewEndpointChecker(Config{}, store.NewMemoryStore())
+			checker.rootCAs = pool
+
+			cert, err := checker.checkSSLCertificate(server.URL)
+			if err != nil {
+				t.Fatalf("checkSSLCertificate() error = %v", err)
+			}
+			if !cert.NotBefore.Equal(tt.notBefore.Truncate(time.Second)) {
+				t.Errorf("NotBefore = %v, want %v", cert.NotBefore, tt.notBefore)
+			}
+			if !cert.NotAfter.Equal(tt.notAfter.Truncate(time.Second)) {
+				t.Errorf("NotAfter = %v, want %v", cert.NotAfter, tt.notAfter)
+			}
+			if cert.Fingerprint == "" {
+				t.Error("Fingerprint is empty")
+			}
+			if cert.RemoteAddr != server.Listener.Addr().String() {
+				t.Errorf("RemoteAddr = %q, want %q", cert.RemoteAddr, server.Listener.Addr().String())
+			}
+
+			if state := certState(cert, now, 5*time.Minute); state != tt.wantState {
+				t.Errorf("certState() = %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
+
+// TestCheckSSLCertificateUntrusted tests that chain verification is still enforced
+func TestCheckSSLCertificateUntrusted(t *testing.T) {
+	server, _ := newTLSTestServer(t, time.Now().Add(time.Hour), time.Now().Add(48*time.Hour))
+	defer server.Close()
+
+	checker := NewEndpointChecker(Config{}, store.NewMemoryStore())
+
+	if _, err := checker.checkSSLCertificate(server.URL); err == nil {
+		t.Error("checkSSLCertificate() expected verification error for untrusted certificate, got nil")
+	}
+}
+
+// TestStoreHTTPStatus tests Redis storage (requires Redis running)
+func TestStoreHTTPStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	// Create Redis client for testing
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15, // Use different DB for testing
+	})
+	ctx := context.Background()
+
+	// Check if Redis is available
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+
+	// Clean up test data
+	defer rdb.FlushDB(ctx)
+
+	config := Config{
+		RedisAddr: "localhost:6379",
+		RedisDB:   15,
+	}
+	checker := NewEndpointChecker(config, mustRedisStore(t, config))
+
+	testURL := "https://example.com"
+	testStatus := 200
+
+	// Store status
+	err := checker.storeHTTPStatus(testURL, testStatus)
+	if err != nil {
+		t.Fatalf("storeHTTPStatus() error = %v", err)
+	}
+
+	// Verify stored status
+	statusKey := store.Keys{}.Endpoint(testURL)
+	storedStatus, err := rdb.HGet(ctx, statusKey, "status").Int()
+	if err != nil {
+		t.Fatalf("Failed to get stored status: %v", err)
+	}
+
+	if storedStatus != testStatus {
+		t.Errorf("Stored status = %d, want %d", storedStatus, testStatus)
+	}
+
+	// Verify timestamp was stored
+	timestamp, err := rdb.HGet(ctx, statusKey, "status_updated").Int64()
+	if err != nil {
+		t.Fatalf("Failed to get stored timestamp: %v", err)
+	}
+
+	// Timestamp should be recent (within last minute)
+	now := time.Now().Unix()
+	if now-timestamp > 60 {
+		t.Errorf("Timestamp too old: %d, now: %d", timestamp, now)
+	}
+}
+
+// TestStoreSSLExpiration tests SSL expiration storage (requires Redis)
+func TestStoreSSLExpiration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15,
+	})
+	ctx := context.Background()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+	defer rdb.FlushDB(ctx)
+
+	config := Config{
+		RedisAddr: "localhost:6379",
+		RedisDB:   15,
+	}
+	checker := NewEndpointChecker(config, mustRedisStore(t, config))
+
+	testURL := "https://example.com"
+	testExpiration := time.Now().Add(90 * 24 * time.Hour) // 90 days from now
+
+	// Store SSL expiration
+	err := checker.storeSSLExpiration(testURL, testExpiration)
+	if err != nil {
+		t.Fatalf("storeSSLExpiration() error = %v", err)
+	}
+
+	// Verify stored expiration
+	sslKey := store.Keys{}.Endpoint(testURL)
+	storedTimestamp, err := rdb.HGet(ctx, sslKey, "ssl_expiry").Int64()
+	if err != nil {
+		t.Fatalf("Failed to get stored SSL expiration: %v", err)
+	}
+
+	if storedTimestamp != testExpiration.Unix() {
+		t.Errorf("Stored expiration = %d, want %d", storedTimestamp, testExpiration.Unix())
+	}
+}
+
+// TestSSLExpiryIndex tests the expiring-soon sorted set maintenance (requires Redis)
+func TestSSLExpiryIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15,
+	})
+	ctx := context.Background()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+	defer rdb.FlushDB(ctx)
+
+	config := Config{
+		RedisAddr: "localhost:6379",
+		RedisDB:   15,
+	}
+	checker := NewEndpointChecker(config, mustRedisStore(t, config))
+
+	kept := "https://example.com"
+	removed := "https://removed.example.com"
+	expiration := time.Now().Add(10 * 24 * time.Hour)
+
+	for _, url := range []string{kept, removed} {
+		if err := checker.storeSSLExpiration(url, expiration); err != nil {
+			t.Fatalf("storeSSLExpiration() error = %v", err)
+		}
+	}
+
+	score, err := rdb.ZScore(ctx, store.SSLExpiryIndexKey, kept).Result()
+	if err != nil {
+		t.Fatalf("Failed to get index score: %v", err)
+	}
+	if int64(score) != expiration.Unix() {
+		t.Errorf("Index score = %d, want %d", int64(score), expiration.Unix())
+	}
+
+	// Only the kept endpoint is still monitored
+	if err := checker.pruneSSLExpiryIndex([]string{kept, "http://plain.example.com"}); err != nil {
+		t.Fatalf("pruneSSLExpiryIndex() error = %v", err)
+	}
+
+	members, err := rdb.ZRange(ctx, store.SSLExpiryIndexKey, 0, -1).Result()
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if len(members) != 1 || members[0] != kept {
+		t.Errorf("Index members = %v, want [%s]", members, kept)
+	}
+}
+
+// TestCheckAllStatuses tests concurrent status checking and the heartbeat
+// written after the cycle
+func TestCheckAllStatuses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	// Create multiple test servers
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server1.Close()
+
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server2.Close()
+
+	// Create Redis client for testing
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15,
+	})
+	ctx := context.Background()
+
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+	defer rdb.FlushDB(ctx)
+
+	config := Config{
+		RedisAddr:           "localhost:6379",
+		RedisDB:             15,
+		StatusCheckInterval: time.Minute,
+	}
+	rs := mustRedisStore(t, config)
+	checker := NewEndpointChecker(config, rs)
+
+	endpoints := []string{server1.URL, server2.URL}
+
+	// Check all statuses
+	start := time.Now().Truncate(time.Second)
+	checker.checkAllStatuses(endpoints)
+
+	heartbeat, err := rs.Heartbeat(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get heartbeat: %v", err)
+	}
+	if heartbeat.At.Before(start) || heartbeat.StatusInterval != time.Minute {
+		t.Errorf("Heartbeat = %+v, want one at the end of the cycle with a 1m status interval", heartbeat)
+	}
+
+	// Verify results in Redis
+	status1, err := rdb.HGet(ctx, store.Keys{}.Endpoint(server1.URL), "status").Int()
+	if err != nil {
+		t.Fatalf("Failed to get status for server1: %v", err)
+	}
+	if status1 != http.StatusOK {
+		t.Errorf("Server1 status = %d, want %d", status1, http.StatusOK)
+	}
+
+	status2, err := rdb.HGet(ctx, store.Keys{}.Endpoint(server2.URL), "status").Int()
+	if err != nil {
+		t.Fatalf("Failed to get status for server2: %v", err)
+	}
+	if status2 != http.StatusNotFound {
+		t.Errorf("Server2 status = %d, want %d", status2, http.StatusNotFound)
+	}
+}
+
+// TestCheckAllStatusesMemoryStore tests concurrent status checking against the in-memory store
+func TestCheckAllStatusesMemoryStore(t *testing.T) {
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server1.Close()
+
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server2.Close()
+
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	checker.checkAllStatuses([]string{server1.URL, server2.URL})
+
+	for url, want := range map[string]int{server1.URL: http.StatusOK, server2.URL: http.StatusNotFound} {
+		data, err := st.GetEndpointData(context.Background(), url)
+		if err != nil {
+			t.Fatalf("GetEndpointData() error = %v", err)
+		}
+		if data.StatusCode != want {
+			t.Errorf("%s status = %d, want %d", url, data.StatusCode, want)
+		}
+		if time.Since(data.StatusUpdated) > time.Minute {
+			t.Errorf("%s status timestamp too old: %v", url, data.StatusUpdated)
+		}
+	}
+}
+
+// TestRecheck tests that a recheck saves the status, and the certificate of
+// an HTTPS endpoint, in one result
+func TestRecheck(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer plain.Close()
+	notAfter := time.Now().Add(60 * 24 * time.Hour)
+	secure, pool := newTLSTestServer(t, time.Now().Add(-24*time.Hour), notAfter)
+	defer secure.Close()
+
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	checker.rootCAs = pool
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantSSL  bool
+	}{
+		{"http", plain.URL, false},
+		{"https", secure.URL, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker.recheck(tt.endpoint)
+			data, err := st.GetEndpointData(context.Background(), tt.endpoint)
+			if err != nil {
+				t.Fatalf("GetEndpointData() error = %v", err)
+			}
+			if !data.HasStatus || time.Since(data.StatusUpdated) > time.Minute {
+				t.Errorf("status not saved: %+v", data)
+			}
+			if tt.endpoint == plain.URL && data.StatusCode != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", data.StatusCode, http.StatusNoContent)
+			}
+			if gotSSL := !data.SSLUpdated.IsZero(); gotSSL != tt.wantSSL {
+				t.Errorf("SSL saved = %v, want %v", gotSSL, tt.wantSSL)
+			}
+			if tt.wantSSL && !data.SSLExpiration.Equal(notAfter.Truncate(time.Second)) {
+				t.Errorf("SSL expiration = %v, want %v", data.SSLExpiration, notAfter)
+			}
+		})
+	}
+}
+
+// BenchmarkCheckHTTPStatus benchmarks HTTP status checking
+func BenchmarkCheckHTTPStatus(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := Config{
+		RedisAddr: "localhost:6379",
+	}
+	checker := NewEndpointChecker(config, store.NewMemoryStore())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = checker.checkHTTPStatus(server.URL)
+	}
+}
+
+// BenchmarkCheckAllStatuses benchmarks concurrent checking
+func BenchmarkCheckAllStatuses(b *testing.B) {
+	// Create test servers
+	servers := make([]*httptest.Server, 10)
+	endpoints := make([]string, 10)
+	for i := 0; i < 10; i++ {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		endpoints[i] = servers[i].URL
+		defer servers[i].Close()
+	}
+
+	checker := NewEndpointChecker(Config{Storage: "memory"}, store.NewMemoryStore())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		checker.checkAllStatuses(endpoints)
+	}
+}
+
+// mustRedisStore opens the Redis store for config or fails the test
+func mustRedisStore(t testing.TB, config Config) *store.RedisStore {
+	t.Helper()
+	st, err := newRedisStore(config)
+	if err != nil {
+		t.Fatalf("newRedisStore: %v", err)
+	}
+	return st
+}
+
+// TestRollupLatency tests that hourly rollups are computed from the status history
+func TestRollupLatency(t *testing.T) {
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	endpoint := "https://example.com"
+	hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, latency := range []time.Duration{100, 300, 200} {
+		st.SaveResults(context.Background(), []store.Result{{
+			Endpoint:   endpoint,
+			CheckedAt:  hour.Add(time.Duration(i) * 20 * time.Minute),
+			HasStatus:  true,
+			StatusCode: 200,
+			Latency:    latency * time.Millisecond,
+		}})
+	}
+	st.SaveResults(context.Background(), []store.Result{{Endpoint: endpoint, CheckedAt: hour.Add(50 * time.Minute), HasStatus: true, StatusCode: 0}})
+
+	// Running twice, as after a restart, gives the same result
+	checker.rollupLatency([]string{endpoint}, hour.Add(90*time.Minute))
+	checker.rollupLatency([]string{endpoint}, hour.Add(90*time.Minute))
+
+	rollups, err := st.LatencyRollups(context.Background(), endpoint, hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 1 {
+		t.Fatalf("got %d rollups, want 1", len(rollups))
+	}
+	if r := rollups[0]; r.Count != 3 || r.Min != 100*time.Millisecond || r.Avg != 200*time.Millisecond || r.Max != 300*time.Millisecond || r.Checks != 4 || r.Up != 3 {
+		t.Errorf("rollup = %+v", r)
+	}
+
+	data, _ := st.GetEndpointData(context.Background(), endpoint)
+	want := []store.Uptime{{Window: "24h", Checks: 4, Up: 3}, {Window: "7d", Checks: 4, Up: 3}, {Window: "30d", Checks: 4, Up: 3}}
+	if !slices.Equal(data.Uptime, want) {
+		t.Errorf("uptime = %+v, want %+v", data.Uptime, want)
+	}
+}
+
+// TestDetectTransitions tests that only changes from the stored state are reported
+func TestDetectTransitions(t *testing.T) {
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	endpoint := "https://example.com"
+	result := func(code int) []store.Result {
+		return []store.Result{{Endpoint: endpoint, CheckedAt: time.Now(), HasStatus: true, StatusCode: code}}
+	}
+
+	checker.saveResults("status", result(200))
+	if events := checker.detectTransitions(result(200)); len(events) != 0 {
+		t.Errorf("unchanged status produced events: %+v", events)
+	}
+
+	events := checker.detectTransitions(result(503))
+	if len(events) != 1 || events[0].Kind != store.EventKindStatus || events[0].Old != store.StatusUp || events[0].New != store.StatusDown {
+		t.Fatalf("events = %+v, want one up -> down status event", events)
+	}
+
+	checker.saveResults("status", result(503))
+	if events := checker.detectTransitions(result(0)); len(events) != 0 {
+		t.Errorf("endpoint that stayed down produced events: %+v", events)
+	}
+}
+
+// TestOutageRecovery tests that failed checks carry the start of their
+// outage and the recovery reports how long it lasted
+func TestOutageRecovery(t *testing.T) {
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	endpoint := "https://example.com"
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	result := func(minutes, code int) []store.Result {
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		return []store.Result{{Endpoint: endpoint, CheckedAt: at, HasStatus: true, StatusCode: code, Error: checkError(code, nil, at)}}
+	}
+
+	checker.saveResults("status", result(0, 200))
+	for minutes, code := range []int{503, 502, 503} {
+		checker.saveResults("status", result(minutes+1, code))
+	}
+	data, _ := st.GetEndpointData(context.Background(), endpoint)
+	if data.Error == nil || !data.Error.Since.Equal(start.Add(time.Minute)) || data.Error.Count != 3 {
+		t.Fatalf("stored error = %+v, want the outage since 12:01 with 3 failed checks", data.Error)
+	}
+
+	events := checker.detectTransitions(result(30, 200))
+	if len(events) != 1 || !events[0].DownSince.Equal(start.Add(time.Minute)) || events[0].FailedChecks != 3 {
+		t.Fatalf("events = %+v, want a recovery from the outage since 12:01 with 3 failed checks", events)
+	}
+	if got, want := describeEvent(events[0], endpoint), "https://example.com recovered after 29m down (3 failed checks)"; got != want {
+		t.Errorf("describeEvent() = %q, want %q", got, want)
+	}
+
+	// The next outage starts over
+	checker.saveResults("status", result(30, 200))
+	checker.saveResults("status", result(40, 500))
+	data, _ = st.GetEndpointData(context.Background(), endpoint)
+	if data.Error == nil || !data.Error.Since.Equal(start.Add(40*time.Minute)) || data.Error.Count != 1 {
+		t.Errorf("stored error = %+v, want a new outage since 12:40", data.Error)
+	}
+}
+
+// TestCertChangeEvents tests that a replaced certificate is reported with
+// the expect_issuer of its endpoint and rated by its assessment
+func TestCertChangeEvents(t *testing.T) {
+	checker := NewEndpointChecker(Config{}, store.NewMemoryStore())
+	endpoint := "https://example.com"
+	checker.setOptions(map[string]endpointOptions{endpoint: {expectIssuer: "Let's Encrypt"}})
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	result := func(issuer, serial string, notAfter time.Time) []store.Result {
+		return []store.Result{{Endpoint: endpoint, CheckedAt: at, Cert: &store.CertInfo{NotAfter: notAfter, Issuer: issuer, SerialNumber: serial, Fingerprint: "fp-" + serial}}}
+	}
+	letsEncrypt := "CN=R3,O=Let's Encrypt,C=US"
+	checker.saveResults("ssl", result(letsEncrypt, "01", at.AddDate(0, 2, 0)))
+
+	tests := []struct {
+		name         string
+		issuer       string
+		notAfter     time.Time
+		wantSeverity string
+	}{
+		{"renewal", letsEncrypt, at.AddDate(0, 3, 0), severityInfo},
+		{"expiring sooner", letsEncrypt, at.AddDate(0, 1, 0), severityWarning},
+		{"unexpected issuer", "CN=Example CA", at.AddDate(0, 3, 0), severityCritical},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := result(tt.issuer, fmt.Sprintf("%02d", i+2), tt.notAfter)
+			var change store.Event
+			for _, event := range checker.detectTransitions(results) {
+				if event.Kind == store.EventKindCertChanged {
+					change = event
+				}
+			}
+			if change.CertChange.ExpectedIssuer != "Let's Encrypt" || change.New != results[0].Cert.Fingerprint {
+				t.Fatalf("cert_changed event = %+v, want the new fingerprint and the expected issuer", change)
+			}
+			if !notable(change) {
+				t.Error("notable() = false, want true")
+			}
+			if got := eventSeverity(change); got != tt.wantSeverity {
+				t.Errorf("eventSeverity() = %s, want %s", got, tt.wantSeverity)
+			}
+			checker.saveResults("ssl", results)
+		})
+	}
+}
+
+// TestCertLevelHysteresis tests that a certificate served near a threshold
+// is reported once, and back to ok once it is clear of the window
+func TestCertLevelHysteresis(t *testing.T) {
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{}, st)
+	endpoint := "https://example.com"
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	var got []string
+	// Two load balancers alternately serve certificates on either side of
+	// the warning window, until both are renewed
+	for i, left := range []time.Duration{40 * day, 29 * day, 31 * day, 29 * day, 31 * day, 60 * day} {
+		at := start.Add(time.Duration(i) * time.Minute)
+		results := []store.Result{{Endpoint: endpoint, CheckedAt: at, Cert: &store.CertInfo{NotAfter: at.Add(left)}}}
+		for _, event := range checker.detectTransitions(results) {
+			if event.Kind == store.EventKindCert {
+				got = append(got, event.Old+" -> "+event.New)
+			}
+		}
+		if err := st.SaveResults(context.Background(), results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"ok -> warning", "warning -> ok"}; !slices.Equal(got, want) {
+		t.Errorf("cert events = %q, want %q", got, want)
+	}
+	if data, _ := st.GetEndpointData(context.Background(), endpoint); data.CertLevel != store.CertLevelOK {
+		t.Errorf("stored CertLevel = %q, want ok", data.CertLevel)
+	}
+}
+
+// flakyStore fails the first failures SaveResults calls and records the
+// endpoints of every call
+type flakyStore struct {
+	*store.MemoryStore
+	failures int
+	calls    [][]string
+}
+
+func (s *flakyStore) SaveResults(ctx context.Context, results []store.Result) error {
+	var endpoints []string
+	for _, result := range results {
+		endpoints = append(endpoints, result.Endpoint)
+	}
+	s.calls = append(s.calls, endpoints)
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("connection reset")
+	}
+	return s.MemoryStore.SaveResults(ctx, results)
+}
+
+// TestPublishEventsAcknowledged tests that events of acknowledged endpoints
+// are published marked (requires Redis)
+func TestPublishEventsAcknowledged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15, CheckerID: "eu-west-1"}
+	rs := mustRedisStore(t, config)
+	ctx := context.Background()
+	if err := rs.Ping(ctx); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: config.RedisAddr, DB: config.RedisDB})
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	now := time.Now().UTC()
+	if err := rs.Acknowledge(ctx, store.Ack{Endpoint: "https://a.example.com", Reason: "maintenance", At: now, Until: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(config, rs)
+	checker.publishEvents([]store.Event{
+		{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now},
+		{Endpoint: "https://b.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now},
+	})
+
+	events, err := rs.Events(ctx, "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !events[0].Acknowledged || events[1].Acknowledged {
+		t.Errorf("events = %+v, want only the first acknowledged", events)
+	}
+	for _, event := range events {
+		if event.CheckedBy != "eu-west-1" {
+			t.Errorf("event of %s CheckedBy = %q, want eu-west-1", event.Endpoint, event.CheckedBy)
+		}
+	}
+}
+
+// TestNotable tests which state changes are sent to notifiers
+func TestNotable(t *testing.T) {
+	event := func(kind, from, to string) store.Event {
+		return store.Event{Endpoint: "https://example.com", Kind: kind, Old: from, New: to}
+	}
+	acknowledged := event(store.EventKindStatus, store.StatusUp, store.StatusDown)
+	acknowledged.Acknowledged = true
+	inMaintenance := event(store.EventKindStatus, store.StatusUp, store.StatusDown)
+	inMaintenance.InMaintenance = true
+
+	tests := []struct {
+		name  string
+		event store.Event
+		want  bool
+	}{
+		{"goes down", event(store.EventKindStatus, store.StatusUp, store.StatusDown), true},
+		{"recovers", event(store.EventKindStatus, store.StatusDown, store.StatusUp), true},
+		{"cert enters warning", event(store.EventKindCert, store.CertLevelOK, store.CertLevelWarning), true},
+		{"cert enters critical", event(store.EventKindCert, store.CertLevelWarning, store.CertLevelCritical), true},
+		{"cert skips warning", event(store.EventKindCert, store.CertLevelOK, store.CertLevelCritical), true},
+		{"cert expires", event(store.EventKindCert, store.CertLevelCritical, store.CertLevelExpired), true},
+		{"cert renewed to ok", event(store.EventKindCert, store.CertLevelCritical, store.CertLevelOK), true},
+		{"cert renewed into warning", event(store.EventKindCert, store.CertLevelExpired, store.CertLevelWarning), false},
+		{"renewal", event(store.EventKindCertRenewed, "2024-03-04T12:00:00Z", "2024-05-30T12:00:00Z"), false},
+		{"acknowledged", acknowledged, false},
+		{"in maintenance", inMaintenance, false},
+		{"SLO burning", event(store.EventKindSLOBurn, store.SLOStateOK, store.SLOStateBurning), true},
+		{"SLO no longer burning", event(store.EventKindSLOBurn, store.SLOStateBurning, store.SLOStateOK), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notable(tt.event); got != tt.want {
+				t.Errorf("notable(%+v) = %v, want %v", tt.event, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSlackMessage tests the text posted to Slack for each kind of event
+func TestSlackMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     store.Event
+		dashboard string
+		want      string
+	}{
+		{
+			"down with error class",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, ErrorClass: store.ErrorClassTimeout},
+			"https://status.example.com/",
+			":red_circle: *https://example.com* is down\nstatus: up → down · error: timeout · <https://status.example.com/?q=https%3A%2F%2Fexample.com|Open in dashboard>",
+		},
+		{
+			"down with checker ID",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, ErrorClass: store.ErrorClassTimeout, CheckedBy: "eu-west-1"},
+			"",
+			":red_circle: *https://example.com* is down\nstatus: up → down · error: timeout · checked by: eu-west-1",
+		},
+		{
+			"recovered without dashboard",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp},
+			"",
+			":large_green_circle: *https://example.com* recovered\nstatus: down → up",
+		},
+		{
+			"recovered after an outage",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp, At: time.Date(2025, 6, 1, 14, 0, 0, 0, time.UTC), DownSince: time.Date(2025, 6, 1, 12, 45, 0, 0, time.UTC), FailedChecks: 25},
+			"",
+			":large_green_circle: *https://example.com* recovered after 1h15m down (25 failed checks)\nstatus: down → up",
+		},
+		{
+			"cert warning",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning},
+			"",
+			":warning: Certificate of *https://example.com* expires within 30 days\ncert: ok → warning",
+		},
+		{
+			"cert critical",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelWarning, New: store.CertLevelCritical},
+			"",
+			":rotating_light: Certificate of *https://example.com* expires within 7 days\ncert: warning → critical",
+		},
+		{
+			"cert expired",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelCritical, New: store.CertLevelExpired},
+			"",
+			":x: Certificate of *https://example.com* expired\ncert: critical → expired",
+		},
+		{
+			"cert renewed",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelExpired, New: store.CertLevelOK},
+			"",
+			":white_check_mark: Certificate of *https://example.com* is valid again\ncert: expired → ok",
+		},
+		{
+			"cert renewed with expiry",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelCritical, New: store.CertLevelOK, NotAfter: time.Date(2025, 9, 30, 23, 59, 59, 0, time.UTC)},
+			"",
+			":white_check_mark: Certificate of *https://example.com* is valid again, until 2025-09-30\ncert: critical → ok",
+		},
+		{
+			"cert changed on renewal",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCertChanged, CertChange: store.CertChange{
+				OldIssuer: "CN=R3,O=Let's Encrypt,C=US", NewIssuer: "CN=R3,O=Let's Encrypt,C=US", OldSerial: "01", NewSerial: "02",
+				OldNotAfter: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), NewNotAfter: time.Date(2025, 9, 29, 0, 0, 0, 0, time.UTC),
+			}},
+			"",
+			":arrows_counterclockwise: Certificate of *https://example.com* was renewed, valid until 2025-09-29\nissuer: Let's Encrypt · serial: 01 → 02 · expires: 2025-07-01 → 2025-09-29",
+		},
+		{
+			"cert changed issuer",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCertChanged, CertChange: store.CertChange{
+				OldIssuer: "CN=R3,O=Let's Encrypt,C=US", NewIssuer: `CN=Example CA\, Inc.`, OldSerial: "01", NewSerial: "02",
+				OldNotAfter: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), NewNotAfter: time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+			}},
+			"",
+			":warning: Certificate of *https://example.com* changed issuer from Let's Encrypt to Example CA, Inc.\nissuer: Let's Encrypt → Example CA, Inc. · serial: 01 → 02 · expires: 2025-07-01 → 2026-07-01",
+		},
+		{
+			"cert changed expiring sooner",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCertChanged, CertChange: store.CertChange{
+				OldSerial: "01", NewSerial: "02",
+				OldNotAfter: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), NewNotAfter: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			}},
+			"",
+			":warning: Certificate of *https://example.com* was replaced with one expiring sooner, on 2025-07-01\nserial: 01 → 02 · expires: 2025-09-01 → 2025-07-01",
+		},
+		{
+			"cert changed to an unexpected issuer",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCertChanged, CertChange: store.CertChange{
+				OldIssuer: "CN=Example CA", NewIssuer: "CN=Example CA", OldSerial: "01", NewSerial: "02", ExpectedIssuer: "let's encrypt",
+				OldNotAfter: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), NewNotAfter: time.Date(2025, 9, 29, 0, 0, 0, 0, time.UTC),
+			}},
+			"",
+			":rotating_light: Certificate of *https://example.com* is issued by Example CA, not the expected let's encrypt\nissuer: Example CA · serial: 01 → 02 · expires: 2025-07-01 → 2025-09-29",
+		},
+		{
+			"SLO burning",
+			store.Event{Endpoint: store.SLOEndpoint("payments"), Kind: store.EventKindSLOBurn, Old: store.SLOStateOK, New: store.SLOStateBurning, SLO: store.SLOStatus{
+				Tag: "payments", Objective: 99.9, Window: "30d", BudgetRemaining: 0.42, BurnRate: 15.24,
+			}},
+			"https://status.example.com",
+			":fire: *slo:payments* (99.9% over 30d) is burning its error budget at 15.2x the sustainable rate, 42.0% left\nslo_burn: ok → burning · <https://status.example.com/?tag=payments|Open in dashboard>",
+		},
+		{
+			"SLO no longer burning",
+			store.Event{Endpoint: store.SLOEndpoint("payments"), Kind: store.EventKindSLOBurn, Old: store.SLOStateBurning, New: store.SLOStateOK, SLO: store.SLOStatus{
+				Tag: "payments", Objective: 99.9, Window: "30d", BudgetRemaining: -0.1, BurnRate: 0.5,
+			}},
+			"",
+			":white_check_mark: *slo:payments* (99.9% over 30d) is no longer burning its error budget fast, -10.0% left\nslo_burn: burning → ok",
+		},
+		{
+			"markup escaped",
+			store.Event{Endpoint: "https://example.com/?a=1&b=<2>", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, ErrorClass: store.ErrorClassHTTP},
+			"https://status.example.com",
+			":red_circle: *https://example.com/?a=1&amp;b=&lt;2&gt;* is down\nstatus: up → down · error: http · <https://status.example.com/?q=https%3A%2F%2Fexample.com%2F%3Fa%3D1%26b%3D%3C2%3E|Open in dashboard>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slackMessage(tt.event, strings.TrimSuffix(tt.dashboard, "/")); got != tt.want {
+				t.Errorf("slackMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSlackNotifier tests that notable events are posted to the webhook
+// asynchronously, retried when the webhook fails and never block a cycle
+func TestSlackNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var texts []string
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			http.Error(w, "rate_limited", http.StatusTooManyRequests)
+			return
+		}
+		var payload struct{ Text string }
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+			return
+		}
+		texts = append(texts, payload.Text)
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	checker := NewEndpointChecker(Config{SlackWebhookURL: server.URL, DashboardURL: "https://status.example.com"}, store.NewMemoryStore())
+	checker.notifiers[0].retryDelay = time.Millisecond
+	now := time.Now().UTC()
+	checker.publishEvents([]store.Event{
+		{Endpoint: "https://a.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now, ErrorClass: store.ErrorClassDNS},
+		{Endpoint: "https://b.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now, InMaintenance: true},
+		{Endpoint: "https://a.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: now},
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(texts)
+		mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 2 || !strings.HasPrefix(texts[0], ":red_circle: *https://a.example.com* is down") || !strings.Contains(texts[0], "error: dns") || !strings.HasPrefix(texts[1], ":warning:") {
+		t.Errorf("posted %q, want the down event after a retry, then the cert warning", texts)
+	}
+}
+
+// blockedNotifier never finishes sending until ctx is done
+type blockedNotifier struct{}
+
+func (blockedNotifier) name() string { return "blocked" }
+
+func (blockedNotifier) send(ctx context.Context, event store.Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestNotifyQueueFull tests that a notifier that hangs drops events
+// instead of blocking the cycle that produced them
+func TestNotifyQueueFull(t *testing.T) {
+	checker := NewEndpointChecker(Config{}, store.NewMemoryStore())
+	// Not running the queue keeps the count of queued events exact
+	checker.notifiers = []*notifyQueue{{notifier: blockedNotifier{}, events: make(chan store.Event, notifyQueueSize)}}
+
+	events := make([]store.Event, 3*notifyQueueSize)
+	for i := range events {
+		events[i] = store.Event{Endpoint: fmt.Sprintf("https://%d.example.com", i), Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown}
+	}
+	done := make(chan struct{})
+	go func() {
+		checker.notify(events)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notify blocked on a hung notifier")
+	}
+	if queued := len(checker.notifiers[0].events); queued != notifyQueueSize {
+		t.Errorf("queued %d events, want %d", queued, notifyQueueSize)
+	}
+}
+
+// TestWebhookNotifier tests the templated body, custom headers and HMAC
+// signature of webhook requests, and that events the webhook keeps failing
+// are given up on after notifyAttempts
+func TestWebhookNotifier(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 10)
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header, body}
+		if failing.Load() {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "webhook.tmpl")
+	tmpl := `{"text": {{json (printf "%s is %s" .Endpoint .New)}}, "kind": {{json .Kind}}{{if .DaysLeft}}, "days": {{.DaysLeft}}{{end}}}`
+	if err := os.WriteFile(path, []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{WebhookURL: server.URL, WebhookSecret: "s3cret"}
+	var err error
+	if config.WebhookTemplate, err = parseWebhookTemplate(path); err != nil {
+		t.Fatal(err)
+	}
+	if config.WebhookHeaders, err = parseWebhookHeaders("Authorization: Bearer abc, X-Team: ops"); err != nil {
+		t.Fatal(err)
+	}
+	notifier := newWebhookNotifier(config)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		event store.Event
+		want  string
+	}{
+		{"status", store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now}, `{"text": "https://example.com is down", "kind": "status"}`},
+		{"cert", store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: now, NotAfter: now.Add(20*24*time.Hour + time.Hour)}, `{"text": "https://example.com is warning", "kind": "cert", "days": 21}`},
+		{"quoted", store.Event{Endpoint: `https://example.com/"a"`, Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp, At: now}, `{"text": "https://example.com/\"a\" is up", "kind": "status"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := notifier.send(context.Background(), tt.event); err != nil {
+				t.Fatal(err)
+			}
+			got := <-requests
+			if string(got.body) != tt.want {
+				t.Errorf("body = %s, want %s", got.body, tt.want)
+			}
+			if got.header.Get("Authorization") != "Bearer abc" || got.header.Get("X-Team") != "ops" || got.header.Get("Content-Type") != "application/json" {
+				t.Errorf("headers = %v", got.header)
+			}
+			mac := hmac.New(sha256.New, []byte("s3cret"))
+			mac.Write(got.body)
+			if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); got.header.Get(webhookSignatureHeader) != want {
+				t.Errorf("%s = %q, want %q", webhookSignatureHeader, got.header.Get(webhookSignatureHeader), want)
+			}
+		})
+	}
+
+	t.Run("default body", func(t *testing.T) {
+		plain := newWebhookNotifier(Config{WebhookURL: server.URL})
+		event := store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now, ErrorClass: store.ErrorClassTimeout, CheckedBy: "eu-west-1"}
+		if err := plain.send(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+		got := <-requests
+		want := `{"endpoint":"https://example.com","kind":"status","old":"up","new":"down","at":"2024-03-01T12:00:00Z","error_class":"timeout","checked_by":"eu-west-1"}`
+		if string(got.body) != want || got.header.Get(webhookSignatureHeader) != "" {
+			t.Errorf("body = %s with signature %q, want %s unsigned", got.body, got.header.Get(webhookSignatureHeader), want)
+		}
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		failing.Store(true)
+		letters := make(chan store.DeadLetter, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		q := newNotifyQueue(ctx, notifier, func(letter store.DeadLetter) { letters <- letter })
+		q.retryDelay = time.Millisecond
+		event := store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now}
+		q.enqueue(event)
+		select {
+		case letter := <-letters:
+			if letter.Notifier != "webhook" || letter.Event != event || letter.Attempts != notifyAttempts || !strings.Contains(letter.Error, "503") {
+				t.Errorf("dead letter = %+v", letter)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("failed event was not given up on")
+		}
+		if len(requests) != notifyAttempts {
+			t.Errorf("webhook got %d requests, want %d", len(requests), notifyAttempts)
+		}
+	})
+}
+
+// TestWebhookConfig tests the validation of the webhook settings
+func TestWebhookConfig(t *testing.T) {
+	headers, err := parseWebhookHeaders(" Authorization: Basic YTpi ,X-Empty:, ")
+	if err != nil || headers.Get("Authorization") != "Basic YTpi" || len(headers["X-Empty"]) != 1 {
+		t.Errorf("parseWebhookHeaders = %v, %v", headers, err)
+	}
+	for _, value := range []string{"Authorization", ": value", "Bad Name: value"} {
+		if _, err := parseWebhookHeaders(value); err == nil {
+			t.Errorf("parseWebhookHeaders(%q) succeeded, want an error", value)
+		}
+	}
+
+	for raw, valid := range map[string]bool{"https://hooks.example.com/x": true, "http://10.0.0.1:8080/": true, "hooks.example.com": false, "ftp://example.com": false} {
+		if err := checkWebhookURL("WEBHOOK_URL", raw); (err == nil) != valid {
+			t.Errorf("checkWebhookURL(%q) = %v", raw, err)
+		}
+	}
+
+	dir := t.TempDir()
+	for name, tmpl := range map[string]string{
+		"syntax":  `{"endpoint": {{json .Endpoint}`,
+		"field":   `{"endpoint": {{json .Missing}}}`,
+		"invalid": `{"endpoint": {{.Endpoint}}}`,
+	} {
+		path := filepath.Join(dir, name+".tmpl")
+		os.WriteFile(path, []byte(tmpl), 0o644)
+		if _, err := parseWebhookTemplate(path); err == nil || !strings.Contains(err.Error(), "WEBHOOK_TEMPLATE") {
+			t.Errorf("parseWebhookTemplate(%s) = %v, want an error", name, err)
+		}
+	}
+	if _, err := parseWebhookTemplate(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("parseWebhookTemplate of a missing file succeeded")
+	}
+}
+
+// smtpMessage is a message received by startSMTPServer
+type smtpMessage struct {
+	auth string // decoded AUTH PLAIN response
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal SMTP server for email notifier tests and
+// returns its address and the messages it receives
+func startSMTPServer(t *testing.T) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan smtpMessage, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				text := textproto.NewConn(conn)
+				text.PrintfLine("220 localhost ESMTP")
+				var msg smtpMessage
+				for {
+					line, err := text.ReadLine()
+					if err != nil {
+						return
+					}
+					verb, arg, _ := strings.Cut(line, " ")
+					switch strings.ToUpper(verb) {
+					case "EHLO", "HELO":
+						text.PrintfLine("250-localhost")
+						text.PrintfLine("250 AUTH PLAIN")
+					case "AUTH":
+						_, response, _ := strings.Cut(arg, " ")
+						decoded, _ := base64.StdEncoding.DecodeString(response)
+						msg.auth = string(decoded)
+						text.PrintfLine("235 Authenticated")
+					case "MAIL":
+						msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+						text.PrintfLine("250 OK")
+					case "RCPT":
+						msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+						text.PrintfLine("250 OK")
+					case "DATA":
+						text.PrintfLine("354 Go ahead")
+						data, err := text.ReadDotBytes()
+						if err != nil {
+							return
+						}
+						msg.data = string(data)
+						messages <- msg
+						msg = smtpMessage{}
+						text.PrintfLine("250 Queued")
+					case "QUIT":
+						text.PrintfLine("221 Bye")
+						return
+					default:
+						text.PrintfLine("250 OK")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+// emailBodies returns the subject and the plain text and HTML parts of a
+// received message
+func emailBodies(t *testing.T, data string) (subject, text, html string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part) // NextPart decodes quoted-printable
+		switch {
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+			text = string(body)
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+			html = string(body)
+		}
+	}
+	subject, _ = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	return subject, text, html
+}
+
+// TestEmailNotifier tests the messages mailed for single events, digests
+// and the test email, their recipients by tag, and a server that is down
+func TestEmailNotifier(t *testing.T) {
+	addr, messages := startSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := strconv.Atoi(port)
+	config := emailConfig{
+		Host:            host,
+		Port:            portNumber,
+		Username:        "checker",
+		Password:        "s3cret",
+		TLS:             smtpTLSNone,
+		From:            "Checker <checker@example.com>",
+		To:              []string{"ops@example.com"},
+		TagTo:           map[string][]string{"payments": {"pay@example.com", "ops@example.com"}},
+		DigestThreshold: 2,
+	}
+	tags := map[string][]string{"https://pay.example.com": {"prod", "payments"}}
+	notifier := newEmailNotifier(config, "https://status.example.com/", func(endpoint string) []string { return tags[endpoint] })
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	receive := func() smtpMessage {
+		t.Helper()
+		select {
+		case msg := <-messages:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no email received")
+			return smtpMessage{}
+		}
+	}
+
+	down := store.Event{Endpoint: "https://pay.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now, ErrorClass: store.ErrorClassTimeout}
+	if err := notifier.send(context.Background(), down); err != nil {
+		t.Fatal(err)
+	}
+	msg := receive()
+	if msg.auth != "\x00checker\x00s3cret" || msg.from != "checker@example.com" || !slices.Equal(msg.to, []string{"pay@example.com", "ops@example.com"}) {
+		t.Errorf("envelope = auth %q, from %s, to %v", msg.auth, msg.from, msg.to)
+	}
+	subject, text, html := emailBodies(t, msg.data)
+	if subject != "[certs-n-status] https://pay.example.com is down" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{"Change:   status up → down", "Error:    timeout", "Time:     2024-03-01 12:00:00 UTC", "Dashboard: https://status.example.com/?q=https%3A%2F%2Fpay.example.com"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part does not contain %q:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(html, "<h2>https://pay.example.com is down</h2>") || !strings.Contains(html, `<a href="https://status.example.com/?q=https%3A%2F%2Fpay.example.com">`) {
+		t.Errorf("HTML part = %s", html)
+	}
+
+	cert := store.Event{Endpoint: "https://www.example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: now, NotAfter: now.Add(20 * 24 * time.Hour)}
+	if err := notifier.send(context.Background(), cert); err != nil {
+		t.Fatal(err)
+	}
+	msg = receive()
+	subject, text, _ = emailBodies(t, msg.data)
+	if !slices.Equal(msg.to, []string{"ops@example.com"}) || subject != "[certs-n-status] Certificate of https://www.example.com expires within 30 days" || !strings.Contains(text, "Expires:  2024-03-21 12:00:00 UTC") {
+		t.Errorf("cert email to %v: %q\n%s", msg.to, subject, text)
+	}
+
+	t.Run("digest", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		q := newNotifyQueue(ctx, notifier, nil)
+		q.digestWindow = 200 * time.Millisecond
+		for i := range 5 {
+			q.enqueue(store.Event{Endpoint: fmt.Sprintf("https://%d.example.com", i), Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now})
+		}
+		for i := range 2 {
+			if subject, _, _ := emailBodies(t, receive().data); subject != fmt.Sprintf("[certs-n-status] https://%d.example.com is down", i) {
+				t.Errorf("email %d subject = %q, want a single event", i, subject)
+			}
+		}
+		subject, text, html := emailBodies(t, receive().data)
+		if subject != "[certs-n-status] 3 state changes" || !strings.Contains(text, "2024-03-01 12:00:00 UTC  https://4.example.com is down") || strings.Count(html, "<tr><td>") != 3 {
+			t.Errorf("digest %q:\n%s\n%s", subject, text, html)
+		}
+		select {
+		case msg := <-messages:
+			t.Errorf("unexpected email after the digest: %s", msg.data)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("test email", func(t *testing.T) {
+		checker := NewEndpointChecker(Config{Email: config}, store.NewMemoryStore())
+		if err := checker.sendTestEmail(); err != nil {
+			t.Fatal(err)
+		}
+		msg := receive()
+		if subject, text, _ := emailBodies(t, msg.data); subject != "[certs-n-status] Test email" || !strings.Contains(text, "test email") || !slices.Equal(msg.to, []string{"ops@example.com", "pay@example.com"}) {
+			t.Errorf("test email to %v: %q %s", msg.to, subject, text)
+		}
+		if err := NewEndpointChecker(Config{}, store.NewMemoryStore()).sendTestEmail(); err == nil {
+			t.Error("sendTestEmail without SMTP_HOST succeeded")
+		}
+	})
+
+	t.Run("server down", func(t *testing.T) {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		closed := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		down := config
+		down.Port = closed
+		if err := newEmailNotifier(down, "", func(string) []string { return nil }).send(context.Background(), cert); err == nil {
+			t.Error("send to a closed port succeeded")
+		}
+	})
+}
+
+// TestEmailConfig tests reading the SMTP_ and EMAIL_ variables
+func TestEmailConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    emailConfig
+		wantErr string
+	}{
+		{"disabled", nil, emailConfig{}, ""},
+		{"defaults", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "checker@example.com", "EMAIL_TO": "a@example.com, B <b@example.com>"}, emailConfig{
+			Host: "smtp.example.com", Port: 587, TLS: smtpTLSStartTLS, From: "<checker@example.com>", To: []string{"a@example.com", "b@example.com"}, DigestThreshold: 5,
+		}, ""},
+		{"implicit TLS and tags", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_TLS": "TLS", "SMTP_USERNAME": "u", "SMTP_PASSWORD": "p", "SMTP_FROM": "checker@example.com", "EMAIL_TAG_TO": "Payments=pay@example.com,oncall@example.com; erp=erp@example.com", "EMAIL_DIGEST_THRESHOLD": "10"}, emailConfig{
+			Host: "smtp.example.com", Port: 465, Username: "u", Password: "p", TLS: smtpTLSImplicit, From: "<checker@example.com>",
+			TagTo: map[string][]string{"payments": {"pay@example.com", "oncall@example.com"}, "erp": {"erp@example.com"}}, DigestThreshold: 10,
+		}, ""},
+		{"port", map[string]string{"SMTP_HOST": "localhost", "SMTP_TLS": "none", "SMTP_PORT": "2525", "SMTP_FROM": "c@example.com", "EMAIL_TO": "a@example.com"}, emailConfig{
+			Host: "localhost", Port: 2525, TLS: smtpTLSNone, From: "<c@example.com>", To: []string{"a@example.com"}, DigestThreshold: 5,
+		}, ""},
+		{"bad TLS mode", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_TLS": "ssl"}, emailConfig{}, "SMTP_TLS"},
+		{"bad port", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_PORT": "smtp"}, emailConfig{}, "SMTP_PORT"},
+		{"no sender", map[string]string{"SMTP_HOST": "smtp.example.com", "EMAIL_TO": "a@example.com"}, emailConfig{}, "SMTP_FROM"},
+		{"no recipients", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "c@example.com"}, emailConfig{}, "EMAIL_TO or EMAIL_TAG_TO"},
+		{"bad recipient", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "c@example.com", "EMAIL_TO": "ops"}, emailConfig{}, "EMAIL_TO"},
+		{"bad tag entry", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "c@example.com", "EMAIL_TAG_TO": "pay@example.com"}, emailConfig{}, "EMAIL_TAG_TO"},
+		{"bad threshold", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_FROM": "c@example.com", "EMAIL_TO": "a@example.com", "EMAIL_DIGEST_THRESHOLD": "0"}, emailConfig{}, "EMAIL_DIGEST_THRESHOLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_TLS", "SMTP_USERNAME", "SMTP_PASSWORD", "SMTP_FROM", "EMAIL_TO", "EMAIL_TAG_TO", "EMAIL_DIGEST_THRESHOLD"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := loadEmailConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadEmailConfig() error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadEmailConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTelegramMessage tests the MarkdownV2 text sent to Telegram
+func TestTelegramMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     store.Event
+		dashboard string
+		want      string
+	}{
+		{
+			"down with error class",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, ErrorClass: store.ErrorClassConnectionRefused},
+			"https://status.example.com",
+			"🔴 *https://example\\.com* is down\nstatus: up → down · error: connection\\_refused · [Open in dashboard](https://status.example.com/?q=https%3A%2F%2Fexample.com)",
+		},
+		{
+			"down with checker ID",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, CheckedBy: "eu-west-1"},
+			"",
+			"🔴 *https://example\\.com* is down\nstatus: up → down · checked by: eu\\-west\\-1",
+		},
+		{
+			"cert critical",
+			store.Event{Endpoint: "https://my-site.example.com", Kind: store.EventKindCert, Old: store.CertLevelWarning, New: store.CertLevelCritical},
+			"",
+			"🚨 Certificate of *https://my\\-site\\.example\\.com* expires within 7 days\ncert: warning → critical",
+		},
+		{
+			"cert renewed",
+			store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelExpired, New: store.CertLevelOK},
+			"https://ops.example.com/(certs)",
+			"✅ Certificate of *https://example\\.com* is valid again\ncert: expired → ok · [Open in dashboard](https://ops.example.com/(certs\\)/?q=https%3A%2F%2Fexample.com)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := telegramMessage(tt.event, tt.dashboard); got != tt.want {
+				t.Errorf("telegramMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTelegramNotifier tests routing events to chats by tag, waiting out
+// rate limits, logging rejected chats once and not repeating delivered
+// chats when a send is retried
+func TestTelegramNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string // chat: text
+	attempts := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		var payload struct {
+			ChatID    string `json:"chat_id"`
+			Text      string `json:"text"`
+			ParseMode string `json:"parse_mode"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[payload.ChatID]++
+		switch {
+		case payload.ChatID == "-404":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`)
+		case payload.ChatID == "-429" && attempts[payload.ChatID] == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 0", "parameters": {"retry_after": 0}}`)
+		case payload.ChatID == "-502" && attempts[payload.ChatID] == 1:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			sent = append(sent, payload.ChatID+": "+payload.Text)
+			io.WriteString(w, `{"ok": true, "result": {}}`)
+		}
+	}))
+	defer server.Close()
+
+	config := telegramConfig{
+		BotToken:   "123:secret",
+		ChatIDs:    []string{"42"},
+		TagChatIDs: map[string][]string{"payments": {"-429", "-404"}, "erp": {"-502", "-404", "42"}},
+	}
+	tags := map[string][]string{"https://pay.example.com": {"payments"}, "https://erp.example.com": {"erp"}}
+	notifier := newTelegramNotifier(config, "", func(endpoint string) []string { return tags[endpoint] })
+	notifier.apiURL = server.URL
+	notifier.interval = 0
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	event := func(endpoint string) store.Event {
+		return store.Event{Endpoint: endpoint, Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: time.Now()}
+	}
+	for _, endpoint := range []string{"https://www.example.com", "https://pay.example.com"} {
+		if err := notifier.send(context.Background(), event(endpoint)); err != nil {
+			t.Errorf("send(%s) = %v", endpoint, err)
+		}
+	}
+	erp := event("https://erp.example.com")
+	if err := notifier.send(context.Background(), erp); err == nil || !strings.Contains(err.Error(), "chat -502") {
+		t.Errorf("send with a failing chat = %v, want its error", err)
+	}
+	if err := notifier.send(context.Background(), erp); err != nil {
+		t.Errorf("retried send = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"42: 🔴 *https://www\\.example\\.com* is down\nstatus: up → down",
+		"-429: 🔴 *https://pay\\.example\\.com* is down\nstatus: up → down",
+		"42: 🔴 *https://erp\\.example\\.com* is down\nstatus: up → down",
+		"-502: 🔴 *https://erp\\.example\\.com* is down\nstatus: up → down",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+	if attempts["-404"] != 2 || attempts["42"] != 2 {
+		t.Errorf("attempts = %v, want each delivered or rejected chat tried once per event", attempts)
+	}
+	if n := strings.Count(logged.String(), "rejects messages to chat -404"); n != 1 {
+		t.Errorf("rejected chat logged %d times, want once:\n%s", n, logged.String())
+	}
+
+	t.Run("token not logged", func(t *testing.T) {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		ln.Close()
+		down := newTelegramNotifier(config, "", func(string) []string { return nil })
+		down.apiURL = "http://" + ln.Addr().String()
+		err := down.send(context.Background(), event("https://www.example.com"))
+		if err == nil || strings.Contains(err.Error(), "secret") {
+			t.Errorf("send to an unreachable API = %v, want an error without the token", err)
+		}
+	})
+}
+
+// TestTelegramConfig tests reading the TELEGRAM_ variables
+func TestTelegramConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    telegramConfig
+		wantErr string
+	}{
+		{"disabled", nil, telegramConfig{}, ""},
+		{"chats", map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc", "TELEGRAM_CHAT_ID": "-1001234567890, @oncall_channel", "TELEGRAM_TAG_CHAT_IDS": "payments=42;ERP=-7,-8"}, telegramConfig{
+			BotToken: "123:abc", ChatIDs: []string{"-1001234567890", "@oncall_channel"}, TagChatIDs: map[string][]string{"payments": {"42"}, "erp": {"-7", "-8"}},
+		}, ""},
+		{"no chats", map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc"}, telegramConfig{}, "TELEGRAM_CHAT_ID or TELEGRAM_TAG_CHAT_IDS"},
+		{"bad chat", map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc", "TELEGRAM_CHAT_ID": "oncall"}, telegramConfig{}, "TELEGRAM_CHAT_ID"},
+		{"bad tag chat", map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc", "TELEGRAM_TAG_CHAT_IDS": "payments=1;erp"}, telegramConfig{}, "TELEGRAM_TAG_CHAT_IDS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"TELEGRAM_BOT_TOKEN", "TELEGRAM_CHAT_ID", "TELEGRAM_TAG_CHAT_IDS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := loadTelegramConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadTelegramConfig() error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadTelegramConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestOpsgenieNotifier tests creating, annotating and closing the alert of
+// an endpoint, with the priority and teams of its tags
+func TestOpsgenieNotifier(t *testing.T) {
+	type request struct {
+		path string
+		body map[string]any
+	}
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey secret-key" {
+			http.Error(w, `{"message": "Key format is not valid!"}`, http.StatusUnauthorized)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, request{r.URL.EscapedPath() + "?" + r.URL.RawQuery, body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"result": "Request will be processed", "requestId": "43a29c5c"}`)
+	}))
+	defer server.Close()
+
+	config := opsgenieConfig{
+		APIKey:        "secret-key",
+		APIURL:        server.URL,
+		Priority:      "P3",
+		TagPriorities: map[string][]string{"prod": {"P2"}, "payments": {"P1"}},
+		Teams:         []string{"SRE"},
+		TagTeams:      map[string][]string{"payments": {"Payments"}},
+	}
+	tags := map[string][]string{"https://pay.example.com/api": {"prod", "payments"}}
+	notifier := newOpsgenieNotifier(config, "https://status.example.com/", func(endpoint string) []string { return tags[endpoint] })
+	now := time.Now().UTC()
+	endpoint := "https://pay.example.com/api"
+	events := []store.Event{
+		{Endpoint: endpoint, Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now, ErrorClass: store.ErrorClassTimeout, CheckedBy: "eu-west-1"},
+		{Endpoint: endpoint, Kind: store.EventKindErrorClass, Old: store.ErrorClassTimeout, New: store.ErrorClassHTTP, At: now, ErrorClass: store.ErrorClassHTTP},
+		{Endpoint: endpoint, Kind: store.EventKindStatus, Old: store.StatusDown, New: store.StatusUp, At: now},
+		{Endpoint: "https://www.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now},
+	}
+	for _, event := range events {
+		if err := notifier.send(context.Background(), event); err != nil {
+			t.Fatalf("send(%s %s) = %v", event.Kind, event.New, err)
+		}
+	}
+
+	alias := "https:%2F%2Fpay.example.com%2Fapi"
+	want := []request{
+		{"/v2/alerts?", map[string]any{
+			"message":     "https://pay.example.com/api is down",
+			"alias":       endpoint,
+			"description": "status: up → down\nerror: timeout\nchecked by: eu-west-1\nhttps://status.example.com/?q=https%3A%2F%2Fpay.example.com%2Fapi",
+			"responders":  []any{map[string]any{"name": "Payments", "type": "team"}},
+			"tags":        []any{"prod", "payments"},
+			"details":     map[string]any{"endpoint": endpoint, "error_class": "timeout", "checked_by": "eu-west-1", "dashboard": "https://status.example.com/?q=https%3A%2F%2Fpay.example.com%2Fapi"},
+			"entity":      endpoint,
+			"source":      "certs-n-status",
+			"priority":    "P1",
+		}},
+		{"/v2/alerts/" + alias + "/notes?identifierType=alias", map[string]any{"note": "Still down, now failing with http instead of timeout", "source": "certs-n-status"}},
+		{"/v2/alerts/" + alias + "/close?identifierType=alias", map[string]any{"note": "https://pay.example.com/api recovered", "source": "certs-n-status"}},
+		{"/v2/alerts?", map[string]any{
+			"message":     "https://www.example.com is down",
+			"alias":       "https://www.example.com",
+			"description": "status: up → down\nhttps://status.example.com/?q=https%3A%2F%2Fwww.example.com",
+			"responders":  []any{map[string]any{"name": "SRE", "type": "team"}},
+			"details":     map[string]any{"endpoint": "https://www.example.com", "dashboard": "https://status.example.com/?q=https%3A%2F%2Fwww.example.com"},
+			"entity":      "https://www.example.com",
+			"source":      "certs-n-status",
+			"priority":    "P3",
+		}},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %+v\nwant %+v", requests, want)
+	}
+
+	t.Run("events", func(t *testing.T) {
+		q := &notifyQueue{notifier: notifier}
+		for _, tt := range []struct {
+			event store.Event
+			want  bool
+		}{
+			{events[0], true},
+			{events[1], true},
+			{store.Event{Kind: store.EventKindErrorClass, Old: store.ErrorClassDNS, New: store.ErrorClassTimeout, Acknowledged: true}, false},
+			{store.Event{Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, InMaintenance: true}, false},
+			{store.Event{Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelExpired}, false},
+		} {
+			if got := q.wants(tt.event); got != tt.want {
+				t.Errorf("wants(%s %s -> %s) = %v, want %v", tt.event.Kind, tt.event.Old, tt.event.New, got, tt.want)
+			}
+		}
+		if (&notifyQueue{notifier: newSlackNotifier("", "")}).wants(events[1]) {
+			t.Error("Slack wants error class events, want only notable ones")
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		config.APIKey = "wrong"
+		err := newOpsgenieNotifier(config, "", func(string) []string { return nil }).send(context.Background(), events[0])
+		if err == nil || !strings.Contains(err.Error(), "401") || strings.Contains(err.Error(), "wrong") {
+			t.Errorf("send with an invalid key = %v, want a 401 error without the key", err)
+		}
+	})
+}
+
+// TestOpsgenieConfig tests reading the OPSGENIE_ variables
+func TestOpsgenieConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    opsgenieConfig
+		wantErr string
+	}{
+		{"disabled", nil, opsgenieConfig{}, ""},
+		{"defaults", map[string]string{"OPSGENIE_API_KEY": "key"}, opsgenieConfig{APIKey: "key", APIURL: "https://api.opsgenie.com", Priority: "P3"}, ""},
+		{"all", map[string]string{
+			"OPSGENIE_API_KEY":        "key",
+			"OPSGENIE_API_URL":        "https://api.eu.opsgenie.com/",
+			"OPSGENIE_PRIORITY":       "P4",
+			"OPSGENIE_TAG_PRIORITIES": "prod=P2; Payments=P1",
+			"OPSGENIE_TEAMS":          "SRE",
+			"OPSGENIE_TAG_TEAMS":      "payments=Payments, SRE;erp=ERP",
+		}, opsgenieConfig{
+			APIKey:        "key",
+			APIURL:        "https://api.eu.opsgenie.com",
+			Priority:      "P4",
+			TagPriorities: map[string][]string{"prod": {"P2"}, "payments": {"P1"}},
+			Teams:         []string{"SRE"},
+			TagTeams:      map[string][]string{"payments": {"Payments", "SRE"}, "erp": {"ERP"}},
+		}, ""},
+		{"bad priority", map[string]string{"OPSGENIE_API_KEY": "key", "OPSGENIE_PRIORITY": "high"}, opsgenieConfig{}, "OPSGENIE_PRIORITY"},
+		{"bad tag priority", map[string]string{"OPSGENIE_API_KEY": "key", "OPSGENIE_TAG_PRIORITIES": "prod=P0"}, opsgenieConfig{}, "OPSGENIE_TAG_PRIORITIES"},
+		{"bad url", map[string]string{"OPSGENIE_API_KEY": "key", "OPSGENIE_API_URL": "api.opsgenie.com"}, opsgenieConfig{}, "OPSGENIE_API_URL"},
+		{"empty team", map[string]string{"OPSGENIE_API_KEY": "key", "OPSGENIE_TEAMS": "SRE,"}, opsgenieConfig{}, "OPSGENIE_TEAMS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"OPSGENIE_API_KEY", "OPSGENIE_API_URL", "OPSGENIE_PRIORITY", "OPSGENIE_TAG_PRIORITIES", "OPSGENIE_TEAMS", "OPSGENIE_TAG_TEAMS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := loadOpsgenieConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadOpsgenieConfig() error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadOpsgenieConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAlertGate tests holding back repeated notifications within the
+// cooldown, sending held back outages that last and reminding of lasting
+// outages, against a fake clock
+func TestAlertGate(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	endpoint := "https://example.com"
+	status := func(from, to string) store.Event {
+		return store.Event{Endpoint: endpoint, Kind: store.EventKindStatus, Old: from, New: to}
+	}
+	cert := func(from, to string) store.Event {
+		return store.Event{Endpoint: endpoint, Kind: store.EventKindCert, Old: from, New: to}
+	}
+	down := store.Result{Endpoint: endpoint, HasStatus: true, StatusCode: 503, Error: &store.CheckError{Class: store.ErrorClassHTTP}}
+	up := store.Result{Endpoint: endpoint, HasStatus: true, StatusCode: 200}
+
+	// A step moves the clock to at, then filters event or, without one,
+	// asks for the reminders due for result
+	type step struct {
+		at     time.Duration
+		event  store.Event
+		result store.Result
+		want   string // the notification sent, "" for none
+	}
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		reminder time.Duration
+		previous map[string]store.AlertState
+		steps    []step
+	}{
+		{"flapping", 10 * time.Minute, 0, nil, []step{
+			{at: 0, event: status("up", "down"), want: "status up -> down"},
+			{at: time.Minute, event: status("down", "up"), want: "status down -> up"},
+			{at: 2 * time.Minute, event: status("up", "down")},
+			{at: 2 * time.Minute, result: down},
+			{at: 3 * time.Minute, event: status("down", "up")},
+			{at: 4 * time.Minute, event: status("up", "down")},
+			{at: 9 * time.Minute, result: down},
+			{at: 10 * time.Minute, result: down, want: "status up -> down"},
+			{at: 11 * time.Minute, result: down},
+			{at: 12 * time.Minute, event: status("down", "up"), want: "status down -> up"},
+			{at: 25 * time.Minute, event: status("up", "down"), want: "status up -> down"},
+		}},
+		{"recovers before the cooldown", 10 * time.Minute, 0, nil, []step{
+			{at: 0, event: status("up", "down"), want: "status up -> down"},
+			{at: time.Minute, event: status("down", "up"), want: "status down -> up"},
+			{at: 2 * time.Minute, event: status("up", "down")},
+			{at: 3 * time.Minute, event: status("down", "up")},
+			{at: 20 * time.Minute, result: up},
+			{at: 20 * time.Minute, result: down},
+		}},
+		{"reminders", 10 * time.Minute, time.Hour, nil, []step{
+			{at: 0, event: status("up", "down"), want: "status up -> down"},
+			{at: 30 * time.Minute, result: down},
+			{at: time.Hour, result: down, want: "status down -> down since 0s"},
+			{at: 90 * time.Minute, result: down},
+			{at: 2*time.Hour + time.Second, result: down, want: "status down -> down since 0s"},
+			{at: 2*time.Hour + time.Minute, event: status("down", "up"), want: "status down -> up"},
+			{at: 4 * time.Hour, result: up},
+		}},
+		{"reminders of a held back outage", 10 * time.Minute, time.Hour, nil, []step{
+			{at: 0, event: status("up", "down"), want: "status up -> down"},
+			{at: time.Minute, event: status("down", "up"), want: "status down -> up"},
+			{at: 2 * time.Minute, event: status("up", "down")},
+			{at: 10 * time.Minute, result: down, want: "status up -> down"},
+			{at: time.Hour, result: down},
+			{at: 70 * time.Minute, result: down, want: "status down -> down since 2m0s"},
+		}},
+		{"certificates", 10 * time.Minute, time.Hour, nil, []step{
+			{at: 0, event: cert("ok", "warning"), want: "cert ok -> warning"},
+			{at: time.Minute, event: cert("warning", "ok"), want: "cert warning -> ok"},
+			{at: 2 * time.Minute, event: cert("ok", "warning")},
+			{at: 3 * time.Minute, event: cert("warning", "ok")},
+			{at: 4 * time.Minute, event: cert("ok", "critical"), want: "cert ok -> critical"},
+			{at: 5 * time.Minute, event: cert("critical", "ok"), want: "cert critical -> ok"},
+			{at: 11 * time.Minute, event: cert("ok", "warning"), want: "cert ok -> warning"},
+		}},
+		{"without a cooldown", 0, time.Hour, nil, []step{
+			{at: 0, event: status("up", "down"), want: "status up -> down"},
+			{at: time.Second, event: status("down", "up"), want: "status down -> up"},
+			{at: 2 * time.Second, event: status("up", "down"), want: "status up -> down"},
+		}},
+		{"not gated", 10 * time.Minute, time.Hour, nil, []step{
+			{at: 0, event: store.Event{Endpoint: endpoint, Kind: store.EventKindStatus, Old: "up", New: "down", Acknowledged: true}, want: "status up -> down"},
+			{at: 0, event: store.Event{Endpoint: endpoint, Kind: store.EventKindStatus, Old: "up", New: "down", InMaintenance: true}, want: "status up -> down"},
+			{at: 0, event: store.Event{Endpoint: endpoint, Kind: store.EventKindErrorClass, Old: "timeout", New: "http"}, want: "error_class timeout -> http"},
+			{at: 0, event: cert("critical", "warning"), want: "cert critical -> warning"},
+			{at: time.Hour, result: down},
+			{at: time.Hour, event: status("up", "down"), want: "status up -> down"},
+		}},
+		{"recovery without a record", 10 * time.Minute, 0, nil, []step{
+			{at: 0, event: status("down", "up"), want: "status down -> up"},
+			{at: time.Minute, event: status("up", "down"), want: "status up -> down"},
+		}},
+		{"state of a previous run", 10 * time.Minute, time.Hour, map[string]store.AlertState{endpoint: {
+			Last:       map[string]string{"status": "down"},
+			NotifiedAt: map[string]time.Time{"status:down": start.Add(-5 * time.Minute)},
+			DownSince:  start.Add(-5 * time.Minute),
+		}}, []step{
+			{at: 0, event: status("up", "down")},
+			{at: 55 * time.Minute, result: down, want: "status down -> down since -5m0s"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := newAlertGate(tt.cooldown, tt.reminder, nil)
+			if tt.previous != nil {
+				gate.load(tt.previous)
+			}
+			var now time.Time
+			gate.now = func() time.Time { return now }
+			for i, step := range tt.steps {
+				now = start.Add(step.at)
+				var sent []store.Event
+				if step.event.Endpoint != "" {
+					step.event.At = now
+					sent, _ = gate.filter([]store.Event{step.event})
+				} else {
+					step.result.CheckedAt = now
+					sent, _ = gate.remind([]store.Result{step.result})
+				}
+				var got string
+				for _, event := range sent {
+					got = fmt.Sprintf("%s %s -> %s", event.Kind, event.Old, event.New)
+					if !event.DownSince.IsZero() {
+						got += " since " + event.DownSince.Sub(start).String()
+					}
+				}
+				if len(sent) > 1 || got != step.want {
+					t.Errorf("step %d at %s sent %q, want %q", i, step.at, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+// alertRecorder records the events it is sent
+type alertRecorder struct {
+	events chan store.Event
+}
+
+func (alertRecorder) name() string { return "recorder" }
+
+func (r alertRecorder) send(ctx context.Context, event store.Event) error {
+	r.events <- event
+	return nil
+}
+
+// TestAlertCooldown tests that the checker passes state changes and the
+// reminders of its status results through the alert gate
+func TestAlertCooldown(t *testing.T) {
+	checker := NewEndpointChecker(Config{AlertCooldown: time.Hour, AlertReminder: time.Hour}, store.NewMemoryStore())
+	recorder := alertRecorder{events: make(chan store.Event, 10)}
+	checker.addNotifier(recorder)
+	now := time.Now()
+	checker.alerts.now = func() time.Time { return now }
+
+	endpoint := "https://example.com"
+	// Down again within the cooldown, held back until it has passed
+	for i, code := range []int{200, 503, 200, 503, 503, 503} {
+		now = now.Add(time.Minute)
+		checker.saveResults("status", []store.Result{{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: code}})
+		if i >= 3 {
+			now = now.Add(2 * time.Hour)
+		}
+	}
+
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < 4 {
+		select {
+		case event := <-recorder.events:
+			got = append(got, describeEvent(event, event.Endpoint))
+		case <-timeout:
+			t.Fatalf("notified %q, want 4 notifications", got)
+		}
+	}
+	want := []string{"https://example.com is down", "https://example.com recovered", "https://example.com is down", "https://example.com is still down after 4h2m"}
+	if !slices.Equal(got, want) {
+		t.Errorf("notified %q, want %q", got, want)
+	}
+}
+
+// TestFormatDuration tests the durations of reminders
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		1500 * time.Millisecond:            "2s",
+		45 * time.Second:                   "45s",
+		10*time.Minute + 20*time.Second:    "10m",
+		2 * time.Hour:                      "2h",
+		90 * time.Minute:                   "1h30m",
+		26*time.Hour + 10*time.Minute + 31: "26h10m",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+// TestAlertRoutes tests choosing the route and notifiers of events
+func TestAlertRoutes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	content := `routes:
+  - name: payments
+    tags: [Payments]
+    notify: [opsgenie, Slack]
+  - urls: ["https://*.marketing.example.com/*"]
+    notify: [slack]
+    min_severity: critical
+default:
+  notify: [slack, email]
+  min_severity: warning
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := loadAlertRoutes(path, []string{"slack", "email", "opsgenie"})
+	if err != nil {
+		t.Fatalf("loadAlertRoutes() error = %v", err)
+	}
+
+	down := store.Event{Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown}
+	certWarning := store.Event{Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning}
+	renewed := store.Event{Kind: store.EventKindCertRenewed}
+	tests := []struct {
+		name     string
+		endpoint string
+		tags     []string
+		event    store.Event
+		route    string
+		want     []string
+	}{
+		{"tagged down", "https://pay.example.com", []string{"prod", "payments"}, down, "route payments", []string{"Slack", "Opsgenie"}},
+		{"tagged renewal", "https://pay.example.com", []string{"payments"}, renewed, "route payments", []string{"Slack", "Opsgenie"}},
+		{"pattern down", "https://www.marketing.example.com/spring", nil, down, "route 2", []string{"Slack"}},
+		{"pattern below min severity", "https://www.marketing.example.com/spring", nil, certWarning, "route 2", nil},
+		{"pattern needs the path", "https://www.marketing.example.com", nil, down, "the default route", []string{"Slack", "email"}},
+		{"default warning", "https://example.com", nil, certWarning, "the default route", []string{"Slack", "email"}},
+		{"default below min severity", "https://example.com", nil, renewed, "the default route", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := routes.route(tt.endpoint, tt.tags)
+			if route.label != tt.route {
+				t.Errorf("route = %s, want %s", route.label, tt.route)
+			}
+			var got []string
+			for _, name := range []string{"Slack", "webhook", "email", "Telegram", "Opsgenie"} {
+				if route.allows(name, eventSeverity(tt.event)) {
+					got = append(got, name)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("notifiers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("without a default", func(t *testing.T) {
+		routes, err := parseAlertRoutes([]byte("routes:\n  - tags: [payments]\n    notify: [opsgenie]\n"), []string{"slack", "opsgenie"})
+		if err != nil {
+			t.Fatalf("parseAlertRoutes() error = %v", err)
+		}
+		if got := routes.route("https://example.com", nil).Notify; !slices.Equal(got, []string{"slack", "opsgenie"}) {
+			t.Errorf("default route notifies %q, want every configured notifier", got)
+		}
+	})
+}
+
+// TestAlertRoutesInvalid tests that invalid routes are refused along with
+// the route at fault
+func TestAlertRoutesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", "", "defines no routes"},
+		{"not yaml", "routes: [", "yaml"},
+		{"unknown field", "routes:\n  - tags: [payments]\n    notfy: [slack]\n", "notfy"},
+		{"unknown notifier", "routes:\n  - tags: [payments]\n    notify: [pagerduty]\n", `route 1 {tags: [payments], notify: [pagerduty]}: unknown notifier "pagerduty"`},
+		{"unconfigured notifier", "routes:\n  - name: payments\n    tags: [payments]\n    notify: [opsgenie]\n", "route 1 {name: payments, tags: [payments], notify: [opsgenie]}: notifier opsgenie is not configured"},
+		{"matches nothing", "routes:\n  - notify: [slack]\n", "route 1 {notify: [slack]}: matches nothing"},
+		{"invalid tag", "routes:\n  - tags: [pay ments]\n    notify: [slack]\n", `invalid tag "pay ments"`},
+		{"unknown severity", "routes:\n  - tags: [payments]\n    notify: [slack]\n    min_severity: high\n", `unknown min_severity "high"`},
+		{"default with tags", "default:\n  tags: [payments]\n  notify: [slack]\n", "default route {tags: [payments], notify: [slack]}: matches every endpoint"},
+		{"default unknown notifier", "default:\n  notify: [sms]\n", `default route {notify: [sms]}: unknown notifier "sms"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAlertRoutes([]byte(tt.content), []string{"slack"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseAlertRoutes() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := loadAlertRoutes(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil || !strings.Contains(err.Error(), "ALERT_ROUTES_FILE") {
+		t.Errorf("loadAlertRoutes() of a missing file error = %v, want one naming ALERT_ROUTES_FILE", err)
+	}
+}
+
+// routeRecorder is a notifier with any name that records what it is sent
+type routeRecorder struct {
+	label  string
+	events chan store.Event
+}
+
+func (r routeRecorder) name() string { return r.label }
+
+func (r routeRecorder) send(ctx context.Context, event store.Event) error {
+	r.events <- event
+	return nil
+}
+
+// TestAlertRouting tests that events only reach the notifiers of their
+// route and that the decision is logged
+func TestAlertRouting(t *testing.T) {
+	routes, err := parseAlertRoutes([]byte("routes:\n  - name: payments\n    tags: [payments]\n    notify: [opsgenie, slack]\ndefault:\n  notify: [slack]\n  min_severity: critical\n"), []string{"slack", "opsgenie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(Config{AlertRoutes: routes}, store.NewMemoryStore())
+	slack := routeRecorder{label: "Slack", events: make(chan store.Event, 10)}
+	opsgenie := routeRecorder{label: "Opsgenie", events: make(chan store.Event, 10)}
+	checker.addNotifier(slack)
+	checker.addNotifier(opsgenie)
+	checker.setOptions(map[string]endpointOptions{"https://pay.example.com": {tags: []string{"payments"}}})
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	checker.enqueue([]store.Event{
+		{Endpoint: "https://pay.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown},
+		{Endpoint: "https://example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown},
+		{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning},
+	})
+
+	received := func(r routeRecorder) []string {
+		var got []string
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case event := <-r.events:
+				got = append(got, event.Endpoint+" "+event.Kind)
+			case <-timeout:
+				return got
+			}
+		}
+	}
+	if got, want := received(slack), []string{"https://pay.example.com status", "https://example.com status"}; !slices.Equal(got, want) {
+		t.Errorf("Slack received %q, want %q", got, want)
+	}
+	if got, want := received(opsgenie), []string{"https://pay.example.com status"}; !slices.Equal(got, want) {
+		t.Errorf("Opsgenie received %q, want %q", got, want)
+	}
+	for _, want := range []string{
+		"Routing status down event of https://pay.example.com by route payments to Slack, Opsgenie",
+		"Routing status down event of https://example.com by the default route to Slack",
+		"Routing cert warning event of https://example.com by the default route to no notifier, warning is below its min_severity critical",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs lack %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+// TestEscalationSteps tests parsing ALERT_ESCALATION
+func TestEscalationSteps(t *testing.T) {
+	steps, err := parseEscalation("15m:warning, 1h:Critical:opsgenie+Slack", []string{"slack", "opsgenie"})
+	if err != nil {
+		t.Fatalf("parseEscalation() error = %v", err)
+	}
+	want := []escalationStep{{After: 15 * time.Minute, Severity: severityWarning}, {After: time.Hour, Severity: severityCritical, Notify: []string{"opsgenie", "slack"}}}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("parseEscalation() = %+v, want %+v", steps, want)
+	}
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{"", "defines no steps"},
+		{"15m", `invalid ALERT_ESCALATION step "15m" (use after:severity`},
+		{"soon:warning", `"soon" is not a positive duration`},
+		{"15m:high", `unknown severity "high"`},
+		{"15m:warning:pager", `unknown notifier "pager"`},
+		{"15m:warning:email", "notifier email is not configured"},
+		{"1h:warning,15m:critical", "must come after 1h0m0s"},
+		{"15m:critical,1h:warning", "less severe than critical"},
+	}
+	for _, tt := range tests {
+		if _, err := parseEscalation(tt.value, []string{"slack", "opsgenie"}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseEscalation(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+		}
+	}
+}
+
+// TestEscalation tests escalating a lasting outage step by step to the
+// notifiers of each step, across a restart, and that the recovery reaches
+// them with the step reached
+func TestEscalation(t *testing.T) {
+	routes, err := parseAlertRoutes([]byte("default:\n  notify: [slack]\n"), []string{"slack", "opsgenie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		AlertCooldown:   10 * time.Minute,
+		AlertEscalation: []escalationStep{{After: 15 * time.Minute, Severity: severityWarning}, {After: time.Hour, Severity: severityCritical, Notify: []string{"opsgenie"}}},
+		AlertRoutes:     routes,
+	}
+	st := store.NewMemoryStore()
+	slack := routeRecorder{label: "Slack", events: make(chan store.Event, 10)}
+	opsgenie := routeRecorder{label: "Opsgenie", events: make(chan store.Event, 10)}
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	newChecker := func() *EndpointChecker {
+		checker := NewEndpointChecker(config, st)
+		checker.addNotifier(slack)
+		checker.addNotifier(opsgenie)
+		return checker
+	}
+	endpoint := "https://example.com"
+	check := func(checker *EndpointChecker, minutes, code int) {
+		at := start.Add(time.Duration(minutes) * time.Minute)
+		checker.alerts.now = func() time.Time { return at }
+		checker.saveResults("status", []store.Result{{Endpoint: endpoint, CheckedAt: at, HasStatus: true, StatusCode: code, Error: checkError(code, nil, at)}})
+	}
+
+	// received waits for n notifications of r
+	received := func(r routeRecorder, n int) []string {
+		var got []string
+		timeout := time.After(5 * time.Second)
+		for len(got) < n {
+			select {
+			case event := <-r.events:
+				got = append(got, describeEvent(event, event.Endpoint))
+			case <-timeout:
+				t.Fatalf("%s received %q, want %d notifications", r.label, got, n)
+			}
+		}
+		return got
+	}
+
+	checker := newChecker()
+	for _, minutes := range []int{0, 1, 10, 16, 61} {
+		code := 503
+		if minutes == 0 {
+			code = 200
+		}
+		check(checker, minutes, code)
+	}
+	if got, want := received(slack, 2), []string{"https://example.com is down", "https://example.com is still down after 15m (escalation step 1)"}; !slices.Equal(got, want) {
+		t.Errorf("Slack received %q, want %q", got, want)
+	}
+	if got, want := received(opsgenie, 1), []string{"https://example.com is still down after 1h (escalation step 2)"}; !slices.Equal(got, want) {
+		t.Errorf("Opsgenie received %q, want %q", got, want)
+	}
+
+	// A restart keeps the step reached and times the outage from the
+	// stored first failed check
+	restarted := newChecker()
+	restarted.alerts.load(checker.alerts.states)
+	check(restarted, 70, 503)
+	check(restarted, 80, 200)
+	recovered := []string{"https://example.com recovered after 1h19m down (5 failed checks, escalation step 2 reached)"}
+	if got := received(slack, 1); !slices.Equal(got, recovered) {
+		t.Errorf("Slack received %q, want %q", got, recovered)
+	}
+	if got := received(opsgenie, 1); !slices.Equal(got, recovered) {
+		t.Errorf("Opsgenie received %q, want %q", got, recovered)
+	}
+	select {
+	case event := <-slack.events:
+		t.Errorf("Slack received %+v, want no more notifications", event)
+	case event := <-opsgenie.events:
+		t.Errorf("Opsgenie received %+v, want no more notifications", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestDigestSchedule tests parsing schedules and finding their next time
+func TestDigestSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Wednesday
+	after := time.Date(2025, 10, 15, 9, 30, 0, 0, berlin)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"08:00", time.Date(2025, 10, 16, 8, 0, 0, 0, berlin)},
+		{"9:45", time.Date(2025, 10, 15, 9, 45, 0, 0, berlin)},
+		{"0 8 * * *", time.Date(2025, 10, 16, 8, 0, 0, 0, berlin)},
+		{"*/20 * * * *", time.Date(2025, 10, 15, 9, 40, 0, 0, berlin)},
+		{"0 8 * * 1-5", time.Date(2025, 10, 16, 8, 0, 0, 0, berlin)},
+		{"0 8 * * 0,6", time.Date(2025, 10, 18, 8, 0, 0, 0, berlin)},
+		{"30 7 * * 0", time.Date(2025, 10, 19, 7, 30, 0, 0, berlin)},
+		{"30 7 * * SUN", time.Date(2025, 10, 19, 7, 30, 0, 0, berlin)},
+		{"0 8 1 * *", time.Date(2025, 11, 1, 8, 0, 0, 0, berlin)},
+		{"0 8 1 * 5", time.Date(2025, 10, 17, 8, 0, 0, 0, berlin)},    // either day matches
+		{"0 8 */1 * 5", time.Date(2025, 10, 17, 8, 0, 0, 0, berlin)},  // every day of the month is *
+		{"0 8 1-31 * 5", time.Date(2025, 10, 16, 8, 0, 0, 0, berlin)}, // a range restricts, so any day matches
+		{"0 8 1 * */1", time.Date(2025, 11, 1, 8, 0, 0, 0, berlin)},   // every day of the week is *
+		{"0 8 */2 * 4", time.Date(2025, 10, 16, 8, 0, 0, 0, berlin)},  // odd days or Thursdays
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, berlin)},
+		{"0 2 26 10 *", time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC)}, // the first 02:00 of the night clocks go back
+	}
+	for _, tt := range tests {
+		schedule, err := parseDigestSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseDigestSchedule(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(after); !got.Equal(tt.want) {
+			t.Errorf("next of %q = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "8am", "0 8 * *", "60 8 * * *", "0 24 * * *", "0 8 0 * *", "0 8 * 13 *", "0 8 * * 7", "0 8-6 * * *", "*/0 8 * * *", "0 8 30 2 *"} {
+		if _, err := parseDigestSchedule(spec); err == nil {
+			t.Errorf("parseDigestSchedule(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestEndpointScheduleOption tests the schedule= and schedule_tz= options,
+// dropping the schedule when either is invalid
+func TestEndpointScheduleOption(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantZone string // "" for no schedule
+	}{
+		{`https://a.example.com schedule="*/5 8-18 * * 1-5" schedule_tz=Europe/Berlin`, "Europe/Berlin"},
+		{`https://a.example.com schedule_tz=America/New_York schedule="0 9 * * *"`, "America/New_York"},
+		{`https://a.example.com schedule="* 8-18 * * *"`, "UTC"},
+		{`https://a.example.com schedule="8-18 * * *"`, ""},
+		{`https://a.example.com schedule="0 25 * * *"`, ""},
+		{`https://a.example.com schedule="0 9 * * *" schedule_tz=Mars/Olympus`, ""},
+		{`https://a.example.com schedule_tz=Europe/Berlin`, ""},
+	}
+	for _, tt := range tests {
+		_, options := parseEndpointLine(tt.line)
+		switch {
+		case tt.wantZone == "" && options.schedule != nil:
+			t.Errorf("parseEndpointLine(%s) schedule = %+v, want none", tt.line, *options.schedule)
+		case tt.wantZone != "" && (options.schedule == nil || options.schedule.Location.String() != tt.wantZone):
+			t.Errorf("parseEndpointLine(%s) schedule = %+v, want one in %s", tt.line, options.schedule, tt.wantZone)
+		}
+	}
+}
+
+// TestEndpointScheduleFromFile tests that a schedule is kept for lines of
+// the endpoints file without any other option
+func TestEndpointScheduleFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints.lst")
+	content := `https://a.example.com schedule="*/5 8-18 * * 1-5"
+https://b.example.com schedule="0 9 * * *" schedule_tz=Europe/Berlin
+https://c.example.com
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(Config{EndpointsFile: path}, store.NewMemoryStore())
+	if _, err := checker.loadEndpoints(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		endpoint string
+		wantZone string // "" for no schedule
+	}{
+		{"https://a.example.com", "UTC"},
+		{"https://b.example.com", "Europe/Berlin"},
+		{"https://c.example.com", ""},
+	}
+	for _, tt := range tests {
+		schedule := checker.options[tt.endpoint].schedule
+		switch {
+		case tt.wantZone == "" && schedule != nil:
+			t.Errorf("schedule of %s = %+v, want none", tt.endpoint, *schedule)
+		case tt.wantZone != "" && (schedule == nil || schedule.Location.String() != tt.wantZone):
+			t.Errorf("schedule of %s = %+v, want one in %s", tt.endpoint, schedule, tt.wantZone)
+		}
+	}
+}
+
+// TestEndpointScheduleDue tests which cycles a schedule lets check the
+// endpoint, at the edges of its hours and days and across DST changes
+func TestEndpointScheduleDue(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	officeHours, err := parseCronSchedule("*/5 8-18 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daily, err := parseCronSchedule("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2025, month, day, hour, min, sec, 0, berlin)
+	}
+	utc := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2025, month, day, hour, min, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		cron       cron.Schedule
+		since, now time.Time
+		want       bool
+	}{
+		// Wednesday, October 15
+		{"on a step", officeHours, local(10, 15, 12, 4, 30), local(10, 15, 12, 5, 30), true},
+		{"between steps", officeHours, local(10, 15, 12, 5, 30), local(10, 15, 12, 6, 30), false},
+		{"step at the cycle start", officeHours, local(10, 15, 12, 4, 59), local(10, 15, 12, 5, 0), true},
+		{"step just before since", officeHours, local(10, 15, 12, 5, 0), local(10, 15, 12, 6, 0), false},
+		{"first of the day", officeHours, local(10, 15, 7, 59, 30), local(10, 15, 8, 0, 30), true},
+		{"before the hours", officeHours, local(10, 15, 7, 54, 30), local(10, 15, 7, 59, 30), false},
+		{"last of the day", officeHours, local(10, 15, 18, 54, 30), local(10, 15, 18, 55, 30), true},
+		{"after the hours", officeHours, local(10, 15, 18, 59, 30), local(10, 15, 19, 0, 30), false},
+		{"steps within a long cycle", officeHours, local(10, 15, 12, 1, 0), local(10, 15, 12, 4, 0), false},
+		{"weekend", officeHours, local(10, 17, 18, 59, 30), local(10, 20, 7, 59, 30), false},
+		{"monday morning", officeHours, local(10, 18, 10, 0, 0), local(10, 20, 8, 0, 30), true},
+		{"in the zone, not UTC", officeHours, utc(10, 15, 16, 59, 30), utc(10, 15, 17, 0, 30), false},
+		// Clocks go forward on March 30 and back on October 26: 08:00 in
+		// Berlin is 06:00 UTC in summer and 07:00 UTC in winter
+		{"08:00 after spring forward", daily, utc(3, 30, 5, 59, 30), utc(3, 30, 6, 0, 30), true},
+		{"07:00 UTC after spring forward", daily, utc(3, 30, 6, 59, 30), utc(3, 30, 7, 0, 30), false},
+		{"08:00 before spring forward", daily, utc(3, 29, 6, 59, 30), utc(3, 29, 7, 0, 30), true},
+		{"08:00 after fall back", daily, utc(10, 26, 6, 59, 30), utc(10, 26, 7, 0, 30), true},
+		{"06:00 UTC after fall back", daily, utc(10, 26, 5, 59, 30), utc(10, 26, 6, 0, 30), false},
+		{"office hours after fall back", officeHours, utc(10, 27, 6, 59, 30), utc(10, 27, 7, 0, 30), true},
+		{"no cycle", daily, local(10, 15, 8, 0, 0), local(10, 15, 8, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := endpointSchedule{Cron: tt.cron, Location: berlin}
+			if got := schedule.due(tt.since, tt.now); got != tt.want {
+				t.Errorf("due(%s, %s) = %v, want %v", tt.since, tt.now, got, tt.want)
+			}
+		})
+	}
+
+	var clock cycleClock
+	now := local(10, 15, 12, 5, 30)
+	if got := clock.scheduleSince(now, 30*time.Second); !got.Equal(now.Add(-time.Minute)) {
+		t.Errorf("scheduleSince() of the first cycle = %s, want a minute back", got)
+	}
+	if got := clock.scheduleSince(now, time.Hour); !got.Equal(now.Add(-time.Hour)) {
+		t.Errorf("scheduleSince() of the first cycle = %s, want an interval back", got)
+	}
+	clock.run(func() {})
+	if since := clock.scheduleSince(time.Now(), time.Hour); time.Since(since) > time.Minute {
+		t.Errorf("scheduleSince() = %s, want the start of the previous cycle", since)
+	}
+}
+
+// TestCheckAllStatusesSchedule tests that a status cycle checks the
+// endpoints due by their schedule and marks the others paused
+func TestCheckAllStatusesSchedule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	everyMinute, err := parseCronSchedule("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leapDay, err := parseCronSchedule("0 0 29 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(Config{StatusCheckInterval: time.Minute}, st)
+	unscheduled, due, paused := server.URL+"/", server.URL+"/due", server.URL+"/paused"
+	checker.setOptions(map[string]endpointOptions{
+		due:    {schedule: &endpointSchedule{Cron: everyMinute, Location: time.UTC}},
+		paused: {schedule: &endpointSchedule{Cron: leapDay, Location: time.UTC}},
+	})
+	checker.checkAllStatuses([]string{unscheduled, due, paused})
+
+	for _, tt := range []struct {
+		endpoint              string
+		wantStatus, wantPause bool
+	}{
+		{unscheduled, true, false},
+		{due, true, false},
+		{paused, false, true},
+	} {
+		data, err := st.GetEndpointData(context.Background(), tt.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.HasStatus != tt.wantStatus || data.PausedBySchedule != tt.wantPause {
+			t.Errorf("%s: HasStatus, PausedBySchedule = %v, %v; want %v, %v", tt.endpoint, data.HasStatus, data.PausedBySchedule, tt.wantStatus, tt.wantPause)
+		}
+	}
+}
+
+// TestCheckAllStatusesPaused tests that endpoints paused through the
+// dashboard are not checked (requires Redis running)
+func TestCheckAllStatusesPaused(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   15,
+	})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15, Storage: "redis", StatusCheckInterval: time.Minute}
+	st := mustRedisStore(t, config)
+	checker := NewEndpointChecker(config, st)
+	active, paused := server.URL+"/", server.URL+"/paused"
+	now := time.Now()
+	if err := st.PauseEndpoint(ctx, store.Pause{Endpoint: paused, At: now, Until: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	checker.checkAllStatuses([]string{active, paused})
+
+	for endpoint, want := range map[string]bool{active: true, paused: false} {
+		data, err := st.GetEndpointData(ctx, endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.HasStatus != want {
+			t.Errorf("%s: HasStatus = %v, want %v", endpoint, data.HasStatus, want)
+		}
+	}
+}
+
+// TestDigestConfig tests reading the DIGEST_ variables
+func TestDigestConfig(t *testing.T) {
+	configured := []string{"slack", "email", "opsgenie"}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		want     []string
+		wantZone string
+		wantErr  string
+	}{
+		{"disabled", nil, nil, "", ""},
+		{"defaults", map[string]string{"DIGEST_NOTIFY": "Slack, email"}, []string{"slack", "email"}, "UTC", ""},
+		{"zone", map[string]string{"DIGEST_NOTIFY": "slack", "DIGEST_SCHEDULE": "30 7 * * 1-5", "DIGEST_TIMEZONE": "Europe/Berlin"}, []string{"slack"}, "Europe/Berlin", ""},
+		{"unknown notifier", map[string]string{"DIGEST_NOTIFY": "sms"}, nil, "", `unknown notifier "sms"`},
+		{"unconfigured notifier", map[string]string{"DIGEST_NOTIFY": "telegram"}, nil, "", "telegram is not configured"},
+		{"opsgenie", map[string]string{"DIGEST_NOTIFY": "opsgenie"}, nil, "", "Opsgenie does not send digests"},
+		{"bad schedule", map[string]string{"DIGEST_NOTIFY": "slack", "DIGEST_SCHEDULE": "daily"}, nil, "", "DIGEST_SCHEDULE"},
+		{"bad zone", map[string]string{"DIGEST_NOTIFY": "slack", "DIGEST_TIMEZONE": "Mars/Olympus"}, nil, "", "DIGEST_TIMEZONE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DIGEST_NOTIFY", "DIGEST_SCHEDULE", "DIGEST_TIMEZONE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := loadDigestConfig(configured)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadDigestConfig() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadDigestConfig() error = %v", err)
+			}
+			if !slices.Equal(got.Notify, tt.want) {
+				t.Errorf("Notify = %q, want %q", got.Notify, tt.want)
+			}
+			if tt.wantZone != "" && got.Location.String() != tt.wantZone {
+				t.Errorf("Location = %s, want %s", got.Location, tt.wantZone)
+			}
+		})
+	}
+}
+
+// TestDailyDigest tests what the digest summarizes and how it reads
+func TestDailyDigest(t *testing.T) {
+	now := time.Date(2025, 10, 15, 8, 0, 0, 0, time.UTC)
+	data := []store.EndpointData{
+		{Endpoint: "https://a.example.com", HasStatus: true, StatusCode: 200, SSLExpiration: now.Add(90 * 24 * time.Hour), Uptime: []store.Uptime{{Window: "24h", Checks: 1440, Up: 1440}, {Window: "7d", Checks: 10080, Up: 10000}}},
+		{Endpoint: "https://b.example.com", HasStatus: true, StatusCode: 503, SSLExpiration: now.Add(-48 * time.Hour), Uptime: []store.Uptime{{Window: "24h", Checks: 1440, Up: 720}}},
+		{Endpoint: "https://c.example.com", HasStatus: true, StatusCode: 301, SSLExpiration: now.Add(12*24*time.Hour + time.Hour), InMaintenance: true, Uptime: []store.Uptime{{Window: "24h", Checks: 1440, Up: 1368}}},
+		{Endpoint: "http://d.example.com", HasStatus: true, StatusCode: 0},
+	}
+	acks := []store.Ack{{Endpoint: "https://b.example.com", Reason: "migrating", User: "alice", Until: now.Add(4 * time.Hour)}}
+	digest := newDailyDigest(data, acks, now)
+
+	if digest.title() != "Daily digest: 2 of 4 endpoints up" {
+		t.Errorf("title() = %q", digest.title())
+	}
+	want := `2 of 4 endpoints up, 2 down
+Uptime over the last 24 hours: 81.67% of 4320 checks
+
+Down (2):
+- http://d.example.com
+- https://b.example.com
+
+Below 100% uptime over the last 24 hours (2):
+- https://b.example.com 50.00%
+- https://c.example.com 95.00%
+
+Certificates expiring within 30 days (2):
+- https://b.example.com expired on 2025-10-13
+- https://c.example.com in 13 days, on 2025-10-27
+
+Acknowledged (1):
+- https://b.example.com until 2025-10-15 12:00 by alice: migrating
+
+In maintenance (1):
+- https://c.example.com`
+	if got := digest.text(); got != want {
+		t.Errorf("text() =\n%s\nwant\n%s", got, want)
+	}
+
+	var many []store.EndpointData
+	for i := range digestListLimit + 3 {
+		many = append(many, store.EndpointData{Endpoint: fmt.Sprintf("https://%02d.example.com", i), HasStatus: true, StatusCode: 500})
+	}
+	if text := newDailyDigest(many, nil, now).text(); !strings.Contains(text, "- https://09.example.com\n- and 3 more") {
+		t.Errorf("text() of %d endpoints down does not cap the list:\n%s", len(many), text)
+	}
+}
+
+// TestSendDailyDigest tests that the digest goes to the DIGEST_NOTIFY
+// notifiers only
+func TestSendDailyDigest(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	st.SaveResults(context.Background(), []store.Result{{Endpoint: "https://example.com", CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}})
+	config := Config{
+		SlackWebhookURL: server.URL + "/slack",
+		WebhookURL:      server.URL + "/webhook",
+		Digest:          digestConfig{Notify: []string{"webhook"}, Location: time.UTC},
+	}
+	checker := NewEndpointChecker(config, st)
+	if err := checker.sendDailyDigest(); err != nil {
+		t.Fatalf("sendDailyDigest() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := bodies["/slack"]; ok {
+		t.Error("the digest went to Slack, which DIGEST_NOTIFY leaves out")
+	}
+	var got struct {
+		Kind      string   `json:"kind"`
+		Title     string   `json:"title"`
+		Endpoints int      `json:"endpoints"`
+		Down      []string `json:"down"`
+	}
+	if err := json.Unmarshal(bodies["/webhook"], &got); err != nil {
+		t.Fatalf("webhook body %s: %v", bodies["/webhook"], err)
+	}
+	if got.Kind != "digest" || got.Title != "Daily digest: 1 of 1 endpoints up" || got.Endpoints != 1 || got.Down == nil {
+		t.Errorf("webhook body = %s", bodies["/webhook"])
+	}
+
+	checker = NewEndpointChecker(Config{SlackWebhookURL: server.URL + "/slack"}, st)
+	if err := checker.sendDailyDigest(); err == nil || !strings.Contains(err.Error(), "DIGEST_NOTIFY") {
+		t.Errorf("sendDailyDigest() without DIGEST_NOTIFY error = %v, want one naming it", err)
+	}
+}
+
+// TestMarkMaintenance tests that results checked during a maintenance
+// window of their endpoint or tag are stored and published marked
+// (requires Redis)
+func TestMarkMaintenance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15}
+	rs := mustRedisStore(t, config)
+	ctx := context.Background()
+	if err := rs.Ping(ctx); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: config.RedisAddr, DB: config.RedisDB})
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	if _, err := rs.AddMaintenanceWindow(ctx, store.MaintenanceWindow{Tag: "erp", Days: "*", Start: "00:00", Duration: "24h", Timezone: "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(config, rs)
+	checker.setOptions(map[string]endpointOptions{"https://erp.example.com": {tags: []string{"erp"}}})
+	now := time.Now().UTC()
+	checker.saveResults("status", []store.Result{
+		{Endpoint: "https://erp.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: []string{"erp"}},
+		{Endpoint: "https://web.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	checker.saveResults("status", []store.Result{
+		{Endpoint: "https://erp.example.com", CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 503, Tags: []string{"erp"}},
+		{Endpoint: "https://web.example.com", CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 503},
+	})
+
+	for endpoint, want := range map[string]bool{"https://erp.example.com": true, "https://web.example.com": false} {
+		if data, err := rs.GetEndpointData(ctx, endpoint); err != nil || data.InMaintenance != want {
+			t.Errorf("%s InMaintenance = %v, %v; want %v", endpoint, data.InMaintenance, err, want)
+		}
+	}
+	events, err := rs.Events(ctx, "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !events[0].InMaintenance || events[1].InMaintenance {
+		t.Errorf("events = %+v, want only the first in maintenance", events)
+	}
+}
+
+// TestSLOConfig tests reading SLO_TARGETS and the SLO policy
+func TestSLOConfig(t *testing.T) {
+	t.Setenv("SLO_TARGETS", "Payments=99.9; prod=99.5")
+	t.Setenv("SLO_WINDOW", "168h")
+	t.Setenv("SLO_EXCLUDE_UNKNOWN", "false")
+	var env store.Env
+	config := loadSLOConfig(&env, 30*time.Second)
+	if err := env.Err(); err != nil {
+		t.Fatalf("loadSLOConfig() error = %v", err)
+	}
+	wantTargets := []store.SLOTarget{{Tag: "payments", Objective: 99.9}, {Tag: "prod", Objective: 99.5}}
+	wantPolicy := store.SLOPolicy{Window: 168 * time.Hour, BurnWindow: time.Hour, BurnRateThreshold: 14.4, ExcludeMaintenance: true, ChecksPerHour: 120}
+	if !slices.Equal(config.Targets, wantTargets) || config.Policy != wantPolicy {
+		t.Errorf("loadSLOConfig() = %+v, want %+v and %+v", config, wantTargets, wantPolicy)
+	}
+
+	for _, tt := range []struct {
+		name, value, wantErr string
+	}{
+		{"SLO_TARGETS", "payments=100", "SLO_TARGETS entry of payments"},
+		{"SLO_TARGETS", "payments", "invalid SLO_TARGETS entry"},
+		{"SLO_TARGETS", "payments=99;payments=98", "tag payments has 2 objectives"},
+		{"SLO_WINDOW", "1000h", "invalid SLO_WINDOW"},
+		{"SLO_WINDOW", "90m", "invalid SLO_WINDOW"},
+		{"SLO_BURN_WINDOW", "200h", "invalid SLO_BURN_WINDOW"},
+		{"SLO_BURN_RATE_THRESHOLD", "-1", "invalid SLO_BURN_RATE_THRESHOLD"},
+	} {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			var env store.Env
+			loadSLOConfig(&env, time.Minute)
+			if err := env.Err(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadSLOConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSLOEvents tests that only SLOs starting or stopping to burn produce
+// events, and that a new SLO starts out ok
+func TestSLOEvents(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	previous := []store.SLOStatus{{Tag: "erp"}, {Tag: "payments", Burning: true}, {Tag: "prod"}}
+	current := []store.SLOStatus{{Tag: "erp", Burning: true, UpdatedAt: at}, {Tag: "payments", UpdatedAt: at}, {Tag: "prod", UpdatedAt: at}, {Tag: "web", Burning: true, UpdatedAt: at}}
+
+	var got []string
+	for _, event := range sloEvents(previous, current) {
+		if event.Kind != store.EventKindSLOBurn || !event.At.Equal(at) || store.SLOEndpoint(event.SLO.Tag) != event.Endpoint {
+			t.Errorf("event = %+v", event)
+		}
+		got = append(got, fmt.Sprintf("%s %s -> %s", event.Endpoint, event.Old, event.New))
+	}
+	want := []string{"slo:erp ok -> burning", "slo:payments burning -> ok", "slo:web ok -> burning"}
+	if !slices.Equal(got, want) {
+		t.Errorf("sloEvents() = %q, want %q", got, want)
+	}
+}
+
+// TestUpdateSLOs tests that the hourly rollup stores the SLOs of the
+// tagged endpoints and publishes their burn rate crossings, leaving out
+// checks in maintenance (requires Redis)
+func TestUpdateSLOs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15, SLO: sloConfig{
+		Targets: []store.SLOTarget{{Tag: "payments", Objective: 99}},
+		Policy:  store.SLOPolicy{Window: 24 * time.Hour, BurnWindow: time.Hour, BurnRateThreshold: 10, ExcludeMaintenance: true, ExcludeUnknown: true, ChecksPerHour: 60},
+	}}
+	rs := mustRedisStore(t, config)
+	ctx := context.Background()
+	if err := rs.Ping(ctx); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: config.RedisAddr, DB: config.RedisDB})
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	checker := NewEndpointChecker(config, rs)
+	checker.leading.Store(true)
+	endpoints := []string{"https://pay.example.com", "https://web.example.com"}
+	checker.setOptions(map[string]endpointOptions{endpoints[0]: {tags: []string{"payments"}}})
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	// Half the payments checks of the last hour fail, as do all checks of
+	// the untagged endpoint
+	for i, code := range []int{200, 503, 200, 503} {
+		rs.SaveResults(ctx, []store.Result{
+			{Endpoint: endpoints[0], CheckedAt: hour.Add(time.Duration(i+1) * 10 * time.Minute), HasStatus: true, StatusCode: code},
+			{Endpoint: endpoints[1], CheckedAt: hour.Add(time.Duration(i+1) * 10 * time.Minute), HasStatus: true, StatusCode: 503},
+		})
+	}
+
+	sloEvents := func() []store.StreamEvent {
+		t.Helper()
+		events, err := rs.Events(ctx, "", store.SLOEndpoint("payments"), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+	checker.rollupLatency(endpoints, hour.Add(90*time.Minute))
+	checker.rollupLatency(endpoints, hour.Add(90*time.Minute))
+	statuses, err := rs.SLOStatuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Endpoints != 1 || statuses[0].Checks != 4 || statuses[0].Good != 2 || !statuses[0].Burning {
+		t.Fatalf("SLOStatuses() = %+v, want payments burning", statuses)
+	}
+	if events := sloEvents(); len(events) != 1 || events[0].New != store.SLOStateBurning || events[0].SLO.Tag != "payments" {
+		t.Errorf("events = %+v, want one ok -> burning event, published once", events)
+	}
+
+	if _, err := rs.AddMaintenanceWindow(ctx, store.MaintenanceWindow{Tag: "payments", Days: "*", Start: "00:00", Duration: "24h", Timezone: "UTC"}); err != nil {
+		t.Fatal(err)
+	}
+	checker.rollupLatency(endpoints, hour.Add(90*time.Minute))
+	statuses, _ = rs.SLOStatuses(ctx)
+	if len(statuses) != 1 || statuses[0].Checks != 0 || statuses[0].MaintenanceChecks != 4 || statuses[0].Burning {
+		t.Errorf("SLOStatuses() in maintenance = %+v, want the checks left out", statuses)
+	}
+	if events := sloEvents(); len(events) != 2 || events[1].New != store.SLOStateOK {
+		t.Errorf("events = %+v, want a burning -> ok event", events)
+	}
+}
+
+// failingNotifier fails to send the events of one endpoint
+type failingNotifier struct {
+	endpoint string
+}
+
+func (failingNotifier) name() string { return "webhook" }
+
+func (n failingNotifier) send(ctx context.Context, event store.Event) error {
+	if event.Endpoint == n.endpoint {
+		return errors.New("server answered 503 Service Unavailable: ")
+	}
+	return nil
+}
+
+// TestAlertHistory tests that delivered and undelivered notifications are
+// recorded in the alert history with the route of their endpoint (requires
+// Redis)
+func TestAlertHistory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	config := Config{RedisAddr: "localhost:6379", RedisDB: 15}
+	rs := mustRedisStore(t, config)
+	ctx := context.Background()
+	if err := rs.Ping(ctx); err != nil {
+		t.Skip("Redis not available, skipping integration test")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: config.RedisAddr, DB: config.RedisDB})
+	defer rdb.Close()
+	rdb.FlushDB(ctx)
+	defer rdb.FlushDB(ctx)
+
+	routes, err := parseAlertRoutes([]byte("routes:\n  - name: payments\n    urls: [\"https://pay.example.com\"]\n    notify: [webhook]\n"), []string{"webhook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.AlertRoutes = routes
+	checker := NewEndpointChecker(config, rs)
+	checker.addNotifier(failingNotifier{endpoint: "https://web.example.com"})
+	checker.notifiers[0].retryDelay = time.Millisecond
+	now := time.Now().UTC()
+	checker.enqueue([]store.Event{
+		{Endpoint: "https://pay.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now},
+		{Endpoint: "https://web.example.com", Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: now},
+	})
+
+	var records []store.AlertRecord
+	for deadline := time.Now().Add(5 * time.Second); len(records) < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if records, err = rs.AlertHistory(ctx, "", time.Time{}, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(records) != 2 {
+		t.Fatalf("alert history = %+v, want 2 notifications", records)
+	}
+	failed, delivered := records[0], records[1]
+	if failed.Endpoint != "https://web.example.com" || failed.Delivered || failed.Attempts != notifyAttempts || !strings.Contains(failed.Error, "503") || failed.Route != "the default route" {
+		t.Errorf("failed notification = %+v", failed)
+	}
+	if delivered.Endpoint != "https://pay.example.com" || !delivered.Delivered || delivered.Attempts != 1 || delivered.Route != "route payments" || delivered.Notifier != "webhook" || delivered.New != store.StatusDown {
+		t.Errorf("delivered notification = %+v", delivered)
+	}
+}
+
+// TestWriteResults tests that results are written in batches with one retry
+func TestWriteResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   int
+		failures  int
+		wantCalls []int // batch size of each SaveResults call
+		wantSaved int
+	}{
+		{"empty cycle", 0, 0, nil, 0},
+		{"one partial batch", 3, 0, []int{3}, 3},
+		{"full and partial batch", writeBatchSize + 1, 0, []int{writeBatchSize, 1}, writeBatchSize + 1},
+		{"retried once", 3, 1, []int{3, 3}, 3},
+		{"given up after retry", 3, 2, []int{3, 3}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &flakyStore{MemoryStore: store.NewMemoryStore(), failures: tt.failures}
+			checker := NewEndpointChecker(Config{}, st)
+
+			results, written := checker.writeResults("status")
+			for i := 0; i < tt.results; i++ {
+				results <- store.Result{Endpoint: fmt.Sprintf("https://%d.example.com", i), CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}
+			}
+			close(results)
+			<-written
+
+			var sizes []int
+			for _, call := range st.calls {
+				sizes = append(sizes, len(call))
+			}
+			if !slices.Equal(sizes, tt.wantCalls) {
+				t.Errorf("SaveResults batch sizes = %v, want %v", sizes, tt.wantCalls)
+			}
+			if saved, _ := st.ListEndpoints(context.Background()); len(saved) != tt.wantSaved {
+				t.Errorf("saved %d endpoints, want %d", len(saved), tt.wantSaved)
+			}
+		})
+	}
+}
+
+// unresponsiveListener returns the address of a server that accepts
+// connections but never answers, like Redis behind a network partition
+func unresponsiveListener(t testing.TB) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return ln.Addr().String()
+}
+
+// TestStoreTimeout tests that a hung Redis fails each store call after
+// StoreTimeout instead of stalling the cycle
+func TestStoreTimeout(t *testing.T) {
+	config := Config{RedisAddr: unresponsiveListener(t), StoreTimeout: 100 * time.Millisecond}
+	st := mustRedisStore(t, config)
+	defer st.Close()
+	checker := NewEndpointChecker(config, st)
+
+	start := time.Now()
+	if err := checker.storeHTTPStatus("https://example.com", 200); err == nil {
+		t.Error("storeHTTPStatus() against a hung Redis succeeded")
+	}
+	results, written := checker.writeResults("status")
+	results <- store.Result{Endpoint: "https://example.com", CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}
+	close(results)
+	<-written
+
+	// One write, then a transition read and two write attempts for the batch
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("store calls took %s against a hung Redis, want about 4 x %s", elapsed, config.StoreTimeout)
+	}
+}
+
+// TestNotifyTest tests that a test event goes to the notifier or route it
+// names, or by its endpoint, is labeled as a test and reports failures
+func TestNotifyTest(t *testing.T) {
+	bodies := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer webhook.Close()
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer slack.Close()
+
+	routes, err := parseAlertRoutes([]byte("routes:\n  - name: payments\n    urls: [\"https://pay.example.com\"]\n    notify: [webhook]\ndefault:\n  notify: [slack]\n"), []string{"slack", "webhook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewEndpointChecker(Config{SlackWebhookURL: slack.URL, WebhookURL: webhook.URL, AlertRoutes: routes}, store.NewMemoryStore())
+	for _, q := range checker.notifiers {
+		q.retryDelay = time.Millisecond
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		route, endpoint string
+		want            []string // notifier: delivered
+		wantErr         string
+	}{
+		{"webhook", "", []string{"webhook: true"}, ""},
+		{"Slack", "", []string{"Slack: false"}, ""},
+		{"payments", "", []string{"webhook: true"}, ""},
+		{"default", "", []string{"Slack: false"}, ""},
+		{"", "https://pay.example.com", []string{"webhook: true"}, ""},
+		{"", "", []string{"Slack: false"}, ""},
+		{"telegram", "", nil, "notifier telegram is not configured"},
+		{"pagerduty", "", nil, `unknown route "pagerduty"`},
+	}
+	for _, tt := range tests {
+		results, err := checker.notifyTest(ctx, tt.route, tt.endpoint)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("notifyTest(%q, %q) error = %v, want %q", tt.route, tt.endpoint, err, tt.wantErr)
+			}
+			continue
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, fmt.Sprintf("%s: %v", result.Notifier, result.Delivered))
+			if !result.Delivered && (result.Attempts != notifyAttempts || !strings.Contains(result.Error, "403")) {
+				t.Errorf("notifyTest(%q, %q) failure = %+v, want %d attempts with the answer", tt.route, tt.endpoint, result, notifyAttempts)
+			}
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("notifyTest(%q, %q) = %v, %v; want %v", tt.route, tt.endpoint, got, err, tt.want)
+		}
+	}
+	for len(bodies) > 0 {
+		if body := <-bodies; !strings.Contains(body, `"test":true`) {
+			t.Errorf("webhook body %s is not marked as a test", body)
+		}
+	}
+
+	event := store.Event{Endpoint: notifyTestEndpoint, Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, Test: true}
+	if got, want := describeEvent(event, event.Endpoint), "[TEST] https://notify-test.example.com is down"; got != want {
+		t.Errorf("describeEvent of a test event = %q, want %q", got, want)
+	}
+
+	for target, want := range map[string]int{
+		"/api/notify-test?route=webhook": http.StatusOK,
+		"/api/notify-test?route=slack":   http.StatusBadGateway,
+		"/api/notify-test?route=sms":     http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		checker.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		if rec.Code != want {
+			t.Errorf("POST %s = %d %s, want %d", target, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+// TestAdminServer tests the admin health and readiness endpoints
+func TestAdminServer(t *testing.T) {
+	get := func(ec *EndpointChecker, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ec.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	checker := NewEndpointChecker(Config{Storage: "memory", EndpointsFile: "endpoints.lst"}, store.NewMemoryStore())
+	if rec := get(checker, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", rec.Code)
+	}
+	if rec := get(checker, "/version"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"version":"dev","commit":"unknown","build_date":"unknown","go_version":"go`) {
+		t.Errorf("/version = %d %s, want the default build information", rec.Code, rec.Body)
+	}
+	rec := get(checker, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"endpoints":"not loaded from endpoints.lst"`) {
+		t.Errorf("/readyz before loading endpoints = %d %s, want 503", rec.Code, rec.Body)
+	}
+	checker.endpointsLoaded.Store(true)
+	if rec := get(checker, "/readyz"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":"ready"`) {
+		t.Errorf("/readyz = %d %s, want 200", rec.Code, rec.Body)
+	}
+
+	// A hung Redis fails readiness within readyTimeout
+	config := Config{Storage: "redis", RedisAddr: unresponsiveListener(t)}
+	st := mustRedisStore(t, config)
+	defer st.Close()
+	hung := NewEndpointChecker(config, st)
+	hung.endpointsLoaded.Store(true)
+	start := time.Now()
+	rec = get(hung, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"storage":"redis: `) {
+		t.Errorf("/readyz with Redis hung = %d %s, want 503", rec.Code, rec.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 2*readyTimeout {
+		t.Errorf("/readyz with Redis hung took %s", elapsed)
+	}
+	if rec := get(hung, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz with Redis hung = %d, want 200", rec.Code)
+	}
+}
+
+// TestLoadConfig tests that invalid values fail loading, all reported at
+// once, rather than falling back to their defaults
+func TestLoadConfig(t *testing.T) {
+	config, err := LoadConfig(&store.Env{})
+	if err != nil {
+		t.Fatalf("LoadConfig(&store.Env{}) without variables = %v", err)
+	}
+	if config.StatusCheckInterval != time.Minute || config.SSLCheckInterval != time.Hour || config.Storage != "redis" || config.EndpointsSource != endpointsSourceFile ||
+		!slices.Equal(config.LatencyBuckets, store.DefaultLatencyBuckets) {
+		t.Errorf("LoadConfig(&store.Env{}) defaults = %+v", config)
+	}
+	if hostname, _ := os.Hostname(); hostname != "" && config.CheckerID != hostname {
+		t.Errorf("LoadConfig(&store.Env{}) CheckerID = %q, want the host name %q", config.CheckerID, hostname)
+	}
+
+	t.Setenv("STATUS_CHECK_INTERVAL", "5 minutes")
+	t.Setenv("SSL_CHECK_INTERVAL", "30s")
+	t.Setenv("REDIS_DB", "one")
+	t.Setenv("AUTO_CLEANUP", "sometimes")
+	t.Setenv("STORAGE", "mysql")
+	t.Setenv("LOG_OUTPUT", "stdout,journal")
+	t.Setenv("LATENCY_BUCKETS", "100ms,10ms")
+	t.Setenv("CAPTURE_HEADERS", "Server,Set-Cookie")
+	_, err = LoadConfig(&store.Env{})
+	if err == nil {
+		t.Fatal("LoadConfig(&store.Env{}) with invalid values succeeded")
+	}
+	for _, want := range []string{"STATUS_CHECK_INTERVAL", "SSL_CHECK_INTERVAL", "REDIS_DB", "AUTO_CLEANUP", "STORAGE", "LOG_OUTPUT", "LATENCY_BUCKETS", "CAPTURE_HEADERS"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadConfig(&store.Env{}) error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+// TestConfigValidate tests each rule of Config.validate
+func TestConfigValidate(t *testing.T) {
+	valid := Config{StatusCheckInterval: time.Minute, SSLCheckInterval: time.Hour, Storage: "redis", EndpointsSource: endpointsSourceFile}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"checker ID", func(c *Config) { c.CheckerID = "eu-west-1" }, ""},
+		{"checker ID with spaces", func(c *Config) { c.CheckerID = "eu west" }, "CHECKER_ID"},
+		{"zero status interval", func(c *Config) { c.StatusCheckInterval = 0 }, "STATUS_CHECK_INTERVAL"},
+		{"negative status interval", func(c *Config) { c.StatusCheckInterval = -time.Minute }, "STATUS_CHECK_INTERVAL"},
+		{"negative SSL interval", func(c *Config) { c.SSLCheckInterval = -time.Hour }, "SSL_CHECK_INTERVAL"},
+		{"SSL interval under a minute", func(c *Config) { c.SSLCheckInterval = 59 * time.Second }, "SSL_CHECK_INTERVAL"},
+		{"SSL interval of a minute", func(c *Config) { c.SSLCheckInterval = time.Minute }, ""},
+		{"unknown storage", func(c *Config) { c.Storage = "etcd" }, "unknown STORAGE"},
+		{"memory", func(c *Config) { c.Storage = "memory" }, ""},
+		{"postgres without URL", func(c *Config) { c.Storage = "postgres" }, "DATABASE_URL"},
+		{"postgres", func(c *Config) { c.Storage, c.DatabaseURL = "postgres", "postgres://localhost/certs" }, ""},
+		{"unknown endpoints source", func(c *Config) { c.EndpointsSource = "consul" }, "unknown ENDPOINTS_SOURCE"},
+		{"registry without Redis", func(c *Config) {
+			c.Storage, c.DatabaseURL, c.EndpointsSource = "postgres", "postgres://localhost/certs", endpointsSourceRedis
+		}, "requires Redis storage"},
+		{"SLOs without Redis", func(c *Config) {
+			c.Storage, c.SLO.Targets = "memory", []store.SLOTarget{{Tag: "payments", Objective: 99.9}}
+		}, "SLO_TARGETS requires Redis storage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.change(&config)
+			problems := config.validate()
+			if tt.wantErr == "" {
+				if len(problems) > 0 {
+					t.Errorf("validate() = %v, want no problems", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Error(), tt.wantErr) {
+				t.Errorf("validate() = %v, want one problem about %s", problems, tt.wantErr)
+			}
+		})
+	}
+
+	// Every problem is returned, not only the first
+	invalid := Config{Storage: "sqlite", EndpointsSource: "consul"}
+	if problems := invalid.validate(); len(problems) != 4 {
+		t.Errorf("validate() of four invalid settings = %v", problems)
+	}
+}
+
+// TestCheckEndpointList tests that an empty endpoints file is reported,
+// and that the checker only refuses to start on it under CONFIG_STRICT
+func TestCheckEndpointList(t *testing.T) {
+	config := Config{EndpointsSource: endpointsSourceFile, EndpointsFile: "endpoints.lst"}
+	if err := config.checkEndpointList([]string{"https://example.com"}); err != nil {
+		t.Errorf("checkEndpointList() of one endpoint = %v", err)
+	}
+	if err := config.checkEndpointList(nil); err == nil || !strings.Contains(err.Error(), "endpoints.lst") {
+		t.Errorf("checkEndpointList() of no endpoints = %v, want an error naming the file", err)
+	}
+	registry := Config{EndpointsSource: endpointsSourceRedis}
+	if err := registry.checkEndpointList(nil); err != nil {
+		t.Errorf("checkEndpointList() of an empty registry = %v, want nil", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "endpoints.lst")
+	if err := os.WriteFile(empty, []byte("# nothing yet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	strict := Config{Storage: "memory", EndpointsSource: endpointsSourceFile, EndpointsFile: empty, StrictConfig: true}
+	if err := NewEndpointChecker(strict, store.NewMemoryStore()).Start(); err == nil || !strings.Contains(err.Error(), "no endpoints to check") {
+		t.Errorf("Start() under CONFIG_STRICT without endpoints = %v, want an error", err)
+	}
+}
+
+// TestStartStop tests that Stop makes Start return once the first check
+// cycle is done
+func TestStartStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "endpoints.lst")
+	if err := os.WriteFile(file, []byte(server.URL+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{
+		Storage:             "memory",
+		EndpointsSource:     endpointsSourceFile,
+		EndpointsFile:       file,
+		StatusCheckInterval: time.Hour,
+		SSLCheckInterval:    time.Hour,
+	}
+	st := store.NewMemoryStore()
+	checker := NewEndpointChecker(config, st)
+	started := make(chan error, 1)
+	go func() { started <- checker.Start() }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, _ := st.GetEndpointData(context.Background(), server.URL); data.HasStatus {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no status saved by the first cycle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-checker.Ready():
+	default:
+		t.Error("Ready() not closed after the first cycle")
+	}
+	checker.Stop()
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Errorf("Start() after Stop = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() did not return after Stop")
+	}
+}
+
+// TestHealthy tests that a check cycle running past its interval, and at
+// least minStalledCycle, makes the checker unhealthy
+func TestHealthy(t *testing.T) {
+	checker := NewEndpointChecker(Config{StatusCheckInterval: time.Minute, SSLCheckInterval: time.Hour}, store.NewMemoryStore())
+	if err := checker.Healthy(); err != nil {
+		t.Errorf("Healthy() between cycles = %v", err)
+	}
+	checker.statusCycle.started.Store(time.Now().Add(-4 * time.Minute).UnixNano())
+	if err := checker.Healthy(); err != nil {
+		t.Errorf("Healthy() within minStalledCycle = %v", err)
+	}
+	checker.statusCycle.started.Store(time.Now().Add(-6 * time.Minute).UnixNano())
+	if err := checker.Healthy(); err == nil || !strings.Contains(err.Error(), "status check cycle") {
+		t.Errorf("Healthy() with a stalled status cycle = %v, want an error", err)
+	}
+	checker.statusCycle.started.Store(0)
+	checker.sslCycle.started.Store(time.Now().Add(-30 * time.Minute).UnixNano())
+	if err := checker.Healthy(); err != nil {
+		t.Errorf("Healthy() within the SSL interval = %v", err)
+	}
+	checker.sslCycle.run(func() {})
+	if checker.sslCycle.started.Load() != 0 {
+		t.Error("cycle still running after run()")
+	}
+}
+
+// TestConfigFile tests reading the settings of --config, overridden by the
+// environment, and printing the effective configuration
+func TestConfigFile(t *testing.T) {
+	for _, key := range ConfigKeys {
+		t.Setenv(key.Env, "")
+	}
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T0/B0/from-env")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+redis:
+  addr: redis:6379
+  db: 3
+checker:
+  status_check_interval: 30s
+  ssl_check_interval: 2h
+  endpoints:
+    file: /etc/certs-n-status/endpoints.lst
+  audit_headers:
+    - Strict-Transport-Security
+    - X-Frame-Options
+  slack:
+    webhook_url: https://hooks.slack.com/services/T0/B0/from-file
+  alerts:
+    cooldown: 5m
+dashboard:
+  server_port: 8081
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ApplyConfigFile(path, ConfigKeys, "dashboard"); err != nil {
+		t.Fatal(err)
+	}
+	var env store.Env
+	config, err := LoadConfig(&env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.RedisAddr != "redis:6379" || config.RedisDB != 3 || config.StatusCheckInterval != 30*time.Second || config.SSLCheckInterval != 2*time.Hour ||
+		config.EndpointsFile != "/etc/certs-n-status/endpoints.lst" || !slices.Equal(config.AuditHeaders, []string{"Strict-Transport-Security", "X-Frame-Options"}) ||
+		config.AlertCooldown != 5*time.Minute {
+		t.Errorf("LoadConfig() from the file = %+v", config)
+	}
+	if config.SlackWebhookURL != "https://hooks.slack.com/services/T0/B0/from-env" {
+		t.Errorf("SlackWebhookURL = %q, want the environment's", config.SlackWebhookURL)
+	}
+
+	var out bytes.Buffer
+	if err := env.WriteConfig(&out, ConfigKeys); err != nil {
+		t.Fatal(err)
+	}
+	printed := out.String()
+	for _, want := range []string{"  status_check_interval: 30s\n", "  ssl_check_interval: 2h0m0s\n", "  result_ttl: \"10\"\n", "    webhook_url: <redacted>\n"} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("printed configuration lacks %q:\n%s", want, printed)
+		}
+	}
+	if strings.Contains(printed, "from-env") {
+		t.Errorf("printed configuration holds a secret:\n%s", printed)
+	}
+
+	// A key the checker does not know is an error, not ignored
+	if err := os.WriteFile(path, []byte("checker:\n  status_check_intervall: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ApplyConfigFile(path, ConfigKeys, "dashboard"); err == nil || !strings.Contains(err.Error(), "unknown key checker.status_check_intervall") {
+		t.Errorf("ApplyConfigFile() with a typo = %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package checker
+
+import (
+	"log"
+
+	"certs-n-status/store"
+)
+
+// markMaintenance marks the results checked during one of the stored
+// maintenance windows, with the tags of the endpoints file. When the
+// windows cannot be read the results stay unmarked, so a failure alerts
+// rather than going unnoticed.
+func (ec *EndpointChecker) markMaintenance(results []store.Result) {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	windows, err := ec.store.MaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read maintenance windows, saving %d results unmarked: %v", len(results), err)
+		return
+	}
+	if len(windows) == 0 {
+		return
+	}
+	for i := range results {
+		result := &results[i]
+		result.InMaintenance = store.InMaintenance(windows, result.Endpoint, ec.endpointTags(result.Endpoint), result.CheckedAt)
+	}
+}
+
+// maintenanceWindows returns the stored maintenance windows, none when they
+// cannot be read
+func (ec *EndpointChecker) maintenanceWindows() []store.MaintenanceWindow {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	windows, err := ec.store.MaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read maintenance windows, rolling up without them: %v", err)
+		return nil
+	}
+	return windows
+}
@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"certs-n-status/store"
+)
+
+// migrateRedis brings the Redis layout up to store.CurrentSchemaVersion,
+// logging every step. It refuses data written by a newer release.
+func migrateRedis(ctx context.Context, rs *store.RedisStore, dryRun bool) error {
+	steps, err := rs.Migrate(ctx, dryRun)
+	for _, step := range steps {
+		if dryRun {
+			log.Printf("[INFO] Dry run: schema version %d would %s (%d endpoints)", step.Version, step.Description, step.Changed)
+		} else {
+			log.Printf("[INFO] Migrated to schema version %d: %s (%d endpoints)", step.Version, step.Description, step.Changed)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate Redis schema: %w", err)
+	}
+	if len(steps) == 0 {
+		log.Printf("[INFO] Redis schema is at version %d, nothing to migrate", store.CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// runMigrate implements `endpoint-checker migrate [-dry-run]`
+func runMigrate(config Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report the pending migrations without applying them")
+	fs.Parse(args)
+
+	if config.Storage != "redis" {
+		return fmt.Errorf("migrate is only supported for Redis storage, not %s (PostgreSQL migrates at startup)", config.Storage)
+	}
+	st, err := newRedisStore(config)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	if err := st.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return migrateRedis(ctx, st, *dryRun)
+}
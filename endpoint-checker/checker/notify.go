@@ -0,0 +1,538 @@
+package checker
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+const (
+	// notifyQueueSize is how many events wait for a notifier before new
+	// ones are dropped, so a notifier that is down never holds up a cycle
+	notifyQueueSize = 100
+	// notifyAttempts is how often an event is sent before it is given up
+	notifyAttempts = 3
+	// notifyRetryDelay is the wait before the first retry, doubling with
+	// every further one
+	notifyRetryDelay = 5 * time.Second
+)
+
+// notifier delivers a state change to people, such as a chat channel
+type notifier interface {
+	name() string
+	send(ctx context.Context, event store.Event) error
+}
+
+// eventChooser is a notifier that chooses its events itself instead of
+// taking the notable ones
+type eventChooser interface {
+	wants(event store.Event) bool
+}
+
+// notable reports whether an event is worth telling someone about: an
+// endpoint going down or recovering, and a certificate entering the warning
+// or critical window, expiring, being valid again after a renewal, or being
+// replaced by another certificate, and an SLO starting or stopping to burn
+// its error budget. Events of acknowledged endpoints or during maintenance
+// are not.
+func notable(event store.Event) bool {
+	if event.Acknowledged || event.InMaintenance {
+		return false
+	}
+	switch event.Kind {
+	case store.EventKindStatus, store.EventKindCertChanged, store.EventKindSLOBurn:
+		return true
+	case store.EventKindCert:
+		return store.CertLevelRank(event.New) > store.CertLevelRank(event.Old) || event.New == store.CertLevelOK
+	}
+	return false
+}
+
+// describeEvent says what happened in a notable event, with the endpoint
+// written as endpoint so notifiers can apply their own markup. A test
+// event says so first.
+func describeEvent(event store.Event, endpoint string) string {
+	if event.Test {
+		event.Test = false
+		return "[TEST] " + describeEvent(event, endpoint)
+	}
+	switch event.Kind {
+	case store.EventKindStatus:
+		switch {
+		case event.Old == store.StatusDown && event.New == store.StatusDown && event.Escalation > 0:
+			return fmt.Sprintf("%s is still down after %s (escalation step %d)", endpoint, formatDuration(event.At.Sub(event.DownSince)), event.Escalation)
+		case event.Old == store.StatusDown && event.New == store.StatusDown && !event.DownSince.IsZero():
+			return fmt.Sprintf("%s is still down after %s", endpoint, formatDuration(event.At.Sub(event.DownSince)))
+		case event.New == store.StatusDown:
+			return endpoint + " is down"
+		case !event.DownSince.IsZero() && event.Escalation > 0:
+			return fmt.Sprintf("%s recovered after %s down (%s, escalation step %d reached)", endpoint, formatDuration(event.At.Sub(event.DownSince)), failedChecks(event.FailedChecks), event.Escalation)
+		case !event.DownSince.IsZero():
+			return fmt.Sprintf("%s recovered after %s down (%s)", endpoint, formatDuration(event.At.Sub(event.DownSince)), failedChecks(event.FailedChecks))
+		case event.Escalation > 0:
+			return fmt.Sprintf("%s recovered (escalation step %d reached)", endpoint, event.Escalation)
+		}
+		return endpoint + " recovered"
+	case store.EventKindCert:
+		switch event.New {
+		case store.CertLevelWarning:
+			return fmt.Sprintf("Certificate of %s expires within %d days", endpoint, int(store.CertWarningWindow.Hours()/24))
+		case store.CertLevelCritical:
+			return fmt.Sprintf("Certificate of %s expires within %d days", endpoint, int(store.CertCriticalWindow.Hours()/24))
+		case store.CertLevelExpired:
+			return fmt.Sprintf("Certificate of %s expired", endpoint)
+		case store.CertLevelOK:
+			if !event.NotAfter.IsZero() {
+				return fmt.Sprintf("Certificate of %s is valid again, until %s", endpoint, event.NotAfter.UTC().Format(time.DateOnly))
+			}
+			return fmt.Sprintf("Certificate of %s is valid again", endpoint)
+		}
+	case store.EventKindCertChanged:
+		change := event.CertChange
+		switch {
+		case change.Assess() == store.CertChangeUnexpectedIssuer:
+			return fmt.Sprintf("Certificate of %s is issued by %s, not the expected %s", endpoint, issuerName(change.NewIssuer), change.ExpectedIssuer)
+		case change.OldIssuer != "" && change.NewIssuer != change.OldIssuer:
+			return fmt.Sprintf("Certificate of %s changed issuer from %s to %s", endpoint, issuerName(change.OldIssuer), issuerName(change.NewIssuer))
+		case change.NewNotAfter.Before(change.OldNotAfter):
+			return fmt.Sprintf("Certificate of %s was replaced with one expiring sooner, on %s", endpoint, change.NewNotAfter.UTC().Format(time.DateOnly))
+		case change.NewNotAfter.After(change.OldNotAfter):
+			return fmt.Sprintf("Certificate of %s was renewed, valid until %s", endpoint, change.NewNotAfter.UTC().Format(time.DateOnly))
+		}
+		return fmt.Sprintf("Certificate of %s was reissued", endpoint)
+	case store.EventKindSLOBurn:
+		slo := event.SLO
+		if event.New == store.SLOStateBurning {
+			return fmt.Sprintf("%s (%s over %s) is burning its error budget at %.1fx the sustainable rate, %.1f%% left", endpoint, formatObjective(slo.Objective), slo.Window, slo.BurnRate, slo.BudgetRemaining*100)
+		}
+		return fmt.Sprintf("%s (%s over %s) is no longer burning its error budget fast, %.1f%% left", endpoint, formatObjective(slo.Objective), slo.Window, slo.BudgetRemaining*100)
+	}
+	return endpoint + " changed"
+}
+
+// issuerName shortens an issuer's distinguished name, such as
+// "CN=R3,O=Let's Encrypt,C=US", to its organization, or its common name
+// without one
+func issuerName(issuer string) string {
+	var commonName string
+	for _, attribute := range splitDistinguishedName(issuer) {
+		switch {
+		case strings.HasPrefix(attribute, "O="):
+			return strings.ReplaceAll(attribute[len("O="):], `\`, "")
+		case strings.HasPrefix(attribute, "CN="):
+			commonName = strings.ReplaceAll(attribute[len("CN="):], `\`, "")
+		}
+	}
+	if commonName != "" {
+		return commonName
+	}
+	return issuer
+}
+
+// splitDistinguishedName splits a distinguished name at the commas that
+// are not escaped with a backslash
+func splitDistinguishedName(name string) []string {
+	var attributes []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '\\':
+			i++
+		case ',':
+			attributes = append(attributes, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(attributes, name[start:])
+}
+
+// certChangeDetails lists what changed about the certificate of a
+// cert_changed event, for the details of a notification
+func certChangeDetails(change store.CertChange) []string {
+	var details []string
+	if change.OldIssuer != change.NewIssuer {
+		details = append(details, fmt.Sprintf("issuer: %s → %s", issuerName(change.OldIssuer), issuerName(change.NewIssuer)))
+	} else if change.NewIssuer != "" {
+		details = append(details, "issuer: "+issuerName(change.NewIssuer))
+	}
+	if change.OldSerial != change.NewSerial {
+		details = append(details, fmt.Sprintf("serial: %s → %s", change.OldSerial, change.NewSerial))
+	}
+	return append(details, fmt.Sprintf("expires: %s → %s", change.OldNotAfter.UTC().Format(time.DateOnly), change.NewNotAfter.UTC().Format(time.DateOnly)))
+}
+
+// failedChecks counts the failed checks of an outage
+func failedChecks(n int) string {
+	if n == 1 {
+		return "1 failed check"
+	}
+	return fmt.Sprintf("%d failed checks", n)
+}
+
+// dashboardLink is the dashboard at dashboardURL, without a trailing
+// slash, showing endpoint, or the endpoints of the tag of an SLOEndpoint
+func dashboardLink(dashboardURL, endpoint string) string {
+	if tag, ok := store.SLOEndpointTag(endpoint); ok {
+		return dashboardURL + "/?tag=" + url.QueryEscape(tag)
+	}
+	return dashboardURL + "/?q=" + url.QueryEscape(endpoint)
+}
+
+// postJSON posts body to target with headers and, when the answer is not
+// 2xx, fails with its status and the start of its body
+func postJSON(ctx context.Context, client *http.Client, target string, body []byte, headers http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+	return nil
+}
+
+// routeByTag returns the recipients byTag gives the tags of an endpoint,
+// without repeats, or fallback when none of its tags has any
+func routeByTag(tags []string, byTag map[string][]string, fallback []string) []string {
+	var to []string
+	for _, tag := range tags {
+		for _, recipient := range byTag[tag] {
+			if !slices.Contains(to, recipient) {
+				to = append(to, recipient)
+			}
+		}
+	}
+	if len(to) == 0 {
+		return fallback
+	}
+	return to
+}
+
+// allRecipients returns fallback and every recipient of byTag, without
+// repeats, for messages about no endpoint in particular
+func allRecipients(fallback []string, byTag map[string][]string) []string {
+	to := slices.Clone(fallback)
+	for _, recipients := range byTag {
+		for _, recipient := range recipients {
+			if !slices.Contains(to, recipient) {
+				to = append(to, recipient)
+			}
+		}
+	}
+	return to
+}
+
+// parseTagRoutes parses the variable name, semicolon-separated tag=list
+// pairs such as "payments=a,b;erp=c", splitting each list with parseList.
+// usage shows one pair in errors.
+func parseTagRoutes(name, value, usage string, parseList func(string) ([]string, error)) (map[string][]string, error) {
+	routes := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		tag, list, ok := strings.Cut(entry, "=")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !ok || !validTag(tag) {
+			return nil, fmt.Errorf("invalid %s entry %q (use %s)", name, strings.TrimSpace(entry), usage)
+		}
+		recipients, err := parseList(list)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry of %s: %w", name, tag, err)
+		}
+		routes[tag] = append(routes[tag], recipients...)
+	}
+	return routes, nil
+}
+
+// notifyDigestWindow is the period digestNotifier thresholds count events in
+const notifyDigestWindow = time.Minute
+
+// digestNotifier is a notifier that combines events into one digest once
+// more than digestThreshold of them come within notifyDigestWindow, so an
+// outage of many endpoints does not flood its recipients
+type digestNotifier interface {
+	notifier
+	digestThreshold() int
+	sendDigest(ctx context.Context, events []store.Event) error
+}
+
+// notifyQueue hands events to a notifier from its own goroutine, retrying
+// failed sends, so the check cycle that produced them never waits for it
+type notifyQueue struct {
+	notifier     notifier
+	events       chan store.Event
+	retryDelay   time.Duration
+	digestWindow time.Duration
+	giveUp       func(store.DeadLetter) // receives the events given up on; nil only logs them
+	// delivered receives every delivery, or final failure, with the number
+	// of attempts it took; nil records nothing
+	delivered func(events []store.Event, err error, attempts int)
+}
+
+// newNotifyQueue starts delivering events to n until ctx is done
+func newNotifyQueue(ctx context.Context, n notifier, giveUp func(store.DeadLetter)) *notifyQueue {
+	q := &notifyQueue{
+		notifier:     n,
+		events:       make(chan store.Event, notifyQueueSize),
+		retryDelay:   notifyRetryDelay,
+		digestWindow: notifyDigestWindow,
+		giveUp:       giveUp,
+	}
+	go q.run(ctx)
+	return q
+}
+
+// wants reports whether the notifier takes event
+func (q *notifyQueue) wants(event store.Event) bool {
+	if chooser, ok := q.notifier.(eventChooser); ok {
+		return chooser.wants(event)
+	}
+	return notable(event)
+}
+
+// enqueue queues an event without blocking, dropping it when the queue is full
+func (q *notifyQueue) enqueue(event store.Event) {
+	select {
+	case q.events <- event:
+	default:
+		log.Printf("[WARN] %s queue is full, dropping %s %s event of %s", q.notifier.name(), event.Kind, event.New, event.Endpoint)
+	}
+}
+
+// run delivers queued events one by one. For a digestNotifier, events
+// beyond its threshold within the digest window are held back and sent
+// together as one digest when the window has passed.
+func (q *notifyQueue) run(ctx context.Context) {
+	digester, digests := q.notifier.(digestNotifier)
+	var (
+		sent    []time.Time // of the messages within the digest window
+		pending []store.Event
+		flush   <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-q.events:
+			if !digests {
+				q.deliver(ctx, []store.Event{event})
+				continue
+			}
+			now := time.Now()
+			sent = slices.DeleteFunc(sent, func(at time.Time) bool { return now.Sub(at) >= q.digestWindow })
+			if len(pending) > 0 || len(sent) >= digester.digestThreshold() {
+				if len(pending) == 0 {
+					flush = time.After(q.digestWindow)
+				}
+				pending = append(pending, event)
+				continue
+			}
+			sent = append(sent, now)
+			q.deliver(ctx, []store.Event{event})
+		case <-flush:
+			sent = append(sent, time.Now())
+			q.deliver(ctx, pending)
+			pending, flush = nil, nil
+		}
+	}
+}
+
+// deliver sends one event, or several as a digest, making up to
+// notifyAttempts attempts with a doubling delay between them, and hands
+// each event to giveUp when all fail
+func (q *notifyQueue) deliver(ctx context.Context, events []store.Event) {
+	what := fmt.Sprintf("%s %s event of %s", events[0].Kind, events[0].New, events[0].Endpoint)
+	send := func() error { return q.notifier.send(ctx, events[0]) }
+	if len(events) > 1 {
+		what = fmt.Sprintf("digest of %d events", len(events))
+		send = func() error { return q.notifier.(digestNotifier).sendDigest(ctx, events) }
+	}
+
+	attempts, err := q.sendWithRetries(ctx, what, send)
+	if err != nil && attempts < notifyAttempts {
+		return // ctx is done
+	}
+	if q.delivered != nil {
+		q.delivered(events, err, attempts)
+	}
+	if err != nil && q.giveUp != nil {
+		for _, event := range events {
+			q.giveUp(store.DeadLetter{Notifier: q.notifier.name(), Event: event, Error: err.Error(), Attempts: attempts, At: time.Now().UTC()})
+		}
+	}
+}
+
+// sendWithRetries calls send up to notifyAttempts times, waiting a
+// doubling delay between attempts, and returns the number of attempts
+// made with the error of the last, nil once one succeeds. It stops early
+// with the error of ctx when ctx is done.
+func (q *notifyQueue) sendWithRetries(ctx context.Context, what string, send func() error) (int, error) {
+	delay := q.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt == notifyAttempts {
+			log.Printf("[ERROR] Failed to send %s to %s after %d attempts: %v", what, q.notifier.name(), attempt, err)
+			return attempt, err
+		}
+		log.Printf("[WARN] Failed to send %s to %s, retrying in %s: %v", what, q.notifier.name(), delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return attempt, err
+		}
+		delay *= 2
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// addNotifier starts delivering notable events to n; with Redis storage
+// the events it gives up on are kept in the store.DeadLetterKey list, and
+// every notification in the store.AlertHistoryKey stream
+func (ec *EndpointChecker) addNotifier(n notifier) {
+	q := newNotifyQueue(ec.ctx, n, ec.saveDeadLetter)
+	q.delivered = func(events []store.Event, err error, attempts int) {
+		ec.saveAlertRecords(n.name(), events, err, attempts)
+	}
+	ec.notifiers = append(ec.notifiers, q)
+}
+
+// saveAlertRecords records the notification of events by the notifier
+// name, which failed with err after its last attempt unless err is nil
+func (ec *EndpointChecker) saveAlertRecords(name string, events []store.Event, err error, attempts int) {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok {
+		return
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	now := time.Now().UTC()
+	for _, event := range events {
+		record := store.AlertRecord{
+			Endpoint:  event.Endpoint,
+			Kind:      event.Kind,
+			Old:       event.Old,
+			New:       event.New,
+			Notifier:  name,
+			Delivered: err == nil,
+			Attempts:  attempts,
+			At:        now,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if ec.config.AlertRoutes != nil {
+			if route := ec.config.AlertRoutes.route(event.Endpoint, ec.endpointTags(event.Endpoint)); route != nil {
+				record.Route = route.label
+			}
+		}
+		if err := rs.AddAlertRecord(ctx, record); err != nil {
+			log.Printf("[WARN] Failed to record %s notification for %s in the alert history: %v", name, event.Endpoint, err)
+		}
+	}
+}
+
+// saveDeadLetter records a notification that could not be delivered, for
+// inspection with LRANGE
+func (ec *EndpointChecker) saveDeadLetter(letter store.DeadLetter) {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok {
+		return
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	if err := rs.AddDeadLetter(ctx, letter); err != nil {
+		log.Printf("[WARN] Failed to record undelivered %s notification for %s: %v", letter.Notifier, letter.Event.Endpoint, err)
+	}
+}
+
+// notify passes the events through the alert gate, when there is one,
+// and queues those it lets through
+func (ec *EndpointChecker) notify(events []store.Event) {
+	if ec.alerts != nil && len(ec.notifiers) > 0 {
+		var changed map[string]store.AlertState
+		events, changed = ec.alerts.filter(events)
+		ec.saveAlertStates(changed)
+	}
+	ec.enqueue(events)
+}
+
+// enqueue queues the events with their recipients, logging where the
+// route of their endpoint sent them. Events not stamped yet, such as
+// reminders, are stamped with the CHECKER_ID.
+func (ec *EndpointChecker) enqueue(events []store.Event) {
+	for _, event := range events {
+		event.CheckedBy = cmp.Or(event.CheckedBy, ec.config.CheckerID)
+		route, severity, queues, wanted := ec.recipients(event)
+		var to []string
+		for _, q := range queues {
+			q.enqueue(event)
+			to = append(to, q.notifier.name())
+		}
+		if route == nil || !wanted {
+			continue
+		}
+		switch {
+		case len(to) > 0:
+			log.Printf("[INFO] Routing %s %s event of %s by %s to %s", event.Kind, event.New, event.Endpoint, route.label, strings.Join(to, ", "))
+		case !route.severe(severity):
+			log.Printf("[INFO] Routing %s %s event of %s by %s to no notifier, %s is below its min_severity %s", event.Kind, event.New, event.Endpoint, route.label, severity, route.MinSeverity)
+		default:
+			log.Printf("[INFO] Routing %s %s event of %s by %s to no notifier, none of its notifiers takes it", event.Kind, event.New, event.Endpoint, route.label)
+		}
+	}
+}
+
+// recipients returns the queues of the configured notifiers that want
+// event and, with ALERT_ROUTES_FILE, that the route of its endpoint sends
+// it to, along with that route, the severity of event and whether any
+// notifier wanted it. Escalations and the recoveries of escalated outages
+// also go to the notifiers their ALERT_ESCALATION steps name.
+func (ec *EndpointChecker) recipients(event store.Event) (route *alertRoute, severity string, queues []*notifyQueue, wanted bool) {
+	if ec.config.AlertRoutes != nil {
+		route = ec.config.AlertRoutes.route(event.Endpoint, ec.endpointTags(event.Endpoint))
+	}
+	severity, escalated, only := escalationTargets(ec.config.AlertEscalation, event)
+	for _, q := range ec.notifiers {
+		if !q.wants(event) {
+			continue
+		}
+		wanted = true
+		name := q.notifier.name()
+		routed := !only && (route == nil || route.allows(name, severity))
+		if routed || slices.Contains(escalated, strings.ToLower(name)) {
+			queues = append(queues, q)
+		}
+	}
+	return route, severity, queues, wanted
+}
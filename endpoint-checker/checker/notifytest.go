@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// notifyTestEndpoint is the endpoint of the test event unless one is given
+const notifyTestEndpoint = "https://notify-test.example.com"
+
+// notifyTestResult is how a notifier fared with the test event
+type notifyTestResult struct {
+	Notifier  string `json:"notifier"`
+	Delivered bool   `json:"delivered"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error,omitempty"` // of the last attempt
+}
+
+// notifyTest sends a test event, endpoint going down, to the notifiers
+// route selects and waits for each to deliver it or give up, retrying as
+// with real events. route names a notifier from notifierNames, which gets
+// the event whatever the routes say, or a route of ALERT_ROUTES_FILE
+// ("default" for its default route); "" routes the event by its endpoint
+// like any other. It fails when route is unknown or selects no notifier.
+func (ec *EndpointChecker) notifyTest(ctx context.Context, route, endpoint string) ([]notifyTestResult, error) {
+	if endpoint == "" {
+		endpoint = notifyTestEndpoint
+	}
+	event := store.Event{Endpoint: endpoint, Kind: store.EventKindStatus, Old: store.StatusUp, New: store.StatusDown, At: time.Now().UTC(), Test: true}
+
+	var queues []*notifyQueue
+	name := strings.ToLower(route)
+	switch {
+	case name == "":
+		_, _, queues, _ = ec.recipients(event)
+	case slices.Contains(notifierNames, name):
+		for _, q := range ec.notifiers {
+			if strings.ToLower(q.notifier.name()) == name {
+				queues = append(queues, q)
+			}
+		}
+		if len(queues) == 0 {
+			return nil, fmt.Errorf("notifier %s is not configured", name)
+		}
+	default:
+		var selected *alertRoute
+		if ec.config.AlertRoutes != nil {
+			selected = ec.config.AlertRoutes.named(name)
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("unknown route %q (use a notifier: %s, or a route of ALERT_ROUTES_FILE)", route, strings.Join(notifierNames, ", "))
+		}
+		severity := eventSeverity(event)
+		for _, q := range ec.notifiers {
+			if selected.allows(q.notifier.name(), severity) {
+				queues = append(queues, q)
+			}
+		}
+	}
+	if len(queues) == 0 {
+		return nil, errors.New("no notifier gets the test event")
+	}
+
+	results := make([]notifyTestResult, 0, len(queues))
+	for _, q := range queues {
+		what := fmt.Sprintf("test event of %s", endpoint)
+		attempts, err := q.sendWithRetries(ctx, what, func() error { return q.notifier.send(ctx, event) })
+		result := notifyTestResult{Notifier: q.notifier.name(), Delivered: err == nil, Attempts: attempts}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleNotifyTest serves POST /api/notify-test?route=&endpoint= on the
+// admin server, answering the results of notifyTest with 200 when every
+// notifier delivered the test event and 502 otherwise
+func (ec *EndpointChecker) handleNotifyTest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	results, err := ec.notifyTest(r.Context(), query.Get("route"), query.Get("endpoint"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := http.StatusOK
+	for _, result := range results {
+		if !result.Delivered {
+			status = http.StatusBadGateway
+		}
+	}
+	log.Printf("[INFO] Sent a test notification from %s to %d notifiers (status %d)", r.RemoteAddr, len(results), status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+// runNotifyTest implements `endpoint-checker notify-test [-route name]
+// [-endpoint url]`, printing how each notifier fared. It fails unless
+// every notifier delivered the test event, for deployment smoke tests.
+func runNotifyTest(config Config, args []string) error {
+	fs := flag.NewFlagSet("notify-test", flag.ExitOnError)
+	route := fs.String("route", "", "notifier (slack, webhook, email, telegram, opsgenie) or ALERT_ROUTES_FILE route to send to; empty routes by endpoint")
+	endpoint := fs.String("endpoint", "", "endpoint of the test event, to test the routes by URL and tags (default "+notifyTestEndpoint+")")
+	fs.Parse(args)
+
+	// Test notifications are neither stored nor recorded
+	checker := NewEndpointChecker(config, store.NewMemoryStore())
+	if _, options, err := checker.loadEndpointsFile(); err == nil {
+		checker.setOptions(options)
+	} else if *endpoint != "" {
+		log.Printf("[WARN] Routing the test event without the tags of the endpoints file: %v", err)
+	}
+
+	results, err := checker.notifyTest(context.Background(), *route, *endpoint)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Delivered {
+			fmt.Printf("%s: delivered (%d attempts)\n", result.Notifier, result.Attempts)
+			continue
+		}
+		failed++
+		fmt.Printf("%s: failed after %d attempts: %s\n", result.Notifier, result.Attempts, result.Error)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d notifiers failed to deliver the test event", failed, len(results))
+	}
+	return nil
+}
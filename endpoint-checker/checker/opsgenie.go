@@ -0,0 +1,238 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// opsgenieAPIURL is the Alert API of Opsgenie's US instance
+const opsgenieAPIURL = "https://api.opsgenie.com"
+
+// opsgenieSource is the source of the alerts, notes and closes the checker makes
+const opsgenieSource = "certs-n-status"
+
+// opsgeniePriority matches Opsgenie's alert priorities, P1 the highest
+var opsgeniePriority = regexp.MustCompile(`^P[1-5]$`)
+
+// opsgenieConfig configures the Opsgenie notifier, which is enabled when
+// APIKey is set
+type opsgenieConfig struct {
+	APIKey        string
+	APIURL        string
+	Priority      string              // of endpoints without a tag in TagPriorities
+	TagPriorities map[string][]string // by endpoints file tag; the highest of an endpoint's tags applies
+	Teams         []string            // responders of endpoints without a tag in TagTeams
+	TagTeams      map[string][]string // responding teams by endpoints file tag, replacing Teams
+}
+
+// loadOpsgenieConfig reads the OPSGENIE_ variables
+func loadOpsgenieConfig() (opsgenieConfig, error) {
+	config := opsgenieConfig{APIKey: os.Getenv("OPSGENIE_API_KEY"), APIURL: opsgenieAPIURL, Priority: "P3"}
+	if config.APIKey == "" {
+		return opsgenieConfig{}, nil
+	}
+	if value := os.Getenv("OPSGENIE_API_URL"); value != "" {
+		if err := checkWebhookURL("OPSGENIE_API_URL", value); err != nil {
+			return opsgenieConfig{}, err
+		}
+		config.APIURL = strings.TrimSuffix(value, "/")
+	}
+	if value := os.Getenv("OPSGENIE_PRIORITY"); value != "" {
+		if !opsgeniePriority.MatchString(value) {
+			return opsgenieConfig{}, fmt.Errorf("invalid OPSGENIE_PRIORITY %q (use P1 to P5)", value)
+		}
+		config.Priority = value
+	}
+	var err error
+	if value := os.Getenv("OPSGENIE_TAG_PRIORITIES"); value != "" {
+		if config.TagPriorities, err = parseTagRoutes("OPSGENIE_TAG_PRIORITIES", value, "tag=P1", parseOpsgeniePriority); err != nil {
+			return opsgenieConfig{}, err
+		}
+	}
+	if value := os.Getenv("OPSGENIE_TEAMS"); value != "" {
+		if config.Teams, err = parseOpsgenieTeams(value); err != nil {
+			return opsgenieConfig{}, fmt.Errorf("invalid OPSGENIE_TEAMS: %w", err)
+		}
+	}
+	if value := os.Getenv("OPSGENIE_TAG_TEAMS"); value != "" {
+		if config.TagTeams, err = parseTagRoutes("OPSGENIE_TAG_TEAMS", value, "tag=team,team", parseOpsgenieTeams); err != nil {
+			return opsgenieConfig{}, err
+		}
+	}
+	return config, nil
+}
+
+// parseOpsgeniePriority parses the priority of an OPSGENIE_TAG_PRIORITIES entry
+func parseOpsgeniePriority(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if !opsgeniePriority.MatchString(value) {
+		return nil, fmt.Errorf("%q is not a priority (use P1 to P5)", value)
+	}
+	return []string{value}, nil
+}
+
+// parseOpsgenieTeams parses a comma-separated list of team names
+func parseOpsgenieTeams(value string) ([]string, error) {
+	var teams []string
+	for _, team := range strings.Split(value, ",") {
+		team = strings.TrimSpace(team)
+		if team == "" {
+			return nil, errors.New("empty team name")
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// opsgenieNotifier keeps one Opsgenie alert per endpoint, aliased by its
+// URL: the alert is created when the endpoint goes down, gets a note when
+// it keeps failing for another reason and is closed when it recovers. An
+// SLO's alert is created when it starts burning its error budget and
+// closed when it stops.
+type opsgenieNotifier struct {
+	config       opsgenieConfig
+	dashboardURL string                         // links each alert to the endpoint when set
+	tags         func(endpoint string) []string // tags of the endpoints file, choosing priority and teams
+	client       *http.Client
+}
+
+func newOpsgenieNotifier(config opsgenieConfig, dashboardURL string, tags func(string) []string) *opsgenieNotifier {
+	return &opsgenieNotifier{
+		config:       config,
+		dashboardURL: strings.TrimSuffix(dashboardURL, "/"),
+		tags:         tags,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *opsgenieNotifier) name() string {
+	return "Opsgenie"
+}
+
+// wants takes the status and error class events of endpoints that are
+// neither acknowledged nor in maintenance, and slo_burn events.
+// Certificates are left to the other notifiers, as they rarely need paging
+// anyone.
+func (o *opsgenieNotifier) wants(event store.Event) bool {
+	if event.Acknowledged || event.InMaintenance {
+		return false
+	}
+	return event.Kind == store.EventKindStatus || event.Kind == store.EventKindErrorClass || event.Kind == store.EventKindSLOBurn
+}
+
+// opsgenieAlert is the body of a create alert request
+type opsgenieAlert struct {
+	Message     string              `json:"message"`
+	Alias       string              `json:"alias"`
+	Description string              `json:"description,omitempty"`
+	Responders  []opsgenieResponder `json:"responders,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Details     map[string]string   `json:"details,omitempty"`
+	Entity      string              `json:"entity"`
+	Source      string              `json:"source"`
+	Priority    string              `json:"priority"`
+}
+
+type opsgenieResponder struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// send creates, annotates or closes the alert of the event's endpoint, and
+// adds reminders that it is still down as notes. An escalation creates the
+// alert, which Opsgenie adds to an open one of the same alias. Opsgenie accepts requests
+// with 202 and processes them later, so a close or note of an alert that
+// does not exist succeeds as well.
+func (o *opsgenieNotifier) send(ctx context.Context, event store.Event) error {
+	switch {
+	case event.Kind == store.EventKindErrorClass:
+		return o.post(ctx, o.alertURL(event.Endpoint, "notes"), map[string]string{
+			"note":   fmt.Sprintf("Still down, now failing with %s instead of %s", event.New, event.Old),
+			"source": opsgenieSource,
+		})
+	case event.Old == store.StatusDown && event.New == store.StatusDown && event.Escalation == 0:
+		return o.post(ctx, o.alertURL(event.Endpoint, "notes"), map[string]string{
+			"note":   describeEvent(event, event.Endpoint),
+			"source": opsgenieSource,
+		})
+	case event.New == store.StatusUp, event.Kind == store.EventKindSLOBurn && event.New == store.SLOStateOK:
+		return o.post(ctx, o.alertURL(event.Endpoint, "close"), map[string]string{
+			"note":   describeEvent(event, event.Endpoint),
+			"source": opsgenieSource,
+		})
+	}
+	return o.post(ctx, o.config.APIURL+"/v2/alerts", o.newAlert(event))
+}
+
+// newAlert builds the alert of an endpoint going down, with the highest
+// priority and the teams of its tags
+func (o *opsgenieNotifier) newAlert(event store.Event) opsgenieAlert {
+	tags := o.tags(event.Endpoint)
+	alert := opsgenieAlert{
+		Message:  truncateRunes(describeEvent(event, event.Endpoint), 130),
+		Alias:    opsgenieAlias(event.Endpoint),
+		Tags:     tags,
+		Details:  map[string]string{"endpoint": event.Endpoint},
+		Entity:   event.Endpoint,
+		Source:   opsgenieSource,
+		Priority: slices.Min(routeByTag(tags, o.config.TagPriorities, []string{o.config.Priority})),
+	}
+	description := []string{fmt.Sprintf("%s: %s → %s", event.Kind, event.Old, event.New)}
+	if event.ErrorClass != "" {
+		description = append(description, "error: "+event.ErrorClass)
+		alert.Details["error_class"] = event.ErrorClass
+	}
+	if event.CheckedBy != "" {
+		description = append(description, "checked by: "+event.CheckedBy)
+		alert.Details["checked_by"] = event.CheckedBy
+	}
+	if o.dashboardURL != "" {
+		link := dashboardLink(o.dashboardURL, event.Endpoint)
+		description = append(description, link)
+		alert.Details["dashboard"] = link
+	}
+	alert.Description = strings.Join(description, "\n")
+	for _, team := range routeByTag(tags, o.config.TagTeams, o.config.Teams) {
+		alert.Responders = append(alert.Responders, opsgenieResponder{Name: team, Type: "team"})
+	}
+	return alert
+}
+
+// alertURL is the URL of an action on the alert of endpoint
+func (o *opsgenieNotifier) alertURL(endpoint, action string) string {
+	return o.config.APIURL + "/v2/alerts/" + url.PathEscape(opsgenieAlias(endpoint)) + "/" + action + "?identifierType=alias"
+}
+
+// post makes one Alert API request
+func (o *opsgenieNotifier) post(ctx context.Context, target string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, o.client, target, payload, http.Header{"Authorization": {"GenieKey " + o.config.APIKey}})
+}
+
+// opsgenieAlias is the alias of the alert of endpoint, its URL within
+// Opsgenie's limit of 512 characters
+func opsgenieAlias(endpoint string) string {
+	return truncateRunes(endpoint, 512)
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"log"
+	"slices"
+
+	"certs-n-status/store"
+)
+
+// pausedEndpoints returns the endpoints paused through the dashboard's
+// POST /api/endpoints/pause, none with other storage. When the pauses
+// cannot be read none is skipped, so a failure checks an endpoint too
+// many rather than leaving one unchecked.
+func (ec *EndpointChecker) pausedEndpoints() map[string]bool {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	pauses, err := rs.Pauses(ctx)
+	if err != nil {
+		log.Printf("[WARN] Failed to read paused endpoints, checking all of them: %v", err)
+		return nil
+	}
+	paused := make(map[string]bool, len(pauses))
+	for _, pause := range pauses {
+		paused[pause.Endpoint] = true
+	}
+	return paused
+}
+
+// skipPaused returns endpoints without the paused ones
+func (ec *EndpointChecker) skipPaused(endpoints []string) []string {
+	paused := ec.pausedEndpoints()
+	if len(paused) == 0 {
+		return endpoints
+	}
+	return slices.DeleteFunc(slices.Clone(endpoints), func(endpoint string) bool { return paused[endpoint] })
+}
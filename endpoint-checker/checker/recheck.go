@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"certs-n-status/store"
+)
+
+// watchRechecks checks the endpoints requested through the dashboard's
+// POST /api/endpoints/recheck as soon as they arrive. Only endpoints of the
+// current cycles are checked, so the channel cannot make the checker fetch
+// arbitrary URLs, and paused ones are not.
+func (ec *EndpointChecker) watchRechecks(rs *store.RedisStore) {
+	rs.WatchRechecks(ec.ctx, func(endpoint string) {
+		if !slices.Contains(ec.currentEndpoints(), endpoint) {
+			log.Printf("[WARN] Ignoring recheck request for unmonitored endpoint %q", endpoint)
+			return
+		}
+		if paused := ec.pausedEndpoints(); paused[endpoint] {
+			log.Printf("[INFO] Ignoring recheck request for paused endpoint %s", endpoint)
+			return
+		}
+		log.Printf("[INFO] Rechecking %s on request", endpoint)
+		go ec.recheck(endpoint)
+	})
+}
+
+// recheck runs the status check of endpoint, and the SSL check of an HTTPS
+// one, and saves both as one result, publishing state changes like a cycle
+func (ec *EndpointChecker) recheck(endpoint string) {
+	result := ec.checkEndpointStatus(endpoint)
+	if strings.HasPrefix(endpoint, "https://") {
+		if ssl, ok := ec.checkEndpointSSL(endpoint); ok {
+			result.Cert = ssl.Cert
+		}
+	}
+	ec.saveResults("recheck", []store.Result{result})
+}
@@ -0,0 +1,103 @@
+package checker
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"certs-n-status/store"
+)
+
+// ENDPOINTS_SOURCE values
+const (
+	endpointsSourceFile  = "file"  // ENDPOINTS_FILE, read at startup
+	endpointsSourceRedis = "redis" // the endpoint registry, managed from the dashboard
+)
+
+// loadEndpoints returns the endpoints to check from the configured source.
+// Endpoints read from the file have their options, such as tags, taken
+// along; those of the registry have none.
+func (ec *EndpointChecker) loadEndpoints() ([]string, error) {
+	switch ec.config.EndpointsSource {
+	case endpointsSourceFile, "":
+		endpoints, options, err := ec.loadEndpointsFile()
+		if err != nil {
+			return nil, err
+		}
+		ec.setOptions(options)
+		return endpoints, nil
+	case endpointsSourceRedis:
+		rs, ok := ec.store.(*store.RedisStore)
+		if !ok {
+			return nil, fmt.Errorf("ENDPOINTS_SOURCE=redis requires Redis storage, not %s", ec.config.Storage)
+		}
+		ctx, cancel := ec.storeContext()
+		defer cancel()
+		endpoints, err := rs.ListEndpoints(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read endpoint registry: %w", err)
+		}
+		slices.Sort(endpoints)
+		return endpoints, nil
+	default:
+		return nil, fmt.Errorf("unknown ENDPOINTS_SOURCE %q (use file or redis)", ec.config.EndpointsSource)
+	}
+}
+
+// seedEndpointRegistry fills an empty registry from ENDPOINTS_FILE when the
+// registry is the source, so switching to it keeps the checked endpoints.
+// Without the file the registry starts empty.
+func (ec *EndpointChecker) seedEndpointRegistry() error {
+	if ec.config.EndpointsSource != endpointsSourceRedis {
+		return nil
+	}
+	endpoints, err := ec.loadEndpoints()
+	if err != nil || len(endpoints) > 0 {
+		return err
+	}
+	if _, err := os.Stat(ec.config.EndpointsFile); os.IsNotExist(err) {
+		return nil
+	}
+	endpoints, _, err = ec.loadEndpointsFile()
+	if err != nil {
+		return err
+	}
+	if _, err := ec.store.(*store.RedisStore).SyncEndpointRegistry(ec.ctx, endpoints); err != nil {
+		return fmt.Errorf("failed to seed endpoint registry: %w", err)
+	}
+	log.Printf("[INFO] Seeded the empty endpoint registry with %d endpoints from %s", len(endpoints), ec.config.EndpointsFile)
+	return nil
+}
+
+func (ec *EndpointChecker) setEndpoints(endpoints []string) {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	ec.endpoints = endpoints
+}
+
+// currentEndpoints returns the endpoints of the last load
+func (ec *EndpointChecker) currentEndpoints() []string {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	return ec.endpoints
+}
+
+// reloadEndpoints reads the registry again when it is the source, picking
+// up endpoints added or removed since the last cycle. When it cannot be
+// read the last endpoints are checked again.
+func (ec *EndpointChecker) reloadEndpoints() []string {
+	if ec.config.EndpointsSource != endpointsSourceRedis {
+		return ec.currentEndpoints()
+	}
+	endpoints, err := ec.loadEndpoints()
+	if err != nil {
+		log.Printf("[WARN] %v; checking the last %d endpoints again", err, len(ec.currentEndpoints()))
+		return ec.currentEndpoints()
+	}
+	if !slices.Equal(endpoints, ec.currentEndpoints()) {
+		log.Printf("[INFO] Endpoint registry changed, now checking %d endpoints", len(endpoints))
+		ec.setEndpoints(endpoints)
+	}
+	return endpoints
+}
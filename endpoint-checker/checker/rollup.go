@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// rollupLookback is how far back each rollup run recomputes hours, so runs
+// missed while the checker was down or busy are filled in on the next one
+const rollupLookback = 24 * time.Hour
+
+func (ec *EndpointChecker) runLatencyRollups() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	// Initial rollup
+	ec.rollupLatency(ec.currentEndpoints(), time.Now())
+
+	for {
+		select {
+		case <-ec.ctx.Done():
+			return
+		case now := <-ticker.C:
+			ec.rollupLatency(ec.currentEndpoints(), now)
+		}
+	}
+}
+
+// rollupLatency recomputes the hourly latency rollups of the completed hours
+// within rollupLookback from the status history, the uptime windows from
+// the rollups, and then the SLOs. Hours are overwritten with the same
+// values on every run, so repeated or skipped runs are harmless.
+func (ec *EndpointChecker) rollupLatency(endpoints []string, now time.Time) {
+	since := now.Truncate(time.Hour).Add(-rollupLookback)
+	windows := ec.maintenanceWindows()
+	rolledUp := 0
+	for _, endpoint := range endpoints {
+		n, err := ec.rollupEndpointLatency(endpoint, windows, since, now)
+		if err != nil {
+			log.Printf("[ERROR] Failed to roll up latency for %s: %v", endpoint, err)
+			continue
+		}
+		rolledUp += n
+	}
+	log.Printf("[INFO] Latency rollup completed: %d hourly rollups for %d endpoints", rolledUp, len(endpoints))
+	ec.updateSLOs(endpoints, now)
+}
+
+// rollupEndpointLatency recomputes one endpoint's rollups and uptime,
+// counting the checks in windows, and returns how many rollups were stored
+func (ec *EndpointChecker) rollupEndpointLatency(endpoint string, windows []store.MaintenanceWindow, since, now time.Time) (int, error) {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	history, err := ec.store.StatusHistory(ctx, endpoint, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read status history: %w", err)
+	}
+	tags := ec.endpointTags(endpoint)
+	rollups := store.RollupLatency(history, now, func(t time.Time) bool {
+		return store.InMaintenance(windows, endpoint, tags, t)
+	})
+	if err := ec.store.SaveLatencyRollups(ctx, endpoint, rollups); err != nil {
+		return 0, fmt.Errorf("failed to store rollups: %w", err)
+	}
+
+	longest := store.UptimeWindows[len(store.UptimeWindows)-1].Length
+	stored, err := ec.store.LatencyRollups(ctx, endpoint, now.Truncate(time.Hour).Add(-longest))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rollups: %w", err)
+	}
+	if err := ec.store.SaveUptime(ctx, endpoint, store.ComputeUptime(stored, now)); err != nil {
+		return 0, fmt.Errorf("failed to store uptime: %w", err)
+	}
+	return len(rollups), nil
+}
@@ -0,0 +1,261 @@
+package checker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"certs-n-status/store"
+
+	"gopkg.in/yaml.v3"
+)
+
+// notifierNames are the notifiers routes may send to, as written in
+// ALERT_ROUTES_FILE
+var notifierNames = []string{"slack", "webhook", "email", "telegram", "opsgenie"}
+
+// Event severities, lowest first, as given to a route's min_severity
+const (
+	severityInfo     = "info"
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+var severities = []string{severityInfo, severityWarning, severityCritical}
+
+// eventSeverity rates an event: an endpoint going or staying down, an SLO
+// burning its error budget and an expired certificate are critical, like a certificate entering the
+// critical window, and a certificate entering the warning window or an
+// endpoint failing for another reason is a warning. A replaced
+// certificate is critical from an unexpected issuer, a warning from
+// another issuer or expiring sooner, and info otherwise. A recovery is
+// rated like the problem it ends, so a route that sent the problem sends
+// its end too. Anything else, such as a renewal, is info.
+func eventSeverity(event store.Event) string {
+	switch event.Kind {
+	case store.EventKindStatus, store.EventKindSLOBurn:
+		return severityCritical
+	case store.EventKindErrorClass:
+		return severityWarning
+	case store.EventKindCertChanged:
+		switch event.CertChange.Assess() {
+		case store.CertChangeUnexpectedIssuer:
+			return severityCritical
+		case store.CertChangeSuspicious:
+			return severityWarning
+		}
+	case store.EventKindCert:
+		level := event.New
+		if level == store.CertLevelOK {
+			level = event.Old
+		}
+		switch level {
+		case store.CertLevelWarning:
+			return severityWarning
+		case store.CertLevelCritical, store.CertLevelExpired:
+			return severityCritical
+		}
+	}
+	return severityInfo
+}
+
+// alertRoute sends the events of the endpoints it matches to its notifiers
+type alertRoute struct {
+	Name        string   `yaml:"name"`
+	Tags        []string `yaml:"tags"`         // matches endpoints with any of these tags
+	URLs        []string `yaml:"urls"`         // matches endpoints like any of these patterns, where * stands for anything
+	Notify      []string `yaml:"notify"`       // names from notifierNames
+	MinSeverity string   `yaml:"min_severity"` // events rated lower are sent nowhere; empty sends all
+
+	label    string // names the route in logs
+	patterns []*regexp.Regexp
+}
+
+// alertRoutes decide which notifiers get an event: the first route that
+// matches its endpoint, or the default route when none does
+type alertRoutes struct {
+	Routes  []alertRoute `yaml:"routes"`
+	Default *alertRoute  `yaml:"default"` // nil sends to every configured notifier
+}
+
+// loadAlertRoutes reads ALERT_ROUTES_FILE, a YAML list of routes and a
+// default route:
+//
+//	routes:
+//	  - name: payments
+//	    tags: [payments]
+//	    notify: [opsgenie, slack]
+//	  - name: marketing
+//	    urls: ["https://*.marketing.example.com/*"]
+//	    notify: [slack]
+//	    min_severity: critical
+//	default:
+//	  notify: [slack, email]
+//
+// Every notifier a route names must be among the configured ones. Unknown
+// fields, notifiers and severities are refused with the offending route,
+// so a typo stops the checker at startup instead of losing alerts.
+func loadAlertRoutes(path string, configured []string) (*alertRoutes, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_ROUTES_FILE: %w", err)
+	}
+	routes, err := parseAlertRoutes(content, configured)
+	if err != nil {
+		return nil, fmt.Errorf("ALERT_ROUTES_FILE %s: %w", path, err)
+	}
+	return routes, nil
+}
+
+// parseAlertRoutes parses and checks the content of ALERT_ROUTES_FILE
+func parseAlertRoutes(content []byte, configured []string) (*alertRoutes, error) {
+	var routes alertRoutes
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&routes); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(routes.Routes) == 0 && routes.Default == nil {
+		return nil, errors.New("defines no routes")
+	}
+	for i := range routes.Routes {
+		route := &routes.Routes[i]
+		if len(route.Tags) == 0 && len(route.URLs) == 0 {
+			return nil, fmt.Errorf("route %d %s: matches nothing (give tags or urls)", i+1, route)
+		}
+		route.label = "route " + route.Name
+		if route.Name == "" {
+			route.label = fmt.Sprintf("route %d", i+1)
+		}
+		if err := route.check(configured); err != nil {
+			return nil, fmt.Errorf("route %d %s: %w", i+1, route, err)
+		}
+	}
+	if routes.Default == nil {
+		routes.Default = &alertRoute{Notify: configured}
+	} else if len(routes.Default.Tags) > 0 || len(routes.Default.URLs) > 0 {
+		return nil, fmt.Errorf("default route %s: matches every endpoint, remove its tags and urls", routes.Default)
+	} else if err := routes.Default.check(configured); err != nil {
+		return nil, fmt.Errorf("default route %s: %w", routes.Default, err)
+	}
+	routes.Default.label = "the default route"
+	return &routes, nil
+}
+
+// check validates the route, lower-casing its tags and notifiers and
+// compiling its URL patterns
+func (r *alertRoute) check(configured []string) error {
+	for i, tag := range r.Tags {
+		r.Tags[i] = strings.ToLower(tag)
+		if !validTag(r.Tags[i]) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	for _, pattern := range r.URLs {
+		if pattern == "" {
+			return errors.New("empty URL pattern")
+		}
+		r.patterns = append(r.patterns, regexp.MustCompile("^"+strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")+"$"))
+	}
+	for i, name := range r.Notify {
+		r.Notify[i] = strings.ToLower(name)
+		switch {
+		case !slices.Contains(notifierNames, r.Notify[i]):
+			return fmt.Errorf("unknown notifier %q (use %s)", name, strings.Join(notifierNames, ", "))
+		case !slices.Contains(configured, r.Notify[i]):
+			return fmt.Errorf("notifier %s is not configured", r.Notify[i])
+		}
+	}
+	if r.MinSeverity != "" && !slices.Contains(severities, r.MinSeverity) {
+		return fmt.Errorf("unknown min_severity %q (use %s)", r.MinSeverity, strings.Join(severities, ", "))
+	}
+	return nil
+}
+
+// String writes the route as in ALERT_ROUTES_FILE, for error messages
+func (r *alertRoute) String() string {
+	var fields []string
+	if r.Name != "" {
+		fields = append(fields, "name: "+r.Name)
+	}
+	list := func(name string, values []string) {
+		if len(values) > 0 {
+			fields = append(fields, name+": ["+strings.Join(values, ", ")+"]")
+		}
+	}
+	list("tags", r.Tags)
+	list("urls", r.URLs)
+	list("notify", r.Notify)
+	if r.MinSeverity != "" {
+		fields = append(fields, "min_severity: "+r.MinSeverity)
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// matches reports whether the route is for endpoint, tagged tags
+func (r *alertRoute) matches(endpoint string, tags []string) bool {
+	for _, tag := range r.Tags {
+		if slices.Contains(tags, tag) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(r.patterns, func(pattern *regexp.Regexp) bool { return pattern.MatchString(endpoint) })
+}
+
+// severe reports whether severity is at least the route's min_severity
+func (r *alertRoute) severe(severity string) bool {
+	return r.MinSeverity == "" || slices.Index(severities, severity) >= slices.Index(severities, r.MinSeverity)
+}
+
+// allows reports whether the route sends events rated severity to the
+// notifier named name
+func (r *alertRoute) allows(name, severity string) bool {
+	return r.severe(severity) && slices.Contains(r.Notify, strings.ToLower(name))
+}
+
+// route returns the route of the endpoint tagged tags
+func (routes *alertRoutes) route(endpoint string, tags []string) *alertRoute {
+	for i := range routes.Routes {
+		if routes.Routes[i].matches(endpoint, tags) {
+			return &routes.Routes[i]
+		}
+	}
+	return routes.Default
+}
+
+// named returns the route called name, the default route for "default",
+// or nil when there is none
+func (routes *alertRoutes) named(name string) *alertRoute {
+	if name == "default" {
+		return routes.Default
+	}
+	for i := range routes.Routes {
+		if routes.Routes[i].Name != "" && strings.EqualFold(routes.Routes[i].Name, name) {
+			return &routes.Routes[i]
+		}
+	}
+	return nil
+}
+
+// configuredNotifiers returns the names of the notifiers config enables,
+// as in ALERT_ROUTES_FILE
+func (config Config) configuredNotifiers() []string {
+	var names []string
+	for i, enabled := range []bool{
+		config.SlackWebhookURL != "",
+		config.WebhookURL != "",
+		config.Email.Host != "",
+		config.Telegram.BotToken != "",
+		config.Opsgenie.APIKey != "",
+	} {
+		if enabled {
+			names = append(names, notifierNames[i])
+		}
+	}
+	return names
+}
@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parseCronSchedule parses five cron fields with cron.ParseStandard:
+// minute, hour, day of month, month and day of week (0 or SUN is Sunday).
+// As in cron, when both days are restricted either one may match. The
+// schedule fires in the zone of the time given to its Next; the daily
+// digest and the schedule= option of endpoints use it.
+func parseCronSchedule(spec string) (cron.Schedule, error) {
+	if len(strings.Fields(spec)) != 5 {
+		return nil, errors.New("use five cron fields such as \"*/5 8-18 * * 1-5\"")
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, errors.New("no such day")
+	}
+	return schedule, nil
+}
+
+// endpointSchedule is the schedule= option of an endpoint: its status and
+// SSL checks only run when the cron schedule fired, in Location, since the
+// previous cycle, so an endpoint checked every minute with "*/5 8-18 * * 1-5"
+// is checked every 5 minutes during office hours on weekdays
+type endpointSchedule struct {
+	Cron     cron.Schedule
+	Location *time.Location // of Cron, from schedule_tz=; UTC by default
+}
+
+// due reports whether the schedule fired after since and at or before now
+func (s endpointSchedule) due(since, now time.Time) bool {
+	next := s.Cron.Next(since.In(s.Location))
+	return !next.IsZero() && !next.After(now)
+}
+
+// scheduleSince returns the time the schedules of the endpoints must have
+// fired after for a cycle starting at now to check them: the start of the
+// previous cycle, or one interval (at least a minute) back for the first
+func (c *cycleClock) scheduleSince(now time.Time, interval time.Duration) time.Time {
+	if c.previous.IsZero() {
+		return now.Add(-max(interval, time.Minute))
+	}
+	return c.previous
+}
+
+// splitBySchedule returns the endpoints a cycle starting at now checks and
+// those their schedule pauses
+func (ec *EndpointChecker) splitBySchedule(endpoints []string, since, now time.Time) (due, paused []string) {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	for _, endpoint := range endpoints {
+		if schedule := ec.options[endpoint].schedule; schedule != nil && !schedule.due(since, now) {
+			paused = append(paused, endpoint)
+			continue
+		}
+		due = append(due, endpoint)
+	}
+	return due, paused
+}
@@ -0,0 +1,96 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// slackNotifier posts state changes to a Slack incoming webhook
+type slackNotifier struct {
+	webhookURL   string
+	dashboardURL string // links each message to the endpoint when set
+	client       *http.Client
+}
+
+func newSlackNotifier(webhookURL, dashboardURL string) *slackNotifier {
+	return &slackNotifier{
+		webhookURL:   webhookURL,
+		dashboardURL: strings.TrimSuffix(dashboardURL, "/"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *slackNotifier) name() string {
+	return "Slack"
+}
+
+// send posts the message of event; Slack answers 200 with the body "ok"
+func (s *slackNotifier) send(ctx context.Context, event store.Event) error {
+	payload, err := json.Marshal(map[string]string{"text": slackMessage(event, s.dashboardURL)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.webhookURL, payload, nil)
+}
+
+// sendDailyDigest posts the digest with its title in bold
+func (s *slackNotifier) sendDailyDigest(ctx context.Context, digest dailyDigest) error {
+	payload, err := json.Marshal(map[string]string{"text": "*" + slackEscape(digest.title()) + "*\n" + slackEscape(digest.text())})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.webhookURL, payload, nil)
+}
+
+// slackMessage formats an event as Slack mrkdwn: what happened to the
+// endpoint, the old and new state, the error class of an endpoint going
+// down, what changed about a replaced certificate and, with a dashboard
+// URL, a link to the endpoint on the dashboard
+func slackMessage(event store.Event, dashboardURL string) string {
+	emoji := ":white_check_mark:"
+	switch {
+	case event.Kind == store.EventKindStatus && event.New == store.StatusDown:
+		emoji = ":red_circle:"
+	case event.Kind == store.EventKindStatus:
+		emoji = ":large_green_circle:"
+	case event.Kind == store.EventKindSLOBurn && event.New == store.SLOStateBurning:
+		emoji = ":fire:"
+	case event.Kind == store.EventKindCertChanged:
+		emoji = map[string]string{
+			store.CertChangeRenewal:          ":arrows_counterclockwise:",
+			store.CertChangeSuspicious:       ":warning:",
+			store.CertChangeUnexpectedIssuer: ":rotating_light:",
+		}[event.CertChange.Assess()]
+	case event.New == store.CertLevelWarning:
+		emoji = ":warning:"
+	case event.New == store.CertLevelCritical:
+		emoji = ":rotating_light:"
+	case event.New == store.CertLevelExpired:
+		emoji = ":x:"
+	}
+	headline := emoji + " " + describeEvent(event, "*"+slackEscape(event.Endpoint)+"*")
+
+	details := []string{fmt.Sprintf("%s: %s → %s", event.Kind, event.Old, event.New)}
+	if event.Kind == store.EventKindCertChanged {
+		details = certChangeDetails(event.CertChange)
+	}
+	if event.ErrorClass != "" {
+		details = append(details, "error: "+event.ErrorClass)
+	}
+	if event.CheckedBy != "" {
+		details = append(details, "checked by: "+slackEscape(event.CheckedBy))
+	}
+	if dashboardURL != "" {
+		details = append(details, fmt.Sprintf("<%s|Open in dashboard>", slackEscape(dashboardLink(dashboardURL, event.Endpoint))))
+	}
+	return headline + "\n" + strings.Join(details, " · ")
+}
+
+// slackEscape escapes the characters Slack reserves for its markup
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
@@ -0,0 +1,155 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// sloConfig holds the SLO_ variables
+type sloConfig struct {
+	Targets []store.SLOTarget // sorted by tag; none disables SLO tracking
+	Policy  store.SLOPolicy
+}
+
+// loadSLOConfig reads the SLO_ variables through env. An unknown hour
+// counts as the status checks of one hour at statusInterval.
+func loadSLOConfig(env *store.Env, statusInterval time.Duration) sloConfig {
+	config := sloConfig{Policy: store.SLOPolicy{
+		Window:             env.Duration("SLO_WINDOW", store.LatencyRollupRetention),
+		BurnWindow:         env.Duration("SLO_BURN_WINDOW", time.Hour),
+		BurnRateThreshold:  env.Float("SLO_BURN_RATE_THRESHOLD", 14.4),
+		ExcludeMaintenance: env.Bool("SLO_EXCLUDE_MAINTENANCE", true),
+		ExcludeUnknown:     env.Bool("SLO_EXCLUDE_UNKNOWN", true),
+		ChecksPerHour:      1,
+	}}
+	if statusInterval > 0 && statusInterval < time.Hour {
+		config.Policy.ChecksPerHour = int(time.Hour / statusInterval)
+	}
+
+	policy := config.Policy
+	if policy.Window <= 0 || policy.Window%time.Hour != 0 || policy.Window > store.LatencyRollupRetention {
+		env.Add(fmt.Errorf("invalid SLO_WINDOW %s (use whole hours up to %s, such as 720h)", policy.Window, store.LatencyRollupRetention))
+	}
+	if policy.BurnWindow <= 0 || policy.BurnWindow%time.Hour != 0 || policy.BurnWindow > policy.Window {
+		env.Add(fmt.Errorf("invalid SLO_BURN_WINDOW %s (use whole hours up to SLO_WINDOW, such as 1h)", policy.BurnWindow))
+	}
+	if policy.BurnRateThreshold < 0 {
+		env.Add(fmt.Errorf("invalid SLO_BURN_RATE_THRESHOLD %v (use 0 or more, such as 14.4)", policy.BurnRateThreshold))
+	}
+
+	if value := os.Getenv("SLO_TARGETS"); value != "" {
+		targets, err := parseTagRoutes("SLO_TARGETS", value, "tag=99.9", parseSLOObjective)
+		env.Add(err)
+		for tag, objectives := range targets {
+			if len(objectives) > 1 {
+				env.Add(fmt.Errorf("invalid SLO_TARGETS: tag %s has %d objectives (give each tag one)", tag, len(objectives)))
+				continue
+			}
+			objective, _ := strconv.ParseFloat(objectives[0], 64)
+			config.Targets = append(config.Targets, store.SLOTarget{Tag: tag, Objective: objective})
+		}
+		slices.SortFunc(config.Targets, func(a, b store.SLOTarget) int { return strings.Compare(a.Tag, b.Tag) })
+	}
+	return config
+}
+
+// parseSLOObjective parses an SLO_TARGETS objective, a percentage between
+// 0 and 100, exclusive
+func parseSLOObjective(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	objective, err := strconv.ParseFloat(value, 64)
+	if err != nil || objective <= 0 || objective >= 100 {
+		return nil, errors.New("use a percentage between 0 and 100 such as 99.9")
+	}
+	return []string{value}, nil
+}
+
+// updateSLOs computes the SLO of every SLO_TARGETS tag from the rollups
+// of the endpoints with the tag, stores them and publishes an slo_burn
+// event for each SLO that started or stopped burning since the last run.
+// SLOs are kept in Redis only, and only the leading instance updates them,
+// so replicas do not notify a burn twice.
+func (ec *EndpointChecker) updateSLOs(endpoints []string, now time.Time) {
+	rs, ok := ec.store.(*store.RedisStore)
+	if !ok || len(ec.config.SLO.Targets) == 0 || !ec.leading.Load() {
+		return
+	}
+	since := now.UTC().Truncate(time.Hour).Add(-ec.config.SLO.Policy.Window)
+	rollups := make(map[string][]store.LatencyRollup)
+	statuses := make([]store.SLOStatus, 0, len(ec.config.SLO.Targets))
+	for _, target := range ec.config.SLO.Targets {
+		var tagged [][]store.LatencyRollup
+		for _, endpoint := range endpoints {
+			if !slices.Contains(ec.endpointTags(endpoint), target.Tag) {
+				continue
+			}
+			if _, ok := rollups[endpoint]; !ok {
+				ctx, cancel := ec.storeContext()
+				stored, err := ec.store.LatencyRollups(ctx, endpoint, since)
+				cancel()
+				if err != nil {
+					log.Printf("[ERROR] Failed to read rollups of %s, not updating SLOs: %v", endpoint, err)
+					return
+				}
+				rollups[endpoint] = stored
+			}
+			tagged = append(tagged, rollups[endpoint])
+		}
+		statuses = append(statuses, store.ComputeSLO(target, ec.config.SLO.Policy, tagged, now))
+	}
+
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	previous, err := rs.SLOStatuses(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Failed to read SLOs, not updating them: %v", err)
+		return
+	}
+	if err := rs.SaveSLOStatuses(ctx, statuses); err != nil {
+		log.Printf("[ERROR] Failed to store SLOs: %v", err)
+		return
+	}
+	for _, status := range statuses {
+		log.Printf("[INFO] SLO of %s: %.3f%% of %s over %s, %.1f%% of the error budget left, burn rate %.1f",
+			status.Tag, status.Compliance, formatObjective(status.Objective), status.Window, status.BudgetRemaining*100, status.BurnRate)
+	}
+	ec.publishEvents(sloEvents(previous, statuses))
+}
+
+// sloEvents returns an slo_burn event for every SLO of current that
+// started or stopped burning since previous. An SLO new to previous
+// starts out ok, so one burning from the start is notified.
+func sloEvents(previous, current []store.SLOStatus) []store.Event {
+	var events []store.Event
+	for _, status := range current {
+		old := store.SLOStateOK
+		if i := slices.IndexFunc(previous, func(p store.SLOStatus) bool { return p.Tag == status.Tag }); i >= 0 {
+			old = previous[i].State()
+		}
+		if old == status.State() {
+			continue
+		}
+		events = append(events, store.Event{
+			Endpoint: store.SLOEndpoint(status.Tag),
+			Kind:     store.EventKindSLOBurn,
+			Old:      old,
+			New:      status.State(),
+			At:       status.UpdatedAt,
+			SLO:      status,
+		})
+	}
+	return events
+}
+
+// formatObjective writes an objective as a percentage, e.g. 99.9%
+func formatObjective(objective float64) string {
+	return strconv.FormatFloat(objective, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,210 @@
+package checker
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"certs-n-status/store"
+)
+
+// Bounds of the name= and desc= options
+const (
+	maxNameLength        = 100
+	maxDescriptionLength = 500
+)
+
+// endpointOptions are the options an endpoints file line gives its endpoint
+type endpointOptions struct {
+	tags         []string
+	name         string            // display name for the dashboard and public status page, "" without one
+	description  string            // what the endpoint is, shown under its name, "" without one
+	expectIssuer string            // part of the issuer every certificate of the endpoint should have, "" for any
+	schedule     *endpointSchedule // when the endpoint is checked, nil for every cycle
+}
+
+// set reports whether a line gave any of the options, so lines without
+// any need no entry
+func (o endpointOptions) set() bool {
+	return o.tags != nil || o.name != "" || o.description != "" || o.expectIssuer != "" || o.schedule != nil
+}
+
+// parseEndpointLine splits an endpoints file line into the endpoint and its
+// options: tags=prod,payments, name="Payments API" (quoted when it has
+// spaces), desc="Card payments", public=true, which adds the public tag,
+// expect_issuer="Let's Encrypt" and schedule="*/5 8-18 * * 1-5" with
+// schedule_tz=Europe/Berlin. Tags are lowercased; invalid tags, names and
+// descriptions and unknown options are logged and skipped, so a typo does
+// not drop the endpoint. An invalid schedule or zone drops the schedule,
+// checking the endpoint every cycle.
+func parseEndpointLine(line string) (string, endpointOptions) {
+	fields := splitEndpointLine(line)
+	endpoint := store.NormalizeEndpoint(fields[0])
+	var options endpointOptions
+	addTag := func(tag string) {
+		tag = strings.ToLower(tag)
+		switch {
+		case tag == "", slices.Contains(options.tags, tag):
+			// empty or repeated
+		case !validTag(tag):
+			log.Printf("[WARN] Ignoring invalid tag %q of %s (use letters, digits, '-', '_' and '.')", tag, endpoint)
+		default:
+			options.tags = append(options.tags, tag)
+		}
+	}
+	var scheduleSpec, scheduleZone string
+	for _, option := range fields[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "tags":
+			for _, tag := range strings.Split(value, ",") {
+				addTag(tag)
+			}
+		case "name":
+			name := strings.TrimSpace(value)
+			if name == "" || len(name) > maxNameLength || strings.ContainsFunc(name, unicode.IsControl) {
+				log.Printf("[WARN] Ignoring invalid name %q of %s (use 1 to %d characters)", value, endpoint, maxNameLength)
+				continue
+			}
+			options.name = name
+		case "desc":
+			description := strings.TrimSpace(value)
+			if description == "" || len(description) > maxDescriptionLength || strings.ContainsFunc(description, unicode.IsControl) {
+				log.Printf("[WARN] Ignoring invalid desc %q of %s (use 1 to %d characters)", value, endpoint, maxDescriptionLength)
+				continue
+			}
+			options.description = description
+		case "expect_issuer":
+			issuer := strings.TrimSpace(value)
+			if issuer == "" || strings.ContainsFunc(issuer, unicode.IsControl) {
+				log.Printf("[WARN] Ignoring invalid expect_issuer %q of %s", value, endpoint)
+				continue
+			}
+			options.expectIssuer = issuer
+		case "schedule":
+			scheduleSpec = value
+		case "schedule_tz":
+			scheduleZone = value
+		case "public":
+			public, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Printf("[WARN] Ignoring invalid option %q of %s (use public=true)", option, endpoint)
+				continue
+			}
+			if public {
+				addTag(store.PublicTag)
+			}
+		default:
+			log.Printf("[WARN] Ignoring unknown option %q of %s (use tags=a,b, name=\"...\", desc=\"...\", public=true, expect_issuer=\"...\" or schedule=\"...\")", option, endpoint)
+		}
+	}
+	if scheduleSpec != "" || scheduleZone != "" {
+		options.schedule = parseEndpointSchedule(endpoint, scheduleSpec, scheduleZone)
+	}
+	return endpoint, options
+}
+
+// parseEndpointSchedule parses the schedule= and schedule_tz= options of
+// endpoint, or logs why they are ignored and returns nil
+func parseEndpointSchedule(endpoint, spec, zone string) *endpointSchedule {
+	if spec == "" {
+		log.Printf("[WARN] Ignoring schedule_tz of %s without a schedule", endpoint)
+		return nil
+	}
+	cron, err := parseCronSchedule(spec)
+	if err != nil {
+		log.Printf("[WARN] Ignoring invalid schedule %q of %s, checking it every cycle: %v", spec, endpoint, err)
+		return nil
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		log.Printf("[WARN] Ignoring schedule of %s, checking it every cycle: invalid schedule_tz %q (use an IANA zone such as Europe/Berlin)", endpoint, zone)
+		return nil
+	}
+	return &endpointSchedule{Cron: cron, Location: location}
+}
+
+// splitEndpointLine splits a line at whitespace outside double quotes,
+// dropping the quotes, so `name="Payments API"` stays one field
+func splitEndpointLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+	for _, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && unicode.IsSpace(c):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// validTag accepts tags that are safe in URLs and the comma-separated
+// stored form: up to 64 letters, digits and -_.
+func validTag(tag string) bool {
+	if len(tag) > 64 {
+		return false
+	}
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ec *EndpointChecker) setOptions(options map[string]endpointOptions) {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	ec.options = options
+}
+
+// endpointTags returns the tags the endpoints file gives endpoint. The
+// SLOEndpoint of a tag has that tag, so its slo_burn events follow the
+// routes and recipients of the tag.
+func (ec *EndpointChecker) endpointTags(endpoint string) []string {
+	if tag, ok := store.SLOEndpointTag(endpoint); ok {
+		return []string{tag}
+	}
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	return ec.options[endpoint].tags
+}
+
+// endpointExpectedIssuer returns the expect_issuer the endpoints file gives endpoint
+func (ec *EndpointChecker) endpointExpectedIssuer(endpoint string) string {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	return ec.options[endpoint].expectIssuer
+}
+
+// endpointName returns the display name the endpoints file gives endpoint
+func (ec *EndpointChecker) endpointName(endpoint string) string {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	return ec.options[endpoint].name
+}
+
+// endpointDescription returns the description the endpoints file gives
+// endpoint
+func (ec *EndpointChecker) endpointDescription(endpoint string) string {
+	ec.endpointsMu.Lock()
+	defer ec.endpointsMu.Unlock()
+	return ec.options[endpoint].description
+}
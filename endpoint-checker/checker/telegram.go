@@ -0,0 +1,305 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// telegramAPIURL is the Bot API server
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramChatInterval spaces messages to one chat, within the Bot API's
+// limit of 20 messages a minute to a group
+const telegramChatInterval = 3 * time.Second
+
+// telegramMaxRetryAfter bounds how long a rate-limited message waits
+// before the attempt fails and is left to the notify queue's retries
+const telegramMaxRetryAfter = time.Minute
+
+// telegramChatID matches numeric chat IDs, negative for groups, and
+// @username of public channels
+var telegramChatID = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z][A-Za-z0-9_]{4,})$`)
+
+// telegramConfig configures the Telegram notifier, which is enabled when
+// BotToken is set
+type telegramConfig struct {
+	BotToken   string
+	ChatIDs    []string            // chats of endpoints without a tag in TagChatIDs
+	TagChatIDs map[string][]string // chats by endpoints file tag, replacing ChatIDs
+}
+
+// loadTelegramConfig reads the TELEGRAM_ variables
+func loadTelegramConfig() (telegramConfig, error) {
+	config := telegramConfig{BotToken: os.Getenv("TELEGRAM_BOT_TOKEN")}
+	if config.BotToken == "" {
+		return telegramConfig{}, nil
+	}
+	var err error
+	if value := os.Getenv("TELEGRAM_CHAT_ID"); value != "" {
+		if config.ChatIDs, err = parseTelegramChatIDs(value); err != nil {
+			return telegramConfig{}, fmt.Errorf("invalid TELEGRAM_CHAT_ID: %w", err)
+		}
+	}
+	if value := os.Getenv("TELEGRAM_TAG_CHAT_IDS"); value != "" {
+		if config.TagChatIDs, err = parseTagRoutes("TELEGRAM_TAG_CHAT_IDS", value, "tag=chat,chat", parseTelegramChatIDs); err != nil {
+			return telegramConfig{}, err
+		}
+	}
+	if len(config.ChatIDs) == 0 && len(config.TagChatIDs) == 0 {
+		return telegramConfig{}, errors.New("TELEGRAM_BOT_TOKEN needs chats in TELEGRAM_CHAT_ID or TELEGRAM_TAG_CHAT_IDS")
+	}
+	return config, nil
+}
+
+// parseTelegramChatIDs parses a comma-separated list of chat IDs
+func parseTelegramChatIDs(value string) ([]string, error) {
+	var chats []string
+	for _, chat := range strings.Split(value, ",") {
+		chat = strings.TrimSpace(chat)
+		if !telegramChatID.MatchString(chat) {
+			return nil, fmt.Errorf("%q is not a chat ID (use a number such as -1001234567890 or @channel)", chat)
+		}
+		chats = append(chats, chat)
+	}
+	return chats, nil
+}
+
+// telegramNotifier sends state changes to Telegram chats through the Bot
+// API. Its sends hold mu, as the daily digest is sent from outside its
+// notify queue's goroutine.
+type telegramNotifier struct {
+	mu           sync.Mutex
+	config       telegramConfig
+	dashboardURL string                         // links each message to the endpoint when set
+	tags         func(endpoint string) []string // tags of the endpoints file, choosing TagChatIDs
+	apiURL       string
+	interval     time.Duration
+	client       *http.Client
+
+	lastSent   map[string]time.Time // by chat, for interval
+	chatErrors map[string]string    // permanent error of each chat, logged once
+
+	// The chats the last event reached, so a retry after a failure in
+	// another chat does not repeat it
+	lastEvent store.Event
+	delivered []string
+}
+
+func newTelegramNotifier(config telegramConfig, dashboardURL string, tags func(string) []string) *telegramNotifier {
+	return &telegramNotifier{
+		config:       config,
+		dashboardURL: strings.TrimSuffix(dashboardURL, "/"),
+		tags:         tags,
+		apiURL:       telegramAPIURL,
+		interval:     telegramChatInterval,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		lastSent:     make(map[string]time.Time),
+		chatErrors:   make(map[string]string),
+	}
+}
+
+func (n *telegramNotifier) name() string {
+	return "Telegram"
+}
+
+// send posts the message of event to the chats of its endpoint. A chat
+// Telegram rejects messages to, because it does not exist or blocked the
+// bot, is logged once rather than with every event and does not fail the
+// send, since retrying cannot help.
+func (n *telegramNotifier) send(ctx context.Context, event store.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if event != n.lastEvent {
+		n.lastEvent, n.delivered = event, nil
+	}
+	text := telegramMessage(event, n.dashboardURL)
+	var errs []error
+	for _, chat := range routeByTag(n.tags(event.Endpoint), n.config.TagChatIDs, n.config.ChatIDs) {
+		if slices.Contains(n.delivered, chat) {
+			continue
+		}
+		err := n.sendMessage(ctx, chat, text)
+		var apiErr *telegramError
+		switch {
+		case err == nil:
+			if description, failed := n.chatErrors[chat]; failed {
+				log.Printf("[INFO] Telegram accepts messages to chat %s again, after: %s", chat, description)
+				delete(n.chatErrors, chat)
+			}
+		case errors.As(err, &apiErr) && apiErr.permanent():
+			if n.chatErrors[chat] != apiErr.Description {
+				log.Printf("[ERROR] Telegram rejects messages to chat %s, not retrying them: %v", chat, err)
+				n.chatErrors[chat] = apiErr.Description
+			}
+		default:
+			errs = append(errs, fmt.Errorf("chat %s: %w", chat, err))
+			continue
+		}
+		n.delivered = append(n.delivered, chat)
+	}
+	return errors.Join(errs...)
+}
+
+// sendDailyDigest posts the digest to every configured chat
+func (n *telegramNotifier) sendDailyDigest(ctx context.Context, digest dailyDigest) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	text := "*" + telegramEscape(digest.title()) + "*\n" + telegramEscape(digest.text())
+	var errs []error
+	for _, chat := range allRecipients(n.config.ChatIDs, n.config.TagChatIDs) {
+		if err := n.sendMessage(ctx, chat, text); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chat, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// telegramError is an error answer of the Bot API
+type telegramError struct {
+	Code        int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+func (e *telegramError) Error() string {
+	return fmt.Sprintf("Telegram answered %d: %s", e.Code, e.Description)
+}
+
+// permanent reports whether retrying cannot help: the token is invalid,
+// the chat does not exist or the bot was blocked or removed from it
+func (e *telegramError) permanent() bool {
+	switch e.Code {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// sendMessage calls sendMessage for one chat, waiting for the chat's
+// interval and, once, for the retry_after of a 429 answer
+func (n *telegramNotifier) sendMessage(ctx context.Context, chat, text string) error {
+	payload, err := json.Marshal(map[string]any{
+		"chat_id":                  chat,
+		"text":                     text,
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		if wait := time.Until(n.lastSent[chat].Add(n.interval)); wait > 0 {
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+		}
+		n.lastSent[chat] = time.Now()
+		err := n.post(ctx, payload)
+		var apiErr *telegramError
+		if attempt == 1 && errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests {
+			retryAfter := time.Duration(apiErr.Parameters.RetryAfter) * time.Second
+			if retryAfter <= telegramMaxRetryAfter {
+				if err := sleepContext(ctx, retryAfter); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		return err
+	}
+}
+
+// post makes one sendMessage request
+func (n *telegramNotifier) post(ctx context.Context, payload []byte) error {
+	endpoint := n.apiURL + "/bot" + n.config.BotToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return errors.New("invalid Bot API request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL holds the token, keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("sendMessage: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	apiErr := &telegramError{Code: resp.StatusCode, Description: resp.Status}
+	json.NewDecoder(resp.Body).Decode(apiErr)
+	return apiErr
+}
+
+// telegramMessage formats an event as Telegram MarkdownV2, with the same
+// details as slackMessage
+func telegramMessage(event store.Event, dashboardURL string) string {
+	icon := "✅"
+	switch {
+	case event.Kind == store.EventKindStatus && event.New == store.StatusDown:
+		icon = "🔴"
+	case event.Kind == store.EventKindStatus:
+		icon = "🟢"
+	case event.Kind == store.EventKindSLOBurn && event.New == store.SLOStateBurning:
+		icon = "🔥"
+	case event.Kind == store.EventKindCertChanged:
+		icon = map[string]string{
+			store.CertChangeRenewal:          "🔄",
+			store.CertChangeSuspicious:       "⚠️",
+			store.CertChangeUnexpectedIssuer: "🚨",
+		}[event.CertChange.Assess()]
+	case event.New == store.CertLevelWarning:
+		icon = "⚠️"
+	case event.New == store.CertLevelCritical:
+		icon = "🚨"
+	case event.New == store.CertLevelExpired:
+		icon = "❌"
+	}
+	// Escape describeEvent's words as well, then put the endpoint in bold
+	headline := icon + " " + strings.Replace(telegramEscape(describeEvent(event, "\x00")), "\x00", "*"+telegramEscape(event.Endpoint)+"*", 1)
+
+	details := []string{telegramEscape(fmt.Sprintf("%s: %s → %s", event.Kind, event.Old, event.New))}
+	if event.Kind == store.EventKindCertChanged {
+		details = nil
+		for _, detail := range certChangeDetails(event.CertChange) {
+			details = append(details, telegramEscape(detail))
+		}
+	}
+	if event.ErrorClass != "" {
+		details = append(details, telegramEscape("error: "+event.ErrorClass))
+	}
+	if event.CheckedBy != "" {
+		details = append(details, telegramEscape("checked by: "+event.CheckedBy))
+	}
+	if dashboardURL != "" {
+		link := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(dashboardLink(dashboardURL, event.Endpoint))
+		details = append(details, "[Open in dashboard]("+link+")")
+	}
+	return headline + "\n" + strings.Join(details, " · ")
+}
+
+// telegramEscape escapes the characters MarkdownV2 reserves
+var telegramEscape = strings.NewReplacer(
+	`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+	`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+).Replace
@@ -0,0 +1,63 @@
+package checker
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// minStalledCycle is the shortest time a check cycle may run before the
+// checker counts as stalled; a cycle may also take its whole interval
+const minStalledCycle = 5 * time.Minute
+
+// cycleClock notes the start of the running check cycle of a loop
+type cycleClock struct {
+	started  atomic.Int64 // Unix nanoseconds, 0 between cycles
+	previous time.Time    // start of the previous cycle; only the loop reads it
+}
+
+// run runs one cycle
+func (c *cycleClock) run(cycle func()) {
+	now := time.Now()
+	c.started.Store(now.UnixNano())
+	defer func() {
+		c.started.Store(0)
+		c.previous = now
+	}()
+	cycle()
+}
+
+// running returns how long the running cycle has run, 0 between cycles
+func (c *cycleClock) running(now time.Time) time.Duration {
+	started := c.started.Load()
+	if started == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, started))
+}
+
+// Ready is closed once Start has loaded the endpoints and started the check
+// loops
+func (ec *EndpointChecker) Ready() <-chan struct{} {
+	return ec.ready
+}
+
+// Healthy returns an error while a status or SSL check cycle has run for
+// longer than its interval, and at least minStalledCycle, e.g. on a check
+// that never times out. It holds back the systemd watchdog pings.
+func (ec *EndpointChecker) Healthy() error {
+	now := time.Now()
+	for _, loop := range []struct {
+		name     string
+		clock    *cycleClock
+		interval time.Duration
+	}{
+		{"status", &ec.statusCycle, ec.config.StatusCheckInterval},
+		{"SSL", &ec.sslCycle, ec.config.SSLCheckInterval},
+	} {
+		if running := loop.clock.running(now); running > max(loop.interval, minStalledCycle) {
+			return fmt.Errorf("the %s check cycle has run for %s", loop.name, running.Truncate(time.Second))
+		}
+	}
+	return nil
+}
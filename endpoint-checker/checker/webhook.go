@@ -0,0 +1,224 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// webhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+// request body keyed with WEBHOOK_SECRET
+const webhookSignatureHeader = "X-Signature-256"
+
+// webhookPayload is posted as JSON to WEBHOOK_URL, or given to the
+// WEBHOOK_TEMPLATE that renders the body instead
+type webhookPayload struct {
+	Endpoint      string    `json:"endpoint"`
+	Kind          string    `json:"kind"`
+	Old           string    `json:"old"`
+	New           string    `json:"new"`
+	At            time.Time `json:"at"`
+	ErrorClass    string    `json:"error_class,omitempty"`
+	NotAfter      time.Time `json:"not_after,omitzero"`
+	DaysLeft      *int      `json:"days_left,omitempty"`      // whole days from At to NotAfter, for certificate events
+	DownSince     time.Time `json:"down_since,omitzero"`      // start of the outage, for recoveries and reminders that an endpoint is still down
+	OutageSeconds int       `json:"outage_seconds,omitempty"` // from DownSince to At
+	FailedChecks  int       `json:"failed_checks,omitempty"`  // of the outage a recovery ends
+	Escalation    int       `json:"escalation,omitempty"`     // step an escalation reaches, or the outage a recovery ends reached
+	Test          bool      `json:"test,omitempty"`           // a synthetic event of notify-test, not a real state change
+	CheckedBy     string    `json:"checked_by,omitempty"`     // CHECKER_ID of the checker instance that sent it
+
+	// CertChange and its Assessment, such as "renewal" or "suspicious", tell
+	// what a cert_changed event changed
+	CertChange *store.CertChange `json:"cert_change,omitempty"`
+	Assessment string            `json:"assessment,omitempty"`
+
+	// SLO is the compliance, error budget and burn rate of an slo_burn event
+	SLO *store.SLOStatus `json:"slo,omitempty"`
+}
+
+func newWebhookPayload(event store.Event) webhookPayload {
+	payload := webhookPayload{
+		Endpoint:     event.Endpoint,
+		Kind:         event.Kind,
+		Old:          event.Old,
+		New:          event.New,
+		At:           event.At,
+		ErrorClass:   event.ErrorClass,
+		NotAfter:     event.NotAfter,
+		DownSince:    event.DownSince,
+		FailedChecks: event.FailedChecks,
+		Escalation:   event.Escalation,
+		Test:         event.Test,
+		CheckedBy:    event.CheckedBy,
+	}
+	if !event.NotAfter.IsZero() {
+		daysLeft, _ := store.DaysLeft(event.NotAfter, event.At)
+		payload.DaysLeft = &daysLeft
+	}
+	if event.Kind == store.EventKindCertChanged {
+		payload.CertChange = &event.CertChange
+		payload.Assessment = event.CertChange.Assess()
+	}
+	if event.Kind == store.EventKindSLOBurn {
+		payload.SLO = &event.SLO
+	}
+	if !event.DownSince.IsZero() {
+		payload.OutageSeconds = int(event.At.Sub(event.DownSince).Seconds())
+	}
+	return payload
+}
+
+// webhookTemplateFuncs are available to WEBHOOK_TEMPLATE; json quotes a
+// value for use inside the JSON document
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// parseWebhookTemplate reads the body template at path and checks that it
+// renders valid JSON, so a broken template fails at startup rather than
+// with the first outage
+func parseWebhookTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WEBHOOK_TEMPLATE: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(webhookTemplateFuncs).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TEMPLATE: %w", err)
+	}
+	now := time.Now().UTC()
+	sample := store.Event{Endpoint: "https://example.com", Kind: store.EventKindCert, Old: store.CertLevelOK, New: store.CertLevelWarning, At: now, NotAfter: now.Add(store.CertWarningWindow)}
+	if _, err := renderWebhookBody(tmpl, sample); err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_TEMPLATE: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderWebhookBody renders the request body of event with tmpl, or
+// encodes its webhookPayload without one
+func renderWebhookBody(tmpl *template.Template, event store.Event) ([]byte, error) {
+	payload := newWebhookPayload(event)
+	if tmpl == nil {
+		return json.Marshal(payload)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, payload); err != nil {
+		return nil, err
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, errors.New("template did not render valid JSON")
+	}
+	return body.Bytes(), nil
+}
+
+// parseWebhookHeaders parses WEBHOOK_HEADERS, comma-separated "Name: value"
+// pairs such as "Authorization: Bearer abc, X-Team: ops"
+func parseWebhookHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, headerValue, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid WEBHOOK_HEADERS entry %q (use Name: value)", strings.TrimSpace(entry))
+		}
+		headers.Add(name, strings.TrimSpace(headerValue))
+	}
+	return headers, nil
+}
+
+// webhookSignature is the value of webhookSignatureHeader for body
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkWebhookURL fails unless raw, the value of the variable name, is an
+// absolute http or https URL
+func checkWebhookURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid %s %q (use an absolute https:// URL)", name, raw)
+	}
+	return nil
+}
+
+// webhookNotifier posts state changes as JSON to any HTTP endpoint
+type webhookNotifier struct {
+	url      string
+	template *template.Template // renders the body; nil posts webhookPayload
+	headers  http.Header        // added to every request, e.g. for authentication
+	secret   string             // signs the body when set
+	client   *http.Client
+}
+
+func newWebhookNotifier(config Config) *webhookNotifier {
+	return &webhookNotifier{
+		url:      config.WebhookURL,
+		template: config.WebhookTemplate,
+		headers:  config.WebhookHeaders,
+		secret:   config.WebhookSecret,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *webhookNotifier) name() string {
+	return "webhook"
+}
+
+// send posts the body of event; any 2xx answer counts as delivered
+func (w *webhookNotifier) send(ctx context.Context, event store.Event) error {
+	body, err := renderWebhookBody(w.template, event)
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, body)
+}
+
+// sendDailyDigest posts the digest as JSON of kind "digest", whether or
+// not WEBHOOK_TEMPLATE renders events
+func (w *webhookNotifier) sendDailyDigest(ctx context.Context, digest dailyDigest) error {
+	body, err := json.Marshal(struct {
+		Kind  string `json:"kind"`
+		Title string `json:"title"`
+		dailyDigest
+	}{"digest", digest.title(), digest})
+	if err != nil {
+		return err
+	}
+	return w.post(ctx, body)
+}
+
+// post posts body with the configured headers and signature
+func (w *webhookNotifier) post(ctx context.Context, body []byte) error {
+	headers := w.headers
+	if w.secret != "" {
+		headers = headers.Clone()
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		headers.Set(webhookSignatureHeader, webhookSignature(w.secret, body))
+	}
+	return postJSON(ctx, w.client, w.url, body, headers)
+}
@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"log"
+	"strings"
+
+	"certs-n-status/store"
+)
+
+// writeBatchSize is how many results are written per store call; with
+// several commands per result that keeps Redis pipelines at a few hundred
+// commands
+const writeBatchSize = 100
+
+// writeResults is a cycle's single writer: the check goroutines send their
+// results on the returned channel and it saves them in batches of
+// writeBatchSize, so they never talk to the store themselves. Close the
+// channel when the cycle is done and wait for done before the next cycle.
+func (ec *EndpointChecker) writeResults(kind string) (results chan<- store.Result, done <-chan struct{}) {
+	ch := make(chan store.Result, writeBatchSize)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		batch := make([]store.Result, 0, writeBatchSize)
+		for result := range ch {
+			batch = append(batch, result)
+			if len(batch) == writeBatchSize {
+				ec.saveResults(kind, batch)
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			ec.saveResults(kind, batch)
+		}
+	}()
+	return ch, finished
+}
+
+// saveResults marks the results checked in a maintenance window, writes
+// them with a single store call, retrying once, and then publishes the state
+// changes they caused and sends the alert reminders due for them
+func (ec *EndpointChecker) saveResults(kind string, results []store.Result) {
+	ec.markMaintenance(results)
+	events := ec.detectTransitions(results)
+	err := ec.trySaveResults(results)
+	if err != nil {
+		log.Printf("[WARN] Failed to store %d %s results, retrying: %v", len(results), kind, err)
+		err = ec.trySaveResults(results)
+	}
+	if err != nil {
+		endpoints := make([]string, 0, len(results))
+		for _, result := range results {
+			endpoints = append(endpoints, result.Endpoint)
+		}
+		log.Printf("[ERROR] Failed to store %d %s results for %s: %v", len(results), kind, strings.Join(endpoints, ", "), err)
+		return
+	}
+	ec.publishEvents(events)
+	ec.remindAlerts(results)
+}
+
+// trySaveResults makes one SaveResults attempt bounded by STORAGE_TIMEOUT
+func (ec *EndpointChecker) trySaveResults(results []store.Result) error {
+	ctx, cancel := ec.storeContext()
+	defer cancel()
+	return ec.store.SaveResults(ctx, results)
+}
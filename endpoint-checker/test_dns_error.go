@@ -1,3 +1,6 @@
+//go:build ignore
+
+// Standalone DNS error detection probe: go run test_dns_error.go
 package main
 
 import (
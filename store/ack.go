@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AckKeyPrefix prefixes the per-endpoint key holding an acknowledgement,
+// which expires with it
+const AckKeyPrefix = "ack:"
+
+// AckIndexKey is the sorted set of acknowledged endpoints scored by the
+// Unix milliseconds their acknowledgement expires at, so they can be
+// listed without a SCAN
+const AckIndexKey = "acks"
+
+// Ack acknowledges a known problem with an endpoint until a given time:
+// the dashboard mutes it and its state changes are published marked as
+// acknowledged
+type Ack struct {
+	Endpoint string    `json:"endpoint"`
+	Reason   string    `json:"reason"`
+	User     string    `json:"user,omitempty"` // dashboard login of whoever acknowledged it
+	At       time.Time `json:"at"`
+	Until    time.Time `json:"until"`
+}
+
+// Acknowledge stores ack, replacing any earlier acknowledgement of its
+// endpoint. It expires on its own at ack.Until.
+func (s *RedisStore) Acknowledge(ctx context.Context, ack Ack) error {
+	ttl := time.Until(ack.Until)
+	if ttl <= 0 {
+		return errors.New("acknowledgement already expired")
+	}
+	payload, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.keys.Ack(ack.Endpoint), payload, ttl)
+	pipe.ZAdd(ctx, s.keys.Key(AckIndexKey), redis.Z{Score: float64(ack.Until.UnixMilli()), Member: ack.Endpoint})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Unacknowledge removes the acknowledgement of endpoint and reports
+// whether there was one
+func (s *RedisStore) Unacknowledge(ctx context.Context, endpoint string) (bool, error) {
+	pipe := s.client.TxPipeline()
+	removed := pipe.Del(ctx, s.keys.Ack(endpoint))
+	pipe.ZRem(ctx, s.keys.Key(AckIndexKey), endpoint)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// Acks returns the acknowledgements in effect, soonest expiring first,
+// dropping expired ones from the index
+func (s *RedisStore) Acks(ctx context.Context) ([]Ack, error) {
+	index := s.keys.Key(AckIndexKey)
+	pipe := s.client.Pipeline()
+	pipe.ZRemRangeByScore(ctx, index, "-inf", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	members := pipe.ZRange(ctx, index, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	endpoints := members.Val()
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		keys[i] = s.keys.Ack(endpoint)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	acks := make([]Ack, 0, len(values))
+	for _, value := range values {
+		// Keys expire a little ahead of their index entries
+		payload, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var ack Ack
+		if err := json.Unmarshal([]byte(payload), &ack); err != nil {
+			continue
+		}
+		acks = append(acks, ack)
+	}
+	return acks, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestAcks tests storing, listing, expiring and removing acknowledgements
+func TestAcks(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	short := Ack{Endpoint: "https://a.example.com", Reason: "migrating DNS", User: "alice", At: now, Until: now.Add(time.Hour)}
+	long := Ack{Endpoint: "https://b.example.com", Reason: "decommissioned", At: now, Until: now.Add(24 * time.Hour)}
+	for _, ack := range []Ack{long, short} {
+		if err := s.Acknowledge(ctx, ack); err != nil {
+			t.Fatalf("Acknowledge(%s) error = %v", ack.Endpoint, err)
+		}
+	}
+	if err := s.Acknowledge(ctx, Ack{Endpoint: "https://c.example.com", Until: now.Add(-time.Minute)}); err == nil {
+		t.Error("Acknowledge() of an expired ack succeeded")
+	}
+
+	acks, err := s.Acks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acks) != 2 || !acks[0].Until.Equal(short.Until) || acks[0].User != "alice" || acks[1].Reason != long.Reason {
+		t.Errorf("Acks() = %+v, want %s then %s", acks, short.Endpoint, long.Endpoint)
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if acks, _ := s.Acks(ctx); len(acks) != 1 || acks[0].Endpoint != long.Endpoint {
+		t.Errorf("Acks() after the first expired = %+v, want only %s", acks, long.Endpoint)
+	}
+
+	for _, want := range []bool{true, false} {
+		if removed, err := s.Unacknowledge(ctx, long.Endpoint); err != nil || removed != want {
+			t.Errorf("Unacknowledge() = %v, %v; want %v", removed, err, want)
+		}
+	}
+	if acks, _ := s.Acks(ctx); len(acks) != 0 {
+		t.Errorf("Acks() after removal = %+v, want none", acks)
+	}
+}
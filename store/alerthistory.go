@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AlertHistoryKey is the stream of every notification the checker sent or
+// gave up on, for post-incident reviews
+const AlertHistoryKey = "notifications:history"
+
+// DefaultAlertHistoryMaxLen and DefaultAlertHistoryMaxAge bound
+// AlertHistoryKey; the oldest notifications are trimmed past either
+const (
+	DefaultAlertHistoryMaxLen = 10000
+	DefaultAlertHistoryMaxAge = 30 * 24 * time.Hour
+)
+
+// AlertRecord is one notification of an event, delivered or not
+type AlertRecord struct {
+	ID        string    `json:"id"`
+	Endpoint  string    `json:"endpoint"` // "" for notifications about no endpoint, such as a digest
+	Kind      string    `json:"kind"`     // of the event, see Event
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	Notifier  string    `json:"notifier"`        // e.g. "webhook" or "Slack"
+	Route     string    `json:"route,omitempty"` // the ALERT_ROUTES_FILE route that matched the endpoint, as the checker logs it
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"` // of the last attempt of an undelivered notification
+	Attempts  int       `json:"attempts"`
+	At        time.Time `json:"at"`
+}
+
+// SetAlertHistoryRetention caps AlertHistoryKey at maxLen notifications and
+// drops those older than maxAge whenever one is added. Zero disables
+// either limit.
+func (s *RedisStore) SetAlertHistoryRetention(maxLen int64, maxAge time.Duration) {
+	s.alertsMaxLen = maxLen
+	s.alertsMaxAge = maxAge
+}
+
+// AddAlertRecord appends a notification to AlertHistoryKey, trimming it to
+// the retention, in one round trip
+func (s *RedisStore) AddAlertRecord(ctx context.Context, record AlertRecord) error {
+	values := []string{
+		"endpoint", record.Endpoint,
+		"kind", record.Kind,
+		"old", record.Old,
+		"new", record.New,
+		"notifier", record.Notifier,
+		"delivered", strconv.FormatBool(record.Delivered),
+		"attempts", strconv.Itoa(record.Attempts),
+		"at", record.At.UTC().Format(time.RFC3339Nano),
+	}
+	if record.Route != "" {
+		values = append(values, "route", record.Route)
+	}
+	if record.Error != "" {
+		values = append(values, "error", record.Error)
+	}
+
+	key := s.keys.Key(AlertHistoryKey)
+	pipe := s.client.Pipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, MaxLen: s.alertsMaxLen, Values: values})
+	if s.alertsMaxAge > 0 {
+		pipe.XTrimMinID(ctx, key, strconv.FormatInt(time.Now().Add(-s.alertsMaxAge).UnixMilli(), 10))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AlertHistory returns up to limit notifications recorded since, newest
+// first. A non-empty endpoint only returns that endpoint's notifications.
+func (s *RedisStore) AlertHistory(ctx context.Context, endpoint string, since time.Time, limit int) ([]AlertRecord, error) {
+	start := "-"
+	if !since.IsZero() {
+		start = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+
+	records := []AlertRecord{}
+	end := "+"
+	for len(records) < limit {
+		batch, err := s.client.XRevRangeN(ctx, s.keys.Key(AlertHistoryKey), end, start, int64(limit)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range batch {
+			record := parseAlertRecord(msg)
+			if (endpoint == "" || record.Endpoint == endpoint) && len(records) < limit {
+				records = append(records, record)
+			}
+		}
+		if len(batch) < limit {
+			break
+		}
+		end = "(" + batch[len(batch)-1].ID
+	}
+	return records, nil
+}
+
+func parseAlertRecord(msg redis.XMessage) AlertRecord {
+	field := func(name string) string {
+		value, _ := msg.Values[name].(string)
+		return value
+	}
+	record := AlertRecord{
+		ID:        msg.ID,
+		Endpoint:  field("endpoint"),
+		Kind:      field("kind"),
+		Old:       field("old"),
+		New:       field("new"),
+		Notifier:  field("notifier"),
+		Route:     field("route"),
+		Delivered: field("delivered") == "true",
+		Error:     field("error"),
+	}
+	record.Attempts, _ = strconv.Atoi(field("attempts"))
+	record.At, _ = time.Parse(time.RFC3339Nano, field("at"))
+	return record
+}
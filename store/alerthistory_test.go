@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestAlertHistory tests that notifications read back newest first, by
+// endpoint and since a time, and that the stream is trimmed by count and age
+func TestAlertHistory(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	records, err := s.AlertHistory(ctx, "", time.Time{}, 10)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("AlertHistory before any were added = %+v, %v", records, err)
+	}
+
+	// Stream IDs come from the server clock, so the records are two hours old
+	mr.SetTime(now.Add(-2 * time.Hour))
+	s.SetAlertHistoryRetention(3, 0)
+	for i := range 5 {
+		record := AlertRecord{
+			Endpoint:  fmt.Sprintf("https://%d.example.com", i%2),
+			Kind:      EventKindStatus,
+			Old:       StatusUp,
+			New:       StatusDown,
+			Notifier:  "webhook",
+			Route:     "route payments",
+			Delivered: i != 4,
+			Attempts:  1,
+			At:        now,
+		}
+		if i == 4 {
+			record.Error, record.Attempts = "server answered 503 Service Unavailable: ", 3
+		}
+		if err := s.AddAlertRecord(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err = s.AlertHistory(ctx, "", time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("AlertHistory = %d records, want the 3 kept", len(records))
+	}
+	newest := records[0]
+	if newest.ID == "" || newest.Endpoint != "https://0.example.com" || newest.Delivered || newest.Attempts != 3 ||
+		newest.Error == "" || newest.Route != "route payments" || newest.Notifier != "webhook" || !newest.At.Equal(now) {
+		t.Errorf("newest record = %+v, want the failed delivery", newest)
+	}
+	if !records[1].Delivered || records[1].Error != "" {
+		t.Errorf("second record = %+v, want a delivery", records[1])
+	}
+
+	records, _ = s.AlertHistory(ctx, "https://1.example.com", time.Time{}, 10)
+	if len(records) != 1 || records[0].Endpoint != "https://1.example.com" {
+		t.Errorf("AlertHistory of one endpoint = %+v", records)
+	}
+	records, _ = s.AlertHistory(ctx, "", time.Time{}, 2)
+	if len(records) != 2 {
+		t.Errorf("AlertHistory limited to 2 = %d records", len(records))
+	}
+	records, _ = s.AlertHistory(ctx, "", time.Now().Add(time.Hour), 10)
+	if len(records) != 0 {
+		t.Errorf("AlertHistory since a later time = %+v, want none", records)
+	}
+
+	// Adding a record drops those older than the maximum age
+	mr.SetTime(now)
+	s.SetAlertHistoryRetention(0, time.Hour)
+	if err := s.AddAlertRecord(ctx, AlertRecord{Endpoint: "https://0.example.com", Notifier: "Slack", Delivered: true, Attempts: 1, At: now}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := mr.Stream(AlertHistoryKey); len(entries) != 1 {
+		t.Errorf("history holds %d entries after an age trim, want 1", len(entries))
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AlertStateKey is the hash of what was last notified about each endpoint,
+// a JSON AlertState by endpoint
+const AlertStateKey = "alert_state"
+
+// AlertState is what the checker last notified about an endpoint, so that
+// repeats of the same condition can be held back and lasting outages
+// reminded of
+type AlertState struct {
+	Last       map[string]string    `json:"last,omitempty"`        // state last notified, by event kind
+	NotifiedAt map[string]time.Time `json:"notified_at,omitempty"` // when each "<kind>:<state>" was last notified
+	DownSince  time.Time            `json:"down_since,omitzero"`   // start of the outage last notified as down
+	HeldSince  time.Time            `json:"held_since,omitzero"`   // start of an outage whose down notification was held back
+	RemindedAt time.Time            `json:"reminded_at,omitzero"`  // of the last reminder of the outage since DownSince
+	Escalation int                  `json:"escalation,omitempty"`  // last escalation step notified of the outage since DownSince, from 1
+}
+
+// AlertStates returns the alert state of every endpoint that has one.
+// Entries that cannot be decoded are skipped.
+func (s *RedisStore) AlertStates(ctx context.Context) (map[string]AlertState, error) {
+	values, err := s.client.HGetAll(ctx, s.keys.Key(AlertStateKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]AlertState, len(values))
+	for endpoint, payload := range values {
+		var state AlertState
+		if err := json.Unmarshal([]byte(payload), &state); err != nil {
+			continue
+		}
+		states[endpoint] = state
+	}
+	return states, nil
+}
+
+// SaveAlertStates writes the alert states of the given endpoints with a
+// single HSET, replacing their earlier states
+func (s *RedisStore) SaveAlertStates(ctx context.Context, states map[string]AlertState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	values := make([]any, 0, 2*len(states))
+	for endpoint, state := range states {
+		payload, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		values = append(values, endpoint, payload)
+	}
+	return s.client.HSet(ctx, s.keys.Key(AlertStateKey), values...).Err()
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestAlertStates tests that alert states read back as saved and that
+// saving some leaves the others alone
+func TestAlertStates(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	states, err := s.AlertStates(ctx)
+	if err != nil || len(states) != 0 {
+		t.Fatalf("AlertStates before any were saved = %+v, %v", states, err)
+	}
+
+	down := AlertState{
+		Last:       map[string]string{EventKindStatus: StatusDown, EventKindCert: CertLevelWarning},
+		NotifiedAt: map[string]time.Time{"status:down": at, "cert:warning": at.Add(-time.Hour)},
+		DownSince:  at.Add(-time.Minute),
+		RemindedAt: at.Add(time.Hour),
+	}
+	up := AlertState{Last: map[string]string{EventKindStatus: StatusUp}, NotifiedAt: map[string]time.Time{"status:up": at}, HeldSince: at}
+	if err := s.SaveAlertStates(ctx, map[string]AlertState{"https://a.example.com": down, "https://b.example.com": down}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveAlertStates(ctx, map[string]AlertState{"https://b.example.com": up}); err != nil {
+		t.Fatal(err)
+	}
+	mr.HSet(AlertStateKey, "https://broken.example.com", "{")
+
+	states, err = s.AlertStates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]AlertState{"https://a.example.com": down, "https://b.example.com": up}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("AlertStates() = %+v, want %+v", states, want)
+	}
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigKey maps a key of the YAML config file to the environment variable
+// it stands for. Keys are dotted paths of nested mappings, e.g. "redis.addr"
+// for
+//
+//	redis:
+//	  addr: redis:6379
+type ConfigKey struct {
+	Path   string
+	Env    string
+	Secret bool // redacted by WriteConfig
+}
+
+// SharedConfigKeys are the storage and Redis settings read by both the
+// checker and the dashboard
+var SharedConfigKeys = []ConfigKey{
+	{Path: "storage.backend", Env: "STORAGE"},
+	{Path: "storage.database_url", Env: "DATABASE_URL", Secret: true},
+	{Path: "storage.timeout", Env: "STORAGE_TIMEOUT"},
+	{Path: "redis.addr", Env: "REDIS_ADDR"},
+	{Path: "redis.db", Env: "REDIS_DB"},
+	{Path: "redis.username", Env: "REDIS_USERNAME"},
+	{Path: "redis.password", Env: "REDIS_PASSWORD", Secret: true},
+	{Path: "redis.password_file", Env: "REDIS_PASSWORD_FILE"},
+	{Path: "redis.key_prefix", Env: "KEY_PREFIX"},
+	{Path: "redis.tls.enabled", Env: "REDIS_TLS"},
+	{Path: "redis.tls.ca_file", Env: "REDIS_TLS_CA_FILE"},
+	{Path: "redis.tls.cert_file", Env: "REDIS_TLS_CERT_FILE"},
+	{Path: "redis.tls.key_file", Env: "REDIS_TLS_KEY_FILE"},
+	{Path: "redis.tls.insecure", Env: "REDIS_TLS_INSECURE"},
+	{Path: "redis.pool.size", Env: "REDIS_POOL_SIZE"},
+	{Path: "redis.pool.min_idle_conns", Env: "REDIS_MIN_IDLE_CONNS"},
+	{Path: "redis.pool.timeout", Env: "REDIS_POOL_TIMEOUT"},
+	{Path: "redis.pool.read_timeout", Env: "REDIS_READ_TIMEOUT"},
+	{Path: "redis.pool.write_timeout", Env: "REDIS_WRITE_TIMEOUT"},
+}
+
+// ApplyConfigFile reads the YAML config file at path and sets the
+// environment variable of each of its keys that the environment leaves
+// empty, so variables, such as secrets injected by the orchestrator,
+// override the file. Lists are joined with commas. Parsing is strict: a key
+// that is not in keys fails, naming its line, unless it is under one of the
+// skipped top-level sections, which other binaries read from the same file.
+func ApplyConfigFile(path string, keys []ConfigKey, skip ...string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	known := make(map[string]string, len(keys))
+	for _, key := range keys {
+		known[key.Path] = key.Env
+	}
+	values := make(map[string]string)
+	var problems []error
+	var walk func(node *yaml.Node, prefix string)
+	walk = func(node *yaml.Node, prefix string) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name, value := node.Content[i], node.Content[i+1]
+			keyPath := name.Value
+			if prefix != "" {
+				keyPath = prefix + "." + name.Value
+			} else if slices.Contains(skip, keyPath) {
+				continue
+			}
+			env, isKey := known[keyPath]
+			switch {
+			case value.Kind == yaml.MappingNode && !isKey:
+				walk(value, keyPath)
+			case !isKey:
+				problems = append(problems, fmt.Errorf("%s:%d: unknown key %s", path, name.Line, keyPath))
+			case value.Kind == yaml.ScalarNode:
+				values[env] = value.Value
+			case value.Kind == yaml.SequenceNode && scalars(value):
+				items := make([]string, len(value.Content))
+				for j, item := range value.Content {
+					items[j] = item.Value
+				}
+				values[env] = strings.Join(items, ",")
+			default:
+				problems = append(problems, fmt.Errorf("%s:%d: %s takes a value or a list of values", path, name.Line, keyPath))
+			}
+		}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a mapping of keys", path)
+	}
+	walk(root, "")
+	if len(problems) > 0 {
+		return errors.Join(problems...)
+	}
+
+	for env, value := range values {
+		if os.Getenv(env) == "" {
+			if err := os.Setenv(env, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scalars(node *yaml.Node) bool {
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteConfig writes the settings of keys in the layout of the config file:
+// the value e resolved for each variable it read, including defaults, and
+// the environment's value of the others, leaving out unset ones. Secrets
+// are written as <redacted>.
+func (e *Env) WriteConfig(w io.Writer, keys []ConfigKey) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range keys {
+		value, ok := e.resolved[key.Env]
+		if !ok {
+			value = os.Getenv(key.Env)
+		}
+		if value == "" {
+			continue
+		}
+		if key.Secret {
+			value = "<redacted>"
+		}
+
+		node := root
+		names := strings.Split(key.Path, ".")
+		for _, name := range names[:len(names)-1] {
+			node = mappingEntry(node, name)
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: names[len(names)-1]},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// mappingEntry returns the mapping under name in node, adding it if missing
+func mappingEntry(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	entry := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, entry)
+	return entry
+}
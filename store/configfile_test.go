@@ -0,0 +1,145 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfigKeys = append(slices.Clone(SharedConfigKeys),
+	ConfigKey{Path: "checker.status_check_interval", Env: "STATUS_CHECK_INTERVAL"},
+	ConfigKey{Path: "checker.audit_headers", Env: "AUDIT_HEADERS"},
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestApplyConfigFile tests that the file sets the variables the
+// environment leaves empty, and that unknown keys fail with their line
+func TestApplyConfigFile(t *testing.T) {
+	for _, key := range testConfigKeys {
+		t.Setenv(key.Env, "")
+	}
+	t.Setenv("REDIS_PASSWORD", "from-env")
+
+	path := writeConfigFile(t, `
+redis:
+  addr: redis:6379
+  db: 2
+  password: from-file
+  tls:
+    enabled: true
+checker:
+  status_check_interval: 30s
+  audit_headers: [Strict-Transport-Security, X-Frame-Options]
+dashboard:
+  server_port: 8081
+`)
+	if err := ApplyConfigFile(path, testConfigKeys, "dashboard"); err != nil {
+		t.Fatal(err)
+	}
+	for env, want := range map[string]string{
+		"REDIS_ADDR":            "redis:6379",
+		"REDIS_DB":              "2",
+		"REDIS_PASSWORD":        "from-env",
+		"REDIS_TLS":             "true",
+		"STATUS_CHECK_INTERVAL": "30s",
+		"AUDIT_HEADERS":         "Strict-Transport-Security,X-Frame-Options",
+	} {
+		if got := os.Getenv(env); got != want {
+			t.Errorf("%s = %q, want %q", env, got, want)
+		}
+	}
+
+	if err := ApplyConfigFile(writeConfigFile(t, ""), testConfigKeys); err != nil {
+		t.Errorf("ApplyConfigFile() of an empty file = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"unknown keys", "redis:\n  adr: redis:6379\nchecker:\n  status_interval: 1m\n", []string{":2: unknown key redis.adr", ":4: unknown key checker.status_interval"}},
+		{"unskipped section", "dashboard:\n  server_port: 8081\n", []string{":2: unknown key dashboard.server_port"}},
+		{"mapping as a value", "redis:\n  addr:\n    host: redis\n", []string{":2: redis.addr takes a value or a list of values"}},
+		{"not a mapping", "- redis\n", []string{"not a mapping"}},
+		{"invalid YAML", "redis: [\n", []string{"failed to parse config file"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyConfigFile(writeConfigFile(t, tt.content), testConfigKeys)
+			if err == nil {
+				t.Fatal("ApplyConfigFile() succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ApplyConfigFile() = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+	if err := ApplyConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), testConfigKeys); err == nil {
+		t.Error("ApplyConfigFile() of a missing file succeeded")
+	}
+}
+
+// TestWriteConfig tests that the effective settings are written in the
+// file's layout, defaults included and secrets redacted
+func TestWriteConfig(t *testing.T) {
+	for _, key := range testConfigKeys {
+		t.Setenv(key.Env, "")
+	}
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	t.Setenv("REDIS_PASSWORD", "hunter2")
+	t.Setenv("REDIS_TLS", "true")
+	t.Setenv("KEY_PREFIX", "prod:")
+
+	var env Env
+	env.String("REDIS_ADDR", "localhost:6379")
+	env.Int("REDIS_DB", 0)
+	env.Duration("STATUS_CHECK_INTERVAL", time.Minute)
+	env.List("AUDIT_HEADERS")
+
+	var out bytes.Buffer
+	if err := env.WriteConfig(&out, testConfigKeys); err != nil {
+		t.Fatal(err)
+	}
+	want := `redis:
+  addr: redis:6379
+  db: "0"
+  password: <redacted>
+  key_prefix: 'prod:'
+  tls:
+    enabled: "true"
+checker:
+  status_check_interval: 1m0s
+`
+	if out.String() != want {
+		t.Errorf("WriteConfig() =\n%s\nwant\n%s", out.String(), want)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Error("WriteConfig() wrote a secret")
+	}
+
+	// The written configuration reads back
+	for _, key := range testConfigKeys {
+		t.Setenv(key.Env, "")
+	}
+	if err := ApplyConfigFile(writeConfigFile(t, out.String()), testConfigKeys); err != nil {
+		t.Fatalf("ApplyConfigFile() of the written configuration = %v", err)
+	}
+	if os.Getenv("KEY_PREFIX") != "prod:" || os.Getenv("STATUS_CHECK_INTERVAL") != "1m0s" {
+		t.Errorf("read back KEY_PREFIX %q and STATUS_CHECK_INTERVAL %q", os.Getenv("KEY_PREFIX"), os.Getenv("STATUS_CHECK_INTERVAL"))
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// DeadLetterKey is the list of notifications that could not be delivered,
+// newest first
+const DeadLetterKey = "notifications:dead_letter"
+
+// DeadLetterMaxLen caps DeadLetterKey, dropping the oldest entries
+const DeadLetterMaxLen = 1000
+
+// DeadLetter is a notification a notifier gave up on
+type DeadLetter struct {
+	Notifier string    `json:"notifier"` // e.g. "webhook" or "Slack"
+	Event    Event     `json:"event"`
+	Error    string    `json:"error"` // of the last attempt
+	Attempts int       `json:"attempts"`
+	At       time.Time `json:"at"`
+}
+
+// AddDeadLetter records a notification that could not be delivered
+func (s *RedisStore) AddDeadLetter(ctx context.Context, letter DeadLetter) error {
+	payload, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	key := s.keys.Key(DeadLetterKey)
+	pipe := s.client.TxPipeline()
+	pipe.LPush(ctx, key, payload)
+	pipe.LTrim(ctx, key, 0, DeadLetterMaxLen-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetters returns up to limit undelivered notifications, newest first
+func (s *RedisStore) DeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	payloads, err := s.client.LRange(ctx, s.keys.Key(DeadLetterKey), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]DeadLetter, 0, len(payloads))
+	for _, payload := range payloads {
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(payload), &letter); err != nil {
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestDeadLetters tests that undelivered notifications read back newest
+// first and that the list is capped
+func TestDeadLetters(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	letters, err := s.DeadLetters(ctx, 10)
+	if err != nil || len(letters) != 0 {
+		t.Fatalf("DeadLetters before any were added = %+v, %v", letters, err)
+	}
+
+	for i := range DeadLetterMaxLen + 5 {
+		letter := DeadLetter{
+			Notifier: "webhook",
+			Event:    Event{Endpoint: fmt.Sprintf("https://%d.example.com", i), Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: at},
+			Error:    "webhook answered 503 Service Unavailable",
+			Attempts: 3,
+			At:       at.Add(time.Duration(i) * time.Second),
+		}
+		if err := s.AddDeadLetter(ctx, letter); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	letters, err = s.DeadLetters(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 || letters[0].Event.Endpoint != fmt.Sprintf("https://%d.example.com", DeadLetterMaxLen+4) || letters[0].Attempts != 3 || !letters[0].At.Equal(at.Add((DeadLetterMaxLen+4)*time.Second)) {
+		t.Errorf("DeadLetters(2) = %+v, want the two newest", letters)
+	}
+	if n, _ := mr.List(DeadLetterKey); len(n) != DeadLetterMaxLen {
+		t.Errorf("dead letter list holds %d entries, want %d", len(n), DeadLetterMaxLen)
+	}
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env reads settings from environment variables, returning the default of
+// an unset variable. An invalid value also returns the default but is
+// recorded, so a binary can report every misconfiguration at startup
+// rather than run with a setting it was not given.
+type Env struct {
+	problems []error
+	resolved map[string]string // the value returned for each variable, see WriteConfig
+}
+
+// Add records a problem found while reading a setting some other way
+func (e *Env) Add(err error) {
+	if err != nil {
+		e.problems = append(e.problems, err)
+	}
+}
+
+// resolve records value as the setting of key and returns it
+func resolve[T any](e *Env, key string, value T) T {
+	if e.resolved == nil {
+		e.resolved = make(map[string]string)
+	}
+	switch v := any(value).(type) {
+	case []string:
+		e.resolved[key] = strings.Join(v, ",")
+	default:
+		e.resolved[key] = fmt.Sprint(v)
+	}
+	return value
+}
+
+// Err returns every recorded problem, one per line, or nil
+func (e *Env) Err() error {
+	return errors.Join(e.problems...)
+}
+
+// String returns key's value
+func (e *Env) String(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return resolve(e, key, value)
+	}
+	return resolve(e, key, defaultValue)
+}
+
+// List returns key's comma-separated values, dropping empty ones
+func (e *Env) List(key string) []string {
+	var list []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return resolve(e, key, list)
+}
+
+// Choice returns key's value, which must be one of choices; the first is
+// the default
+func (e *Env) Choice(key string, choices ...string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, choices[0])
+	}
+	if !slices.Contains(choices, value) {
+		e.Add(fmt.Errorf("unknown %s %q (use %s)", key, value, strings.Join(choices, " or ")))
+		return resolve(e, key, choices[0])
+	}
+	return resolve(e, key, value)
+}
+
+// Int returns key's value, which must be a non-negative integer
+func (e *Env) Int(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, defaultValue)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		e.Add(fmt.Errorf("invalid %s value %q (use a non-negative integer)", key, value))
+		return resolve(e, key, defaultValue)
+	}
+	return resolve(e, key, n)
+}
+
+// Int64 returns key's value, which must be a non-negative integer
+func (e *Env) Int64(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, defaultValue)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		e.Add(fmt.Errorf("invalid %s value %q (use a non-negative integer)", key, value))
+		return resolve(e, key, defaultValue)
+	}
+	return resolve(e, key, n)
+}
+
+// Float returns key's value, which must be a non-negative number
+func (e *Env) Float(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, defaultValue)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		e.Add(fmt.Errorf("invalid %s value %q (use a non-negative number)", key, value))
+		return resolve(e, key, defaultValue)
+	}
+	return resolve(e, key, f)
+}
+
+// Bool returns key's value, which must be true or false (or 1, 0 and the
+// other forms of strconv.ParseBool)
+func (e *Env) Bool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, defaultValue)
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		e.Add(fmt.Errorf("invalid %s value %q (use true or false)", key, value))
+		return resolve(e, key, defaultValue)
+	}
+	return resolve(e, key, b)
+}
+
+// Duration returns key's value, which must be a non-negative Go duration
+func (e *Env) Duration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return resolve(e, key, defaultValue)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		e.Add(fmt.Errorf("invalid %s value %q (use a duration such as 90s, 5m or 1h)", key, value))
+		return resolve(e, key, defaultValue)
+	}
+	return resolve(e, key, d)
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEnv tests that unset variables keep their defaults and that every
+// invalid value is reported rather than replaced by its default
+func TestEnv(t *testing.T) {
+	t.Setenv("TEST_NAME", "prod")
+	t.Setenv("TEST_LIST", " a, ,b ")
+	t.Setenv("TEST_INT", "3")
+	t.Setenv("TEST_INT64", "4")
+	t.Setenv("TEST_FLOAT", "0.5")
+	t.Setenv("TEST_BOOL", "true")
+	t.Setenv("TEST_DURATION", "90s")
+	t.Setenv("TEST_CHOICE", "json")
+
+	var env Env
+	if got := env.String("TEST_NAME", "dev"); got != "prod" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := env.String("TEST_UNSET", "dev"); got != "dev" {
+		t.Errorf("String() of an unset variable = %q", got)
+	}
+	if got := env.List("TEST_LIST"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("List() = %q", got)
+	}
+	if got := env.Choice("TEST_CHOICE", "text", "json"); got != "json" {
+		t.Errorf("Choice() = %q", got)
+	}
+	if got := env.Choice("TEST_UNSET", "text", "json"); got != "text" {
+		t.Errorf("Choice() of an unset variable = %q, want the first", got)
+	}
+	if got := env.Int("TEST_INT", 1); got != 3 {
+		t.Errorf("Int() = %d", got)
+	}
+	if got := env.Int64("TEST_INT64", 1); got != 4 {
+		t.Errorf("Int64() = %d", got)
+	}
+	if got := env.Float("TEST_FLOAT", 1); got != 0.5 {
+		t.Errorf("Float() = %v", got)
+	}
+	if got := env.Bool("TEST_BOOL", false); !got {
+		t.Errorf("Bool() = %v", got)
+	}
+	if got := env.Duration("TEST_DURATION", time.Minute); got != 90*time.Second {
+		t.Errorf("Duration() = %s", got)
+	}
+	if got := env.Duration("TEST_UNSET", time.Minute); got != time.Minute {
+		t.Errorf("Duration() of an unset variable = %s", got)
+	}
+	if err := env.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+
+	t.Setenv("TEST_INT", "zero")
+	t.Setenv("TEST_INT64", "-1")
+	t.Setenv("TEST_FLOAT", "fast")
+	t.Setenv("TEST_BOOL", "yes please")
+	t.Setenv("TEST_DURATION", "5 minutes")
+	t.Setenv("TEST_CHOICE", "yaml")
+	if got := env.Int("TEST_INT", 1); got != 1 {
+		t.Errorf("Int() of an invalid value = %d, want the default", got)
+	}
+	env.Int64("TEST_INT64", 1)
+	env.Float("TEST_FLOAT", 1)
+	env.Bool("TEST_BOOL", false)
+	if got := env.Duration("TEST_DURATION", time.Minute); got != time.Minute {
+		t.Errorf("Duration() of an invalid value = %s, want the default", got)
+	}
+	env.Choice("TEST_CHOICE", "text", "json")
+	env.Add(nil)
+
+	err := env.Err()
+	if err == nil {
+		t.Fatal("Err() = nil, want the invalid values")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Err() has %d lines, want one per invalid value:\n%v", len(lines), err)
+	}
+	for i, key := range []string{"TEST_INT", "TEST_INT64", "TEST_FLOAT", "TEST_BOOL", "TEST_DURATION"} {
+		if !strings.HasPrefix(lines[i], "invalid "+key+" value ") {
+			t.Errorf("line %d = %q, want the invalid %s", i+1, lines[i], key)
+		}
+	}
+	if lines[5] != `unknown TEST_CHOICE "yaml" (use text or json)` {
+		t.Errorf("line 6 = %q, want the unknown choice", lines[5])
+	}
+}
@@ -0,0 +1,518 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// EventsChannel is the Redis pub/sub channel state-change events are published on
+const EventsChannel = "certs-n-status:events"
+
+// EventStreamKey is the Redis stream every published event is also appended to
+const EventStreamKey = "events"
+
+// DefaultEventStreamMaxLen keeps months of events for a typical installation
+const DefaultEventStreamMaxLen = 10000
+
+// Event kinds
+const (
+	EventKindStatus      = "status"       // Old and New are StatusUp or StatusDown
+	EventKindCert        = "cert"         // Old and New are one of the CertLevel values
+	EventKindCertRenewed = "cert_renewed" // Old and New are the RFC 3339 NotAfter of the replaced and new certificate
+	EventKindErrorClass  = "error_class"  // Old and New are the ErrorClass values of an endpoint that stays down
+	EventKindCertChanged = "cert_changed" // Old and New are the fingerprints of the replaced and new certificate, detailed by CertChange
+	EventKindSLOBurn     = "slo_burn"     // Old and New are SLOStateOK or SLOStateBurning of the SLOEndpoint of a tag, detailed by SLO
+	EventKindPause       = "pause"        // Old and New are PauseStateActive or PauseStatePaused, by User and, when pausing, Until
+)
+
+// Whether an endpoint is checked, reported by pause events
+const (
+	PauseStateActive = "active"
+	PauseStatePaused = "paused"
+)
+
+// Endpoint availability reported by status events
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Certificate expiry levels reported by cert events, matching the
+// dashboard's thresholds
+const (
+	CertLevelOK       = "ok"
+	CertLevelWarning  = "warning"  // expires within CertWarningWindow
+	CertLevelCritical = "critical" // expires within CertCriticalWindow
+	CertLevelExpired  = "expired"
+)
+
+const (
+	CertWarningWindow  = 30 * 24 * time.Hour
+	CertCriticalWindow = 7 * 24 * time.Hour
+	// CertHysteresis is how far a certificate must be clear of a window
+	// before its level drops back, so one served near a threshold, e.g.
+	// alternately by two load balancers, does not flap between levels
+	CertHysteresis = 2 * 24 * time.Hour
+)
+
+// Assessments of a certificate change
+const (
+	CertChangeRenewal          = "renewal"           // same issuer, expiring no sooner: a renewal or reissue
+	CertChangeSuspicious       = "suspicious"        // another issuer, or expiring sooner than the replaced certificate
+	CertChangeUnexpectedIssuer = "unexpected_issuer" // an issuer other than the expected one
+)
+
+// CertChange tells what a cert_changed event changed
+type CertChange struct {
+	OldIssuer   string    `json:"old_issuer"`
+	NewIssuer   string    `json:"new_issuer"`
+	OldSerial   string    `json:"old_serial"`
+	NewSerial   string    `json:"new_serial"`
+	OldNotAfter time.Time `json:"old_not_after"`
+	NewNotAfter time.Time `json:"new_not_after"`
+	// ExpectedIssuer is the issuer the endpoint's certificates should have,
+	// as given to the checker; "" expects none in particular
+	ExpectedIssuer string `json:"expected_issuer,omitempty"`
+}
+
+// Assess tells whether the change looks like a renewal or not: one of
+// CertChangeRenewal, CertChangeSuspicious and CertChangeUnexpectedIssuer.
+// The expected issuer matches when it is part of the issuer's
+// distinguished name, ignoring case, e.g. "Let's Encrypt".
+func (c CertChange) Assess() string {
+	switch {
+	case c.ExpectedIssuer != "" && !strings.Contains(strings.ToLower(c.NewIssuer), strings.ToLower(c.ExpectedIssuer)):
+		return CertChangeUnexpectedIssuer
+	case c.OldIssuer != "" && c.NewIssuer != c.OldIssuer, c.NewNotAfter.Before(c.OldNotAfter):
+		return CertChangeSuspicious
+	}
+	return CertChangeRenewal
+}
+
+// Event describes an endpoint moving from one state to another, from Old
+// to New as its Kind defines them
+type Event struct {
+	Endpoint string    `json:"endpoint"`
+	Kind     string    `json:"kind"`
+	Old      string    `json:"old"`
+	New      string    `json:"new"`
+	At       time.Time `json:"at"`
+	// ErrorClass is the class of the failed check of status events going
+	// down and of error_class events
+	ErrorClass string `json:"error_class,omitempty"`
+	// NotAfter is the expiry of the new certificate of certificate events
+	NotAfter time.Time `json:"not_after,omitzero"`
+	// DownSince and FailedChecks describe the outage a status event going
+	// up ends, when known. The checker's reminders that an endpoint is
+	// still down, status events from down to down that are only notified
+	// and never published, carry DownSince as well, and so do its
+	// escalations.
+	DownSince    time.Time `json:"down_since,omitzero"`
+	FailedChecks int       `json:"failed_checks,omitempty"`
+	// CertChange details a cert_changed event
+	CertChange CertChange `json:"cert_change,omitzero"`
+	// SLO is the SLO of a slo_burn event, whose burn rate crossed its
+	// threshold
+	SLO SLOStatus `json:"slo,omitzero"`
+	// Escalation is the step an escalation reminder reaches, or the one the
+	// outage a notified recovery ends reached
+	Escalation int `json:"escalation,omitempty"`
+	// Acknowledged events happened while the endpoint had an Ack and
+	// InMaintenance ones during a MaintenanceWindow; neither is meant to
+	// alert anyone
+	Acknowledged  bool `json:"acknowledged,omitempty"`
+	InMaintenance bool `json:"in_maintenance,omitempty"`
+	// CheckedBy is the CHECKER_ID of the instance that produced the event
+	CheckedBy string `json:"checked_by,omitempty"`
+	// User paused or resumed the endpoint of a pause event, empty when a
+	// pause expired; Until is when a pause ends
+	User  string    `json:"user,omitempty"`
+	Until time.Time `json:"until,omitzero"`
+	// Test marks the synthetic events of the checker's notify-test, only
+	// notified, never published
+	Test bool `json:"test,omitempty"`
+}
+
+// StatusLevel reports whether a status code counts as up: any 2xx or 3xx
+// response. Errors (0), DNS failures (-1) and 4xx/5xx responses are down.
+func StatusLevel(statusCode int) string {
+	if statusCode >= 200 && statusCode < 400 {
+		return StatusUp
+	}
+	return StatusDown
+}
+
+// CertLevel classifies a certificate by how long it has left at now
+func CertLevel(notAfter, now time.Time) string {
+	left := notAfter.Sub(now)
+	switch {
+	case left <= 0:
+		return CertLevelExpired
+	case left < CertCriticalWindow:
+		return CertLevelCritical
+	case left < CertWarningWindow:
+		return CertLevelWarning
+	}
+	return CertLevelOK
+}
+
+// DaysLeft returns the days from now until notAfter, along with the
+// precise time left, negative once it has passed. Days are spans of 24
+// hours, not calendar days, so midnight and daylight saving changes make
+// no difference. They are rounded up while notAfter is ahead, so a
+// certificate expiring in 23 hours has 1 day left and 0 means it expires
+// right now, and down once it has passed, so one that expired 2 hours ago
+// did so -1 days ago. The dashboard, API and notifications all count days
+// left this way.
+func DaysLeft(notAfter, now time.Time) (days int, left time.Duration) {
+	left = notAfter.Sub(now)
+	days = int(left / (24 * time.Hour))
+	if left%(24*time.Hour) > 0 {
+		days++
+	} else if left%(24*time.Hour) < 0 {
+		days--
+	}
+	return days, left
+}
+
+// CertLevelRank orders the certificate levels from ok to expired
+func CertLevelRank(level string) int {
+	switch level {
+	case CertLevelWarning:
+		return 1
+	case CertLevelCritical:
+		return 2
+	case CertLevelExpired:
+		return 3
+	}
+	return 0
+}
+
+// NextCertLevel is the alert state machine of a certificate: given the
+// level it was at, "" when unknown, it returns the level of a check at
+// now that found it expiring at notAfter. A level rises as soon as a
+// window is entered, but only drops back once the certificate is
+// CertHysteresis clear of the window, so a certificate renewed two days
+// before its warning window ends is ok again at once, while one with 31
+// days left that was at warning stays there. Expiry has no hysteresis: a
+// certificate that is valid again is critical at worst.
+func NextCertLevel(previous string, notAfter, now time.Time) string {
+	level := CertLevel(notAfter, now)
+	if previous == "" || CertLevelRank(level) >= CertLevelRank(previous) {
+		return level
+	}
+	eased := CertLevel(notAfter.Add(-CertHysteresis), now)
+	if eased == CertLevelExpired {
+		eased = CertLevelCritical
+	}
+	if CertLevelRank(eased) < CertLevelRank(previous) {
+		return eased
+	}
+	return previous
+}
+
+// CurrentCertLevel returns the stored alert level of the certificate or,
+// for data stored without one, the level of its expiry at its last check;
+// "" before a certificate was checked
+func (d EndpointData) CurrentCertLevel() string {
+	switch {
+	case d.CertLevel != "":
+		return d.CertLevel
+	case d.SSLUpdated.IsZero():
+		return ""
+	}
+	return CertLevel(d.SSLExpiration, d.SSLUpdated)
+}
+
+// AlertState is the alert condition of an endpoint: StatusDown while its
+// last status check is down, otherwise the CurrentCertLevel of its
+// certificate, or CertLevelOK without one; "" before anything was checked
+func (d EndpointData) AlertState() string {
+	if d.HasStatus && StatusLevel(d.StatusCode) == StatusDown {
+		return StatusDown
+	}
+	if level := d.CurrentCertLevel(); level != "" {
+		return level
+	}
+	if d.HasStatus {
+		return CertLevelOK
+	}
+	return ""
+}
+
+// Transitions compares a result with the data stored before it and returns
+// an event for every state that changed. Nothing is reported for a check
+// with no previous value to compare against. A certificate with a different
+// NotAfter is reported as renewed, one with a different fingerprint, serial
+// number or issuer as changed, and an endpoint that stays down for another
+// reason as an error class change. A certificate's level follows
+// NextCertLevel from the level stored before, so one that simply aged
+// past a threshold is reported as well as one that was replaced, and one
+// hovering at a threshold only once.
+func Transitions(previous EndpointData, result Result) []Event {
+	var events []Event
+	at := result.CheckedAt.UTC()
+
+	if result.HasStatus && previous.HasStatus {
+		from, to := StatusLevel(previous.StatusCode), StatusLevel(result.StatusCode)
+		if from != to {
+			event := Event{Endpoint: result.Endpoint, Kind: EventKindStatus, Old: from, New: to, At: at}
+			if result.Error != nil {
+				event.ErrorClass = result.Error.Class
+			}
+			if to == StatusUp && previous.Error != nil && !previous.Error.Since.IsZero() {
+				event.DownSince, event.FailedChecks = previous.Error.Since.UTC(), previous.Error.Count
+			}
+			events = append(events, event)
+		} else if to == StatusDown && previous.Error != nil && result.Error != nil && previous.Error.Class != result.Error.Class {
+			events = append(events, Event{Endpoint: result.Endpoint, Kind: EventKindErrorClass, Old: previous.Error.Class, New: result.Error.Class, At: at, ErrorClass: result.Error.Class})
+		}
+	}
+	if result.Cert != nil && !previous.SSLExpiration.IsZero() {
+		notAfter := result.Cert.NotAfter.Truncate(time.Second).UTC()
+		if !notAfter.Equal(previous.SSLExpiration) {
+			events = append(events, Event{
+				Endpoint: result.Endpoint,
+				Kind:     EventKindCertRenewed,
+				Old:      previous.SSLExpiration.UTC().Format(time.RFC3339),
+				New:      notAfter.Format(time.RFC3339),
+				At:       at,
+				NotAfter: notAfter,
+			})
+		}
+		if previous.CertInfo != nil && certChanged(*previous.CertInfo, *result.Cert) {
+			events = append(events, Event{
+				Endpoint: result.Endpoint,
+				Kind:     EventKindCertChanged,
+				Old:      previous.CertInfo.Fingerprint,
+				New:      result.Cert.Fingerprint,
+				At:       at,
+				NotAfter: notAfter,
+				CertChange: CertChange{
+					OldIssuer:   previous.CertInfo.Issuer,
+					NewIssuer:   result.Cert.Issuer,
+					OldSerial:   previous.CertInfo.SerialNumber,
+					NewSerial:   result.Cert.SerialNumber,
+					OldNotAfter: previous.CertInfo.NotAfter.UTC(),
+					NewNotAfter: notAfter,
+				},
+			})
+		}
+		from := previous.CurrentCertLevel()
+		to := NextCertLevel(from, result.Cert.NotAfter, result.CheckedAt)
+		if from != to {
+			events = append(events, Event{Endpoint: result.Endpoint, Kind: EventKindCert, Old: from, New: to, At: at, NotAfter: notAfter})
+		}
+	}
+	for i := range events {
+		events[i].InMaintenance = result.InMaintenance
+	}
+	return events
+}
+
+// certChanged reports whether current is another certificate than
+// previous, comparing what both know of fingerprint, serial and issuer
+func certChanged(previous, current CertInfo) bool {
+	differ := func(a, b string) bool { return a != "" && b != "" && a != b }
+	return differ(previous.Fingerprint, current.Fingerprint) || differ(previous.SerialNumber, current.SerialNumber) || differ(previous.Issuer, current.Issuer)
+}
+
+// PublishEvents publishes each event as JSON on EventsChannel and appends
+// it to the EventStreamKey stream, both under the key prefix, in one round trip
+func (s *RedisStore) PublishEvents(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	pipe := s.client.Pipeline()
+	for _, event := range events {
+		if err := s.queueEvent(ctx, pipe, event); err != nil {
+			return err
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// queueEvent queues the publication of event and its stream entry on pipe
+func (s *RedisStore) queueEvent(ctx context.Context, pipe redis.Pipeliner, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	pipe.Publish(ctx, s.keys.Key(EventsChannel), payload)
+	values := []string{
+		"endpoint", event.Endpoint,
+		"kind", event.Kind,
+		"old", event.Old,
+		"new", event.New,
+		"at", event.At.UTC().Format(time.RFC3339),
+	}
+	if event.ErrorClass != "" {
+		values = append(values, "error_class", event.ErrorClass)
+	}
+	if !event.NotAfter.IsZero() {
+		values = append(values, "not_after", event.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if !event.DownSince.IsZero() {
+		values = append(values, "down_since", event.DownSince.UTC().Format(time.RFC3339), "failed_checks", strconv.Itoa(event.FailedChecks))
+	}
+	if event.CertChange != (CertChange{}) {
+		change, err := json.Marshal(event.CertChange)
+		if err != nil {
+			return err
+		}
+		values = append(values, "cert_change", string(change))
+	}
+	if event.SLO.Tag != "" {
+		slo, err := json.Marshal(event.SLO)
+		if err != nil {
+			return err
+		}
+		values = append(values, "slo", string(slo))
+	}
+	if event.Acknowledged {
+		values = append(values, "acknowledged", "true")
+	}
+	if event.InMaintenance {
+		values = append(values, "in_maintenance", "true")
+	}
+	if event.CheckedBy != "" {
+		values = append(values, "checked_by", event.CheckedBy)
+	}
+	if event.User != "" {
+		values = append(values, "user", event.User)
+	}
+	if !event.Until.IsZero() {
+		values = append(values, "until", event.Until.UTC().Format(time.RFC3339))
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.keys.Key(EventStreamKey),
+		MaxLen: s.eventsMaxLen,
+		Values: values,
+	})
+	return nil
+}
+
+// WatchEvents calls handle with every event published on EventsChannel
+// until ctx is done. go-redis resubscribes after a broken connection, so
+// events published while it is down are missed; the stream keeps them.
+func (s *RedisStore) WatchEvents(ctx context.Context, handle func(Event)) {
+	pubsub := s.client.Subscribe(ctx, s.keys.Key(EventsChannel))
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("[WARN] Ignoring malformed event %q: %v", msg.Payload, err)
+				continue
+			}
+			handle(event)
+		}
+	}
+}
+
+// StreamEvent is an event read back from the event stream
+type StreamEvent struct {
+	ID string `json:"id"`
+	Event
+}
+
+// Events returns up to limit events appended after the stream ID since, or
+// from the start of the stream when since is empty, oldest first. A
+// non-empty endpoint only returns that endpoint's events.
+func (s *RedisStore) Events(ctx context.Context, since, endpoint string, limit int) ([]StreamEvent, error) {
+	start := "-"
+	if since != "" {
+		start = "(" + since
+	}
+
+	events := []StreamEvent{}
+	for len(events) < limit {
+		batch, err := s.client.XRangeN(ctx, s.keys.Key(EventStreamKey), start, "+", int64(limit)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range batch {
+			event := parseStreamEvent(msg)
+			if (endpoint == "" || event.Endpoint == endpoint) && len(events) < limit {
+				events = append(events, event)
+			}
+		}
+		if len(batch) < limit {
+			break
+		}
+		start = "(" + batch[len(batch)-1].ID
+	}
+	return events, nil
+}
+
+// RecentEvents returns up to limit events appended before the stream ID
+// before, or up to the end of the stream when before is empty, newest first.
+// Callers walk back through the stream by passing the last returned ID.
+func (s *RedisStore) RecentEvents(ctx context.Context, before string, limit int) ([]StreamEvent, error) {
+	end := "+"
+	if before != "" {
+		end = "(" + before
+	}
+
+	batch, err := s.client.XRevRangeN(ctx, s.keys.Key(EventStreamKey), end, "-", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]StreamEvent, 0, len(batch))
+	for _, msg := range batch {
+		events = append(events, parseStreamEvent(msg))
+	}
+	return events, nil
+}
+
+func parseStreamEvent(msg redis.XMessage) StreamEvent {
+	field := func(name string) string {
+		value, _ := msg.Values[name].(string)
+		return value
+	}
+	event := StreamEvent{ID: msg.ID, Event: Event{
+		Endpoint:      field("endpoint"),
+		Kind:          field("kind"),
+		Old:           field("old"),
+		New:           field("new"),
+		ErrorClass:    field("error_class"),
+		Acknowledged:  field("acknowledged") == "true",
+		InMaintenance: field("in_maintenance") == "true",
+		CheckedBy:     field("checked_by"),
+		User:          field("user"),
+	}}
+	event.At, _ = time.Parse(time.RFC3339, field("at"))
+	if notAfter := field("not_after"); notAfter != "" {
+		event.NotAfter, _ = time.Parse(time.RFC3339, notAfter)
+	}
+	if downSince := field("down_since"); downSince != "" {
+		event.DownSince, _ = time.Parse(time.RFC3339, downSince)
+		event.FailedChecks, _ = strconv.Atoi(field("failed_checks"))
+	}
+	if until := field("until"); until != "" {
+		event.Until, _ = time.Parse(time.RFC3339, until)
+	}
+	if change := field("cert_change"); change != "" {
+		json.Unmarshal([]byte(change), &event.CertChange)
+	}
+	if slo := field("slo"); slo != "" {
+		json.Unmarshal([]byte(slo), &event.SLO)
+	}
+	return event
+}
@@ -0,0 +1,448 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestCertLevel tests the certificate expiry thresholds
+func TestCertLevel(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		want     string
+	}{
+		{"far off", now.Add(90 * day), CertLevelOK},
+		{"exactly 30 days", now.Add(30 * day), CertLevelOK},
+		{"within 30 days", now.Add(30*day - time.Second), CertLevelWarning},
+		{"within 7 days", now.Add(7*day - time.Second), CertLevelCritical},
+		{"expiring now", now, CertLevelExpired},
+		{"expired", now.Add(-day), CertLevelExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CertLevel(tt.notAfter, now); got != tt.want {
+				t.Errorf("CertLevel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDaysLeft tests that days left round up while a certificate is valid
+// and down once it expired, in spans of 24 hours whatever the midnights
+// and daylight saving changes in between
+func TestDaysLeft(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	utc := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		notAfter time.Time
+		wantDays int
+		wantLeft time.Duration
+	}{
+		{"30 days", utc, utc.Add(30 * day), 30, 30 * day},
+		{"a second short of 30 days", utc, utc.Add(30*day - time.Second), 30, 30*day - time.Second},
+		{"a second over 30 days", utc, utc.Add(30*day + time.Second), 31, 30*day + time.Second},
+		{"23 hours", utc, utc.Add(23 * time.Hour), 1, 23 * time.Hour},
+		{"expiring now", utc, utc, 0, 0},
+		{"expired 2 hours ago", utc, utc.Add(-2 * time.Hour), -1, -2 * time.Hour},
+		{"expired a day ago", utc, utc.Add(-day), -1, -day},
+		{"expired 36 hours ago", utc, utc.Add(-36 * time.Hour), -2, -36 * time.Hour},
+		{"across midnight", time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 1, 0, 0, time.UTC), 1, 2 * time.Minute},
+		{"expired at midnight", time.Date(2024, 3, 2, 0, 1, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), -1, -time.Minute},
+		// From midnight to midnight over the spring change is 23 hours,
+		// over the autumn change 25 hours
+		{"spring forward", time.Date(2024, 3, 31, 0, 0, 0, 0, berlin), time.Date(2024, 4, 1, 0, 0, 0, 0, berlin), 1, 23 * time.Hour},
+		{"fall back", time.Date(2024, 10, 27, 0, 0, 0, 0, berlin), time.Date(2024, 10, 28, 0, 0, 0, 0, berlin), 2, 25 * time.Hour},
+		{"zones differ", time.Date(2024, 3, 1, 12, 0, 0, 0, berlin), time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), 1, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days, left := DaysLeft(tt.notAfter, tt.now)
+			if days != tt.wantDays || left != tt.wantLeft {
+				t.Errorf("DaysLeft() = %d, %s; want %d, %s", days, left, tt.wantDays, tt.wantLeft)
+			}
+		})
+	}
+}
+
+// TestTransitions tests which results produce state-change events
+func TestTransitions(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	endpoint := "https://example.com"
+
+	status := func(code int) Result {
+		return Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: code}
+	}
+	cert := func(notAfter time.Time) Result {
+		return Result{Endpoint: endpoint, CheckedAt: now, Cert: &CertInfo{NotAfter: notAfter}}
+	}
+	storedStatus := EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: 200, StatusUpdated: now.Add(-time.Minute)}
+	storedCert := func(notAfter, checkedAt time.Time) EndpointData {
+		return EndpointData{Endpoint: endpoint, SSLExpiration: notAfter, SSLUpdated: checkedAt}
+	}
+	storedCertInfo := func(issuer, serial string, notAfter time.Time) EndpointData {
+		return EndpointData{Endpoint: endpoint, SSLExpiration: notAfter, SSLUpdated: now.Add(-time.Hour), CertInfo: &CertInfo{NotAfter: notAfter, Issuer: issuer, SerialNumber: serial, Fingerprint: "fp-" + serial}}
+	}
+	certInfo := func(issuer, serial string, notAfter time.Time) Result {
+		return Result{Endpoint: endpoint, CheckedAt: now, Cert: &CertInfo{NotAfter: notAfter, Issuer: issuer, SerialNumber: serial, Fingerprint: "fp-" + serial}}
+	}
+	event := func(kind, from, to string) Event {
+		return Event{Endpoint: endpoint, Kind: kind, Old: from, New: to, At: now}
+	}
+	certEvent := func(kind, from, to string, notAfter time.Time) Event {
+		return Event{Endpoint: endpoint, Kind: kind, Old: from, New: to, At: now, NotAfter: notAfter}
+	}
+
+	tests := []struct {
+		name     string
+		previous EndpointData
+		result   Result
+		want     []Event
+	}{
+		{"first status check", EndpointData{Endpoint: endpoint}, status(500), nil},
+		{"still up", storedStatus, status(301), nil},
+		{"goes down", storedStatus, status(503), []Event{event(EventKindStatus, StatusUp, StatusDown)}},
+		{"network error", storedStatus, status(0), []Event{event(EventKindStatus, StatusUp, StatusDown)}},
+		{"timed out", storedStatus, Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, Error: &CheckError{Class: ErrorClassTimeout}}, []Event{
+			{Endpoint: endpoint, Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: now, ErrorClass: ErrorClassTimeout},
+		}},
+		{"comes back", EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: -1}, status(200), []Event{event(EventKindStatus, StatusDown, StatusUp)}},
+		{"comes back after an outage", EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: 503, Error: &CheckError{Class: ErrorClassHTTP, Since: now.Add(-time.Hour), Count: 60}}, status(200), []Event{
+			{Endpoint: endpoint, Kind: EventKindStatus, Old: StatusDown, New: StatusUp, At: now, DownSince: now.Add(-time.Hour), FailedChecks: 60},
+		}},
+		{"still down", EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: 0}, status(404), nil},
+		{"down for another reason", EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: 0, Error: &CheckError{Class: ErrorClassTimeout}}, Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 502, Error: &CheckError{Class: ErrorClassHTTP}}, []Event{
+			{Endpoint: endpoint, Kind: EventKindErrorClass, Old: ErrorClassTimeout, New: ErrorClassHTTP, At: now, ErrorClass: ErrorClassHTTP},
+		}},
+		{"down for the same reason", EndpointData{Endpoint: endpoint, HasStatus: true, StatusCode: 503, Error: &CheckError{Class: ErrorClassHTTP}}, Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 502, Error: &CheckError{Class: ErrorClassHTTP}}, nil},
+		{"first cert check", EndpointData{Endpoint: endpoint}, cert(now.Add(day)), nil},
+		{"cert unchanged", storedCert(now.Add(60*day), now.Add(-time.Hour)), cert(now.Add(60 * day)), nil},
+		{"cert ages into warning", storedCert(now.Add(30*day-time.Minute), now.Add(-time.Hour)), cert(now.Add(30*day - time.Minute)), []Event{certEvent(EventKindCert, CertLevelOK, CertLevelWarning, now.Add(30*day-time.Minute))}},
+		{"cert expires", storedCert(now.Add(-time.Second), now.Add(-time.Hour)), cert(now.Add(-time.Second)), []Event{certEvent(EventKindCert, CertLevelCritical, CertLevelExpired, now.Add(-time.Second))}},
+		{"cert renewed", storedCert(now.Add(3*day), now.Add(-time.Hour)), cert(now.Add(90 * day)), []Event{
+			certEvent(EventKindCertRenewed, "2024-03-04T12:00:00Z", "2024-05-30T12:00:00Z", now.Add(90*day)),
+			certEvent(EventKindCert, CertLevelCritical, CertLevelOK, now.Add(90*day)),
+		}},
+		{"cert replaced early", storedCert(now.Add(60*day), now.Add(-time.Hour)), cert(now.Add(90*day + 500*time.Millisecond)), []Event{
+			certEvent(EventKindCertRenewed, "2024-04-30T12:00:00Z", "2024-05-30T12:00:00Z", now.Add(90*day)),
+		}},
+		{"cert reissued", storedCertInfo("CN=R11", "01", now.Add(60*day)), certInfo("CN=R11", "02", now.Add(60*day)), []Event{
+			{Endpoint: endpoint, Kind: EventKindCertChanged, Old: "fp-01", New: "fp-02", At: now, NotAfter: now.Add(60 * day), CertChange: CertChange{
+				OldIssuer: "CN=R11", NewIssuer: "CN=R11", OldSerial: "01", NewSerial: "02", OldNotAfter: now.Add(60 * day), NewNotAfter: now.Add(60 * day),
+			}},
+		}},
+		{"cert from another issuer", storedCertInfo("CN=R11", "01", now.Add(60*day)), certInfo("CN=Evil CA", "02", now.Add(90*day)), []Event{
+			certEvent(EventKindCertRenewed, "2024-04-30T12:00:00Z", "2024-05-30T12:00:00Z", now.Add(90*day)),
+			{Endpoint: endpoint, Kind: EventKindCertChanged, Old: "fp-01", New: "fp-02", At: now, NotAfter: now.Add(90 * day), CertChange: CertChange{
+				OldIssuer: "CN=R11", NewIssuer: "CN=Evil CA", OldSerial: "01", NewSerial: "02", OldNotAfter: now.Add(60 * day), NewNotAfter: now.Add(90 * day),
+			}},
+		}},
+		{"cert hovering at the warning window", EndpointData{Endpoint: endpoint, SSLExpiration: now.Add(30*day + time.Hour), SSLUpdated: now.Add(-time.Hour), CertLevel: CertLevelWarning}, cert(now.Add(30*day + time.Hour)), nil},
+		{"cert clear of the warning window", EndpointData{Endpoint: endpoint, SSLExpiration: now.Add(33 * day), SSLUpdated: now.Add(-time.Hour), CertLevel: CertLevelWarning}, cert(now.Add(33 * day)), []Event{
+			certEvent(EventKindCert, CertLevelWarning, CertLevelOK, now.Add(33*day)),
+		}},
+		{"cert details unchanged", storedCertInfo("CN=R11", "01", now.Add(60*day)), certInfo("CN=R11", "01", now.Add(60*day)), nil},
+		{"status result ignores cert", storedCert(now.Add(time.Hour), now.Add(-60*day)), status(200), nil},
+		{"down in maintenance", storedStatus, Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 503, InMaintenance: true}, []Event{
+			{Endpoint: endpoint, Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: now, InMaintenance: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Transitions(tt.previous, tt.result); !slices.Equal(got, tt.want) {
+				t.Errorf("Transitions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNextCertLevel tests the hysteresis of the certificate levels
+func TestNextCertLevel(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		name     string
+		previous string
+		left     time.Duration
+		want     string
+	}{
+		{"unknown", "", 29 * day, CertLevelWarning},
+		{"still ok", CertLevelOK, 31 * day, CertLevelOK},
+		{"enters warning", CertLevelOK, 30*day - time.Second, CertLevelWarning},
+		{"skips to expired", CertLevelOK, -time.Second, CertLevelExpired},
+		{"just out of warning", CertLevelWarning, 30 * day, CertLevelWarning},
+		{"within the hysteresis", CertLevelWarning, 32*day - time.Second, CertLevelWarning},
+		{"clear of warning", CertLevelWarning, 32 * day, CertLevelOK},
+		{"critical eases to warning", CertLevelCritical, 10 * day, CertLevelWarning},
+		{"critical stays near the window", CertLevelCritical, 8 * day, CertLevelCritical},
+		{"expired renewed", CertLevelExpired, 90 * day, CertLevelOK},
+		{"expired renewed briefly", CertLevelExpired, day, CertLevelCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextCertLevel(tt.previous, now.Add(tt.left), now); got != tt.want {
+				t.Errorf("NextCertLevel(%q, %s left) = %q, want %q", tt.previous, tt.left, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAlertState tests the alert condition shown for an endpoint
+func TestAlertState(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		data EndpointData
+		want string
+	}{
+		{"unchecked", EndpointData{}, ""},
+		{"up", EndpointData{HasStatus: true, StatusCode: 200}, CertLevelOK},
+		{"down", EndpointData{HasStatus: true, StatusCode: 503, CertLevel: CertLevelWarning}, StatusDown},
+		{"stored level", EndpointData{HasStatus: true, StatusCode: 200, SSLExpiration: now.Add(31 * 24 * time.Hour), SSLUpdated: now, CertLevel: CertLevelWarning}, CertLevelWarning},
+		{"level of the expiry", EndpointData{SSLExpiration: now.Add(time.Hour), SSLUpdated: now}, CertLevelCritical},
+	}
+	for _, tt := range tests {
+		if got := tt.data.AlertState(); got != tt.want {
+			t.Errorf("%s: AlertState() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCertChangeAssess tests telling renewals from suspicious changes
+func TestCertChangeAssess(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	letsEncrypt := "CN=R11,O=Let's Encrypt,C=US"
+	tests := []struct {
+		name   string
+		change CertChange
+		want   string
+	}{
+		{"renewal", CertChange{OldIssuer: letsEncrypt, NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now.AddDate(0, 3, 0)}, CertChangeRenewal},
+		{"reissue", CertChange{OldIssuer: letsEncrypt, NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now}, CertChangeRenewal},
+		{"old issuer unknown", CertChange{NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now.AddDate(0, 3, 0)}, CertChangeRenewal},
+		{"another issuer", CertChange{OldIssuer: letsEncrypt, NewIssuer: "CN=DigiCert TLS RSA SHA256 2020 CA1,O=DigiCert Inc,C=US", OldNotAfter: now, NewNotAfter: now.AddDate(1, 0, 0)}, CertChangeSuspicious},
+		{"shorter validity", CertChange{OldIssuer: letsEncrypt, NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now.AddDate(0, 0, -1)}, CertChangeSuspicious},
+		{"expected issuer", CertChange{OldIssuer: "CN=Other", NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now, ExpectedIssuer: "let's encrypt"}, CertChangeSuspicious},
+		{"unexpected issuer", CertChange{OldIssuer: letsEncrypt, NewIssuer: letsEncrypt, OldNotAfter: now, NewNotAfter: now.AddDate(0, 3, 0), ExpectedIssuer: "DigiCert"}, CertChangeUnexpectedIssuer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.change.Assess(); got != tt.want {
+				t.Errorf("Assess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPublishEvents tests that events reach pub/sub subscribers as JSON
+func TestPublishEvents(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+
+	sub := s.client.Subscribe(ctx, EventsChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events := []Event{
+		{Endpoint: "https://a.example.com", Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), ErrorClass: ErrorClassTimeout},
+		{Endpoint: "https://b.example.com", Kind: EventKindCert, Old: CertLevelOK, New: CertLevelWarning, At: time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC), NotAfter: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), Acknowledged: true},
+	}
+	if err := s.PublishEvents(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PublishEvents(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := sub.Channel()
+	for _, want := range events {
+		select {
+		case msg := <-messages:
+			var got Event
+			if err := json.Unmarshal([]byte(msg.Payload), &got); err != nil {
+				t.Fatalf("invalid event %q: %v", msg.Payload, err)
+			}
+			if got != want {
+				t.Errorf("event = %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event received, want %+v", want)
+		}
+	}
+}
+
+// TestWatchEvents tests that published events reach WatchEvents, and only
+// those of the store's key prefix
+func TestWatchEvents(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.SetKeyPrefix("prod:")
+
+	received := make(chan Event, 10)
+	go s.WatchEvents(ctx, func(event Event) { received <- event })
+	for mr.PubSubNumSub("prod:" + EventsChannel)["prod:"+EventsChannel] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	mr.Publish(EventsChannel, `{"endpoint":"https://other.example.com"}`)
+	mr.Publish("prod:"+EventsChannel, "not json")
+	want := Event{Endpoint: "https://example.com", Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	if err := s.PublishEvents(ctx, []Event{want}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("event = %+v, want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("unexpected event %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestEventStream tests appending events to the stream and reading them back
+func TestEventStream(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var published []Event
+	for i, endpoint := range []string{"https://a.example.com", "https://b.example.com", "https://a.example.com"} {
+		published = append(published, Event{Endpoint: endpoint, Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: at.Add(time.Duration(i) * time.Minute)})
+	}
+	published[0].ErrorClass = ErrorClassHTTP
+	published[0].CheckedBy = "eu-west"
+	published[1].NotAfter = at.Add(10 * 24 * time.Hour)
+	published[1].Acknowledged = true
+	published[1].CertChange = CertChange{OldIssuer: "CN=R10", NewIssuer: "CN=R11", OldSerial: "01", NewSerial: "02", OldNotAfter: at, NewNotAfter: at.Add(10 * 24 * time.Hour), ExpectedIssuer: "R1"}
+	published[1].SLO = SLOStatus{Tag: "payments", Objective: 99.9, Window: "30d", Checks: 43200, Good: 43000, Compliance: 99.5, BudgetRemaining: -3.6, BurnRate: 20, BurnRateThreshold: 14.4, Burning: true, UpdatedAt: at}
+	published[2].InMaintenance = true
+	published[2].New, published[2].DownSince, published[2].FailedChecks = StatusUp, at.Add(-time.Hour), 61
+	if err := s.PublishEvents(ctx, published); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.Events(ctx, "", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(published) {
+		t.Fatalf("Events() returned %d events, want %d", len(all), len(published))
+	}
+	for i, event := range all {
+		if event.Event != published[i] || event.ID == "" {
+			t.Errorf("event %d = %+v, want %+v with an ID", i, event, published[i])
+		}
+	}
+
+	tests := []struct {
+		name     string
+		since    string
+		endpoint string
+		limit    int
+		want     []StreamEvent
+	}{
+		{"limit", "", "", 2, all[:2]},
+		{"since", all[0].ID, "", 100, all[1:]},
+		{"since the last", all[2].ID, "", 100, []StreamEvent{}},
+		{"endpoint", "", "https://a.example.com", 100, []StreamEvent{all[0], all[2]}},
+		{"endpoint and limit", "", "https://a.example.com", 1, all[:1]},
+		{"endpoint and since", all[0].ID, "https://a.example.com", 1, all[2:]},
+		{"unknown endpoint", "", "https://c.example.com", 1, []StreamEvent{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Events(ctx, tt.since, tt.endpoint, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Events() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRecentEvents tests reading the stream back newest first
+func TestRecentEvents(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var published []Event
+	for i := 0; i < 3; i++ {
+		published = append(published, Event{Endpoint: "https://example.com", Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: at.Add(time.Duration(i) * time.Minute)})
+	}
+	if err := s.PublishEvents(ctx, published); err != nil {
+		t.Fatal(err)
+	}
+	all, err := s.Events(ctx, "", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		before string
+		limit  int
+		want   []StreamEvent
+	}{
+		{"all", "", 100, []StreamEvent{all[2], all[1], all[0]}},
+		{"limit", "", 2, []StreamEvent{all[2], all[1]}},
+		{"before", all[2].ID, 100, []StreamEvent{all[1], all[0]}},
+		{"before the first", all[0].ID, 100, []StreamEvent{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.RecentEvents(ctx, tt.before, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("RecentEvents() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEventStreamMaxLen tests that the stream is trimmed to its configured length
+func TestEventStreamMaxLen(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	s.SetEventStreamMaxLen(5)
+
+	for i := 0; i < 20; i++ {
+		event := Event{Endpoint: "https://example.com", Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: time.Now()}
+		if err := s.PublishEvents(ctx, []Event{event}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.client.XLen(ctx, EventStreamKey).Val(); n != 5 {
+		t.Errorf("stream length = %d, want 5", n)
+	}
+}
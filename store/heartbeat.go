@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HeartbeatKey is the hash the checker writes at the end of every check
+// cycle, so the dashboard can tell when it stopped updating
+const HeartbeatKey = "checker_heartbeat"
+
+// CheckersKey is the sorted set of the CHECKER_IDs of the checker
+// instances writing heartbeats, scored by the Unix time of their last one,
+// so the dashboard can tell which instances share the store
+const CheckersKey = "checkers"
+
+// CheckerRetention is how long a checker instance stays in CheckersKey
+// after its last heartbeat
+const CheckerRetention = 24 * time.Hour
+
+// HeartbeatStaleFactor is how many status intervals may pass without an
+// update before results count as stale
+const HeartbeatStaleFactor = 3
+
+// Heartbeat tells when the checker last finished a check cycle and how
+// often it checks. With several checker instances it is the last one of
+// any of them; CheckerID tells which.
+type Heartbeat struct {
+	At             time.Time
+	StatusInterval time.Duration
+	SSLInterval    time.Duration
+	CheckerID      string
+}
+
+// CheckerHeartbeat is the last heartbeat of one checker instance
+type CheckerHeartbeat struct {
+	ID string
+	At time.Time
+}
+
+// sortCheckers sorts checkers by their last heartbeat, the most recent
+// first, and instances heard from at the same second by ID
+func sortCheckers(checkers []CheckerHeartbeat) {
+	slices.SortFunc(checkers, func(a, b CheckerHeartbeat) int {
+		if c := b.At.Compare(a.At); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+// StaleAfter is how long a status result may go without an update before
+// it is stale: HeartbeatStaleFactor status intervals, or 0 when the
+// interval is unknown
+func (h Heartbeat) StaleAfter() time.Duration {
+	return HeartbeatStaleFactor * h.StatusInterval
+}
+
+// Stale reports whether the checker has not finished a cycle within
+// StaleAfter of now. A missing heartbeat is not stale.
+func (h Heartbeat) Stale(now time.Time) bool {
+	return !h.At.IsZero() && h.StaleAfter() > 0 && now.Sub(h.At) > h.StaleAfter()
+}
+
+func (s *RedisStore) SaveHeartbeat(ctx context.Context, heartbeat Heartbeat) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.keys.Key(HeartbeatKey),
+		"at", heartbeat.At.Unix(),
+		"status_interval", int64(heartbeat.StatusInterval.Seconds()),
+		"ssl_interval", int64(heartbeat.SSLInterval.Seconds()),
+		"checker_id", heartbeat.CheckerID,
+	)
+	if heartbeat.CheckerID != "" {
+		pipe.ZAdd(ctx, s.keys.Key(CheckersKey), redis.Z{Score: float64(heartbeat.At.Unix()), Member: heartbeat.CheckerID})
+		pipe.ZRemRangeByScore(ctx, s.keys.Key(CheckersKey), "-inf", "("+strconv.FormatInt(heartbeat.At.Add(-CheckerRetention).Unix(), 10))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) Checkers(ctx context.Context, since time.Time) ([]CheckerHeartbeat, error) {
+	members, err := s.client.ZRevRangeByScoreWithScores(ctx, s.keys.Key(CheckersKey), &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	checkers := make([]CheckerHeartbeat, 0, len(members))
+	for _, member := range members {
+		id, _ := member.Member.(string)
+		checkers = append(checkers, CheckerHeartbeat{ID: id, At: time.Unix(int64(member.Score), 0)})
+	}
+	return checkers, nil
+}
+
+func (s *RedisStore) Heartbeat(ctx context.Context) (Heartbeat, error) {
+	fields, err := s.client.HGetAll(ctx, s.keys.Key(HeartbeatKey)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Heartbeat{}, err
+	}
+	var heartbeat Heartbeat
+	if at, err := strconv.ParseInt(fields["at"], 10, 64); err == nil {
+		heartbeat.At = time.Unix(at, 0)
+	}
+	if seconds, err := strconv.ParseInt(fields["status_interval"], 10, 64); err == nil {
+		heartbeat.StatusInterval = time.Duration(seconds) * time.Second
+	}
+	if seconds, err := strconv.ParseInt(fields["ssl_interval"], 10, 64); err == nil {
+		heartbeat.SSLInterval = time.Duration(seconds) * time.Second
+	}
+	heartbeat.CheckerID = fields["checker_id"]
+	return heartbeat, nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestHeartbeat tests that a saved heartbeat reads back and when it counts
+// as stale
+func TestHeartbeat(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+
+		heartbeat, err := s.Heartbeat(ctx)
+		if err != nil {
+			t.Fatalf("Heartbeat before any was saved: %v", err)
+		}
+		if heartbeat != (Heartbeat{}) {
+			t.Errorf("Heartbeat before any was saved = %+v, want zero", heartbeat)
+		}
+
+		want := Heartbeat{At: time.Unix(1700000000, 0), StatusInterval: time.Minute, SSLInterval: time.Hour, CheckerID: "eu-west"}
+		if err := s.SaveHeartbeat(ctx, want); err != nil {
+			t.Fatalf("SaveHeartbeat: %v", err)
+		}
+		heartbeat, err = s.Heartbeat(ctx)
+		if err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+		if !heartbeat.At.Equal(want.At) || heartbeat.StatusInterval != want.StatusInterval || heartbeat.SSLInterval != want.SSLInterval || heartbeat.CheckerID != want.CheckerID {
+			t.Errorf("Heartbeat = %+v, want %+v", heartbeat, want)
+		}
+
+		tests := []struct {
+			name      string
+			heartbeat Heartbeat
+			since     time.Duration
+			want      bool
+		}{
+			{"recent", want, time.Minute, false},
+			{"at the limit", want, 3 * time.Minute, false},
+			{"past the limit", want, 3*time.Minute + time.Second, true},
+			{"no heartbeat", Heartbeat{}, 24 * time.Hour, false},
+			{"unknown interval", Heartbeat{At: want.At}, 24 * time.Hour, false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := tt.heartbeat.Stale(want.At.Add(tt.since)); got != tt.want {
+					t.Errorf("Stale after %v = %v, want %v", tt.since, got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+// TestCheckers tests that each checker instance's last heartbeat is kept
+// and instances are forgotten after CheckerRetention
+func TestCheckers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Unix(1700000000, 0)
+
+		for _, heartbeat := range []Heartbeat{
+			{At: now.Add(-2 * CheckerRetention), CheckerID: "retired"},
+			{At: now.Add(-10 * time.Minute), CheckerID: "us-east"},
+			{At: now.Add(-time.Minute), CheckerID: "eu-west"},
+			{At: now.Add(-30 * time.Second)}, // an older version without an ID
+			{At: now, CheckerID: "eu-west"},
+		} {
+			if err := s.SaveHeartbeat(ctx, heartbeat); err != nil {
+				t.Fatalf("SaveHeartbeat(%+v): %v", heartbeat, err)
+			}
+		}
+
+		checkers, err := s.Checkers(ctx, now.Add(-CheckerRetention))
+		if err != nil {
+			t.Fatalf("Checkers: %v", err)
+		}
+		want := []CheckerHeartbeat{{ID: "eu-west", At: now}, {ID: "us-east", At: now.Add(-10 * time.Minute)}}
+		if len(checkers) != len(want) {
+			t.Fatalf("Checkers = %+v, want %+v", checkers, want)
+		}
+		for i := range want {
+			if checkers[i].ID != want[i].ID || !checkers[i].At.Equal(want[i].At) {
+				t.Errorf("Checkers[%d] = %+v, want %+v", i, checkers[i], want[i])
+			}
+		}
+
+		if checkers, err := s.Checkers(ctx, time.Time{}); err != nil || len(checkers) != 2 {
+			t.Errorf("Checkers since ever = %+v, %v, want the retired one removed", checkers, err)
+		}
+		if checkers, err := s.Checkers(ctx, now.Add(-5*time.Minute)); err != nil || len(checkers) != 1 || checkers[0].ID != "eu-west" {
+			t.Errorf("Checkers of the last 5m = %+v, %v, want eu-west only", checkers, err)
+		}
+		if heartbeat, _ := s.Heartbeat(ctx); heartbeat.CheckerID != "eu-west" {
+			t.Errorf("Heartbeat().CheckerID = %q, want the last instance's", heartbeat.CheckerID)
+		}
+	})
+}
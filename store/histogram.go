@@ -0,0 +1,259 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LatencyHistogramKeyPrefix prefixes the per-endpoint hash of latency
+// histograms
+const LatencyHistogramKeyPrefix = "histogram:latency:"
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram
+// buckets, roughly log-scaled from 1ms to 30s
+var DefaultLatencyBuckets = []time.Duration{
+	1 * time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 5 * time.Millisecond, 7 * time.Millisecond,
+	10 * time.Millisecond, 15 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 50 * time.Millisecond, 70 * time.Millisecond,
+	100 * time.Millisecond, 150 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 500 * time.Millisecond, 700 * time.Millisecond,
+	1 * time.Second, 1500 * time.Millisecond, 2 * time.Second, 3 * time.Second, 5 * time.Second, 7 * time.Second,
+	10 * time.Second, 15 * time.Second, 20 * time.Second, 30 * time.Second,
+}
+
+// MaxLatencyBuckets caps the buckets, and with them the size of each histogram
+const MaxLatencyBuckets = 100
+
+// ParseLatencyBuckets parses comma-separated bucket bounds such as
+// "10ms,100ms,1s": whole milliseconds in increasing order
+func ParseLatencyBuckets(value string) ([]time.Duration, error) {
+	var bounds []time.Duration
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		bound, err := time.ParseDuration(field)
+		if err != nil || bound < time.Millisecond || bound%time.Millisecond != 0 {
+			return nil, fmt.Errorf("bucket %q is not whole milliseconds such as 5ms or 1.5s", field)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket %s does not exceed the one before", field)
+		}
+		bounds = append(bounds, bound)
+	}
+	if len(bounds) == 0 || len(bounds) > MaxLatencyBuckets {
+		return nil, fmt.Errorf("give 1 to %d buckets", MaxLatencyBuckets)
+	}
+	return bounds, nil
+}
+
+// LatencyWindow is a window the latency histograms cover: the last Slots
+// slots of Slot each, the current one included. A histogram rotates one
+// slot at a time, so the oldest slot is reset once the window moves past it.
+type LatencyWindow struct {
+	Name  string // "1h"
+	Slot  time.Duration
+	Slots int
+}
+
+// LatencyWindows are the windows of every latency histogram
+var LatencyWindows = []LatencyWindow{
+	{Name: "1h", Slot: 5 * time.Minute, Slots: 12},
+	{Name: "24h", Slot: time.Hour, Slots: 24},
+}
+
+// slot returns the number of the slot t falls in and its index in the ring
+func (w LatencyWindow) slot(t time.Time) (number int64, ring int64) {
+	number = t.Unix() / int64(w.Slot/time.Second)
+	return number, number % int64(w.Slots)
+}
+
+// LatencyPercentiles are the latency percentiles of an endpoint over a
+// LatencyWindow, estimated from its histogram by interpolating within the
+// bucket the rank falls in. A percentile in the bucket above every bound is
+// reported as the highest bound.
+type LatencyPercentiles struct {
+	Window string
+	Since  time.Time // start of the oldest slot counted
+	Count  int       // checks with a response in the window
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// SetLatencyBuckets sets the bucket bounds SaveResults counts latencies
+// in. Histograms written with other bounds keep their buckets until their
+// slots rotate.
+func (s *RedisStore) SetLatencyBuckets(bounds []time.Duration) {
+	s.latencyBuckets = bounds
+}
+
+// latencyBucketField names the bucket latency falls in by its bounds in
+// milliseconds, e.g. "70_100" for latencies above 70ms up to 100ms and
+// "30000_inf" above every bound, so readers need not know the buckets
+func latencyBucketField(bounds []time.Duration, latency time.Duration) string {
+	i, _ := slices.BinarySearch(bounds, latency)
+	lower, upper := "0", "inf"
+	if i > 0 {
+		lower = strconv.FormatInt(bounds[i-1].Milliseconds(), 10)
+	}
+	if i < len(bounds) {
+		upper = strconv.FormatInt(bounds[i].Milliseconds(), 10)
+	}
+	return lower + "_" + upper
+}
+
+// latencyBucket is a histogram bucket; Upper is 0 above every bound
+type latencyBucket struct {
+	Lower, Upper time.Duration
+}
+
+// upper returns Upper, the largest duration above every bound
+func (b latencyBucket) upper() time.Duration {
+	if b.Upper == 0 {
+		return math.MaxInt64
+	}
+	return b.Upper
+}
+
+// parseLatencyBucket parses a latencyBucketField
+func parseLatencyBucket(field string) (latencyBucket, bool) {
+	lower, upper, ok := strings.Cut(field, "_")
+	lowerMs, err := strconv.ParseInt(lower, 10, 64)
+	if !ok || err != nil {
+		return latencyBucket{}, false
+	}
+	bucket := latencyBucket{Lower: time.Duration(lowerMs) * time.Millisecond}
+	if upper != "inf" {
+		upperMs, err := strconv.ParseInt(upper, 10, 64)
+		if err != nil || upperMs <= lowerMs {
+			return latencyBucket{}, false
+		}
+		bucket.Upper = time.Duration(upperMs) * time.Millisecond
+	}
+	return bucket, true
+}
+
+// recordLatencyScript adds a check to the histogram hash KEYS[1] in the
+// bucket ARGV[1] of each window given by the triples of ARGV from 2 on:
+// window name, ring index and slot number. A ring index holding an older
+// slot is reset first, so each window keeps its slots' counts only:
+//
+//	<window>:<ring>          slot number the ring index counts
+//	<window>:<ring>:<bucket> checks of the slot in the bucket
+var recordLatencyScript = `
+for i = 2, #ARGV, 3 do
+	local ring = ARGV[i] .. ':' .. ARGV[i + 1]
+	if redis.call('HGET', KEYS[1], ring) ~= ARGV[i + 2] then
+		local prefix = ring .. ':'
+		for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+			if string.sub(field, 1, #prefix) == prefix then
+				redis.call('HDEL', KEYS[1], field)
+			end
+		end
+		redis.call('HSET', KEYS[1], ring, ARGV[i + 2])
+	end
+	redis.call('HINCRBY', KEYS[1], ring .. ':' .. ARGV[1], 1)
+end
+return 1
+`
+
+// queueLatencyHistogram counts a status check with a response in the
+// endpoint's latency histograms, which expire once the longest window
+// passes without a check
+func (s *RedisStore) queueLatencyHistogram(ctx context.Context, pipe redis.Pipeliner, result Result) {
+	if len(s.latencyBuckets) == 0 || result.StatusCode <= 0 {
+		return
+	}
+	args := []interface{}{latencyBucketField(s.latencyBuckets, result.Latency)}
+	var longest time.Duration
+	for _, window := range LatencyWindows {
+		number, ring := window.slot(result.CheckedAt)
+		args = append(args, window.Name, ring, number)
+		longest = max(longest, window.Slot*time.Duration(window.Slots))
+	}
+	key := s.keys.LatencyHistogram(result.Endpoint)
+	pipe.Eval(ctx, recordLatencyScript, []string{key}, args...)
+	pipe.Expire(ctx, key, longest)
+}
+
+// LatencyPercentiles returns the endpoint's latency percentiles at now for
+// each of LatencyWindows, with a zero Count for windows without checks
+func (s *RedisStore) LatencyPercentiles(ctx context.Context, endpoint string, now time.Time) ([]LatencyPercentiles, error) {
+	fields, err := s.client.HGetAll(ctx, s.keys.LatencyHistogram(endpoint)).Result()
+	if err != nil {
+		return nil, err
+	}
+	percentiles := make([]LatencyPercentiles, 0, len(LatencyWindows))
+	for _, window := range LatencyWindows {
+		current, _ := window.slot(now)
+		oldest := current - int64(window.Slots) + 1
+		counts := make(map[latencyBucket]int)
+		for field, value := range fields {
+			name, rest, _ := strings.Cut(field, ":")
+			ring, bucketField, ok := strings.Cut(rest, ":")
+			if name != window.Name || !ok {
+				continue
+			}
+			bucket, ok := parseLatencyBucket(bucketField)
+			if !ok {
+				continue
+			}
+			number, err := strconv.ParseInt(fields[name+":"+ring], 10, 64)
+			if err != nil || number < oldest || number > current {
+				continue
+			}
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				continue
+			}
+			counts[bucket] += count
+		}
+		p := histogramPercentiles(counts)
+		p.Window = window.Name
+		p.Since = time.Unix(oldest*int64(window.Slot/time.Second), 0).UTC()
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// histogramPercentiles estimates the percentiles of the latencies counted
+// by bucket. Buckets of other bounds may overlap after a change of the
+// bounds, which only blurs the estimate.
+func histogramPercentiles(counts map[latencyBucket]int) LatencyPercentiles {
+	buckets := make([]latencyBucket, 0, len(counts))
+	var p LatencyPercentiles
+	for bucket, count := range counts {
+		buckets = append(buckets, bucket)
+		p.Count += count
+	}
+	slices.SortFunc(buckets, func(a, b latencyBucket) int {
+		return cmp.Or(cmp.Compare(a.Lower, b.Lower), cmp.Compare(a.upper(), b.upper()))
+	})
+	quantile := func(q float64) time.Duration {
+		rank := q * float64(p.Count)
+		seen := 0
+		for _, bucket := range buckets {
+			count := counts[bucket]
+			if float64(seen+count) >= rank {
+				if bucket.Upper == 0 {
+					return bucket.Lower
+				}
+				return bucket.Lower + time.Duration(float64(bucket.Upper-bucket.Lower)*(rank-float64(seen))/float64(count))
+			}
+			seen += count
+		}
+		return 0
+	}
+	if p.Count > 0 {
+		p.P50, p.P95, p.P99 = quantile(0.50), quantile(0.95), quantile(0.99)
+	}
+	return p
+}
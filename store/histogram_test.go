@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestParseLatencyBuckets tests bucket bounds and their validation
+func TestParseLatencyBuckets(t *testing.T) {
+	got, err := ParseLatencyBuckets("5ms, 50ms,1.5s")
+	if want := []time.Duration{5 * time.Millisecond, 50 * time.Millisecond, 1500 * time.Millisecond}; err != nil || !slices.Equal(got, want) {
+		t.Errorf("ParseLatencyBuckets() = %v, %v, want %v", got, err, want)
+	}
+	for _, value := range []string{"", "10ms,5ms", "10ms,10ms", "500us", "1.5ms", "fast"} {
+		if _, err := ParseLatencyBuckets(value); err == nil {
+			t.Errorf("ParseLatencyBuckets(%q) succeeded", value)
+		}
+	}
+}
+
+// TestLatencyPercentiles tests that SaveResults counts latencies in the
+// windows' slots, resetting a slot when it rotates, and the percentiles
+// read back
+func TestLatencyPercentiles(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	s.SetLatencyBuckets([]time.Duration{10 * time.Millisecond, 100 * time.Millisecond, time.Second})
+	ctx := context.Background()
+	endpoint := "https://example.com"
+	now := time.Date(2024, 3, 1, 12, 32, 0, 0, time.UTC)
+	result := func(at time.Time, statusCode int, latency time.Duration) Result {
+		return Result{Endpoint: endpoint, CheckedAt: at, HasStatus: true, StatusCode: statusCode, Latency: latency}
+	}
+
+	// A day ago, in the ring index of the current hour
+	if err := s.SaveResults(ctx, []Result{result(now.Add(-24*time.Hour+8*time.Minute), 200, 5*time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	// Outside the last hour but within the day
+	results := []Result{result(time.Date(2024, 3, 1, 10, 50, 0, 0, time.UTC), 200, 2*time.Second)}
+	at := time.Date(2024, 3, 1, 12, 31, 0, 0, time.UTC)
+	for i := range 99 {
+		latency := 5 * time.Millisecond
+		switch {
+		case i >= 90:
+			latency = 500 * time.Millisecond
+		case i >= 50:
+			latency = 50 * time.Millisecond
+		}
+		results = append(results, result(at, 200, latency))
+	}
+	// Failed checks have no response time
+	results = append(results, result(at, 0, 10*time.Second))
+	if err := s.SaveResults(ctx, results); err != nil {
+		t.Fatal(err)
+	}
+
+	key := LatencyHistogramKeyPrefix + endpoint
+	if got := mr.HGet(key, "24h:12:1000_inf"); got != "" {
+		t.Errorf("24h:12:1000_inf = %q, want the check of a day ago reset", got)
+	}
+	if got := mr.HGet(key, "24h:12"); got != "474804" {
+		t.Errorf("24h:12 = %q, want the slot of the current hour", got)
+	}
+	if ttl := mr.TTL(key); ttl != 24*time.Hour {
+		t.Errorf("TTL = %v, want 24h", ttl)
+	}
+
+	got, err := s.LatencyPercentiles(ctx, endpoint, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LatencyPercentiles{
+		{Window: "1h", Since: time.Date(2024, 3, 1, 11, 35, 0, 0, time.UTC), Count: 99, P50: 9900 * time.Microsecond, P95: 505 * time.Millisecond, P99: 901 * time.Millisecond},
+		{Window: "24h", Since: time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC), Count: 100, P50: 10 * time.Millisecond, P95: 600 * time.Millisecond, P99: time.Second},
+	}
+	for i := range got {
+		got[i].P50, got[i].P95, got[i].P99 = got[i].P50.Round(time.Microsecond), got[i].P95.Round(time.Microsecond), got[i].P99.Round(time.Microsecond)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("LatencyPercentiles() =\n%+v, want\n%+v", got, want)
+	}
+
+	// An hour later the check of the last hour has left its window
+	got, err = s.LatencyPercentiles(ctx, endpoint, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Count != 0 || got[0].P50 != 0 || got[1].Count != 100 {
+		t.Errorf("LatencyPercentiles() an hour later = %+v", got)
+	}
+}
+
+// TestHistogramPercentiles tests percentiles in the bucket above every bound
+func TestHistogramPercentiles(t *testing.T) {
+	got := histogramPercentiles(map[latencyBucket]int{{Lower: time.Second}: 3})
+	if got.Count != 3 || got.P50 != time.Second || got.P99 != time.Second {
+		t.Errorf("histogramPercentiles() = %+v, want every percentile at the highest bound", got)
+	}
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Internationalized host names are stored and dialed in their ASCII form,
+// each non-ASCII label becoming "xn--" and its punycode, and shown in their
+// Unicode form, both as IDNA lookups map them
+
+// hostToASCII returns the ASCII form of host. ASCII hosts are returned
+// unchanged, so existing keys keep their case.
+func hostToASCII(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+	return idna.Lookup.ToASCII(host)
+}
+
+// hostToUnicode returns the Unicode form of host, or host itself when it
+// has no punycode labels or they are invalid
+func hostToUnicode(host string) string {
+	if !strings.Contains(strings.ToLower(host), "xn--") {
+		return host
+	}
+	unicode, err := idna.Lookup.ToUnicode(host)
+	if err != nil {
+		return host
+	}
+	return unicode
+}
+
+// DisplayEndpoint returns endpoint with its host name in Unicode, e.g.
+// https://münchen.example for https://xn--mnchen-3ya.example, for display
+func DisplayEndpoint(endpoint string) string {
+	prefix, host, suffix := splitEndpointHost(endpoint)
+	return prefix + hostToUnicode(host) + suffix
+}
+
+// splitEndpointHost splits endpoint around its host name: the scheme and
+// user info before it, the port, path, query and fragment after it. IPv6
+// literals are left in the prefix.
+func splitEndpointHost(endpoint string) (prefix, host, suffix string) {
+	start := strings.Index(endpoint, "://")
+	if start < 0 {
+		return endpoint, "", ""
+	}
+	start += len("://")
+	end := len(endpoint)
+	if i := strings.IndexAny(endpoint[start:], "/?#"); i >= 0 {
+		end = start + i
+	}
+	if i := strings.LastIndex(endpoint[start:end], "@"); i >= 0 {
+		start += i + 1
+	}
+	if strings.HasPrefix(endpoint[start:end], "[") {
+		return endpoint, "", ""
+	}
+	if i := strings.LastIndex(endpoint[start:end], ":"); i >= 0 {
+		end = start + i
+	}
+	return endpoint[:start], endpoint[start:end], endpoint[end:]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
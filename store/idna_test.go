@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestHostToASCII tests converting host names to their ASCII form
+func TestHostToASCII(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr bool
+	}{
+		{"example.com", "example.com", false},
+		{"Example.COM", "Example.COM", false}, // ASCII hosts keep their keys
+		{"MÜNCHEN.example", "xn--mnchen-3ya.example", false},
+		{"münchen.example", "xn--mnchen-3ya.example", false}, // NFC
+		{"münchen。example", "xn--mnchen-3ya.example", false},
+		{"münchen.example.", "xn--mnchen-3ya.example.", false},
+		{"3年b組金八先生.example", "xn--3b-ww4c5e180e575a65lsy2b.example", false},
+		{"münchen\x00.example", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := hostToASCII(tt.host)
+		if (err != nil) != tt.wantErr || !tt.wantErr && got != tt.want {
+			t.Errorf("hostToASCII(%q) = %q, %v, want %q (error %v)", tt.host, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestDisplayEndpoint tests that stored endpoints display with Unicode host names
+func TestDisplayEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://example.com/health", "https://example.com/health"},
+		{"https://xn--mnchen-3ya.example", "https://münchen.example"},
+		{"https://XN--mnchen-3ya.example:8443/xn--a", "https://münchen.example:8443/xn--a"},
+		{"https://xn--invalid!.example", "https://xn--invalid!.example"},
+		{"https://[::1]:8443", "https://[::1]:8443"},
+		{"not a url", "not a url"},
+	}
+
+	for _, tt := range tests {
+		if got := DisplayEndpoint(tt.endpoint); got != tt.want {
+			t.Errorf("DisplayEndpoint(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+
+	for _, endpoint := range []string{"https://münchen.example/a", "https://3年b組金八先生.example", "https://bücher.example:8443"} {
+		normalized := NormalizeEndpoint(endpoint)
+		if _, host, _ := splitEndpointHost(normalized); !isASCII(host) {
+			t.Errorf("NormalizeEndpoint(%q) = %q, want an ASCII host", endpoint, normalized)
+		}
+		if got := DisplayEndpoint(normalized); got != endpoint {
+			t.Errorf("DisplayEndpoint(%q) = %q, want %q", normalized, got, endpoint)
+		}
+	}
+}
+
+// TestIDNEndpointRoundTrip tests that IDN endpoints are stored under ASCII keys
+func TestIDNEndpointRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t)
+	endpoint := NormalizeEndpoint("https://münchen.example")
+
+	if err := s.SetStatus(ctx, endpoint, 200, time.Now()); err != nil {
+		t.Fatalf("SetStatus() error: %v", err)
+	}
+	if !mr.Exists("endpoint:https://xn--mnchen-3ya.example") {
+		t.Errorf("keys %v, want endpoint:https://xn--mnchen-3ya.example", mr.Keys())
+	}
+	data, err := s.GetEndpointData(ctx, endpoint)
+	if err != nil {
+		t.Fatalf("GetEndpointData() error: %v", err)
+	}
+	if DisplayEndpoint(data.Endpoint) != "https://münchen.example" {
+		t.Errorf("DisplayEndpoint(%q) = %q", data.Endpoint, DisplayEndpoint(data.Endpoint))
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import "strings"
+
+// Keys builds the name of every Redis key the project uses, and of the
+// events channel, under an optional namespace prefix such as "prod:" so
+// several environments can share one Redis. The zero value builds the
+// unprefixed names.
+type Keys struct {
+	Prefix string
+}
+
+// Key returns name under the prefix
+func (k Keys) Key(name string) string {
+	return k.Prefix + name
+}
+
+// Endpoint returns the key of the hash holding endpoint's results
+func (k Keys) Endpoint(endpoint string) string {
+	return k.Key(EndpointKeyPrefix + endpoint)
+}
+
+// StatusHistory returns the key of endpoint's status history
+func (k Keys) StatusHistory(endpoint string) string {
+	return k.Key(StatusHistoryKeyPrefix + endpoint)
+}
+
+// SSLHistory returns the key of endpoint's certificate renewal history
+func (k Keys) SSLHistory(endpoint string) string {
+	return k.Key(SSLHistoryKeyPrefix + endpoint)
+}
+
+// LatencyRollups returns the key of endpoint's hourly latency rollups
+func (k Keys) LatencyRollups(endpoint string) string {
+	return k.Key(LatencyRollupKeyPrefix + endpoint)
+}
+
+// LatencyHistogram returns the key of endpoint's latency histograms
+func (k Keys) LatencyHistogram(endpoint string) string {
+	return k.Key(LatencyHistogramKeyPrefix + endpoint)
+}
+
+// Recheck returns the key limiting how often endpoint is rechecked on request
+func (k Keys) Recheck(endpoint string) string {
+	return k.Key(RecheckKeyPrefix + endpoint)
+}
+
+// Ack returns the key of endpoint's acknowledgement
+func (k Keys) Ack(endpoint string) string {
+	return k.Key(AckKeyPrefix + endpoint)
+}
+
+// Pause returns the key of endpoint's pause
+func (k Keys) Pause(endpoint string) string {
+	return k.Key(PauseKeyPrefix + endpoint)
+}
+
+// Pattern returns a SCAN pattern matching every key that starts with
+// keyPrefix, e.g. EndpointKeyPrefix. Glob characters in the namespace
+// prefix are escaped.
+func (k Keys) Pattern(keyPrefix string) string {
+	return globEscaper.Replace(k.Key(keyPrefix)) + "*"
+}
+
+// TrimPrefix returns what follows keyPrefix in key, usually the endpoint
+func (k Keys) TrimPrefix(key, keyPrefix string) string {
+	return strings.TrimPrefix(key, k.Key(keyPrefix))
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestKeys tests key names with and without a namespace prefix
+func TestKeys(t *testing.T) {
+	endpoint := "https://example.com"
+
+	tests := []struct {
+		name string
+		got  func(Keys) string
+		want string // unprefixed; the empty prefix must keep these exact names
+	}{
+		{"endpoint", func(k Keys) string { return k.Endpoint(endpoint) }, "endpoint:https://example.com"},
+		{"status history", func(k Keys) string { return k.StatusHistory(endpoint) }, "history:status:https://example.com"},
+		{"ssl history", func(k Keys) string { return k.SSLHistory(endpoint) }, "history:ssl:https://example.com"},
+		{"latency rollups", func(k Keys) string { return k.LatencyRollups(endpoint) }, "history:latency:hourly:https://example.com"},
+		{"recheck", func(k Keys) string { return k.Recheck(endpoint) }, "recheck:https://example.com"},
+		{"ack", func(k Keys) string { return k.Ack(endpoint) }, "ack:https://example.com"},
+		{"pause", func(k Keys) string { return k.Pause(endpoint) }, "pause:https://example.com"},
+		{"registry", func(k Keys) string { return k.Key(EndpointRegistryKey) }, "endpoints_registry"},
+		{"expiry index", func(k Keys) string { return k.Key(SSLExpiryIndexKey) }, "ssl_expiry_index"},
+		{"schema version", func(k Keys) string { return k.Key(SchemaVersionKey) }, "schema_version"},
+		{"checkers", func(k Keys) string { return k.Key(CheckersKey) }, "checkers"},
+		{"event stream", func(k Keys) string { return k.Key(EventStreamKey) }, "events"},
+		{"events channel", func(k Keys) string { return k.Key(EventsChannel) }, "certs-n-status:events"},
+		{"recheck channel", func(k Keys) string { return k.Key(RecheckChannel) }, "certs-n-status:recheck"},
+		{"endpoint pattern", func(k Keys) string { return k.Pattern(EndpointKeyPrefix) }, "endpoint:*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got(Keys{}); got != tt.want {
+				t.Errorf("no prefix: got %q, want %q", got, tt.want)
+			}
+			if got := tt.got(Keys{Prefix: "prod:"}); got != "prod:"+tt.want {
+				t.Errorf("prefix prod: got %q, want %q", got, "prod:"+tt.want)
+			}
+		})
+	}
+
+	if got := (Keys{Prefix: "team[a]*:"}).Pattern(EndpointKeyPrefix); got != `team\[a\]\*:endpoint:*` {
+		t.Errorf("Pattern() with glob characters = %q", got)
+	}
+	if got := (Keys{Prefix: "prod:"}).TrimPrefix("prod:endpoint:"+endpoint, EndpointKeyPrefix); got != endpoint {
+		t.Errorf("TrimPrefix() = %q, want %q", got, endpoint)
+	}
+}
+
+// TestKeyPrefix tests that prefixed stores sharing one Redis stay apart
+func TestKeyPrefix(t *testing.T) {
+	plain, mr := newTestRedisStore(t)
+	prod := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	prod.SetKeyPrefix("prod:")
+	t.Cleanup(func() { prod.Close() })
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}
+
+	prod.SaveResults(ctx, []Result{{Endpoint: "https://prod.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 200, Cert: &cert}})
+	prod.SaveLatencyRollups(ctx, "https://prod.example.com", []LatencyRollup{{Hour: checkedAt.Truncate(time.Hour), Count: 1}})
+	prod.PublishEvents(ctx, []Event{{Endpoint: "https://prod.example.com", Kind: EventKindStatus, Old: StatusDown, New: StatusUp, At: checkedAt}})
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "prod:") {
+			t.Errorf("prefixed store wrote %q", key)
+		}
+	}
+
+	plain.SaveResults(ctx, []Result{{Endpoint: "https://staging.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503}})
+
+	for _, tt := range []struct {
+		s    *RedisStore
+		want []string
+	}{
+		{plain, []string{"https://staging.example.com"}},
+		{prod, []string{"https://prod.example.com"}},
+	} {
+		endpoints, _ := tt.s.ListEndpoints(ctx)
+		if !slices.Equal(endpoints, tt.want) {
+			t.Errorf("ListEndpoints(%q) = %v, want %v", tt.s.keys.Prefix, endpoints, tt.want)
+		}
+		tt.s.SetScanDiscovery(true)
+		endpoints, _ = tt.s.ListEndpoints(ctx)
+		if !slices.Equal(endpoints, tt.want) {
+			t.Errorf("ListEndpoints(%q) with SCAN = %v, want %v", tt.s.keys.Prefix, endpoints, tt.want)
+		}
+	}
+	if expiring, _ := prod.ExpiringBefore(ctx, time.Unix(1720000000, 0)); len(expiring) != 1 {
+		t.Errorf("prefixed ExpiringBefore() = %v, want the prod endpoint", expiring)
+	}
+	if events, _ := plain.Events(ctx, "", "", 10); len(events) != 0 {
+		t.Errorf("unprefixed store read the prefixed events: %v", events)
+	}
+
+	// Cleaning up one environment leaves the other alone
+	if _, err := plain.Cleanup(ctx, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := prod.GetEndpointData(ctx, "https://prod.example.com"); data.StatusCode != 200 {
+		t.Errorf("cleanup without prefix removed prefixed data: %+v", data)
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LeaderKey holds the ID of the checker instance that leads its replicas,
+// running what only one of them may, such as sending the daily digest
+const LeaderKey = "leader"
+
+// holdLeaderScript sets KEYS[1] to the holder ARGV[1] for ARGV[2]
+// milliseconds unless another holder has it
+var holdLeaderScript = `
+local current = redis.call('GET', KEYS[1])
+if current and current ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`
+
+// HoldLeadership makes holder the leader for ttl when there is none, or
+// extends its term when it already leads, and reports whether it leads.
+// A leader that stops calling it loses the lead once ttl has passed.
+func (s *RedisStore) HoldLeadership(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	held, err := s.client.Eval(ctx, holdLeaderScript, []string{s.keys.Key(LeaderKey)}, holder, ttl.Milliseconds()).Int()
+	return held == 1, err
+}
+
+// Leader returns the ID of the leading checker instance, "" when none leads
+func (s *RedisStore) Leader(ctx context.Context) (string, error) {
+	leader, err := s.client.Get(ctx, s.keys.Key(LeaderKey)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return leader, err
+}
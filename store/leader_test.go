@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestHoldLeadership tests that one holder leads at a time, keeps the lead
+// while it renews it and loses it once its term ran out
+func TestHoldLeadership(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+
+	if leader, err := s.Leader(ctx); err != nil || leader != "" {
+		t.Fatalf("Leader() before any = %q, %v, want none", leader, err)
+	}
+	steps := []struct {
+		holder  string
+		advance time.Duration
+		want    bool
+	}{
+		{"a", 0, true},
+		{"b", 0, false},
+		{"a", 20 * time.Second, true}, // renews the term
+		{"b", 20 * time.Second, false},
+		{"b", 15 * time.Second, true}, // a's term ran out
+		{"a", 0, false},
+	}
+	for i, step := range steps {
+		mr.FastForward(step.advance)
+		held, err := s.HoldLeadership(ctx, step.holder, 30*time.Second)
+		if err != nil {
+			t.Fatalf("step %d: HoldLeadership(%s): %v", i, step.holder, err)
+		}
+		if held != step.want {
+			t.Errorf("step %d: HoldLeadership(%s) = %v, want %v", i, step.holder, held, step.want)
+		}
+	}
+	if leader, err := s.Leader(ctx); err != nil || leader != "b" {
+		t.Errorf("Leader() = %q, %v, want b", leader, err)
+	}
+}
@@ -0,0 +1,217 @@
+// Package logging sends the standard logger of the checker and the
+// dashboard to the sinks of LOG_OUTPUT: stdout, a file rotated by size and
+// age, and syslog. LOG_FORMAT applies to all of them, so a JSON line
+// written to the file is the same line syslog receives.
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"certs-n-status/store"
+)
+
+// LOG_FORMAT values
+const (
+	FormatText = "text" // "[LEVEL] message" lines, as the standard logger writes them
+	FormatJSON = "json" // one JSON object per line
+)
+
+// LOG_OUTPUT values
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+	OutputSyslog = "syslog"
+)
+
+// Config selects the format and the sinks of the log
+type Config struct {
+	App            string        // names the process in syslog messages
+	Format         string        // FormatText or FormatJSON
+	Outputs        []string      // OutputStdout, OutputFile or OutputSyslog; none keeps stderr
+	File           string        // path of OutputFile
+	MaxSize        int64         // bytes after which the file is rotated; 0 never rotates it
+	MaxAge         time.Duration // rotated files older than it are removed; 0 keeps them
+	MaxBackups     int           // rotated files kept; 0 keeps all
+	SyslogAddress  string        // udp://, tcp:// or unix:// address; empty uses the local /dev/log
+	SyslogFacility string        // e.g. "daemon" or "local0"
+}
+
+// ConfigFromEnv reads the log settings through env. app names the process
+// in syslog messages and the default log file.
+func ConfigFromEnv(env *store.Env, app string) Config {
+	config := Config{
+		App:            app,
+		Format:         env.Choice("LOG_FORMAT", FormatText, FormatJSON),
+		File:           env.String("LOG_FILE", "/var/log/certs-n-status/"+app+".log"),
+		MaxSize:        int64(env.Int("LOG_MAX_SIZE_MB", 100)) << 20,
+		MaxAge:         env.Duration("LOG_MAX_AGE", 0),
+		MaxBackups:     env.Int("LOG_MAX_BACKUPS", 5),
+		SyslogAddress:  env.String("SYSLOG_ADDR", ""),
+		SyslogFacility: env.Choice("SYSLOG_FACILITY", facilityNames...),
+	}
+	for _, output := range env.List("LOG_OUTPUT") {
+		switch output {
+		case OutputStdout, OutputFile, OutputSyslog:
+			if !slices.Contains(config.Outputs, output) {
+				config.Outputs = append(config.Outputs, output)
+			}
+		default:
+			env.Add(fmt.Errorf("unknown LOG_OUTPUT %q (use stdout, file or syslog)", output))
+		}
+	}
+	if slices.Contains(config.Outputs, OutputSyslog) {
+		if _, _, err := parseSyslogAddress(config.SyslogAddress); err != nil {
+			env.Add(err)
+		}
+	}
+	return config
+}
+
+// ConfigKeys are the keys of the --config file for the log settings, under
+// section, e.g. "checker.log.output"
+func ConfigKeys(section string) []store.ConfigKey {
+	return []store.ConfigKey{
+		{Path: section + ".log.format", Env: "LOG_FORMAT"},
+		{Path: section + ".log.output", Env: "LOG_OUTPUT"},
+		{Path: section + ".log.file", Env: "LOG_FILE"},
+		{Path: section + ".log.max_size_mb", Env: "LOG_MAX_SIZE_MB"},
+		{Path: section + ".log.max_age", Env: "LOG_MAX_AGE"},
+		{Path: section + ".log.max_backups", Env: "LOG_MAX_BACKUPS"},
+		{Path: section + ".log.syslog.address", Env: "SYSLOG_ADDR"},
+		{Path: section + ".log.syslog.facility", Env: "SYSLOG_FACILITY"},
+	}
+}
+
+// Setup sends the standard logger to the sinks of config in its format.
+// The log file is reopened on SIGUSR2, for logrotate's postrotate. The
+// returned function closes the sinks and restores the previous logger.
+func Setup(config Config) (func(), error) {
+	prevOutput, prevFlags := log.Writer(), log.Flags()
+	var sinks []io.Writer
+	var closers []func() error
+	closeAll := func() {
+		for _, closeSink := range closers {
+			closeSink()
+		}
+	}
+	for _, output := range config.Outputs {
+		switch output {
+		case OutputStdout:
+			sinks = append(sinks, os.Stdout)
+		case OutputFile:
+			file, err := OpenRotatingFile(config.File, config.MaxSize, config.MaxAge, config.MaxBackups)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			reopen := make(chan os.Signal, 1)
+			signal.Notify(reopen, syscall.SIGUSR2)
+			stopped := make(chan struct{})
+			go func() {
+				for {
+					select {
+					case <-reopen:
+						if err := file.Reopen(); err != nil {
+							fmt.Fprintf(os.Stderr, "[ERROR] Failed to reopen log file: %v\n", err)
+						}
+					case <-stopped:
+						return
+					}
+				}
+			}()
+			sinks = append(sinks, file)
+			closers = append(closers, func() error {
+				signal.Stop(reopen)
+				close(stopped)
+				return file.Close()
+			})
+		case OutputSyslog:
+			w, err := DialSyslog(config.SyslogAddress, config.SyslogFacility, config.App)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			sinks = append(sinks, w)
+			closers = append(closers, w.Close)
+		default:
+			closeAll()
+			return nil, fmt.Errorf("unknown LOG_OUTPUT %q (use stdout, file or syslog)", output)
+		}
+	}
+
+	var w io.Writer = prevOutput
+	if len(sinks) > 0 {
+		w = fanOut(sinks)
+	}
+	if config.Format == FormatJSON {
+		log.SetFlags(0)
+		w = &jsonWriter{w: w}
+	}
+	log.SetOutput(w)
+	return func() {
+		log.SetOutput(prevOutput)
+		log.SetFlags(prevFlags)
+		closeAll()
+	}, nil
+}
+
+// fanOut writes every line to all sinks. A failing sink, such as an
+// unreachable syslog server, does not keep the line from the others.
+type fanOut []io.Writer
+
+func (f fanOut) Write(p []byte) (int, error) {
+	var errs []error
+	for _, w := range f {
+		if _, err := w.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(f) {
+		return 0, errors.Join(errs...)
+	}
+	return len(p), nil
+}
+
+// jsonWriter turns "[LEVEL] message" lines into {"time", "level", "msg"}
+// objects; lines that are already JSON objects, such as access log entries,
+// pass through
+type jsonWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (j *jsonWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	out := []byte(line)
+	if !strings.HasPrefix(line, "{") {
+		level, msg := "info", line
+		if rest, ok := strings.CutPrefix(line, "["); ok {
+			if name, text, ok := strings.Cut(rest, "] "); ok {
+				level, msg = strings.ToLower(name), text
+			}
+		}
+		out, _ = json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{time.Now().UTC().Format(time.RFC3339Nano), level, msg})
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(out, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
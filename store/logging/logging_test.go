@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"certs-n-status/store"
+)
+
+var logEnv = []string{"LOG_FORMAT", "LOG_OUTPUT", "LOG_FILE", "LOG_MAX_SIZE_MB", "LOG_MAX_AGE", "LOG_MAX_BACKUPS", "SYSLOG_ADDR", "SYSLOG_FACILITY"}
+
+// TestConfigFromEnv tests the defaults and that invalid outputs, syslog
+// addresses and facilities are reported
+func TestConfigFromEnv(t *testing.T) {
+	for _, key := range logEnv {
+		t.Setenv(key, "")
+	}
+	var env store.Env
+	config := ConfigFromEnv(&env, "checker")
+	if err := env.Err(); err != nil {
+		t.Fatalf("ConfigFromEnv() without variables reports %v", err)
+	}
+	if config.Format != FormatText || len(config.Outputs) != 0 || config.File != "/var/log/certs-n-status/checker.log" ||
+		config.MaxSize != 100<<20 || config.MaxAge != 0 || config.MaxBackups != 5 || config.SyslogFacility != "daemon" {
+		t.Errorf("ConfigFromEnv() defaults = %+v", config)
+	}
+
+	t.Setenv("LOG_FORMAT", "json")
+	t.Setenv("LOG_OUTPUT", "stdout, file,syslog,file")
+	t.Setenv("LOG_MAX_SIZE_MB", "10")
+	t.Setenv("LOG_MAX_AGE", "168h")
+	t.Setenv("SYSLOG_ADDR", "tcp://logs:601")
+	t.Setenv("SYSLOG_FACILITY", "local3")
+	env = store.Env{}
+	config = ConfigFromEnv(&env, "checker")
+	if err := env.Err(); err != nil {
+		t.Fatalf("ConfigFromEnv() reports %v", err)
+	}
+	if config.Format != FormatJSON || strings.Join(config.Outputs, ",") != "stdout,file,syslog" ||
+		config.MaxSize != 10<<20 || config.MaxAge != 168*time.Hour || config.SyslogAddress != "tcp://logs:601" || config.SyslogFacility != "local3" {
+		t.Errorf("ConfigFromEnv() = %+v", config)
+	}
+
+	t.Setenv("LOG_OUTPUT", "stdout,kafka,syslog")
+	t.Setenv("SYSLOG_ADDR", "logs:514")
+	t.Setenv("SYSLOG_FACILITY", "local9")
+	env = store.Env{}
+	config = ConfigFromEnv(&env, "checker")
+	for _, want := range []string{`unknown LOG_OUTPUT "kafka"`, `invalid SYSLOG_ADDR "logs:514"`, `unknown SYSLOG_FACILITY "local9"`} {
+		if err := env.Err(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ConfigFromEnv() reports %v, want %q", err, want)
+		}
+	}
+	if strings.Join(config.Outputs, ",") != "stdout,syslog" {
+		t.Errorf("ConfigFromEnv() outputs = %q, want the known ones", config.Outputs)
+	}
+}
+
+// restoreLogger puts the standard logger back after a test changed it
+func restoreLogger(t *testing.T) {
+	t.Helper()
+	output, flags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+}
+
+// TestSetup tests that the format applies to the log file and that closing
+// restores the previous logger
+func TestSetup(t *testing.T) {
+	restoreLogger(t)
+	var before strings.Builder
+	log.SetOutput(&before)
+	path := filepath.Join(t.TempDir(), "logs", "checker.log")
+	closeLog, err := Setup(Config{Format: FormatJSON, Outputs: []string{OutputFile}, File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("[WARN] Endpoint %s is down", "https://example.com")
+	closeLog()
+	log.Printf("[INFO] after")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct{ Level, Msg string }
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("log file %q: %v", data, err)
+	}
+	if entry.Level != "warn" || entry.Msg != "Endpoint https://example.com is down" {
+		t.Errorf("log file entry = %+v", entry)
+	}
+	if !strings.Contains(before.String(), "[INFO] after") || strings.Contains(before.String(), "down") {
+		t.Errorf("previous logger got %q, want only the line after closing", before.String())
+	}
+
+	// Without outputs the current writer is kept
+	closeLog, err = Setup(Config{Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("[ERROR] failed")
+	closeLog()
+	if !strings.Contains(before.String(), `"level":"error"`) {
+		t.Errorf("previous logger got %q, want a JSON line", before.String())
+	}
+}
+
+// TestSetupReopen tests that SIGUSR2 reopens the log file after it was
+// moved away, as logrotate does
+func TestSetupReopen(t *testing.T) {
+	restoreLogger(t)
+	path := filepath.Join(t.TempDir(), "dashboard.log")
+	closeLog, err := Setup(Config{Format: FormatText, Outputs: []string{OutputFile}, File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeLog()
+	log.Printf("[INFO] first")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the log file was not reopened")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Printf("[INFO] second")
+
+	moved, _ := os.ReadFile(path + ".1")
+	reopened, _ := os.ReadFile(path)
+	if !strings.Contains(string(moved), "first") || strings.Contains(string(moved), "second") {
+		t.Errorf("moved file = %q, want only the first line", moved)
+	}
+	if !strings.Contains(string(reopened), "[INFO] second") {
+		t.Errorf("reopened file = %q, want the second line", reopened)
+	}
+}
+
+// TestJSONWriter tests the conversion of log lines to JSON objects
+func TestJSONWriter(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantLevel string
+		wantMsg   string
+	}{
+		{"[INFO] Starting Go dashboard server on port 8080\n", "info", "Starting Go dashboard server on port 8080"},
+		{"[WARN] Failed dashboard login for user \"bob\" from 10.0.0.1\n", "warn", `Failed dashboard login for user "bob" from 10.0.0.1`},
+		{"[FATAL] Invalid configuration\n", "fatal", "Invalid configuration"},
+		{"no level here\n", "info", "no level here"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		w := &jsonWriter{w: &out}
+		if n, err := w.Write([]byte(tt.line)); err != nil || n != len(tt.line) {
+			t.Errorf("Write(%q) = %d, %v", tt.line, n, err)
+		}
+		var entry struct{ Time, Level, Msg string }
+		if err := json.Unmarshal([]byte(out.String()), &entry); err != nil {
+			t.Errorf("Write(%q) wrote %q: %v", tt.line, out.String(), err)
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry.Time); err != nil {
+			t.Errorf("Write(%q): time %q: %v", tt.line, entry.Time, err)
+		}
+		if entry.Level != tt.wantLevel || entry.Msg != tt.wantMsg {
+			t.Errorf("Write(%q) = level %q msg %q, want %q %q", tt.line, entry.Level, entry.Msg, tt.wantLevel, tt.wantMsg)
+		}
+	}
+
+	var out strings.Builder
+	w := &jsonWriter{w: &out}
+	line := `{"level":"info","msg":"request"}` + "\n"
+	w.Write([]byte(line))
+	if out.String() != line {
+		t.Errorf("JSON line rewritten to %q", out.String())
+	}
+}
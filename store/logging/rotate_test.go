@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRotatingFile tests that the file is rotated before it outgrows its
+// limit and that rotated files beyond the backup count or age are removed
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checker.log")
+	f, err := OpenRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	// three, four and six would take the file past 10 bytes, each rotating
+	// it; of the three rotated files the oldest goes
+	want := []string{"checker-2025-01-02T15-04-08.000.log", "checker-2025-01-02T15-04-10.000.log", "checker.log"}
+	if got := dirNames(t, dir); !slices.Equal(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+	for name, content := range map[string]string{
+		"checker-2025-01-02T15-04-08.000.log": "three\n",
+		"checker-2025-01-02T15-04-10.000.log": "four\nfive\n",
+		"checker.log":                         "six\n",
+	} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+
+	// Rotated files older than maxAge go, whatever their count
+	f.maxBackups, f.maxAge = 0, time.Hour
+	now = now.Add(time.Hour)
+	f.Write([]byte("sixth line\n"))
+	want = []string{"checker-2025-01-02T16-04-11.000.log", "checker.log"}
+	if got := dirNames(t, dir); !slices.Equal(got, want) {
+		t.Errorf("files after maxAge = %q, want %q", got, want)
+	}
+
+	// An existing file counts towards the limit when reopened
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("x\n")); err == nil {
+		t.Error("Write() after Close() succeeded")
+	}
+	f, err = OpenRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.size != int64(len("sixth line\n")) {
+		t.Errorf("size of the reopened file = %d", f.size)
+	}
+}
+
+// TestOpenRotatingFileCreatesDirectory tests that a missing log directory
+// is created
+func TestOpenRotatingFileCreatesDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "var", "log", "dashboard.log")
+	f, err := OpenRotatingFile(path, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write([]byte(strings.Repeat("x", 100)))
+	if info, err := os.Stat(path); err != nil || info.Size() != 100 {
+		t.Errorf("Stat(%s) = %v, %v", path, info, err)
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
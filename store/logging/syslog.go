@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// facilityNames are the SYSLOG_FACILITY values, by their code; the first
+// is the default
+var facilityNames = []string{
+	"daemon", "kern", "user", "mail", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var facilityCodes = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// severities of the log levels, see RFC 5424 section 6.2.1
+var severities = map[string]int{
+	"fatal": 2,
+	"error": 3,
+	"warn":  4,
+	"info":  6,
+	"debug": 7,
+}
+
+// parseSyslogAddress returns the network and address of a SYSLOG_ADDR
+func parseSyslogAddress(addr string) (network, address string, err error) {
+	if addr == "" {
+		return "unixgram", "/dev/log", nil
+	}
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if ok && rest != "" {
+		switch scheme {
+		case "udp", "tcp":
+			return scheme, rest, nil
+		case "unix":
+			return "unixgram", rest, nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid SYSLOG_ADDR %q (use udp://host:port, tcp://host:port or unix:///path)", addr)
+}
+
+// SyslogWriter sends every log line as an RFC 5424 message, its severity
+// taken from the line's level. Over TCP messages are framed by octet
+// counting, see RFC 6587.
+type SyslogWriter struct {
+	network, address string
+	facility         int
+	app              string
+	hostname         string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialSyslog connects to the syslog server at addr, a SYSLOG_ADDR
+func DialSyslog(addr, facility, app string) (*SyslogWriter, error) {
+	network, address, err := parseSyslogAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	code, ok := facilityCodes[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown SYSLOG_FACILITY %q", facility)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	w := &SyslogWriter{network: network, address: address, facility: code, app: app, hostname: hostname}
+	if err := w.dial(); err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) dial() error {
+	conn, err := net.DialTimeout(w.network, w.address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write sends p as one message, redialing once should the connection have
+// been lost, e.g. by a restart of the syslog server
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(string(p), "\n")
+	msg := w.format(time.Now(), lineSeverity(line), line)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return 0, net.ErrClosed
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		w.conn.Close()
+		if err := w.dial(); err != nil {
+			return 0, err
+		}
+		if _, err := w.conn.Write(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection; later writes fail
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// format returns the message of line: <PRI>1 TIMESTAMP HOSTNAME APP-NAME
+// PROCID - - MSG, without structured data or message ID
+func (w *SyslogWriter) format(t time.Time, severity int, line string) []byte {
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		w.facility*8+severity, t.UTC().Format(time.RFC3339Nano), w.hostname, w.app, os.Getpid(), line)
+	if w.network == "tcp" {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	return []byte(msg)
+}
+
+// lineSeverity returns the severity of a log line by the "level" of a JSON
+// line or the [LEVEL] tag of a text line, informational without either
+func lineSeverity(line string) int {
+	var level string
+	if strings.HasPrefix(line, "{") {
+		var entry struct{ Level string }
+		json.Unmarshal([]byte(line), &entry)
+		level = entry.Level
+	} else if _, rest, ok := strings.Cut(line, "["); ok {
+		level, _, _ = strings.Cut(rest, "]")
+	}
+	if severity, ok := severities[strings.ToLower(level)]; ok {
+		return severity
+	}
+	return severities["info"]
+}
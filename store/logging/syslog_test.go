@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSyslogWriter tests RFC 5424 messages over UDP, and over TCP framed
+// by octet counting
+func TestSyslogWriter(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	w, err := DialSyslog("udp://"+udp.LocalAddr().String(), "local3", "endpoint-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.Write([]byte("2025/01/02 15:04:05 [WARN] Endpoint https://example.com is down\n")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	udp.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := udp.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local3 (19) * 8 + warning (4)
+	want := regexp.MustCompile(`^<156>1 \S+Z \S+ endpoint-checker ` + strconv.Itoa(os.Getpid()) + ` - - 2025/01/02 15:04:05 \[WARN\] Endpoint https://example.com is down$`)
+	if !want.Match(buf[:n]) {
+		t.Errorf("UDP message = %q", buf[:n])
+	}
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err == nil {
+			received <- string(msg)
+		}
+	}()
+	w, err = DialSyslog("tcp://"+tcp.Addr().String(), "daemon", "dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte(`{"time":"2025-01-02T15:04:05Z","level":"error","msg":"Failed to read endpoints"}` + "\n"))
+	select {
+	case msg := <-received:
+		// daemon (3) * 8 + error (3)
+		if !strings.HasPrefix(msg, "<27>1 ") || !strings.HasSuffix(msg, ` - - {"time":"2025-01-02T15:04:05Z","level":"error","msg":"Failed to read endpoints"}`) {
+			t.Errorf("TCP message = %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no TCP message received")
+	}
+}
+
+// TestLineSeverity tests the severities of text and JSON lines
+func TestLineSeverity(t *testing.T) {
+	tests := []struct {
+		line string
+		want int
+	}{
+		{"[FATAL] Invalid configuration", 2},
+		{"2025/01/02 15:04:05 [ERROR] Failed to save", 3},
+		{"[WARN] Slow check", 4},
+		{"[INFO] Started", 6},
+		{"[DEBUG] GET /healthz 200", 7},
+		{"no level here", 6},
+		{`{"level":"debug","msg":"request"}`, 7},
+		{`{"msg":"request"}`, 6},
+	}
+	for _, tt := range tests {
+		if got := lineSeverity(tt.line); got != tt.want {
+			t.Errorf("lineSeverity(%q) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // windows name their zone, which must resolve without a zoneinfo database on the host
+)
+
+// MaintenanceKey is the hash of maintenance windows, each a JSON
+// MaintenanceWindow under its ID
+const MaintenanceKey = "maintenance"
+
+// MaxMaintenanceDuration bounds a window, so only windows starting on the
+// day of a check or the day before can cover it
+const MaxMaintenanceDuration = 24 * time.Hour
+
+// weekdayNames are the day names of MaintenanceWindow.Days
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// MaintenanceWindow is a weekly period during which an endpoint, or every
+// endpoint with a tag, is expected to fail: results checked in it are
+// stored marked InMaintenance and their state changes are not meant to
+// alert anyone. Start is wall-clock time in Timezone, so a window keeps its
+// local hours across daylight saving changes.
+type MaintenanceWindow struct {
+	ID       string `json:"id"`
+	Endpoint string `json:"endpoint,omitempty"` // set for an endpoint's window
+	Tag      string `json:"tag,omitempty"`      // or for the endpoints with a tag
+	Days     string `json:"days"`               // "*", or days and ranges such as "sun" or "mon-fri,sun"
+	Start    string `json:"start"`              // "02:00"
+	Duration string `json:"duration"`           // "2h", at most MaxMaintenanceDuration
+	Timezone string `json:"timezone"`           // IANA zone such as "Europe/Berlin"
+	Reason   string `json:"reason,omitempty"`
+
+	days     [7]bool
+	start    time.Duration // since midnight
+	duration time.Duration
+	location *time.Location
+}
+
+// Validate checks the window's schedule and prepares it for Active. Days
+// are normalized to lower case.
+func (w *MaintenanceWindow) Validate() error {
+	if (w.Endpoint == "") == (w.Tag == "") {
+		return errors.New("a maintenance window needs either an endpoint or a tag")
+	}
+	w.Days = strings.ToLower(strings.TrimSpace(w.Days))
+	days, err := parseWeekdays(w.Days)
+	if err != nil {
+		return err
+	}
+	hour, minute, ok := strings.Cut(w.Start, ":")
+	h, hErr := strconv.Atoi(hour)
+	m, mErr := strconv.Atoi(minute)
+	if !ok || len(minute) != 2 || hErr != nil || mErr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return fmt.Errorf("invalid start %q (use HH:MM)", w.Start)
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil || duration <= 0 || duration > MaxMaintenanceDuration {
+		return fmt.Errorf("invalid duration %q (use e.g. 2h, at most %s)", w.Duration, MaxMaintenanceDuration)
+	}
+	if w.Timezone == "" {
+		return errors.New("missing timezone (use e.g. UTC or Europe/Berlin)")
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q", w.Timezone)
+	}
+	w.days, w.start, w.duration, w.location = days, time.Duration(h)*time.Hour+time.Duration(m)*time.Minute, duration, location
+	return nil
+}
+
+// parseWeekdays parses a comma-separated list of weekday names and ranges,
+// which may wrap around the week ("fri-mon"), or "*" for every day
+func parseWeekdays(spec string) ([7]bool, error) {
+	var days [7]bool
+	if spec == "*" {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, last := slices.Index(weekdayNames, from), slices.Index(weekdayNames, to)
+		if !isRange {
+			last = first
+		}
+		if first < 0 || last < 0 {
+			return days, fmt.Errorf("invalid days %q (use e.g. sun, mon-fri or sat,sun)", spec)
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// Covers reports whether the window applies to an endpoint with tags
+func (w *MaintenanceWindow) Covers(endpoint string, tags []string) bool {
+	if w.Endpoint != "" {
+		return w.Endpoint == endpoint
+	}
+	return slices.Contains(tags, w.Tag)
+}
+
+// Active reports whether t falls in the window. The window must have been
+// validated.
+func (w *MaintenanceWindow) Active(t time.Time) bool {
+	if w.location == nil {
+		return false
+	}
+	local := t.In(w.location)
+	for back := 0; back <= 1; back++ {
+		day := local.AddDate(0, 0, -back)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), int(w.start.Hours()), int(w.start.Minutes())%60, 0, 0, w.location)
+		if !t.Before(start) && t.Before(start.Add(w.duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// InMaintenance reports whether any of windows covers the endpoint with
+// tags at t
+func InMaintenance(windows []MaintenanceWindow, endpoint string, tags []string, t time.Time) bool {
+	for i := range windows {
+		if windows[i].Covers(endpoint, tags) && windows[i].Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// newMaintenanceWindow validates w and gives it a new random ID, for the
+// stores to keep it under
+func newMaintenanceWindow(w MaintenanceWindow) (MaintenanceWindow, error) {
+	if err := w.Validate(); err != nil {
+		return w, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	w.ID = hex.EncodeToString(id)
+	return w, nil
+}
+
+// sortMaintenanceWindows sorts windows by ID, the order MaintenanceWindows
+// returns them in
+func sortMaintenanceWindows(windows []MaintenanceWindow) {
+	slices.SortFunc(windows, func(a, b MaintenanceWindow) int { return strings.Compare(a.ID, b.ID) })
+}
+
+// AddMaintenanceWindow validates w and stores it under a new random ID,
+// which it returns with the stored window
+func (s *RedisStore) AddMaintenanceWindow(ctx context.Context, w MaintenanceWindow) (MaintenanceWindow, error) {
+	w, err := newMaintenanceWindow(w)
+	if err != nil {
+		return w, err
+	}
+	payload, err := json.Marshal(w)
+	if err != nil {
+		return w, err
+	}
+	return w, s.client.HSet(ctx, s.keys.Key(MaintenanceKey), w.ID, payload).Err()
+}
+
+// DeleteMaintenanceWindow removes the window with id and reports whether
+// there was one
+func (s *RedisStore) DeleteMaintenanceWindow(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.HDel(ctx, s.keys.Key(MaintenanceKey), id).Result()
+	return removed > 0, err
+}
+
+// MaintenanceWindows returns every stored window, validated and sorted by
+// ID. Entries that do not parse or validate, such as those naming a zone
+// unknown to this build, are skipped.
+func (s *RedisStore) MaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error) {
+	values, err := s.client.HGetAll(ctx, s.keys.Key(MaintenanceKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]MaintenanceWindow, 0, len(values))
+	for _, payload := range values {
+		var w MaintenanceWindow
+		if json.Unmarshal([]byte(payload), &w) != nil || w.Validate() != nil {
+			continue
+		}
+		windows = append(windows, w)
+	}
+	sortMaintenanceWindows(windows)
+	return windows, nil
+}
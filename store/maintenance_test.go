@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMaintenanceWindowActive tests which times weekly windows cover,
+// across midnight, week ends and daylight saving changes
+func TestMaintenanceWindowActive(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		window MaintenanceWindow
+		at     time.Time
+		want   bool
+	}{
+		// 2024-03-03 is a Sunday
+		{"sunday night", MaintenanceWindow{Days: "sun", Start: "02:00", Duration: "2h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 3, 2, 0), true},
+		{"end excluded", MaintenanceWindow{Days: "sun", Start: "02:00", Duration: "2h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 3, 4, 0), false},
+		{"other day", MaintenanceWindow{Days: "sun", Start: "02:00", Duration: "2h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 4, 3, 0), false},
+		{"zone of the window", MaintenanceWindow{Days: "sun", Start: "02:00", Duration: "2h", Timezone: "Europe/Berlin"}, at(time.UTC, 2024, 3, 3, 1, 30), true},
+		{"zone of the window, outside", MaintenanceWindow{Days: "sun", Start: "02:00", Duration: "2h", Timezone: "Europe/Berlin"}, at(time.UTC, 2024, 3, 3, 3, 30), false},
+		{"local hours in summer time", MaintenanceWindow{Days: "sun", Start: "03:00", Duration: "1h", Timezone: "Europe/Berlin"}, at(berlin, 2024, 7, 7, 3, 30), true},
+		{"across midnight", MaintenanceWindow{Days: "sat", Start: "23:00", Duration: "3h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 3, 1, 59), true},
+		{"across midnight, over", MaintenanceWindow{Days: "sat", Start: "23:00", Duration: "3h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 3, 2, 0), false},
+		{"range wrapping the week", MaintenanceWindow{Days: "fri-mon", Start: "00:00", Duration: "24h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 4, 12, 0), true},
+		{"outside the range", MaintenanceWindow{Days: "fri-mon", Start: "00:00", Duration: "24h", Timezone: "UTC"}, at(time.UTC, 2024, 3, 6, 12, 0), false},
+		{"every day", MaintenanceWindow{Days: "*", Start: "12:00", Duration: "30m", Timezone: "UTC"}, at(time.UTC, 2024, 3, 6, 12, 15), true},
+		{"list", MaintenanceWindow{Days: "Mon,wed", Start: "12:00", Duration: "30m", Timezone: "UTC"}, at(time.UTC, 2024, 3, 6, 12, 15), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.window.Tag = "erp"
+			if err := tt.window.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := tt.window.Active(tt.at); got != tt.want {
+				t.Errorf("Active(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMaintenanceWindowValidate tests that invalid windows are rejected
+func TestMaintenanceWindowValidate(t *testing.T) {
+	valid := MaintenanceWindow{Endpoint: "https://example.com", Days: "sun", Start: "02:00", Duration: "2h", Timezone: "UTC"}
+	tests := []struct {
+		name    string
+		change  func(w *MaintenanceWindow)
+		wantErr string
+	}{
+		{"valid", func(w *MaintenanceWindow) {}, ""},
+		{"no target", func(w *MaintenanceWindow) { w.Endpoint = "" }, "endpoint or a tag"},
+		{"both targets", func(w *MaintenanceWindow) { w.Tag = "erp" }, "endpoint or a tag"},
+		{"unknown day", func(w *MaintenanceWindow) { w.Days = "sunday" }, "invalid days"},
+		{"empty days", func(w *MaintenanceWindow) { w.Days = "" }, "invalid days"},
+		{"bad start", func(w *MaintenanceWindow) { w.Start = "2am" }, "invalid start"},
+		{"start past midnight", func(w *MaintenanceWindow) { w.Start = "24:00" }, "invalid start"},
+		{"too long", func(w *MaintenanceWindow) { w.Duration = "25h" }, "invalid duration"},
+		{"no timezone", func(w *MaintenanceWindow) { w.Timezone = "" }, "missing timezone"},
+		{"unknown timezone", func(w *MaintenanceWindow) { w.Timezone = "Mars/Olympus" }, "invalid timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := valid
+			tt.change(&w)
+			err := w.Validate()
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestMaintenanceWindows tests storing, listing and deleting windows, and
+// the mark of results checked in one, in every store
+func TestMaintenanceWindows(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+
+		if _, err := s.AddMaintenanceWindow(ctx, MaintenanceWindow{Tag: "erp", Days: "sun", Start: "2:00"}); err == nil {
+			t.Error("AddMaintenanceWindow() of an invalid window succeeded")
+		}
+		added, err := s.AddMaintenanceWindow(ctx, MaintenanceWindow{Tag: "erp", Days: "SUN", Start: "02:00", Duration: "2h", Timezone: "Europe/Berlin", Reason: "batch jobs"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added.ID == "" || added.Days != "sun" {
+			t.Errorf("added window = %+v, want an ID and days sun", added)
+		}
+
+		windows, err := s.MaintenanceWindows(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(windows) != 1 || windows[0].ID != added.ID || windows[0].Reason != "batch jobs" {
+			t.Fatalf("MaintenanceWindows() = %+v, want the added window", windows)
+		}
+		sunday := time.Date(2024, 3, 3, 1, 30, 0, 0, time.UTC)
+		if !InMaintenance(windows, "https://erp.example.com", []string{"prod", "erp"}, sunday) ||
+			InMaintenance(windows, "https://erp.example.com", []string{"prod"}, sunday) {
+			t.Error("InMaintenance() does not follow the window's tag")
+		}
+
+		for _, want := range []bool{true, false} {
+			if removed, err := s.DeleteMaintenanceWindow(ctx, added.ID); err != nil || removed != want {
+				t.Errorf("DeleteMaintenanceWindow() = %v, %v; want %v", removed, err, want)
+			}
+		}
+		if windows, err := s.MaintenanceWindows(ctx); err != nil || windows == nil || len(windows) != 0 {
+			t.Errorf("MaintenanceWindows() after deleting = %#v, %v; want an empty list", windows, err)
+		}
+
+		for _, inMaintenance := range []bool{true, false} {
+			s.SaveResults(ctx, []Result{{Endpoint: "https://erp.example.com", CheckedAt: sunday, HasStatus: true, StatusCode: 503, InMaintenance: inMaintenance}})
+			if data, err := s.GetEndpointData(ctx, "https://erp.example.com"); err != nil || data.InMaintenance != inMaintenance {
+				t.Errorf("InMaintenance = %v, %v; want %v", data.InMaintenance, err, inMaintenance)
+			}
+		}
+	})
+}
+
+// TestMaintenanceWindowsSkipInvalid tests that stored entries that do not
+// parse are left out of the windows
+func TestMaintenanceWindowsSkipInvalid(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	added, err := s.AddMaintenanceWindow(ctx, MaintenanceWindow{Tag: "erp", Days: "sun", Start: "02:00", Duration: "2h", Timezone: "UTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.HSet(MaintenanceKey, "broken", "not json")
+	if windows, err := s.MaintenanceWindows(ctx); err != nil || len(windows) != 1 || windows[0].ID != added.ID {
+		t.Errorf("MaintenanceWindows() = %+v, %v; want only the added window", windows, err)
+	}
+}
@@ -0,0 +1,537 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps results in process memory. It is used by tests and for
+// running without Redis (STORAGE=memory); nothing survives a restart.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	endpoints map[string]*EndpointData
+	index     map[string]time.Time // SSL expiry index
+	history   map[string][]HistoryEntry
+	retention HistoryRetention
+	renewals  map[string][]SSLObservation
+	rollups   map[string]map[time.Time]LatencyRollup
+	expires   map[string]time.Time         // by endpoint, with a result TTL
+	windows   map[string]MaintenanceWindow // by ID
+	heartbeat Heartbeat
+	checkers  map[string]time.Time // last heartbeat by CheckerID
+	statusTTL time.Duration
+	sslTTL    time.Duration
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		endpoints: make(map[string]*EndpointData),
+		index:     make(map[string]time.Time),
+		history:   make(map[string][]HistoryEntry),
+		retention: DefaultHistoryRetention,
+		renewals:  make(map[string][]SSLObservation),
+		rollups:   make(map[string]map[time.Time]LatencyRollup),
+		expires:   make(map[string]time.Time),
+		windows:   make(map[string]MaintenanceWindow),
+		checkers:  make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// SetResultTTL makes endpoints expire statusTTL after the last status write
+// or sslTTL after the last SSL write, whichever is later, like
+// RedisStore.SetResultTTL, taking their history with them. Zero keeps
+// results forever.
+func (s *MemoryStore) SetResultTTL(statusTTL, sslTTL time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statusTTL = statusTTL
+	s.sslTTL = sslTTL
+}
+
+// SetHistoryRetention sets how much status history SaveResults keeps per endpoint
+func (s *MemoryStore) SetHistoryRetention(retention HistoryRetention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = retention
+}
+
+// refresh extends the endpoint's expiry to ttl from now without shortening
+// a longer one. Callers hold mu.
+func (s *MemoryStore) refresh(endpoint string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if at := s.now().Add(ttl); at.After(s.expires[endpoint]) {
+		s.expires[endpoint] = at
+	}
+}
+
+// expired reports whether the endpoint's results aged out. Callers hold mu.
+func (s *MemoryStore) expired(endpoint string) bool {
+	at, ok := s.expires[endpoint]
+	return ok && !s.now().Before(at)
+}
+
+// dropExpired deletes the endpoints that aged out, which reads already
+// skip, with their history, expiry index entry and rollups. Callers hold
+// mu for writing.
+func (s *MemoryStore) dropExpired() {
+	for endpoint := range s.expires {
+		if s.expired(endpoint) {
+			delete(s.endpoints, endpoint)
+			delete(s.index, endpoint)
+			delete(s.history, endpoint)
+			delete(s.renewals, endpoint)
+			delete(s.rollups, endpoint)
+			delete(s.expires, endpoint)
+		}
+	}
+}
+
+// entry returns the record for an endpoint, creating it. Callers hold mu.
+func (s *MemoryStore) entry(endpoint string) *EndpointData {
+	data, ok := s.endpoints[endpoint]
+	if !ok {
+		data = &EndpointData{Endpoint: endpoint}
+		s.endpoints[endpoint] = data
+	}
+	return data
+}
+
+func (s *MemoryStore) SetStatus(ctx context.Context, endpoint string, statusCode int, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropExpired()
+	s.setStatus(endpoint, statusCode, checkedAt)
+	return nil
+}
+
+func (s *MemoryStore) setStatus(endpoint string, statusCode int, checkedAt time.Time) {
+	s.refresh(endpoint, s.statusTTL)
+	data := s.entry(endpoint)
+	data.StatusCode = statusCode
+	data.HasStatus = true
+	data.StatusUpdated = checkedAt.Truncate(time.Second).UTC()
+}
+
+func (s *MemoryStore) SetSSLExpiry(ctx context.Context, endpoint string, expiration time.Time, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropExpired()
+	s.setSSLExpiry(endpoint, expiration, checkedAt)
+	s.observeSSL(endpoint, SSLObservation{ObservedAt: checkedAt, NotAfter: expiration})
+	return nil
+}
+
+func (s *MemoryStore) setSSLExpiry(endpoint string, expiration time.Time, checkedAt time.Time) {
+	s.refresh(endpoint, s.sslTTL)
+	data := s.entry(endpoint)
+	data.SSLExpiration = expiration.Truncate(time.Second).UTC()
+	data.SSLUpdated = checkedAt.Truncate(time.Second).UTC()
+	s.index[endpoint] = data.SSLExpiration
+}
+
+func (s *MemoryStore) SetCertInfo(ctx context.Context, endpoint string, cert CertInfo, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropExpired()
+	s.setCertInfo(endpoint, cert, checkedAt)
+	return nil
+}
+
+func (s *MemoryStore) setCertInfo(endpoint string, cert CertInfo, checkedAt time.Time) {
+	s.setSSLExpiry(endpoint, cert.NotAfter, checkedAt)
+	cert.NotBefore = cert.NotBefore.Truncate(time.Second).UTC()
+	cert.NotAfter = cert.NotAfter.Truncate(time.Second).UTC()
+	s.entry(endpoint).CertInfo = &cert
+	s.observeSSL(endpoint, newSSLObservation(cert, checkedAt))
+}
+
+// observeSSL appends to the renewal history when NotAfter changed. Callers hold mu.
+func (s *MemoryStore) observeSSL(endpoint string, observation SSLObservation) {
+	observation.ObservedAt = observation.ObservedAt.Truncate(time.Second).UTC()
+	observation.NotAfter = observation.NotAfter.Truncate(time.Second).UTC()
+
+	renewals := s.renewals[endpoint]
+	if n := len(renewals); n > 0 && renewals[n-1].NotAfter.Equal(observation.NotAfter) {
+		return
+	}
+	renewals = append(renewals, observation)
+	if len(renewals) > SSLHistoryLimit {
+		renewals = renewals[len(renewals)-SSLHistoryLimit:]
+	}
+	s.renewals[endpoint] = renewals
+}
+
+func (s *MemoryStore) setHeaderAudit(endpoint string, audit HeaderAudit) {
+	stored := HeaderAudit{
+		Headers:  make(map[string]string, len(audit.Headers)),
+		Failures: append([]string(nil), audit.Failures...),
+		Updated:  audit.Updated.Truncate(time.Second).UTC(),
+	}
+	for name, value := range audit.Headers {
+		stored.Headers[name] = value
+	}
+	s.entry(endpoint).HeaderAudit = &stored
+}
+
+func (s *MemoryStore) SaveResults(ctx context.Context, results []Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dropExpired()
+	for _, result := range results {
+		if result.HasStatus {
+			s.setStatus(result.Endpoint, result.StatusCode, result.CheckedAt)
+			s.setError(result.Endpoint, result.Error)
+			s.entry(result.Endpoint).Tags = slices.Clone(result.Tags)
+			s.entry(result.Endpoint).Name = result.Name
+			s.entry(result.Endpoint).Description = result.Description
+			s.entry(result.Endpoint).RemoteAddr = result.RemoteAddr
+			s.entry(result.Endpoint).CheckedBy = result.CheckedBy
+			s.entry(result.Endpoint).InMaintenance = result.InMaintenance
+			s.entry(result.Endpoint).PausedBySchedule = false
+			s.appendHistory(result)
+		}
+		if result.PausedBySchedule {
+			s.entry(result.Endpoint).PausedBySchedule = true
+			s.refresh(result.Endpoint, s.statusTTL)
+		}
+		if result.Cert != nil {
+			s.setCertInfo(result.Endpoint, *result.Cert, result.CheckedAt)
+			s.entry(result.Endpoint).CertLevel = result.certLevel()
+			s.entry(result.Endpoint).SSLCheckedBy = result.CheckedBy
+		}
+		if result.HeaderAudit != nil {
+			s.setHeaderAudit(result.Endpoint, *result.HeaderAudit)
+		}
+		if result.Captured != nil {
+			headers := make(map[string]string, len(result.Captured.Headers))
+			maps.Copy(headers, result.Captured.Headers)
+			s.entry(result.Endpoint).Captured = &CapturedHeaders{Headers: headers, Changed: result.Captured.Changed.Truncate(time.Second).UTC()}
+		}
+	}
+	return nil
+}
+
+// setError replaces the stored error, clearing it for nil. Callers hold mu.
+func (s *MemoryStore) setError(endpoint string, checkError *CheckError) {
+	data := s.entry(endpoint)
+	data.Error = nil
+	if checkError != nil {
+		stored := *checkError
+		stored.At = stored.At.UTC().Truncate(time.Second)
+		data.Error = &stored
+	}
+}
+
+// appendHistory records a status check and trims the history to the
+// retention. Callers hold mu.
+func (s *MemoryStore) appendHistory(result Result) {
+	checkedAt := result.CheckedAt.Truncate(time.Millisecond).UTC()
+	history := append(s.history[result.Endpoint], HistoryEntry{
+		CheckedAt:  checkedAt,
+		StatusCode: result.StatusCode,
+		Latency:    result.Latency.Truncate(time.Millisecond),
+	})
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].CheckedAt.Before(history[j].CheckedAt)
+	})
+	if s.retention.MaxAge > 0 {
+		cutoff := checkedAt.Add(-s.retention.MaxAge)
+		for len(history) > 0 && history[0].CheckedAt.Before(cutoff) {
+			history = history[1:]
+		}
+	}
+	if s.retention.Count > 0 && len(history) > s.retention.Count {
+		history = history[len(history)-s.retention.Count:]
+	}
+	s.history[result.Endpoint] = history
+}
+
+func (s *MemoryStore) PruneSSLExpiryIndex(ctx context.Context, keep []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	monitored := make(map[string]bool, len(keep))
+	for _, endpoint := range keep {
+		monitored[endpoint] = true
+	}
+
+	removed := 0
+	for endpoint := range s.index {
+		if !monitored[endpoint] {
+			delete(s.index, endpoint)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemoryStore) ListEndpoints(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Mirror RedisStore, which discovers endpoints from status and ssl keys
+	result := make([]string, 0, len(s.endpoints))
+	for endpoint, data := range s.endpoints {
+		if (data.HasStatus || !data.SSLUpdated.IsZero()) && !s.expired(endpoint) {
+			result = append(result, endpoint)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) ListEndpointData(ctx context.Context) ([]EndpointData, error) {
+	endpoints, err := s.ListEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]EndpointData, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		result = append(result, s.copyEndpointData(endpoint))
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) GetEndpointData(ctx context.Context, endpoint string) (EndpointData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.copyEndpointData(endpoint), nil
+}
+
+func (s *MemoryStore) GetEndpointsData(ctx context.Context, endpoints []string) ([]EndpointData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]EndpointData, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		result = append(result, s.copyEndpointData(endpoint))
+	}
+	return result, nil
+}
+
+// copyEndpointData returns a copy that callers may keep. Callers hold mu.
+func (s *MemoryStore) copyEndpointData(endpoint string) EndpointData {
+	stored, ok := s.endpoints[endpoint]
+	if !ok || s.expired(endpoint) {
+		return EndpointData{Endpoint: endpoint}
+	}
+
+	data := *stored
+	if !strings.HasPrefix(endpoint, "https://") {
+		data.SSLExpiration = time.Time{}
+		data.SSLUpdated = time.Time{}
+		data.CertInfo = nil
+	}
+	if data.CertInfo != nil {
+		cert := *data.CertInfo
+		data.CertInfo = &cert
+	}
+	if data.HeaderAudit != nil {
+		audit := *data.HeaderAudit
+		data.HeaderAudit = &audit
+	}
+	if data.Captured != nil {
+		captured := *data.Captured
+		captured.Headers = maps.Clone(captured.Headers)
+		data.Captured = &captured
+	}
+	data.Uptime = slices.Clone(data.Uptime)
+	data.Tags = slices.Clone(data.Tags)
+	if data.Error != nil {
+		checkError := *data.Error
+		data.Error = &checkError
+	}
+	return data
+}
+
+func (s *MemoryStore) ExpiringBefore(ctx context.Context, t time.Time) ([]Expiry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var expiring []Expiry
+	for endpoint, expiration := range s.index {
+		if !expiration.After(t) {
+			expiring = append(expiring, Expiry{Endpoint: endpoint, SSLExpiration: expiration})
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		if expiring[i].SSLExpiration.Equal(expiring[j].SSLExpiration) {
+			return expiring[i].Endpoint < expiring[j].Endpoint
+		}
+		return expiring[i].SSLExpiration.Before(expiring[j].SSLExpiration)
+	})
+	return expiring, nil
+}
+
+func (s *MemoryStore) StatusHistory(ctx context.Context, endpoint string, since time.Time) ([]HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.expired(endpoint) {
+		return nil, nil
+	}
+	var history []HistoryEntry
+	for _, entry := range s.history[endpoint] {
+		if !entry.CheckedAt.Before(since) {
+			history = append(history, entry)
+		}
+	}
+	return history, nil
+}
+
+func (s *MemoryStore) SSLHistory(ctx context.Context, endpoint string) ([]SSLObservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.expired(endpoint) {
+		return nil, nil
+	}
+	return append([]SSLObservation(nil), s.renewals[endpoint]...), nil
+}
+
+func (s *MemoryStore) SaveLatencyRollups(ctx context.Context, endpoint string, rollups []LatencyRollup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byHour, ok := s.rollups[endpoint]
+	if !ok {
+		byHour = make(map[time.Time]LatencyRollup)
+		s.rollups[endpoint] = byHour
+	}
+	var latest time.Time
+	for _, rollup := range rollups {
+		rollup.Hour = rollup.Hour.UTC()
+		byHour[rollup.Hour] = rollup
+		if rollup.Hour.After(latest) {
+			latest = rollup.Hour
+		}
+	}
+	for hour := range byHour {
+		if hour.Before(latest.Add(-LatencyRollupRetention)) {
+			delete(byHour, hour)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LatencyRollups(ctx context.Context, endpoint string, since time.Time) ([]LatencyRollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rollups []LatencyRollup
+	for hour, rollup := range s.rollups[endpoint] {
+		if !hour.Before(since) {
+			rollups = append(rollups, rollup)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Hour.Before(rollups[j].Hour) })
+	return rollups, nil
+}
+
+func (s *MemoryStore) SaveUptime(ctx context.Context, endpoint string, uptime []Uptime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if data, ok := s.endpoints[endpoint]; ok {
+		data.Uptime = slices.Clone(uptime)
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddMaintenanceWindow(ctx context.Context, w MaintenanceWindow) (MaintenanceWindow, error) {
+	w, err := newMaintenanceWindow(w)
+	if err != nil {
+		return w, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.windows[w.ID] = w
+	return w, nil
+}
+
+func (s *MemoryStore) DeleteMaintenanceWindow(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.windows[id]
+	delete(s.windows, id)
+	return ok, nil
+}
+
+func (s *MemoryStore) MaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	windows := make([]MaintenanceWindow, 0, len(s.windows))
+	for _, w := range s.windows {
+		windows = append(windows, w)
+	}
+	sortMaintenanceWindows(windows)
+	return windows, nil
+}
+
+func (s *MemoryStore) SaveHeartbeat(ctx context.Context, heartbeat Heartbeat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	heartbeat.At = heartbeat.At.Truncate(time.Second)
+	s.heartbeat = heartbeat
+	if heartbeat.CheckerID != "" {
+		s.checkers[heartbeat.CheckerID] = heartbeat.At
+		for id, at := range s.checkers {
+			if at.Before(heartbeat.At.Add(-CheckerRetention)) {
+				delete(s.checkers, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Heartbeat(ctx context.Context) (Heartbeat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.heartbeat, nil
+}
+
+func (s *MemoryStore) Checkers(ctx context.Context, since time.Time) ([]CheckerHeartbeat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checkers := make([]CheckerHeartbeat, 0, len(s.checkers))
+	for id, at := range s.checkers {
+		if !at.Before(since.Truncate(time.Second)) {
+			checkers = append(checkers, CheckerHeartbeat{ID: id, At: at})
+		}
+	}
+	sortCheckers(checkers)
+	return checkers, nil
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
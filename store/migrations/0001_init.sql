@@ -0,0 +1,34 @@
+-- Current state, one row per endpoint
+CREATE TABLE endpoints (
+    endpoint         TEXT PRIMARY KEY,
+    status_code      INTEGER,
+    status_updated   TIMESTAMPTZ,
+    ssl_expiration   TIMESTAMPTZ,
+    ssl_updated      TIMESTAMPTZ,
+    expiry_indexed   BOOLEAN NOT NULL DEFAULT FALSE,
+    cert_not_before  TIMESTAMPTZ,
+    cert_subject     TEXT,
+    cert_issuer      TEXT,
+    cert_serial      TEXT,
+    cert_fingerprint TEXT,
+    cert_state       TEXT,
+    header_audit     JSONB
+);
+
+CREATE INDEX endpoints_ssl_expiration_idx ON endpoints (ssl_expiration) WHERE expiry_indexed;
+
+-- Time-series history of every check
+CREATE TABLE status_history (
+    endpoint    TEXT        NOT NULL,
+    checked_at  TIMESTAMPTZ NOT NULL,
+    status_code INTEGER     NOT NULL,
+    PRIMARY KEY (endpoint, checked_at)
+);
+
+CREATE TABLE ssl_history (
+    endpoint    TEXT        NOT NULL,
+    checked_at  TIMESTAMPTZ NOT NULL,
+    not_after   TIMESTAMPTZ NOT NULL,
+    fingerprint TEXT,
+    PRIMARY KEY (endpoint, checked_at)
+);
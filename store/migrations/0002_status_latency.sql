@@ -0,0 +1,2 @@
+-- Latency of each status check, NULL for checks recorded before it was measured
+ALTER TABLE status_history ADD COLUMN latency_ms INTEGER;
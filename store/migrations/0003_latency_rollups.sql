@@ -0,0 +1,11 @@
+-- Hourly latency rollups computed by the checker, kept for 30 days
+CREATE TABLE latency_rollups (
+    endpoint TEXT        NOT NULL,
+    hour     TIMESTAMPTZ NOT NULL,
+    count    INTEGER     NOT NULL,
+    min_ms   INTEGER     NOT NULL,
+    avg_ms   INTEGER     NOT NULL,
+    p95_ms   INTEGER     NOT NULL,
+    max_ms   INTEGER     NOT NULL,
+    PRIMARY KEY (endpoint, hour)
+);
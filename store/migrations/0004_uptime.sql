@@ -0,0 +1,6 @@
+-- Checks and up checks of each hour; 0 for hours rolled up before they were counted
+ALTER TABLE latency_rollups ADD COLUMN checks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE latency_rollups ADD COLUMN up INTEGER NOT NULL DEFAULT 0;
+
+-- Uptime windows computed by the checker, {"24h": {"checks": 1440, "up": 1438}, ...}
+ALTER TABLE endpoints ADD COLUMN uptime JSONB;
@@ -0,0 +1,4 @@
+-- Why the last status check was not up, NULL after a check that is up
+ALTER TABLE endpoints ADD COLUMN error_class TEXT;
+ALTER TABLE endpoints ADD COLUMN error_message TEXT;
+ALTER TABLE endpoints ADD COLUMN error_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+-- Tags of the endpoints file, comma-separated, NULL without any
+ALTER TABLE endpoints ADD COLUMN tags TEXT;
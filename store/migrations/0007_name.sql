@@ -0,0 +1,2 @@
+-- Display name of the endpoints file's name= option, NULL without one
+ALTER TABLE endpoints ADD COLUMN name TEXT;
@@ -0,0 +1,4 @@
+-- First failed check and number of failed checks of the current outage,
+-- NULL after a check that is up
+ALTER TABLE endpoints ADD COLUMN error_since TIMESTAMPTZ;
+ALTER TABLE endpoints ADD COLUMN error_count INTEGER;
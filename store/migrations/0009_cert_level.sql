@@ -0,0 +1,3 @@
+-- Alert level of the certificate, kept by the checker with hysteresis;
+-- NULL for certificates stored before it was
+ALTER TABLE endpoints ADD COLUMN cert_level TEXT;
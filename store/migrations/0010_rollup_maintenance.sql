@@ -0,0 +1,4 @@
+-- Checks and up checks of each hour that fell in a maintenance window; 0 for
+-- hours rolled up before they were counted
+ALTER TABLE latency_rollups ADD COLUMN maintenance INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE latency_rollups ADD COLUMN maintenance_up INTEGER NOT NULL DEFAULT 0;
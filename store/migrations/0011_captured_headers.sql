@@ -0,0 +1,3 @@
+-- Response headers captured for diagnostics with the time they last
+-- changed, NULL until the checker captures any
+ALTER TABLE endpoints ADD COLUMN captured_headers JSONB;
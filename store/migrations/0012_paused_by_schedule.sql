@@ -0,0 +1,3 @@
+-- Set while the endpoint's schedule keeps the checker from checking it,
+-- cleared by its next status check
+ALTER TABLE endpoints ADD COLUMN paused_by_schedule BOOLEAN NOT NULL DEFAULT FALSE;
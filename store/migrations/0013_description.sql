@@ -0,0 +1,2 @@
+-- Description of the endpoints file's desc= option, NULL without one
+ALTER TABLE endpoints ADD COLUMN description TEXT;
@@ -0,0 +1,4 @@
+-- Address that served the last status check and the last certificate check,
+-- NULL when no connection was made
+ALTER TABLE endpoints ADD COLUMN remote_addr TEXT;
+ALTER TABLE endpoints ADD COLUMN cert_remote_addr TEXT;
@@ -0,0 +1,4 @@
+-- CHECKER_ID of the checker instance that wrote the last status and the
+-- last certificate check, NULL for results it did not write
+ALTER TABLE endpoints ADD COLUMN checked_by TEXT;
+ALTER TABLE endpoints ADD COLUMN ssl_checked_by TEXT;
@@ -0,0 +1,10 @@
+-- Set when the last status check fell in a maintenance window, cleared by
+-- the next one outside
+ALTER TABLE endpoints ADD COLUMN in_maintenance BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Weekly maintenance windows managed through the dashboard, each a JSON
+-- MaintenanceWindow under its ID
+CREATE TABLE maintenance_windows (
+    id         TEXT PRIMARY KEY,
+    definition JSONB NOT NULL
+);
@@ -0,0 +1,8 @@
+-- The last heartbeat of each checker instance under its CHECKER_ID, or ''
+-- for instances without one, with its check intervals in seconds
+CREATE TABLE checkers (
+    id              TEXT        PRIMARY KEY,
+    last_heartbeat  TIMESTAMPTZ NOT NULL,
+    status_interval BIGINT      NOT NULL,
+    ssl_interval    BIGINT      NOT NULL
+);
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PauseKeyPrefix prefixes the per-endpoint key holding a pause, which
+// expires with it
+const PauseKeyPrefix = "pause:"
+
+// PauseIndexKey is the sorted set of paused endpoints scored by the Unix
+// milliseconds their pause expires at, so they can be listed without a
+// SCAN and their expiry recorded
+const PauseIndexKey = "pauses"
+
+// Pause stops the checks of an endpoint until a given time without
+// removing it from the configuration
+type Pause struct {
+	Endpoint string    `json:"endpoint"`
+	Reason   string    `json:"reason,omitempty"`
+	User     string    `json:"user,omitempty"` // dashboard login of whoever paused it
+	At       time.Time `json:"at"`
+	Until    time.Time `json:"until"`
+}
+
+// shiftTTLScript moves the expiry of each of KEYS that has one by ARGV[1]
+// milliseconds, which may be negative, leaving at least a millisecond
+var shiftTTLScript = `
+local delta = tonumber(ARGV[1])
+for _, key in ipairs(KEYS) do
+	local ttl = redis.call('PTTL', key)
+	if ttl > 0 then
+		redis.call('PEXPIRE', key, math.max(ttl + delta, 1))
+	end
+end
+return 0
+`
+
+// resultKeys are the keys holding the results of endpoint, which expire
+// without checks
+func (s *RedisStore) resultKeys(endpoint string) []string {
+	return []string{
+		s.keys.Endpoint(endpoint),
+		s.keys.StatusHistory(endpoint),
+		s.keys.SSLHistory(endpoint),
+		s.keys.LatencyRollups(endpoint),
+		s.keys.LatencyHistogram(endpoint),
+	}
+}
+
+// pausedUntil returns when the pause of endpoint in the index ends, or
+// the zero time without one
+func (s *RedisStore) pausedUntil(ctx context.Context, endpoint string) (time.Time, error) {
+	score, err := s.client.ZScore(ctx, s.keys.Key(PauseIndexKey), endpoint).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(score)), nil
+}
+
+// PauseEndpoint stores pause, replacing any earlier pause of its endpoint,
+// and records it in the event stream. It expires on its own at
+// pause.Until. The endpoint's results are kept until then: the TTLs of
+// their keys are extended by the length of the pause, so they are back to
+// what they were once it ends.
+func (s *RedisStore) PauseEndpoint(ctx context.Context, pause Pause) error {
+	now := time.Now()
+	ttl := pause.Until.Sub(now)
+	if ttl <= 0 {
+		return errors.New("pause already expired")
+	}
+	payload, err := json.Marshal(pause)
+	if err != nil {
+		return err
+	}
+	// A replaced pause already extended the TTLs up to its end
+	extendFrom, err := s.pausedUntil(ctx, pause.Endpoint)
+	if err != nil {
+		return err
+	}
+	if extendFrom.Before(now) {
+		extendFrom = now
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, s.keys.Pause(pause.Endpoint), payload, ttl)
+	pipe.ZAdd(ctx, s.keys.Key(PauseIndexKey), redis.Z{Score: float64(pause.Until.UnixMilli()), Member: pause.Endpoint})
+	pipe.Eval(ctx, shiftTTLScript, s.resultKeys(pause.Endpoint), pause.Until.Sub(extendFrom).Milliseconds())
+	event := Event{Endpoint: pause.Endpoint, Kind: EventKindPause, Old: PauseStateActive, New: PauseStatePaused, At: pause.At, User: pause.User, Until: pause.Until}
+	if err := s.queueEvent(ctx, pipe, event); err != nil {
+		return err
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ResumeEndpoint removes the pause of endpoint and reports whether there
+// was one, recording the resumption by user in the event stream. The TTLs
+// of its results lose what remained of the pause's extension.
+func (s *RedisStore) ResumeEndpoint(ctx context.Context, endpoint, user string, at time.Time) (bool, error) {
+	until, err := s.pausedUntil(ctx, endpoint)
+	if err != nil {
+		return false, err
+	}
+	removed, err := s.client.ZRem(ctx, s.keys.Key(PauseIndexKey), endpoint).Result()
+	if err != nil {
+		return false, err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.keys.Pause(endpoint))
+	if removed > 0 && until.After(at) {
+		pipe.Eval(ctx, shiftTTLScript, s.resultKeys(endpoint), -until.Sub(at).Milliseconds())
+	}
+	if removed > 0 {
+		event := Event{Endpoint: endpoint, Kind: EventKindPause, Old: PauseStatePaused, New: PauseStateActive, At: at, User: user}
+		if err := s.queueEvent(ctx, pipe, event); err != nil {
+			return false, err
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// Pauses returns the pauses in effect, soonest expiring first. Expired
+// ones are dropped from the index and their endpoints recorded as resumed
+// in the event stream, once whichever instance drops them.
+func (s *RedisStore) Pauses(ctx context.Context) ([]Pause, error) {
+	index := s.keys.Key(PauseIndexKey)
+	expired, err := s.client.ZRangeByScoreWithScores(ctx, index, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, z := range expired {
+		endpoint, _ := z.Member.(string)
+		// Only the caller whose ZREM removes the entry records the expiry
+		removed, err := s.client.ZRem(ctx, index, endpoint).Result()
+		if err != nil {
+			return nil, err
+		}
+		if removed == 0 {
+			continue
+		}
+		event := Event{Endpoint: endpoint, Kind: EventKindPause, Old: PauseStatePaused, New: PauseStateActive, At: time.UnixMilli(int64(z.Score)).UTC()}
+		if err := s.PublishEvents(ctx, []Event{event}); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoints, err := s.client.ZRange(ctx, index, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		keys[i] = s.keys.Pause(endpoint)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	pauses := make([]Pause, 0, len(values))
+	for _, value := range values {
+		// Keys expire a little ahead of their index entries
+		payload, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var pause Pause
+		if err := json.Unmarshal([]byte(payload), &pause); err != nil {
+			continue
+		}
+		pauses = append(pauses, pause)
+	}
+	return pauses, nil
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestPauses tests pausing, listing, expiring and resuming endpoints, and
+// the events recording it
+func TestPauses(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s.SetResultTTL(time.Hour, time.Hour)
+	if err := s.SetStatus(ctx, "https://a.example.com", 200, now); err != nil {
+		t.Fatal(err)
+	}
+	short := Pause{Endpoint: "https://a.example.com", Reason: "migrating", User: "alice", At: now, Until: now.Add(4 * time.Hour)}
+	long := Pause{Endpoint: "https://b.example.com", At: now, Until: now.Add(24 * time.Hour)}
+	for _, pause := range []Pause{long, short} {
+		if err := s.PauseEndpoint(ctx, pause); err != nil {
+			t.Fatalf("PauseEndpoint(%s) error = %v", pause.Endpoint, err)
+		}
+	}
+	if err := s.PauseEndpoint(ctx, Pause{Endpoint: "https://c.example.com", Until: now.Add(-time.Minute)}); err == nil {
+		t.Error("PauseEndpoint() of an expired pause succeeded")
+	}
+	// The results of a paused endpoint outlive their TTL
+	if ttl := mr.TTL(s.keys.Endpoint(short.Endpoint)); ttl <= 4*time.Hour {
+		t.Errorf("TTL of a paused endpoint = %v, want over the 4h pause", ttl)
+	}
+
+	pauses, err := s.Pauses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pauses) != 2 || pauses[0].User != "alice" || pauses[0].Reason != short.Reason || pauses[1].Endpoint != long.Endpoint {
+		t.Errorf("Pauses() = %+v, want %s then %s", pauses, short.Endpoint, long.Endpoint)
+	}
+
+	// A pause whose time is up is dropped and recorded as resumed, once
+	expiredAt := now.Add(-time.Minute)
+	s.client.ZAdd(ctx, s.keys.Key(PauseIndexKey), redis.Z{Score: float64(expiredAt.UnixMilli()), Member: short.Endpoint})
+	for range 2 {
+		if pauses, _ := s.Pauses(ctx); len(pauses) != 1 || pauses[0].Endpoint != long.Endpoint {
+			t.Errorf("Pauses() after the first expired = %+v, want only %s", pauses, long.Endpoint)
+		}
+	}
+
+	for _, want := range []bool{true, false} {
+		if removed, err := s.ResumeEndpoint(ctx, long.Endpoint, "bob", now); err != nil || removed != want {
+			t.Errorf("ResumeEndpoint() = %v, %v; want %v", removed, err, want)
+		}
+	}
+	if pauses, _ := s.Pauses(ctx); len(pauses) != 0 {
+		t.Errorf("Pauses() after resuming = %+v, want none", pauses)
+	}
+
+	events, err := s.Events(ctx, "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{Endpoint: long.Endpoint, Old: PauseStateActive, New: PauseStatePaused, At: now, Until: long.Until},
+		{Endpoint: short.Endpoint, Old: PauseStateActive, New: PauseStatePaused, At: now, User: "alice", Until: short.Until},
+		{Endpoint: short.Endpoint, Old: PauseStatePaused, New: PauseStateActive, At: expiredAt},
+		{Endpoint: long.Endpoint, Old: PauseStatePaused, New: PauseStateActive, At: now, User: "bob"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Events() = %+v, want %d pause events", events, len(want))
+	}
+	for i, event := range events {
+		want[i].Kind = EventKindPause
+		if event.Event != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, event.Event, want[i])
+		}
+	}
+}
+
+// TestPauseKeepsResults tests that a pause outlasting the result TTL keeps
+// every key of the endpoint's results, and that resuming gives them back
+// the TTLs they had
+func TestPauseKeepsResults(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	endpoint := "https://a.example.com"
+
+	s.SetResultTTL(10*time.Minute, 10*time.Minute)
+	result := Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, Latency: 50 * time.Millisecond,
+		Cert: &CertInfo{NotAfter: now.Add(90 * 24 * time.Hour), State: CertStateValid}}
+	if err := s.SaveResults(ctx, []Result{result}); err != nil {
+		t.Fatal(err)
+	}
+	rollup := LatencyRollup{Hour: now.Truncate(time.Hour), Count: 1, Min: 50 * time.Millisecond, Avg: 50 * time.Millisecond, P95: 50 * time.Millisecond, Max: 50 * time.Millisecond, Checks: 1, Up: 1}
+	if err := s.SaveLatencyRollups(ctx, endpoint, []LatencyRollup{rollup}); err != nil {
+		t.Fatal(err)
+	}
+	ttls := make(map[string]time.Duration)
+	for _, key := range s.resultKeys(endpoint) {
+		if ttls[key] = mr.TTL(key); ttls[key] <= 0 {
+			t.Fatalf("TTL of %s before the pause = %v", key, ttls[key])
+		}
+	}
+
+	pause := Pause{Endpoint: endpoint, At: now, Until: time.Now().Add(4 * time.Hour)}
+	if err := s.PauseEndpoint(ctx, pause); err != nil {
+		t.Fatal(err)
+	}
+	// Replacing the pause extends the TTLs only by the time it adds
+	pause.Until = pause.Until.Add(time.Hour)
+	if err := s.PauseEndpoint(ctx, pause); err != nil {
+		t.Fatal(err)
+	}
+	for key, ttl := range ttls {
+		if got, want := mr.TTL(key), ttl+5*time.Hour; got < want-time.Second || got > want+time.Second {
+			t.Errorf("TTL of %s while paused = %v, want about %v", key, got, want)
+		}
+	}
+
+	mr.FastForward(time.Hour)
+	if data, err := s.GetEndpointData(ctx, endpoint); err != nil || !data.HasStatus {
+		t.Errorf("GetEndpointData() an hour into the pause = %+v, %v; want the stored status", data, err)
+	}
+	if history, err := s.StatusHistory(ctx, endpoint, time.Time{}); err != nil || len(history) != 1 {
+		t.Errorf("StatusHistory() an hour into the pause = %v, %v; want the stored check", history, err)
+	}
+	if history, err := s.SSLHistory(ctx, endpoint); err != nil || len(history) != 1 {
+		t.Errorf("SSLHistory() an hour into the pause = %v, %v; want the stored certificate", history, err)
+	}
+
+	// Resumed an hour in, the keys have the TTLs they had when paused
+	if _, err := s.ResumeEndpoint(ctx, endpoint, "bob", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for key, ttl := range ttls {
+		if got := mr.TTL(key); got < ttl-time.Second || got > ttl+time.Second {
+			t.Errorf("TTL of %s after resuming = %v, want about %v", key, got, ttl)
+		}
+	}
+	mr.FastForward(10*time.Minute + time.Second)
+	if history, _ := s.StatusHistory(ctx, endpoint, time.Time{}); len(history) != 0 {
+		t.Errorf("StatusHistory() past its TTL after resuming = %v, want none", history)
+	}
+}
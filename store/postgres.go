@@ -0,0 +1,836 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// PostgresStore keeps the current state of every endpoint in the endpoints
+// table and appends each check to the status_history and ssl_history tables.
+// The schema is created and upgraded by the embedded migrations at startup.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to databaseURL, retrying while the server is
+// unavailable, and applies any pending migrations.
+func OpenPostgres(ctx context.Context, databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		if attempt == 5 {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to PostgreSQL after %d attempts: %w", attempt, err)
+		}
+		log.Printf("[WARN] PostgreSQL not reachable (attempt %d): %v; retrying in %s", attempt, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	s := &PostgresStore{db: db}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate applies the embedded migrations that have not been recorded in
+// schema_migrations yet, each in its own transaction.
+func (s *PostgresStore) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	names, err := migrationNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var applied bool
+		if err := s.db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, name,
+		).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", name, err)
+		}
+		if applied {
+			continue
+		}
+
+		body, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(body)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		log.Printf("[INFO] Applied database migration %s", name)
+	}
+	return nil
+}
+
+// migrationNames lists the embedded migrations in the order they are applied
+func migrationNames() ([]string, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".sql") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// upsertStatement builds a multi-row INSERT of rows tuples into the endpoints
+// table that updates columns[1:] when the endpoint (columns[0]) exists.
+func upsertStatement(columns []string, rows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO endpoints (%s) VALUES ", strings.Join(columns, ", "))
+	writeValues(&b, len(columns), rows)
+	b.WriteString(" ON CONFLICT (endpoint) DO UPDATE SET ")
+	for i, column := range columns[1:] {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s = EXCLUDED.%s", column, column)
+	}
+	return b.String()
+}
+
+// insertStatement builds a multi-row INSERT into a history table
+func insertStatement(table string, columns []string, rows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	writeValues(&b, len(columns), rows)
+	b.WriteString(" ON CONFLICT DO NOTHING")
+	return b.String()
+}
+
+// writeValues writes rows placeholder tuples of width columns: ($1, $2), ($3, $4)
+func writeValues(b *strings.Builder, columns, rows int) {
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for col := 0; col < columns; col++ {
+			if col > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "$%d", row*columns+col+1)
+		}
+		b.WriteString(")")
+	}
+}
+
+var (
+	statusColumns = []string{"endpoint", "status_code", "status_updated", "error_class", "error_message", "error_at", "error_since", "error_count", "tags", "name", "description", "remote_addr", "checked_by", "in_maintenance", "paused_by_schedule"}
+	pausedColumns = []string{"endpoint", "paused_by_schedule"}
+	certColumns   = []string{"endpoint", "ssl_expiration", "ssl_updated", "expiry_indexed",
+		"cert_not_before", "cert_subject", "cert_issuer", "cert_serial", "cert_fingerprint", "cert_state", "cert_remote_addr", "cert_level", "ssl_checked_by"}
+	headerColumns   = []string{"endpoint", "header_audit"}
+	capturedColumns = []string{"endpoint", "captured_headers"}
+
+	statusHistoryColumns = []string{"endpoint", "checked_at", "status_code", "latency_ms"}
+	rollupColumns        = []string{"endpoint", "hour", "count", "min_ms", "avg_ms", "p95_ms", "max_ms", "checks", "up", "maintenance", "maintenance_up"}
+)
+
+type headerAuditJSON struct {
+	Headers  map[string]string `json:"headers"`
+	Failures []string          `json:"failures"`
+	Updated  int64             `json:"updated"`
+}
+
+// SaveResults upserts the current state and appends history for all results
+// in one transaction, with one multi-row statement per kind of result.
+func (s *PostgresStore) SaveResults(ctx context.Context, results []Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	// A multi-row upsert may not touch the same row twice, so keep the last
+	// result of each kind per endpoint
+	statuses := make(map[string]Result)
+	certs := make(map[string]Result)
+	headers := make(map[string]Result)
+	captured := make(map[string]Result)
+	paused := make(map[string]Result)
+	var statusHistory, sslHistory []interface{}
+	for _, result := range results {
+		if result.HasStatus {
+			statuses[result.Endpoint] = result
+			statusHistory = append(statusHistory, result.Endpoint, result.CheckedAt.UTC(), result.StatusCode, result.Latency.Milliseconds())
+		}
+		if result.Cert != nil {
+			certs[result.Endpoint] = result
+			sslHistory = append(sslHistory, result.Endpoint, result.CheckedAt.UTC(), result.Cert.NotAfter.UTC(), result.Cert.Fingerprint)
+		}
+		if result.HeaderAudit != nil {
+			headers[result.Endpoint] = result
+		}
+		if result.Captured != nil {
+			captured[result.Endpoint] = result
+		}
+		if result.PausedBySchedule {
+			paused[result.Endpoint] = result
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(statuses) > 0 {
+		var args []interface{}
+		for _, r := range statuses {
+			// NULLs clear the error of a previous check
+			var class, message, at, since, count interface{}
+			if r.Error != nil {
+				class, message, at = r.Error.Class, r.Error.Message, r.Error.At.UTC()
+				if !r.Error.Since.IsZero() {
+					since, count = r.Error.Since.UTC(), r.Error.Count
+				}
+			}
+			var tags interface{}
+			if len(r.Tags) > 0 {
+				tags = strings.Join(r.Tags, ",")
+			}
+			var name, description, remoteAddr, checkedBy interface{}
+			if r.Name != "" {
+				name = r.Name
+			}
+			if r.Description != "" {
+				description = r.Description
+			}
+			if r.RemoteAddr != "" {
+				remoteAddr = r.RemoteAddr
+			}
+			if r.CheckedBy != "" {
+				checkedBy = r.CheckedBy
+			}
+			args = append(args, r.Endpoint, r.StatusCode, r.CheckedAt.UTC(), class, message, at, since, count, tags, name, description, remoteAddr, checkedBy, r.InMaintenance, false)
+		}
+		if _, err := tx.ExecContext(ctx, upsertStatement(statusColumns, len(statuses)), args...); err != nil {
+			return fmt.Errorf("failed to upsert statuses: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insertStatement("status_history", statusHistoryColumns, len(statusHistory)/len(statusHistoryColumns)), statusHistory...); err != nil {
+			return fmt.Errorf("failed to append status history: %w", err)
+		}
+	}
+
+	if len(certs) > 0 {
+		var args []interface{}
+		for _, r := range certs {
+			c := r.Cert
+			var remoteAddr, checkedBy interface{}
+			if c.RemoteAddr != "" {
+				remoteAddr = c.RemoteAddr
+			}
+			if r.CheckedBy != "" {
+				checkedBy = r.CheckedBy
+			}
+			args = append(args, r.Endpoint, c.NotAfter.UTC(), r.CheckedAt.UTC(), true,
+				c.NotBefore.UTC(), c.Subject, c.Issuer, c.SerialNumber, c.Fingerprint, c.State, remoteAddr, r.certLevel(), checkedBy)
+		}
+		if _, err := tx.ExecContext(ctx, upsertStatement(certColumns, len(certs)), args...); err != nil {
+			return fmt.Errorf("failed to upsert certificates: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insertStatement("ssl_history",
+			[]string{"endpoint", "checked_at", "not_after", "fingerprint"}, len(sslHistory)/4), sslHistory...); err != nil {
+			return fmt.Errorf("failed to append SSL history: %w", err)
+		}
+	}
+
+	if len(headers) > 0 {
+		var args []interface{}
+		for _, r := range headers {
+			audit, err := json.Marshal(headerAuditJSON{
+				Headers:  r.HeaderAudit.Headers,
+				Failures: r.HeaderAudit.Failures,
+				Updated:  r.HeaderAudit.Updated.Unix(),
+			})
+			if err != nil {
+				return err
+			}
+			args = append(args, r.Endpoint, string(audit))
+		}
+		if _, err := tx.ExecContext(ctx, upsertStatement(headerColumns, len(headers)), args...); err != nil {
+			return fmt.Errorf("failed to upsert header audits: %w", err)
+		}
+	}
+
+	if len(captured) > 0 {
+		var args []interface{}
+		for _, r := range captured {
+			field, err := capturedHeadersField(*r.Captured)
+			if err != nil {
+				return err
+			}
+			args = append(args, r.Endpoint, field)
+		}
+		if _, err := tx.ExecContext(ctx, upsertStatement(capturedColumns, len(captured)), args...); err != nil {
+			return fmt.Errorf("failed to upsert captured headers: %w", err)
+		}
+	}
+
+	if len(paused) > 0 {
+		var args []interface{}
+		for endpoint := range paused {
+			args = append(args, endpoint, true)
+		}
+		if _, err := tx.ExecContext(ctx, upsertStatement(pausedColumns, len(paused)), args...); err != nil {
+			return fmt.Errorf("failed to mark endpoints paused by schedule: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) SetStatus(ctx context.Context, endpoint string, statusCode int, checkedAt time.Time) error {
+	return s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: checkedAt, HasStatus: true, StatusCode: statusCode}})
+}
+
+func (s *PostgresStore) SetSSLExpiry(ctx context.Context, endpoint string, expiration time.Time, checkedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO endpoints (endpoint, ssl_expiration, ssl_updated, expiry_indexed)
+		VALUES ($1, $2, $3, TRUE)
+		ON CONFLICT (endpoint) DO UPDATE SET ssl_expiration = EXCLUDED.ssl_expiration,
+			ssl_updated = EXCLUDED.ssl_updated, expiry_indexed = TRUE`,
+		endpoint, expiration.UTC(), checkedAt.UTC())
+	return err
+}
+
+func (s *PostgresStore) SetCertInfo(ctx context.Context, endpoint string, cert CertInfo, checkedAt time.Time) error {
+	return s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: checkedAt, Cert: &cert}})
+}
+
+func (s *PostgresStore) PruneSSLExpiryIndex(ctx context.Context, keep []string) (int, error) {
+	if keep == nil {
+		keep = []string{}
+	}
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE endpoints SET expiry_indexed = FALSE WHERE expiry_indexed AND NOT (endpoint = ANY($1))`, keep)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := res.RowsAffected()
+	return int(removed), err
+}
+
+func (s *PostgresStore) ListEndpoints(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT endpoint FROM endpoints WHERE status_updated IS NOT NULL OR ssl_updated IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var endpoints []string
+	for rows.Next() {
+		var endpoint string
+		if err := rows.Scan(&endpoint); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+const selectEndpointData = `SELECT endpoint, status_code, status_updated, ssl_expiration, ssl_updated,
+	cert_not_before, cert_subject, cert_issuer, cert_serial, cert_fingerprint, cert_state, cert_remote_addr, cert_level, header_audit, captured_headers, uptime,
+	error_class, error_message, error_at, error_since, error_count, tags, name, description, remote_addr, checked_by, ssl_checked_by, in_maintenance, paused_by_schedule
+	FROM endpoints`
+
+// ListEndpointData reads every endpoint with a single query
+func (s *PostgresStore) ListEndpointData(ctx context.Context) ([]EndpointData, error) {
+	rows, err := s.db.QueryContext(ctx, selectEndpointData+` WHERE status_updated IS NOT NULL OR ssl_updated IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []EndpointData
+	for rows.Next() {
+		data, err := scanEndpointData(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, rows.Err()
+}
+
+func (s *PostgresStore) GetEndpointData(ctx context.Context, endpoint string) (EndpointData, error) {
+	data, err := scanEndpointData(s.db.QueryRowContext(ctx, selectEndpointData+` WHERE endpoint = $1`, endpoint))
+	if err == sql.ErrNoRows {
+		return EndpointData{Endpoint: endpoint}, nil
+	}
+	return data, err
+}
+
+// GetEndpointsData reads the endpoints with a single query
+func (s *PostgresStore) GetEndpointsData(ctx context.Context, endpoints []string) ([]EndpointData, error) {
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.QueryContext(ctx, selectEndpointData+` WHERE endpoint = ANY($1)`, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]EndpointData, len(endpoints))
+	for rows.Next() {
+		data, err := scanEndpointData(rows)
+		if err != nil {
+			return nil, err
+		}
+		stored[data.Endpoint] = data
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]EndpointData, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		data, ok := stored[endpoint]
+		if !ok {
+			data = EndpointData{Endpoint: endpoint}
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+func scanEndpointData(row interface{ Scan(...interface{}) error }) (EndpointData, error) {
+	var (
+		data                                                EndpointData
+		statusCode                                          sql.NullInt64
+		statusUpdated, sslExpiration, sslUpdated, notBefore sql.NullTime
+		subject, issuer, serial, fingerprint, state, level  sql.NullString
+		certRemoteAddr, remoteAddr, checkedBy, sslCheckedBy sql.NullString
+		headerAudit, captured, uptime                       []byte
+		errorClass, errorMessage, tags, name, description   sql.NullString
+		errorAt, errorSince                                 sql.NullTime
+		errorCount                                          sql.NullInt64
+	)
+	if err := row.Scan(&data.Endpoint, &statusCode, &statusUpdated, &sslExpiration, &sslUpdated,
+		&notBefore, &subject, &issuer, &serial, &fingerprint, &state, &certRemoteAddr, &level, &headerAudit, &captured, &uptime,
+		&errorClass, &errorMessage, &errorAt, &errorSince, &errorCount, &tags, &name, &description, &remoteAddr, &checkedBy, &sslCheckedBy, &data.InMaintenance, &data.PausedBySchedule); err != nil {
+		return data, err
+	}
+
+	if statusCode.Valid {
+		data.StatusCode = int(statusCode.Int64)
+		data.HasStatus = true
+	}
+	data.StatusUpdated = nullTime(statusUpdated)
+
+	if audit := parseHeaderAuditJSON(headerAudit); audit != nil {
+		data.HeaderAudit = audit
+	}
+	data.Captured = parseCapturedHeaders(string(captured))
+	data.Uptime = parseUptimeJSON(uptime)
+	if errorClass.Valid {
+		data.Error = &CheckError{Class: errorClass.String, Message: errorMessage.String, At: nullTime(errorAt), Since: nullTime(errorSince), Count: int(errorCount.Int64)}
+	}
+	if tags.String != "" {
+		data.Tags = strings.Split(tags.String, ",")
+	}
+	data.Name = name.String
+	data.Description = description.String
+	data.RemoteAddr = remoteAddr.String
+	data.CheckedBy = checkedBy.String
+
+	// SSL data only exists for HTTPS endpoints
+	if !strings.HasPrefix(data.Endpoint, "https://") {
+		return data, nil
+	}
+	data.SSLExpiration = nullTime(sslExpiration)
+	data.SSLUpdated = nullTime(sslUpdated)
+	data.CertLevel = level.String
+	data.SSLCheckedBy = sslCheckedBy.String
+	if state.Valid {
+		data.CertInfo = &CertInfo{
+			NotBefore:    nullTime(notBefore),
+			NotAfter:     data.SSLExpiration,
+			Subject:      subject.String,
+			Issuer:       issuer.String,
+			SerialNumber: serial.String,
+			Fingerprint:  fingerprint.String,
+			State:        state.String,
+			RemoteAddr:   certRemoteAddr.String,
+		}
+	}
+	return data, nil
+}
+
+func parseHeaderAuditJSON(raw []byte) *HeaderAudit {
+	if len(raw) == 0 {
+		return nil
+	}
+	var stored headerAuditJSON
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil
+	}
+	audit := &HeaderAudit{
+		Headers:  stored.Headers,
+		Failures: stored.Failures,
+		Updated:  time.Unix(stored.Updated, 0).UTC(),
+	}
+	if audit.Headers == nil {
+		audit.Headers = make(map[string]string)
+	}
+	return audit
+}
+
+// uptimeJSON is one window of the uptime column
+type uptimeJSON struct {
+	Checks int `json:"checks"`
+	Up     int `json:"up"`
+}
+
+func parseUptimeJSON(raw []byte) []Uptime {
+	if len(raw) == 0 {
+		return nil
+	}
+	var stored map[string]uptimeJSON
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil
+	}
+	var uptime []Uptime
+	for _, window := range UptimeWindows {
+		if u, ok := stored[window.Name]; ok {
+			uptime = append(uptime, Uptime{Window: window.Name, Checks: u.Checks, Up: u.Up})
+		}
+	}
+	return uptime
+}
+
+func nullTime(t sql.NullTime) time.Time {
+	if !t.Valid {
+		return time.Time{}
+	}
+	return t.Time.UTC().Truncate(time.Second)
+}
+
+func (s *PostgresStore) ExpiringBefore(ctx context.Context, t time.Time) ([]Expiry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT endpoint, ssl_expiration FROM endpoints
+		WHERE expiry_indexed AND ssl_expiration <= $1
+		ORDER BY ssl_expiration, endpoint`, t.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expiring []Expiry
+	for rows.Next() {
+		var e Expiry
+		if err := rows.Scan(&e.Endpoint, &e.SSLExpiration); err != nil {
+			return nil, err
+		}
+		e.SSLExpiration = e.SSLExpiration.UTC().Truncate(time.Second)
+		expiring = append(expiring, e)
+	}
+	return expiring, rows.Err()
+}
+
+func (s *PostgresStore) StatusHistory(ctx context.Context, endpoint string, since time.Time) ([]HistoryEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT checked_at, status_code, latency_ms FROM status_history
+		WHERE endpoint = $1 AND checked_at >= $2
+		ORDER BY checked_at`, endpoint, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var latency sql.NullInt64
+		if err := rows.Scan(&entry.CheckedAt, &entry.StatusCode, &latency); err != nil {
+			return nil, err
+		}
+		entry.CheckedAt = entry.CheckedAt.UTC()
+		entry.Latency = time.Duration(latency.Int64) * time.Millisecond
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// SSLHistory derives renewals from ssl_history, which records every check
+func (s *PostgresStore) SSLHistory(ctx context.Context, endpoint string) ([]SSLObservation, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT checked_at, not_after, fingerprint FROM (
+			SELECT checked_at, not_after, fingerprint,
+				LAG(not_after) OVER (ORDER BY checked_at) AS previous
+			FROM ssl_history WHERE endpoint = $1
+		) h
+		WHERE previous IS NULL OR previous <> not_after
+		ORDER BY checked_at DESC
+		LIMIT $2`, endpoint, SSLHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []SSLObservation
+	for rows.Next() {
+		var observation SSLObservation
+		var fingerprint sql.NullString
+		if err := rows.Scan(&observation.ObservedAt, &observation.NotAfter, &fingerprint); err != nil {
+			return nil, err
+		}
+		observation.ObservedAt = observation.ObservedAt.UTC()
+		observation.NotAfter = observation.NotAfter.UTC()
+		observation.Fingerprint = fingerprint.String
+		history = append(history, observation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(history)
+	return history, nil
+}
+
+func (s *PostgresStore) SaveLatencyRollups(ctx context.Context, endpoint string, rollups []LatencyRollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	var args []interface{}
+	latest := rollups[0].Hour
+	for _, r := range rollups {
+		args = append(args, endpoint, r.Hour.UTC(), r.Count,
+			r.Min.Milliseconds(), r.Avg.Milliseconds(), r.P95.Milliseconds(), r.Max.Milliseconds(), r.Checks, r.Up, r.Maintenance, r.MaintenanceUp)
+		if r.Hour.After(latest) {
+			latest = r.Hour
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO latency_rollups (%s) VALUES ", strings.Join(rollupColumns, ", "))
+	writeValues(&b, len(rollupColumns), len(rollups))
+	b.WriteString(" ON CONFLICT (endpoint, hour) DO UPDATE SET count = EXCLUDED.count, min_ms = EXCLUDED.min_ms," +
+		" avg_ms = EXCLUDED.avg_ms, p95_ms = EXCLUDED.p95_ms, max_ms = EXCLUDED.max_ms," +
+		" checks = EXCLUDED.checks, up = EXCLUDED.up," +
+		" maintenance = EXCLUDED.maintenance, maintenance_up = EXCLUDED.maintenance_up")
+	if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+		return fmt.Errorf("failed to upsert latency rollups: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM latency_rollups WHERE endpoint = $1 AND hour < $2`,
+		endpoint, latest.Add(-LatencyRollupRetention).UTC()); err != nil {
+		return fmt.Errorf("failed to trim latency rollups: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) LatencyRollups(ctx context.Context, endpoint string, since time.Time) ([]LatencyRollup, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT hour, count, min_ms, avg_ms, p95_ms, max_ms, checks, up, maintenance, maintenance_up FROM latency_rollups
+		WHERE endpoint = $1 AND hour >= $2
+		ORDER BY hour`, endpoint, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []LatencyRollup
+	for rows.Next() {
+		var r LatencyRollup
+		var minMs, avgMs, p95Ms, maxMs int64
+		if err := rows.Scan(&r.Hour, &r.Count, &minMs, &avgMs, &p95Ms, &maxMs, &r.Checks, &r.Up, &r.Maintenance, &r.MaintenanceUp); err != nil {
+			return nil, err
+		}
+		r.Hour = r.Hour.UTC()
+		r.Min = time.Duration(minMs) * time.Millisecond
+		r.Avg = time.Duration(avgMs) * time.Millisecond
+		r.P95 = time.Duration(p95Ms) * time.Millisecond
+		r.Max = time.Duration(maxMs) * time.Millisecond
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+func (s *PostgresStore) SaveUptime(ctx context.Context, endpoint string, uptime []Uptime) error {
+	stored := make(map[string]uptimeJSON, len(uptime))
+	for _, u := range uptime {
+		stored[u.Window] = uptimeJSON{Checks: u.Checks, Up: u.Up}
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `UPDATE endpoints SET uptime = $2 WHERE endpoint = $1`, endpoint, value)
+	return err
+}
+
+func (s *PostgresStore) AddMaintenanceWindow(ctx context.Context, w MaintenanceWindow) (MaintenanceWindow, error) {
+	w, err := newMaintenanceWindow(w)
+	if err != nil {
+		return w, err
+	}
+	definition, err := json.Marshal(w)
+	if err != nil {
+		return w, err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO maintenance_windows (id, definition) VALUES ($1, $2)`, w.ID, definition)
+	return w, err
+}
+
+func (s *PostgresStore) DeleteMaintenanceWindow(ctx context.Context, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	return removed > 0, err
+}
+
+// MaintenanceWindows skips rows that do not parse or validate, like
+// RedisStore.MaintenanceWindows
+func (s *PostgresStore) MaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT definition FROM maintenance_windows ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var definition []byte
+		if err := rows.Scan(&definition); err != nil {
+			return nil, err
+		}
+		var w MaintenanceWindow
+		if json.Unmarshal(definition, &w) != nil || w.Validate() != nil {
+			continue
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+func (s *PostgresStore) SaveHeartbeat(ctx context.Context, heartbeat Heartbeat) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	at := heartbeat.At.Truncate(time.Second)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO checkers (id, last_heartbeat, status_interval, ssl_interval) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET last_heartbeat = EXCLUDED.last_heartbeat,
+			status_interval = EXCLUDED.status_interval, ssl_interval = EXCLUDED.ssl_interval`,
+		heartbeat.CheckerID, at, int64(heartbeat.StatusInterval.Seconds()), int64(heartbeat.SSLInterval.Seconds()),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checkers WHERE last_heartbeat < $1`, at.Add(-CheckerRetention)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) Heartbeat(ctx context.Context) (Heartbeat, error) {
+	var heartbeat Heartbeat
+	var statusInterval, sslInterval int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, last_heartbeat, status_interval, ssl_interval FROM checkers ORDER BY last_heartbeat DESC LIMIT 1`,
+	).Scan(&heartbeat.CheckerID, &heartbeat.At, &statusInterval, &sslInterval)
+	if err == sql.ErrNoRows {
+		return Heartbeat{}, nil
+	}
+	if err != nil {
+		return Heartbeat{}, err
+	}
+	heartbeat.StatusInterval = time.Duration(statusInterval) * time.Second
+	heartbeat.SSLInterval = time.Duration(sslInterval) * time.Second
+	return heartbeat, nil
+}
+
+func (s *PostgresStore) Checkers(ctx context.Context, since time.Time) ([]CheckerHeartbeat, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, last_heartbeat FROM checkers WHERE id <> '' AND last_heartbeat >= $1 ORDER BY last_heartbeat DESC, id`,
+		since.Truncate(time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkers := []CheckerHeartbeat{}
+	for rows.Next() {
+		var checker CheckerHeartbeat
+		if err := rows.Scan(&checker.ID, &checker.At); err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, checker)
+	}
+	return checkers, rows.Err()
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
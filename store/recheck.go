@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RecheckChannel is the Redis pub/sub channel the dashboard publishes
+// endpoints on that a user asked to check right away
+const RecheckChannel = "certs-n-status:recheck"
+
+// RecheckKeyPrefix prefixes the per-endpoint key that holds back further
+// rechecks for RecheckInterval
+const RecheckKeyPrefix = "recheck:"
+
+// RecheckInterval is the shortest time between two rechecks of an endpoint
+const RecheckInterval = 10 * time.Second
+
+// ErrNoRecheckSubscriber means no checker was subscribed to RecheckChannel
+// to receive a recheck request
+var ErrNoRecheckSubscriber = errors.New("no checker is subscribed to recheck requests")
+
+// RequestRecheck publishes endpoint on RecheckChannel unless it was
+// requested within RecheckInterval, in which case ok is false and wait is
+// how long until the next request is accepted. The interval is kept in
+// Redis, so it holds across dashboard replicas.
+func (s *RedisStore) RequestRecheck(ctx context.Context, endpoint string) (ok bool, wait time.Duration, err error) {
+	key := s.keys.Recheck(endpoint)
+	set, err := s.client.SetNX(ctx, key, time.Now().Unix(), RecheckInterval).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if !set {
+		wait, err := s.client.PTTL(ctx, key).Result()
+		if err != nil {
+			return false, 0, err
+		}
+		return false, max(wait, time.Millisecond), nil
+	}
+
+	receivers, err := s.client.Publish(ctx, s.keys.Key(RecheckChannel), endpoint).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if receivers == 0 {
+		// Nobody ran the recheck, so it does not count against the interval
+		s.client.Del(ctx, key)
+		return false, 0, ErrNoRecheckSubscriber
+	}
+	return true, 0, nil
+}
+
+// WatchRechecks calls handle with every endpoint published on
+// RecheckChannel until ctx is done. Requests published while the
+// subscription is down are missed.
+func (s *RedisStore) WatchRechecks(ctx context.Context, handle func(endpoint string)) {
+	pubsub := s.client.Subscribe(ctx, s.keys.Key(RecheckChannel))
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			handle(msg.Payload)
+		}
+	}
+}
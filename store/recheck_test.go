@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRequestRecheck tests that recheck requests reach WatchRechecks, at
+// most once per RecheckInterval per endpoint, and fail without a subscriber
+func TestRequestRecheck(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	endpoint := "https://example.com"
+
+	if _, _, err := s.RequestRecheck(ctx, endpoint); !errors.Is(err, ErrNoRecheckSubscriber) {
+		t.Fatalf("RequestRecheck without subscriber: err = %v, want ErrNoRecheckSubscriber", err)
+	}
+	if mr.Exists(s.keys.Recheck(endpoint)) {
+		t.Error("unreceived recheck counts against the interval")
+	}
+
+	received := make(chan string, 10)
+	go s.WatchRechecks(ctx, func(endpoint string) { received <- endpoint })
+	for mr.PubSubNumSub(RecheckChannel)[RecheckChannel] == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		advance  time.Duration
+		wantOK   bool
+	}{
+		{"first request", endpoint, 0, true},
+		{"repeated request", endpoint, 0, false},
+		{"other endpoint", "https://other.example.com", 0, true},
+		{"within interval", endpoint, RecheckInterval - time.Second, false},
+		{"after interval", endpoint, time.Second, true},
+	}
+
+	for _, tt := range tests {
+		mr.FastForward(tt.advance)
+		ok, wait, err := s.RequestRecheck(ctx, tt.endpoint)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+		}
+		if !ok && (wait <= 0 || wait > RecheckInterval) {
+			t.Errorf("%s: wait = %s, want within (0, %s]", tt.name, wait, RecheckInterval)
+		}
+		if !ok {
+			continue
+		}
+		select {
+		case got := <-received:
+			if got != tt.endpoint {
+				t.Errorf("%s: received %q, want %q", tt.name, got, tt.endpoint)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: no recheck received", tt.name)
+		}
+	}
+}
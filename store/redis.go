@@ -0,0 +1,935 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SSLExpiryIndexKey is a sorted set of endpoints scored by certificate NotAfter
+const SSLExpiryIndexKey = "ssl_expiry_index"
+
+// EndpointRegistryKey is a set of the endpoints being checked, so readers
+// do not have to SCAN the keyspace
+const EndpointRegistryKey = "endpoints_registry"
+
+// EndpointKeyPrefix prefixes the per-endpoint hash holding all of its results
+const EndpointKeyPrefix = "endpoint:"
+
+// StatusHistoryKeyPrefix prefixes the per-endpoint sorted set of status checks
+const StatusHistoryKeyPrefix = "history:status:"
+
+// SSLHistoryKeyPrefix prefixes the per-endpoint list of observed certificate renewals
+const SSLHistoryKeyPrefix = "history:ssl:"
+
+// LatencyRollupKeyPrefix prefixes the per-endpoint sorted set of hourly latency rollups
+const LatencyRollupKeyPrefix = "history:latency:hourly:"
+
+// RedisStore keeps results in Redis using one hash per endpoint:
+//
+//	endpoint:<url>   status, status_updated, ssl_expiry, ssl_updated,
+//	                 cert_* certificate details and alert level,
+//	                 headers_* header audit,
+//	                 uptime_* uptime windows, error_* last check error
+//	                 and start of the outage,
+//	                 captured_headers JSON captured response headers,
+//	                 tags comma-separated tags, name display name,
+//	                 in_maintenance, paused_by_schedule
+//	ssl_expiry_index sorted set of endpoints scored by NotAfter
+//	endpoints_registry set of checked endpoints
+//	history:status:<url> sorted set of status checks scored by Unix milliseconds
+//	history:ssl:<url>    list of JSON certificate observations, one per NotAfter change
+//	history:latency:hourly:<url> sorted set of JSON latency rollups scored by hour
+//	histogram:latency:<url> hash of latency bucket counts by window and slot
+//	events           stream of state-change events
+//	recheck:<url>    marker holding back rechecks for RecheckInterval
+//	ack:<url>        JSON acknowledgement, expiring with it
+//	acks             sorted set of acknowledged endpoints scored by expiry
+//	maintenance      hash of JSON maintenance windows by ID
+//	slo              hash of JSON SLO statuses by tag
+//	alert_state      hash of JSON alert states by endpoint, what was last
+//	                 notified
+//	checker_heartbeat hash of at, status_interval and ssl_interval of the
+//	                 checker's last cycle
+//	leader           ID of the checker instance leading its replicas
+//	notifications:dead_letter list of JSON notifications that could not
+//	                 be delivered, newest first
+//	notifications:history stream of every notification sent or given up on
+//
+// All names are built by Keys, under the prefix set with SetKeyPrefix.
+// Each write is a single HSET so readers never see a half-updated endpoint.
+type RedisStore struct {
+	client         *redis.Client
+	statusTTL      time.Duration
+	sslTTL         time.Duration
+	scanDiscovery  bool
+	history        HistoryRetention
+	latencyBuckets []time.Duration
+	eventsMaxLen   int64
+	alertsMaxLen   int64
+	alertsMaxAge   time.Duration
+	keys           Keys
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		client:         client,
+		history:        DefaultHistoryRetention,
+		latencyBuckets: DefaultLatencyBuckets,
+		eventsMaxLen:   DefaultEventStreamMaxLen,
+		alertsMaxLen:   DefaultAlertHistoryMaxLen,
+		alertsMaxAge:   DefaultAlertHistoryMaxAge,
+	}
+}
+
+// SetHistoryRetention sets how much status history SaveResults keeps per endpoint
+func (s *RedisStore) SetHistoryRetention(retention HistoryRetention) {
+	s.history = retention
+}
+
+// SetResultTTL makes endpoint hashes expire statusTTL after the last status
+// write or sslTTL after the last SSL write, whichever is later, so endpoints
+// that are no longer checked age out. Zero keeps results forever.
+func (s *RedisStore) SetResultTTL(statusTTL, sslTTL time.Duration) {
+	s.statusTTL = statusTTL
+	s.sslTTL = sslTTL
+}
+
+// SetEventStreamMaxLen caps the event stream at maxLen entries, trimming
+// the oldest. Zero keeps every event.
+func (s *RedisStore) SetEventStreamMaxLen(maxLen int64) {
+	s.eventsMaxLen = maxLen
+}
+
+// SetKeyPrefix namespaces every key, e.g. "prod:", so several environments
+// can share one Redis. The empty prefix uses the plain key names.
+func (s *RedisStore) SetKeyPrefix(prefix string) {
+	s.keys = Keys{Prefix: prefix}
+}
+
+// SetScanDiscovery makes ListEndpoints SCAN for endpoint hashes instead of
+// reading the registry, for data written before the registry existed
+func (s *RedisStore) SetScanDiscovery(scan bool) {
+	s.scanDiscovery = scan
+}
+
+func (s *RedisStore) SetStatus(ctx context.Context, endpoint string, statusCode int, checkedAt time.Time) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.keys.Endpoint(endpoint), statusFields(statusCode, checkedAt)...)
+	s.queueRefresh(ctx, pipe, endpoint, s.statusTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) SetSSLExpiry(ctx context.Context, endpoint string, expiration time.Time, checkedAt time.Time) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.keys.Endpoint(endpoint), sslFields(expiration, checkedAt)...)
+	s.queueRefresh(ctx, pipe, endpoint, s.sslTTL)
+	s.queueExpiryIndex(ctx, pipe, endpoint, expiration)
+	if err := s.queueSSLObservation(ctx, pipe, endpoint, SSLObservation{ObservedAt: checkedAt, NotAfter: expiration}); err != nil {
+		return err
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) SetCertInfo(ctx context.Context, endpoint string, cert CertInfo, checkedAt time.Time) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.keys.Endpoint(endpoint), certFields(cert, checkedAt)...)
+	s.queueRefresh(ctx, pipe, endpoint, s.sslTTL)
+	s.queueExpiryIndex(ctx, pipe, endpoint, cert.NotAfter)
+	if err := s.queueSSLObservation(ctx, pipe, endpoint, newSSLObservation(cert, checkedAt)); err != nil {
+		return err
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SaveResults writes all results in a single MULTI/EXEC transaction, one HSET per endpoint
+func (s *RedisStore) SaveResults(ctx context.Context, results []Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	pipe := s.client.TxPipeline()
+	for _, result := range results {
+		var fields []interface{}
+		var ttl time.Duration
+		if result.HasStatus {
+			fields = append(fields, statusFields(result.StatusCode, result.CheckedAt)...)
+			if result.Error != nil {
+				fields = append(fields, errorFields(*result.Error)...)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), errorFieldNames...)
+			}
+			if len(result.Tags) > 0 {
+				fields = append(fields, "tags", strings.Join(result.Tags, ","))
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "tags")
+			}
+			if result.Name != "" {
+				fields = append(fields, "name", result.Name)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "name")
+			}
+			if result.Description != "" {
+				fields = append(fields, "description", result.Description)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "description")
+			}
+			if result.RemoteAddr != "" {
+				fields = append(fields, "remote_addr", result.RemoteAddr)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "remote_addr")
+			}
+			if result.CheckedBy != "" {
+				fields = append(fields, "checked_by", result.CheckedBy)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "checked_by")
+			}
+			if result.InMaintenance {
+				fields = append(fields, "in_maintenance", "1")
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "in_maintenance")
+			}
+			pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "paused_by_schedule")
+			ttl = s.statusTTL
+			s.queueHistory(ctx, pipe, result)
+			s.queueLatencyHistogram(ctx, pipe, result)
+		}
+		if result.PausedBySchedule {
+			fields = append(fields, "paused_by_schedule", "1")
+			ttl = s.statusTTL
+		}
+		if result.Cert != nil {
+			fields = append(fields, certFields(*result.Cert, result.CheckedAt)...)
+			fields = append(fields, "cert_level", result.certLevel())
+			if result.CheckedBy != "" {
+				fields = append(fields, "ssl_checked_by", result.CheckedBy)
+			} else {
+				pipe.HDel(ctx, s.keys.Endpoint(result.Endpoint), "ssl_checked_by")
+			}
+			ttl = max(ttl, s.sslTTL)
+			s.queueExpiryIndex(ctx, pipe, result.Endpoint, result.Cert.NotAfter)
+			if err := s.queueSSLObservation(ctx, pipe, result.Endpoint, newSSLObservation(*result.Cert, result.CheckedAt)); err != nil {
+				return err
+			}
+		}
+		if result.HeaderAudit != nil {
+			auditFields, err := headerAuditFields(*result.HeaderAudit)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, auditFields...)
+		}
+		if result.Captured != nil {
+			captured, err := capturedHeadersField(*result.Captured)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, "captured_headers", captured)
+		}
+		if len(fields) > 0 {
+			pipe.HSet(ctx, s.keys.Endpoint(result.Endpoint), fields...)
+			s.queueRefresh(ctx, pipe, result.Endpoint, ttl)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func statusFields(statusCode int, checkedAt time.Time) []interface{} {
+	return []interface{}{
+		"status", statusCode,
+		"status_updated", checkedAt.Unix(),
+	}
+}
+
+// errorFieldNames are the hash fields of a CheckError, deleted by a check
+// that is up
+var errorFieldNames = []string{"error_class", "error_message", "error_at", "error_since", "error_count"}
+
+func errorFields(checkError CheckError) []interface{} {
+	fields := []interface{}{
+		"error_class", checkError.Class,
+		"error_message", checkError.Message,
+		"error_at", checkError.At.Unix(),
+	}
+	if !checkError.Since.IsZero() {
+		fields = append(fields, "error_since", checkError.Since.Unix(), "error_count", checkError.Count)
+	}
+	return fields
+}
+
+func sslFields(expiration time.Time, checkedAt time.Time) []interface{} {
+	return []interface{}{
+		"ssl_expiry", expiration.Unix(),
+		"ssl_updated", checkedAt.Unix(),
+	}
+}
+
+func certFields(cert CertInfo, checkedAt time.Time) []interface{} {
+	return append(sslFields(cert.NotAfter, checkedAt),
+		"cert_not_before", cert.NotBefore.Unix(),
+		"cert_subject", cert.Subject,
+		"cert_issuer", cert.Issuer,
+		"cert_serial", cert.SerialNumber,
+		"cert_fingerprint", cert.Fingerprint,
+		"cert_state", cert.State,
+		"cert_remote_addr", cert.RemoteAddr,
+	)
+}
+
+// headerAuditFields stores the captured headers as one JSON field so headers
+// that disappeared since the previous audit are not kept
+func headerAuditFields(audit HeaderAudit) ([]interface{}, error) {
+	headers, err := json.Marshal(audit.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		"headers_pass", audit.Passed(),
+		"headers_failures", strings.Join(audit.Failures, "; "),
+		"headers_updated", audit.Updated.Unix(),
+		"headers", string(headers),
+	}, nil
+}
+
+// capturedHeadersJSON is the stored form of CapturedHeaders
+type capturedHeadersJSON struct {
+	Headers map[string]string `json:"headers"`
+	Changed int64             `json:"changed"`
+}
+
+func capturedHeadersField(captured CapturedHeaders) (string, error) {
+	payload, err := json.Marshal(capturedHeadersJSON{Headers: captured.Headers, Changed: captured.Changed.Unix()})
+	return string(payload), err
+}
+
+// parseCapturedHeaders parses a captured_headers field, nil when it is
+// missing or malformed
+func parseCapturedHeaders(value string) *CapturedHeaders {
+	var stored capturedHeadersJSON
+	if value == "" || json.Unmarshal([]byte(value), &stored) != nil {
+		return nil
+	}
+	captured := &CapturedHeaders{Headers: stored.Headers, Changed: time.Unix(stored.Changed, 0).UTC()}
+	if captured.Headers == nil {
+		captured.Headers = make(map[string]string)
+	}
+	return captured
+}
+
+// queueRefresh registers the endpoint and refreshes its hash TTL without
+// shortening a longer one, e.g. status writes never cut the SSL data's TTL
+func (s *RedisStore) queueRefresh(ctx context.Context, pipe redis.Pipeliner, endpoint string, ttl time.Duration) {
+	pipe.SAdd(ctx, s.keys.Key(EndpointRegistryKey), endpoint)
+	if ttl <= 0 {
+		return
+	}
+	pipe.ExpireNX(ctx, s.keys.Endpoint(endpoint), ttl)
+	pipe.ExpireGT(ctx, s.keys.Endpoint(endpoint), ttl)
+}
+
+// queueHistory appends a status check to the endpoint's history and trims it
+// to the retention. The member is "<code>|<latency ms>|<checked at ms>"; the
+// timestamp keeps identical results from collapsing into one member.
+func (s *RedisStore) queueHistory(ctx context.Context, pipe redis.Pipeliner, result Result) {
+	key := s.keys.StatusHistory(result.Endpoint)
+	checkedAt := result.CheckedAt.UnixMilli()
+	pipe.ZAdd(ctx, key, redis.Z{
+		Score:  float64(checkedAt),
+		Member: fmt.Sprintf("%d|%d|%d", result.StatusCode, result.Latency.Milliseconds(), checkedAt),
+	})
+	if s.history.Count > 0 {
+		pipe.ZRemRangeByRank(ctx, key, 0, int64(-s.history.Count-1))
+	}
+	if s.history.MaxAge > 0 {
+		cutoff := result.CheckedAt.Add(-s.history.MaxAge).UnixMilli()
+		pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", cutoff))
+	}
+	if s.statusTTL > 0 {
+		pipe.ExpireNX(ctx, key, s.statusTTL)
+		pipe.ExpireGT(ctx, key, s.statusTTL)
+	}
+}
+
+// appendIfChangedScript appends ARGV[1] to the list KEYS[1] unless the last
+// entry has the same not_after (ARGV[2]), then caps the list at ARGV[3] entries
+var appendIfChangedScript = `
+local last = redis.call('LINDEX', KEYS[1], -1)
+if last and cjson.decode(last)['not_after'] == tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('RPUSH', KEYS[1], ARGV[1])
+redis.call('LTRIM', KEYS[1], -tonumber(ARGV[3]), -1)
+return 1
+`
+
+// queueSSLObservation appends a certificate observation to the endpoint's
+// renewal history when its NotAfter differs from the last one recorded
+func (s *RedisStore) queueSSLObservation(ctx context.Context, pipe redis.Pipeliner, endpoint string, observation SSLObservation) error {
+	entry, err := json.Marshal(sslObservationJSON{
+		ObservedAt:  observation.ObservedAt.Unix(),
+		NotAfter:    observation.NotAfter.Unix(),
+		Fingerprint: observation.Fingerprint,
+	})
+	if err != nil {
+		return err
+	}
+
+	key := s.keys.SSLHistory(endpoint)
+	pipe.Eval(ctx, appendIfChangedScript, []string{key}, string(entry), observation.NotAfter.Unix(), SSLHistoryLimit)
+	if s.sslTTL > 0 {
+		pipe.ExpireNX(ctx, key, s.sslTTL)
+		pipe.ExpireGT(ctx, key, s.sslTTL)
+	}
+	return nil
+}
+
+type sslObservationJSON struct {
+	ObservedAt  int64  `json:"observed_at"`
+	NotAfter    int64  `json:"not_after"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// queueExpiryIndex keeps the expiring-soon index in sync
+func (s *RedisStore) queueExpiryIndex(ctx context.Context, pipe redis.Pipeliner, endpoint string, expiration time.Time) {
+	pipe.ZAdd(ctx, s.keys.Key(SSLExpiryIndexKey), redis.Z{
+		Score:  float64(expiration.Unix()),
+		Member: endpoint,
+	})
+}
+
+func (s *RedisStore) PruneSSLExpiryIndex(ctx context.Context, keep []string) (int, error) {
+	members, err := s.client.ZRange(ctx, s.keys.Key(SSLExpiryIndexKey), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	monitored := make(map[string]bool, len(keep))
+	for _, endpoint := range keep {
+		monitored[endpoint] = true
+	}
+
+	var stale []interface{}
+	for _, member := range members {
+		if !monitored[member] {
+			stale = append(stale, member)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	if err := s.client.ZRem(ctx, s.keys.Key(SSLExpiryIndexKey), stale...).Err(); err != nil {
+		return 0, err
+	}
+	return len(stale), nil
+}
+
+// SyncEndpointRegistry makes the registry hold exactly the given endpoints
+// and returns how many were removed from it
+func (s *RedisStore) SyncEndpointRegistry(ctx context.Context, endpoints []string) (int, error) {
+	registered, err := s.client.SMembers(ctx, s.keys.Key(EndpointRegistryKey)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	monitored := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		monitored[endpoint] = true
+	}
+	var removed []interface{}
+	for _, endpoint := range registered {
+		if !monitored[endpoint] {
+			removed = append(removed, endpoint)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	if len(removed) > 0 {
+		pipe.SRem(ctx, s.keys.Key(EndpointRegistryKey), removed...)
+	}
+	if len(endpoints) > 0 {
+		members := make([]interface{}, len(endpoints))
+		for i, endpoint := range endpoints {
+			members[i] = endpoint
+		}
+		pipe.SAdd(ctx, s.keys.Key(EndpointRegistryKey), members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// AddEndpoint registers an endpoint for checking and reports whether it
+// was new to the registry
+func (s *RedisStore) AddEndpoint(ctx context.Context, endpoint string) (bool, error) {
+	added, err := s.client.SAdd(ctx, s.keys.Key(EndpointRegistryKey), endpoint).Result()
+	return added > 0, err
+}
+
+// RemoveEndpoint unregisters an endpoint and deletes its results: the
+// endpoint hash, its histories, keys of older versions, its SSL expiry
+// index entry, any acknowledgement and its alert state. It reports whether
+// the endpoint was registered.
+func (s *RedisStore) RemoveEndpoint(ctx context.Context, endpoint string) (bool, error) {
+	pipe := s.client.TxPipeline()
+	removed := pipe.SRem(ctx, s.keys.Key(EndpointRegistryKey), endpoint)
+	for _, prefix := range resultKeyPrefixes {
+		pipe.Unlink(ctx, s.keys.Key(prefix+endpoint))
+	}
+	pipe.ZRem(ctx, s.keys.Key(SSLExpiryIndexKey), endpoint)
+	pipe.Del(ctx, s.keys.Ack(endpoint))
+	pipe.ZRem(ctx, s.keys.Key(AckIndexKey), endpoint)
+	pipe.HDel(ctx, s.keys.Key(AlertStateKey), endpoint)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// SeedEndpointRegistry fills a missing registry from the existing endpoint
+// hashes and returns how many endpoints were added. An existing registry is
+// left alone.
+func (s *RedisStore) SeedEndpointRegistry(ctx context.Context) (int, error) {
+	exists, err := s.client.Exists(ctx, s.keys.Key(EndpointRegistryKey)).Result()
+	if err != nil || exists > 0 {
+		return 0, err
+	}
+
+	endpoints, err := s.scanEndpoints(ctx)
+	if err != nil || len(endpoints) == 0 {
+		return 0, err
+	}
+	members := make([]interface{}, len(endpoints))
+	for i, endpoint := range endpoints {
+		members[i] = endpoint
+	}
+	if err := s.client.SAdd(ctx, s.keys.Key(EndpointRegistryKey), members...).Err(); err != nil {
+		return 0, err
+	}
+	return len(endpoints), nil
+}
+
+func (s *RedisStore) ListEndpoints(ctx context.Context) ([]string, error) {
+	if s.scanDiscovery {
+		return s.scanEndpoints(ctx)
+	}
+	return s.client.SMembers(ctx, s.keys.Key(EndpointRegistryKey)).Result()
+}
+
+func (s *RedisStore) scanEndpoints(ctx context.Context) ([]string, error) {
+	var endpoints []string
+	iter := s.client.Scan(ctx, 0, s.keys.Pattern(EndpointKeyPrefix), 0).Iterator()
+	for iter.Next(ctx) {
+		endpoints = append(endpoints, s.keys.TrimPrefix(iter.Val(), EndpointKeyPrefix))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// ListEndpointData reads every endpoint in two round trips: the endpoint
+// list, then all hashes in one pipeline
+func (s *RedisStore) ListEndpointData(ctx context.Context) ([]EndpointData, error) {
+	endpoints, err := s.ListEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := s.endpointHashes(ctx, endpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]EndpointData, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		// Registered endpoints whose results expired have nothing to show
+		if len(hashes[i]) == 0 {
+			continue
+		}
+		result = append(result, parseEndpointData(endpoint, hashes[i]))
+	}
+	return result, nil
+}
+
+func (s *RedisStore) GetEndpointData(ctx context.Context, endpoint string) (EndpointData, error) {
+	fields, err := s.client.HGetAll(ctx, s.keys.Endpoint(endpoint)).Result()
+	if err != nil {
+		return EndpointData{Endpoint: endpoint}, err
+	}
+	return parseEndpointData(endpoint, fields), nil
+}
+
+// GetEndpointsData reads all the endpoint hashes in one pipelined round trip
+func (s *RedisStore) GetEndpointsData(ctx context.Context, endpoints []string) ([]EndpointData, error) {
+	hashes, err := s.endpointHashes(ctx, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]EndpointData, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		result = append(result, parseEndpointData(endpoint, hashes[i]))
+	}
+	return result, nil
+}
+
+// endpointHashes pipelines an HGETALL per endpoint and returns the hashes in
+// the same order, empty for endpoints without one
+func (s *RedisStore) endpointHashes(ctx context.Context, endpoints []string) ([]map[string]string, error) {
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(endpoints))
+	for i, endpoint := range endpoints {
+		cmds[i] = pipe.HGetAll(ctx, s.keys.Endpoint(endpoint))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	hashes := make([]map[string]string, len(cmds))
+	for i, cmd := range cmds {
+		hashes[i] = cmd.Val()
+	}
+	return hashes, nil
+}
+
+// parseEndpointData converts an endpoint hash into EndpointData. Malformed
+// fields are treated as missing.
+func parseEndpointData(endpoint string, fields map[string]string) EndpointData {
+	data := EndpointData{
+		Endpoint:      endpoint,
+		StatusUpdated: parseUnix(fields["status_updated"]),
+	}
+	if code, err := strconv.Atoi(fields["status"]); err == nil {
+		data.StatusCode = code
+		data.HasStatus = true
+	}
+
+	// Header audit is only present when auditing is enabled in the checker
+	if _, ok := fields["headers_updated"]; ok {
+		data.HeaderAudit = parseHeaderAudit(fields)
+	}
+	data.Captured = parseCapturedHeaders(fields["captured_headers"])
+	if class, ok := fields["error_class"]; ok {
+		data.Error = &CheckError{Class: class, Message: fields["error_message"], At: parseUnix(fields["error_at"]), Since: parseUnix(fields["error_since"])}
+		data.Error.Count, _ = strconv.Atoi(fields["error_count"])
+	}
+	if tags := fields["tags"]; tags != "" {
+		data.Tags = strings.Split(tags, ",")
+	}
+	data.Name = fields["name"]
+	data.Description = fields["description"]
+	data.RemoteAddr = fields["remote_addr"]
+	data.CheckedBy = fields["checked_by"]
+	data.SSLCheckedBy = fields["ssl_checked_by"]
+	data.InMaintenance = fields["in_maintenance"] == "1"
+	data.PausedBySchedule = fields["paused_by_schedule"] == "1"
+	for _, window := range UptimeWindows {
+		if u, ok := parseUptime(window.Name, fields[uptimeField(window.Name)]); ok {
+			data.Uptime = append(data.Uptime, u)
+		}
+	}
+
+	// SSL data only exists for HTTPS endpoints
+	if !strings.HasPrefix(endpoint, "https://") {
+		return data
+	}
+
+	data.SSLExpiration = parseUnix(fields["ssl_expiry"])
+	data.SSLUpdated = parseUnix(fields["ssl_updated"])
+	data.CertLevel = fields["cert_level"]
+	if _, ok := fields["cert_not_before"]; ok {
+		data.CertInfo = parseCertInfo(fields)
+	}
+	return data
+}
+
+func (s *RedisStore) ExpiringBefore(ctx context.Context, t time.Time) ([]Expiry, error) {
+	results, err := s.client.ZRangeByScoreWithScores(ctx, s.keys.Key(SSLExpiryIndexKey), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(t.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := make([]Expiry, 0, len(results))
+	for _, z := range results {
+		endpoint, ok := z.Member.(string)
+		if !ok {
+			continue
+		}
+		expiring = append(expiring, Expiry{
+			Endpoint:      endpoint,
+			SSLExpiration: time.Unix(int64(z.Score), 0).UTC(),
+		})
+	}
+	return expiring, nil
+}
+
+func (s *RedisStore) StatusHistory(ctx context.Context, endpoint string, since time.Time) ([]HistoryEntry, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.keys.StatusHistory(endpoint), &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]HistoryEntry, 0, len(members))
+	for _, member := range members {
+		if entry, ok := parseHistoryMember(member); ok {
+			history = append(history, entry)
+		}
+	}
+	return history, nil
+}
+
+func (s *RedisStore) SSLHistory(ctx context.Context, endpoint string) ([]SSLObservation, error) {
+	entries, err := s.client.LRange(ctx, s.keys.SSLHistory(endpoint), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]SSLObservation, 0, len(entries))
+	for _, entry := range entries {
+		var observation sslObservationJSON
+		if err := json.Unmarshal([]byte(entry), &observation); err != nil {
+			continue
+		}
+		history = append(history, SSLObservation{
+			ObservedAt:  time.Unix(observation.ObservedAt, 0).UTC(),
+			NotAfter:    time.Unix(observation.NotAfter, 0).UTC(),
+			Fingerprint: observation.Fingerprint,
+		})
+	}
+	return history, nil
+}
+
+type latencyRollupJSON struct {
+	Hour   int64 `json:"hour"`
+	Count  int   `json:"count"`
+	Min    int64 `json:"min_ms"`
+	Avg    int64 `json:"avg_ms"`
+	P95    int64 `json:"p95_ms"`
+	Max    int64 `json:"max_ms"`
+	Checks int   `json:"checks"`
+	Up     int   `json:"up"`
+
+	Maintenance   int `json:"maintenance,omitempty"`
+	MaintenanceUp int `json:"maintenance_up,omitempty"`
+}
+
+func (s *RedisStore) SaveLatencyRollups(ctx context.Context, endpoint string, rollups []LatencyRollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	key := s.keys.LatencyRollups(endpoint)
+	pipe := s.client.TxPipeline()
+	latest := rollups[0].Hour
+	for _, rollup := range rollups {
+		member, err := json.Marshal(latencyRollupJSON{
+			Hour:   rollup.Hour.Unix(),
+			Count:  rollup.Count,
+			Min:    rollup.Min.Milliseconds(),
+			Avg:    rollup.Avg.Milliseconds(),
+			P95:    rollup.P95.Milliseconds(),
+			Max:    rollup.Max.Milliseconds(),
+			Checks: rollup.Checks,
+			Up:     rollup.Up,
+
+			Maintenance:   rollup.Maintenance,
+			MaintenanceUp: rollup.MaintenanceUp,
+		})
+		if err != nil {
+			return err
+		}
+		hour := strconv.FormatInt(rollup.Hour.Unix(), 10)
+		pipe.ZRemRangeByScore(ctx, key, hour, hour)
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(rollup.Hour.Unix()), Member: member})
+		if rollup.Hour.After(latest) {
+			latest = rollup.Hour
+		}
+	}
+	cutoff := latest.Add(-LatencyRollupRetention).Unix()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", fmt.Sprintf("(%d", cutoff))
+	pipe.Expire(ctx, key, LatencyRollupRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) LatencyRollups(ctx context.Context, endpoint string, since time.Time) ([]LatencyRollup, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.keys.LatencyRollups(endpoint), &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := make([]LatencyRollup, 0, len(members))
+	for _, member := range members {
+		var r latencyRollupJSON
+		if err := json.Unmarshal([]byte(member), &r); err != nil {
+			continue
+		}
+		rollups = append(rollups, LatencyRollup{
+			Hour:   time.Unix(r.Hour, 0).UTC(),
+			Count:  r.Count,
+			Min:    time.Duration(r.Min) * time.Millisecond,
+			Avg:    time.Duration(r.Avg) * time.Millisecond,
+			P95:    time.Duration(r.P95) * time.Millisecond,
+			Max:    time.Duration(r.Max) * time.Millisecond,
+			Checks: r.Checks,
+			Up:     r.Up,
+
+			Maintenance:   r.Maintenance,
+			MaintenanceUp: r.MaintenanceUp,
+		})
+	}
+	return rollups, nil
+}
+
+// setIfExistsScript sets the hash fields ARGV on KEYS[1] only when the hash
+// exists, so it keeps its TTL and an expired endpoint is not brought back
+var setIfExistsScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+return redis.call('HSET', KEYS[1], unpack(ARGV))
+`
+
+// SaveUptime stores the uptime of each window in the endpoint hash as
+// uptime_<window> fields holding "<up>/<checks>". Endpoints without a
+// stored hash are skipped.
+func (s *RedisStore) SaveUptime(ctx context.Context, endpoint string, uptime []Uptime) error {
+	if len(uptime) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, 2*len(uptime))
+	for _, u := range uptime {
+		args = append(args, uptimeField(u.Window), formatUptime(u))
+	}
+	return s.client.Eval(ctx, setIfExistsScript, []string{s.keys.Endpoint(endpoint)}, args...).Err()
+}
+
+func parseHistoryMember(member string) (HistoryEntry, bool) {
+	parts := strings.Split(member, "|")
+	if len(parts) != 3 {
+		return HistoryEntry{}, false
+	}
+	code, err1 := strconv.Atoi(parts[0])
+	latency, err2 := strconv.ParseInt(parts[1], 10, 64)
+	checkedAt, err3 := strconv.ParseInt(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return HistoryEntry{}, false
+	}
+	return HistoryEntry{
+		CheckedAt:  time.UnixMilli(checkedAt).UTC(),
+		StatusCode: code,
+		Latency:    time.Duration(latency) * time.Millisecond,
+	}, true
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// PoolStats is a snapshot of the Redis connection pool. The first three
+// counters are cumulative since the store was created.
+type PoolStats struct {
+	Hits       uint32 `json:"hits"`     // calls served by an idle connection
+	Misses     uint32 `json:"misses"`   // calls that had to dial
+	Timeouts   uint32 `json:"timeouts"` // calls that gave up waiting for a free connection
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+	PoolSize   int    `json:"pool_size"`
+}
+
+func (s *RedisStore) PoolStats() PoolStats {
+	stats := s.client.PoolStats()
+	return PoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+		PoolSize:   s.client.Options().PoolSize,
+	}
+}
+
+// WatchPoolTimeouts logs a warning after every interval in which calls
+// timed out waiting for a free pooled connection, until ctx is done
+func (s *RedisStore) WatchPoolTimeouts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := s.client.PoolStats().Timeouts
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stats := s.PoolStats()
+		if stats.Timeouts > last {
+			log.Printf("[WARN] %d Redis calls timed out waiting for a pooled connection in the last %s (%d of %d connections open, %d idle); consider raising REDIS_POOL_SIZE or REDIS_POOL_TIMEOUT",
+				stats.Timeouts-last, interval, stats.TotalConns, stats.PoolSize, stats.IdleConns)
+		}
+		last = stats.Timeouts
+	}
+}
+
+func parseCertInfo(fields map[string]string) *CertInfo {
+	return &CertInfo{
+		NotBefore:    parseUnix(fields["cert_not_before"]),
+		NotAfter:     parseUnix(fields["ssl_expiry"]),
+		Subject:      fields["cert_subject"],
+		Issuer:       fields["cert_issuer"],
+		SerialNumber: fields["cert_serial"],
+		Fingerprint:  fields["cert_fingerprint"],
+		State:        fields["cert_state"],
+		RemoteAddr:   fields["cert_remote_addr"],
+	}
+}
+
+func parseHeaderAudit(fields map[string]string) *HeaderAudit {
+	audit := &HeaderAudit{
+		Headers: make(map[string]string),
+		Updated: parseUnix(fields["headers_updated"]),
+	}
+	if failures := fields["headers_failures"]; failures != "" {
+		audit.Failures = strings.Split(failures, "; ")
+	}
+	// A malformed header map leaves Headers empty; the verdict is still usable
+	_ = json.Unmarshal([]byte(fields["headers"]), &audit.Headers)
+	return audit
+}
+
+// parseUnix converts a stored Unix timestamp, returning the zero time for malformed values
+func parseUnix(value string) time.Time {
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(timestamp, 0).UTC()
+}
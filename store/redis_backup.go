@@ -0,0 +1,414 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BackupFormat identifies backup documents written by Export, and
+// BackupVersion their layout; it is bumped whenever the layout changes
+const (
+	BackupFormat  = "certs-n-status-backup"
+	BackupVersion = 1
+)
+
+// backupChunkSize caps how many members of a key are read, held in memory
+// and written at once; larger keys are exported as several records. Import
+// flushes its pipeline every backupChunkSize records.
+const backupChunkSize = 1000
+
+// Keys exported by Export: the endpoint state always, the histories and the
+// event stream only when asked for
+var (
+	backupStateKeys       = []string{SchemaVersionKey, EndpointRegistryKey, SSLExpiryIndexKey}
+	backupStatePrefixes   = []string{EndpointKeyPrefix}
+	backupHistoryKeys     = []string{EventStreamKey}
+	backupHistoryPrefixes = []string{StatusHistoryKeyPrefix, SSLHistoryKeyPrefix, LatencyRollupKeyPrefix, LatencyHistogramKeyPrefix}
+)
+
+// backupHeader holds the fields of a backup document that precede its keys
+type backupHeader struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	KeyPrefix     string    `json:"key_prefix"`
+	History       bool      `json:"history"`
+}
+
+// backupRecord is one key, or one chunk of a large key. Key leaves out the
+// key prefix so a backup can be imported under another one, and TTL is the
+// time to live left at export in milliseconds, 0 for none.
+type backupRecord struct {
+	Key     string            `json:"key"`
+	Type    string            `json:"type"`
+	TTL     int64             `json:"ttl_ms,omitempty"`
+	Value   string            `json:"value,omitempty"`   // string
+	Fields  map[string]string `json:"fields,omitempty"`  // hash
+	Members []string          `json:"members,omitempty"` // set, or list in order
+	Scored  []backupScored    `json:"scored,omitempty"`  // sorted set
+	Entries []backupEntry     `json:"entries,omitempty"` // stream
+}
+
+type backupScored struct {
+	Score  float64 `json:"score"`
+	Member string  `json:"member"`
+}
+
+type backupEntry struct {
+	ID     string   `json:"id"`
+	Values []string `json:"values"` // field, value, field, value...
+}
+
+// parseStreamEntry converts one raw XRANGE entry, [id, [field, value...]]
+func parseStreamEntry(message interface{}) (backupEntry, bool) {
+	parts, ok := message.([]interface{})
+	if !ok || len(parts) != 2 {
+		return backupEntry{}, false
+	}
+	id, ok := parts[0].(string)
+	pairs, ok2 := parts[1].([]interface{})
+	if !ok || !ok2 {
+		return backupEntry{}, false
+	}
+	entry := backupEntry{ID: id, Values: make([]string, 0, len(pairs))}
+	for _, value := range pairs {
+		entry.Values = append(entry.Values, fmt.Sprint(value))
+	}
+	return entry, true
+}
+
+// Export writes the endpoint state under this store's key prefix to w as a
+// JSON backup document, including the status, certificate and latency
+// histories and the event stream when history is set. Keys are read and
+// written a chunk at a time, so memory use does not grow with the size of
+// the histories. It returns the number of keys exported.
+func (s *RedisStore) Export(ctx context.Context, w io.Writer, history bool) (int, error) {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	header, err := json.Marshal(backupHeader{
+		Format:        BackupFormat,
+		Version:       BackupVersion,
+		SchemaVersion: version,
+		ExportedAt:    time.Now().UTC(),
+		KeyPrefix:     s.keys.Prefix,
+		History:       history,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The header object is left open so the keys array can be streamed into it
+	out := bufio.NewWriter(w)
+	out.Write(header[:len(header)-1])
+	out.WriteString(`,"keys":[`)
+	records := 0
+	emit := func(record backupRecord) error {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if records > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteByte('\n')
+		records++
+		_, err = out.Write(line)
+		return err
+	}
+
+	keys, prefixes := backupStateKeys, backupStatePrefixes
+	if history {
+		keys = append(append([]string(nil), keys...), backupHistoryKeys...)
+		prefixes = append(append([]string(nil), prefixes...), backupHistoryPrefixes...)
+	}
+	exported := 0
+	for _, name := range keys {
+		found, err := s.exportKey(ctx, s.keys.Key(name), emit)
+		if err != nil {
+			return exported, err
+		}
+		if found {
+			exported++
+		}
+	}
+	for _, prefix := range prefixes {
+		iter := s.client.Scan(ctx, 0, s.keys.Pattern(prefix), 0).Iterator()
+		for iter.Next(ctx) {
+			found, err := s.exportKey(ctx, iter.Val(), emit)
+			if err != nil {
+				return exported, err
+			}
+			if found {
+				exported++
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return exported, err
+		}
+	}
+
+	out.WriteString("\n]}\n")
+	return exported, out.Flush()
+}
+
+// exportKey passes key to emit as one record per backupChunkSize members,
+// reporting false when the key does not exist
+func (s *RedisStore) exportKey(ctx context.Context, key string, emit func(backupRecord) error) (bool, error) {
+	kind, err := s.client.Type(ctx, key).Result()
+	if err != nil || kind == "none" {
+		return false, err
+	}
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	record := backupRecord{Key: strings.TrimPrefix(key, s.keys.Prefix), Type: kind}
+	if ttl > 0 {
+		record.TTL = ttl.Milliseconds()
+	}
+
+	switch kind {
+	case "string":
+		if record.Value, err = s.client.Get(ctx, key).Result(); err != nil && err != redis.Nil {
+			return false, err
+		}
+		return true, emit(record)
+	case "hash":
+		if record.Fields, err = s.client.HGetAll(ctx, key).Result(); err != nil {
+			return false, err
+		}
+		return true, emit(record)
+	case "set":
+		iter := s.client.SScan(ctx, key, 0, "", backupChunkSize).Iterator()
+		for iter.Next(ctx) {
+			record.Members = append(record.Members, iter.Val())
+			if len(record.Members) == backupChunkSize {
+				if err := emit(record); err != nil {
+					return false, err
+				}
+				record.Members = nil
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return false, err
+		}
+		if len(record.Members) == 0 {
+			return true, nil
+		}
+		return true, emit(record)
+	case "list":
+		for start := int64(0); ; start += backupChunkSize {
+			if record.Members, err = s.client.LRange(ctx, key, start, start+backupChunkSize-1).Result(); err != nil {
+				return false, err
+			}
+			if len(record.Members) > 0 {
+				if err := emit(record); err != nil {
+					return false, err
+				}
+			}
+			if len(record.Members) < backupChunkSize {
+				return true, nil
+			}
+		}
+	case "zset":
+		for start := int64(0); ; start += backupChunkSize {
+			members, err := s.client.ZRangeWithScores(ctx, key, start, start+backupChunkSize-1).Result()
+			if err != nil {
+				return false, err
+			}
+			record.Scored = make([]backupScored, 0, len(members))
+			for _, z := range members {
+				record.Scored = append(record.Scored, backupScored{Score: z.Score, Member: fmt.Sprint(z.Member)})
+			}
+			if len(record.Scored) > 0 {
+				if err := emit(record); err != nil {
+					return false, err
+				}
+			}
+			if len(members) < backupChunkSize {
+				return true, nil
+			}
+		}
+	case "stream":
+		for start := "-"; ; {
+			// XRANGE is sent raw because XRangeN returns fields as a map,
+			// losing their order
+			messages, err := s.client.Do(ctx, "XRANGE", key, start, "+", "COUNT", backupChunkSize).Slice()
+			if err != nil {
+				return false, err
+			}
+			record.Entries = make([]backupEntry, 0, len(messages))
+			for _, message := range messages {
+				entry, ok := parseStreamEntry(message)
+				if !ok {
+					return false, fmt.Errorf("cannot export %s: unexpected XRANGE reply %v", key, message)
+				}
+				record.Entries = append(record.Entries, entry)
+			}
+			if len(record.Entries) > 0 {
+				if err := emit(record); err != nil {
+					return false, err
+				}
+			}
+			if len(messages) < backupChunkSize {
+				return true, nil
+			}
+			start = "(" + record.Entries[len(record.Entries)-1].ID
+		}
+	}
+	return false, fmt.Errorf("cannot export %s: unsupported type %s", key, kind)
+}
+
+// Import writes the keys of a backup document written by Export under this
+// store's key prefix, replacing existing keys of the same name, and returns
+// the number of keys imported. The document is read a record at a time.
+// Backups of a newer schema version than this build supports are refused.
+func (s *RedisStore) Import(ctx context.Context, r io.Reader) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	if err := expectDelim(dec, '{'); err != nil {
+		return 0, err
+	}
+
+	// The header fields come first; they are checked once the keys start
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return 0, fmt.Errorf("invalid backup document: %w", err)
+		}
+		name, _ := token.(string)
+		if name != "keys" {
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return 0, fmt.Errorf("invalid backup document: %w", err)
+			}
+			fields[name] = value
+			continue
+		}
+
+		if err := checkBackupHeader(fields); err != nil {
+			return 0, err
+		}
+		return s.importKeys(ctx, dec)
+	}
+	return 0, fmt.Errorf("invalid backup document: no keys")
+}
+
+func checkBackupHeader(fields map[string]json.RawMessage) error {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var header backupHeader
+	if err := json.Unmarshal(encoded, &header); err != nil {
+		return fmt.Errorf("invalid backup header: %w", err)
+	}
+	if header.Format != BackupFormat {
+		return fmt.Errorf("not a %s document", BackupFormat)
+	}
+	if header.Version != BackupVersion {
+		return fmt.Errorf("unsupported backup version %d, want %d", header.Version, BackupVersion)
+	}
+	if header.SchemaVersion > CurrentSchemaVersion {
+		return fmt.Errorf("backup schema version %d is newer than the supported version %d, upgrade this binary", header.SchemaVersion, CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// importKeys reads the keys array, deleting each key before its first record
+// is written
+func (s *RedisStore) importKeys(ctx context.Context, dec *json.Decoder) (int, error) {
+	if err := expectDelim(dec, '['); err != nil {
+		return 0, err
+	}
+
+	imported := make(map[string]bool)
+	pipe := s.client.Pipeline()
+	for queued := 1; dec.More(); queued++ {
+		var record backupRecord
+		if err := dec.Decode(&record); err != nil {
+			return len(imported), fmt.Errorf("invalid backup record: %w", err)
+		}
+		key := s.keys.Key(record.Key)
+		if !imported[key] {
+			imported[key] = true
+			pipe.Unlink(ctx, key)
+		}
+		if err := queueBackupRecord(ctx, pipe, key, record); err != nil {
+			return len(imported), err
+		}
+		if record.TTL > 0 {
+			pipe.PExpire(ctx, key, time.Duration(record.TTL)*time.Millisecond)
+		}
+		if queued%backupChunkSize == 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return len(imported), err
+			}
+		}
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return len(imported), err
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return len(imported), err
+	}
+	return len(imported), nil
+}
+
+func queueBackupRecord(ctx context.Context, pipe redis.Pipeliner, key string, record backupRecord) error {
+	switch record.Type {
+	case "string":
+		pipe.Set(ctx, key, record.Value, 0)
+	case "hash":
+		if len(record.Fields) > 0 {
+			pipe.HSet(ctx, key, record.Fields)
+		}
+	case "set":
+		if len(record.Members) > 0 {
+			pipe.SAdd(ctx, key, record.Members)
+		}
+	case "list":
+		if len(record.Members) > 0 {
+			pipe.RPush(ctx, key, record.Members)
+		}
+	case "zset":
+		members := make([]redis.Z, 0, len(record.Scored))
+		for _, z := range record.Scored {
+			members = append(members, redis.Z{Score: z.Score, Member: z.Member})
+		}
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, key, members...)
+		}
+	case "stream":
+		for _, entry := range record.Entries {
+			if len(entry.Values) == 0 || len(entry.Values)%2 != 0 {
+				return fmt.Errorf("cannot import %s: stream entry %s has %d field values", record.Key, entry.ID, len(entry.Values))
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{Stream: key, ID: entry.ID, Values: entry.Values})
+		}
+	default:
+		return fmt.Errorf("cannot import %s: unsupported type %q", record.Key, record.Type)
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid backup document: %w", err)
+	}
+	if token != want {
+		return fmt.Errorf("invalid backup document: got %v, want %v", token, want)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExportImport tests that a backup restores the exported keys exactly
+func TestExportImport(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	s.SetKeyPrefix("prod:")
+	s.SetHistoryRetention(HistoryRetention{})
+	s.SetResultTTL(10*time.Minute, 10*time.Hour)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0).UTC()
+
+	// Enough history to span several export chunks
+	var results []Result
+	for i := range 2*backupChunkSize + 5 {
+		results = append(results, Result{Endpoint: "https://a.example.com", CheckedAt: checkedAt.Add(time.Duration(i) * time.Minute), HasStatus: true, StatusCode: 200, Latency: time.Duration(i%50) * time.Millisecond})
+	}
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), Subject: "CN=b.example.com", State: CertStateValid}
+	results = append(results,
+		Result{Endpoint: "https://b.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503, Cert: &cert,
+			HeaderAudit: &HeaderAudit{Headers: map[string]string{"X-Frame-Options": "DENY"}, Failures: []string{"missing HSTS"}, Updated: checkedAt}},
+	)
+	if err := s.SaveResults(ctx, results); err != nil {
+		t.Fatal(err)
+	}
+	s.SaveLatencyRollups(ctx, "https://a.example.com", []LatencyRollup{{Hour: checkedAt.Truncate(time.Hour), Count: 60, Min: time.Millisecond, Max: 49 * time.Millisecond}})
+	s.PublishEvents(ctx, []Event{{Endpoint: "https://b.example.com", Kind: EventKindStatus, Old: StatusUp, New: StatusDown, At: checkedAt}})
+	if _, err := s.Migrate(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	mr.Set("other:endpoint:https://c.example.com", "not ours")
+
+	var backup bytes.Buffer
+	exported, err := s.Export(ctx, &backup, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(backup.Bytes()) {
+		t.Fatalf("export is not valid JSON:\n%s", backup.String())
+	}
+	// schema_version, registry, expiry index, events and per endpoint a hash,
+	// status history and latency histograms, plus SSL history for b and
+	// latency rollups for a
+	if exported != 12 {
+		t.Errorf("exported %d keys, want 12", exported)
+	}
+	if strings.Contains(backup.String(), "other:") {
+		t.Error("export includes keys outside the key prefix")
+	}
+
+	snapshot := func() string {
+		var b strings.Builder
+		for _, key := range mr.Keys() {
+			fmt.Fprintf(&b, "%s ttl=%s\n", key, mr.TTL(key))
+		}
+		return b.String() + mr.Dump()
+	}
+	before := snapshot()
+	mr.FlushAll()
+	mr.Set("prod:endpoint:https://a.example.com", "stale value replaced by the import")
+	mr.Set("other:endpoint:https://c.example.com", "not ours")
+
+	imported, err := s.Import(ctx, bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != exported {
+		t.Errorf("imported %d keys, want %d", imported, exported)
+	}
+	if after := snapshot(); after != before {
+		t.Errorf("round trip changed the data\nbefore:\n%s\nafter:\n%s", before, after)
+	}
+
+	// Without history only the endpoint state is exported
+	backup.Reset()
+	if _, err := s.Export(ctx, &backup, false); err != nil {
+		t.Fatal(err)
+	}
+	staging, _ := newTestRedisStore(t)
+	staging.SetKeyPrefix("staging:")
+	if _, err := staging.Import(ctx, &backup); err != nil {
+		t.Fatal(err)
+	}
+	data, err := staging.GetEndpointData(ctx, "https://b.example.com")
+	if err != nil || data.StatusCode != 503 || data.CertInfo == nil || data.CertInfo.Subject != cert.Subject || data.HeaderAudit == nil {
+		t.Errorf("imported under another prefix: %+v, %v", data, err)
+	}
+	if history, _ := staging.StatusHistory(ctx, "https://a.example.com", time.Time{}); len(history) != 0 {
+		t.Errorf("backup without history restored %d history entries", len(history))
+	}
+	if events, _ := staging.Events(ctx, "", "", 10); len(events) != 0 {
+		t.Errorf("backup without history restored %d events", len(events))
+	}
+}
+
+// TestImportInvalid tests that malformed or incompatible backups are refused
+func TestImportInvalid(t *testing.T) {
+	header := func(format string, version, schemaVersion int) string {
+		return fmt.Sprintf(`{"format":%q,"version":%d,"schema_version":%d,"keys":[`, format, version, schemaVersion)
+	}
+	current := header(BackupFormat, BackupVersion, CurrentSchemaVersion)
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{"not json", "endpoint:https://example.com", "invalid backup document"},
+		{"no keys", `{"format":"certs-n-status-backup","version":1}`, "no keys"},
+		{"other format", header("something-else", BackupVersion, 0) + `]}`, "not a certs-n-status-backup document"},
+		{"newer version", header(BackupFormat, BackupVersion+1, 0) + `]}`, "unsupported backup version"},
+		{"newer schema", header(BackupFormat, BackupVersion, CurrentSchemaVersion+1) + `]}`, "newer than the supported version"},
+		{"unknown type", current + `{"key":"x","type":"hyperloglog"}]}`, "unsupported type"},
+		{"truncated", current + `{"key":"endpoint:https://example.com","type":"hash","fields":{"status":"200"}}`, "unexpected end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestRedisStore(t)
+			_, err := s.Import(context.Background(), strings.NewReader(tt.document))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Import() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package store
+
+import "context"
+
+// resultKeyPrefixes lists every per-endpoint key prefix the project writes,
+// current and legacy, so orphaned data of any vintage can be found
+var resultKeyPrefixes = append([]string{EndpointKeyPrefix, StatusHistoryKeyPrefix, SSLHistoryKeyPrefix, LatencyRollupKeyPrefix, LatencyHistogramKeyPrefix}, legacyPrefixes...)
+
+// Cleanup removes the data of endpoints not listed in keep: their result
+// keys, their members of the endpoint registry and SSL expiry index and
+// their alert states. It
+// returns what was removed, one "<key>" or "<set> <endpoint>" entry each.
+// With dryRun it only reports what would be removed.
+func (s *RedisStore) Cleanup(ctx context.Context, keep []string, dryRun bool) ([]string, error) {
+	monitored := make(map[string]bool, len(keep))
+	for _, endpoint := range keep {
+		monitored[endpoint] = true
+	}
+
+	var keys []string
+	for _, prefix := range resultKeyPrefixes {
+		iter := s.client.Scan(ctx, 0, s.keys.Pattern(prefix), 0).Iterator()
+		for iter.Next(ctx) {
+			if !monitored[s.keys.TrimPrefix(iter.Val(), prefix)] {
+				keys = append(keys, iter.Val())
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	registryKey, indexKey := s.keys.Key(EndpointRegistryKey), s.keys.Key(SSLExpiryIndexKey)
+	registered, err := s.client.SMembers(ctx, registryKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	indexed, err := s.client.ZRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	alertKey := s.keys.Key(AlertStateKey)
+	alerted, err := s.client.HKeys(ctx, alertKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	staleRegistered := unmonitored(registered, monitored)
+	staleIndexed := unmonitored(indexed, monitored)
+	staleAlerted := unmonitored(alerted, monitored)
+
+	removed := append([]string(nil), keys...)
+	for _, endpoint := range staleRegistered {
+		removed = append(removed, registryKey+" "+endpoint.(string))
+	}
+	for _, endpoint := range staleIndexed {
+		removed = append(removed, indexKey+" "+endpoint.(string))
+	}
+	for _, endpoint := range staleAlerted {
+		removed = append(removed, alertKey+" "+endpoint.(string))
+	}
+	if dryRun || len(removed) == 0 {
+		return removed, nil
+	}
+
+	pipe := s.client.Pipeline()
+	for _, key := range keys {
+		pipe.Unlink(ctx, key)
+	}
+	if len(staleRegistered) > 0 {
+		pipe.SRem(ctx, registryKey, staleRegistered...)
+	}
+	if len(staleIndexed) > 0 {
+		pipe.ZRem(ctx, indexKey, staleIndexed...)
+	}
+	if len(staleAlerted) > 0 {
+		fields := make([]string, len(staleAlerted))
+		for i, endpoint := range staleAlerted {
+			fields[i] = endpoint.(string)
+		}
+		pipe.HDel(ctx, alertKey, fields...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func unmonitored(members []string, monitored map[string]bool) []interface{} {
+	var stale []interface{}
+	for _, member := range members {
+		if !monitored[member] {
+			stale = append(stale, member)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// legacyPrefixes are the per-value string and hash keys written before
+// results moved into a single endpoint:<url> hash
+var legacyPrefixes = []string{"status:", "status_updated:", "ssl:", "ssl_updated:", "cert_info:", "headers:"}
+
+// migrateLegacyKeys moves results stored under the old one-key-per-value
+// layout into endpoint hashes and deletes the old keys. Endpoints that
+// already have a hash keep it. It is safe to run repeatedly and returns the
+// number of endpoints migrated, or with dryRun that would be.
+func (s *RedisStore) migrateLegacyKeys(ctx context.Context, dryRun bool) (int, error) {
+	endpoints := make(map[string]bool)
+	for _, prefix := range []string{"status:", "ssl:"} {
+		iter := s.client.Scan(ctx, 0, s.keys.Pattern(prefix), 0).Iterator()
+		for iter.Next(ctx) {
+			endpoints[s.keys.TrimPrefix(iter.Val(), prefix)] = true
+		}
+		if err := iter.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	migrated := 0
+	for endpoint := range endpoints {
+		exists, err := s.client.Exists(ctx, s.keys.Endpoint(endpoint)).Result()
+		if err != nil {
+			return migrated, err
+		}
+
+		if dryRun {
+			if exists == 0 {
+				migrated++
+			}
+			continue
+		}
+
+		pipe := s.client.TxPipeline()
+		if exists == 0 {
+			fields, err := s.legacyFields(ctx, endpoint)
+			if err != nil {
+				return migrated, err
+			}
+			if len(fields) > 0 {
+				pipe.HSet(ctx, s.keys.Endpoint(endpoint), fields...)
+				pipe.SAdd(ctx, s.keys.Key(EndpointRegistryKey), endpoint)
+			}
+		}
+		for _, prefix := range legacyPrefixes {
+			pipe.Del(ctx, s.keys.Key(prefix+endpoint))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return migrated, err
+		}
+		if exists == 0 {
+			migrated++
+		}
+	}
+	return migrated, nil
+}
+
+// legacyFields reads an endpoint's old keys and returns them as endpoint hash fields
+func (s *RedisStore) legacyFields(ctx context.Context, endpoint string) ([]interface{}, error) {
+	var fields []interface{}
+
+	for field, key := range map[string]string{
+		"status":         s.keys.Key("status:" + endpoint),
+		"status_updated": s.keys.Key("status_updated:" + endpoint),
+		"ssl_expiry":     s.keys.Key("ssl:" + endpoint),
+		"ssl_updated":    s.keys.Key("ssl_updated:" + endpoint),
+	} {
+		value, err := s.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field, value)
+	}
+
+	cert, err := s.client.HGetAll(ctx, s.keys.Key("cert_info:"+endpoint)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(cert) > 0 {
+		fields = append(fields,
+			"cert_not_before", cert["not_before"],
+			"cert_subject", cert["subject"],
+			"cert_issuer", cert["issuer"],
+			"cert_serial", cert["serial"],
+			"cert_fingerprint", cert["fingerprint"],
+			"cert_state", cert["state"],
+		)
+	}
+
+	headers, err := s.client.HGetAll(ctx, s.keys.Key("headers:"+endpoint)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) > 0 {
+		audit := HeaderAudit{
+			Headers: make(map[string]string),
+			Updated: parseUnix(headers["updated"]),
+		}
+		if failures := headers["failures"]; failures != "" {
+			audit.Failures = strings.Split(failures, "; ")
+		}
+		for field, value := range headers {
+			if name, ok := strings.CutPrefix(field, "header:"); ok {
+				audit.Headers[name] = value
+			}
+		}
+		auditFields, err := headerAuditFields(audit)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, auditFields...)
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SchemaVersionKey holds the version of the Redis key layout, i.e. the
+// number of migrations applied. Data written before it existed has none and
+// counts as version 0.
+const SchemaVersionKey = "schema_version"
+
+// migration upgrades the Redis layout by one schema version. It must be
+// idempotent, and with dryRun only count what it would change.
+type migration struct {
+	description string
+	apply       func(s *RedisStore, ctx context.Context, dryRun bool) (int, error)
+}
+
+// migrations are applied in order; schema version n means the first n have run
+var migrations = []migration{
+	{"move legacy per-value keys into endpoint hashes", (*RedisStore).migrateLegacyKeys},
+	{"register every endpoint hash in the endpoint registry", (*RedisStore).registerEndpointHashes},
+}
+
+// CurrentSchemaVersion is the Redis layout this build reads and writes,
+// len(migrations)
+const CurrentSchemaVersion = 2
+
+// MigrationStep reports one applied, or with a dry run pending, migration
+type MigrationStep struct {
+	Version     int // schema version after the step
+	Description string
+	Changed     int // endpoints changed, or that would be
+}
+
+// SchemaVersion returns the stored schema version, 0 when there is none
+func (s *RedisStore) SchemaVersion(ctx context.Context) (int, error) {
+	value, err := s.client.Get(ctx, s.keys.Key(SchemaVersionKey)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid %s %q", s.keys.Key(SchemaVersionKey), value)
+	}
+	return version, nil
+}
+
+// CheckSchemaVersion fails when the data was written by a newer release
+// whose layout this build does not understand. Older data is accepted; the
+// checker migrates it.
+func (s *RedisStore) CheckSchemaVersion(ctx context.Context) error {
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("redis schema version %d is newer than the supported version %d, upgrade this binary", version, CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// Migrate applies the migrations the stored data is missing, recording the
+// schema version after each one, and returns the steps taken. With dryRun
+// nothing is written and the steps report what would change. Running it
+// again once the data is current does nothing.
+func (s *RedisStore) Migrate(ctx context.Context, dryRun bool) ([]MigrationStep, error) {
+	if err := s.CheckSchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	version, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []MigrationStep
+	for i := version; i < len(migrations); i++ {
+		step := MigrationStep{Version: i + 1, Description: migrations[i].description}
+		step.Changed, err = migrations[i].apply(s, ctx, dryRun)
+		if err != nil {
+			return steps, fmt.Errorf("migration to schema version %d (%s): %w", step.Version, step.Description, err)
+		}
+		if !dryRun {
+			if err := s.client.Set(ctx, s.keys.Key(SchemaVersionKey), step.Version, 0).Err(); err != nil {
+				return steps, err
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// registerEndpointHashes adds endpoints that have a hash but are missing from
+// the registry, such as those written before the registry existed
+func (s *RedisStore) registerEndpointHashes(ctx context.Context, dryRun bool) (int, error) {
+	registered, err := s.client.SMembers(ctx, s.keys.Key(EndpointRegistryKey)).Result()
+	if err != nil {
+		return 0, err
+	}
+	endpoints, err := s.scanEndpoints(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	monitored := make(map[string]bool, len(registered))
+	for _, endpoint := range registered {
+		monitored[endpoint] = true
+	}
+	missing := unmonitored(endpoints, monitored)
+	if dryRun || len(missing) == 0 {
+		return len(missing), nil
+	}
+	if err := s.client.SAdd(ctx, s.keys.Key(EndpointRegistryKey), missing...).Err(); err != nil {
+		return 0, err
+	}
+	return len(missing), nil
+}
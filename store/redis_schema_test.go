@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestMigrate tests upgrading old-format data to the current layout
+func TestMigrate(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	if CurrentSchemaVersion != len(migrations) {
+		t.Fatalf("CurrentSchemaVersion = %d, want len(migrations) = %d", CurrentSchemaVersion, len(migrations))
+	}
+
+	// One endpoint in the original layout, one in a hash from before the registry
+	mr.Set("status:https://legacy.example.com", "200")
+	mr.Set("status_updated:https://legacy.example.com", "1700000000")
+	mr.Set("ssl:https://legacy.example.com", "1710000000")
+	mr.HSet("endpoint:https://unregistered.example.com", "status", "503", "status_updated", "1700000000")
+	before := mr.Keys()
+
+	steps, err := s.Migrate(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MigrationStep{
+		{Version: 1, Description: migrations[0].description, Changed: 1},
+		{Version: 2, Description: migrations[1].description, Changed: 1},
+	}
+	if !slices.Equal(steps, want) {
+		t.Errorf("dry run steps = %+v, want %+v", steps, want)
+	}
+	if after := mr.Keys(); !slices.Equal(after, before) {
+		t.Errorf("dry run changed keys from %v to %v", before, after)
+	}
+
+	steps, err = s.Migrate(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(steps, want) {
+		t.Errorf("steps = %+v, want %+v", steps, want)
+	}
+
+	wantKeys := []string{
+		"endpoint:https://legacy.example.com",
+		"endpoint:https://unregistered.example.com",
+		EndpointRegistryKey,
+		SchemaVersionKey,
+	}
+	if keys := mr.Keys(); !slices.Equal(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+	if version, _ := mr.Get(SchemaVersionKey); version != "2" {
+		t.Errorf("%s = %q, want 2", SchemaVersionKey, version)
+	}
+	members, _ := mr.Members(EndpointRegistryKey)
+	if !slices.Equal(members, []string{"https://legacy.example.com", "https://unregistered.example.com"}) {
+		t.Errorf("registry = %v", members)
+	}
+	if data, _ := s.GetEndpointData(ctx, "https://legacy.example.com"); data.StatusCode != 200 || data.SSLExpiration.Unix() != 1710000000 {
+		t.Errorf("migrated endpoint = %+v", data)
+	}
+
+	// Current data needs no migration
+	if steps, err := s.Migrate(ctx, false); err != nil || len(steps) != 0 {
+		t.Errorf("second Migrate() = %+v, %v; want no steps", steps, err)
+	}
+}
+
+// TestMigrateFresh tests that an empty Redis is stamped with the current version
+func TestMigrateFresh(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+
+	steps, err := s.Migrate(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range steps {
+		if step.Changed != 0 {
+			t.Errorf("step %+v changed data in an empty Redis", step)
+		}
+	}
+	if version, err := s.SchemaVersion(ctx); err != nil || version != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion() = %d, %v; want %d", version, err, CurrentSchemaVersion)
+	}
+
+	// Writing results afterwards leaves the version alone
+	s.SetStatus(ctx, "https://example.com", 200, time.Now())
+	if version, _ := mr.Get(SchemaVersionKey); version != "2" {
+		t.Errorf("%s = %q after a write, want 2", SchemaVersionKey, version)
+	}
+}
+
+// TestCheckSchemaVersion tests refusing data written by a newer release
+func TestCheckSchemaVersion(t *testing.T) {
+	tests := []struct {
+		stored  string // "" leaves the key unset
+		wantErr bool
+	}{
+		{"", false},
+		{"1", false},
+		{"2", false},
+		{"3", true},
+		{"two", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stored, func(t *testing.T) {
+			s, mr := newTestRedisStore(t)
+			if tt.stored != "" {
+				mr.Set(SchemaVersionKey, tt.stored)
+			}
+			if err := s.CheckSchemaVersion(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("CheckSchemaVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := s.Migrate(context.Background(), false); (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+//go:build redistls
+
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestRedisTLSIntegration connects to a real TLS-enabled Redis, e.g.
+//
+//	docker run -p 6380:6380 -v $PWD/certs:/certs redis:7 \
+//	  --port 0 --tls-port 6380 --tls-cert-file /certs/server.pem \
+//	  --tls-key-file /certs/server-key.pem --tls-ca-cert-file /certs/ca.pem
+//	REDIS_TLS_ADDR=localhost:6380 REDIS_TLS=true REDIS_TLS_CA_FILE=certs/ca.pem \
+//	  REDIS_TLS_CERT_FILE=certs/client.pem REDIS_TLS_KEY_FILE=certs/client-key.pem \
+//	  go test -tags redistls -run TLSIntegration ./...
+func TestRedisTLSIntegration(t *testing.T) {
+	addr := os.Getenv("REDIS_TLS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TLS_ADDR not set")
+	}
+
+	cfg, err := RedisTLSFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &redis.Options{Addr: addr, DB: 15}
+	if err := cfg.ApplyTo(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewRedisStore(redis.NewClient(opts))
+	defer s.Close()
+
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() over TLS error = %v", err)
+	}
+}
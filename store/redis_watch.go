@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyspaceEventFlags are the notify-keyspace-events classes WatchEndpoints
+// needs: keyspace channels (K) for hash writes (h), deletes and expiry
+// changes (g), expirations (x) and registry changes (s)
+const KeyspaceEventFlags = "Kghxs"
+
+// Timing of the WatchEndpoints subscription: an idle subscription is pinged
+// every watchPingInterval and counts as lost when the ping is not answered
+// within another interval; a lost one is retried after watchRetryDelay
+const (
+	watchPingInterval = 15 * time.Second
+	watchRetryDelay   = time.Second
+)
+
+// EndpointWatcher receives the notifications of WatchEndpoints
+type EndpointWatcher interface {
+	// Resync is called once subscribed, after every reconnect and when the
+	// endpoint registry changes. Changes made while the subscription was
+	// down are only seen by a full read, so implementations rebuild
+	// whatever they keep from scratch.
+	Resync()
+	// Changed is called with the endpoint whose hash was written, deleted
+	// or expired
+	Changed(endpoint string)
+	// Lost is called when the subscription breaks; until the next Resync
+	// the implementation may be missing changes
+	Lost(err error)
+}
+
+// KeyspaceEventsEnabled reports whether the server publishes the keyspace
+// notifications WatchEndpoints relies on. It fails when CONFIG GET is not
+// allowed, as on some managed Redis services.
+func (s *RedisStore) KeyspaceEventsEnabled(ctx context.Context) (bool, error) {
+	config, err := s.client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return false, err
+	}
+	flags := config["notify-keyspace-events"]
+	if !strings.Contains(flags, "K") {
+		return false, nil
+	}
+	if strings.Contains(flags, "A") {
+		return true, nil
+	}
+	for _, flag := range KeyspaceEventFlags[1:] {
+		if !strings.ContainsRune(flags, flag) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// WatchEndpoints follows the endpoint hashes and the endpoint registry
+// through Redis keyspace notifications, reporting to w, until ctx is done.
+// A broken subscription is reported with Lost and re-established.
+func (s *RedisStore) WatchEndpoints(ctx context.Context, w EndpointWatcher) {
+	for {
+		err := s.watchEndpoints(ctx, w)
+		if ctx.Err() != nil {
+			return
+		}
+		w.Lost(err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// watchEndpoints runs one subscription until it breaks or ctx is done
+func (s *RedisStore) watchEndpoints(ctx context.Context, w EndpointWatcher) error {
+	channelPrefix := fmt.Sprintf("__keyspace@%d__:", s.client.Options().DB)
+	registryKey := s.keys.Key(EndpointRegistryKey)
+	patterns := []string{
+		channelPrefix + s.keys.Pattern(EndpointKeyPrefix),
+		channelPrefix + globEscaper.Replace(registryKey),
+	}
+	pubsub := s.client.PSubscribe(ctx, patterns...)
+	defer pubsub.Close()
+
+	pinged := false
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, watchPingInterval)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() && !pinged {
+				if err := pubsub.Ping(ctx); err != nil {
+					return err
+				}
+				pinged = true
+				continue
+			}
+			return err
+		}
+		pinged = false
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Resync once every pattern is subscribed, so no change is missed
+			if msg.Kind == "psubscribe" && msg.Count == len(patterns) {
+				w.Resync()
+			}
+		case *redis.Message:
+			key := strings.TrimPrefix(msg.Channel, channelPrefix)
+			if key == registryKey {
+				// Members are only added together with their hash, which
+				// reports the endpoint already
+				if msg.Payload != "sadd" {
+					w.Resync()
+				}
+				continue
+			}
+			w.Changed(s.keys.TrimPrefix(key, EndpointKeyPrefix))
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCredentialsFromEnv reads REDIS_USERNAME and the Redis password, taken
+// from the file named by REDIS_PASSWORD_FILE when set and from REDIS_PASSWORD
+// otherwise. Trailing newlines in the file are stripped.
+func RedisCredentialsFromEnv() (username, password string, err error) {
+	username = os.Getenv("REDIS_USERNAME")
+	path := os.Getenv("REDIS_PASSWORD_FILE")
+	if path == "" {
+		return username, os.Getenv("REDIS_PASSWORD"), nil
+	}
+	if os.Getenv("REDIS_PASSWORD") != "" {
+		return "", "", fmt.Errorf("REDIS_PASSWORD and REDIS_PASSWORD_FILE are mutually exclusive")
+	}
+	password, err = readSecretFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	return username, password, nil
+}
+
+// readSecretFile returns the contents of a mounted secret without the
+// trailing newline editors and `echo` tend to add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// RedisPool tunes the Redis connection pool and socket timeouts. Zero
+// fields keep the go-redis defaults: 10 connections per CPU, no idle
+// minimum, 3s read and write timeouts and a pool timeout of the read
+// timeout plus 1s.
+type RedisPool struct {
+	PoolSize     int           // maximum open connections
+	MinIdleConns int           // idle connections kept open
+	PoolTimeout  time.Duration // how long a call waits for a free connection
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// RedisPoolFromEnv reads REDIS_POOL_SIZE, REDIS_MIN_IDLE_CONNS,
+// REDIS_POOL_TIMEOUT, REDIS_READ_TIMEOUT and REDIS_WRITE_TIMEOUT.
+func RedisPoolFromEnv() (RedisPool, error) {
+	var cfg RedisPool
+	var err error
+	if cfg.PoolSize, err = envInt("REDIS_POOL_SIZE"); err != nil {
+		return cfg, err
+	}
+	if cfg.MinIdleConns, err = envInt("REDIS_MIN_IDLE_CONNS"); err != nil {
+		return cfg, err
+	}
+	if cfg.PoolTimeout, err = envDuration("REDIS_POOL_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.ReadTimeout, err = envDuration("REDIS_READ_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	if cfg.WriteTimeout, err = envDuration("REDIS_WRITE_TIMEOUT"); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// ApplyTo sets the configured fields of opts, leaving the others at their defaults
+func (c RedisPool) ApplyTo(opts *redis.Options) {
+	if c.PoolSize > 0 {
+		opts.PoolSize = c.PoolSize
+	}
+	if c.MinIdleConns > 0 {
+		opts.MinIdleConns = c.MinIdleConns
+	}
+	if c.PoolTimeout > 0 {
+		opts.PoolTimeout = c.PoolTimeout
+	}
+	if c.ReadTimeout > 0 {
+		opts.ReadTimeout = c.ReadTimeout
+	}
+	if c.WriteTimeout > 0 {
+		opts.WriteTimeout = c.WriteTimeout
+	}
+}
+
+func envInt(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s value %q: want a non-negative integer", key, value)
+	}
+	return n, nil
+}
+
+func envDuration(key string) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s value %q: want a duration such as 500ms", key, value)
+	}
+	return d, nil
+}
+
+// RedisTLS configures TLS, and optionally mutual TLS, for Redis connections
+type RedisTLS struct {
+	Enabled  bool
+	CAFile   string // PEM bundle to verify the server; system roots when empty
+	CertFile string // client certificate for mutual TLS
+	KeyFile  string
+	Insecure bool // skip server certificate verification
+}
+
+// RedisTLSFromEnv reads REDIS_TLS, REDIS_TLS_CA_FILE, REDIS_TLS_CERT_FILE,
+// REDIS_TLS_KEY_FILE and REDIS_TLS_INSECURE.
+func RedisTLSFromEnv() (RedisTLS, error) {
+	var cfg RedisTLS
+	var err error
+	if cfg.Enabled, err = envBool("REDIS_TLS"); err != nil {
+		return cfg, err
+	}
+	if cfg.Insecure, err = envBool("REDIS_TLS_INSECURE"); err != nil {
+		return cfg, err
+	}
+	cfg.CAFile = os.Getenv("REDIS_TLS_CA_FILE")
+	cfg.CertFile = os.Getenv("REDIS_TLS_CERT_FILE")
+	cfg.KeyFile = os.Getenv("REDIS_TLS_KEY_FILE")
+	return cfg, nil
+}
+
+func envBool(key string) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	return b, nil
+}
+
+// Config builds the tls.Config for connecting to addr, or returns nil when
+// TLS is disabled. Unreadable certificate files are reported with their path.
+func (c RedisTLS) Config(addr string) (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, fmt.Errorf("REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE must be set together")
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         host,
+		InsecureSkipVerify: c.Insecure,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file %s: %w", c.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate %s / key %s: %w", c.CertFile, c.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// ApplyTo sets opts.TLSConfig for the configured TLS mode
+func (c RedisTLS) ApplyTo(opts *redis.Options) error {
+	tlsConfig, err := c.Config(opts.Addr)
+	if err != nil {
+		return err
+	}
+	opts.TLSConfig = tlsConfig
+	return nil
+}
@@ -0,0 +1,316 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testPKI is a throwaway CA with a server and a client certificate written to disk
+type testPKI struct {
+	caPool     *x509.CertPool
+	server     tls.Certificate
+	caFile     string
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "127.0.0.1"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	clientKeyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return testPKI{
+		caPool:     pool,
+		server:     tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey},
+		caFile:     writePEM("ca.pem", "CERTIFICATE", caDER),
+		clientCert: writePEM("client.pem", "CERTIFICATE", clientDER),
+		clientKey:  writePEM("client-key.pem", "EC PRIVATE KEY", clientKeyDER),
+	}
+}
+
+// TestRedisMutualTLS tests connecting to a Redis server that requires client certificates
+func TestRedisMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+
+	mr := miniredis.NewMiniRedis()
+	if err := mr.StartTLS(&tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientCAs:    pki.caPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	tests := []struct {
+		name    string
+		tls     RedisTLS
+		wantErr bool
+	}{
+		{"mutual TLS", RedisTLS{Enabled: true, CAFile: pki.caFile, CertFile: pki.clientCert, KeyFile: pki.clientKey}, false},
+		{"missing client certificate", RedisTLS{Enabled: true, CAFile: pki.caFile}, true},
+		{"untrusted server", RedisTLS{Enabled: true, CertFile: pki.clientCert, KeyFile: pki.clientKey}, true},
+		{"insecure skips server verification", RedisTLS{Enabled: true, Insecure: true, CertFile: pki.clientCert, KeyFile: pki.clientKey}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &redis.Options{Addr: mr.Addr(), MaxRetries: -1}
+			if err := tt.tls.ApplyTo(opts); err != nil {
+				t.Fatalf("ApplyTo() error = %v", err)
+			}
+			client := redis.NewClient(opts)
+			defer client.Close()
+
+			err := client.Ping(context.Background()).Err()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRedisTLSConfigErrors tests that configuration problems name the offending file
+func TestRedisTLSConfigErrors(t *testing.T) {
+	pki := newTestPKI(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name    string
+		tls     RedisTLS
+		wantErr string
+	}{
+		{"missing CA file", RedisTLS{Enabled: true, CAFile: missing}, missing},
+		{"CA file without certificates", RedisTLS{Enabled: true, CAFile: pki.clientKey}, pki.clientKey},
+		{"missing client certificate", RedisTLS{Enabled: true, CertFile: missing, KeyFile: pki.clientKey}, missing},
+		{"certificate without key", RedisTLS{Enabled: true, CertFile: pki.clientCert}, "must be set together"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.tls.Config("localhost:6379")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Config() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if cfg, err := (RedisTLS{CAFile: missing}).Config("localhost:6379"); cfg != nil || err != nil {
+		t.Errorf("disabled TLS Config() = %v, %v; want nil, nil", cfg, err)
+	}
+}
+
+// TestRedisTLSFromEnv tests reading the TLS settings from the environment
+func TestRedisTLSFromEnv(t *testing.T) {
+	t.Setenv("REDIS_TLS", "true")
+	t.Setenv("REDIS_TLS_CA_FILE", "/etc/redis/ca.pem")
+	t.Setenv("REDIS_TLS_INSECURE", "")
+
+	cfg, err := RedisTLSFromEnv()
+	if err != nil {
+		t.Fatalf("RedisTLSFromEnv() error = %v", err)
+	}
+	if !cfg.Enabled || cfg.CAFile != "/etc/redis/ca.pem" || cfg.Insecure {
+		t.Errorf("RedisTLSFromEnv() = %+v", cfg)
+	}
+
+	t.Setenv("REDIS_TLS", "yes please")
+	if _, err := RedisTLSFromEnv(); err == nil || !strings.Contains(err.Error(), "REDIS_TLS") {
+		t.Errorf("RedisTLSFromEnv() error = %v, want invalid REDIS_TLS", err)
+	}
+}
+
+// TestRedisCredentialsFromEnv tests the username and password sources
+func TestRedisCredentialsFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "redis-password")
+	if err := os.WriteFile(secret, []byte("s3cret\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	crlf := filepath.Join(dir, "redis-password-crlf")
+	if err := os.WriteFile(crlf, []byte("s3cret\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		password     string
+		passwordFile string
+		wantPassword string
+		wantErr      string
+	}{
+		{"password from env", "fromenv", "", "fromenv", ""},
+		{"password from file", "", secret, "s3cret", ""},
+		{"CRLF stripped", "", crlf, "s3cret", ""},
+		{"missing file", "", filepath.Join(dir, "missing"), "", "missing"},
+		{"both set", "fromenv", secret, "", "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REDIS_USERNAME", "checker")
+			t.Setenv("REDIS_PASSWORD", tt.password)
+			t.Setenv("REDIS_PASSWORD_FILE", tt.passwordFile)
+
+			username, password, err := RedisCredentialsFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("RedisCredentialsFromEnv() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RedisCredentialsFromEnv() error = %v", err)
+			}
+			if username != "checker" || password != tt.wantPassword {
+				t.Errorf("RedisCredentialsFromEnv() = %q, %q; want %q, %q", username, password, "checker", tt.wantPassword)
+			}
+		})
+	}
+}
+
+// TestRedisPoolFromEnv tests reading the pool settings and applying them
+// over the go-redis defaults
+func TestRedisPoolFromEnv(t *testing.T) {
+	t.Setenv("REDIS_POOL_SIZE", "50")
+	t.Setenv("REDIS_MIN_IDLE_CONNS", "")
+	t.Setenv("REDIS_POOL_TIMEOUT", "250ms")
+	t.Setenv("REDIS_READ_TIMEOUT", "")
+	t.Setenv("REDIS_WRITE_TIMEOUT", "1s")
+
+	cfg, err := RedisPoolFromEnv()
+	if err != nil {
+		t.Fatalf("RedisPoolFromEnv() error = %v", err)
+	}
+	want := RedisPool{PoolSize: 50, PoolTimeout: 250 * time.Millisecond, WriteTimeout: time.Second}
+	if cfg != want {
+		t.Errorf("RedisPoolFromEnv() = %+v, want %+v", cfg, want)
+	}
+
+	opts := &redis.Options{ReadTimeout: 3 * time.Second}
+	cfg.ApplyTo(opts)
+	if opts.PoolSize != 50 || opts.MinIdleConns != 0 || opts.PoolTimeout != 250*time.Millisecond || opts.ReadTimeout != 3*time.Second || opts.WriteTimeout != time.Second {
+		t.Errorf("ApplyTo() options = %+v", opts)
+	}
+
+	for key, value := range map[string]string{"REDIS_POOL_SIZE": "-1", "REDIS_MIN_IDLE_CONNS": "many", "REDIS_READ_TIMEOUT": "3"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := RedisPoolFromEnv(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("RedisPoolFromEnv() error = %v, want invalid %s", err, key)
+			}
+		})
+	}
+}
+
+// TestPoolStats tests that pool exhaustion shows up as timeouts
+func TestPoolStats(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opts := &redis.Options{Addr: mr.Addr()}
+	RedisPool{PoolSize: 1, PoolTimeout: 10 * time.Millisecond}.ApplyTo(opts)
+	s := NewRedisStore(redis.NewClient(opts))
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.PoolStats(); stats.PoolSize != 1 || stats.TotalConns != 1 || stats.Timeouts != 0 {
+		t.Errorf("PoolStats() after one call = %+v", stats)
+	}
+
+	// Hold the only connection so the next call has to wait for it
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		s.WatchPoolTimeouts(watchCtx, 20*time.Millisecond)
+	}()
+
+	conn := s.client.Conn()
+	if err := conn.Ping(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(ctx); err == nil {
+		t.Error("Ping() with the pool exhausted succeeded")
+	}
+	conn.Close()
+	if stats := s.PoolStats(); stats.Timeouts == 0 {
+		t.Errorf("PoolStats() with the pool exhausted = %+v, want timeouts", stats)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	stopWatching()
+	<-watching
+	if !strings.Contains(logs.String(), "timed out waiting for a pooled connection") {
+		t.Errorf("no pool timeout warning logged, got %q", logs.String())
+	}
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// LatencyRollupRetention is how long hourly latency rollups are kept
+const LatencyRollupRetention = 30 * 24 * time.Hour
+
+// LatencyRollup summarizes the status checks of one hour
+type LatencyRollup struct {
+	Hour  time.Time // start of the hour, UTC
+	Count int       // checks with a response, which the latencies are taken from
+	Min   time.Duration
+	Avg   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+
+	// Checks and Up count every check and those whose status was up, see
+	// StatusLevel; both are 0 for hours rolled up before they were counted
+	Checks int
+	Up     int
+
+	// Maintenance and MaintenanceUp count the checks of Checks and Up that
+	// fell in a maintenance window, as the windows were when rolled up
+	Maintenance   int
+	MaintenanceUp int
+}
+
+// RollupLatency groups history into hourly rollups for every hour that ended
+// at or before now, oldest first. Failed checks (status code 0 or below)
+// are left out of the latencies because their latency is the time to fail,
+// not to respond, but are counted in Checks. Checks for which inMaintenance
+// reports true are also counted in Maintenance; nil counts none. The result
+// only depends on the entries and windows, so recomputing an hour is safe.
+func RollupLatency(history []HistoryEntry, now time.Time, inMaintenance func(time.Time) bool) []LatencyRollup {
+	currentHour := now.UTC().Truncate(time.Hour)
+	byHour := make(map[time.Time]*LatencyRollup)
+	latencies := make(map[time.Time][]time.Duration)
+	for _, entry := range history {
+		hour := entry.CheckedAt.UTC().Truncate(time.Hour)
+		if !hour.Before(currentHour) {
+			continue
+		}
+		rollup, ok := byHour[hour]
+		if !ok {
+			rollup = &LatencyRollup{Hour: hour}
+			byHour[hour] = rollup
+		}
+		up := StatusLevel(entry.StatusCode) == StatusUp
+		rollup.Checks++
+		if up {
+			rollup.Up++
+		}
+		if inMaintenance != nil && inMaintenance(entry.CheckedAt) {
+			rollup.Maintenance++
+			if up {
+				rollup.MaintenanceUp++
+			}
+		}
+		if entry.StatusCode > 0 {
+			latencies[hour] = append(latencies[hour], entry.Latency)
+		}
+	}
+
+	rollups := make([]LatencyRollup, 0, len(byHour))
+	for hour, rollup := range byHour {
+		if sorted := latencies[hour]; len(sorted) > 0 {
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			var total time.Duration
+			for _, latency := range sorted {
+				total += latency
+			}
+			n := len(sorted)
+			rollup.Count = n
+			rollup.Min = sorted[0]
+			rollup.Avg = total / time.Duration(n)
+			rollup.P95 = sorted[(n*95+99)/100-1] // nearest rank
+			rollup.Max = sorted[n-1]
+		}
+		rollups = append(rollups, *rollup)
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Hour.Before(rollups[j].Hour) })
+	return rollups
+}
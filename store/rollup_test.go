@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRollupLatency tests the hourly min/avg/p95/max computation and the
+// check counts
+func TestRollupLatency(t *testing.T) {
+	hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var history []HistoryEntry
+	for i := 1; i <= 20; i++ {
+		history = append(history, HistoryEntry{
+			CheckedAt:  hour.Add(time.Duration(i) * time.Minute),
+			StatusCode: 200,
+			Latency:    time.Duration(i*10) * time.Millisecond,
+		})
+	}
+	history = append(history,
+		// Failed checks are counted, but not in the latencies
+		HistoryEntry{CheckedAt: hour.Add(30 * time.Minute), StatusCode: 0, Latency: 10 * time.Second},
+		// The next hour has one check, which is down
+		HistoryEntry{CheckedAt: hour.Add(70 * time.Minute), StatusCode: 503, Latency: 5 * time.Millisecond},
+		// The hour after has only failed checks
+		HistoryEntry{CheckedAt: hour.Add(130 * time.Minute), StatusCode: -1, Latency: time.Millisecond},
+		// The current hour is not complete yet
+		HistoryEntry{CheckedAt: hour.Add(190 * time.Minute), StatusCode: 200, Latency: time.Millisecond},
+	)
+
+	// Maintenance covers the last checks of the first hour and the next hour
+	inMaintenance := func(t time.Time) bool {
+		return !t.Before(hour.Add(20*time.Minute)) && t.Before(hour.Add(2*time.Hour))
+	}
+	got := RollupLatency(history, hour.Add(195*time.Minute), inMaintenance)
+	want := []LatencyRollup{
+		{Hour: hour, Count: 20, Min: 10 * time.Millisecond, Avg: 105 * time.Millisecond, P95: 190 * time.Millisecond, Max: 200 * time.Millisecond, Checks: 21, Up: 20, Maintenance: 2, MaintenanceUp: 1},
+		{Hour: hour.Add(time.Hour), Count: 1, Min: 5 * time.Millisecond, Avg: 5 * time.Millisecond, P95: 5 * time.Millisecond, Max: 5 * time.Millisecond, Checks: 1, Maintenance: 1},
+		{Hour: hour.Add(2 * time.Hour), Checks: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("RollupLatency() = %+v, want %+v", got, want)
+	}
+
+	if got := RollupLatency(nil, hour, nil); len(got) != 0 {
+		t.Errorf("RollupLatency(nil) = %+v, want none", got)
+	}
+}
+
+// TestLatencyRollups tests storing, replacing and expiring rollups
+func TestLatencyRollups(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		hour := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		rollup := func(h time.Time, count int) LatencyRollup {
+			return LatencyRollup{Hour: h, Count: count, Min: time.Millisecond, Avg: 2 * time.Millisecond, P95: 3 * time.Millisecond, Max: 4 * time.Millisecond, Checks: count + 1, Up: count, Maintenance: 1, MaintenanceUp: 1}
+		}
+
+		old := hour.Add(-LatencyRollupRetention - time.Hour)
+		if err := s.SaveLatencyRollups(ctx, endpoint, []LatencyRollup{rollup(old, 1), rollup(hour, 1)}); err != nil {
+			t.Fatal(err)
+		}
+		// Rolling up the same hour again replaces it
+		if err := s.SaveLatencyRollups(ctx, endpoint, []LatencyRollup{rollup(hour, 5), rollup(hour.Add(time.Hour), 2)}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.LatencyRollups(ctx, endpoint, old)
+		if err != nil {
+			t.Fatalf("LatencyRollups() error = %v", err)
+		}
+		want := []LatencyRollup{rollup(hour, 5), rollup(hour.Add(time.Hour), 2)}
+		if !slices.Equal(got, want) {
+			t.Errorf("LatencyRollups() = %+v, want %+v", got, want)
+		}
+
+		got, _ = s.LatencyRollups(ctx, endpoint, hour.Add(time.Hour))
+		if len(got) != 1 {
+			t.Errorf("LatencyRollups(since) = %+v, want only the last hour", got)
+		}
+	})
+}
+
+// TestComputeUptime tests summing rollups into the uptime windows, leaving
+// out the current hour and hours without checks
+func TestComputeUptime(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 30, 0, 0, time.UTC)
+	hour := now.Truncate(time.Hour)
+	rollups := []LatencyRollup{
+		{Hour: hour.Add(-31 * 24 * time.Hour), Checks: 60, Up: 0},    // outside every window
+		{Hour: hour.Add(-20 * 24 * time.Hour), Checks: 60, Up: 30},   // 30d
+		{Hour: hour.Add(-3 * 24 * time.Hour), Checks: 60, Up: 60},    // 7d and 30d
+		{Hour: hour.Add(-24 * time.Hour), Checks: 60, Up: 59},        // first hour of 24h
+		{Hour: hour.Add(-time.Hour), Checks: 60, Up: 60},             // last completed hour
+		{Hour: hour.Add(-2 * time.Hour), Count: 5, Checks: 0, Up: 0}, // rolled up before checks were counted
+		{Hour: hour, Checks: 30, Up: 0},                              // not complete yet
+	}
+
+	got := ComputeUptime(rollups, now)
+	want := []Uptime{
+		{Window: "24h", Checks: 120, Up: 119},
+		{Window: "7d", Checks: 180, Up: 179},
+		{Window: "30d", Checks: 240, Up: 209},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ComputeUptime() = %+v, want %+v", got, want)
+	}
+	if p, ok := got[0].Percent(); !ok || p < 99.16 || p > 99.17 {
+		t.Errorf("Percent() = %v, %v, want 99.16..99.17", p, ok)
+	}
+
+	got = ComputeUptime(nil, now)
+	if _, ok := got[0].Percent(); ok || len(got) != len(UptimeWindows) {
+		t.Errorf("ComputeUptime(nil) = %+v, want windows without checks", got)
+	}
+}
+
+// TestSaveUptime tests storing uptime with the endpoint's results, and only
+// for endpoints that have results
+func TestSaveUptime(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		uptime := []Uptime{{Window: "24h", Checks: 1440, Up: 1438}, {Window: "7d", Checks: 10080, Up: 10000}, {Window: "30d", Checks: 0, Up: 0}}
+
+		if err := s.SaveUptime(ctx, "https://unknown.example.com", uptime); err != nil {
+			t.Fatal(err)
+		}
+		if endpoints, _ := s.ListEndpoints(ctx); len(endpoints) != 0 {
+			t.Errorf("SaveUptime created endpoints %v", endpoints)
+		}
+
+		if err := s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: time.Now(), HasStatus: true, StatusCode: 200}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveUptime(ctx, endpoint, uptime); err != nil {
+			t.Fatal(err)
+		}
+		data, err := s.GetEndpointData(ctx, endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(data.Uptime, uptime) {
+			t.Errorf("Uptime = %+v, want %+v", data.Uptime, uptime)
+		}
+	})
+}
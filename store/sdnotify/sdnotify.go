@@ -0,0 +1,90 @@
+// Package sdnotify sends the service notifications of systemd, see
+// sd_notify(3), so that a Type=notify unit is only started once the binary
+// is ready and, with WatchdogSec, restarted when it stops pinging. Outside
+// systemd, without NOTIFY_SOCKET, every call does nothing.
+package sdnotify
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Ready tells the service manager that startup completed
+const Ready = "READY=1"
+
+// Notify sends state, e.g. Ready, to the socket of NOTIFY_SOCKET. It
+// returns nil without the variable.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the WatchdogSec of the service, from
+// WATCHDOG_USEC, or 0 when the watchdog is off or meant for another
+// process, by WATCHDOG_PID
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pings the watchdog every half WatchdogInterval until ctx is
+// done. While healthy, unless nil, returns an error the pings are held
+// back, so that systemd restarts a binary whose work stopped progressing.
+// Without a watchdog it returns at once.
+func Watchdog(ctx context.Context, healthy func() error) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	var stalled bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var err error
+		if healthy != nil {
+			err = healthy()
+		}
+		if err != nil {
+			if !stalled {
+				log.Printf("[ERROR] Holding back the systemd watchdog ping, the service will be restarted: %v", err)
+			}
+			stalled = true
+			continue
+		}
+		if stalled {
+			log.Printf("[INFO] Pinging the systemd watchdog again")
+		}
+		stalled = false
+		if err := Notify("WATCHDOG=1"); err != nil {
+			log.Printf("[WARN] Failed to ping the systemd watchdog: %v", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package sdnotify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listen serves NOTIFY_SOCKET for the test, returning the states sent
+func listen(t *testing.T) <-chan string {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, too few for t.TempDir
+	dir, err := os.MkdirTemp("", "sdnotify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	states := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			states <- string(buf[:n])
+		}
+	}()
+	return states
+}
+
+// TestNotify tests that states reach NOTIFY_SOCKET and that nothing
+// happens without it
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(Ready); err != nil {
+		t.Errorf("Notify() without NOTIFY_SOCKET = %v", err)
+	}
+
+	states := listen(t)
+	if err := Notify(Ready); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case state := <-states:
+		if state != "READY=1" {
+			t.Errorf("sent %q, want READY=1", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing sent")
+	}
+}
+
+// TestWatchdogInterval tests reading WatchdogSec for this process only
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval() = %s, want 30s", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval() for this process = %s, want 30s", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval() for another process = %s, want 0", got)
+	}
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval() without a watchdog = %s, want 0", got)
+	}
+}
+
+// TestWatchdog tests that pings are sent while healthy and held back
+// otherwise
+func TestWatchdog(t *testing.T) {
+	states := listen(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+	var stalled atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watchdog(ctx, func() error {
+			if stalled.Load() {
+				return errors.New("stalled")
+			}
+			return nil
+		})
+		close(done)
+	}()
+
+	select {
+	case state := <-states:
+		if state != "WATCHDOG=1" {
+			t.Errorf("sent %q, want WATCHDOG=1", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no ping while healthy")
+	}
+	stalled.Store(true)
+	time.Sleep(30 * time.Millisecond) // a ping may be on its way
+	for len(states) > 0 {
+		<-states
+	}
+	select {
+	case state := <-states:
+		t.Errorf("sent %q while stalled", state)
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SLOKey is the hash of the SLO statuses computed by the checker, each a
+// JSON SLOStatus under its tag
+const SLOKey = "slo"
+
+// sloEndpointPrefix prefixes the tag in the Endpoint of slo_burn events
+const sloEndpointPrefix = "slo:"
+
+// SLO states reported by slo_burn events
+const (
+	SLOStateOK      = "ok"
+	SLOStateBurning = "burning" // the burn rate reached the threshold
+)
+
+// SLOTarget is the objective of the endpoints with a tag: the percentage
+// of checks that should be up, e.g. 99.9
+type SLOTarget struct {
+	Tag       string
+	Objective float64
+}
+
+// SLOPolicy tells how SLOs are computed from the hourly rollups
+type SLOPolicy struct {
+	Window            time.Duration // compliance and error budget cover it; whole hours within LatencyRollupRetention
+	BurnWindow        time.Duration // the burn rate covers it; whole hours
+	BurnRateThreshold float64       // burn rates at or above it are burning; 0 never burns
+	// ExcludeMaintenance leaves out the checks that fell in a maintenance
+	// window; otherwise they count like any other
+	ExcludeMaintenance bool
+	// ExcludeUnknown leaves out hours without checks, e.g. while the
+	// checker was down; otherwise each counts as ChecksPerHour failed checks
+	ExcludeUnknown bool
+	ChecksPerHour  int
+}
+
+// SLOStatus is an SLO as computed at UpdatedAt. Checks and Good count
+// the checks of the window after the policy's exclusions, including
+// unknown hours unless they are excluded.
+type SLOStatus struct {
+	Tag                string    `json:"tag"`
+	Objective          float64   `json:"objective"`
+	Window             string    `json:"window"` // "30d"
+	ExcludeMaintenance bool      `json:"exclude_maintenance"`
+	ExcludeUnknown     bool      `json:"exclude_unknown"`
+	Endpoints          int       `json:"endpoints"`
+	Checks             int       `json:"checks"`
+	Good               int       `json:"good"`
+	MaintenanceChecks  int       `json:"maintenance_checks"` // checks in maintenance windows, whether excluded or not
+	UnknownHours       int       `json:"unknown_hours"`      // endpoint hours without checks, whether excluded or not
+	Compliance         float64   `json:"compliance"`         // percentage of good checks; 100 without checks
+	BudgetRemaining    float64   `json:"budget_remaining"`   // share of the error budget left, negative once overspent
+	BurnRate           float64   `json:"burn_rate"`          // how fast the burn window spends the budget; 1 spends it in exactly the window
+	BurnRateThreshold  float64   `json:"burn_rate_threshold"`
+	Burning            bool      `json:"burning"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// State returns SLOStateBurning or SLOStateOK
+func (s SLOStatus) State() string {
+	if s.Burning {
+		return SLOStateBurning
+	}
+	return SLOStateOK
+}
+
+// SLOEndpoint returns the Endpoint of the slo_burn events of tag
+func SLOEndpoint(tag string) string {
+	return sloEndpointPrefix + tag
+}
+
+// SLOEndpointTag returns the tag of an SLOEndpoint, false for endpoints
+// that are not
+func SLOEndpointTag(endpoint string) (string, bool) {
+	return strings.CutPrefix(endpoint, sloEndpointPrefix)
+}
+
+// ComputeSLO computes target's status at now from the hourly rollups of
+// each endpoint with the tag, counting back from the start of the hour of
+// now, so the windows cover completed hours only. An endpoint's hours
+// before its first rollup in the window are not counted, as it may not
+// have been checked yet; hours without checks after it are unknown.
+func ComputeSLO(target SLOTarget, policy SLOPolicy, rollups [][]LatencyRollup, now time.Time) SLOStatus {
+	status := SLOStatus{
+		Tag:                target.Tag,
+		Objective:          target.Objective,
+		Window:             sloWindowName(policy.Window),
+		ExcludeMaintenance: policy.ExcludeMaintenance,
+		ExcludeUnknown:     policy.ExcludeUnknown,
+		Endpoints:          len(rollups),
+		BurnRateThreshold:  policy.BurnRateThreshold,
+		UpdatedAt:          now,
+	}
+	currentHour := now.UTC().Truncate(time.Hour)
+	since := currentHour.Add(-policy.Window)
+	burnSince := currentHour.Add(-policy.BurnWindow)
+
+	var burnChecks, burnGood int
+	for _, endpointRollups := range rollups {
+		byHour := make(map[time.Time]LatencyRollup)
+		var first time.Time
+		for _, rollup := range endpointRollups {
+			hour := rollup.Hour.UTC()
+			if rollup.Checks == 0 || hour.Before(since) || !hour.Before(currentHour) {
+				continue
+			}
+			byHour[hour] = rollup
+			if first.IsZero() || hour.Before(first) {
+				first = hour
+			}
+		}
+		if first.IsZero() {
+			continue
+		}
+		for hour := first; hour.Before(currentHour); hour = hour.Add(time.Hour) {
+			var checks, good int
+			if rollup, ok := byHour[hour]; ok {
+				checks, good = rollup.Checks, rollup.Up
+				status.MaintenanceChecks += rollup.Maintenance
+				if policy.ExcludeMaintenance {
+					checks -= rollup.Maintenance
+					good -= rollup.MaintenanceUp
+				}
+			} else {
+				status.UnknownHours++
+				if policy.ExcludeUnknown {
+					continue
+				}
+				checks = policy.ChecksPerHour
+			}
+			status.Checks += checks
+			status.Good += good
+			if !hour.Before(burnSince) {
+				burnChecks += checks
+				burnGood += good
+			}
+		}
+	}
+
+	allowed := 1 - target.Objective/100
+	status.Compliance, status.BudgetRemaining = 100, 1
+	if status.Checks > 0 {
+		badShare := float64(status.Checks-status.Good) / float64(status.Checks)
+		status.Compliance = 100 * (1 - badShare)
+		status.BudgetRemaining = 1 - badShare/allowed
+	}
+	if burnChecks > 0 {
+		status.BurnRate = float64(burnChecks-burnGood) / float64(burnChecks) / allowed
+	}
+	status.Burning = policy.BurnRateThreshold > 0 && status.BurnRate >= policy.BurnRateThreshold
+	return status
+}
+
+// sloWindowName names a window in days when it is whole days, e.g. "30d",
+// and in hours otherwise
+func sloWindowName(window time.Duration) string {
+	if window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", window/time.Hour)
+}
+
+// SaveSLOStatuses replaces the stored statuses with statuses, so the SLOs
+// no longer configured go
+func (s *RedisStore) SaveSLOStatuses(ctx context.Context, statuses []SLOStatus) error {
+	key := s.keys.Key(SLOKey)
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, key)
+	for _, status := range statuses {
+		payload, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(ctx, key, status.Tag, payload)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SLOStatuses returns the stored statuses sorted by tag, skipping entries
+// that do not parse
+func (s *RedisStore) SLOStatuses(ctx context.Context) ([]SLOStatus, error) {
+	values, err := s.client.HGetAll(ctx, s.keys.Key(SLOKey)).Result()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]SLOStatus, 0, len(values))
+	for _, payload := range values {
+		var status SLOStatus
+		if json.Unmarshal([]byte(payload), &status) != nil {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(a, b SLOStatus) int { return strings.Compare(a.Tag, b.Tag) })
+	return statuses, nil
+}
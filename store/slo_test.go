@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestComputeSLO tests compliance, error budget and burn rate with and
+// without leaving out maintenance and unknown hours
+func TestComputeSLO(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 30, 0, 0, time.UTC)
+	hour := now.Truncate(time.Hour)
+	var a []LatencyRollup
+	for back := 25; back >= 0; back-- {
+		rollup := LatencyRollup{Hour: hour.Add(-time.Duration(back) * time.Hour), Checks: 60, Up: 60}
+		switch back {
+		case 25: // before the window
+			rollup.Up = 0
+		case 5: // down during maintenance
+			rollup.Up, rollup.Maintenance = 0, 60
+		case 1: // half down in the burn window
+			rollup.Up = 30
+		case 0: // not complete yet
+			rollup.Up = 0
+		}
+		a = append(a, rollup)
+	}
+	// b was first checked 3 hours ago and missed the hour after
+	b := []LatencyRollup{
+		{Hour: hour.Add(-3 * time.Hour), Checks: 60, Up: 60},
+		{Hour: hour.Add(-time.Hour), Checks: 60, Up: 60},
+	}
+	target := SLOTarget{Tag: "payments", Objective: 99}
+	policy := SLOPolicy{Window: 24 * time.Hour, BurnWindow: time.Hour, BurnRateThreshold: 14.4, ExcludeMaintenance: true, ExcludeUnknown: true, ChecksPerHour: 60}
+
+	got := ComputeSLO(target, policy, [][]LatencyRollup{a, b, nil}, now)
+	if got.Tag != "payments" || got.Window != "1d" || got.Endpoints != 3 || got.Checks != 1500 || got.Good != 1470 ||
+		got.MaintenanceChecks != 60 || got.UnknownHours != 1 || !got.Burning || got.State() != SLOStateBurning {
+		t.Errorf("ComputeSLO() = %+v", got)
+	}
+	for name, value := range map[string][2]float64{
+		"compliance":       {got.Compliance, 98},
+		"budget remaining": {got.BudgetRemaining, -1},
+		"burn rate":        {got.BurnRate, 25}, // a quarter of the last hour's checks failed
+	} {
+		if math.Abs(value[0]-value[1]) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, value[0], value[1])
+		}
+	}
+
+	// Counted, the maintenance hour fails and the unknown hour of b fails
+	// 60 checks
+	policy.ExcludeMaintenance, policy.ExcludeUnknown = false, false
+	got = ComputeSLO(target, policy, [][]LatencyRollup{a, b}, now)
+	if got.Checks != 1620 || got.Good != 1470 || got.MaintenanceChecks != 60 || got.UnknownHours != 1 {
+		t.Errorf("ComputeSLO() counting everything = %+v", got)
+	}
+	// b's unknown hour is outside the burn window
+	if math.Abs(got.BurnRate-25) > 1e-9 {
+		t.Errorf("burn rate = %v, want 25", got.BurnRate)
+	}
+
+	policy.Window = 36 * time.Hour
+	got = ComputeSLO(target, policy, nil, now)
+	if got.Window != "36h" || got.Checks != 0 || got.Compliance != 100 || got.BudgetRemaining != 1 || got.BurnRate != 0 || got.Burning {
+		t.Errorf("ComputeSLO() without rollups = %+v", got)
+	}
+}
+
+// TestSLOEndpoint tests the pseudo-endpoints of slo_burn events
+func TestSLOEndpoint(t *testing.T) {
+	if tag, ok := SLOEndpointTag(SLOEndpoint("payments")); !ok || tag != "payments" {
+		t.Errorf("SLOEndpointTag(SLOEndpoint()) = %q, %v", tag, ok)
+	}
+	if _, ok := SLOEndpointTag("https://example.com"); ok {
+		t.Error("SLOEndpointTag() of a URL succeeded")
+	}
+}
+
+// TestSLOStatuses tests that saving replaces every stored status
+func TestSLOStatuses(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := s.SaveSLOStatuses(ctx, []SLOStatus{{Tag: "erp", Objective: 99}}); err != nil {
+		t.Fatal(err)
+	}
+	saved := []SLOStatus{
+		{Tag: "prod", Objective: 99.5, Window: "30d", Checks: 100, Good: 100, Compliance: 100, BudgetRemaining: 1, UpdatedAt: at},
+		{Tag: "payments", Objective: 99.9, Window: "30d", Checks: 1000, Good: 990, Compliance: 99, BudgetRemaining: -9, BurnRate: 20, BurnRateThreshold: 14.4, Burning: true, UpdatedAt: at},
+	}
+	if err := s.SaveSLOStatuses(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+	mr.HSet(SLOKey, "broken", "not json")
+
+	got, err := s.SLOStatuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != saved[1] || got[1] != saved[0] {
+		t.Errorf("SLOStatuses() = %+v, want the saved statuses by tag", got)
+	}
+}
@@ -0,0 +1,287 @@
+// Package store defines how endpoint check results are persisted and read
+// back, shared by the endpoint checker and the dashboard.
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Certificate states stored with CertInfo
+const (
+	CertStateValid       = "valid"
+	CertStateNotYetValid = "not_yet_valid"
+)
+
+// CertInfo describes the leaf certificate presented by an endpoint
+type CertInfo struct {
+	NotBefore    time.Time
+	NotAfter     time.Time
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	Fingerprint  string
+	State        string
+	RemoteAddr   string // IP and port the certificate was read from, e.g. "192.0.2.10:443"
+}
+
+// HeaderAudit is the result of evaluating the configured header policies
+// against one HTTP response.
+type HeaderAudit struct {
+	Headers  map[string]string // captured values, keyed by canonical header name
+	Failures []string
+	Updated  time.Time
+}
+
+func (a HeaderAudit) Passed() bool {
+	return len(a.Failures) == 0
+}
+
+// Classes of CheckError
+const (
+	ErrorClassDNS               = "dns"
+	ErrorClassTimeout           = "timeout"
+	ErrorClassTLS               = "tls"
+	ErrorClassConnectionRefused = "connection_refused"
+	ErrorClassConnectionReset   = "connection_reset"
+	ErrorClassNetwork           = "network" // any other failure to get a response
+	ErrorClassHTTP              = "http"    // a response with a 4xx or 5xx status
+)
+
+// CheckError describes why the last status check of an endpoint was not
+// up. A check that is up clears it. The checker carries Since and Count
+// over from the stored error while the endpoint stays down, so they
+// survive restarts.
+type CheckError struct {
+	Class   string // one of the ErrorClass values
+	Message string
+	At      time.Time
+	Since   time.Time // first failed check of the outage; zero when not known
+	Count   int       // failed checks of the outage so far, with Since
+}
+
+// CapturedHeaders are the response headers the checker captures for
+// diagnostics, e.g. Server, Via and X-Cache, as of the last check with a
+// response
+type CapturedHeaders struct {
+	Headers map[string]string // by canonical name; headers the response lacked are left out
+	Changed time.Time         // when Headers last changed
+}
+
+// EndpointData is everything stored about one endpoint. Zero times mean the
+// corresponding check has not been recorded yet.
+type EndpointData struct {
+	Endpoint      string
+	StatusCode    int
+	HasStatus     bool
+	StatusUpdated time.Time
+	SSLExpiration time.Time
+	SSLUpdated    time.Time
+	CertInfo      *CertInfo
+	CertLevel     string // alert level of the certificate, one of the CertLevel values; "" when stored without one
+	HeaderAudit   *HeaderAudit
+	Captured      *CapturedHeaders // nil until headers are captured
+	Uptime        []Uptime         // the UptimeWindows computed so far, in their order
+	Error         *CheckError      // set while the last status check is not up
+	Tags          []string         // tags of the endpoints file, e.g. "prod", "payments"
+	Name          string           // display name of the endpoints file, "" without one
+	Description   string           // description of the endpoints file, "" without one
+	RemoteAddr    string           // IP and port that served the last status check, "" when it got no connection
+	CheckedBy     string           // CHECKER_ID of the checker instance that wrote the last status result
+	SSLCheckedBy  string           // CHECKER_ID of the checker instance that wrote the last certificate
+	InMaintenance bool             // the last status check fell in a MaintenanceWindow
+	// PausedBySchedule is set while the endpoint's schedule keeps the
+	// checker from checking it, so its last check is old but not stale
+	PausedBySchedule bool
+}
+
+// PublicTag is the tag of the endpoints shown on the dashboard's public
+// status page; the endpoints file's public=true option sets it
+const PublicTag = "public"
+
+// NormalizeEndpoint turns an endpoints file entry into the URL results are
+// stored under: surrounding whitespace is dropped, https:// is assumed
+// when no scheme is given and an internationalized host name is converted
+// to its ASCII form, e.g. https://xn--mnchen-3ya.example for
+// https://münchen.example, so it can be dialed and keys stay ASCII. A host
+// name that cannot be converted is kept, failing its checks.
+func NormalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimSpace(endpoint)
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	prefix, host, suffix := splitEndpointHost(endpoint)
+	if ascii, err := hostToASCII(host); err == nil {
+		endpoint = prefix + ascii + suffix
+	}
+	return endpoint
+}
+
+// Result is one check outcome, written together with others by SaveResults.
+// A status check sets HasStatus, an SSL check sets Cert, and HeaderAudit is
+// set when the response headers were audited.
+type Result struct {
+	Endpoint   string
+	CheckedAt  time.Time
+	HasStatus  bool
+	StatusCode int
+	Latency    time.Duration // how long the status check took
+	Error      *CheckError   // why the status check was not up; a status result without one clears the stored error
+	Tags       []string      // replace the stored tags with every status result, nil removing them
+	Name       string        // replaces the stored display name with every status result, "" removing it
+	// Description replaces the stored description like Name
+	Description string
+	// RemoteAddr is the IP and port of the connection the status check
+	// used, "" when it got none; it replaces the stored address like Name
+	RemoteAddr string
+	// CheckedBy is the CHECKER_ID of the checker instance that produced the
+	// result, stored with its status and its certificate alike; "" for
+	// results no checker produced, such as pushed ones
+	CheckedBy string
+	// InMaintenance marks a result checked during a MaintenanceWindow; like
+	// Tags it is stored with every status result
+	InMaintenance bool
+	Cert          *CertInfo
+	// CertLevel is the alert level of Cert, from NextCertLevel, stored with
+	// it; "" stores the level of its expiry at CheckedAt
+	CertLevel   string
+	HeaderAudit *HeaderAudit
+	// Captured replaces the stored captured headers; nil leaves them, so
+	// headers that did not change need not be written again
+	Captured *CapturedHeaders
+	// PausedBySchedule marks a status cycle that skipped the endpoint
+	// outside its schedule. Such a result carries nothing else and keeps
+	// the endpoint from expiring; the next status result clears it.
+	PausedBySchedule bool
+}
+
+// certLevel is the alert level stored with Cert
+func (r Result) certLevel() string {
+	if r.CertLevel != "" {
+		return r.CertLevel
+	}
+	return CertLevel(r.Cert.NotAfter, r.CheckedAt)
+}
+
+// HistoryEntry is one recorded status check
+type HistoryEntry struct {
+	CheckedAt  time.Time
+	StatusCode int
+	Latency    time.Duration
+}
+
+// SSLObservation records a certificate seen with a new NotAfter
+type SSLObservation struct {
+	ObservedAt  time.Time
+	NotAfter    time.Time
+	Fingerprint string
+}
+
+// SSLHistoryLimit caps the renewal history kept per endpoint; at one renewal
+// every 60-90 days that covers well over a decade
+const SSLHistoryLimit = 100
+
+func newSSLObservation(cert CertInfo, observedAt time.Time) SSLObservation {
+	return SSLObservation{ObservedAt: observedAt, NotAfter: cert.NotAfter, Fingerprint: cert.Fingerprint}
+}
+
+// HistoryRetention bounds the status history kept per endpoint. A zero
+// field does not limit.
+type HistoryRetention struct {
+	Count  int           // keep at most this many entries
+	MaxAge time.Duration // drop entries older than this
+}
+
+// DefaultHistoryRetention keeps a day of history at the default one-minute interval
+var DefaultHistoryRetention = HistoryRetention{Count: 1440}
+
+// ParseHistoryRetention parses an entry count ("1440") or a Go duration ("168h")
+func ParseHistoryRetention(value string) (HistoryRetention, error) {
+	if count, err := strconv.Atoi(value); err == nil && count >= 0 {
+		return HistoryRetention{Count: count}, nil
+	}
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		return HistoryRetention{}, fmt.Errorf("invalid history retention %q: want an entry count or a duration", value)
+	}
+	return HistoryRetention{MaxAge: maxAge}, nil
+}
+
+// DefaultOperationTimeout bounds a single store call made by the checker or
+// a dashboard request, so a hung storage server fails fast instead of
+// blocking until TCP gives up
+const DefaultOperationTimeout = 2 * time.Second
+
+// Expiry is one entry of the SSL expiry index
+type Expiry struct {
+	Endpoint      string
+	SSLExpiration time.Time
+}
+
+// Store persists check results. Implementations must be safe for concurrent use.
+type Store interface {
+	// SetStatus records the HTTP status code of a check
+	SetStatus(ctx context.Context, endpoint string, statusCode int, checkedAt time.Time) error
+	// SetSSLExpiry records a certificate expiration and indexes it
+	SetSSLExpiry(ctx context.Context, endpoint string, expiration time.Time, checkedAt time.Time) error
+	// SetCertInfo records the full certificate details, including the expiration
+	SetCertInfo(ctx context.Context, endpoint string, cert CertInfo, checkedAt time.Time) error
+	// SaveResults writes the results of a check cycle in as few round trips
+	// as the backend allows
+	SaveResults(ctx context.Context, results []Result) error
+	// PruneSSLExpiryIndex drops index entries for endpoints not in keep and
+	// returns how many were removed
+	PruneSSLExpiryIndex(ctx context.Context, keep []string) (int, error)
+
+	// ListEndpoints returns every endpoint with stored results, in no particular order
+	ListEndpoints(ctx context.Context) ([]string, error)
+	// ListEndpointData returns the stored results for every endpoint, in no particular order
+	ListEndpointData(ctx context.Context) ([]EndpointData, error)
+	// GetEndpointData returns the stored results for one endpoint
+	GetEndpointData(ctx context.Context, endpoint string) (EndpointData, error)
+	// GetEndpointsData returns the stored results for each of endpoints, in
+	// the same order, in as few round trips as the backend allows
+	GetEndpointsData(ctx context.Context, endpoints []string) ([]EndpointData, error)
+	// ExpiringBefore returns indexed certificates expiring before t, soonest first
+	ExpiringBefore(ctx context.Context, t time.Time) ([]Expiry, error)
+	// StatusHistory returns the status checks recorded for endpoint at or
+	// after since, oldest first
+	StatusHistory(ctx context.Context, endpoint string, since time.Time) ([]HistoryEntry, error)
+	// SSLHistory returns the certificate observations with a NotAfter
+	// different from the one before, i.e. renewals, oldest first
+	SSLHistory(ctx context.Context, endpoint string) ([]SSLObservation, error)
+	// SaveLatencyRollups stores hourly rollups, replacing any for the same
+	// hours, and drops rollups older than LatencyRollupRetention
+	SaveLatencyRollups(ctx context.Context, endpoint string, rollups []LatencyRollup) error
+	// LatencyRollups returns the hourly rollups starting at or after since, oldest first
+	LatencyRollups(ctx context.Context, endpoint string, since time.Time) ([]LatencyRollup, error)
+	// SaveUptime replaces the stored uptime windows of an endpoint,
+	// leaving endpoints without stored results alone
+	SaveUptime(ctx context.Context, endpoint string, uptime []Uptime) error
+
+	// AddMaintenanceWindow validates w and stores it under a new random ID,
+	// which it returns with the stored window
+	AddMaintenanceWindow(ctx context.Context, w MaintenanceWindow) (MaintenanceWindow, error)
+	// DeleteMaintenanceWindow removes the window with id and reports
+	// whether there was one
+	DeleteMaintenanceWindow(ctx context.Context, id string) (bool, error)
+	// MaintenanceWindows returns every stored window, sorted by ID
+	MaintenanceWindows(ctx context.Context) ([]MaintenanceWindow, error)
+
+	// SaveHeartbeat records that a checker finished a check cycle, and with
+	// a CheckerID that this instance did, forgetting the instances not heard
+	// from within CheckerRetention
+	SaveHeartbeat(ctx context.Context, heartbeat Heartbeat) error
+	// Heartbeat returns the last heartbeat of any checker, the zero
+	// Heartbeat when none was saved
+	Heartbeat(ctx context.Context) (Heartbeat, error)
+	// Checkers returns the checker instances whose last heartbeat was at or
+	// after since, the most recent first
+	Checkers(ctx context.Context, since time.Time) ([]CheckerHeartbeat, error)
+
+	Ping(ctx context.Context) error
+	Close() error
+}
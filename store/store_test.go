@@ -0,0 +1,1240 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// forEachStore runs a test against every Store implementation
+func forEachStore(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("redis", func(t *testing.T) {
+		s, _ := newTestRedisStore(t)
+		test(t, s)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, newTestPostgresStore(t))
+	})
+}
+
+func newTestRedisStore(t testing.TB) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	t.Cleanup(func() { s.Close() })
+	return s, mr
+}
+
+// newTestPostgresStore connects to TEST_DATABASE_URL with empty tables,
+// skipping the test when it is not set
+func newTestPostgresStore(t *testing.T) *PostgresStore {
+	t.Helper()
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping PostgreSQL test")
+	}
+
+	ctx := context.Background()
+	s, err := OpenPostgres(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("OpenPostgres() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if _, err := s.db.ExecContext(ctx, `TRUNCATE endpoints, status_history, ssl_history, maintenance_windows, checkers`); err != nil {
+		t.Fatalf("failed to reset tables: %v", err)
+	}
+	return s
+}
+
+// TestStatusRoundTrip tests storing and reading HTTP status results
+func TestStatusRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		checkedAt := time.Unix(1700000000, 0)
+
+		if err := s.SetStatus(ctx, "http://example.com", 503, checkedAt); err != nil {
+			t.Fatalf("SetStatus() error = %v", err)
+		}
+
+		data, err := s.GetEndpointData(ctx, "http://example.com")
+		if err != nil {
+			t.Fatalf("GetEndpointData() error = %v", err)
+		}
+		if !data.HasStatus || data.StatusCode != 503 {
+			t.Errorf("StatusCode = %d (HasStatus %v), want 503", data.StatusCode, data.HasStatus)
+		}
+		if !data.StatusUpdated.Equal(checkedAt) {
+			t.Errorf("StatusUpdated = %v, want %v", data.StatusUpdated, checkedAt)
+		}
+
+		unknown, err := s.GetEndpointData(ctx, "https://unknown.example.com")
+		if err != nil {
+			t.Fatalf("GetEndpointData() error = %v", err)
+		}
+		if unknown.HasStatus || !unknown.StatusUpdated.IsZero() || unknown.CertInfo != nil {
+			t.Errorf("unknown endpoint has data: %+v", unknown)
+		}
+	})
+}
+
+// TestCertInfoRoundTrip tests storing and reading certificate details
+func TestCertInfoRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		checkedAt := time.Unix(1700000000, 0)
+		cert := CertInfo{
+			NotBefore:    time.Unix(1690000000, 0).UTC(),
+			NotAfter:     time.Unix(1710000000, 0).UTC(),
+			Subject:      "CN=example.com",
+			Issuer:       "CN=Test CA",
+			SerialNumber: "2a",
+			Fingerprint:  "abcdef",
+			State:        CertStateValid,
+		}
+
+		if err := s.SetCertInfo(ctx, "https://example.com", cert, checkedAt); err != nil {
+			t.Fatalf("SetCertInfo() error = %v", err)
+		}
+
+		data, err := s.GetEndpointData(ctx, "https://example.com")
+		if err != nil {
+			t.Fatalf("GetEndpointData() error = %v", err)
+		}
+		if !data.SSLExpiration.Equal(cert.NotAfter) {
+			t.Errorf("SSLExpiration = %v, want %v", data.SSLExpiration, cert.NotAfter)
+		}
+		if !data.SSLUpdated.Equal(checkedAt) {
+			t.Errorf("SSLUpdated = %v, want %v", data.SSLUpdated, checkedAt)
+		}
+		if data.CertInfo == nil || *data.CertInfo != cert {
+			t.Errorf("CertInfo = %+v, want %+v", data.CertInfo, cert)
+		}
+	})
+}
+
+// TestHeaderAuditRoundTrip tests that a new audit replaces the previous one
+func TestHeaderAuditRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+
+		first := HeaderAudit{
+			Headers: map[string]string{"X-Content-Type-Options": "nosniff"},
+			Updated: time.Unix(1700000000, 0),
+		}
+		second := HeaderAudit{
+			Headers:  map[string]string{"Strict-Transport-Security": "max-age=60"},
+			Failures: []string{"Strict-Transport-Security max-age 60 below 15552000", "X-Content-Type-Options missing"},
+			Updated:  time.Unix(1700000060, 0),
+		}
+		for _, audit := range []HeaderAudit{first, second} {
+			if err := s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: audit.Updated, HeaderAudit: &audit}}); err != nil {
+				t.Fatalf("SaveResults() error = %v", err)
+			}
+		}
+
+		data, err := s.GetEndpointData(ctx, endpoint)
+		if err != nil {
+			t.Fatalf("GetEndpointData() error = %v", err)
+		}
+		audit := data.HeaderAudit
+		if audit == nil {
+			t.Fatal("HeaderAudit = nil")
+		}
+		if audit.Passed() {
+			t.Error("Passed() = true, want false")
+		}
+		if len(audit.Failures) != 2 {
+			t.Errorf("Failures = %q", audit.Failures)
+		}
+		if len(audit.Headers) != 1 || audit.Headers["Strict-Transport-Security"] != "max-age=60" {
+			t.Errorf("Headers = %v", audit.Headers)
+		}
+		if !audit.Updated.Equal(second.Updated) {
+			t.Errorf("Updated = %v, want %v", audit.Updated, second.Updated)
+		}
+	})
+}
+
+// TestCheckErrorRoundTrip tests storing the error of a failed check, with
+// the outage it belongs to, and clearing it with the next check that is up
+func TestCheckErrorRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		failedAt := time.Unix(1700000000, 0).UTC()
+		checkError := CheckError{Class: ErrorClassTimeout, Message: `Get "https://example.com": context deadline exceeded <b>`, At: failedAt}
+		stillFailing := CheckError{Class: ErrorClassHTTP, Message: "HTTP 503 Service Unavailable", At: failedAt.Add(2 * time.Second), Since: failedAt, Count: 2}
+
+		steps := []struct {
+			name   string
+			result Result
+			want   *CheckError
+		}{
+			{"failed", Result{Endpoint: endpoint, CheckedAt: failedAt, HasStatus: true, StatusCode: 0, Error: &checkError}, &checkError},
+			{"SSL check keeps it", Result{Endpoint: endpoint, CheckedAt: failedAt.Add(time.Second), Cert: &CertInfo{NotAfter: failedAt.Add(time.Hour), State: CertStateValid}}, &checkError},
+			{"still failing", Result{Endpoint: endpoint, CheckedAt: failedAt.Add(2 * time.Second), HasStatus: true, StatusCode: 503, Error: &stillFailing}, &stillFailing},
+			{"up", Result{Endpoint: endpoint, CheckedAt: failedAt.Add(time.Minute), HasStatus: true, StatusCode: 200}, nil},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			switch {
+			case step.want == nil && data.Error != nil:
+				t.Errorf("%s: Error = %+v, want none", step.name, *data.Error)
+			case step.want != nil && (data.Error == nil || *data.Error != *step.want):
+				t.Errorf("%s: Error = %+v, want %+v", step.name, data.Error, *step.want)
+			}
+		}
+	})
+}
+
+// TestTagsRoundTrip tests that status results replace the stored tags,
+// display name and description and SSL results keep them
+func TestTagsRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		now := time.Unix(1700000000, 0).UTC()
+
+		steps := []struct {
+			name     string
+			result   Result
+			want     []string
+			wantName string
+			wantDesc string
+		}{
+			{"tagged", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, Tags: []string{"prod", "payments"}, Name: "Payments API", Description: "Card payments for the EU shops"}, []string{"prod", "payments"}, "Payments API", "Card payments for the EU shops"},
+			{"SSL check keeps them", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Second), Cert: &CertInfo{NotAfter: now.Add(time.Hour), State: CertStateValid}}, []string{"prod", "payments"}, "Payments API", "Card payments for the EU shops"},
+			{"retagged", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 200, Tags: []string{"staging"}, Name: "Payments"}, []string{"staging"}, "Payments", ""},
+			{"untagged", Result{Endpoint: endpoint, CheckedAt: now.Add(2 * time.Minute), HasStatus: true, StatusCode: 200}, nil, "", ""},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			if !slices.Equal(data.Tags, step.want) {
+				t.Errorf("%s: Tags = %q, want %q", step.name, data.Tags, step.want)
+			}
+			if data.Name != step.wantName {
+				t.Errorf("%s: Name = %q, want %q", step.name, data.Name, step.wantName)
+			}
+			if data.Description != step.wantDesc {
+				t.Errorf("%s: Description = %q, want %q", step.name, data.Description, step.wantDesc)
+			}
+		}
+	})
+}
+
+// TestRemoteAddrRoundTrip tests that status results replace the stored
+// remote address, SSL results keep it and store their own
+func TestRemoteAddrRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		now := time.Unix(1700000000, 0).UTC()
+
+		steps := []struct {
+			name     string
+			result   Result
+			want     string
+			wantCert string
+		}{
+			{"status", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, RemoteAddr: "192.0.2.10:443"}, "192.0.2.10:443", ""},
+			{"SSL check", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Second), Cert: &CertInfo{NotAfter: now.Add(time.Hour), State: CertStateValid, RemoteAddr: "[2001:db8::1]:443"}}, "192.0.2.10:443", "[2001:db8::1]:443"},
+			{"other IP", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 503, RemoteAddr: "192.0.2.11:443"}, "192.0.2.11:443", "[2001:db8::1]:443"},
+			{"no connection", Result{Endpoint: endpoint, CheckedAt: now.Add(2 * time.Minute), HasStatus: true, StatusCode: 0}, "", "[2001:db8::1]:443"},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			if data.RemoteAddr != step.want {
+				t.Errorf("%s: RemoteAddr = %q, want %q", step.name, data.RemoteAddr, step.want)
+			}
+			var certAddr string
+			if data.CertInfo != nil {
+				certAddr = data.CertInfo.RemoteAddr
+			}
+			if certAddr != step.wantCert {
+				t.Errorf("%s: CertInfo.RemoteAddr = %q, want %q", step.name, certAddr, step.wantCert)
+			}
+		}
+	})
+}
+
+// TestCheckedByRoundTrip tests that status and SSL results each store the
+// checker instance that produced them
+func TestCheckedByRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		now := time.Unix(1700000000, 0).UTC()
+
+		steps := []struct {
+			name    string
+			result  Result
+			want    string
+			wantSSL string
+		}{
+			{"status", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, CheckedBy: "eu-west"}, "eu-west", ""},
+			{"SSL check", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Second), Cert: &CertInfo{NotAfter: now.Add(time.Hour), State: CertStateValid}, CheckedBy: "us-east"}, "eu-west", "us-east"},
+			{"pushed status", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 200}, "", "us-east"},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			if data.CheckedBy != step.want || data.SSLCheckedBy != step.wantSSL {
+				t.Errorf("%s: CheckedBy = %q, SSLCheckedBy = %q, want %q and %q", step.name, data.CheckedBy, data.SSLCheckedBy, step.want, step.wantSSL)
+			}
+		}
+	})
+}
+
+// TestCapturedHeadersRoundTrip tests that captured headers are replaced
+// by results carrying them and kept by the others
+func TestCapturedHeadersRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		now := time.Unix(1700000000, 0).UTC()
+		cdn := CapturedHeaders{Headers: map[string]string{"Server": "cloudflare", "X-Cache": "MISS"}, Changed: now}
+		origin := CapturedHeaders{Headers: map[string]string{}, Changed: now.Add(2 * time.Minute)}
+
+		steps := []struct {
+			name   string
+			result Result
+			want   *CapturedHeaders
+		}{
+			{"none captured", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200}, nil},
+			{"captured", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200, Captured: &cdn}, &cdn},
+			{"unchanged", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Minute), HasStatus: true, StatusCode: 0}, &cdn},
+			{"changed", Result{Endpoint: endpoint, CheckedAt: now.Add(2 * time.Minute), HasStatus: true, StatusCode: 502, Captured: &origin}, &origin},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			switch {
+			case step.want == nil && data.Captured != nil:
+				t.Errorf("%s: Captured = %+v, want none", step.name, *data.Captured)
+			case step.want != nil && (data.Captured == nil || !maps.Equal(data.Captured.Headers, step.want.Headers) || !data.Captured.Changed.Equal(step.want.Changed)):
+				t.Errorf("%s: Captured = %+v, want %+v", step.name, data.Captured, *step.want)
+			}
+		}
+	})
+}
+
+// TestPausedBySchedule tests that a paused result marks the endpoint,
+// leaving its last status, until the next status result
+func TestPausedBySchedule(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		now := time.Unix(1700000000, 0).UTC()
+
+		steps := []struct {
+			name       string
+			result     Result
+			wantPaused bool
+		}{
+			{"checked", Result{Endpoint: endpoint, CheckedAt: now, HasStatus: true, StatusCode: 200}, false},
+			{"paused", Result{Endpoint: endpoint, CheckedAt: now.Add(time.Minute), PausedBySchedule: true}, true},
+			{"still paused", Result{Endpoint: endpoint, CheckedAt: now.Add(2 * time.Minute), PausedBySchedule: true}, true},
+			{"checked again", Result{Endpoint: endpoint, CheckedAt: now.Add(3 * time.Minute), HasStatus: true, StatusCode: 503}, false},
+		}
+		for _, step := range steps {
+			if err := s.SaveResults(ctx, []Result{step.result}); err != nil {
+				t.Fatalf("%s: SaveResults() error = %v", step.name, err)
+			}
+			data, err := s.GetEndpointData(ctx, endpoint)
+			if err != nil {
+				t.Fatalf("%s: GetEndpointData() error = %v", step.name, err)
+			}
+			if data.PausedBySchedule != step.wantPaused {
+				t.Errorf("%s: PausedBySchedule = %v, want %v", step.name, data.PausedBySchedule, step.wantPaused)
+			}
+			if !data.HasStatus || data.StatusUpdated.After(step.result.CheckedAt) {
+				t.Errorf("%s: status = %d at %v, want the last status check kept", step.name, data.StatusCode, data.StatusUpdated)
+			}
+		}
+	})
+}
+
+// TestListEndpoints tests endpoint discovery
+func TestListEndpoints(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now()
+
+		s.SetStatus(ctx, "http://a.example.com", 200, now)
+		s.SetStatus(ctx, "https://b.example.com", 200, now)
+		s.SetSSLExpiry(ctx, "https://b.example.com", now.Add(time.Hour), now)
+		s.SetSSLExpiry(ctx, "https://c.example.com", now.Add(time.Hour), now)
+
+		endpoints, err := s.ListEndpoints(ctx)
+		if err != nil {
+			t.Fatalf("ListEndpoints() error = %v", err)
+		}
+		sort.Strings(endpoints)
+		want := []string{"http://a.example.com", "https://b.example.com", "https://c.example.com"}
+		if len(endpoints) != len(want) {
+			t.Fatalf("ListEndpoints() = %v, want %v", endpoints, want)
+		}
+		for i := range want {
+			if endpoints[i] != want[i] {
+				t.Errorf("ListEndpoints()[%d] = %s, want %s", i, endpoints[i], want[i])
+			}
+		}
+	})
+}
+
+// TestSSLExpiryIndex tests the expiring-soon index and its pruning
+func TestSSLExpiryIndex(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Unix(1700000000, 0).UTC()
+		day := 24 * time.Hour
+
+		s.SetSSLExpiry(ctx, "https://soon.example.com", now.Add(5*day), now)
+		s.SetSSLExpiry(ctx, "https://expired.example.com", now.Add(-day), now)
+		s.SetSSLExpiry(ctx, "https://later.example.com", now.Add(60*day), now)
+		s.SetSSLExpiry(ctx, "https://removed.example.com", now.Add(2*day), now)
+
+		removed, err := s.PruneSSLExpiryIndex(ctx, []string{
+			"https://soon.example.com", "https://expired.example.com", "https://later.example.com",
+		})
+		if err != nil {
+			t.Fatalf("PruneSSLExpiryIndex() error = %v", err)
+		}
+		if removed != 1 {
+			t.Errorf("PruneSSLExpiryIndex() removed %d, want 1", removed)
+		}
+
+		expiring, err := s.ExpiringBefore(ctx, now.Add(30*day))
+		if err != nil {
+			t.Fatalf("ExpiringBefore() error = %v", err)
+		}
+		want := []Expiry{
+			{"https://expired.example.com", now.Add(-day)},
+			{"https://soon.example.com", now.Add(5 * day)},
+		}
+		if len(expiring) != len(want) {
+			t.Fatalf("ExpiringBefore() = %v, want %v", expiring, want)
+		}
+		for i := range want {
+			if expiring[i].Endpoint != want[i].Endpoint || !expiring[i].SSLExpiration.Equal(want[i].SSLExpiration) {
+				t.Errorf("ExpiringBefore()[%d] = %v, want %v", i, expiring[i], want[i])
+			}
+		}
+	})
+}
+
+// TestRedisKeyLayout pins the hash layout so other readers keep working
+func TestRedisKeyLayout(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+	endpoint := "https://example.com"
+
+	s.SetStatus(ctx, endpoint, 200, checkedAt)
+	s.SetCertInfo(ctx, endpoint, CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}, checkedAt)
+
+	for field, want := range map[string]string{
+		"status":         "200",
+		"status_updated": "1700000000",
+		"ssl_expiry":     "1710000000",
+		"ssl_updated":    "1700000000",
+		"cert_state":     CertStateValid,
+	} {
+		if got := mr.HGet("endpoint:"+endpoint, field); got != want {
+			t.Errorf("endpoint:%s %s = %q, want %q", endpoint, field, got, want)
+		}
+	}
+	want := []string{"endpoint:" + endpoint, EndpointRegistryKey, "history:ssl:" + endpoint, SSLExpiryIndexKey}
+	if keys := mr.Keys(); !slices.Equal(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	if ok, err := mr.SIsMember(EndpointRegistryKey, endpoint); err != nil || !ok {
+		t.Errorf("%s missing %s (%v)", EndpointRegistryKey, endpoint, err)
+	}
+	if score, err := mr.ZScore(SSLExpiryIndexKey, endpoint); err != nil || score != 1710000000 {
+		t.Errorf("%s score = %v (%v), want 1710000000", SSLExpiryIndexKey, score, err)
+	}
+}
+
+// TestResultTTL tests that endpoint hashes age out and status writes never shorten the SSL TTL
+func TestResultTTL(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	s.SetResultTTL(10*time.Minute, 10*time.Hour)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}
+	key := Keys{}.Endpoint("https://example.com")
+
+	s.SetStatus(ctx, "https://example.com", 200, checkedAt)
+	if ttl := mr.TTL(key); ttl != 10*time.Minute {
+		t.Errorf("TTL after status = %v, want 10m", ttl)
+	}
+	s.SetCertInfo(ctx, "https://example.com", cert, checkedAt)
+	if ttl := mr.TTL(key); ttl != 10*time.Hour {
+		t.Errorf("TTL after SSL = %v, want 10h", ttl)
+	}
+	mr.FastForward(time.Hour)
+	s.SetStatus(ctx, "https://example.com", 200, checkedAt)
+	if ttl := mr.TTL(key); ttl != 9*time.Hour {
+		t.Errorf("TTL after status refresh = %v, want 9h", ttl)
+	}
+
+	s.SaveResults(ctx, []Result{{Endpoint: "http://plain.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 200}})
+	if ttl := mr.TTL(Keys{}.Endpoint("http://plain.example.com")); ttl != 10*time.Minute {
+		t.Errorf("TTL after SaveResults = %v, want 10m", ttl)
+	}
+
+	// An endpoint paused by its schedule does not age out
+	mr.FastForward(5 * time.Minute)
+	s.SaveResults(ctx, []Result{{Endpoint: "http://plain.example.com", CheckedAt: checkedAt, PausedBySchedule: true}})
+	if ttl := mr.TTL(Keys{}.Endpoint("http://plain.example.com")); ttl != 10*time.Minute {
+		t.Errorf("TTL after a paused result = %v, want 10m", ttl)
+	}
+
+	mr.FastForward(9*time.Hour + time.Second)
+	data, err := s.ListEndpointData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("ListEndpointData() after TTL = %v, want none", data)
+	}
+}
+
+// TestMemoryResultTTL tests that endpoints of the memory store age out like
+// the Redis hashes, with their history, expiry index entry and rollups
+func TestMemoryResultTTL(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	s.SetResultTTL(10*time.Minute, 10*time.Hour)
+	ctx := context.Background()
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}
+
+	s.SaveResults(ctx, []Result{
+		{Endpoint: "https://example.com", CheckedAt: now, HasStatus: true, StatusCode: 200, Cert: &cert},
+		{Endpoint: "http://plain.example.com", CheckedAt: now, HasStatus: true, StatusCode: 200},
+	})
+	s.SaveLatencyRollups(ctx, "https://example.com", []LatencyRollup{{Hour: now.Truncate(time.Hour), Count: 1}})
+	now = now.Add(time.Hour)
+	s.SetStatus(ctx, "https://example.com", 200, now)
+	endpoints, _ := s.ListEndpoints(ctx)
+	if !slices.Equal(endpoints, []string{"https://example.com"}) {
+		t.Errorf("ListEndpoints() after the status TTL = %v, want the HTTPS endpoint kept by its SSL TTL", endpoints)
+	}
+	if data, _ := s.GetEndpointData(ctx, "http://plain.example.com"); data.HasStatus {
+		t.Errorf("GetEndpointData() after the status TTL = %+v, want nothing", data)
+	}
+
+	now = now.Add(9*time.Hour + time.Second)
+	if data, _ := s.ListEndpointData(ctx); len(data) != 0 {
+		t.Errorf("ListEndpointData() after TTL = %v, want none", data)
+	}
+	if history, _ := s.StatusHistory(ctx, "https://example.com", time.Time{}); len(history) != 0 {
+		t.Errorf("StatusHistory() after TTL = %v, want none", history)
+	}
+	s.SetStatus(ctx, "https://example.com", 503, now)
+	if data, _ := s.GetEndpointData(ctx, "https://example.com"); data.StatusCode != 503 || data.CertInfo != nil {
+		t.Errorf("GetEndpointData() written after TTL = %+v, want only the new status", data)
+	}
+	if expiring, _ := s.ExpiringBefore(ctx, cert.NotAfter); len(expiring) != 0 {
+		t.Errorf("ExpiringBefore() after TTL = %v, want none", expiring)
+	}
+	if rollups, _ := s.LatencyRollups(ctx, "https://example.com", time.Time{}); len(rollups) != 0 {
+		t.Errorf("LatencyRollups() after TTL = %v, want none", rollups)
+	}
+}
+
+// TestEndpointRegistry tests syncing, seeding and the SCAN fallback
+func TestEndpointRegistry(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+
+	s.SetStatus(ctx, "https://kept.example.com", 200, checkedAt)
+	s.SetStatus(ctx, "https://removed.example.com", 200, checkedAt)
+
+	removed, err := s.SyncEndpointRegistry(ctx, []string{"https://kept.example.com", "https://new.example.com"})
+	if err != nil {
+		t.Fatalf("SyncEndpointRegistry() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("SyncEndpointRegistry() removed %d, want 1", removed)
+	}
+	endpoints, _ := s.ListEndpoints(ctx)
+	sort.Strings(endpoints)
+	if want := []string{"https://kept.example.com", "https://new.example.com"}; !slices.Equal(endpoints, want) {
+		t.Errorf("ListEndpoints() = %v, want %v", endpoints, want)
+	}
+
+	// The SCAN fallback finds every endpoint hash, registered or not
+	s.SetScanDiscovery(true)
+	endpoints, _ = s.ListEndpoints(ctx)
+	sort.Strings(endpoints)
+	if want := []string{"https://kept.example.com", "https://removed.example.com"}; !slices.Equal(endpoints, want) {
+		t.Errorf("ListEndpoints() with SCAN = %v, want %v", endpoints, want)
+	}
+
+	if seeded, err := s.SeedEndpointRegistry(ctx); err != nil || seeded != 0 {
+		t.Errorf("SeedEndpointRegistry() with existing registry = %d, %v; want 0, nil", seeded, err)
+	}
+	mr.Del(EndpointRegistryKey)
+	if seeded, err := s.SeedEndpointRegistry(ctx); err != nil || seeded != 2 {
+		t.Errorf("SeedEndpointRegistry() = %d, %v; want 2, nil", seeded, err)
+	}
+}
+
+// TestAddRemoveEndpoint tests registering an endpoint and removing it with
+// all of its data
+func TestAddRemoveEndpoint(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}
+
+	for _, want := range []bool{true, false} {
+		if added, err := s.AddEndpoint(ctx, "https://new.example.com"); err != nil || added != want {
+			t.Errorf("AddEndpoint() = %v, %v; want %v, nil", added, err, want)
+		}
+	}
+	s.AddEndpoint(ctx, "https://kept.example.com")
+	for _, endpoint := range []string{"https://new.example.com", "https://kept.example.com"} {
+		s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: checkedAt, HasStatus: true, StatusCode: 200}})
+		s.SetCertInfo(ctx, endpoint, cert, checkedAt)
+	}
+	mr.Set("status:https://new.example.com", "200")
+	mr.HSet(AlertStateKey, "https://new.example.com", "{}")
+
+	if removed, err := s.RemoveEndpoint(ctx, "https://new.example.com"); err != nil || !removed {
+		t.Errorf("RemoveEndpoint() = %v, %v; want true, nil", removed, err)
+	}
+	if removed, err := s.RemoveEndpoint(ctx, "https://new.example.com"); err != nil || removed {
+		t.Errorf("RemoveEndpoint() again = %v, %v; want false, nil", removed, err)
+	}
+
+	endpoints, _ := s.ListEndpoints(ctx)
+	if want := []string{"https://kept.example.com"}; !slices.Equal(endpoints, want) {
+		t.Errorf("ListEndpoints() = %v, want %v", endpoints, want)
+	}
+	var left []string
+	for _, key := range mr.Keys() {
+		if strings.Contains(key, "new.example.com") {
+			left = append(left, key)
+		}
+	}
+	if left != nil {
+		t.Errorf("keys left after RemoveEndpoint: %v", left)
+	}
+	if members, _ := mr.ZMembers(SSLExpiryIndexKey); !slices.Equal(members, []string{"https://kept.example.com"}) {
+		t.Errorf("SSL expiry index = %v, want only kept.example.com", members)
+	}
+	if mr.HGet(AlertStateKey, "https://new.example.com") != "" {
+		t.Error("alert state left after RemoveEndpoint")
+	}
+}
+
+// BenchmarkListEndpoints compares the registry with SCAN discovery on a
+// shared Redis holding 200 endpoints among 20000 unrelated keys
+func BenchmarkListEndpoints(b *testing.B) {
+	s, mr := newTestRedisStore(b)
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		s.SetStatus(ctx, fmt.Sprintf("https://%d.example.com", i), 200, time.Now())
+	}
+	for i := 0; i < 20000; i++ {
+		mr.Set(fmt.Sprintf("other:%d", i), "x")
+	}
+
+	for _, scan := range []bool{false, true} {
+		name := "registry"
+		if scan {
+			name = "scan"
+		}
+		b.Run(name, func(b *testing.B) {
+			s.SetScanDiscovery(scan)
+			for i := 0; i < b.N; i++ {
+				if _, err := s.ListEndpoints(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestGetEndpointsData tests that bulk reads keep the requested order
+func TestGetEndpointsData(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		checkedAt := time.Unix(1700000000, 0).UTC()
+		s.SaveResults(ctx, []Result{
+			{Endpoint: "https://a.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 200},
+			{Endpoint: "https://b.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 503},
+		})
+
+		requested := []string{"https://b.example.com", "https://unknown.example.com", "https://a.example.com"}
+		got, err := s.GetEndpointsData(ctx, requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(requested) {
+			t.Fatalf("GetEndpointsData() returned %d entries, want %d", len(got), len(requested))
+		}
+		for i, want := range []struct {
+			hasStatus  bool
+			statusCode int
+		}{{true, 503}, {false, 0}, {true, 200}} {
+			if got[i].Endpoint != requested[i] || got[i].HasStatus != want.hasStatus || got[i].StatusCode != want.statusCode {
+				t.Errorf("entry %d = %+v, want %s with status %d", i, got[i], requested[i], want.statusCode)
+			}
+		}
+
+		if got, err := s.GetEndpointsData(ctx, nil); err != nil || len(got) != 0 {
+			t.Errorf("GetEndpointsData(nil) = %v, %v, want nothing", got, err)
+		}
+	})
+}
+
+// roundTripCounter is a go-redis hook counting commands and pipelines sent
+type roundTripCounter struct {
+	n int
+}
+
+func (c *roundTripCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (c *roundTripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.n++
+		return next(ctx, cmd)
+	}
+}
+
+func (c *roundTripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.n++
+		return next(ctx, cmds)
+	}
+}
+
+// TestListEndpointDataRoundTrips tests that reading every endpoint takes two
+// round trips regardless of the number of endpoints
+func TestListEndpointDataRoundTrips(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	ctx := context.Background()
+	var results []Result
+	for i := 0; i < 500; i++ {
+		results = append(results, Result{Endpoint: fmt.Sprintf("https://%d.example.com", i), CheckedAt: time.Now(), HasStatus: true, StatusCode: 200})
+	}
+	if err := s.SaveResults(ctx, results); err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &roundTripCounter{}
+	s.client.AddHook(counter)
+	data, err := s.ListEndpointData(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 500 {
+		t.Errorf("ListEndpointData() returned %d endpoints, want 500", len(data))
+	}
+	if counter.n != 2 {
+		t.Errorf("ListEndpointData() took %d round trips, want 2", counter.n)
+	}
+
+	counter.n = 0
+	if _, err := s.GetEndpointsData(ctx, []string{"https://1.example.com", "https://2.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if counter.n != 1 {
+		t.Errorf("GetEndpointsData() took %d round trips, want 1", counter.n)
+	}
+}
+
+// BenchmarkListEndpointData compares the pipelined bulk read with reading
+// each endpoint hash on its own
+func BenchmarkListEndpointData(b *testing.B) {
+	s, _ := newTestRedisStore(b)
+	ctx := context.Background()
+	var results []Result
+	for i := 0; i < 500; i++ {
+		results = append(results, Result{Endpoint: fmt.Sprintf("https://%d.example.com", i), CheckedAt: time.Now(), HasStatus: true, StatusCode: 200})
+	}
+	if err := s.SaveResults(ctx, results); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("pipelined", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.ListEndpointData(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per-endpoint", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			endpoints, err := s.ListEndpoints(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, endpoint := range endpoints {
+				if _, err := s.GetEndpointData(ctx, endpoint); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// TestMigrateLegacyKeys tests moving the old one-key-per-value layout into endpoint hashes
+func TestMigrateLegacyKeys(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	legacy := "https://legacy.example.com"
+	current := "https://current.example.com"
+
+	mr.Set("status:"+legacy, "301")
+	mr.Set("status_updated:"+legacy, "1700000000")
+	mr.Set("ssl:"+legacy, "1710000000")
+	mr.Set("ssl_updated:"+legacy, "1700000100")
+	mr.HSet("cert_info:"+legacy, "not_before", "1690000000", "not_after", "1710000000", "fingerprint", "ab12", "state", CertStateValid)
+	mr.HSet("headers:"+legacy, "pass", "0", "failures", "X-Frame-Options missing", "updated", "1700000000", "header:X-Content-Type-Options", "nosniff")
+
+	// An endpoint already written by a new checker keeps its hash
+	s.SetStatus(ctx, current, 200, time.Unix(1700000500, 0))
+	mr.Set("status:"+current, "500")
+
+	keys := mr.Keys()
+	if migrated, err := s.migrateLegacyKeys(ctx, true); err != nil || migrated != 1 {
+		t.Errorf("migrateLegacyKeys() dry run = %d, %v; want 1, nil", migrated, err)
+	}
+	if after := mr.Keys(); !slices.Equal(after, keys) {
+		t.Errorf("dry run changed keys from %v to %v", keys, after)
+	}
+
+	migrated, err := s.migrateLegacyKeys(ctx, false)
+	if err != nil {
+		t.Fatalf("migrateLegacyKeys() error = %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("migrateLegacyKeys() = %d, want 1", migrated)
+	}
+
+	data, err := s.GetEndpointData(ctx, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.HasStatus || data.StatusCode != 301 || data.StatusUpdated.Unix() != 1700000000 {
+		t.Errorf("status = %d (%v) at %v, want 301 at 1700000000", data.StatusCode, data.HasStatus, data.StatusUpdated)
+	}
+	if data.SSLExpiration.Unix() != 1710000000 || data.SSLUpdated.Unix() != 1700000100 {
+		t.Errorf("SSL = %v updated %v", data.SSLExpiration, data.SSLUpdated)
+	}
+	if data.CertInfo == nil || data.CertInfo.Fingerprint != "ab12" || data.CertInfo.NotBefore.Unix() != 1690000000 {
+		t.Errorf("CertInfo = %+v", data.CertInfo)
+	}
+	if data.HeaderAudit == nil || data.HeaderAudit.Passed() || data.HeaderAudit.Headers["X-Content-Type-Options"] != "nosniff" {
+		t.Errorf("HeaderAudit = %+v", data.HeaderAudit)
+	}
+
+	if data, _ := s.GetEndpointData(ctx, current); data.StatusCode != 200 {
+		t.Errorf("current endpoint status = %d, want 200", data.StatusCode)
+	}
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, "endpoint:") && key != EndpointRegistryKey {
+			t.Errorf("legacy key %s left behind", key)
+		}
+	}
+
+	if migrated, err := s.migrateLegacyKeys(ctx, false); err != nil || migrated != 0 {
+		t.Errorf("second migrateLegacyKeys() = %d, %v; want 0, nil", migrated, err)
+	}
+}
+
+// TestSaveResults tests writing a whole cycle of results at once
+func TestSaveResults(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		checkedAt := time.Unix(1700000000, 0).UTC()
+		cert := CertInfo{
+			NotBefore: checkedAt.Add(-24 * time.Hour),
+			NotAfter:  checkedAt.Add(24 * time.Hour),
+			State:     CertStateValid,
+		}
+
+		err := s.SaveResults(ctx, []Result{
+			{Endpoint: "https://a.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 500},
+			{Endpoint: "https://a.example.com", CheckedAt: checkedAt.Add(time.Second), HasStatus: true, StatusCode: 200,
+				HeaderAudit: &HeaderAudit{Headers: map[string]string{"Server": "nginx"}, Updated: checkedAt}},
+			{Endpoint: "http://b.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 404},
+			{Endpoint: "https://a.example.com", CheckedAt: checkedAt, Cert: &cert},
+		})
+		if err != nil {
+			t.Fatalf("SaveResults() error = %v", err)
+		}
+
+		all, err := s.ListEndpointData(ctx)
+		if err != nil {
+			t.Fatalf("ListEndpointData() error = %v", err)
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Endpoint < all[j].Endpoint })
+		if len(all) != 2 {
+			t.Fatalf("ListEndpointData() returned %d endpoints, want 2", len(all))
+		}
+
+		b, a := all[0], all[1]
+		if b.Endpoint != "http://b.example.com" || b.StatusCode != 404 {
+			t.Errorf("b = %+v", b)
+		}
+		if a.StatusCode != 200 || !a.StatusUpdated.Equal(checkedAt.Add(time.Second)) {
+			t.Errorf("a status = %d at %v, want the last result", a.StatusCode, a.StatusUpdated)
+		}
+		if !a.SSLExpiration.Equal(cert.NotAfter) || a.CertInfo == nil || a.CertInfo.State != CertStateValid {
+			t.Errorf("a certificate = %v %+v", a.SSLExpiration, a.CertInfo)
+		}
+		if a.CertLevel != CertLevelCritical {
+			t.Errorf("a CertLevel = %q, want the level of its expiry %q", a.CertLevel, CertLevelCritical)
+		}
+		if a.HeaderAudit == nil || a.HeaderAudit.Headers["Server"] != "nginx" {
+			t.Errorf("a header audit = %+v", a.HeaderAudit)
+		}
+	})
+}
+
+// TestUpsertStatement tests the batched upsert SQL
+func TestUpsertStatement(t *testing.T) {
+	got := upsertStatement([]string{"endpoint", "status_code", "status_updated"}, 2)
+	want := "INSERT INTO endpoints (endpoint, status_code, status_updated) VALUES ($1, $2, $3), ($4, $5, $6)" +
+		" ON CONFLICT (endpoint) DO UPDATE SET status_code = EXCLUDED.status_code, status_updated = EXCLUDED.status_updated"
+	if got != want {
+		t.Errorf("upsertStatement() =\n%s\nwant\n%s", got, want)
+	}
+
+	got = insertStatement("status_history", []string{"endpoint", "checked_at"}, 1)
+	want = "INSERT INTO status_history (endpoint, checked_at) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if got != want {
+		t.Errorf("insertStatement() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestMigrationNames tests that embedded migrations are found in order
+func TestMigrationNames(t *testing.T) {
+	names, err := migrationNames()
+	if err != nil {
+		t.Fatalf("migrationNames() error = %v", err)
+	}
+	if len(names) == 0 || names[0] != "0001_init.sql" {
+		t.Errorf("migrationNames() = %v, want 0001_init.sql first", names)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("migrationNames() = %v, not sorted", names)
+	}
+}
+
+// TestCleanup tests purging data for endpoints that are no longer monitored
+func TestCleanup(t *testing.T) {
+	s, mr := newTestRedisStore(t)
+	ctx := context.Background()
+	checkedAt := time.Unix(1700000000, 0)
+	cert := CertInfo{NotAfter: time.Unix(1710000000, 0), State: CertStateValid}
+
+	for _, endpoint := range []string{"https://kept.example.com", "https://gone.example.com"} {
+		s.SetStatus(ctx, endpoint, 200, checkedAt)
+		s.SetCertInfo(ctx, endpoint, cert, checkedAt)
+	}
+	s.SaveResults(ctx, []Result{{Endpoint: "https://gone.example.com", CheckedAt: checkedAt, HasStatus: true, StatusCode: 200}})
+	mr.Set("status:https://legacy.example.com", "200")
+	mr.Set("ssl_updated:https://legacy.example.com", "1700000000")
+	mr.HSet("cert_info:https://legacy.example.com", "state", CertStateValid)
+	mr.Set("unrelated", "x")
+	s.SaveAlertStates(ctx, map[string]AlertState{
+		"https://kept.example.com": {Last: map[string]string{EventKindStatus: StatusUp}},
+		"https://gone.example.com": {Last: map[string]string{EventKindStatus: StatusDown}},
+	})
+
+	want := []string{
+		"alert_state https://gone.example.com",
+		"cert_info:https://legacy.example.com",
+		"endpoint:https://gone.example.com",
+		"endpoints_registry https://gone.example.com",
+		"histogram:latency:https://gone.example.com",
+		"history:ssl:https://gone.example.com",
+		"history:status:https://gone.example.com",
+		"ssl_expiry_index https://gone.example.com",
+		"ssl_updated:https://legacy.example.com",
+		"status:https://legacy.example.com",
+	}
+	keep := []string{"https://kept.example.com"}
+
+	removed, err := s.Cleanup(ctx, keep, true)
+	if err != nil {
+		t.Fatalf("Cleanup(dry run) error = %v", err)
+	}
+	sort.Strings(removed)
+	if !slices.Equal(removed, want) {
+		t.Errorf("Cleanup(dry run) = %v, want %v", removed, want)
+	}
+	if !mr.Exists("endpoint:https://gone.example.com") {
+		t.Error("dry run deleted data")
+	}
+
+	removed, err = s.Cleanup(ctx, keep, false)
+	if err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	sort.Strings(removed)
+	if !slices.Equal(removed, want) {
+		t.Errorf("Cleanup() = %v, want %v", removed, want)
+	}
+	keys := mr.Keys()
+	if want := []string{"alert_state", "endpoint:https://kept.example.com", "endpoints_registry", "history:ssl:https://kept.example.com", "ssl_expiry_index", "unrelated"}; !slices.Equal(keys, want) {
+		t.Errorf("keys after Cleanup() = %v, want %v", keys, want)
+	}
+	if members, _ := mr.Members(EndpointRegistryKey); !slices.Equal(members, keep) {
+		t.Errorf("%s = %v, want %v", EndpointRegistryKey, members, keep)
+	}
+	if members, _ := mr.ZMembers(SSLExpiryIndexKey); !slices.Equal(members, keep) {
+		t.Errorf("%s = %v, want %v", SSLExpiryIndexKey, members, keep)
+	}
+	if fields, _ := mr.HKeys(AlertStateKey); !slices.Equal(fields, keep) {
+		t.Errorf("%s = %v, want %v", AlertStateKey, fields, keep)
+	}
+}
+
+// TestStatusHistory tests recording and reading back status checks
+func TestStatusHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		start := time.Unix(1700000000, 0).UTC()
+		endpoint := "https://example.com"
+
+		var results []Result
+		for i, code := range []int{200, 200, 503, 200} {
+			results = append(results, Result{
+				Endpoint:   endpoint,
+				CheckedAt:  start.Add(time.Duration(i) * time.Minute),
+				HasStatus:  true,
+				StatusCode: code,
+				Latency:    120 * time.Millisecond,
+			})
+		}
+		// Save out of order, as concurrent cycles may
+		if err := s.SaveResults(ctx, results[2:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveResults(ctx, results[:2]); err != nil {
+			t.Fatal(err)
+		}
+
+		history, err := s.StatusHistory(ctx, endpoint, start.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("StatusHistory() error = %v", err)
+		}
+		want := []HistoryEntry{
+			{start.Add(time.Minute), 200, 120 * time.Millisecond},
+			{start.Add(2 * time.Minute), 503, 120 * time.Millisecond},
+			{start.Add(3 * time.Minute), 200, 120 * time.Millisecond},
+		}
+		if !slices.Equal(history, want) {
+			t.Errorf("StatusHistory() = %v, want %v", history, want)
+		}
+
+		if history, _ := s.StatusHistory(ctx, "https://other.example.com", start); len(history) != 0 {
+			t.Errorf("StatusHistory() of unknown endpoint = %v, want none", history)
+		}
+	})
+}
+
+// TestHistoryRetention tests trimming history by count and by age
+func TestHistoryRetention(t *testing.T) {
+	start := time.Unix(1700000000, 0).UTC()
+	tests := []struct {
+		name      string
+		retention HistoryRetention
+		want      int
+	}{
+		{"count", HistoryRetention{Count: 3}, 3},
+		{"age", HistoryRetention{MaxAge: 4 * time.Minute}, 5},
+		{"unlimited", HistoryRetention{}, 10},
+	}
+
+	for _, tt := range tests {
+		redisStore, _ := newTestRedisStore(t)
+		stores := map[string]interface {
+			Store
+			SetHistoryRetention(HistoryRetention)
+		}{"memory": NewMemoryStore(), "redis": redisStore}
+
+		for name, s := range stores {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				s.SetHistoryRetention(tt.retention)
+				ctx := context.Background()
+				for i := 0; i < 10; i++ {
+					s.SaveResults(ctx, []Result{{Endpoint: "https://example.com", CheckedAt: start.Add(time.Duration(i) * time.Minute), HasStatus: true, StatusCode: 200}})
+				}
+
+				history, err := s.StatusHistory(ctx, "https://example.com", start)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(history) != tt.want {
+					t.Fatalf("kept %d entries, want %d", len(history), tt.want)
+				}
+				if last := history[len(history)-1].CheckedAt; !last.Equal(start.Add(9 * time.Minute)) {
+					t.Errorf("newest entry at %v, want the last check", last)
+				}
+			})
+		}
+	}
+}
+
+// TestParseHistoryRetention tests the HISTORY_RETENTION formats
+func TestParseHistoryRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    HistoryRetention
+		wantErr bool
+	}{
+		{"1440", HistoryRetention{Count: 1440}, false},
+		{"0", HistoryRetention{}, false},
+		{"168h", HistoryRetention{MaxAge: 168 * time.Hour}, false},
+		{"-5", HistoryRetention{}, true},
+		{"a week", HistoryRetention{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseHistoryRetention(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHistoryRetention(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHistoryRetention(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNormalizeEndpoint tests the endpoint normalization shared by the checker and the dashboard
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"example.com", "https://example.com"},
+		{"  example.com/health ", "https://example.com/health"},
+		{"http://example.com", "http://example.com"},
+		{"https://example.com:8443", "https://example.com:8443"},
+		{"münchen.example", "https://xn--mnchen-3ya.example"},
+		{"https://MÜNCHEN.example:8443/größe?q=ü", "https://xn--mnchen-3ya.example:8443/größe?q=ü"},
+		{"https://user@bücher.example/", "https://user@xn--bcher-kva.example/"},
+		{"https://xn--mnchen-3ya.example", "https://xn--mnchen-3ya.example"},
+		{"https://[::1]:8443", "https://[::1]:8443"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEndpoint(tt.endpoint); got != tt.want {
+			t.Errorf("NormalizeEndpoint(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+// TestSSLHistory tests that only NotAfter changes are recorded as renewals
+func TestSSLHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		endpoint := "https://example.com"
+		day := 24 * time.Hour
+		start := time.Unix(1700000000, 0).UTC()
+		first := CertInfo{NotAfter: start.Add(90 * day), Fingerprint: "aa", State: CertStateValid}
+		renewed := CertInfo{NotAfter: start.Add(150 * day), Fingerprint: "bb", State: CertStateValid}
+
+		for i, cert := range []CertInfo{first, first, renewed, renewed} {
+			checkedAt := start.Add(time.Duration(i) * 30 * day)
+			if err := s.SaveResults(ctx, []Result{{Endpoint: endpoint, CheckedAt: checkedAt, Cert: &cert}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		history, err := s.SSLHistory(ctx, endpoint)
+		if err != nil {
+			t.Fatalf("SSLHistory() error = %v", err)
+		}
+		want := []SSLObservation{
+			{ObservedAt: start, NotAfter: first.NotAfter, Fingerprint: "aa"},
+			{ObservedAt: start.Add(60 * day), NotAfter: renewed.NotAfter, Fingerprint: "bb"},
+		}
+		if !slices.Equal(history, want) {
+			t.Errorf("SSLHistory() = %v, want %v", history, want)
+		}
+	})
+}
+
+// TestSSLHistoryLimit tests that the renewal history is capped
+func TestSSLHistoryLimit(t *testing.T) {
+	redisStore, _ := newTestRedisStore(t)
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "redis": redisStore} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Unix(1700000000, 0).UTC()
+			for i := 0; i < SSLHistoryLimit+5; i++ {
+				s.SetSSLExpiry(ctx, "https://example.com", start.Add(time.Duration(i)*time.Hour), start)
+			}
+
+			history, err := s.SSLHistory(ctx, "https://example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != SSLHistoryLimit {
+				t.Fatalf("kept %d observations, want %d", len(history), SSLHistoryLimit)
+			}
+			if got := history[0].NotAfter; !got.Equal(start.Add(5 * time.Hour)) {
+				t.Errorf("oldest kept NotAfter = %v, want the 6th observation", got)
+			}
+		})
+	}
+}
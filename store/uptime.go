@@ -0,0 +1,73 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// UptimeWindow is a rolling window uptime is reported for
+type UptimeWindow struct {
+	Name   string // "24h", also the suffix of the stored field
+	Length time.Duration
+}
+
+// UptimeWindows are the windows of ComputeUptime, shortest first. The
+// longest fits within LatencyRollupRetention.
+var UptimeWindows = []UptimeWindow{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// Uptime counts the checks of one window. Hours without checks, e.g. while
+// the checker was down, are unknown and not counted at all.
+type Uptime struct {
+	Window string
+	Checks int
+	Up     int // checks with a StatusUp status code
+}
+
+// Percent returns the share of up checks, false when the window has no checks
+func (u Uptime) Percent() (float64, bool) {
+	if u.Checks == 0 {
+		return 0, false
+	}
+	return float64(u.Up) * 100 / float64(u.Checks), true
+}
+
+// ComputeUptime sums the checks of the hourly rollups in each of
+// UptimeWindows, counting back from the start of the hour of now, so the
+// windows cover completed hours only
+func ComputeUptime(rollups []LatencyRollup, now time.Time) []Uptime {
+	currentHour := now.UTC().Truncate(time.Hour)
+	uptime := make([]Uptime, len(UptimeWindows))
+	for i, window := range UptimeWindows {
+		uptime[i].Window = window.Name
+		since := currentHour.Add(-window.Length)
+		for _, rollup := range rollups {
+			if !rollup.Hour.Before(since) && rollup.Hour.Before(currentHour) {
+				uptime[i].Checks += rollup.Checks
+				uptime[i].Up += rollup.Up
+			}
+		}
+	}
+	return uptime
+}
+
+// uptimeField is the endpoint hash field of a window's uptime
+func uptimeField(window string) string {
+	return "uptime_" + window
+}
+
+// formatUptime and parseUptime store a window as "<up>/<checks>"
+func formatUptime(u Uptime) string {
+	return fmt.Sprintf("%d/%d", u.Up, u.Checks)
+}
+
+func parseUptime(window, value string) (Uptime, bool) {
+	u := Uptime{Window: window}
+	if _, err := fmt.Sscanf(value, "%d/%d", &u.Up, &u.Checks); err != nil || u.Up < 0 || u.Up > u.Checks {
+		return Uptime{}, false
+	}
+	return u, true
+}
@@ -0,0 +1,40 @@
+// Package version holds the build information of the binaries. Release
+// builds set it with -ldflags, e.g.
+//
+//	go build -ldflags "-X certs-n-status/store/version.Version=1.4.0 \
+//	    -X certs-n-status/store/version.Commit=$(git rev-parse --short HEAD) \
+//	    -X certs-n-status/store/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Local builds keep the defaults, dev and unknown.
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Set at build time with -ldflags -X
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown" // build date, RFC 3339 UTC
+)
+
+// Info is the build information, as the version endpoints return it
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+}
+
+// String formats i for --version and the startup log, e.g.
+// "1.4.0 (commit 1a2b3c4, built 2024-03-01T12:00:00Z, go1.25.3)"
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", i.Version, i.Commit, i.Date, i.GoVersion)
+}
@@ -0,0 +1,18 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestGet tests the defaults of a local build and their formatting
+func TestGet(t *testing.T) {
+	info := Get()
+	want := Info{Version: "dev", Commit: "unknown", Date: "unknown", GoVersion: runtime.Version()}
+	if info != want {
+		t.Errorf("Get() = %+v, want %+v", info, want)
+	}
+	if got, want := info.String(), "dev (commit unknown, built unknown, "+runtime.Version()+")"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}